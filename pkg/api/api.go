@@ -20,6 +20,7 @@ import (
 	"github.com/grafana/dskit/middleware"
 	"github.com/grafana/dskit/server"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
 
 	"github.com/grafana/mimir/pkg/alertmanager"
 	"github.com/grafana/mimir/pkg/alertmanager/alertmanagerpb"
@@ -260,13 +261,21 @@ func (a *API) RegisterRuntimeConfig(runtimeConfigHandler http.HandlerFunc, userL
 
 const PrometheusPushEndpoint = "/api/v1/push"
 const OTLPPushEndpoint = "/otlp/v1/metrics"
+const InfluxPushEndpoint = "/api/v1/push/influx/write"
+const InfluxV2PushEndpoint = "/api/v2/write"
+const GraphitePushEndpoint = "/api/v1/push/graphite"
 
 // RegisterDistributor registers the endpoints associated with the distributor.
 func (a *API) RegisterDistributor(d *distributor.Distributor, pushConfig distributor.Config, reg prometheus.Registerer, limits *validation.Overrides) {
 	distributorpb.RegisterDistributorServer(a.server.GRPC, d)
+	pmetricotlp.RegisterGRPCServer(a.server.GRPC, distributor.NewOTLPGRPCServer(pushConfig.MaxOTLPRequestSize, a.cfg.EnableOtelMetadataStorage, limits, pushConfig.RetryConfig, d.PushWithMiddlewares, d.PushMetrics, reg, a.logger, pushConfig.DirectOTLPTranslationEnabled))
 
 	a.RegisterRoute(PrometheusPushEndpoint, distributor.Handler(pushConfig.MaxRecvMsgSize, d.RequestBufferPool, a.sourceIPs, a.cfg.SkipLabelNameValidationHeader, limits, pushConfig.RetryConfig, d.PushWithMiddlewares, d.PushMetrics, a.logger), true, false, "POST")
 	a.RegisterRoute(OTLPPushEndpoint, distributor.OTLPHandler(pushConfig.MaxOTLPRequestSize, d.RequestBufferPool, a.sourceIPs, a.cfg.EnableOtelMetadataStorage, limits, pushConfig.RetryConfig, d.PushWithMiddlewares, d.PushMetrics, reg, a.logger, pushConfig.DirectOTLPTranslationEnabled), true, false, "POST")
+	influxHandler := distributor.InfluxHandler(pushConfig.MaxRecvMsgSize, d.RequestBufferPool, a.sourceIPs, limits, pushConfig.RetryConfig, d.PushWithMiddlewares, d.PushMetrics, a.logger)
+	a.RegisterRoute(InfluxPushEndpoint, influxHandler, true, false, "POST")
+	a.RegisterRoute(InfluxV2PushEndpoint, influxHandler, true, false, "POST")
+	a.RegisterRoute(GraphitePushEndpoint, distributor.GraphiteHandler(pushConfig.MaxRecvMsgSize, d.RequestBufferPool, a.sourceIPs, limits, pushConfig.RetryConfig, d.PushWithMiddlewares, d.PushMetrics, a.logger), true, false, "POST")
 
 	a.indexPage.AddLinks(defaultWeight, "Distributor", []IndexPageLink{
 		{Desc: "Ring status", Path: "/distributor/ring"},
@@ -449,6 +458,7 @@ func (a *API) RegisterQueryAPI(handler http.Handler, buildInfoHandler http.Handl
 	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/cardinality/active_series"), handler, true, true, "GET", "POST")
 	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/cardinality/active_native_histogram_metrics"), handler, true, true, "GET", "POST")
 	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/format_query"), handler, true, true, "GET", "POST")
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/explain_query"), handler, true, true, "GET", "POST")
 }
 
 // RegisterQueryFrontendHandler registers the Prometheus routes supported by the
@@ -464,6 +474,20 @@ func (a *API) RegisterQueryFrontend1(f *frontendv1.Frontend) {
 
 func (a *API) RegisterQueryFrontend2(f *frontendv2.Frontend) {
 	frontendv2pb.RegisterFrontendForQuerierServer(a.server.GRPC, f)
+
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/active_queries"), http.HandlerFunc(f.ActiveQueriesHandler), true, true, "GET")
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/active_queries/{id}"), http.HandlerFunc(f.CancelActiveQueryHandler), true, true, "DELETE")
+}
+
+// RegisterActiveQueriesNotSupported registers the active queries routes for query-frontends that don't use
+// the query-scheduler (frontend v1 or downstream URL), which can't list nor cancel active queries.
+func (a *API) RegisterActiveQueriesNotSupported() {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "listing and canceling active queries is only supported when the query-frontend uses the query-scheduler", http.StatusNotImplemented)
+	})
+
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/active_queries"), handler, true, true, "GET")
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/active_queries/{id}"), handler, true, true, "DELETE")
 }
 
 func (a *API) RegisterQueryScheduler(f *scheduler.Scheduler) {
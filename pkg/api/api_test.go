@@ -251,6 +251,31 @@ func TestApiIngesterShutdown(t *testing.T) {
 	}
 }
 
+func TestApiActiveQueriesNotSupported(t *testing.T) {
+	serverCfg := getServerConfig(t)
+	srv, err := server.New(serverCfg)
+	require.NoError(t, err)
+
+	go func() { _ = srv.Run() }()
+	t.Cleanup(srv.Stop)
+
+	api, err := New(Config{PrometheusHTTPPrefix: "/prometheus"}, tenantfederation.Config{}, serverCfg, srv, log.NewNopLogger())
+	require.NoError(t, err)
+
+	api.RegisterActiveQueriesNotSupported()
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/prometheus/api/v1/active_queries", nil),
+		httptest.NewRequest("DELETE", "/prometheus/api/v1/active_queries/some-id", nil),
+	} {
+		req.Header.Set("X-Scope-OrgID", "user-1")
+		w := httptest.NewRecorder()
+		api.server.HTTP.ServeHTTP(w, req)
+		require.Equal(t, http.StatusNotImplemented, w.Code)
+		require.Contains(t, w.Body.String(), "only supported when the query-frontend uses the query-scheduler")
+	}
+}
+
 // Generates server config, with gRPC listening on random port.
 func getServerConfig(t *testing.T) server.Config {
 	grpcHost, grpcPortNum := getHostnameAndRandomPort(t)
@@ -269,8 +269,10 @@ const (
 )
 
 type PushMetrics struct {
-	otlpRequestCounter   *prometheus.CounterVec
-	uncompressedBodySize *prometheus.HistogramVec
+	otlpRequestCounter                  *prometheus.CounterVec
+	remoteWriteRequestCounter           *prometheus.CounterVec
+	remoteWriteIgnoredCreatedTimestamps *prometheus.CounterVec
+	uncompressedBodySize                *prometheus.HistogramVec
 }
 
 func newPushMetrics(reg prometheus.Registerer) *PushMetrics {
@@ -279,6 +281,14 @@ func newPushMetrics(reg prometheus.Registerer) *PushMetrics {
 			Name: "cortex_distributor_otlp_requests_total",
 			Help: "The total number of OTLP requests that have come in to the distributor.",
 		}, []string{"user"}),
+		remoteWriteRequestCounter: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_distributor_remote_write_requests_total",
+			Help: "The total number of Prometheus remote-write requests that have come in to the distributor, by protocol version.",
+		}, []string{"user", "version"}),
+		remoteWriteIgnoredCreatedTimestamps: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_distributor_remote_write_ignored_created_timestamps_total",
+			Help: "The total number of series with a created timestamp received by the distributor in Prometheus remote-write 2.0 requests, whose created timestamp is not supported and has been ignored.",
+		}, []string{"user"}),
 		uncompressedBodySize: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
 			Name:                            "cortex_distributor_uncompressed_request_body_size_bytes",
 			Help:                            "Size of uncompressed request body in bytes.",
@@ -295,6 +305,18 @@ func (m *PushMetrics) IncOTLPRequest(user string) {
 	}
 }
 
+func (m *PushMetrics) IncRemoteWriteRequest(user, version string) {
+	if m != nil {
+		m.remoteWriteRequestCounter.WithLabelValues(user, version).Inc()
+	}
+}
+
+func (m *PushMetrics) AddRemoteWriteIgnoredCreatedTimestamps(user string, count int) {
+	if m != nil && count > 0 {
+		m.remoteWriteIgnoredCreatedTimestamps.WithLabelValues(user).Add(float64(count))
+	}
+}
+
 func (m *PushMetrics) ObserveUncompressedBodySize(user string, size float64) {
 	if m != nil {
 		m.uncompressedBodySize.WithLabelValues(user).Observe(size)
@@ -303,6 +325,8 @@ func (m *PushMetrics) ObserveUncompressedBodySize(user string, size float64) {
 
 func (m *PushMetrics) deleteUserMetrics(user string) {
 	m.otlpRequestCounter.DeleteLabelValues(user)
+	m.remoteWriteRequestCounter.DeletePartialMatch(prometheus.Labels{"user": user})
+	m.remoteWriteIgnoredCreatedTimestamps.DeleteLabelValues(user)
 	m.uncompressedBodySize.DeleteLabelValues(user)
 }
 
@@ -788,6 +812,7 @@ func (d *Distributor) wrapPushWithMiddlewares(next PushFunc) PushFunc {
 	middlewares = append(middlewares, d.prePushSortAndFilterMiddleware)
 	middlewares = append(middlewares, d.prePushValidationMiddleware)
 	middlewares = append(middlewares, d.cfg.PushWrappers...)
+	middlewares = append(middlewares, writtenStatsMiddleware) // should run last to count what is actually written
 
 	for ix := len(middlewares) - 1; ix >= 0; ix-- {
 		next = middlewares[ix](next)
@@ -796,6 +821,33 @@ func (d *Distributor) wrapPushWithMiddlewares(next PushFunc) PushFunc {
 	return next
 }
 
+// writtenStatsMiddleware counts the samples, histograms and exemplars of the request, once the other middlewares
+// have deduplicated, relabeled or dropped series, if the written stats are tracked in the context.
+func writtenStatsMiddleware(next PushFunc) PushFunc {
+	return func(ctx context.Context, pushReq *Request) error {
+		stats := writtenStatsFromContext(ctx)
+		if stats == nil {
+			return next(ctx, pushReq)
+		}
+
+		next, maybeCleanup := NextOrCleanup(next, pushReq)
+		defer maybeCleanup()
+
+		req, err := pushReq.WriteRequest()
+		if err != nil {
+			return err
+		}
+
+		for _, ts := range req.Timeseries {
+			stats.Samples += len(ts.Samples)
+			stats.Histograms += len(ts.Histograms)
+			stats.Exemplars += len(ts.Exemplars)
+		}
+
+		return next(ctx, pushReq)
+	}
+}
+
 func (d *Distributor) prePushHaDedupeMiddleware(next PushFunc) PushFunc {
 	return func(ctx context.Context, pushReq *Request) error {
 		next, maybeCleanup := NextOrCleanup(next, pushReq)
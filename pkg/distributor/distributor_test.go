@@ -42,6 +42,7 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/relabel"
 	"github.com/prometheus/prometheus/scrape"
+	"github.com/prometheus/prometheus/storage/remote"
 	promtestutil "github.com/prometheus/prometheus/util/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -4649,6 +4650,39 @@ func TestInstanceLimitsBeforeHaDedupe(t *testing.T) {
 	assert.Equal(t, []*mimirpb.WriteRequest{expectedWriteReq}, submittedWriteReqs)
 }
 
+func TestWrittenStatsMiddleware(t *testing.T) {
+	var limits validation.Limits
+	flagext.DefaultValues(&limits)
+	limits.MetricRelabelingEnabled = true
+	limits.MetricRelabelConfigs = []*relabel.Config{{
+		SourceLabels: []model.LabelName{model.MetricNameLabel},
+		Regex:        relabel.MustNewRegexp("dropped"),
+		Action:       relabel.Drop,
+	}}
+
+	ds, _, _, _ := prepare(t, prepConfig{
+		numDistributors: 1,
+		limits:          &limits,
+	})
+
+	mockPush := func(_ context.Context, pushReq *Request) error {
+		pushReq.CleanUp()
+		return nil
+	}
+	wrappedMockPush := ds[0].wrapPushWithMiddlewares(mockPush)
+
+	req := &mimirpb.WriteRequest{Timeseries: []mimirpb.PreallocTimeseries{
+		makeTimeseries([]string{model.MetricNameLabel, "kept"}, makeSamples(100, 1), nil),
+		makeTimeseries([]string{model.MetricNameLabel, "dropped"}, makeSamples(100, 2), nil),
+	}}
+
+	// The series dropped by the relabeling rules are not counted as written.
+	stats := &remote.WriteResponseStats{}
+	ctx := contextWithWrittenStats(user.InjectOrgID(context.Background(), "user"), stats)
+	require.NoError(t, wrappedMockPush(ctx, NewParsedRequest(req)))
+	assert.Equal(t, remote.WriteResponseStats{Samples: 1}, *stats)
+}
+
 func TestRelabelMiddleware(t *testing.T) {
 	ctxWithUser := user.InjectOrgID(context.Background(), "user")
 
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package distributor
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/middleware"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/mimir/pkg/distributor/graphitepush"
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+// GraphiteHandler is a http.Handler accepting Graphite plaintext protocol write requests. The metric paths
+// are mapped to Prometheus metrics following the tenant's Graphite mappings.
+func GraphiteHandler(
+	maxRecvMsgSize int,
+	requestBufferPool util.Pool,
+	sourceIPs *middleware.SourceIPExtractor,
+	limits *validation.Overrides,
+	retryCfg RetryConfig,
+	push PushFunc,
+	pushMetrics *PushMetrics,
+	logger log.Logger,
+) http.Handler {
+	return handler(maxRecvMsgSize, requestBufferPool, sourceIPs, false, limits, retryCfg, push, logger, func(ctx context.Context, r *http.Request, maxRecvMsgSize int, buffers *util.RequestBuffers, req *mimirpb.PreallocWriteRequest, _ log.Logger) error {
+		tenantID, err := tenant.TenantID(ctx)
+		if err != nil {
+			return err
+		}
+
+		var compression util.CompressionType
+		switch contentEncoding := r.Header.Get("Content-Encoding"); contentEncoding {
+		case "gzip":
+			compression = util.Gzip
+		case "":
+			compression = util.NoCompression
+		default:
+			return httpgrpc.Errorf(http.StatusUnsupportedMediaType, "unsupported compression: %s. Only \"gzip\" or no compression supported", contentEncoding)
+		}
+
+		unmarshaler := graphitePlaintextUnmarshaler{
+			request:  req,
+			mappings: limits.GraphiteMappings(tenantID),
+			now:      time.Now(),
+		}
+		bodySize, err := util.ParseProtoReader(ctx, r.Body, int(r.ContentLength), maxRecvMsgSize, buffers, unmarshaler, compression)
+		var tooLargeErr util.MsgSizeTooLargeErr
+		if errors.As(err, &tooLargeErr) {
+			return httpgrpc.Errorf(http.StatusRequestEntityTooLarge, distributorMaxWriteMessageSizeErr{
+				actual: tooLargeErr.Actual,
+				limit:  tooLargeErr.Limit,
+			}.Error())
+		}
+		if err != nil {
+			return err
+		}
+
+		pushMetrics.ObserveUncompressedBodySize(tenantID, float64(bodySize))
+
+		return nil
+	})
+}
+
+// graphitePlaintextUnmarshaler implements proto.Message, parsing the Graphite plaintext protocol into a write request.
+type graphitePlaintextUnmarshaler struct {
+	request  *mimirpb.PreallocWriteRequest
+	mappings []*validation.GraphiteMapping
+	now      time.Time
+}
+
+func (u graphitePlaintextUnmarshaler) ProtoMessage() {}
+
+func (u graphitePlaintextUnmarshaler) Reset() {}
+
+func (u graphitePlaintextUnmarshaler) String() string {
+	return ""
+}
+
+func (u graphitePlaintextUnmarshaler) Unmarshal(data []byte) error {
+	series, err := graphitepush.Parse(data, u.mappings, u.now)
+	if err != nil {
+		return err
+	}
+	u.request.Timeseries = series
+	return nil
+}
@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package distributor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestGraphiteHandler(t *testing.T) {
+	const body = "servers.web-1.cpu.user 10 1700000001\nservers.web-1.uptime 90 1700000002"
+
+	gzipped := func(data string) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		_, err := w.Write([]byte(data))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		return buf.Bytes()
+	}
+
+	limits, err := validation.NewOverrides(validation.Limits{}, validation.NewMockTenantLimits(map[string]*validation.Limits{
+		"mapped": {
+			GraphiteMappings: []*validation.GraphiteMapping{
+				{Match: "servers.*.cpu.*", Name: "server_cpu_$2", Labels: map[string]string{"server": "$1"}},
+			},
+		},
+	}))
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		tenantID        string
+		body            []byte
+		contentEncoding string
+		maxMsgSize      int
+
+		expectedCode   int
+		expectedBody   string
+		expectedSeries []string
+	}{
+		"should push the series mapped with the tenant's mappings": {
+			tenantID:       "mapped",
+			body:           []byte(body),
+			maxMsgSize:     100000,
+			expectedCode:   http.StatusOK,
+			expectedSeries: []string{`{__name__="server_cpu_user", server="web-1"}`, `{__name__="servers_web_1_uptime"}`},
+		},
+		"should push the unmapped series of tenants without mappings": {
+			tenantID:       "unmapped",
+			body:           []byte(body),
+			maxMsgSize:     100000,
+			expectedCode:   http.StatusOK,
+			expectedSeries: []string{`{__name__="servers_web_1_cpu_user"}`, `{__name__="servers_web_1_uptime"}`},
+		},
+		"should accept gzip compressed requests": {
+			tenantID:        "mapped",
+			body:            gzipped(body),
+			contentEncoding: "gzip",
+			maxMsgSize:      100000,
+			expectedCode:    http.StatusOK,
+			expectedSeries:  []string{`{__name__="server_cpu_user", server="web-1"}`, `{__name__="servers_web_1_uptime"}`},
+		},
+		"should reject unsupported compressions": {
+			tenantID:        "mapped",
+			body:            []byte(body),
+			contentEncoding: "snappy",
+			maxMsgSize:      100000,
+			expectedCode:    http.StatusUnsupportedMediaType,
+			expectedBody:    "unsupported compression: snappy",
+		},
+		"should reject invalid lines": {
+			tenantID:     "mapped",
+			body:         []byte("servers.web-1.cpu.user ten 1700000001"),
+			maxMsgSize:   100000,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `unable to parse line 1: invalid value "ten"`,
+		},
+		"should reject requests larger than the limit": {
+			tenantID:     "mapped",
+			body:         []byte(body),
+			maxMsgSize:   10,
+			expectedCode: http.StatusRequestEntityTooLarge,
+			expectedBody: "the incoming push request has been rejected because its message size",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var pushedSeries []string
+			push := func(_ context.Context, pushReq *Request) error {
+				req, err := pushReq.WriteRequest()
+				if err != nil {
+					return err
+				}
+				for _, ts := range req.Timeseries {
+					pushedSeries = append(pushedSeries, mimirpb.FromLabelAdaptersToLabels(ts.Labels).String())
+				}
+				pushReq.CleanUp()
+				return nil
+			}
+
+			req, err := http.NewRequest("POST", "http://localhost/api/v1/push/graphite", bytes.NewReader(tc.body))
+			require.NoError(t, err)
+			if tc.contentEncoding != "" {
+				req.Header.Set("Content-Encoding", tc.contentEncoding)
+			}
+			req = req.WithContext(user.InjectOrgID(context.Background(), tc.tenantID))
+
+			resp := httptest.NewRecorder()
+			handler := GraphiteHandler(tc.maxMsgSize, nil, nil, limits, RetryConfig{}, push, nil, log.NewNopLogger())
+			handler.ServeHTTP(resp, req)
+
+			require.Equal(t, tc.expectedCode, resp.Code, resp.Body.String())
+			if tc.expectedCode != http.StatusOK {
+				assert.Contains(t, resp.Body.String(), tc.expectedBody)
+				assert.Empty(t, pushedSeries)
+				return
+			}
+			assert.Equal(t, tc.expectedSeries, pushedSeries)
+		})
+	}
+}
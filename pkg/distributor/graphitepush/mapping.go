@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package graphitepush
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/prometheus/util/strutil"
+
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+// mapPath returns the metric name and the labels of the first mapping whose glob pattern matches path, along with
+// whether the metric is kept. The paths not matching any mapping are converted to metric names as they are.
+func mapPath(path string, mappings []*validation.GraphiteMapping) (name string, labels map[string]string, keep bool) {
+	components := strings.Split(path, ".")
+
+	for _, m := range mappings {
+		captures, ok := matchGlob(strings.Split(m.Match, "."), components)
+		if !ok {
+			continue
+		}
+		if m.Action == validation.GraphiteMappingActionDrop {
+			return "", nil, false
+		}
+
+		labels = make(map[string]string, len(m.Labels))
+		for labelName, labelValue := range m.Labels {
+			labels[labelName] = expand(labelValue, captures)
+		}
+		return sanitizeName(expand(m.Name, captures)), labels, true
+	}
+
+	return sanitizeName(path), nil, true
+}
+
+// matchGlob matches the path components with the pattern ones, returning the values captured by each of
+// the wildcards of the pattern. A wildcard matches any sequence of characters within a single path component.
+func matchGlob(pattern, components []string) ([]string, bool) {
+	if len(pattern) != len(components) {
+		return nil, false
+	}
+
+	var (
+		captures []string
+		ok       bool
+	)
+	for i := range pattern {
+		if captures, ok = matchComponent(pattern[i], components[i], captures); !ok {
+			return nil, false
+		}
+	}
+	return captures, true
+}
+
+func matchComponent(pattern, component string, captures []string) ([]string, bool) {
+	wildcard := strings.IndexByte(pattern, '*')
+	if wildcard < 0 {
+		return captures, pattern == component
+	}
+	if !strings.HasPrefix(component, pattern[:wildcard]) {
+		return captures, false
+	}
+
+	pattern, component = pattern[wildcard+1:], component[wildcard:]
+	// Wildcards are greedy: the longest capture leaving a match for the rest of the pattern wins.
+	for end := len(component); end >= 0; end-- {
+		if result, ok := matchComponent(pattern, component[end:], append(captures, component[:end])); ok {
+			return result, true
+		}
+	}
+	return captures, false
+}
+
+// expand replaces the $n and ${n} references in template with the value captured by the nth wildcard.
+// References to missing captures are replaced with an empty string.
+func expand(template string, captures []string) string {
+	if !strings.Contains(template, "$") {
+		return template
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(template); i++ {
+		if template[i] != '$' || i+1 == len(template) {
+			b.WriteByte(template[i])
+			continue
+		}
+
+		start, end := i+1, i+1
+		if template[start] == '{' {
+			start++
+			end = strings.IndexByte(template[start:], '}')
+			if end < 0 {
+				b.WriteByte(template[i])
+				continue
+			}
+			end += start
+		} else {
+			for end < len(template) && template[end] >= '0' && template[end] <= '9' {
+				end++
+			}
+		}
+
+		n, err := strconv.Atoi(template[start:end])
+		if err != nil {
+			b.WriteByte(template[i])
+			continue
+		}
+		if n >= 1 && n <= len(captures) {
+			b.WriteString(captures[n-1])
+		}
+		if template[i+1] == '{' {
+			end++
+		}
+		i = end - 1
+	}
+	return b.String()
+}
+
+// sanitizeName replaces the characters which aren't valid in a Prometheus metric or label name with underscores.
+func sanitizeName(name string) string {
+	name = strutil.SanitizeLabelName(name)
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+// Package graphitepush parses Graphite plaintext protocol write requests into Mimir series.
+package graphitepush
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+// Parse parses the Graphite plaintext protocol data into series. Each line is a metric in the
+// "<path> <value> [<timestamp>]" format, where the path is optionally followed by ";<tag>=<value>" tags, and the
+// timestamp is in seconds. Lines without a timestamp, or with a -1 timestamp, get the now timestamp.
+//
+// The path of each metric is mapped to a metric name and labels by the first of the mappings whose glob pattern
+// matches it. The tags of the metric are added to the labels, unless a label with the same name, once sanitized, comes
+// from the mapping. The last of the tags with the same name wins, and the mapping labels with an empty value are skipped.
+func Parse(data []byte, mappings []*validation.GraphiteMapping, now time.Time) ([]mimirpb.PreallocTimeseries, error) {
+	series := mimirpb.PreallocTimeseriesSliceFromPool()
+	nowMs := now.UnixMilli()
+
+	for lineNum := 1; len(data) > 0; lineNum++ {
+		var line []byte
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			line, data = data, nil
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var err error
+		series, err = appendLineSeries(series, line, mappings, nowMs)
+		if err != nil {
+			mimirpb.ReuseSlice(series)
+			return nil, fmt.Errorf("unable to parse line %d: %w", lineNum, err)
+		}
+	}
+
+	return series, nil
+}
+
+// appendLineSeries parses a line and appends its series, unless it's dropped by the mappings.
+func appendLineSeries(series []mimirpb.PreallocTimeseries, line []byte, mappings []*validation.GraphiteMapping, nowMs int64) ([]mimirpb.PreallocTimeseries, error) {
+	parts := strings.Fields(string(line))
+	if len(parts) != 2 && len(parts) != 3 {
+		return series, fmt.Errorf("expected \"<path> <value> [<timestamp>]\", got %d fields", len(parts))
+	}
+
+	path, tags, err := parsePath(parts[0])
+	if err != nil {
+		return series, err
+	}
+
+	value, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return series, fmt.Errorf("invalid value %q", parts[1])
+	}
+
+	timestampMs := nowMs
+	if len(parts) == 3 {
+		ts, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil || math.IsNaN(ts) || math.IsInf(ts, 0) {
+			return series, fmt.Errorf("invalid timestamp %q", parts[2])
+		}
+		if ts != -1 {
+			timestampMs = int64(ts * 1000)
+		}
+	}
+
+	name, labels, keep := mapPath(path, mappings)
+	if !keep {
+		return series, nil
+	}
+
+	// Names are compared once sanitized, as they're emitted, so that they can't be duplicated.
+	labelValues := make(map[string]string, len(tags)+len(labels))
+	for _, tag := range tags {
+		labelValues[sanitizeName(tag.Name)] = tag.Value
+	}
+	labelNames := make([]string, 0, len(labels))
+	for labelName := range labels {
+		labelNames = append(labelNames, labelName)
+	}
+	// Iterate the mapping labels in order, for the ones with the same sanitized name to be deterministic.
+	sort.Strings(labelNames)
+	for _, labelName := range labelNames {
+		if labelValue := labels[labelName]; labelValue != "" {
+			labelValues[sanitizeName(labelName)] = labelValue
+		}
+	}
+	// The metric name comes from the path only.
+	delete(labelValues, model.MetricNameLabel)
+
+	ts := mimirpb.TimeseriesFromPool()
+	ts.Labels = append(ts.Labels, mimirpb.LabelAdapter{Name: model.MetricNameLabel, Value: name})
+	for labelName, labelValue := range labelValues {
+		ts.Labels = append(ts.Labels, mimirpb.LabelAdapter{Name: labelName, Value: labelValue})
+	}
+	sort.Slice(ts.Labels, func(i, j int) bool { return ts.Labels[i].Name < ts.Labels[j].Name })
+	ts.Samples = append(ts.Samples, mimirpb.Sample{Value: value, TimestampMs: timestampMs})
+
+	return append(series, mimirpb.PreallocTimeseries{TimeSeries: ts}), nil
+}
+
+// parsePath splits a tagged path, like "disk.used;datacenter=dc1;rack=a1", into the path and its tags.
+func parsePath(taggedPath string) (string, []mimirpb.LabelAdapter, error) {
+	path, rest, _ := strings.Cut(taggedPath, ";")
+	if path == "" {
+		return "", nil, fmt.Errorf("missing path")
+	}
+
+	var tags []mimirpb.LabelAdapter
+	for rest != "" {
+		var tag string
+		tag, rest, _ = strings.Cut(rest, ";")
+
+		name, value, ok := strings.Cut(tag, "=")
+		if !ok || name == "" || value == "" {
+			return "", nil, fmt.Errorf("invalid tag %q", tag)
+		}
+		if name == model.MetricNameLabel {
+			// The metric name is built from the path.
+			continue
+		}
+		tags = append(tags, mimirpb.LabelAdapter{Name: name, Value: value})
+	}
+
+	return path, tags, nil
+}
@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package graphitepush
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	nowMs := now.UnixMilli()
+
+	mappings := []*validation.GraphiteMapping{
+		{Match: "servers.*.debug.*", Action: validation.GraphiteMappingActionDrop},
+		{Match: "servers.*.cpu.*", Name: "server_cpu_${2}_seconds", Labels: map[string]string{"server": "$1", "source": "graphite"}},
+		{Match: "servers.*.*", Name: "server_$2"},
+		{Match: "app.*-*.requests", Name: "app_requests_total", Labels: map[string]string{"app": "$1", "env": "$2"}},
+		{Match: "jobs.*.*", Name: "job_$2", Labels: map[string]string{"job.name": "$1", "team": "$3"}},
+	}
+
+	tests := map[string]struct {
+		data        string
+		expected    []mimirpb.TimeSeries
+		expectedErr string
+	}{
+		"unmatched path is converted to a metric name": {
+			data: "foo.bar-baz.1xx 1.5 1700000001",
+			expected: []mimirpb.TimeSeries{
+				series(1.5, 1700000001000, "__name__", "foo_bar_baz_1xx"),
+			},
+		},
+		"unmatched path starting with a digit": {
+			data: "5xx.count 1 1700000001",
+			expected: []mimirpb.TimeSeries{
+				series(1, 1700000001000, "__name__", "_5xx_count"),
+			},
+		},
+		"the first matching mapping applies": {
+			data: "servers.web-1.cpu.user 10 1700000001\nservers.web-1.load 2 1700000002",
+			expected: []mimirpb.TimeSeries{
+				series(10, 1700000001000, "__name__", "server_cpu_user_seconds", "server", "web-1", "source", "graphite"),
+				series(2, 1700000002000, "__name__", "server_load"),
+			},
+		},
+		"the paths must have the same number of components of the pattern": {
+			data: "servers.web-1.cpu.user.extra 10 1700000001",
+			expected: []mimirpb.TimeSeries{
+				series(10, 1700000001000, "__name__", "servers_web_1_cpu_user_extra"),
+			},
+		},
+		"multiple wildcards in a single component": {
+			data: "app.checkout-prod-eu.requests 3 1700000001",
+			expected: []mimirpb.TimeSeries{
+				series(3, 1700000001000, "__name__", "app_requests_total", "app", "checkout-prod", "env", "eu"),
+			},
+		},
+		"dropped metrics": {
+			data:     "servers.web-1.debug.gc 1 1700000001",
+			expected: []mimirpb.TimeSeries{},
+		},
+		"tagged metrics, with the mapping labels taking precedence over the tags": {
+			data: "servers.web-1.cpu.user;dc=eu;source=collectd;__name__=x;tag.name=a 10 1700000001",
+			expected: []mimirpb.TimeSeries{
+				series(10, 1700000001000, "__name__", "server_cpu_user_seconds", "dc", "eu", "server", "web-1", "source", "graphite", "tag_name", "a"),
+			},
+		},
+		"the mapping labels take precedence over the tags with the same sanitized name": {
+			data: "jobs.backup.duration;job_name=restore 5 1700000001",
+			expected: []mimirpb.TimeSeries{
+				series(5, 1700000001000, "__name__", "job_duration", "job_name", "backup"),
+			},
+		},
+		"the mapping labels with an empty value don't take precedence over the tags": {
+			data: "jobs.backup.duration;team=infra 5 1700000001",
+			expected: []mimirpb.TimeSeries{
+				series(5, 1700000001000, "__name__", "job_duration", "job_name", "backup", "team", "infra"),
+			},
+		},
+		"the last of the tags with the same sanitized name wins": {
+			data: "foo;dc=eu;dc=us;tag.name=a;tag_name=b 1 1700000001",
+			expected: []mimirpb.TimeSeries{
+				series(1, 1700000001000, "__name__", "foo", "dc", "us", "tag_name", "b"),
+			},
+		},
+		"missing and -1 timestamps get the now timestamp": {
+			data: "foo 1\nbar 2 -1",
+			expected: []mimirpb.TimeSeries{
+				series(1, nowMs, "__name__", "foo"),
+				series(2, nowMs, "__name__", "bar"),
+			},
+		},
+		"fractional timestamps, blank lines and extra whitespaces": {
+			data: "\n  foo\t1   1700000001.5 \r\n\n",
+			expected: []mimirpb.TimeSeries{
+				series(1, 1700000001500, "__name__", "foo"),
+			},
+		},
+		"too many fields": {
+			data:        "foo 1 1700000001 bar",
+			expectedErr: `unable to parse line 1: expected "<path> <value> [<timestamp>]", got 4 fields`,
+		},
+		"invalid value": {
+			data:        "foo 1 1\nbar abc 1700000001",
+			expectedErr: `unable to parse line 2: invalid value "abc"`,
+		},
+		"invalid timestamp": {
+			data:        "foo 1 abc",
+			expectedErr: `unable to parse line 1: invalid timestamp "abc"`,
+		},
+		"invalid tag": {
+			data:        "foo;dc 1 1700000001",
+			expectedErr: `unable to parse line 1: invalid tag "dc"`,
+		},
+		"missing path": {
+			data:        ";dc=eu 1 1700000001",
+			expectedErr: "unable to parse line 1: missing path",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := Parse([]byte(tc.data), mappings, now)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			actualSeries := make([]mimirpb.TimeSeries, 0, len(actual))
+			for _, ts := range actual {
+				actualSeries = append(actualSeries, mimirpb.TimeSeries{Labels: ts.Labels, Samples: ts.Samples})
+			}
+			assert.Equal(t, tc.expected, actualSeries)
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	captures := []string{"a", "b"}
+
+	for template, expected := range map[string]string{
+		"name":        "name",
+		"$1_$2":       "a_b",
+		"${1}x_${2}":  "ax_b",
+		"$3":          "",
+		"$0":          "",
+		"cost_$":      "cost_$",
+		"cost_$x":     "cost_$x",
+		"cost_${x}":   "cost_${x}",
+		"cost_${1":    "cost_${1",
+		"$1$2$1":      "aba",
+		"${1}${2}end": "abend",
+	} {
+		assert.Equal(t, expected, expand(template, captures), template)
+	}
+}
+
+func series(value float64, timestampMs int64, labels ...string) mimirpb.TimeSeries {
+	ts := mimirpb.TimeSeries{
+		Samples: []mimirpb.Sample{{Value: value, TimestampMs: timestampMs}},
+	}
+	for i := 0; i < len(labels); i += 2 {
+		ts.Labels = append(ts.Labels, mimirpb.LabelAdapter{Name: labels[i], Value: labels[i+1]})
+	}
+	return ts
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package distributor
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/middleware"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/mimir/pkg/distributor/influxpush"
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+// InfluxHandler is a http.Handler accepting InfluxDB line protocol write requests, as sent to the
+// InfluxDB 1.x /write and 2.x /api/v2/write endpoints.
+func InfluxHandler(
+	maxRecvMsgSize int,
+	requestBufferPool util.Pool,
+	sourceIPs *middleware.SourceIPExtractor,
+	limits *validation.Overrides,
+	retryCfg RetryConfig,
+	push PushFunc,
+	pushMetrics *PushMetrics,
+	logger log.Logger,
+) http.Handler {
+	h := handler(maxRecvMsgSize, requestBufferPool, sourceIPs, false, limits, retryCfg, push, logger, func(ctx context.Context, r *http.Request, maxRecvMsgSize int, buffers *util.RequestBuffers, req *mimirpb.PreallocWriteRequest, _ log.Logger) error {
+		precision, err := influxpush.ParsePrecision(r.URL.Query().Get("precision"))
+		if err != nil {
+			return err
+		}
+
+		var compression util.CompressionType
+		switch contentEncoding := r.Header.Get("Content-Encoding"); contentEncoding {
+		case "gzip":
+			compression = util.Gzip
+		case "":
+			compression = util.NoCompression
+		default:
+			return httpgrpc.Errorf(http.StatusUnsupportedMediaType, "unsupported compression: %s. Only \"gzip\" or no compression supported", contentEncoding)
+		}
+
+		unmarshaler := influxLineProtocolUnmarshaler{
+			request:   req,
+			precision: precision,
+			now:       time.Now(),
+		}
+		bodySize, err := util.ParseProtoReader(ctx, r.Body, int(r.ContentLength), maxRecvMsgSize, buffers, unmarshaler, compression)
+		var tooLargeErr util.MsgSizeTooLargeErr
+		if errors.As(err, &tooLargeErr) {
+			// InfluxDB clients split the batch of points when the request is too large.
+			return httpgrpc.Errorf(http.StatusRequestEntityTooLarge, distributorMaxWriteMessageSizeErr{
+				actual: tooLargeErr.Actual,
+				limit:  tooLargeErr.Limit,
+			}.Error())
+		}
+		if err != nil {
+			return err
+		}
+
+		tenantID, err := tenant.TenantID(ctx)
+		if err != nil {
+			return err
+		}
+		pushMetrics.ObserveUncompressedBodySize(tenantID, float64(bodySize))
+
+		return nil
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &trackingResponseWriter{ResponseWriter: w}
+		h.ServeHTTP(rw, r)
+
+		// InfluxDB clients expect no content in the response of successful writes.
+		if !rw.wroteHeader {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
+
+// influxLineProtocolUnmarshaler implements proto.Message, parsing the InfluxDB line protocol into a write request.
+type influxLineProtocolUnmarshaler struct {
+	request   *mimirpb.PreallocWriteRequest
+	precision time.Duration
+	now       time.Time
+}
+
+func (u influxLineProtocolUnmarshaler) ProtoMessage() {}
+
+func (u influxLineProtocolUnmarshaler) Reset() {}
+
+func (u influxLineProtocolUnmarshaler) String() string {
+	return ""
+}
+
+func (u influxLineProtocolUnmarshaler) Unmarshal(data []byte) error {
+	series, err := influxpush.Parse(data, u.precision, u.now)
+	if err != nil {
+		return err
+	}
+	u.request.Timeseries = series
+	return nil
+}
@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package distributor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+func TestInfluxHandler(t *testing.T) {
+	const body = "cpu,host=a usage=10,idle=90i 1700000001\nmem,host=a used=5 1700000002"
+
+	gzipped := func(data string) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		_, err := w.Write([]byte(data))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		return buf.Bytes()
+	}
+
+	tests := map[string]struct {
+		body            []byte
+		contentEncoding string
+		precision       string
+		maxMsgSize      int
+
+		expectedCode   int
+		expectedBody   string
+		expectedSeries []string
+		expectedTimes  []int64
+	}{
+		"should push the parsed series and respond with no content": {
+			body:           []byte(body),
+			precision:      "s",
+			maxMsgSize:     100000,
+			expectedCode:   http.StatusNoContent,
+			expectedSeries: []string{`{__name__="cpu_usage", host="a"}`, `{__name__="cpu_idle", host="a"}`, `{__name__="mem_used", host="a"}`},
+			expectedTimes:  []int64{1700000001000, 1700000001000, 1700000002000},
+		},
+		"should accept gzip compressed requests": {
+			body:            gzipped(body),
+			contentEncoding: "gzip",
+			precision:       "ms",
+			maxMsgSize:      100000,
+			expectedCode:    http.StatusNoContent,
+			expectedSeries:  []string{`{__name__="cpu_usage", host="a"}`, `{__name__="cpu_idle", host="a"}`, `{__name__="mem_used", host="a"}`},
+			expectedTimes:   []int64{1700000001, 1700000001, 1700000002},
+		},
+		"should reject unsupported compressions": {
+			body:            []byte(body),
+			contentEncoding: "snappy",
+			maxMsgSize:      100000,
+			expectedCode:    http.StatusUnsupportedMediaType,
+			expectedBody:    "unsupported compression: snappy",
+		},
+		"should reject invalid precisions": {
+			body:         []byte(body),
+			precision:    "d",
+			maxMsgSize:   100000,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `invalid precision "d"`,
+		},
+		"should reject invalid line protocol": {
+			body:         []byte("cpu,host=a"),
+			maxMsgSize:   100000,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "unable to parse line 1: missing fields",
+		},
+		"should reject lines with duplicate tags": {
+			body:         []byte("cpu,host=a,host=b usage=10"),
+			maxMsgSize:   100000,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `unable to parse line 1: duplicate tag "host"`,
+		},
+		"should reject lines with duplicate fields": {
+			body:         []byte("cpu,host=a usage=10,usage=20"),
+			maxMsgSize:   100000,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `unable to parse line 1: duplicate field "usage"`,
+		},
+		"should reject timestamps overflowing once converted to milliseconds": {
+			body:         []byte("cpu,host=a usage=10 9223372036854775807"),
+			precision:    "h",
+			maxMsgSize:   100000,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "unable to parse line 1: timestamp 9223372036854775807 out of range",
+		},
+		"should reject requests larger than the limit": {
+			body:         []byte(body),
+			maxMsgSize:   10,
+			expectedCode: http.StatusRequestEntityTooLarge,
+			expectedBody: "the incoming push request has been rejected because its message size",
+		},
+		"should reject gzip compressed requests larger than the limit once decompressed": {
+			body:            gzipped(body),
+			contentEncoding: "gzip",
+			maxMsgSize:      len(body) - 1,
+			expectedCode:    http.StatusRequestEntityTooLarge,
+			expectedBody:    "the incoming push request has been rejected because its message size",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				pushedSeries []string
+				pushedTimes  []int64
+			)
+			push := func(_ context.Context, pushReq *Request) error {
+				req, err := pushReq.WriteRequest()
+				if err != nil {
+					return err
+				}
+				for _, ts := range req.Timeseries {
+					pushedSeries = append(pushedSeries, mimirpb.FromLabelAdaptersToLabels(ts.Labels).String())
+					pushedTimes = append(pushedTimes, ts.Samples[0].TimestampMs)
+				}
+				pushReq.CleanUp()
+				return nil
+			}
+
+			url := "http://localhost/api/v1/push/influx/write"
+			if tc.precision != "" {
+				url += "?precision=" + tc.precision
+			}
+			req, err := http.NewRequest("POST", url, bytes.NewReader(tc.body))
+			require.NoError(t, err)
+			if tc.contentEncoding != "" {
+				req.Header.Set("Content-Encoding", tc.contentEncoding)
+			}
+			req = req.WithContext(user.InjectOrgID(context.Background(), "test"))
+
+			resp := httptest.NewRecorder()
+			handler := InfluxHandler(tc.maxMsgSize, nil, nil, nil, RetryConfig{}, push, nil, log.NewNopLogger())
+			handler.ServeHTTP(resp, req)
+
+			require.Equal(t, tc.expectedCode, resp.Code, resp.Body.String())
+			if tc.expectedCode != http.StatusNoContent {
+				assert.Contains(t, resp.Body.String(), tc.expectedBody)
+				assert.Empty(t, pushedSeries)
+				return
+			}
+			assert.Empty(t, resp.Body.String())
+			assert.Equal(t, tc.expectedSeries, pushedSeries)
+			assert.Equal(t, tc.expectedTimes, pushedTimes)
+		})
+	}
+}
@@ -0,0 +1,324 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+// Package influxpush parses InfluxDB line protocol write requests into Mimir series.
+package influxpush
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+const (
+	// Characters which can be escaped by a backslash in each element of a line.
+	measurementEscapes = ", \\"
+	tagEscapes         = ",= \\"
+	fieldKeyEscapes    = ",= \\"
+)
+
+// ParsePrecision parses the precision of the timestamps of an InfluxDB write request, as passed in its
+// precision URL parameter. Both the InfluxDB 1.x and 2.x values are supported. Timestamps are in nanoseconds
+// if the precision is empty.
+func ParsePrecision(precision string) (time.Duration, error) {
+	switch precision {
+	case "", "n", "ns":
+		return time.Nanosecond, nil
+	case "u", "us", "µs":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	case "m":
+		return time.Minute, nil
+	case "h":
+		return time.Hour, nil
+	}
+	return 0, fmt.Errorf("invalid precision %q, supported: ns, us, ms, s, m, h", precision)
+}
+
+// Parse parses the InfluxDB line protocol data into series. Each numeric field of a line is a series: its metric
+// name is the measurement and the field key joined by an underscore, and its labels are the tags of the line.
+// Names are sanitized to be valid Prometheus metric and label names, and lines with tags whose names are the same
+// once sanitized are rejected, as well as lines with fields whose metric names are the same. Boolean fields are converted to 1 and 0, while string fields are ignored. Lines
+// without a timestamp get the now timestamp.
+func Parse(data []byte, precision time.Duration, now time.Time) ([]mimirpb.PreallocTimeseries, error) {
+	series := mimirpb.PreallocTimeseriesSliceFromPool()
+	nowMs := now.UnixMilli()
+
+	for lineNum := 1; len(data) > 0; lineNum++ {
+		var line []byte
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			line, data = data, nil
+		}
+
+		line = bytes.TrimRight(bytes.TrimLeft(line, " \t"), " \t\r")
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		var err error
+		series, err = appendLineSeries(series, line, precision, nowMs)
+		if err != nil {
+			mimirpb.ReuseSlice(series)
+			return nil, fmt.Errorf("unable to parse line %d: %w", lineNum, err)
+		}
+	}
+
+	return series, nil
+}
+
+type field struct {
+	key   string
+	name  string
+	value float64
+}
+
+// appendLineSeries parses a line and appends a series for each of its numeric fields.
+func appendLineSeries(series []mimirpb.PreallocTimeseries, line []byte, precision time.Duration, nowMs int64) ([]mimirpb.PreallocTimeseries, error) {
+	token, pos := scanUntil(line, 0, ", ")
+	if len(token) == 0 {
+		return series, fmt.Errorf("missing measurement")
+	}
+	measurement := unescape(token, measurementEscapes)
+
+	var tags []mimirpb.LabelAdapter
+	for pos < len(line) && line[pos] == ',' {
+		var key, value []byte
+		key, pos = scanUntil(line, pos+1, "=, ")
+		if pos >= len(line) || line[pos] != '=' || len(key) == 0 {
+			return series, fmt.Errorf("missing tag key")
+		}
+		value, pos = scanUntil(line, pos+1, ", ")
+		if len(value) == 0 {
+			return series, fmt.Errorf("missing tag value")
+		}
+
+		name := sanitizeName(unescape(key, tagEscapes), false)
+		if name == model.MetricNameLabel {
+			// The metric name is built from the measurement and the field.
+			continue
+		}
+		tags = append(tags, mimirpb.LabelAdapter{Name: name, Value: unescape(value, tagEscapes)})
+	}
+
+	if pos >= len(line) || line[pos] != ' ' {
+		return series, fmt.Errorf("missing fields")
+	}
+	pos = skipSpaces(line, pos)
+
+	var fields []field
+	for {
+		var key []byte
+		key, pos = scanUntil(line, pos, "=, ")
+		if pos >= len(line) || line[pos] != '=' || len(key) == 0 {
+			return series, fmt.Errorf("missing field key")
+		}
+		pos++
+
+		if pos < len(line) && line[pos] == '"' {
+			// String fields can't be represented as samples, and are skipped.
+			end := pos + 1
+			for ; end < len(line) && line[end] != '"'; end++ {
+				if line[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(line) {
+				return series, fmt.Errorf("unterminated string field value")
+			}
+			pos = end + 1
+		} else {
+			var value []byte
+			value, pos = scanUntil(line, pos, ", ")
+			v, err := parseFieldValue(value)
+			if err != nil {
+				return series, fmt.Errorf("invalid value of field %q: %w", key, err)
+			}
+			k := unescape(key, fieldKeyEscapes)
+			fields = append(fields, field{key: k, name: sanitizeName(measurement+"_"+k, true), value: v})
+		}
+
+		if pos < len(line) && line[pos] == ',' {
+			pos++
+			continue
+		}
+		break
+	}
+
+	timestampMs := nowMs
+	if pos < len(line) {
+		if line[pos] != ' ' {
+			return series, fmt.Errorf("invalid field value")
+		}
+		if rest := line[skipSpaces(line, pos):]; len(rest) > 0 {
+			ts, err := strconv.ParseInt(string(rest), 10, 64)
+			if err != nil {
+				return series, fmt.Errorf("invalid timestamp %q", rest)
+			}
+			timestampMs, err = toMilliseconds(ts, precision)
+			if err != nil {
+				return series, err
+			}
+		}
+	}
+
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	for i := 1; i < len(tags); i++ {
+		// Repeated tags, as well as tags whose names are the same once sanitized, would be duplicate labels.
+		if tags[i].Name == tags[i-1].Name {
+			return series, fmt.Errorf("duplicate tag %q", tags[i].Name)
+		}
+	}
+
+	if len(fields) > 1 {
+		// Repeated fields, as well as fields whose metric names are the same once sanitized, would be duplicate series.
+		names := make(map[string]struct{}, len(fields))
+		for _, f := range fields {
+			if _, ok := names[f.name]; ok {
+				return series, fmt.Errorf("duplicate field %q", f.key)
+			}
+			names[f.name] = struct{}{}
+		}
+	}
+
+	for _, f := range fields {
+		ts := mimirpb.TimeseriesFromPool()
+		ts.Labels = append(ts.Labels, mimirpb.LabelAdapter{Name: model.MetricNameLabel, Value: f.name})
+		ts.Labels = append(ts.Labels, tags...)
+		ts.Samples = append(ts.Samples, mimirpb.Sample{Value: f.value, TimestampMs: timestampMs})
+		series = append(series, mimirpb.PreallocTimeseries{TimeSeries: ts})
+	}
+
+	return series, nil
+}
+
+// parseFieldValue parses a float, integer, unsigned integer or boolean field value.
+func parseFieldValue(value []byte) (float64, error) {
+	if len(value) == 0 {
+		return 0, fmt.Errorf("missing value")
+	}
+
+	s := string(value)
+	switch s {
+	case "t", "T", "true", "True", "TRUE":
+		return 1, nil
+	case "f", "F", "false", "False", "FALSE":
+		return 0, nil
+	}
+
+	switch s[len(s)-1] {
+	case 'i':
+		v, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+		return float64(v), err
+	case 'u':
+		v, err := strconv.ParseUint(s[:len(s)-1], 10, 64)
+		return float64(v), err
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	// The line protocol doesn't support these values, although strconv parses them.
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return v, nil
+}
+
+// toMilliseconds converts a timestamp with the given precision to milliseconds. It returns an error if the
+// timestamp in milliseconds overflows an int64.
+func toMilliseconds(ts int64, precision time.Duration) (int64, error) {
+	if precision < time.Millisecond {
+		return ts / int64(time.Millisecond/precision), nil
+	}
+
+	multiplier := int64(precision / time.Millisecond)
+	if ts > math.MaxInt64/multiplier || ts < math.MinInt64/multiplier {
+		return 0, fmt.Errorf("timestamp %d out of range", ts)
+	}
+	return ts * multiplier, nil
+}
+
+// scanUntil returns the token starting at pos and ending at the first unescaped character in stops, along with
+// the position of such character, or the length of the line if there's none.
+func scanUntil(line []byte, pos int, stops string) ([]byte, int) {
+	start := pos
+	for pos < len(line) {
+		if line[pos] == '\\' {
+			pos += 2
+			continue
+		}
+		if strings.IndexByte(stops, line[pos]) >= 0 {
+			break
+		}
+		pos++
+	}
+	if pos > len(line) {
+		pos = len(line)
+	}
+	return line[start:pos], pos
+}
+
+func skipSpaces(line []byte, pos int) int {
+	for pos < len(line) && line[pos] == ' ' {
+		pos++
+	}
+	return pos
+}
+
+// unescape removes the backslashes escaping the given characters from token.
+func unescape(token []byte, escapes string) string {
+	if bytes.IndexByte(token, '\\') < 0 {
+		return string(token)
+	}
+
+	var b strings.Builder
+	b.Grow(len(token))
+	for i := 0; i < len(token); i++ {
+		if token[i] == '\\' && i+1 < len(token) && strings.IndexByte(escapes, token[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(token[i])
+	}
+	return b.String()
+}
+
+// sanitizeName replaces the characters which aren't valid in a Prometheus metric name (if metricName is true) or
+// label name with underscores, and prefixes the names starting with a digit with an underscore.
+func sanitizeName(name string, metricName bool) string {
+	valid := func(c byte) bool {
+		return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || (metricName && c == ':')
+	}
+
+	startsWithDigit := len(name) > 0 && name[0] >= '0' && name[0] <= '9'
+	if !startsWithDigit && strings.IndexFunc(name, func(r rune) bool { return r >= utf8.RuneSelf || !valid(byte(r)) }) < 0 {
+		return name
+	}
+
+	var b strings.Builder
+	b.Grow(len(name) + 1)
+	if startsWithDigit {
+		b.WriteByte('_')
+	}
+	for _, r := range name {
+		if r < utf8.RuneSelf && valid(byte(r)) {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package influxpush
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	nowMs := now.UnixMilli()
+
+	tests := map[string]struct {
+		data        string
+		precision   time.Duration
+		expected    []mimirpb.TimeSeries
+		expectedErr string
+	}{
+		"single field without tags and timestamp": {
+			data:      "cpu value=1.5",
+			precision: time.Nanosecond,
+			expected: []mimirpb.TimeSeries{
+				series(1.5, nowMs, "__name__", "cpu_value"),
+			},
+		},
+		"multiple fields with sorted tags and timestamp": {
+			data:      "cpu,region=eu,host=a usage=10i,idle=90u 1700000001000000000",
+			precision: time.Nanosecond,
+			expected: []mimirpb.TimeSeries{
+				series(10, 1700000001000, "__name__", "cpu_usage", "host", "a", "region", "eu"),
+				series(90, 1700000001000, "__name__", "cpu_idle", "host", "a", "region", "eu"),
+			},
+		},
+		"boolean fields are converted to numbers and string fields are skipped": {
+			data:      `disk,dev=sda healthy=t,model="WD \"Blue\", 1TB",failed=false 1700000001`,
+			precision: time.Second,
+			expected: []mimirpb.TimeSeries{
+				series(1, 1700000001000, "__name__", "disk_healthy", "dev", "sda"),
+				series(0, 1700000001000, "__name__", "disk_failed", "dev", "sda"),
+			},
+		},
+		"escaped characters": {
+			data:      `my\ measurement,tag\,key=tag\ value,eq\=k=v\=1 field\=key=2 1700000001000`,
+			precision: time.Millisecond,
+			expected: []mimirpb.TimeSeries{
+				series(2, 1700000001000, "__name__", "my_measurement_field_key", "eq_k", "v=1", "tag_key", "tag value"),
+			},
+		},
+		"invalid names are sanitized and the __name__ tag is dropped": {
+			data:      "1net.io,__name__=x,if-name=eth0 rx.bytes=3 1700000001000000",
+			precision: time.Microsecond,
+			expected: []mimirpb.TimeSeries{
+				series(3, 1700000001000, "__name__", "_1net_io_rx_bytes", "if_name", "eth0"),
+			},
+		},
+		"multiple lines, blank lines and comments": {
+			data:      "# a comment\n\ncpu value=1 1\r\n  mem value=2 2\n",
+			precision: time.Minute,
+			expected: []mimirpb.TimeSeries{
+				series(1, time.Minute.Milliseconds(), "__name__", "cpu_value"),
+				series(2, 2*time.Minute.Milliseconds(), "__name__", "mem_value"),
+			},
+		},
+		"missing fields": {
+			data:        "cpu,host=a",
+			precision:   time.Nanosecond,
+			expectedErr: "unable to parse line 1: missing fields",
+		},
+		"missing tag value": {
+			data:        "cpu,host= value=1",
+			precision:   time.Nanosecond,
+			expectedErr: "unable to parse line 1: missing tag value",
+		},
+		"repeated tag": {
+			data:        "cpu,host=a,host=b value=1",
+			precision:   time.Nanosecond,
+			expectedErr: `unable to parse line 1: duplicate tag "host"`,
+		},
+		"tags colliding once sanitized": {
+			data:        "cpu,a.b=x,a_b=y value=1",
+			precision:   time.Nanosecond,
+			expectedErr: `unable to parse line 1: duplicate tag "a_b"`,
+		},
+		"repeated field": {
+			data:        "cpu value=1,value=2",
+			precision:   time.Nanosecond,
+			expectedErr: `unable to parse line 1: duplicate field "value"`,
+		},
+		"fields colliding once sanitized": {
+			data:        "cpu a.b=1,a_b=2",
+			precision:   time.Nanosecond,
+			expectedErr: `unable to parse line 1: duplicate field "a_b"`,
+		},
+		"invalid field value": {
+			data:        "cpu value=1\ncpu value=abc",
+			precision:   time.Nanosecond,
+			expectedErr: `unable to parse line 2: invalid value of field "value"`,
+		},
+		"NaN field value": {
+			data:        "cpu value=NaN",
+			precision:   time.Nanosecond,
+			expectedErr: `invalid number "NaN"`,
+		},
+		"unterminated string field value": {
+			data:        `cpu value="abc`,
+			precision:   time.Nanosecond,
+			expectedErr: "unterminated string field value",
+		},
+		"timestamp overflowing once converted to milliseconds": {
+			data:        "cpu value=1 9223372036854775807",
+			precision:   time.Hour,
+			expectedErr: `unable to parse line 1: timestamp 9223372036854775807 out of range`,
+		},
+		"negative timestamp overflowing once converted to milliseconds": {
+			data:        "cpu value=1 -9223372036854775807",
+			precision:   time.Minute,
+			expectedErr: `unable to parse line 1: timestamp -9223372036854775807 out of range`,
+		},
+		"invalid timestamp": {
+			data:        "cpu value=1 abc",
+			precision:   time.Nanosecond,
+			expectedErr: `invalid timestamp "abc"`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := Parse([]byte(tc.data), tc.precision, now)
+			if tc.expectedErr != "" {
+				require.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			actualSeries := make([]mimirpb.TimeSeries, 0, len(actual))
+			for _, ts := range actual {
+				actualSeries = append(actualSeries, mimirpb.TimeSeries{Labels: ts.Labels, Samples: ts.Samples})
+			}
+			assert.Equal(t, tc.expected, actualSeries)
+		})
+	}
+}
+
+func TestParsePrecision(t *testing.T) {
+	for precision, expected := range map[string]time.Duration{
+		"":   time.Nanosecond,
+		"ns": time.Nanosecond,
+		"n":  time.Nanosecond,
+		"us": time.Microsecond,
+		"u":  time.Microsecond,
+		"µs": time.Microsecond,
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"m":  time.Minute,
+		"h":  time.Hour,
+	} {
+		actual, err := ParsePrecision(precision)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual, precision)
+	}
+
+	_, err := ParsePrecision("d")
+	require.EqualError(t, err, `invalid precision "d", supported: ns, us, ms, s, m, h`)
+}
+
+func series(value float64, timestampMs int64, labels ...string) mimirpb.TimeSeries {
+	ts := mimirpb.TimeSeries{
+		Samples: []mimirpb.Sample{{Value: value, TimestampMs: timestampMs}},
+	}
+	for i := 0; i < len(labels); i += 2 {
+		ts.Labels = append(ts.Labels, mimirpb.LabelAdapter{Name: labels[i], Value: labels[i+1]})
+	}
+	return ts
+}
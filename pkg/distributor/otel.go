@@ -47,8 +47,12 @@ const (
 	maxErrMsgLen   = 1024
 )
 
+// otlpParserFunc parses the OTLP HTTP request into req, setting the partial success of the conversion.
+type otlpParserFunc func(ctx context.Context, r *http.Request, maxSize int, buffers *util.RequestBuffers, req *mimirpb.PreallocWriteRequest, partialSuccess pmetricotlp.ExportPartialSuccess, logger log.Logger) error
+
 type OTLPHandlerLimits interface {
 	OTelMetricSuffixesEnabled(id string) bool
+	PromoteOTelResourceAttributes(id string) []string
 }
 
 // OTLPHandler is an http.Handler accepting OTLP write requests.
@@ -65,9 +69,15 @@ func OTLPHandler(
 	logger log.Logger,
 	directTranslation bool,
 ) http.Handler {
-	discardedDueToOtelParseError := validation.DiscardedSamplesCounter(reg, otelParseError)
+	converter := otlpConverter{
+		enableOtelMetadataStorage:    enableOtelMetadataStorage,
+		limits:                       limits,
+		pushMetrics:                  pushMetrics,
+		discardedDueToOtelParseError: discardedDueToOtelParseErrorCounter(reg),
+		directTranslation:            directTranslation,
+	}
 
-	return otlpHandler(maxRecvMsgSize, requestBufferPool, sourceIPs, retryCfg, push, logger, func(ctx context.Context, r *http.Request, maxRecvMsgSize int, buffers *util.RequestBuffers, req *mimirpb.PreallocWriteRequest, logger log.Logger) error {
+	return otlpHandler(maxRecvMsgSize, requestBufferPool, sourceIPs, retryCfg, push, logger, func(ctx context.Context, r *http.Request, maxRecvMsgSize int, buffers *util.RequestBuffers, req *mimirpb.PreallocWriteRequest, partialSuccess pmetricotlp.ExportPartialSuccess, logger log.Logger) error {
 		contentType := r.Header.Get("Content-Type")
 		contentEncoding := r.Header.Get("Content-Encoding")
 		var compression util.CompressionType
@@ -158,56 +168,111 @@ func OTLPHandler(
 
 		level.Debug(spanLogger).Log("msg", "decoding complete, starting conversion")
 
-		tenantID, err := tenant.TenantID(ctx)
+		converted, err := converter.convert(ctx, otlpReq, uncompressedBodySize, req, spanLogger)
 		if err != nil {
 			return err
 		}
-		addSuffixes := limits.OTelMetricSuffixesEnabled(tenantID)
+		converted.CopyTo(partialSuccess)
+		return nil
+	})
+}
 
-		pushMetrics.IncOTLPRequest(tenantID)
-		pushMetrics.ObserveUncompressedBodySize(tenantID, float64(uncompressedBodySize))
+// otlpConverter converts OTLP metrics export requests to write requests. It's shared by the OTLP HTTP handler
+// and gRPC server.
+type otlpConverter struct {
+	enableOtelMetadataStorage    bool
+	limits                       OTLPHandlerLimits
+	pushMetrics                  *PushMetrics
+	discardedDueToOtelParseError *prometheus.CounterVec
+	directTranslation            bool
+}
 
-		var metrics []mimirpb.PreallocTimeseries
-		if directTranslation {
-			metrics, err = otelMetricsToTimeseries(ctx, tenantID, addSuffixes, discardedDueToOtelParseError, logger, otlpReq.Metrics())
-			if err != nil {
-				return err
-			}
-		} else {
-			metrics, err = otelMetricsToTimeseriesOld(ctx, tenantID, addSuffixes, discardedDueToOtelParseError, logger, otlpReq.Metrics())
-			if err != nil {
-				return err
-			}
+// discardedDueToOtelParseErrorCounter returns the counter of the samples discarded because of OTLP parse errors,
+// which is shared by the OTLP HTTP handler and gRPC server registered to the same registerer.
+func discardedDueToOtelParseErrorCounter(reg prometheus.Registerer) *prometheus.CounterVec {
+	counter := validation.DiscardedSamplesCounter(nil, otelParseError)
+	if reg == nil {
+		return counter
+	}
+	if err := reg.Register(counter); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if errors.As(err, &alreadyRegistered) {
+			return alreadyRegistered.ExistingCollector.(*prometheus.CounterVec)
 		}
+		panic(err)
+	}
+	return counter
+}
 
-		metricCount := len(metrics)
-		sampleCount := 0
-		histogramCount := 0
-		exemplarCount := 0
+// convert translates otlpReq into req. The OTLP metrics that couldn't be translated are dropped, and returned
+// as a partial success: the request fails only if none of its metrics can be translated.
+func (c otlpConverter) convert(ctx context.Context, otlpReq pmetricotlp.ExportRequest, uncompressedBodySize int, req *mimirpb.PreallocWriteRequest, logger log.Logger) (pmetricotlp.ExportPartialSuccess, error) {
+	partialSuccess := pmetricotlp.NewExportPartialSuccess()
 
-		for _, m := range metrics {
-			sampleCount += len(m.Samples)
-			histogramCount += len(m.Histograms)
-			exemplarCount += len(m.Exemplars)
+	tenantID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return partialSuccess, err
+	}
+	addSuffixes := c.limits.OTelMetricSuffixesEnabled(tenantID)
+	promoteResourceAttributes := c.limits.PromoteOTelResourceAttributes(tenantID)
+
+	c.pushMetrics.IncOTLPRequest(tenantID)
+	c.pushMetrics.ObserveUncompressedBodySize(tenantID, float64(uncompressedBodySize))
+
+	var (
+		metrics   []mimirpb.PreallocTimeseries
+		parseErrs error
+	)
+	if c.directTranslation {
+		metrics, parseErrs = otelMetricsToTimeseries(ctx, addSuffixes, promoteResourceAttributes, otlpReq.Metrics())
+	} else {
+		metrics, parseErrs = otelMetricsToTimeseriesOld(ctx, addSuffixes, promoteResourceAttributes, otlpReq.Metrics())
+	}
+	if parseErrs != nil {
+		dropped := len(multierr.Errors(parseErrs))
+		c.discardedDueToOtelParseError.WithLabelValues(tenantID, "").Add(float64(dropped)) // Group is empty here as metrics couldn't be parsed
+
+		parseErrsMsg := parseErrs.Error()
+		if len(parseErrsMsg) > maxErrMsgLen {
+			parseErrsMsg = parseErrsMsg[:maxErrMsgLen]
 		}
 
-		level.Debug(spanLogger).Log(
-			"msg", "OTLP to Prometheus conversion complete",
-			"metric_count", metricCount,
-			"sample_count", sampleCount,
-			"histogram_count", histogramCount,
-			"exemplar_count", exemplarCount,
-		)
+		if len(metrics) == 0 {
+			return partialSuccess, errors.New(parseErrsMsg)
+		}
 
-		req.Timeseries = metrics
+		level.Warn(logger).Log("msg", "OTLP parse error", "err", parseErrsMsg)
+		partialSuccess.SetRejectedDataPoints(int64(dropped))
+		partialSuccess.SetErrorMessage(parseErrsMsg)
+	}
 
-		if enableOtelMetadataStorage {
-			metadata := otelMetricsToMetadata(addSuffixes, otlpReq.Metrics())
-			req.Metadata = metadata
-		}
+	metricCount := len(metrics)
+	sampleCount := 0
+	histogramCount := 0
+	exemplarCount := 0
 
-		return nil
-	})
+	for _, m := range metrics {
+		sampleCount += len(m.Samples)
+		histogramCount += len(m.Histograms)
+		exemplarCount += len(m.Exemplars)
+	}
+
+	level.Debug(logger).Log(
+		"msg", "OTLP to Prometheus conversion complete",
+		"metric_count", metricCount,
+		"sample_count", sampleCount,
+		"histogram_count", histogramCount,
+		"exemplar_count", exemplarCount,
+	)
+
+	req.Timeseries = metrics
+
+	if c.enableOtelMetadataStorage {
+		metadata := otelMetricsToMetadata(addSuffixes, otlpReq.Metrics())
+		req.Metadata = metadata
+	}
+
+	return partialSuccess, nil
 }
 
 func otlpHandler(
@@ -217,7 +282,7 @@ func otlpHandler(
 	retryCfg RetryConfig,
 	push PushFunc,
 	logger log.Logger,
-	parser parserFunc,
+	parser otlpParserFunc,
 ) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -228,10 +293,11 @@ func otlpHandler(
 				logger = utillog.WithSourceIPs(source, logger)
 			}
 		}
+		partialSuccess := pmetricotlp.NewExportPartialSuccess()
 		supplier := func() (*mimirpb.WriteRequest, func(), error) {
 			rb := util.NewRequestBuffers(requestBufferPool)
 			var req mimirpb.PreallocWriteRequest
-			if err := parser(ctx, r, maxRecvMsgSize, rb, &req, logger); err != nil {
+			if err := parser(ctx, r, maxRecvMsgSize, rb, &req, partialSuccess, logger); err != nil {
 				// Check for httpgrpc error, default to client error if parsing failed
 				if _, ok := httpgrpc.HTTPResponseFromError(err); !ok {
 					err = httpgrpc.Errorf(http.StatusBadRequest, err.Error())
@@ -280,6 +346,11 @@ func otlpHandler(
 			}
 			addHeaders(w, err, r, httpCode, retryCfg)
 			writeErrorToHTTPResponseBody(r.Context(), w, httpCode, grpcCode, errorMsg, logger)
+			return
+		}
+
+		if partialSuccess.RejectedDataPoints() > 0 {
+			writePartialSuccessToHTTPResponseBody(w, r.Header.Get("Content-Type"), partialSuccess, logger)
 		}
 	})
 }
@@ -338,6 +409,35 @@ func writeErrorToHTTPResponseBody(reqCtx context.Context, w http.ResponseWriter,
 	}
 }
 
+// writePartialSuccessToHTTPResponseBody writes the partial success of the request to the response body,
+// encoded with the same content type as the request.
+// See doc https://opentelemetry.io/docs/specs/otlp/#partial-success-1
+func writePartialSuccessToHTTPResponseBody(w http.ResponseWriter, contentType string, partialSuccess pmetricotlp.ExportPartialSuccess, logger log.Logger) {
+	resp := pmetricotlp.NewExportResponse()
+	partialSuccess.CopyTo(resp.PartialSuccess())
+
+	var (
+		respBytes []byte
+		err       error
+	)
+	if contentType == jsonContentType {
+		respBytes, err = resp.MarshalJSON()
+	} else {
+		contentType = pbContentType
+		respBytes, err = resp.MarshalProto()
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "otlp response marshal failed", "err", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(respBytes); err != nil {
+		level.Error(logger).Log("msg", "write partial success to otlp response failed", "err", err)
+	}
+}
+
 // otlpProtoUnmarshaler implements proto.Message wrapping pmetricotlp.ExportRequest.
 type otlpProtoUnmarshaler struct {
 	request *pmetricotlp.ExportRequest
@@ -407,60 +507,30 @@ func otelMetricsToMetadata(addSuffixes bool, md pmetric.Metrics) []*mimirpb.Metr
 	return metadata
 }
 
-func otelMetricsToTimeseries(ctx context.Context, tenantID string, addSuffixes bool, discardedDueToOtelParseError *prometheus.CounterVec, logger log.Logger, md pmetric.Metrics) ([]mimirpb.PreallocTimeseries, error) {
+func otelMetricsToTimeseries(ctx context.Context, addSuffixes bool, promoteResourceAttributes []string, md pmetric.Metrics) ([]mimirpb.PreallocTimeseries, error) {
 	converter := otlp.NewMimirConverter()
 	errs := converter.FromMetrics(ctx, md, otlp.Settings{
-		AddMetricSuffixes: addSuffixes,
+		AddMetricSuffixes:         addSuffixes,
+		PromoteResourceAttributes: promoteResourceAttributes,
 	})
-	mimirTS := converter.TimeSeries()
-	if errs != nil {
-		dropped := len(multierr.Errors(errs))
-		discardedDueToOtelParseError.WithLabelValues(tenantID, "").Add(float64(dropped)) // Group is empty here as metrics couldn't be parsed
-
-		parseErrs := errs.Error()
-		if len(parseErrs) > maxErrMsgLen {
-			parseErrs = parseErrs[:maxErrMsgLen]
-		}
-
-		if len(mimirTS) == 0 {
-			return nil, errors.New(parseErrs)
-		}
-
-		level.Warn(logger).Log("msg", "OTLP parse error", "err", parseErrs)
-	}
-
-	return mimirTS, nil
+	return converter.TimeSeries(), errs
 }
 
 // Old, less efficient, version of otelMetricsToTimeseries.
-func otelMetricsToTimeseriesOld(ctx context.Context, tenantID string, addSuffixes bool, discardedDueToOtelParseError *prometheus.CounterVec, logger log.Logger, md pmetric.Metrics) ([]mimirpb.PreallocTimeseries, error) {
+func otelMetricsToTimeseriesOld(ctx context.Context, addSuffixes bool, promoteResourceAttributes []string, md pmetric.Metrics) ([]mimirpb.PreallocTimeseries, error) {
 	converter := prometheusremotewrite.NewPrometheusConverter()
 	errs := converter.FromMetrics(ctx, md, prometheusremotewrite.Settings{
-		AddMetricSuffixes: addSuffixes,
+		AddMetricSuffixes:         addSuffixes,
+		PromoteResourceAttributes: promoteResourceAttributes,
 	})
 	promTS := converter.TimeSeries()
-	if errs != nil {
-		dropped := len(multierr.Errors(errs))
-		discardedDueToOtelParseError.WithLabelValues(tenantID, "").Add(float64(dropped)) // Group is empty here as metrics couldn't be parsed
-
-		parseErrs := errs.Error()
-		if len(parseErrs) > maxErrMsgLen {
-			parseErrs = parseErrs[:maxErrMsgLen]
-		}
-
-		if len(promTS) == 0 {
-			return nil, errors.New(parseErrs)
-		}
-
-		level.Warn(logger).Log("msg", "OTLP parse error", "err", parseErrs)
-	}
 
 	mimirTS := mimirpb.PreallocTimeseriesSliceFromPool()
 	for _, ts := range promTS {
 		mimirTS = append(mimirTS, promToMimirTimeseries(&ts))
 	}
 
-	return mimirTS, nil
+	return mimirTS, errs
 }
 
 func promToMimirTimeseries(promTs *prompb.TimeSeries) mimirpb.PreallocTimeseries {
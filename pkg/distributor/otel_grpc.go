@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package distributor
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+	"github.com/gogo/status"
+	"github.com/grafana/dskit/grpcutil"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/globalerror"
+	utillog "github.com/grafana/mimir/pkg/util/log"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+)
+
+// retryAttemptGRPCMetadataKey is the gRPC counterpart of the Retry-Attempt HTTP header.
+const retryAttemptGRPCMetadataKey = "retry-attempt"
+
+// OTLPGRPCServer is a pmetricotlp.GRPCServer accepting OTLP metrics export requests over gRPC.
+// Requests are translated, limited and pushed like the ones received by the OTLPHandler.
+type OTLPGRPCServer struct {
+	pmetricotlp.UnimplementedGRPCServer
+
+	maxRecvMsgSize int
+	retryCfg       RetryConfig
+	push           PushFunc
+	converter      otlpConverter
+	logger         log.Logger
+}
+
+// NewOTLPGRPCServer makes a new OTLPGRPCServer.
+func NewOTLPGRPCServer(
+	maxRecvMsgSize int,
+	enableOtelMetadataStorage bool,
+	limits OTLPHandlerLimits,
+	retryCfg RetryConfig,
+	push PushFunc,
+	pushMetrics *PushMetrics,
+	reg prometheus.Registerer,
+	logger log.Logger,
+	directTranslation bool,
+) *OTLPGRPCServer {
+	return &OTLPGRPCServer{
+		maxRecvMsgSize: maxRecvMsgSize,
+		retryCfg:       retryCfg,
+		push:           push,
+		converter: otlpConverter{
+			enableOtelMetadataStorage:    enableOtelMetadataStorage,
+			limits:                       limits,
+			pushMetrics:                  pushMetrics,
+			discardedDueToOtelParseError: discardedDueToOtelParseErrorCounter(reg),
+			directTranslation:            directTranslation,
+		},
+		logger: logger,
+	}
+}
+
+// Export implements pmetricotlp.GRPCServer.
+func (s *OTLPGRPCServer) Export(ctx context.Context, otlpReq pmetricotlp.ExportRequest) (pmetricotlp.ExportResponse, error) {
+	logger := utillog.WithContext(ctx, s.logger)
+
+	spanLogger, ctx := spanlogger.NewWithLogger(ctx, logger, "Distributor.OTLPGRPCServer.Export")
+	defer spanLogger.Span.Finish()
+
+	// The request has already been decoded by the gRPC server, whose own limit on the message size applies,
+	// so its size is the one of the decoded metrics.
+	size := (&pmetric.ProtoMarshaler{}).MetricsSize(otlpReq.Metrics())
+	spanLogger.SetTag("request_size", size)
+	if size > s.maxRecvMsgSize {
+		return pmetricotlp.NewExportResponse(), status.Error(codes.ResourceExhausted, distributorMaxOTLPRequestSizeErr{
+			actual: size,
+			limit:  s.maxRecvMsgSize,
+		}.Error())
+	}
+
+	partialSuccess := pmetricotlp.NewExportPartialSuccess()
+	supplier := func() (*mimirpb.WriteRequest, func(), error) {
+		var (
+			req mimirpb.PreallocWriteRequest
+			err error
+		)
+		partialSuccess, err = s.converter.convert(ctx, otlpReq, size, &req, spanLogger)
+		if err != nil {
+			return nil, nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		cleanup := func() {
+			mimirpb.ReuseSlice(req.Timeseries)
+		}
+		return &req.WriteRequest, cleanup, nil
+	}
+
+	resp := pmetricotlp.NewExportResponse()
+	if err := s.push(ctx, newRequest(supplier)); err != nil {
+		if err = s.toGRPCError(ctx, err, logger); err != nil {
+			return resp, err
+		}
+	}
+
+	partialSuccess.CopyTo(resp.PartialSuccess())
+	return resp, nil
+}
+
+// toGRPCError converts the given push error into a gRPC error, following the same semantics of the errors
+// returned by the OTLPHandler: the errors which are retryable over HTTP are returned with a retryable gRPC
+// status code, as listed in https://opentelemetry.io/docs/specs/otlp/#failures. A nil error is returned for
+// the push errors which are successful responses over HTTP, like the deduplicated HA samples ones.
+func (s *OTLPGRPCServer) toGRPCError(ctx context.Context, pushErr error, logger log.Logger) error {
+	if errors.Is(pushErr, context.Canceled) {
+		level.Warn(logger).Log("msg", "push request canceled", "err", pushErr)
+		return status.Error(codes.Canceled, "push request context canceled")
+	}
+
+	var (
+		httpCode int
+		grpcCode codes.Code
+		errorMsg string
+	)
+	if st, ok := grpcutil.ErrorToStatus(pushErr); ok {
+		if !util.IsHTTPStatusCode(st.Code()) {
+			// The error already carries a gRPC status code, like the ones returned by the translation.
+			return pushErr
+		}
+		// TODO: This code is needed for backwards compatibility,
+		// and can be removed once -ingester.return-only-grpc-errors
+		// is removed.
+		httpCode = httpRetryableToOTLPRetryable(int(st.Code()))
+		grpcCode = codes.InvalidArgument
+		errorMsg = st.Message()
+	} else {
+		grpcCode, httpCode = toOtlpGRPCHTTPStatus(pushErr)
+		errorMsg = pushErr.Error()
+	}
+	if httpCode/100 == 2 {
+		return nil
+	}
+
+	// This error message is consistent with error message in Prometheus remote-write handler, and ingester's ingest-storage pushToStorage method.
+	msgs := []interface{}{"msg", "detected an error while ingesting OTLP metrics request (the request may have been partially ingested)", "httpCode", httpCode, "err", pushErr}
+	if httpCode/100 == 4 {
+		msgs = append(msgs, "insight", true)
+	}
+	level.Error(logger).Log(msgs...)
+
+	retryable := httpCode == http.StatusTooManyRequests || httpCode/100 == 5
+	if retryable {
+		grpcCode = codes.Unavailable
+	}
+
+	stat := status.New(grpcCode, errorMsg)
+	if retryable && s.retryCfg.Enabled {
+		var retryAttempt string
+		if values := metadata.ValueFromIncomingContext(ctx, retryAttemptGRPCMetadataKey); len(values) > 0 {
+			retryAttempt = values[0]
+		}
+		retrySeconds, _ := strconv.Atoi(calculateRetryAfter(retryAttempt, s.retryCfg.BaseSeconds, s.retryCfg.MaxBackoffExponent))
+		if statWithDetails, err := stat.WithDetails(&rpc.RetryInfo{
+			RetryDelay: types.DurationProto(time.Duration(retrySeconds) * time.Second),
+		}); err == nil {
+			stat = statWithDetails
+		}
+	}
+
+	// Keep the original error wrapped, so that the gRPC logging middleware honours its semantics.
+	return globalerror.ErrorWithStatus{
+		UnderlyingErr: pushErr,
+		Status:        stat,
+	}
+}
@@ -0,0 +1,282 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package distributor
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+	"github.com/gogo/status"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/middleware"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+func TestOTLPGRPCServer_Export(t *testing.T) {
+	validMetrics := createOTLPGRPCTestMetrics("foo")
+
+	partiallyValidMetrics := createOTLPGRPCTestMetrics("foo")
+	// A metric without a type can't be translated.
+	partiallyValidMetrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().AppendEmpty().SetName("untyped")
+
+	tests := map[string]struct {
+		metrics    pmetric.Metrics
+		maxMsgSize int
+
+		expectedCode                  codes.Code
+		expectedErr                   string
+		expectedSeries                []string
+		expectedRejectedDataPoints    int64
+		expectedPartialSuccessMessage string
+		expectedMetrics               string
+	}{
+		"should push the translated series": {
+			metrics:        validMetrics,
+			maxMsgSize:     100000,
+			expectedCode:   codes.OK,
+			expectedSeries: []string{"foo"},
+		},
+		"should report the metrics which couldn't be translated as a partial success": {
+			metrics:                       partiallyValidMetrics,
+			maxMsgSize:                    100000,
+			expectedCode:                  codes.OK,
+			expectedSeries:                []string{"foo"},
+			expectedRejectedDataPoints:    1,
+			expectedPartialSuccessMessage: `invalid temporality and type combination for metric "untyped"`,
+			expectedMetrics: `
+				# HELP cortex_discarded_samples_total The total number of samples that were discarded.
+				# TYPE cortex_discarded_samples_total counter
+				cortex_discarded_samples_total{group="",reason="otlp_parse_error",user="test"} 1
+			`,
+		},
+		"should reject the request if none of its metrics can be translated": {
+			metrics: func() pmetric.Metrics {
+				md := pmetric.NewMetrics()
+				md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetName("untyped")
+				return md
+			}(),
+			maxMsgSize:   100000,
+			expectedCode: codes.InvalidArgument,
+			expectedErr:  `invalid temporality and type combination for metric "untyped"`,
+		},
+		"should reject the request if it's larger than the limit": {
+			metrics:      validMetrics,
+			maxMsgSize:   10,
+			expectedCode: codes.ResourceExhausted,
+			expectedErr:  "the incoming OTLP request has been rejected because its message size",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var pushedSeries []string
+			push := func(_ context.Context, pushReq *Request) error {
+				req, err := pushReq.WriteRequest()
+				if err != nil {
+					return err
+				}
+				for _, ts := range req.Timeseries {
+					pushedSeries = append(pushedSeries, mimirpb.FromLabelAdaptersToLabels(ts.Labels).Get("__name__"))
+				}
+				pushReq.CleanUp()
+				return nil
+			}
+
+			reg := prometheus.NewPedanticRegistry()
+			srv := NewOTLPGRPCServer(tc.maxMsgSize, false, otlpLimitsMock{}, RetryConfig{}, push, newPushMetrics(reg), reg, log.NewNopLogger(), true)
+			client := startOTLPGRPCTestServer(t, srv)
+
+			resp, err := client.Export(otlpGRPCTestContext("test"), pmetricotlp.NewExportRequestFromMetrics(tc.metrics))
+			stat, _ := status.FromError(err)
+			require.Equal(t, tc.expectedCode, stat.Code(), err)
+			if tc.expectedCode != codes.OK {
+				assert.Contains(t, stat.Message(), tc.expectedErr)
+				assert.Empty(t, pushedSeries)
+				return
+			}
+
+			assert.Equal(t, tc.expectedSeries, pushedSeries)
+			assert.Equal(t, tc.expectedRejectedDataPoints, resp.PartialSuccess().RejectedDataPoints())
+			assert.Equal(t, tc.expectedPartialSuccessMessage, resp.PartialSuccess().ErrorMessage())
+			if tc.expectedMetrics != "" {
+				assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(tc.expectedMetrics), "cortex_discarded_samples_total"))
+			}
+		})
+	}
+
+	t.Run("should reject requests without a tenant", func(t *testing.T) {
+		srv := NewOTLPGRPCServer(100000, false, otlpLimitsMock{}, RetryConfig{}, readBodyPushFunc(t), nil, nil, log.NewNopLogger(), true)
+		client := startOTLPGRPCTestServer(t, srv)
+
+		_, err := client.Export(context.Background(), pmetricotlp.NewExportRequestFromMetrics(validMetrics))
+		require.Error(t, err)
+	})
+}
+
+func TestOTLPGRPCServer_Export_PushErrors(t *testing.T) {
+	retryCfg := RetryConfig{Enabled: true, BaseSeconds: 3, MaxBackoffExponent: 5}
+
+	tests := map[string]struct {
+		pushErr      error
+		retryCfg     RetryConfig
+		retryAttempt string
+
+		expectedCode       codes.Code
+		expectedMsg        string
+		expectedRetryDelay [2]time.Duration
+	}{
+		"a replicasDidNotMatchError is a successful response": {
+			pushErr:      newReplicasDidNotMatchError("a", "b"),
+			expectedCode: codes.OK,
+		},
+		"a validationError gets translated into gRPC codes.InvalidArgument": {
+			pushErr:      newValidationError(errors.New("invalid series")),
+			expectedCode: codes.InvalidArgument,
+			expectedMsg:  "invalid series",
+		},
+		"a tooManyClustersError gets translated into gRPC codes.FailedPrecondition": {
+			pushErr:      newTooManyClustersError(10),
+			expectedCode: codes.FailedPrecondition,
+			expectedMsg:  newTooManyClustersError(10).Error(),
+		},
+		"an ingestionRateLimitedError gets translated into the retryable gRPC codes.Unavailable": {
+			pushErr:      newIngestionRateLimitedError(10, 10),
+			expectedCode: codes.Unavailable,
+			expectedMsg:  newIngestionRateLimitedError(10, 10).Error(),
+		},
+		"an ingestionRateLimitedError gets translated into gRPC codes.Unavailable with the retry delay when retries are enabled": {
+			pushErr:            newIngestionRateLimitedError(10, 10),
+			retryCfg:           retryCfg,
+			expectedCode:       codes.Unavailable,
+			expectedMsg:        newIngestionRateLimitedError(10, 10).Error(),
+			expectedRetryDelay: [2]time.Duration{3 * time.Second, 6 * time.Second},
+		},
+		"the retry delay grows with the retry attempt sent in the request metadata": {
+			pushErr:            newIngestionRateLimitedError(10, 10),
+			retryCfg:           retryCfg,
+			retryAttempt:       "3",
+			expectedCode:       codes.Unavailable,
+			expectedMsg:        newIngestionRateLimitedError(10, 10).Error(),
+			expectedRetryDelay: [2]time.Duration{12 * time.Second, 24 * time.Second},
+		},
+		"a generic error gets translated into the retryable gRPC codes.Unavailable": {
+			pushErr:      errors.New("failed pushing to ingesters"),
+			expectedCode: codes.Unavailable,
+			expectedMsg:  "failed pushing to ingesters",
+		},
+		"an httpgrpc 4xx error gets translated into gRPC codes.InvalidArgument": {
+			pushErr:      httpgrpc.Errorf(400, "bad request"),
+			expectedCode: codes.InvalidArgument,
+			expectedMsg:  "bad request",
+		},
+		"an httpgrpc 5xx error gets translated into the retryable gRPC codes.Unavailable": {
+			pushErr:      httpgrpc.Errorf(500, "internal error"),
+			expectedCode: codes.Unavailable,
+			expectedMsg:  "internal error",
+		},
+		"a context.Canceled gets translated into gRPC codes.Canceled": {
+			pushErr:      context.Canceled,
+			expectedCode: codes.Canceled,
+			expectedMsg:  "push request context canceled",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			push := func(_ context.Context, pushReq *Request) error {
+				_, err := pushReq.WriteRequest()
+				require.NoError(t, err)
+				pushReq.CleanUp()
+				return tc.pushErr
+			}
+
+			srv := NewOTLPGRPCServer(100000, false, otlpLimitsMock{}, tc.retryCfg, push, nil, nil, log.NewNopLogger(), true)
+			client := startOTLPGRPCTestServer(t, srv)
+
+			ctx := otlpGRPCTestContext("test")
+			if tc.retryAttempt != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, retryAttemptGRPCMetadataKey, tc.retryAttempt)
+			}
+
+			_, err := client.Export(ctx, pmetricotlp.NewExportRequestFromMetrics(createOTLPGRPCTestMetrics("foo")))
+			stat, _ := status.FromError(err)
+			require.Equal(t, tc.expectedCode, stat.Code(), err)
+			if tc.expectedCode == codes.OK {
+				return
+			}
+			assert.Contains(t, stat.Message(), tc.expectedMsg)
+
+			var retryInfo *rpc.RetryInfo
+			for _, detail := range stat.Details() {
+				if info, ok := detail.(*rpc.RetryInfo); ok {
+					retryInfo = info
+				}
+			}
+			if tc.expectedRetryDelay == [2]time.Duration{} {
+				assert.Nil(t, retryInfo)
+				return
+			}
+			require.NotNil(t, retryInfo)
+			retryDelay, err := types.DurationFromProto(retryInfo.RetryDelay)
+			require.NoError(t, err)
+			assert.GreaterOrEqual(t, retryDelay, tc.expectedRetryDelay[0])
+			assert.Less(t, retryDelay, tc.expectedRetryDelay[1])
+		})
+	}
+}
+
+// startOTLPGRPCTestServer starts a gRPC server, authenticating the requests with the tenant in their metadata,
+// serving the given OTLPGRPCServer, and returns a client connected to it.
+func startOTLPGRPCTestServer(t *testing.T, srv *OTLPGRPCServer) pmetricotlp.GRPCClient {
+	serv := grpc.NewServer(grpc.UnaryInterceptor(middleware.ServerUserHeaderInterceptor))
+	t.Cleanup(serv.GracefulStop)
+	pmetricotlp.RegisterGRPCServer(serv, srv)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	go func() {
+		_ = serv.Serve(listener)
+	}()
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return pmetricotlp.NewGRPCClient(conn)
+}
+
+// otlpGRPCTestContext returns a context sending the tenant as gRPC metadata, like an OpenTelemetry Collector
+// configured with the X-Scope-OrgID header does.
+func otlpGRPCTestContext(tenantID string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "X-Scope-OrgID", tenantID)
+}
+
+func createOTLPGRPCTestMetrics(name string) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	metric := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName(name)
+	dp := metric.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.SetDoubleValue(1)
+	dp.SetTimestamp(pcommon.NewTimestampFromTime(time.Now()))
+	return md
+}
@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -20,7 +21,10 @@ import (
 	"github.com/grafana/dskit/middleware"
 	"github.com/grafana/dskit/user"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/prompb"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -558,6 +562,134 @@ func TestHandler_otlpDroppedMetricsPanic2(t *testing.T) {
 	assert.Equal(t, 200, resp.Code)
 }
 
+func TestHandler_otlpPromoteResourceAttributes(t *testing.T) {
+	md := pmetric.NewMetrics()
+	resource := md.ResourceMetrics().AppendEmpty()
+	resource.Resource().Attributes().PutStr("service.name", "service")
+	resource.Resource().Attributes().PutStr("service.instance.id", "instance-1")
+	resource.Resource().Attributes().PutStr("k8s.namespace.name", "namespace")
+	resource.Resource().Attributes().PutStr("deployment.environment", "production")
+	resource.Resource().Attributes().PutStr("cloud.region", "us-central1")
+
+	metric := resource.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName("foo")
+	datapoint := metric.SetEmptyGauge().DataPoints().AppendEmpty()
+	datapoint.SetTimestamp(pcommon.NewTimestampFromTime(time.Now()))
+	datapoint.SetDoubleValue(1)
+	// The labels of the series take precedence over the promoted resource attributes.
+	datapoint.Attributes().PutStr("deployment.environment", "staging")
+
+	limits, err := validation.NewOverrides(
+		validation.Limits{},
+		validation.NewMockTenantLimits(map[string]*validation.Limits{
+			"test": {PromoteOTelResourceAttributes: []string{"k8s.namespace.name", "deployment.environment", "missing.attribute"}},
+		}),
+	)
+	require.NoError(t, err)
+
+	for _, directTranslation := range []bool{true, false} {
+		t.Run(fmt.Sprintf("direct translation: %t", directTranslation), func(t *testing.T) {
+			var series []labels.Labels
+			handler := OTLPHandler(100000, nil, nil, false, limits, RetryConfig{}, func(_ context.Context, pushReq *Request) error {
+				request, err := pushReq.WriteRequest()
+				require.NoError(t, err)
+				for _, ts := range request.Timeseries {
+					series = append(series, mimirpb.FromLabelAdaptersToLabels(ts.Labels).Copy())
+				}
+				pushReq.CleanUp()
+				return nil
+			}, nil, nil, log.NewNopLogger(), directTranslation)
+
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, createOTLPProtoRequest(t, pmetricotlp.NewExportRequestFromMetrics(md), false))
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			// The resource attributes are still exported as target_info, which doesn't get the promoted attributes.
+			assert.ElementsMatch(t, []labels.Labels{
+				labels.FromStrings(
+					model.MetricNameLabel, "foo",
+					"deployment_environment", "staging",
+					"instance", "instance-1",
+					"job", "service",
+					"k8s_namespace_name", "namespace",
+				),
+				labels.FromStrings(
+					model.MetricNameLabel, "target_info",
+					"cloud_region", "us-central1",
+					"deployment_environment", "production",
+					"instance", "instance-1",
+					"job", "service",
+					"k8s_namespace_name", "namespace",
+				),
+			}, series)
+		})
+	}
+}
+
+func TestHandler_otlpPartialSuccess(t *testing.T) {
+	md := createOTLPGRPCTestMetrics("foo")
+	// A metric without a type can't be translated.
+	md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().AppendEmpty().SetName("untyped")
+	exportReq := pmetricotlp.NewExportRequestFromMetrics(md)
+
+	tests := map[string]struct {
+		req       *http.Request
+		unmarshal func(resp pmetricotlp.ExportResponse, data []byte) error
+	}{
+		"protobuf": {
+			req: createOTLPProtoRequest(t, exportReq, false),
+			unmarshal: func(resp pmetricotlp.ExportResponse, data []byte) error {
+				return resp.UnmarshalProto(data)
+			},
+		},
+		"JSON": {
+			req: createOTLPJSONRequest(t, exportReq, false),
+			unmarshal: func(resp pmetricotlp.ExportResponse, data []byte) error {
+				return resp.UnmarshalJSON(data)
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var pushedSeries []string
+			push := func(_ context.Context, pushReq *Request) error {
+				req, err := pushReq.WriteRequest()
+				if err != nil {
+					return err
+				}
+				for _, ts := range req.Timeseries {
+					pushedSeries = append(pushedSeries, mimirpb.FromLabelAdaptersToLabels(ts.Labels).Get(model.MetricNameLabel))
+				}
+				pushReq.CleanUp()
+				return nil
+			}
+
+			reg := prometheus.NewPedanticRegistry()
+			handler := OTLPHandler(100000, nil, nil, false, otlpLimitsMock{}, RetryConfig{}, push, newPushMetrics(reg), reg, log.NewNopLogger(), true)
+			// The gRPC server registered to the same registerer shares the discarded samples counter.
+			NewOTLPGRPCServer(100000, false, otlpLimitsMock{}, RetryConfig{}, push, nil, reg, log.NewNopLogger(), true)
+
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, tc.req)
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, tc.req.Header.Get("Content-Type"), resp.Header().Get("Content-Type"))
+			assert.Equal(t, []string{"foo"}, pushedSeries)
+
+			exportResp := pmetricotlp.NewExportResponse()
+			require.NoError(t, tc.unmarshal(exportResp, resp.Body.Bytes()))
+			assert.Equal(t, int64(1), exportResp.PartialSuccess().RejectedDataPoints())
+			assert.Equal(t, `invalid temporality and type combination for metric "untyped"`, exportResp.PartialSuccess().ErrorMessage())
+
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+				# HELP cortex_discarded_samples_total The total number of samples that were discarded.
+				# TYPE cortex_discarded_samples_total counter
+				cortex_discarded_samples_total{group="",reason="otlp_parse_error",user="test"} 1
+			`), "cortex_discarded_samples_total"))
+		})
+	}
+}
+
 func TestHandler_otlpWriteRequestTooBigWithCompression(t *testing.T) {
 	// createOTLPProtoRequest will create a request which is BIGGER with compression (37 vs 58 bytes).
 	// Hence creating a dummy request.
@@ -11,16 +11,20 @@ import (
 	"flag"
 	"fmt"
 	"math/rand"
+	"mime"
 	"net/http"
 	"strconv"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/gogo/protobuf/proto"
 	"github.com/grafana/dskit/httpgrpc"
 	"github.com/grafana/dskit/httpgrpc/server"
 	"github.com/grafana/dskit/middleware"
 	"github.com/grafana/dskit/tenant"
 	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/storage/remote"
 
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/util"
@@ -43,8 +47,16 @@ var (
 const (
 	SkipLabelNameValidationHeader = "X-Mimir-SkipLabelNameValidation"
 	statusClientClosedRequest     = 499
+
+	remoteWriteMediaType = "application/x-protobuf"
 )
 
+// remoteWriteVersions are the remote-write protocol versions of the supported protobuf messages.
+var remoteWriteVersions = map[config.RemoteWriteProtoMsg]string{
+	config.RemoteWriteProtoMsgV1: "1.0",
+	config.RemoteWriteProtoMsgV2: "2.0",
+}
+
 type RetryConfig struct {
 	Enabled            bool `yaml:"enabled" category:"experimental"`
 	BaseSeconds        int  `yaml:"base_seconds" category:"experimental"`
@@ -68,6 +80,22 @@ func (cfg *RetryConfig) Validate() error {
 	return nil
 }
 
+type writtenStatsContextKey int
+
+const writtenStatsKey writtenStatsContextKey = 0
+
+// contextWithWrittenStats returns a context in which the push middlewares track the number of samples, histograms
+// and exemplars written by the push request into stats.
+func contextWithWrittenStats(ctx context.Context, stats *remote.WriteResponseStats) context.Context {
+	return context.WithValue(ctx, writtenStatsKey, stats)
+}
+
+// writtenStatsFromContext returns the written stats tracked in the context, or nil if they're not tracked.
+func writtenStatsFromContext(ctx context.Context) *remote.WriteResponseStats {
+	stats, _ := ctx.Value(writtenStatsKey).(*remote.WriteResponseStats)
+	return stats
+}
+
 // Handler is a http.Handler which accepts WriteRequests.
 func Handler(
 	maxRecvMsgSize int,
@@ -80,8 +108,23 @@ func Handler(
 	pushMetrics *PushMetrics,
 	logger log.Logger,
 ) http.Handler {
-	return handler(maxRecvMsgSize, requestBufferPool, sourceIPs, allowSkipLabelNameValidation, limits, retryCfg, push, logger, func(ctx context.Context, r *http.Request, maxRecvMsgSize int, buffers *util.RequestBuffers, req *mimirpb.PreallocWriteRequest, _ log.Logger) error {
-		protoBodySize, err := util.ParseProtoReader(ctx, r.Body, int(r.ContentLength), maxRecvMsgSize, buffers, req, util.RawSnappy)
+	h := handler(maxRecvMsgSize, requestBufferPool, sourceIPs, allowSkipLabelNameValidation, limits, retryCfg, push, logger, func(ctx context.Context, r *http.Request, maxRecvMsgSize int, buffers *util.RequestBuffers, req *mimirpb.PreallocWriteRequest, _ log.Logger) error {
+		protoMsg, err := remoteWriteProtoMsg(r)
+		if err != nil {
+			return httpgrpc.Errorf(http.StatusUnsupportedMediaType, err.Error())
+		}
+
+		// Remote-write 2.0 requests are decoded straight into the remote-write 1.0 request used internally.
+		var (
+			msg    proto.Message = req
+			rw2Req *mimirpb.PreallocWriteRequestRW2
+		)
+		if protoMsg == config.RemoteWriteProtoMsgV2 {
+			rw2Req = &mimirpb.PreallocWriteRequestRW2{PreallocWriteRequest: req}
+			msg = rw2Req
+		}
+
+		protoBodySize, err := util.ParseProtoReader(ctx, r.Body, int(r.ContentLength), maxRecvMsgSize, buffers, msg, util.RawSnappy)
 		if errors.Is(err, util.MsgSizeTooLargeErr{}) {
 			err = distributorMaxWriteMessageSizeErr{actual: int(r.ContentLength), limit: maxRecvMsgSize}
 		}
@@ -93,9 +136,66 @@ func Handler(
 			return err
 		}
 		pushMetrics.ObserveUncompressedBodySize(tenantID, float64(protoBodySize))
+		pushMetrics.IncRemoteWriteRequest(tenantID, remoteWriteVersions[protoMsg])
+		if rw2Req != nil {
+			pushMetrics.AddRemoteWriteIgnoredCreatedTimestamps(tenantID, rw2Req.IgnoredCreatedTimestamps)
+		}
 
 		return nil
 	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if protoMsg, err := remoteWriteProtoMsg(r); err != nil || protoMsg != config.RemoteWriteProtoMsgV2 {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// Remote-write 2.0 clients expect the number of written samples, histograms and exemplars in the response.
+		stats := &remote.WriteResponseStats{}
+		rw := &trackingResponseWriter{ResponseWriter: w}
+		h.ServeHTTP(rw, r.WithContext(contextWithWrittenStats(r.Context(), stats)))
+
+		if !rw.wroteHeader {
+			stats.SetHeaders(w)
+		}
+	})
+}
+
+// trackingResponseWriter tracks whether the response of a write request has been written.
+type trackingResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *trackingResponseWriter) WriteHeader(statusCode int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *trackingResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// remoteWriteProtoMsg returns the remote-write protobuf message of the request, negotiated through its
+// Content-Type header. Requests without a protobuf message parameter, or without a valid Content-Type,
+// are remote-write 1.0 requests, as they were before the negotiation was introduced.
+func remoteWriteProtoMsg(r *http.Request) (config.RemoteWriteProtoMsg, error) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return config.RemoteWriteProtoMsgV1, nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != remoteWriteMediaType || params["proto"] == "" {
+		return config.RemoteWriteProtoMsgV1, nil
+	}
+
+	protoMsg := config.RemoteWriteProtoMsg(params["proto"])
+	if _, ok := remoteWriteVersions[protoMsg]; !ok {
+		return "", fmt.Errorf("unsupported remote-write protobuf message %q, supported: %s", protoMsg, config.RemoteWriteProtoMsgs{config.RemoteWriteProtoMsgV1, config.RemoteWriteProtoMsgV2}.String())
+	}
+	return protoMsg, nil
 }
 
 type distributorMaxWriteMessageSizeErr struct {
@@ -32,7 +32,10 @@ import (
 	"github.com/grafana/dskit/user"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/prompb"
+	writev2 "github.com/prometheus/prometheus/prompb/io/prometheus/write/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/pdata/pmetric"
@@ -54,6 +57,97 @@ func TestHandler_remoteWrite(t *testing.T) {
 	assert.Equal(t, 200, resp.Code)
 }
 
+func TestHandler_remoteWriteProtocolNegotiation(t *testing.T) {
+	tests := map[string]struct {
+		contentType     string
+		body            []byte
+		expectedCode    int
+		expectedVersion string
+		expectedHeaders map[string]string
+	}{
+		"no content type": {
+			body:            createPrometheusRemoteWriteProtobuf(t),
+			expectedCode:    http.StatusOK,
+			expectedVersion: "1.0",
+		},
+		"remote-write 1.0 without protobuf message": {
+			contentType:     "application/x-protobuf",
+			body:            createPrometheusRemoteWriteProtobuf(t),
+			expectedCode:    http.StatusOK,
+			expectedVersion: "1.0",
+		},
+		"remote-write 1.0": {
+			contentType:     "application/x-protobuf;proto=prometheus.WriteRequest",
+			body:            createPrometheusRemoteWriteProtobuf(t),
+			expectedCode:    http.StatusOK,
+			expectedVersion: "1.0",
+		},
+		"remote-write 2.0": {
+			contentType:     "application/x-protobuf;proto=io.prometheus.write.v2.Request",
+			body:            createPrometheusRemoteWriteV2Protobuf(t),
+			expectedCode:    http.StatusOK,
+			expectedVersion: "2.0",
+			expectedHeaders: map[string]string{
+				"X-Prometheus-Remote-Write-Samples-Written":    "1",
+				"X-Prometheus-Remote-Write-Histograms-Written": "1",
+				"X-Prometheus-Remote-Write-Exemplars-Written":  "1",
+			},
+		},
+		"unsupported protobuf message": {
+			contentType:  "application/x-protobuf;proto=io.prometheus.write.v3.Request",
+			body:         createPrometheusRemoteWriteProtobuf(t),
+			expectedCode: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			reg := prometheus.NewPedanticRegistry()
+			// The written stats are counted by the push middlewares.
+			pushFunc := writtenStatsMiddleware(verifyWritePushFunc(t, mimirpb.API))
+			if tc.expectedCode != http.StatusOK {
+				pushFunc = readBodyPushFunc(t)
+			}
+			handler := Handler(100000, nil, nil, false, nil, RetryConfig{}, pushFunc, newPushMetrics(reg), log.NewNopLogger())
+
+			req := createRequest(t, tc.body)
+			req.Header.Del("Content-Type")
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			require.Equal(t, tc.expectedCode, resp.Code, resp.Body.String())
+
+			for name, value := range tc.expectedHeaders {
+				assert.Equal(t, value, resp.Header().Get(name), name)
+			}
+			if tc.expectedHeaders == nil {
+				assert.Empty(t, resp.Header().Get("X-Prometheus-Remote-Write-Samples-Written"))
+			}
+
+			expectedMetrics := ""
+			if tc.expectedVersion != "" {
+				expectedMetrics = fmt.Sprintf(`
+					# HELP cortex_distributor_remote_write_requests_total The total number of Prometheus remote-write requests that have come in to the distributor, by protocol version.
+					# TYPE cortex_distributor_remote_write_requests_total counter
+					cortex_distributor_remote_write_requests_total{user="test",version=%q} 1
+				`, tc.expectedVersion)
+			}
+			if tc.expectedVersion == "2.0" {
+				// The created timestamp of the series is ignored.
+				expectedMetrics += `
+					# HELP cortex_distributor_remote_write_ignored_created_timestamps_total The total number of series with a created timestamp received by the distributor in Prometheus remote-write 2.0 requests, whose created timestamp is not supported and has been ignored.
+					# TYPE cortex_distributor_remote_write_ignored_created_timestamps_total counter
+					cortex_distributor_remote_write_ignored_created_timestamps_total{user="test"} 1
+				`
+			}
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedMetrics), "cortex_distributor_remote_write_requests_total", "cortex_distributor_remote_write_ignored_created_timestamps_total"))
+		})
+	}
+}
+
 func TestOTelMetricsToMetadata(t *testing.T) {
 	otelMetrics := pmetric.NewMetrics()
 	rs := otelMetrics.ResourceMetrics().AppendEmpty()
@@ -306,6 +400,31 @@ func createPrometheusRemoteWriteProtobuf(t testing.TB) []byte {
 	return inputBytes
 }
 
+func createPrometheusRemoteWriteV2Protobuf(t testing.TB) []byte {
+	t.Helper()
+	symbols := writev2.NewSymbolTable()
+	input := writev2.Request{
+		Timeseries: []writev2.TimeSeries{
+			{
+				LabelsRefs: symbols.SymbolizeLabels(labels.FromStrings("__name__", "foo"), nil),
+				Samples: []writev2.Sample{
+					{Value: 1, Timestamp: time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC).UnixMilli()},
+				},
+				Histograms: []writev2.Histogram{
+					writev2.FromIntHistogram(1337, test.GenerateTestHistogram(1))},
+				Exemplars: []writev2.Exemplar{
+					{LabelsRefs: symbols.SymbolizeLabels(labels.FromStrings("trace_id", "1234"), nil), Value: 1, Timestamp: 1337},
+				},
+				CreatedTimestamp: time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC).UnixMilli(),
+			},
+		},
+	}
+	input.Symbols = symbols.Symbols()
+	inputBytes, err := input.Marshal()
+	require.NoError(t, err)
+	return inputBytes
+}
+
 func createMimirWriteRequestProtobuf(t *testing.T, skipLabelNameValidation bool) []byte {
 	t.Helper()
 	h := prompb.FromIntHistogram(1337, test.GenerateTestHistogram(1))
@@ -1080,3 +1199,7 @@ type otlpLimitsMock struct{}
 func (o otlpLimitsMock) OTelMetricSuffixesEnabled(_ string) bool {
 	return false
 }
+
+func (o otlpLimitsMock) PromoteOTelResourceAttributes(_ string) []string {
+	return nil
+}
@@ -10,7 +10,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
 	"net/url"
 	"sort"
@@ -35,6 +34,7 @@ import (
 	apierror "github.com/grafana/mimir/pkg/api/error"
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/querier/api"
+	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/streamingpromql/compat"
 	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/chunkinfologger"
@@ -49,6 +49,9 @@ var (
 
 	// List of HTTP headers to propagate when a Prometheus request is encoded into a HTTP request.
 	prometheusCodecPropagateHeaders = []string{compat.ForceFallbackHeaderName, chunkinfologger.ChunkInfoLoggingHeader}
+
+	// List of HTTP headers to propagate when a Prometheus response is encoded into a HTTP response.
+	prometheusCodecPropagateResponseHeaders = []string{estimatedQueryCostHeader, recordingRulesHeader}
 )
 
 const (
@@ -423,7 +426,7 @@ func DecodeRangeQueryTimeParams(reqValues *url.Values) (start, end, step int64,
 		return 0, 0, 0, errEndBeforeStart
 	}
 
-	step, err = parseDurationMs(reqValues.Get("step"))
+	step, err = util.ParseDurationMs(reqValues.Get("step"))
 	if err != nil {
 		return 0, 0, 0, decorateWithParamName(err, "step")
 	}
@@ -774,6 +777,10 @@ func (c prometheusCodec) EncodeResponse(ctx context.Context, req *http.Request,
 	}
 	if a.Data != nil {
 		sp.LogFields(otlog.Int("series", len(a.Data.Result)))
+
+		if queryStats := stats.FromContext(ctx); queryStats != nil && isQueryStatsRequested(req) {
+			a = withQueryStats(a, queryStats)
+		}
 	}
 
 	selectedContentType, formatter := c.negotiateContentType(req.Header.Get("Accept"))
@@ -799,9 +806,45 @@ func (c prometheusCodec) EncodeResponse(ctx context.Context, req *http.Request,
 		StatusCode:    http.StatusOK,
 		ContentLength: int64(len(b)),
 	}
+
+	for _, h := range a.GetHeaders() {
+		if slices.Contains(prometheusCodecPropagateResponseHeaders, h.Name) {
+			resp.Header[h.Name] = h.Values
+		}
+	}
+
 	return &resp, nil
 }
 
+// isQueryStatsRequested returns true if the request includes the stats parameter, which requests that statistics
+// about the evaluation of the query are included in the response.
+func isQueryStatsRequested(req *http.Request) bool {
+	reqValues, err := util.ParseRequestFormWithoutConsumingBody(req)
+	if err != nil {
+		return false
+	}
+
+	return reqValues.Get("stats") != ""
+}
+
+// withQueryStats returns a copy of resp with the samples processed by queriers, as recorded in queryStats, included
+// in the response in the same format as Prometheus' API.
+//
+// Per-step sample counts and timings are not included, as they aren't tracked by queriers.
+func withQueryStats(resp *PrometheusResponse, queryStats *stats.Stats) *PrometheusResponse {
+	data := *resp.Data
+	data.Stats = &PrometheusResponseStats{
+		Samples: &PrometheusResponseSamplesStats{
+			TotalQueryableSamples: int64(queryStats.LoadSamplesProcessed()),
+			PeakSamples:           int64(queryStats.LoadPeakSamples()),
+		},
+	}
+
+	withStats := *resp
+	withStats.Data = &data
+	return &withStats
+}
+
 func (prometheusCodec) negotiateContentType(acceptHeader string) (string, formatter) {
 	if acceptHeader == "" {
 		return jsonMimeType, jsonFormatterInstance
@@ -939,20 +982,6 @@ func readResponseBody(res *http.Response) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func parseDurationMs(s string) (int64, error) {
-	if d, err := strconv.ParseFloat(s, 64); err == nil {
-		ts := d * float64(time.Second/time.Millisecond)
-		if ts > float64(math.MaxInt64) || ts < float64(math.MinInt64) {
-			return 0, apierror.Newf(apierror.TypeBadData, "cannot parse %q to a valid duration. It overflows int64", s)
-		}
-		return int64(ts), nil
-	}
-	if d, err := model.ParseDuration(s); err == nil {
-		return int64(d) / int64(time.Millisecond/time.Nanosecond), nil
-	}
-	return 0, apierror.Newf(apierror.TypeBadData, "cannot parse %q to a valid duration", s)
-}
-
 func encodeTime(t int64) string {
 	f := float64(t) / 1.0e3
 	return strconv.FormatFloat(f, 'f', -1, 64)
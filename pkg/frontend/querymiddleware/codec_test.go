@@ -34,6 +34,7 @@ import (
 	apierror "github.com/grafana/mimir/pkg/api/error"
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/querier/api"
+	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/streamingpromql/compat"
 	"github.com/grafana/mimir/pkg/util/chunkinfologger"
 	testutil "github.com/grafana/mimir/pkg/util/test"
@@ -820,6 +821,95 @@ func TestPrometheusCodec_EncodeResponse_ContentNegotiation(t *testing.T) {
 	}
 }
 
+func TestPrometheusCodec_EncodeResponse_QueryStats(t *testing.T) {
+	testResponse := &PrometheusResponse{
+		Status: statusSuccess,
+		Data: &PrometheusData{
+			ResultType: model.ValVector.String(),
+			Result: []SampleStream{
+				{Labels: []mimirpb.LabelAdapter{{Name: "foo", Value: "bar"}}, Samples: []mimirpb.Sample{{TimestampMs: 1000, Value: 1}}},
+			},
+		},
+	}
+
+	scenarios := map[string]struct {
+		url           string
+		statsEnabled  bool
+		expectedStats *PrometheusResponseStats
+	}{
+		"stats not requested": {
+			url:          "/api/v1/query?query=foo",
+			statsEnabled: true,
+		},
+		"stats requested": {
+			url:          "/api/v1/query?query=foo&stats=all",
+			statsEnabled: true,
+			expectedStats: &PrometheusResponseStats{
+				Samples: &PrometheusResponseSamplesStats{TotalQueryableSamples: 100, PeakSamples: 20},
+			},
+		},
+		"stats requested but query stats tracking disabled": {
+			url:          "/api/v1/query?query=foo&stats=all",
+			statsEnabled: false,
+		},
+	}
+
+	codec := newTestPrometheusCodec()
+
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			if scenario.statsEnabled {
+				var queryStats *stats.Stats
+				queryStats, ctx = stats.ContextWithEmptyStats(ctx)
+				queryStats.AddSamplesProcessed(100)
+				queryStats.UpdatePeakSamples(20)
+			}
+
+			req, err := http.NewRequest(http.MethodGet, scenario.url, nil)
+			require.NoError(t, err)
+
+			encodedResponse, err := codec.EncodeResponse(ctx, req, testResponse)
+			require.NoError(t, err)
+
+			body, err := io.ReadAll(encodedResponse.Body)
+			require.NoError(t, err)
+
+			decoded := struct {
+				Data struct {
+					Stats *PrometheusResponseStats `json:"stats"`
+				} `json:"data"`
+			}{}
+			require.NoError(t, json.Unmarshal(body, &decoded))
+			require.Equal(t, scenario.expectedStats, decoded.Data.Stats)
+
+			require.Nil(t, testResponse.Data.Stats, "original response should not be modified")
+		})
+	}
+}
+
+func TestPrometheusCodec_EncodeResponse_PropagatesHeaders(t *testing.T) {
+	codec := newTestPrometheusCodec()
+	res := &PrometheusResponse{
+		Status: statusSuccess,
+		Data:   &PrometheusData{ResultType: model.ValVector.String()},
+		Headers: []*PrometheusHeader{
+			{Name: estimatedQueryCostHeader, Values: []string{"123"}},
+			{Name: "Some-Other-Header", Values: []string{"foo"}},
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/api/v1/query?query=foo", nil)
+	require.NoError(t, err)
+
+	encodedResponse, err := codec.EncodeResponse(context.Background(), req, res)
+	require.NoError(t, err)
+
+	require.Equal(t, "123", encodedResponse.Header.Get(estimatedQueryCostHeader))
+	require.Empty(t, encodedResponse.Header.Get("Some-Other-Header"))
+}
+
 type prometheusAPIResponse struct {
 	Status    string             `json:"status"`
 	Data      interface{}        `json:"data,omitempty"`
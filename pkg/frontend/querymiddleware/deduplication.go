@@ -0,0 +1,202 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/mimir/pkg/querier/api"
+	"github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+)
+
+var errAllDeduplicatedRequestsCanceled = errors.New("all requests waiting for the query result have been canceled")
+
+type deduplicationMiddlewareMetrics struct {
+	requestsCount     prometheus.Counter
+	deduplicatedCount prometheus.Counter
+}
+
+func newDeduplicationMiddlewareMetrics(reg prometheus.Registerer) *deduplicationMiddlewareMetrics {
+	return &deduplicationMiddlewareMetrics{
+		requestsCount: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_query_deduplication_requests_total",
+			Help: "Total number of queries considered for deduplication with identical in-flight queries.",
+		}),
+		deduplicatedCount: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_query_deduplication_deduplicated_total",
+			Help: "Total number of queries that shared the result of an identical in-flight query, rather than being executed.",
+		}),
+	}
+}
+
+// inFlightQueries tracks the queries currently being executed by deduplicationMiddleware, keyed by deduplication key.
+type inFlightQueries struct {
+	mtx     sync.Mutex
+	queries map[string]*inFlightQuery
+}
+
+// inFlightQuery is a single query execution shared by one or more requests.
+type inFlightQuery struct {
+	// done is closed once the query has completed and resp and err have been set.
+	done chan struct{}
+	resp Response
+	err  error
+
+	// stats are the statistics recorded while executing the query, which are merged into the statistics of
+	// every request sharing the execution once it has completed.
+	stats *stats.Stats
+
+	// cancel cancels the query execution. It is called once the query has completed or all waiting requests have
+	// been canceled.
+	cancel context.CancelCauseFunc
+
+	// waiters is the number of requests waiting for the query to complete. It is guarded by inFlightQueries.mtx.
+	waiters int
+}
+
+// deduplicationMiddleware is a MetricsQueryMiddleware that shares a single downstream execution between identical
+// queries received concurrently for the same tenant, such as when many users open the same dashboard at once.
+//
+// A shared execution is only canceled once every request waiting for it has been canceled. Statistics about a shared
+// execution, such as the number of samples processed, are recorded for every request that shared it.
+type deduplicationMiddleware struct {
+	next     MetricsQueryHandler
+	logger   log.Logger
+	metrics  *deduplicationMiddlewareMetrics
+	inFlight *inFlightQueries
+}
+
+// newDeduplicationMiddleware makes a new deduplicationMiddleware.
+//
+// Every handler returned by the middleware shares the same set of in-flight queries.
+func newDeduplicationMiddleware(logger log.Logger, reg prometheus.Registerer) MetricsQueryMiddleware {
+	metrics := newDeduplicationMiddlewareMetrics(reg)
+	inFlight := &inFlightQueries{queries: map[string]*inFlightQuery{}}
+
+	return MetricsQueryMiddlewareFunc(func(next MetricsQueryHandler) MetricsQueryHandler {
+		return &deduplicationMiddleware{
+			next:     next,
+			logger:   logger,
+			metrics:  metrics,
+			inFlight: inFlight,
+		}
+	})
+}
+
+func (d *deduplicationMiddleware) Do(ctx context.Context, req MetricsQueryRequest) (Response, error) {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return d.next.Do(ctx, req)
+	}
+
+	d.metrics.requestsCount.Inc()
+	key := deduplicationKey(ctx, tenant.JoinTenantIDs(tenantIDs), req)
+
+	d.inFlight.mtx.Lock()
+	query, deduplicated := d.inFlight.queries[key]
+	if deduplicated {
+		query.waiters++
+	} else {
+		query = d.start(ctx, key, req)
+	}
+	d.inFlight.mtx.Unlock()
+
+	if deduplicated {
+		d.metrics.deduplicatedCount.Inc()
+		spanLog := spanlogger.FromContext(ctx, d.logger)
+		level.Debug(spanLog).Log("msg", "sharing the result of an identical in-flight query", "query", req.GetQuery())
+	}
+
+	select {
+	case <-query.done:
+		stats.FromContext(ctx).Merge(query.stats)
+		return query.resp, query.err
+	case <-ctx.Done():
+		d.leave(key, query)
+		return nil, context.Cause(ctx)
+	}
+}
+
+// start begins executing req in the background, and registers it as in-flight under key.
+// It must be called with d.inFlight.mtx held.
+func (d *deduplicationMiddleware) start(ctx context.Context, key string, req MetricsQueryRequest) *inFlightQuery {
+	// The query must not be canceled when the request that started it is canceled, as other requests may be
+	// waiting for it, so we detach it from ctx's cancellation while keeping ctx's values (eg. tenant ID and tracing span).
+	// Detaching also drops ctx's deadline, so we apply it again to make sure the query can't run for longer
+	// than the request that started it is allowed to.
+	// The query records its statistics separately from the request that started it, so that they can be merged
+	// into the statistics of each request sharing it.
+	queryCtx := context.WithoutCancel(ctx)
+	cancelDeadline := context.CancelFunc(func() {})
+	if deadline, ok := ctx.Deadline(); ok {
+		queryCtx, cancelDeadline = context.WithDeadline(queryCtx, deadline)
+	}
+	queryCtx, cancel := context.WithCancelCause(queryCtx)
+	queryStats, queryCtx := stats.ContextWithEmptyStats(queryCtx)
+
+	query := &inFlightQuery{
+		done:    make(chan struct{}),
+		stats:   queryStats,
+		cancel:  cancel,
+		waiters: 1,
+	}
+
+	d.inFlight.queries[key] = query
+
+	go func() {
+		defer close(query.done)
+
+		query.resp, query.err = d.next.Do(queryCtx, req)
+		query.cancel(nil)
+		cancelDeadline()
+
+		// Remove the query before signalling its completion, so that requests received from now on
+		// execute the query again rather than sharing a result that may have become stale.
+		d.inFlight.mtx.Lock()
+		d.remove(key, query)
+		d.inFlight.mtx.Unlock()
+	}()
+
+	return query
+}
+
+// leave is called when a request waiting for query is canceled. The query is canceled if no other requests
+// are waiting for it.
+func (d *deduplicationMiddleware) leave(key string, query *inFlightQuery) {
+	d.inFlight.mtx.Lock()
+	defer d.inFlight.mtx.Unlock()
+
+	query.waiters--
+	if query.waiters > 0 {
+		return
+	}
+
+	query.cancel(errAllDeduplicatedRequestsCanceled)
+	d.remove(key, query)
+}
+
+// remove removes query from the in-flight queries, if it is still registered under key.
+// It must be called with d.inFlight.mtx held.
+func (d *deduplicationMiddleware) remove(key string, query *inFlightQuery) {
+	if d.inFlight.queries[key] == query {
+		delete(d.inFlight.queries, key)
+	}
+}
+
+// deduplicationKey returns a key that is the same for two requests only if they can share the same response.
+func deduplicationKey(ctx context.Context, tenantID string, req MetricsQueryRequest) string {
+	consistency, _ := api.ReadConsistencyFromContext(ctx)
+	options := req.GetOptions()
+
+	return fmt.Sprintf("%s:%s:%s:%d:%d:%d:%s:%s", tenantID, req.GetPath(), req.GetQuery(), req.GetStart(), req.GetEnd(), req.GetStep(), consistency, options.String())
+}
@@ -0,0 +1,345 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/mimir/pkg/querier/api"
+	"github.com/grafana/mimir/pkg/querier/stats"
+)
+
+// blockingHandler is a MetricsQueryHandler that blocks each request until it is released or canceled.
+type blockingHandler struct {
+	calls    atomic.Int64
+	started  chan struct{}
+	release  chan struct{}
+	canceled chan error
+	resp     Response
+	err      error
+}
+
+func newBlockingHandler() *blockingHandler {
+	return &blockingHandler{
+		started:  make(chan struct{}, 10),
+		release:  make(chan struct{}),
+		canceled: make(chan error, 10),
+		resp:     &PrometheusResponse{Status: statusSuccess, Data: &PrometheusData{ResultType: "vector"}},
+	}
+}
+
+func (h *blockingHandler) Do(ctx context.Context, _ MetricsQueryRequest) (Response, error) {
+	h.calls.Inc()
+	h.started <- struct{}{}
+
+	select {
+	case <-h.release:
+		queryStats := stats.FromContext(ctx)
+		queryStats.AddFetchedSeries(10)
+		queryStats.AddFetchedChunks(20)
+		queryStats.AddSamplesProcessed(100)
+		return h.resp, h.err
+	case <-ctx.Done():
+		h.canceled <- context.Cause(ctx)
+		return nil, context.Cause(ctx)
+	}
+}
+
+func TestDeduplicationMiddleware(t *testing.T) {
+	rangeRequest := func(query string) MetricsQueryRequest {
+		return NewPrometheusRangeQueryRequest("/api/v1/query_range", nil, 0, 3600000, 60000, 0, parseQuery(t, query), Options{}, nil)
+	}
+
+	type result struct {
+		resp Response
+		err  error
+	}
+
+	// run starts executing req through handler in the background, and returns a channel that receives its result.
+	run := func(ctx context.Context, handler MetricsQueryHandler, req MetricsQueryRequest) chan result {
+		results := make(chan result, 1)
+
+		go func() {
+			resp, err := handler.Do(ctx, req)
+			results <- result{resp, err}
+		}()
+
+		return results
+	}
+
+	// waitForWaiters waits until n requests are waiting for the in-flight query with key.
+	waitForWaiters := func(t *testing.T, handler MetricsQueryHandler, key string, n int) {
+		inFlight := handler.(*deduplicationMiddleware).inFlight
+
+		require.Eventually(t, func() bool {
+			inFlight.mtx.Lock()
+			defer inFlight.mtx.Unlock()
+
+			query, ok := inFlight.queries[key]
+			return ok && query.waiters == n
+		}, time.Second, time.Millisecond)
+	}
+
+	keyFor := func(ctx context.Context, req MetricsQueryRequest) string {
+		tenantID, err := user.ExtractOrgID(ctx)
+		require.NoError(t, err)
+		return deduplicationKey(ctx, tenantID, req)
+	}
+
+	t.Run("identical concurrent requests share a single execution", func(t *testing.T) {
+		reg := prometheus.NewPedanticRegistry()
+		next := newBlockingHandler()
+		handler := newDeduplicationMiddleware(log.NewNopLogger(), reg).Wrap(next)
+		ctx := user.InjectOrgID(context.Background(), "user-1")
+		req := rangeRequest("sum(rate(some_metric[5m]))")
+
+		results := make([]chan result, 0, 3)
+		for i := 0; i < 3; i++ {
+			results = append(results, run(ctx, handler, req))
+		}
+
+		waitForWaiters(t, handler, keyFor(ctx, req), 3)
+		close(next.release)
+
+		for _, r := range results {
+			res := <-r
+			require.NoError(t, res.err)
+			require.Same(t, next.resp, res.resp)
+		}
+
+		require.Equal(t, int64(1), next.calls.Load())
+		require.Empty(t, handler.(*deduplicationMiddleware).inFlight.queries)
+
+		assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_frontend_query_deduplication_deduplicated_total Total number of queries that shared the result of an identical in-flight query, rather than being executed.
+			# TYPE cortex_frontend_query_deduplication_deduplicated_total counter
+			cortex_frontend_query_deduplication_deduplicated_total 2
+			# HELP cortex_frontend_query_deduplication_requests_total Total number of queries considered for deduplication with identical in-flight queries.
+			# TYPE cortex_frontend_query_deduplication_requests_total counter
+			cortex_frontend_query_deduplication_requests_total 3
+		`)))
+	})
+
+	t.Run("statistics of the shared execution are recorded for every waiting request", func(t *testing.T) {
+		next := newBlockingHandler()
+		handler := newDeduplicationMiddleware(log.NewNopLogger(), nil).Wrap(next)
+		req := rangeRequest("some_metric")
+
+		var (
+			reqStats []*stats.Stats
+			results  []chan result
+		)
+		for i := 0; i < 2; i++ {
+			s, ctx := stats.ContextWithEmptyStats(user.InjectOrgID(context.Background(), "user-1"))
+			reqStats = append(reqStats, s)
+			results = append(results, run(ctx, handler, req))
+		}
+
+		waitForWaiters(t, handler, keyFor(user.InjectOrgID(context.Background(), "user-1"), req), 2)
+		close(next.release)
+
+		for i, r := range results {
+			require.NoError(t, (<-r).err)
+			assert.Equal(t, uint64(10), reqStats[i].LoadFetchedSeries())
+			assert.Equal(t, uint64(20), reqStats[i].LoadFetchedChunks())
+			assert.Equal(t, uint64(100), reqStats[i].LoadSamplesProcessed())
+		}
+		require.Equal(t, int64(1), next.calls.Load())
+	})
+
+	t.Run("errors are shared with every waiting request", func(t *testing.T) {
+		next := newBlockingHandler()
+		next.err = errors.New("something went wrong")
+		handler := newDeduplicationMiddleware(log.NewNopLogger(), nil).Wrap(next)
+		ctx := user.InjectOrgID(context.Background(), "user-1")
+		req := rangeRequest("some_metric")
+
+		first := run(ctx, handler, req)
+		second := run(ctx, handler, req)
+		waitForWaiters(t, handler, keyFor(ctx, req), 2)
+		close(next.release)
+
+		require.Equal(t, next.err, (<-first).err)
+		require.Equal(t, next.err, (<-second).err)
+		require.Equal(t, int64(1), next.calls.Load())
+	})
+
+	t.Run("requests are executed again once the in-flight query has completed", func(t *testing.T) {
+		next := newBlockingHandler()
+		close(next.release)
+		handler := newDeduplicationMiddleware(log.NewNopLogger(), nil).Wrap(next)
+		ctx := user.InjectOrgID(context.Background(), "user-1")
+		req := rangeRequest("some_metric")
+
+		for i := 0; i < 2; i++ {
+			_, err := handler.Do(ctx, req)
+			require.NoError(t, err)
+		}
+
+		require.Equal(t, int64(2), next.calls.Load())
+	})
+
+	t.Run("different requests are not deduplicated", func(t *testing.T) {
+		reqs := map[string]struct {
+			ctx context.Context
+			req MetricsQueryRequest
+		}{
+			"original": {
+				ctx: user.InjectOrgID(context.Background(), "user-1"),
+				req: rangeRequest("some_metric"),
+			},
+			"different tenant": {
+				ctx: user.InjectOrgID(context.Background(), "user-2"),
+				req: rangeRequest("some_metric"),
+			},
+			"different query": {
+				ctx: user.InjectOrgID(context.Background(), "user-1"),
+				req: rangeRequest("other_metric"),
+			},
+			"different time range": {
+				ctx: user.InjectOrgID(context.Background(), "user-1"),
+				req: NewPrometheusRangeQueryRequest("/api/v1/query_range", nil, 0, 7200000, 60000, 0, parseQuery(t, "some_metric"), Options{}, nil),
+			},
+			"different step": {
+				ctx: user.InjectOrgID(context.Background(), "user-1"),
+				req: NewPrometheusRangeQueryRequest("/api/v1/query_range", nil, 0, 3600000, 30000, 0, parseQuery(t, "some_metric"), Options{}, nil),
+			},
+			"different options": {
+				ctx: user.InjectOrgID(context.Background(), "user-1"),
+				req: NewPrometheusRangeQueryRequest("/api/v1/query_range", nil, 0, 3600000, 60000, 0, parseQuery(t, "some_metric"), Options{ShardingDisabled: true}, nil),
+			},
+			"different read consistency": {
+				ctx: api.ContextWithReadConsistency(user.InjectOrgID(context.Background(), "user-1"), api.ReadConsistencyStrong),
+				req: rangeRequest("some_metric"),
+			},
+			"instant query": {
+				ctx: user.InjectOrgID(context.Background(), "user-1"),
+				req: NewPrometheusInstantQueryRequest("/api/v1/query", nil, 0, 0, parseQuery(t, "some_metric"), Options{}, nil),
+			},
+		}
+
+		next := newBlockingHandler()
+		handler := newDeduplicationMiddleware(log.NewNopLogger(), nil).Wrap(next)
+
+		results := make([]chan result, 0, len(reqs))
+		for _, r := range reqs {
+			results = append(results, run(r.ctx, handler, r.req))
+		}
+
+		for _, r := range reqs {
+			waitForWaiters(t, handler, keyFor(r.ctx, r.req), 1)
+		}
+
+		close(next.release)
+
+		for _, r := range results {
+			require.NoError(t, (<-r).err)
+		}
+
+		require.Equal(t, int64(len(reqs)), next.calls.Load())
+	})
+
+	t.Run("canceling one request does not cancel the shared execution", func(t *testing.T) {
+		next := newBlockingHandler()
+		handler := newDeduplicationMiddleware(log.NewNopLogger(), nil).Wrap(next)
+		ctx := user.InjectOrgID(context.Background(), "user-1")
+		req := rangeRequest("some_metric")
+
+		firstCtx, cancelFirst := context.WithCancel(ctx)
+		first := run(firstCtx, handler, req)
+		second := run(ctx, handler, req)
+		waitForWaiters(t, handler, keyFor(ctx, req), 2)
+
+		cancelFirst()
+		require.ErrorIs(t, (<-first).err, context.Canceled)
+		waitForWaiters(t, handler, keyFor(ctx, req), 1)
+
+		close(next.release)
+		res := <-second
+		require.NoError(t, res.err)
+		require.Same(t, next.resp, res.resp)
+		require.Empty(t, next.canceled)
+	})
+
+	t.Run("canceling every request cancels the shared execution", func(t *testing.T) {
+		next := newBlockingHandler()
+		handler := newDeduplicationMiddleware(log.NewNopLogger(), nil).Wrap(next)
+		ctx := user.InjectOrgID(context.Background(), "user-1")
+		req := rangeRequest("some_metric")
+
+		var cancels []context.CancelFunc
+		var results []chan result
+		for i := 0; i < 2; i++ {
+			reqCtx, cancel := context.WithCancel(ctx)
+			cancels = append(cancels, cancel)
+			results = append(results, run(reqCtx, handler, req))
+		}
+		waitForWaiters(t, handler, keyFor(ctx, req), 2)
+
+		for i, cancel := range cancels {
+			cancel()
+			require.ErrorIs(t, (<-results[i]).err, context.Canceled)
+		}
+
+		require.ErrorIs(t, <-next.canceled, errAllDeduplicatedRequestsCanceled)
+
+		// A new request must not share the canceled execution.
+		done := run(ctx, handler, req)
+		<-next.started
+		<-next.started
+		close(next.release)
+		require.NoError(t, (<-done).err)
+		require.Equal(t, int64(2), next.calls.Load())
+	})
+
+	t.Run("the shared execution keeps the deadline of the request that started it", func(t *testing.T) {
+		next := newBlockingHandler()
+		handler := newDeduplicationMiddleware(log.NewNopLogger(), nil).Wrap(next)
+		ctx := user.InjectOrgID(context.Background(), "user-1")
+		req := rangeRequest("some_metric")
+
+		firstCtx, cancelFirst := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancelFirst()
+		first := run(firstCtx, handler, req)
+		<-next.started
+		second := run(ctx, handler, req)
+		waitForWaiters(t, handler, keyFor(ctx, req), 2)
+
+		require.ErrorIs(t, (<-first).err, context.DeadlineExceeded)
+		require.ErrorIs(t, (<-second).err, context.DeadlineExceeded)
+		require.ErrorIs(t, <-next.canceled, context.DeadlineExceeded)
+		require.Equal(t, int64(1), next.calls.Load())
+	})
+
+	t.Run("requests without a tenant are not deduplicated", func(t *testing.T) {
+		next := newBlockingHandler()
+		close(next.release)
+		handler := newDeduplicationMiddleware(log.NewNopLogger(), nil).Wrap(next)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := handler.Do(context.Background(), rangeRequest("some_metric"))
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		require.Equal(t, int64(2), next.calls.Load())
+	})
+}
@@ -25,6 +25,13 @@ func newMaxQueryExpressionSizeBytesError(actualSizeBytes, maxQuerySizeBytes int)
 	))
 }
 
+func newMaxEstimatedQueryCostError(estimatedCost uint64, maxEstimatedCost int) error {
+	return apierror.New(apierror.TypeBadData, globalerror.MaxEstimatedQueryCost.MessageWithPerTenantLimitConfig(
+		fmt.Sprintf("the estimated cost of the query exceeds the limit (estimated cost: %d samples, limit: %d samples); reduce the time range of the query, increase its step or select fewer series", estimatedCost, maxEstimatedCost),
+		validation.MaxEstimatedQueryCostFlag,
+	))
+}
+
 func newQueryBlockedError() error {
 	return apierror.New(apierror.TypeBadData, globalerror.QueryBlocked.Message("the request has been blocked by the cluster administrator"))
 }
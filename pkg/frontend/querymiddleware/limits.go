@@ -45,6 +45,10 @@ type Limits interface {
 	// query may be. 0 means "unlimited".
 	MaxQueryExpressionSizeBytes(userID string) int
 
+	// MaxEstimatedQueryCost returns the limit of the estimated cost of a query, measured as the estimated
+	// number of samples processed by the query. 0 means "unlimited".
+	MaxEstimatedQueryCost(userID string) int
+
 	// MaxCacheFreshness returns the period after which results are cacheable,
 	// to prevent caching of very recent results.
 	MaxCacheFreshness(userID string) time.Duration
@@ -101,6 +105,12 @@ type Limits interface {
 
 	// QueryIngestersWithin returns the maximum lookback beyond which queries are not sent to ingester.
 	QueryIngestersWithin(userID string) time.Duration
+
+	// RulerRecordingRulesEvaluationEnabled returns whether the recording rules of a given tenant are evaluated.
+	RulerRecordingRulesEvaluationEnabled(userID string) bool
+
+	// EvaluationDelay returns the rules evaluation delay for a given tenant.
+	EvaluationDelay(userID string) time.Duration
 }
 
 type limitsMiddleware struct {
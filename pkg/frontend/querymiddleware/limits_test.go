@@ -509,6 +509,10 @@ func (m multiTenantMockLimits) MaxQueryExpressionSizeBytes(userID string) int {
 	return m.byTenant[userID].maxQueryExpressionSizeBytes
 }
 
+func (m multiTenantMockLimits) MaxEstimatedQueryCost(userID string) int {
+	return m.byTenant[userID].maxEstimatedQueryCost
+}
+
 func (m multiTenantMockLimits) MaxQueryParallelism(userID string) int {
 	return m.byTenant[userID].maxQueryParallelism
 }
@@ -585,11 +589,20 @@ func (m multiTenantMockLimits) QueryIngestersWithin(userID string) time.Duration
 	return m.byTenant[userID].queryIngestersWithin
 }
 
+func (m multiTenantMockLimits) RulerRecordingRulesEvaluationEnabled(userID string) bool {
+	return !m.byTenant[userID].recordingRulesEvaluationDisabled
+}
+
+func (m multiTenantMockLimits) EvaluationDelay(userID string) time.Duration {
+	return m.byTenant[userID].evaluationDelay
+}
+
 type mockLimits struct {
 	maxQueryLookback                     time.Duration
 	maxQueryLength                       time.Duration
 	maxTotalQueryLength                  time.Duration
 	maxQueryExpressionSizeBytes          int
+	maxEstimatedQueryCost                int
 	maxCacheFreshness                    time.Duration
 	maxQueryParallelism                  int
 	maxShardedQueries                    int
@@ -609,6 +622,8 @@ type mockLimits struct {
 	blockedQueries                       []*validation.BlockedQuery
 	alignQueriesWithStep                 bool
 	queryIngestersWithin                 time.Duration
+	recordingRulesEvaluationDisabled     bool
+	evaluationDelay                      time.Duration
 }
 
 func (m mockLimits) MaxQueryLookback(string) time.Duration {
@@ -626,6 +641,10 @@ func (m mockLimits) MaxQueryExpressionSizeBytes(string) int {
 	return m.maxQueryExpressionSizeBytes
 }
 
+func (m mockLimits) MaxEstimatedQueryCost(string) int {
+	return m.maxEstimatedQueryCost
+}
+
 func (m mockLimits) MaxQueryParallelism(string) int {
 	if m.maxQueryParallelism == 0 {
 		return 14 // Flag default.
@@ -705,6 +724,14 @@ func (m mockLimits) QueryIngestersWithin(string) time.Duration {
 	return m.queryIngestersWithin
 }
 
+func (m mockLimits) RulerRecordingRulesEvaluationEnabled(string) bool {
+	return !m.recordingRulesEvaluationDisabled
+}
+
+func (m mockLimits) EvaluationDelay(string) time.Duration {
+	return m.evaluationDelay
+}
+
 type mockHandler struct {
 	mock.Mock
 }
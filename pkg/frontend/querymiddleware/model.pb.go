@@ -176,6 +176,8 @@ func (m *PrometheusResponse) GetInfos() []string {
 type PrometheusData struct {
 	ResultType string         `protobuf:"bytes,1,opt,name=ResultType,proto3" json:"resultType"`
 	Result     []SampleStream `protobuf:"bytes,2,rep,name=Result,proto3" json:"result"`
+	// Statistics about the evaluation of the query. Only set in responses to requests with the stats parameter.
+	Stats *PrometheusResponseStats `protobuf:"bytes,3,opt,name=Stats,proto3" json:"stats,omitempty"`
 }
 
 func (m *PrometheusData) Reset()      { *m = PrometheusData{} }
@@ -224,6 +226,108 @@ func (m *PrometheusData) GetResult() []SampleStream {
 	return nil
 }
 
+func (m *PrometheusData) GetStats() *PrometheusResponseStats {
+	if m != nil {
+		return m.Stats
+	}
+	return nil
+}
+
+// PrometheusResponseStats holds the statistics returned by the Prometheus API when the stats parameter is set.
+type PrometheusResponseStats struct {
+	Samples *PrometheusResponseSamplesStats `protobuf:"bytes,1,opt,name=Samples,proto3" json:"samples"`
+}
+
+func (m *PrometheusResponseStats) Reset()      { *m = PrometheusResponseStats{} }
+func (*PrometheusResponseStats) ProtoMessage() {}
+func (*PrometheusResponseStats) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4c16552f9fdb66d8, []int{3}
+}
+func (m *PrometheusResponseStats) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *PrometheusResponseStats) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_PrometheusResponseStats.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *PrometheusResponseStats) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PrometheusResponseStats.Merge(m, src)
+}
+func (m *PrometheusResponseStats) XXX_Size() int {
+	return m.Size()
+}
+func (m *PrometheusResponseStats) XXX_DiscardUnknown() {
+	xxx_messageInfo_PrometheusResponseStats.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PrometheusResponseStats proto.InternalMessageInfo
+
+func (m *PrometheusResponseStats) GetSamples() *PrometheusResponseSamplesStats {
+	if m != nil {
+		return m.Samples
+	}
+	return nil
+}
+
+type PrometheusResponseSamplesStats struct {
+	TotalQueryableSamples int64 `protobuf:"varint,1,opt,name=TotalQueryableSamples,proto3" json:"totalQueryableSamples"`
+	PeakSamples           int64 `protobuf:"varint,2,opt,name=PeakSamples,proto3" json:"peakSamples"`
+}
+
+func (m *PrometheusResponseSamplesStats) Reset()      { *m = PrometheusResponseSamplesStats{} }
+func (*PrometheusResponseSamplesStats) ProtoMessage() {}
+func (*PrometheusResponseSamplesStats) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4c16552f9fdb66d8, []int{4}
+}
+func (m *PrometheusResponseSamplesStats) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *PrometheusResponseSamplesStats) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_PrometheusResponseSamplesStats.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *PrometheusResponseSamplesStats) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PrometheusResponseSamplesStats.Merge(m, src)
+}
+func (m *PrometheusResponseSamplesStats) XXX_Size() int {
+	return m.Size()
+}
+func (m *PrometheusResponseSamplesStats) XXX_DiscardUnknown() {
+	xxx_messageInfo_PrometheusResponseSamplesStats.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PrometheusResponseSamplesStats proto.InternalMessageInfo
+
+func (m *PrometheusResponseSamplesStats) GetTotalQueryableSamples() int64 {
+	if m != nil {
+		return m.TotalQueryableSamples
+	}
+	return 0
+}
+
+func (m *PrometheusResponseSamplesStats) GetPeakSamples() int64 {
+	if m != nil {
+		return m.PeakSamples
+	}
+	return 0
+}
+
 type SampleStream struct {
 	Labels     []github_com_grafana_mimir_pkg_mimirpb.LabelAdapter `protobuf:"bytes,1,rep,name=labels,proto3,customtype=github.com/grafana/mimir/pkg/mimirpb.LabelAdapter" json:"metric"`
 	Samples    []mimirpb.Sample                                    `protobuf:"bytes,2,rep,name=samples,proto3" json:"values"`
@@ -233,7 +337,7 @@ type SampleStream struct {
 func (m *SampleStream) Reset()      { *m = SampleStream{} }
 func (*SampleStream) ProtoMessage() {}
 func (*SampleStream) Descriptor() ([]byte, []int) {
-	return fileDescriptor_4c16552f9fdb66d8, []int{3}
+	return fileDescriptor_4c16552f9fdb66d8, []int{5}
 }
 func (m *SampleStream) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -285,7 +389,7 @@ type CachedResponse struct {
 func (m *CachedResponse) Reset()      { *m = CachedResponse{} }
 func (*CachedResponse) ProtoMessage() {}
 func (*CachedResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_4c16552f9fdb66d8, []int{4}
+	return fileDescriptor_4c16552f9fdb66d8, []int{6}
 }
 func (m *CachedResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -342,7 +446,7 @@ type Extent struct {
 func (m *Extent) Reset()      { *m = Extent{} }
 func (*Extent) ProtoMessage() {}
 func (*Extent) Descriptor() ([]byte, []int) {
-	return fileDescriptor_4c16552f9fdb66d8, []int{5}
+	return fileDescriptor_4c16552f9fdb66d8, []int{7}
 }
 func (m *Extent) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -418,7 +522,7 @@ type Options struct {
 func (m *Options) Reset()      { *m = Options{} }
 func (*Options) ProtoMessage() {}
 func (*Options) Descriptor() ([]byte, []int) {
-	return fileDescriptor_4c16552f9fdb66d8, []int{6}
+	return fileDescriptor_4c16552f9fdb66d8, []int{8}
 }
 func (m *Options) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -489,7 +593,7 @@ type QueryStatistics struct {
 func (m *QueryStatistics) Reset()      { *m = QueryStatistics{} }
 func (*QueryStatistics) ProtoMessage() {}
 func (*QueryStatistics) Descriptor() ([]byte, []int) {
-	return fileDescriptor_4c16552f9fdb66d8, []int{7}
+	return fileDescriptor_4c16552f9fdb66d8, []int{9}
 }
 func (m *QueryStatistics) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -541,7 +645,7 @@ type CachedHTTPResponse struct {
 func (m *CachedHTTPResponse) Reset()      { *m = CachedHTTPResponse{} }
 func (*CachedHTTPResponse) ProtoMessage() {}
 func (*CachedHTTPResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_4c16552f9fdb66d8, []int{8}
+	return fileDescriptor_4c16552f9fdb66d8, []int{10}
 }
 func (m *CachedHTTPResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -607,7 +711,7 @@ type CachedHTTPHeader struct {
 func (m *CachedHTTPHeader) Reset()      { *m = CachedHTTPHeader{} }
 func (*CachedHTTPHeader) ProtoMessage() {}
 func (*CachedHTTPHeader) Descriptor() ([]byte, []int) {
-	return fileDescriptor_4c16552f9fdb66d8, []int{9}
+	return fileDescriptor_4c16552f9fdb66d8, []int{11}
 }
 func (m *CachedHTTPHeader) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -654,6 +758,8 @@ func init() {
 	proto.RegisterType((*PrometheusHeader)(nil), "queryrange.PrometheusHeader")
 	proto.RegisterType((*PrometheusResponse)(nil), "queryrange.PrometheusResponse")
 	proto.RegisterType((*PrometheusData)(nil), "queryrange.PrometheusData")
+	proto.RegisterType((*PrometheusResponseStats)(nil), "queryrange.PrometheusResponseStats")
+	proto.RegisterType((*PrometheusResponseSamplesStats)(nil), "queryrange.PrometheusResponseSamplesStats")
 	proto.RegisterType((*SampleStream)(nil), "queryrange.SampleStream")
 	proto.RegisterType((*CachedResponse)(nil), "queryrange.CachedResponse")
 	proto.RegisterType((*Extent)(nil), "queryrange.Extent")
@@ -666,71 +772,77 @@ func init() {
 func init() { proto.RegisterFile("model.proto", fileDescriptor_4c16552f9fdb66d8) }
 
 var fileDescriptor_4c16552f9fdb66d8 = []byte{
-	// 1015 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x55, 0xcf, 0x6e, 0x23, 0xc5,
-	0x13, 0xf6, 0xc4, 0x7f, 0x53, 0xde, 0x5f, 0x62, 0x75, 0xa2, 0x1f, 0x4e, 0x80, 0x19, 0x6b, 0xc4,
-	0x21, 0xa0, 0x5d, 0x07, 0x82, 0xe0, 0x80, 0x00, 0xb1, 0xce, 0x06, 0x25, 0xb0, 0x40, 0x68, 0x47,
-	0x20, 0x71, 0x89, 0xda, 0x9e, 0x8e, 0x3d, 0xec, 0xcc, 0xf4, 0xd0, 0xdd, 0xde, 0x5d, 0xdf, 0x10,
-	0x0f, 0x80, 0x78, 0x02, 0xce, 0x3c, 0xca, 0x1e, 0x73, 0x5c, 0xed, 0x61, 0x44, 0x1c, 0x21, 0x21,
-	0x9f, 0xf6, 0x01, 0x38, 0xa0, 0xa9, 0x9e, 0x19, 0xcf, 0xee, 0xe6, 0xc0, 0xc5, 0xd3, 0xfd, 0xd5,
-	0xf7, 0x55, 0x55, 0x57, 0x57, 0x97, 0xa1, 0x1d, 0x0a, 0x8f, 0x07, 0xfd, 0x58, 0x0a, 0x2d, 0x08,
-	0xfc, 0x34, 0xe3, 0x72, 0x2e, 0x59, 0x34, 0xe1, 0xbb, 0x77, 0x26, 0xbe, 0x9e, 0xce, 0x46, 0xfd,
-	0xb1, 0x08, 0xf7, 0x27, 0x62, 0x22, 0xf6, 0x91, 0x32, 0x9a, 0x5d, 0xe0, 0x0e, 0x37, 0xb8, 0x32,
-	0xd2, 0xdd, 0x77, 0xcb, 0x74, 0xc9, 0x2e, 0x58, 0xc4, 0xf6, 0x43, 0x3f, 0xf4, 0xe5, 0x7e, 0xfc,
-	0x60, 0x62, 0x56, 0xf1, 0xc8, 0x7c, 0x33, 0xc5, 0xce, 0x44, 0x88, 0x49, 0xc0, 0x57, 0x7e, 0x59,
-	0x34, 0x37, 0x26, 0xf7, 0x3e, 0x74, 0x4e, 0xa5, 0x08, 0xb9, 0x9e, 0xf2, 0x99, 0x3a, 0xe6, 0xcc,
-	0xe3, 0x92, 0xec, 0x40, 0xed, 0x6b, 0x16, 0xf2, 0xae, 0xd5, 0xb3, 0xf6, 0xd6, 0x07, 0xf5, 0x65,
-	0xe2, 0x58, 0x77, 0x28, 0x42, 0xe4, 0x4d, 0x68, 0x7c, 0xc7, 0x82, 0x19, 0x57, 0xdd, 0xb5, 0x5e,
-	0x75, 0x65, 0xcc, 0x40, 0xf7, 0x9f, 0x35, 0x20, 0x2b, 0x77, 0x94, 0xab, 0x58, 0x44, 0x8a, 0x13,
-	0x17, 0x1a, 0x43, 0xcd, 0xf4, 0x4c, 0x65, 0x2e, 0x61, 0x99, 0x38, 0x0d, 0x85, 0x08, 0xcd, 0x2c,
-	0x64, 0x00, 0xb5, 0x7b, 0x4c, 0xb3, 0xee, 0x5a, 0xcf, 0xda, 0x6b, 0x1f, 0xec, 0xf6, 0x57, 0xf5,
-	0xe9, 0xaf, 0x3c, 0xa6, 0x8c, 0x01, 0x59, 0x26, 0xce, 0x86, 0xc7, 0x34, 0xbb, 0x2d, 0x42, 0x5f,
-	0xf3, 0x30, 0xd6, 0x73, 0x8a, 0x5a, 0xf2, 0x01, 0xac, 0x1f, 0x49, 0x29, 0xe4, 0xd9, 0x3c, 0xe6,
-	0xdd, 0x2a, 0x86, 0x7a, 0x6d, 0x99, 0x38, 0x5b, 0x3c, 0x07, 0x4b, 0x8a, 0x15, 0x93, 0xbc, 0x0d,
-	0x75, 0xdc, 0x74, 0x6b, 0x28, 0xd9, 0x5a, 0x26, 0xce, 0x26, 0x4a, 0x4a, 0x74, 0xc3, 0x20, 0x9f,
-	0x40, 0xd3, 0x14, 0x49, 0x75, 0xeb, 0xbd, 0xea, 0x5e, 0xfb, 0xe0, 0x8d, 0x9b, 0x13, 0x35, 0xa4,
-	0xbc, 0x3c, 0xb9, 0x86, 0x1c, 0x40, 0xeb, 0x7b, 0x26, 0x23, 0x3f, 0x9a, 0xa8, 0x6e, 0x03, 0x0b,
-	0xf8, 0xff, 0x65, 0xe2, 0x90, 0x47, 0x19, 0x56, 0x8a, 0x57, 0xf0, 0xd2, 0xec, 0x4e, 0xa2, 0x0b,
-	0xa1, 0xba, 0x4d, 0x14, 0x60, 0x76, 0x7e, 0x0a, 0x94, 0xb3, 0x43, 0x86, 0xfb, 0x8b, 0x05, 0x1b,
-	0x2f, 0x16, 0x8b, 0xf4, 0x01, 0x28, 0x57, 0xb3, 0x40, 0x63, 0x4d, 0x4c, 0xf9, 0x37, 0x96, 0x89,
-	0x03, 0xb2, 0x40, 0x69, 0x89, 0x41, 0x3e, 0x83, 0x86, 0xd9, 0xe1, 0x05, 0xb7, 0x0f, 0xba, 0xe5,
-	0xf3, 0x0d, 0x59, 0x18, 0x07, 0x7c, 0xa8, 0x25, 0x67, 0xe1, 0x60, 0xe3, 0x49, 0xe2, 0x54, 0xd2,
-	0x8b, 0x34, 0x9e, 0x68, 0xa6, 0x73, 0x7f, 0x5d, 0x83, 0x5b, 0x65, 0x22, 0x89, 0xa1, 0x11, 0xb0,
-	0x11, 0x0f, 0xd2, 0xdb, 0x4f, 0x5d, 0x6e, 0xf5, 0xc7, 0x42, 0x6a, 0xfe, 0x38, 0x1e, 0xf5, 0xef,
-	0xa7, 0xf8, 0x29, 0xf3, 0xe5, 0xe0, 0x30, 0xf5, 0xf6, 0x2c, 0x71, 0xde, 0xfb, 0x2f, 0xcd, 0x6d,
-	0x74, 0x77, 0x3d, 0x16, 0x6b, 0x2e, 0xd3, 0x14, 0x42, 0xae, 0xa5, 0x3f, 0xa6, 0x59, 0x1c, 0xf2,
-	0x11, 0x34, 0x15, 0x66, 0xa0, 0xb2, 0x53, 0x74, 0x56, 0x21, 0x4d, 0x6a, 0xab, 0xec, 0x1f, 0x62,
-	0xe7, 0xd2, 0x5c, 0x40, 0x4e, 0x01, 0xa6, 0xbe, 0xd2, 0x62, 0x22, 0x59, 0xa8, 0xba, 0xd5, 0xec,
-	0x92, 0x0b, 0xf9, 0xe7, 0x81, 0x60, 0xfa, 0x38, 0x27, 0x60, 0xea, 0x24, 0x73, 0x55, 0xd2, 0xd1,
-	0xd2, 0xda, 0xfd, 0x11, 0x36, 0x0e, 0xd9, 0x78, 0xca, 0xbd, 0xe2, 0x3d, 0xec, 0x40, 0xf5, 0x01,
-	0x9f, 0x67, 0xb7, 0xd1, 0x5c, 0x26, 0x4e, 0xba, 0xa5, 0xe9, 0x4f, 0xda, 0x60, 0xfc, 0xb1, 0xe6,
-	0x91, 0xce, 0x53, 0x27, 0xe5, 0x0b, 0x38, 0x42, 0xd3, 0x60, 0x33, 0x8b, 0x98, 0x53, 0x69, 0xbe,
-	0x70, 0x9f, 0x59, 0xd0, 0x30, 0x24, 0xe2, 0x40, 0x5d, 0x69, 0x26, 0x35, 0x86, 0xa9, 0x0e, 0xd6,
-	0x97, 0x89, 0x63, 0x00, 0x6a, 0x3e, 0x69, 0x16, 0x3c, 0xf2, 0xf0, 0xc1, 0x55, 0x4d, 0x16, 0x3c,
-	0xf2, 0x68, 0xfa, 0x43, 0x7a, 0xd0, 0xd2, 0x92, 0x8d, 0xf9, 0xb9, 0xef, 0x65, 0x8f, 0x22, 0xef,
-	0x64, 0x84, 0x4f, 0x3c, 0xf2, 0x29, 0xb4, 0x64, 0x76, 0x9c, 0x6e, 0x1d, 0x9f, 0xec, 0x76, 0xdf,
-	0x4c, 0x99, 0x7e, 0x3e, 0x65, 0xfa, 0x77, 0xa3, 0xf9, 0xe0, 0xd6, 0x32, 0x71, 0x0a, 0x26, 0x2d,
-	0x56, 0xe4, 0x36, 0x10, 0x3c, 0xd7, 0xb9, 0xf6, 0x43, 0xae, 0x34, 0x0b, 0xe3, 0xf3, 0x30, 0x7d,
-	0x13, 0xd6, 0x5e, 0x95, 0x76, 0xd0, 0x72, 0x96, 0x1b, 0xbe, 0x52, 0x5f, 0xd4, 0x5a, 0xd5, 0x4e,
-	0xcd, 0xfd, 0xcb, 0x82, 0xe6, 0x37, 0xb1, 0xf6, 0x45, 0xa4, 0xc8, 0x5b, 0xf0, 0x3f, 0x2c, 0xea,
-	0x3d, 0x5f, 0xb1, 0x51, 0xc0, 0x3d, 0x3c, 0x65, 0x8b, 0xbe, 0x08, 0x92, 0x77, 0xa0, 0x33, 0x9c,
-	0x32, 0xe9, 0xf9, 0xd1, 0xa4, 0x20, 0xae, 0x21, 0xf1, 0x15, 0x9c, 0xf4, 0xa0, 0x7d, 0x26, 0x34,
-	0x0b, 0xd0, 0xa0, 0x70, 0x7c, 0xd4, 0x69, 0x19, 0x22, 0x07, 0xb0, 0x7d, 0x12, 0x29, 0xcd, 0x22,
-	0x3d, 0x8c, 0x03, 0x5f, 0x17, 0x1e, 0x6b, 0xe8, 0xf1, 0x46, 0xdb, 0xcb, 0x9a, 0x93, 0x48, 0x73,
-	0xf9, 0x90, 0x05, 0x58, 0xb3, 0x2a, 0xbd, 0xd1, 0xe6, 0x1e, 0xc1, 0xe6, 0xb7, 0x69, 0x05, 0xd2,
-	0xc9, 0xe8, 0x2b, 0xed, 0x8f, 0x31, 0xf4, 0x91, 0xd2, 0x7e, 0xc8, 0x34, 0xf7, 0x86, 0x5c, 0xfa,
-	0x5c, 0x1d, 0x8a, 0x59, 0x64, 0xee, 0xb6, 0x46, 0x6f, 0xb4, 0xb9, 0xbf, 0x5b, 0x40, 0x4c, 0xe3,
-	0x1d, 0x9f, 0x9d, 0x9d, 0x16, 0xcd, 0xf7, 0x3a, 0xac, 0x8f, 0x53, 0xf4, 0xbc, 0x68, 0x41, 0xda,
-	0x42, 0xe0, 0x4b, 0x3e, 0x27, 0x0e, 0xb4, 0xcd, 0x5c, 0x3e, 0x1f, 0x0b, 0x8f, 0x63, 0xad, 0xea,
-	0x14, 0x0c, 0x74, 0x28, 0x3c, 0x4e, 0x3e, 0x84, 0xe6, 0x34, 0x1b, 0x80, 0xd5, 0x57, 0x07, 0xe0,
-	0x2a, 0x9c, 0x99, 0x78, 0x34, 0x27, 0x13, 0x02, 0xb5, 0x91, 0xf0, 0xe6, 0x58, 0xab, 0x5b, 0x14,
-	0xd7, 0xee, 0xc7, 0xd0, 0x79, 0x59, 0x90, 0xf2, 0xa2, 0xe2, 0xbf, 0x87, 0xe2, 0x9a, 0x6c, 0x43,
-	0x1d, 0x5f, 0x29, 0xa6, 0xb3, 0x4e, 0xcd, 0x66, 0x70, 0x74, 0x79, 0x65, 0x57, 0x9e, 0x5e, 0xd9,
-	0x95, 0xe7, 0x57, 0xb6, 0xf5, 0xf3, 0xc2, 0xb6, 0xfe, 0x58, 0xd8, 0xd6, 0x93, 0x85, 0x6d, 0x5d,
-	0x2e, 0x6c, 0xeb, 0xcf, 0x85, 0x6d, 0xfd, 0xbd, 0xb0, 0x2b, 0xcf, 0x17, 0xb6, 0xf5, 0xdb, 0xb5,
-	0x5d, 0xb9, 0xbc, 0xb6, 0x2b, 0x4f, 0xaf, 0xed, 0xca, 0x0f, 0x9b, 0x98, 0x6d, 0xe8, 0x7b, 0x5e,
-	0xc0, 0x1f, 0x31, 0xc9, 0x47, 0x0d, 0x6c, 0xd7, 0xf7, 0xff, 0x0d, 0x00, 0x00, 0xff, 0xff, 0xab,
-	0x90, 0x68, 0x82, 0x9e, 0x07, 0x00, 0x00,
+	// 1116 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x55, 0x4f, 0x6f, 0x1b, 0x45,
+	0x14, 0xf7, 0xfa, 0x7f, 0x9e, 0x43, 0x62, 0x4d, 0x52, 0xea, 0x04, 0xd8, 0xb5, 0x16, 0x0e, 0xa1,
+	0x6a, 0x1d, 0x6a, 0x04, 0x07, 0x04, 0x88, 0x3a, 0x0d, 0x4a, 0xa0, 0xb4, 0xe9, 0x38, 0x02, 0x89,
+	0x4b, 0x34, 0xf6, 0x4e, 0xec, 0x25, 0xfb, 0x8f, 0xd9, 0x71, 0x5b, 0xdf, 0xf8, 0x04, 0x88, 0x4f,
+	0xc0, 0x89, 0x03, 0x1f, 0xa5, 0xc7, 0x88, 0x53, 0xd5, 0xc3, 0x8a, 0x38, 0x42, 0x42, 0x7b, 0xea,
+	0x07, 0xe0, 0x80, 0xf6, 0xcd, 0xae, 0xbd, 0x69, 0xcd, 0x9f, 0xcb, 0xee, 0xcc, 0xef, 0xfd, 0xde,
+	0x9f, 0x79, 0xef, 0xcd, 0x1b, 0x68, 0xb8, 0xbe, 0xc5, 0x9d, 0x4e, 0x20, 0x7c, 0xe9, 0x13, 0xf8,
+	0x7e, 0xc2, 0xc5, 0x54, 0x30, 0x6f, 0xc4, 0xb7, 0x6f, 0x8d, 0x6c, 0x39, 0x9e, 0x0c, 0x3a, 0x43,
+	0xdf, 0xdd, 0x1d, 0xf9, 0x23, 0x7f, 0x17, 0x29, 0x83, 0xc9, 0x29, 0xee, 0x70, 0x83, 0x2b, 0xa5,
+	0xba, 0xfd, 0x5e, 0x9e, 0x2e, 0xd8, 0x29, 0xf3, 0xd8, 0xae, 0x6b, 0xbb, 0xb6, 0xd8, 0x0d, 0xce,
+	0x46, 0x6a, 0x15, 0x0c, 0xd4, 0x3f, 0xd5, 0xd8, 0x1a, 0xf9, 0xfe, 0xc8, 0xe1, 0x0b, 0xbb, 0xcc,
+	0x9b, 0x2a, 0x91, 0x79, 0x0f, 0x9a, 0x47, 0xc2, 0x77, 0xb9, 0x1c, 0xf3, 0x49, 0x78, 0xc0, 0x99,
+	0xc5, 0x05, 0xd9, 0x82, 0xf2, 0x7d, 0xe6, 0xf2, 0x96, 0xd6, 0xd6, 0x76, 0x56, 0x7a, 0x95, 0x38,
+	0x32, 0xb4, 0x5b, 0x14, 0x21, 0xf2, 0x16, 0x54, 0xbf, 0x66, 0xce, 0x84, 0x87, 0xad, 0x62, 0xbb,
+	0xb4, 0x10, 0xa6, 0xa0, 0xf9, 0x57, 0x11, 0xc8, 0xc2, 0x1c, 0xe5, 0x61, 0xe0, 0x7b, 0x21, 0x27,
+	0x26, 0x54, 0xfb, 0x92, 0xc9, 0x49, 0x98, 0x9a, 0x84, 0x38, 0x32, 0xaa, 0x21, 0x22, 0x34, 0x95,
+	0x90, 0x1e, 0x94, 0xef, 0x32, 0xc9, 0x5a, 0xc5, 0xb6, 0xb6, 0xd3, 0xe8, 0x6e, 0x77, 0x16, 0xf9,
+	0xe9, 0x2c, 0x2c, 0x26, 0x8c, 0x1e, 0x89, 0x23, 0x63, 0xcd, 0x62, 0x92, 0xdd, 0xf4, 0x5d, 0x5b,
+	0x72, 0x37, 0x90, 0x53, 0x8a, 0xba, 0xe4, 0x03, 0x58, 0xd9, 0x17, 0xc2, 0x17, 0xc7, 0xd3, 0x80,
+	0xb7, 0x4a, 0xe8, 0xea, 0x7a, 0x1c, 0x19, 0x1b, 0x3c, 0x03, 0x73, 0x1a, 0x0b, 0x26, 0x79, 0x17,
+	0x2a, 0xb8, 0x69, 0x95, 0x51, 0x65, 0x23, 0x8e, 0x8c, 0x75, 0x54, 0xc9, 0xd1, 0x15, 0x83, 0x7c,
+	0x02, 0x35, 0x95, 0xa4, 0xb0, 0x55, 0x69, 0x97, 0x76, 0x1a, 0xdd, 0x37, 0x97, 0x07, 0xaa, 0x48,
+	0x59, 0x7a, 0x32, 0x1d, 0xd2, 0x85, 0xfa, 0x37, 0x4c, 0x78, 0xb6, 0x37, 0x0a, 0x5b, 0x55, 0x4c,
+	0xe0, 0xeb, 0x71, 0x64, 0x90, 0xc7, 0x29, 0x96, 0xf3, 0x37, 0xe7, 0x25, 0xd1, 0x1d, 0x7a, 0xa7,
+	0x7e, 0xd8, 0xaa, 0xb5, 0x4b, 0x59, 0x74, 0x76, 0x02, 0xe4, 0xa3, 0x43, 0x86, 0xf9, 0x9b, 0x06,
+	0x6b, 0x57, 0x93, 0x45, 0x3a, 0x00, 0x94, 0x87, 0x13, 0x47, 0x62, 0x4e, 0x54, 0xfa, 0xd7, 0xe2,
+	0xc8, 0x00, 0x31, 0x47, 0x69, 0x8e, 0x41, 0x3e, 0x83, 0xaa, 0xda, 0x61, 0x81, 0x1b, 0xdd, 0x56,
+	0xfe, 0x7c, 0x7d, 0xe6, 0x06, 0x0e, 0xef, 0x4b, 0xc1, 0x99, 0xdb, 0x5b, 0x7b, 0x1a, 0x19, 0x85,
+	0xa4, 0x90, 0xca, 0x12, 0x4d, 0xf5, 0xc8, 0x7d, 0xa8, 0x24, 0x25, 0x0d, 0xb1, 0x00, 0x8d, 0xee,
+	0xdb, 0xcb, 0x13, 0x94, 0xf5, 0x06, 0x52, 0xd5, 0xa1, 0x92, 0x86, 0xb8, 0x72, 0x28, 0x94, 0x99,
+	0x0e, 0x5c, 0xff, 0x07, 0x35, 0xf2, 0x10, 0x6a, 0x2a, 0x24, 0xd5, 0x58, 0x8d, 0xee, 0x8d, 0xff,
+	0x70, 0xa6, 0xc8, 0xca, 0x67, 0x23, 0x8e, 0x8c, 0x5a, 0xa8, 0x10, 0x9a, 0xd9, 0x31, 0x7f, 0xd1,
+	0x40, 0xff, 0x77, 0x45, 0xf2, 0x00, 0xae, 0x1d, 0xfb, 0x92, 0x39, 0x0f, 0x13, 0x57, 0x6c, 0xe0,
+	0xf0, 0x7c, 0x0c, 0xa5, 0xde, 0x56, 0x1c, 0x19, 0xd7, 0xe4, 0x32, 0x02, 0x5d, 0xae, 0x47, 0x6e,
+	0x43, 0xe3, 0x88, 0xb3, 0xb3, 0xcc, 0x4c, 0x11, 0xcd, 0xac, 0xc7, 0x91, 0xd1, 0x08, 0x16, 0x30,
+	0xcd, 0x73, 0xcc, 0x1f, 0x8b, 0xb0, 0x9a, 0xaf, 0x06, 0x09, 0xa0, 0xea, 0xb0, 0x01, 0x77, 0x92,
+	0x28, 0x92, 0xba, 0x6d, 0x74, 0x86, 0xbe, 0x90, 0xfc, 0x49, 0x30, 0xe8, 0xdc, 0x4b, 0xf0, 0x23,
+	0x66, 0x8b, 0xde, 0x5e, 0x52, 0xb2, 0xe7, 0x91, 0x71, 0xfb, 0xff, 0x4c, 0x10, 0xa5, 0x77, 0xc7,
+	0x62, 0x81, 0xe4, 0x22, 0xa9, 0xb3, 0xcb, 0xa5, 0xb0, 0x87, 0x34, 0xf5, 0x43, 0x3e, 0x82, 0x2c,
+	0x7b, 0x69, 0xab, 0x34, 0x17, 0x2e, 0x55, 0x68, 0x8b, 0x16, 0x79, 0x84, 0xe3, 0x81, 0x66, 0x0a,
+	0xe4, 0x08, 0x60, 0x6c, 0x87, 0xd2, 0x1f, 0x09, 0xe6, 0x26, 0x8d, 0xa2, 0x6e, 0xd2, 0x5c, 0xfd,
+	0x73, 0xc7, 0x67, 0xf2, 0x20, 0x23, 0x60, 0xe8, 0x24, 0x35, 0x95, 0xd3, 0xa3, 0xb9, 0xb5, 0xf9,
+	0x1d, 0xac, 0xed, 0xb1, 0xe1, 0x98, 0x5b, 0xf3, 0xa1, 0xb3, 0x05, 0xa5, 0x33, 0x3e, 0x4d, 0x5b,
+	0xbe, 0x16, 0x47, 0x46, 0xb2, 0xa5, 0xc9, 0x27, 0xb9, 0xc5, 0xfc, 0x89, 0xe4, 0x9e, 0xcc, 0x42,
+	0x27, 0xf9, 0xbe, 0xd9, 0x47, 0x51, 0x6f, 0x3d, 0xf5, 0x98, 0x51, 0x69, 0xb6, 0x30, 0x9f, 0x6b,
+	0x50, 0x55, 0x24, 0x62, 0x40, 0x25, 0x94, 0x4c, 0xc8, 0xb4, 0xf6, 0x2b, 0x71, 0x64, 0x28, 0x80,
+	0xaa, 0x5f, 0x12, 0x05, 0xf7, 0xac, 0xb4, 0xa6, 0x18, 0x05, 0xf7, 0x2c, 0x9a, 0x7c, 0x48, 0x1b,
+	0xea, 0x52, 0xb0, 0x21, 0x3f, 0xb1, 0xad, 0x74, 0xf2, 0x64, 0xe3, 0x02, 0xe1, 0x43, 0x8b, 0x7c,
+	0x0a, 0x75, 0x91, 0x1e, 0xa7, 0x55, 0xc1, 0x06, 0xdf, 0xec, 0xa8, 0x51, 0xde, 0xc9, 0x46, 0x79,
+	0xe7, 0x8e, 0x37, 0xed, 0xad, 0xc6, 0x91, 0x31, 0x67, 0xd2, 0xf9, 0x8a, 0xdc, 0x04, 0x82, 0xe7,
+	0x3a, 0x91, 0xb6, 0xcb, 0x43, 0xc9, 0xdc, 0xe0, 0xc4, 0x4d, 0x06, 0x8f, 0xb6, 0x53, 0xa2, 0x4d,
+	0x94, 0x1c, 0x67, 0x82, 0xaf, 0xc2, 0x2f, 0xca, 0xf5, 0x52, 0xb3, 0x6c, 0xfe, 0xa1, 0x41, 0xed,
+	0x41, 0x20, 0x6d, 0xdf, 0x0b, 0xc9, 0x3b, 0xf0, 0x1a, 0x26, 0xf5, 0xae, 0x1d, 0x26, 0xfd, 0x6a,
+	0xe1, 0x29, 0xeb, 0xf4, 0x2a, 0x48, 0x6e, 0x40, 0xb3, 0x3f, 0x66, 0xc2, 0xb2, 0xbd, 0xd1, 0x9c,
+	0x58, 0x44, 0xe2, 0x2b, 0x38, 0x69, 0x43, 0x03, 0xef, 0x00, 0x0a, 0xd4, 0x88, 0xa8, 0xd0, 0x3c,
+	0x44, 0xba, 0xb0, 0x79, 0xe8, 0x85, 0x92, 0x79, 0xb2, 0x1f, 0x38, 0xb6, 0x9c, 0x5b, 0x2c, 0xa3,
+	0xc5, 0xa5, 0xb2, 0x97, 0x75, 0x0e, 0x3d, 0xc9, 0xc5, 0x23, 0xe6, 0x60, 0xce, 0x4a, 0x74, 0xa9,
+	0xcc, 0xdc, 0x87, 0x75, 0xbc, 0x88, 0xc9, 0x9d, 0xb6, 0x43, 0x69, 0x0f, 0xd1, 0xf5, 0x7e, 0x28,
+	0x6d, 0x97, 0x49, 0x6e, 0xf5, 0xb9, 0xb0, 0x79, 0xb8, 0xe7, 0x4f, 0x3c, 0x55, 0xdb, 0x32, 0x5d,
+	0x2a, 0x33, 0x7f, 0xd6, 0x80, 0xa8, 0xc6, 0x3b, 0x38, 0x3e, 0x3e, 0x9a, 0x37, 0xdf, 0x1b, 0xb0,
+	0x32, 0x4c, 0xd0, 0x93, 0x79, 0x0b, 0xd2, 0x3a, 0x02, 0x5f, 0xf2, 0x29, 0x31, 0xa0, 0xa1, 0x1e,
+	0xbf, 0x93, 0xa1, 0x6f, 0x71, 0xcc, 0x55, 0x85, 0x82, 0x82, 0xf6, 0x7c, 0x8b, 0x93, 0x0f, 0xa1,
+	0x36, 0x4e, 0x5f, 0x99, 0xd2, 0xab, 0xaf, 0xcc, 0xc2, 0x9d, 0x7a, 0x56, 0x68, 0x46, 0x26, 0x04,
+	0xca, 0x03, 0xdf, 0x9a, 0x62, 0xae, 0x56, 0x29, 0xae, 0xcd, 0x8f, 0xa1, 0xf9, 0xb2, 0x42, 0xc2,
+	0xf3, 0xe6, 0x0f, 0x3c, 0xc5, 0x35, 0xd9, 0x84, 0x0a, 0xde, 0x52, 0x0c, 0x67, 0x85, 0xaa, 0x4d,
+	0x6f, 0xff, 0xfc, 0x42, 0x2f, 0x3c, 0xbb, 0xd0, 0x0b, 0x2f, 0x2e, 0x74, 0xed, 0x87, 0x99, 0xae,
+	0xfd, 0x3a, 0xd3, 0xb5, 0xa7, 0x33, 0x5d, 0x3b, 0x9f, 0xe9, 0xda, 0xef, 0x33, 0x5d, 0xfb, 0x73,
+	0xa6, 0x17, 0x5e, 0xcc, 0x74, 0xed, 0xa7, 0x4b, 0xbd, 0x70, 0x7e, 0xa9, 0x17, 0x9e, 0x5d, 0xea,
+	0x85, 0x6f, 0xd7, 0x31, 0x5a, 0xd7, 0xb6, 0x2c, 0x87, 0x3f, 0x66, 0x82, 0x0f, 0xaa, 0xd8, 0xae,
+	0xef, 0xff, 0x3d, 0x00, 0x5a, 0xe7, 0x6a, 0x51, 0x03, 0x09, 0x00, 0x00,
 }
 
 func (this *PrometheusHeader) Equal(that interface{}) bool {
@@ -852,6 +964,60 @@ func (this *PrometheusData) Equal(that interface{}) bool {
 			return false
 		}
 	}
+	if !this.Stats.Equal(that1.Stats) {
+		return false
+	}
+	return true
+}
+func (this *PrometheusResponseStats) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*PrometheusResponseStats)
+	if !ok {
+		that2, ok := that.(PrometheusResponseStats)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !this.Samples.Equal(that1.Samples) {
+		return false
+	}
+	return true
+}
+func (this *PrometheusResponseSamplesStats) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*PrometheusResponseSamplesStats)
+	if !ok {
+		that2, ok := that.(PrometheusResponseSamplesStats)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.TotalQueryableSamples != that1.TotalQueryableSamples {
+		return false
+	}
+	if this.PeakSamples != that1.PeakSamples {
+		return false
+	}
 	return true
 }
 func (this *SampleStream) Equal(that interface{}) bool {
@@ -1127,7 +1293,7 @@ func (this *PrometheusData) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 6)
+	s := make([]string, 0, 7)
 	s = append(s, "&querymiddleware.PrometheusData{")
 	s = append(s, "ResultType: "+fmt.Sprintf("%#v", this.ResultType)+",\n")
 	if this.Result != nil {
@@ -1137,6 +1303,32 @@ func (this *PrometheusData) GoString() string {
 		}
 		s = append(s, "Result: "+fmt.Sprintf("%#v", vs)+",\n")
 	}
+	if this.Stats != nil {
+		s = append(s, "Stats: "+fmt.Sprintf("%#v", this.Stats)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *PrometheusResponseStats) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&querymiddleware.PrometheusResponseStats{")
+	if this.Samples != nil {
+		s = append(s, "Samples: "+fmt.Sprintf("%#v", this.Samples)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *PrometheusResponseSamplesStats) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&querymiddleware.PrometheusResponseSamplesStats{")
+	s = append(s, "TotalQueryableSamples: "+fmt.Sprintf("%#v", this.TotalQueryableSamples)+",\n")
+	s = append(s, "PeakSamples: "+fmt.Sprintf("%#v", this.PeakSamples)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -1402,6 +1594,18 @@ func (m *PrometheusData) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.Stats != nil {
+		{
+			size, err := m.Stats.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintModel(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x1a
+	}
 	if len(m.Result) > 0 {
 		for iNdEx := len(m.Result) - 1; iNdEx >= 0; iNdEx-- {
 			{
@@ -1426,6 +1630,74 @@ func (m *PrometheusData) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *PrometheusResponseStats) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PrometheusResponseStats) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *PrometheusResponseStats) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Samples != nil {
+		{
+			size, err := m.Samples.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintModel(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *PrometheusResponseSamplesStats) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PrometheusResponseSamplesStats) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *PrometheusResponseSamplesStats) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.PeakSamples != 0 {
+		i = encodeVarintModel(dAtA, i, uint64(m.PeakSamples))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.TotalQueryableSamples != 0 {
+		i = encodeVarintModel(dAtA, i, uint64(m.TotalQueryableSamples))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
 func (m *SampleStream) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -1865,6 +2137,38 @@ func (m *PrometheusData) Size() (n int) {
 			n += 1 + l + sovModel(uint64(l))
 		}
 	}
+	if m.Stats != nil {
+		l = m.Stats.Size()
+		n += 1 + l + sovModel(uint64(l))
+	}
+	return n
+}
+
+func (m *PrometheusResponseStats) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Samples != nil {
+		l = m.Samples.Size()
+		n += 1 + l + sovModel(uint64(l))
+	}
+	return n
+}
+
+func (m *PrometheusResponseSamplesStats) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.TotalQueryableSamples != 0 {
+		n += 1 + sovModel(uint64(m.TotalQueryableSamples))
+	}
+	if m.PeakSamples != 0 {
+		n += 1 + sovModel(uint64(m.PeakSamples))
+	}
 	return n
 }
 
@@ -2069,6 +2373,28 @@ func (this *PrometheusData) String() string {
 	s := strings.Join([]string{`&PrometheusData{`,
 		`ResultType:` + fmt.Sprintf("%v", this.ResultType) + `,`,
 		`Result:` + repeatedStringForResult + `,`,
+		`Stats:` + strings.Replace(this.Stats.String(), "PrometheusResponseStats", "PrometheusResponseStats", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *PrometheusResponseStats) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&PrometheusResponseStats{`,
+		`Samples:` + strings.Replace(this.Samples.String(), "PrometheusResponseSamplesStats", "PrometheusResponseSamplesStats", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *PrometheusResponseSamplesStats) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&PrometheusResponseSamplesStats{`,
+		`TotalQueryableSamples:` + fmt.Sprintf("%v", this.TotalQueryableSamples) + `,`,
+		`PeakSamples:` + fmt.Sprintf("%v", this.PeakSamples) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -2681,6 +3007,222 @@ func (m *PrometheusData) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Stats", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowModel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthModel
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthModel
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Stats == nil {
+				m.Stats = &PrometheusResponseStats{}
+			}
+			if err := m.Stats.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipModel(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthModel
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthModel
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *PrometheusResponseStats) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowModel
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: PrometheusResponseStats: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: PrometheusResponseStats: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Samples", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowModel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthModel
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthModel
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Samples == nil {
+				m.Samples = &PrometheusResponseSamplesStats{}
+			}
+			if err := m.Samples.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipModel(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthModel
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthModel
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *PrometheusResponseSamplesStats) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowModel
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: PrometheusResponseSamplesStats: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: PrometheusResponseSamplesStats: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TotalQueryableSamples", wireType)
+			}
+			m.TotalQueryableSamples = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowModel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TotalQueryableSamples |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PeakSamples", wireType)
+			}
+			m.PeakSamples = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowModel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.PeakSamples |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipModel(dAtA[iNdEx:])
@@ -628,29 +628,35 @@ func (d *PrometheusData) MarshalJSON() ([]byte, error) {
 	switch d.ResultType {
 	case model.ValString.String():
 		return json.Marshal(struct {
-			Type   model.ValueType     `json:"resultType"`
-			Result stringSampleStreams `json:"result"`
+			Type   model.ValueType          `json:"resultType"`
+			Result stringSampleStreams      `json:"result"`
+			Stats  *PrometheusResponseStats `json:"stats,omitempty"`
 		}{
 			Type:   model.ValString,
 			Result: d.Result,
+			Stats:  d.Stats,
 		})
 
 	case model.ValScalar.String():
 		return json.Marshal(struct {
-			Type   model.ValueType     `json:"resultType"`
-			Result scalarSampleStreams `json:"result"`
+			Type   model.ValueType          `json:"resultType"`
+			Result scalarSampleStreams      `json:"result"`
+			Stats  *PrometheusResponseStats `json:"stats,omitempty"`
 		}{
 			Type:   model.ValScalar,
 			Result: d.Result,
+			Stats:  d.Stats,
 		})
 
 	case model.ValVector.String():
 		return json.Marshal(struct {
-			Type   model.ValueType      `json:"resultType"`
-			Result []vectorSampleStream `json:"result"`
+			Type   model.ValueType          `json:"resultType"`
+			Result []vectorSampleStream     `json:"result"`
+			Stats  *PrometheusResponseStats `json:"stats,omitempty"`
 		}{
 			Type:   model.ValVector,
 			Result: asVectorSampleStreams(d.Result),
+			Stats:  d.Stats,
 		})
 
 	case model.ValMatrix.String():
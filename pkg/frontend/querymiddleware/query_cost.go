@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gogo/protobuf/proto"
+	"github.com/grafana/dskit/cache"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+const (
+	// estimatedQueryCostHeader is the response header containing the estimated cost of the query.
+	estimatedQueryCostHeader = "X-Estimated-Query-Cost"
+
+	// assumedSampleInterval is the interval between samples assumed when estimating the number of samples
+	// selected by a range vector selector or a subquery with no explicit step.
+	assumedSampleInterval = time.Minute
+)
+
+// queryCostMiddleware is a MetricsQueryMiddleware that estimates the cost of each query before it is executed, and
+// rejects queries with an estimated cost greater than the tenant's limit.
+//
+// The cost is the estimated number of samples processed by the query. It's computed from the number of series
+// selected by previous executions of the query, as tracked by cardinalityEstimation, and the query's time range,
+// step and expression. If no estimate of the number of series is available, each selector of the query is assumed
+// to select a single series, so the cost only depends on the query's time range, step and expression.
+//
+// The cost is only estimated for tenants with a limit.
+type queryCostMiddleware struct {
+	next          MetricsQueryHandler
+	limits        Limits
+	cache         cache.Cache
+	splitInterval time.Duration
+	logger        log.Logger
+
+	rejectedQueries *prometheus.CounterVec
+}
+
+// newQueryCostMiddleware makes a new queryCostMiddleware. cache is the cache of the estimates of the number of series
+// selected by queries, or nil if they are not tracked. splitInterval must be the interval that range queries are
+// split by before their number of series is estimated, or 0 if they are not split.
+func newQueryCostMiddleware(cache cache.Cache, splitInterval time.Duration, limits Limits, logger log.Logger, registerer prometheus.Registerer) MetricsQueryMiddleware {
+	rejectedQueries := promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_query_frontend_expensive_queries_rejected_total",
+		Help: "Number of queries that were rejected because their estimated cost exceeded the limit.",
+	}, []string{"user"})
+
+	return MetricsQueryMiddlewareFunc(func(next MetricsQueryHandler) MetricsQueryHandler {
+		return &queryCostMiddleware{
+			next:            next,
+			limits:          limits,
+			cache:           cache,
+			splitInterval:   splitInterval,
+			logger:          logger,
+			rejectedQueries: rejectedQueries,
+		}
+	})
+}
+
+func (q *queryCostMiddleware) Do(ctx context.Context, req MetricsQueryRequest) (Response, error) {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return q.next.Do(ctx, req)
+	}
+
+	maxCost := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, q.limits.MaxEstimatedQueryCost)
+	if maxCost <= 0 {
+		return q.next.Do(ctx, req)
+	}
+
+	cost, ok := q.estimateCost(ctx, tenant.JoinTenantIDs(tenantIDs), req)
+	if !ok {
+		return q.next.Do(ctx, req)
+	}
+
+	spanLog := spanlogger.FromContext(ctx, q.logger)
+	level.Debug(spanLog).Log("msg", "estimated query cost", "query", req.GetQuery(), "estimated_cost", cost)
+
+	if cost > uint64(maxCost) {
+		for _, tenantID := range tenantIDs {
+			q.rejectedQueries.WithLabelValues(tenantID).Inc()
+		}
+
+		return nil, newMaxEstimatedQueryCostError(cost, maxCost)
+	}
+
+	resp, err := q.next.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return withEstimatedQueryCostHeader(resp, cost), nil
+}
+
+// estimateCost returns the estimated cost of req, and false if the cost can't be estimated because the query
+// can't be parsed.
+//
+// Range queries are split in the same way as they are before their number of series is estimated, and the cost
+// is the sum of the cost of each split query.
+func (q *queryCostMiddleware) estimateCost(ctx context.Context, tenantID string, req MetricsQueryRequest) (uint64, bool) {
+	expr, err := parser.ParseExpr(req.GetQuery())
+	if err != nil {
+		return 0, false
+	}
+
+	reqs := []MetricsQueryRequest{req}
+	if q.splitInterval > 0 && IsRangeQuery(req.GetPath()) {
+		if reqs, err = splitQueryByInterval(req, q.splitInterval); err != nil {
+			return 0, false
+		}
+	}
+
+	keys := make([]string, 0, len(reqs))
+	for _, r := range reqs {
+		keys = append(keys, generateCardinalityEstimationCacheKey(tenantID, r, cardinalityEstimateBucketSize))
+	}
+
+	var found map[string][]byte
+	if q.cache != nil {
+		found = q.cache.GetMulti(ctx, keys)
+	}
+
+	samplesPerSeriesPerStep := estimateSamplesPerSeriesPerStep(expr)
+	samplesPerStep, _ := estimateSamplesPerStep(expr)
+	cost := 0.0
+
+	for i, r := range reqs {
+		if seriesCount, ok := q.estimatedSeriesCount(found[keys[i]]); ok {
+			cost += float64(seriesCount) * float64(stepsCount(r)) * samplesPerSeriesPerStep
+			continue
+		}
+
+		// Without an estimate of the number of series, each selector is assumed to select a single series.
+		cost += float64(stepsCount(r)) * samplesPerStep
+	}
+
+	return uint64(math.Ceil(cost)), true
+}
+
+// estimatedSeriesCount returns the estimated number of series decoded from the cached cardinality estimate val,
+// and false if no estimate is available.
+func (q *queryCostMiddleware) estimatedSeriesCount(val []byte) (uint64, bool) {
+	if val == nil {
+		return 0, false
+	}
+
+	qs := &QueryStatistics{}
+	if err := proto.Unmarshal(val, qs); err != nil {
+		level.Warn(q.logger).Log("msg", "failed to unmarshal cardinality estimate")
+		return 0, false
+	}
+
+	return qs.EstimatedSeriesCount, true
+}
+
+// stepsCount returns the number of steps req is evaluated at.
+func stepsCount(req MetricsQueryRequest) int64 {
+	if req.GetStep() <= 0 {
+		return 1
+	}
+
+	return (req.GetEnd()-req.GetStart())/req.GetStep() + 1
+}
+
+// estimateSamplesPerSeriesPerStep returns the average number of samples expr is expected to process for each
+// selected series at each step, based on the shape of the expression.
+func estimateSamplesPerSeriesPerStep(expr parser.Expr) float64 {
+	samples, selectors := estimateSamplesPerStep(expr)
+	if selectors == 0 {
+		return 0
+	}
+
+	return samples / float64(selectors)
+}
+
+// estimateSamplesPerStep returns the number of samples expr is expected to process at each step, assuming each
+// selector selects a single series, and the number of selectors in expr.
+func estimateSamplesPerStep(expr parser.Node) (samples float64, selectors int) {
+	switch e := expr.(type) {
+	case *parser.VectorSelector:
+		return 1, 1
+	case *parser.MatrixSelector:
+		return max(1, float64(e.Range)/float64(assumedSampleInterval)), 1
+	case *parser.SubqueryExpr:
+		step := e.Step
+		if step == 0 {
+			step = assumedSampleInterval
+		}
+
+		samples, selectors = estimateSamplesPerStep(e.Expr)
+		return samples * max(1, float64(e.Range)/float64(step)), selectors
+	}
+
+	for _, child := range parser.Children(expr) {
+		childSamples, childSelectors := estimateSamplesPerStep(child)
+		samples += childSamples
+		selectors += childSelectors
+	}
+
+	return samples, selectors
+}
+
+// withEstimatedQueryCostHeader returns resp with the estimated cost of the query added to its headers.
+func withEstimatedQueryCostHeader(resp Response, cost uint64) Response {
+	return withResponseHeader(resp, estimatedQueryCostHeader, []string{strconv.FormatUint(cost, 10)})
+}
+
+// withResponseHeader returns resp with the given header added to its headers.
+// resp itself is not modified, as it may be shared with other requests.
+func withResponseHeader(resp Response, name string, values []string) Response {
+	promResp, ok := resp.(*PrometheusResponse)
+	if !ok {
+		return resp
+	}
+
+	withHeader := *promResp
+	withHeader.Headers = make([]*PrometheusHeader, 0, len(promResp.Headers)+1)
+	withHeader.Headers = append(withHeader.Headers, promResp.Headers...)
+	withHeader.Headers = append(withHeader.Headers, &PrometheusHeader{Name: name, Values: values})
+
+	return &withHeader
+}
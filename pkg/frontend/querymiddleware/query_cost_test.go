@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/grafana/dskit/cache"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+)
+
+func TestEstimateSamplesPerSeriesPerStep(t *testing.T) {
+	for query, expected := range map[string]float64{
+		`up`:                                           1,
+		`sum by (job) (up)`:                            1,
+		`rate(some_metric[5m])`:                        5,
+		`rate(some_metric[30s])`:                       1,
+		`sum(rate(some_metric[5m])) / sum(up)`:         3,
+		`max_over_time(some_metric[10m:])`:             10,
+		`max_over_time(rate(some_metric[5m])[10m:2m])`: 25,
+		`vector(1)`:                                    0,
+		`label_replace(up, "foo", "$1", "bar", "(.*)") + 1`: 1,
+	} {
+		t.Run(query, func(t *testing.T) {
+			require.Equal(t, expected, estimateSamplesPerSeriesPerStep(parseQuery(t, query)))
+		})
+	}
+}
+
+func TestQueryCostMiddleware(t *testing.T) {
+	const (
+		tenantID = "user-1"
+		query    = "sum(rate(some_metric[5m]))"
+	)
+
+	// A two hour range query, with 121 steps.
+	request := NewPrometheusRangeQueryRequest(queryRangePathSuffix, nil, 0, (2 * time.Hour).Milliseconds(), time.Minute.Milliseconds(), 0, parseQuery(t, query), Options{}, nil)
+
+	// The same query, split by one hour intervals into queries with 60 and 61 steps.
+	splitRequests, err := splitQueryByInterval(request, time.Hour)
+	require.NoError(t, err)
+	require.Len(t, splitRequests, 2)
+
+	estimate := func(series uint64) []byte {
+		b, err := proto.Marshal(&QueryStatistics{EstimatedSeriesCount: series})
+		require.NoError(t, err)
+		return b
+	}
+
+	keyFor := func(r MetricsQueryRequest) string {
+		return generateCardinalityEstimationCacheKey(tenantID, r, cardinalityEstimateBucketSize)
+	}
+
+	tests := map[string]struct {
+		noCache          bool
+		splitInterval    time.Duration
+		cacheContent     map[string][]byte
+		maxCost          int
+		expectedCost     string
+		expectedRejected bool
+	}{
+		"no limit": {
+			// The cost is not estimated.
+			cacheContent: map[string][]byte{keyFor(request): estimate(10)},
+		},
+		"no estimate available, below limit": {
+			maxCost:      605,
+			expectedCost: "605", // 121 steps * 5 samples per step of the single series assumed to be selected.
+		},
+		"no estimate available, above limit": {
+			maxCost:          604,
+			expectedRejected: true,
+		},
+		"no cache, above limit": {
+			noCache:          true,
+			maxCost:          604,
+			expectedRejected: true,
+		},
+		"estimate available, below limit": {
+			cacheContent: map[string][]byte{keyFor(request): estimate(10)},
+			maxCost:      6050,
+			expectedCost: "6050",
+		},
+		"estimate available, above limit": {
+			cacheContent:     map[string][]byte{keyFor(request): estimate(10)},
+			maxCost:          6049,
+			expectedRejected: true,
+		},
+		"split query, estimates available for all split queries": {
+			splitInterval: time.Hour,
+			cacheContent: map[string][]byte{
+				keyFor(splitRequests[0]): estimate(10),
+				keyFor(splitRequests[1]): estimate(20),
+			},
+			maxCost:      10000,
+			expectedCost: "9100", // (10 series * 60 steps + 20 series * 61 steps) * 5 samples per step.
+		},
+		"split query, estimates available for some split queries": {
+			splitInterval: time.Hour,
+			cacheContent: map[string][]byte{
+				keyFor(splitRequests[1]): estimate(20),
+			},
+			maxCost:      10000,
+			expectedCost: "6400", // (1 series * 60 steps + 20 series * 61 steps) * 5 samples per step.
+		},
+		"split query, estimates available for some split queries, above limit": {
+			splitInterval: time.Hour,
+			cacheContent: map[string][]byte{
+				keyFor(splitRequests[1]): estimate(20),
+			},
+			maxCost:          6399,
+			expectedRejected: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var c cache.Cache
+			if !tc.noCache {
+				mockCache := cache.NewMockCache()
+				mockCache.SetMultiAsync(tc.cacheContent, time.Minute)
+				c = mockCache
+			}
+
+			reg := prometheus.NewPedanticRegistry()
+			downstreamCalls := 0
+			downstream := HandlerFunc(func(context.Context, MetricsQueryRequest) (Response, error) {
+				downstreamCalls++
+				return &PrometheusResponse{Status: statusSuccess, Data: &PrometheusData{ResultType: "matrix"}}, nil
+			})
+
+			handler := newQueryCostMiddleware(c, tc.splitInterval, mockLimits{maxEstimatedQueryCost: tc.maxCost}, log.NewNopLogger(), reg).Wrap(downstream)
+			resp, err := handler.Do(user.InjectOrgID(context.Background(), tenantID), request)
+
+			if tc.expectedRejected {
+				require.Error(t, err)
+				require.True(t, apierror.IsAPIError(err))
+				require.Contains(t, err.Error(), "the estimated cost of the query exceeds the limit")
+				require.Equal(t, 0, downstreamCalls)
+
+				assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+					# HELP cortex_query_frontend_expensive_queries_rejected_total Number of queries that were rejected because their estimated cost exceeded the limit.
+					# TYPE cortex_query_frontend_expensive_queries_rejected_total counter
+					cortex_query_frontend_expensive_queries_rejected_total{user="user-1"} 1
+				`)))
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, 1, downstreamCalls)
+
+			var costHeader *PrometheusHeader
+			for _, h := range resp.GetHeaders() {
+				if h.Name == estimatedQueryCostHeader {
+					costHeader = h
+				}
+			}
+
+			if tc.expectedCost == "" {
+				require.Nil(t, costHeader)
+			} else {
+				require.NotNil(t, costHeader)
+				require.Equal(t, []string{tc.expectedCost}, costHeader.Values)
+			}
+		})
+	}
+}
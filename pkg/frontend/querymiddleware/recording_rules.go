@@ -0,0 +1,500 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/concurrency"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/mimir/pkg/frontend/querymiddleware/astmapper"
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+)
+
+const (
+	// recordingRulesHeader is the response header containing the name of the recording rules whose recorded
+	// series have been used in place of the matching subexpressions of the query.
+	recordingRulesHeader = "X-Recording-Rules-Used"
+
+	// defaultLookbackDelta is the PromQL engine default lookback delta, used when none is configured.
+	defaultLookbackDelta = 5 * time.Minute
+
+	// recordingRulesLoadConcurrency is the maximum number of rule groups whose last modification time is
+	// concurrently fetched from the rule store.
+	recordingRulesLoadConcurrency = 10
+)
+
+// recordingRulesMiddleware is a MetricsQueryMiddleware that rewrites the subexpressions of queries which exactly
+// match the expression of one of the tenant's recording rules, in order to select the series recorded by the rule
+// instead of computing them again.
+//
+// A subexpression is only rewritten if the recorded series are the ones returned by the rule expression at every
+// timestamp the query is evaluated at:
+//   - The subexpression is an aggregation which drops the metric name, and the rule doesn't add any label.
+//   - The rule group is evaluated at timestamps aligned on its interval (align_evaluation_time_on_interval), with
+//     no query offset, so that each recorded sample is the value of the rule expression at the sample timestamp.
+//     The evaluation timestamps of other rule groups depend on the ruler configuration, and aren't known here.
+//   - The start of the query is one of the rule evaluation timestamps, and the step of range queries is a multiple
+//     of the rule evaluation interval.
+//   - The rule has been evaluated for every timestamp of the query: the query ends at least one evaluation interval
+//     in the past, and the rule has been recording since before the start of the query. Since the rule store
+//     doesn't track when rules have been created, the middleware conservatively considers a rule recording only once
+//     the ruler had the time to pick up the last modification of its rule group.
+//
+// Evaluations missed by the ruler, for example because a rule group takes longer than its interval to evaluate,
+// aren't detected.
+type recordingRulesMiddleware struct {
+	next          MetricsQueryHandler
+	rules         *recordingRulesCache
+	limits        Limits
+	lookbackDelta time.Duration
+	logger        log.Logger
+
+	rewrittenQueries *prometheus.CounterVec
+	rewrittenExprs   *prometheus.CounterVec
+}
+
+// newRecordingRulesMiddleware makes a new recordingRulesMiddleware. defaultEvaluationInterval is the interval
+// rule groups with no interval are evaluated at, and pollInterval is how frequently the ruler syncs rule groups
+// from the store.
+func newRecordingRulesMiddleware(
+	store rulestore.RuleStore,
+	defaultEvaluationInterval time.Duration,
+	pollInterval time.Duration,
+	lookbackDelta time.Duration,
+	limits Limits,
+	logger log.Logger,
+	registerer prometheus.Registerer,
+) MetricsQueryMiddleware {
+	if lookbackDelta <= 0 {
+		lookbackDelta = defaultLookbackDelta
+	}
+
+	rules := newRecordingRulesCache(store, defaultEvaluationInterval, pollInterval, limits, logger, registerer)
+
+	rewrittenQueries := promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_frontend_recording_rules_rewritten_queries_total",
+		Help: "Total number of queries rewritten to select the series recorded by recording rules.",
+	}, []string{"user"})
+	rewrittenExprs := promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_frontend_recording_rules_rewritten_subexpressions_total",
+		Help: "Total number of query subexpressions replaced by the series recorded by recording rules.",
+	}, []string{"user"})
+
+	return MetricsQueryMiddlewareFunc(func(next MetricsQueryHandler) MetricsQueryHandler {
+		return &recordingRulesMiddleware{
+			next:             next,
+			rules:            rules,
+			limits:           limits,
+			lookbackDelta:    lookbackDelta,
+			logger:           logger,
+			rewrittenQueries: rewrittenQueries,
+			rewrittenExprs:   rewrittenExprs,
+		}
+	})
+}
+
+func (r *recordingRulesMiddleware) Do(ctx context.Context, req MetricsQueryRequest) (Response, error) {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil || len(tenantIDs) != 1 {
+		// Recording rules are only rewritten for single-tenant queries, because the recorded series only belong
+		// to the tenant owning the rule.
+		return r.next.Do(ctx, req)
+	}
+	tenantID := tenantIDs[0]
+
+	if !r.limits.RulerRecordingRulesEvaluationEnabled(tenantID) {
+		return r.next.Do(ctx, req)
+	}
+
+	rules := r.rules.get(ctx, tenantID)
+	if len(rules) == 0 {
+		return r.next.Do(ctx, req)
+	}
+
+	rewrittenReq, used, err := r.rewrite(req, rules)
+	if err != nil {
+		level.Warn(spanlogger.FromContext(ctx, r.logger)).Log("msg", "failed to rewrite query using recording rules", "query", req.GetQuery(), "err", err)
+		return r.next.Do(ctx, req)
+	}
+	if len(used) == 0 {
+		return r.next.Do(ctx, req)
+	}
+
+	level.Debug(spanlogger.FromContext(ctx, r.logger)).Log("msg", "rewrote query using recording rules", "original", req.GetQuery(), "rewritten", rewrittenReq.GetQuery(), "recording_rules", len(used))
+	r.rewrittenQueries.WithLabelValues(tenantID).Inc()
+	r.rewrittenExprs.WithLabelValues(tenantID).Add(float64(len(used)))
+
+	resp, err := r.next.Do(ctx, rewrittenReq)
+	if err != nil {
+		return nil, err
+	}
+
+	return withResponseHeader(resp, recordingRulesHeader, used), nil
+}
+
+// rewrite returns req with the subexpressions matching one of the rules replaced by the series recorded by the
+// rule, and the name of the rules used, in the order they have been used.
+func (r *recordingRulesMiddleware) rewrite(req MetricsQueryRequest, rules map[string][]recordingRule) (MetricsQueryRequest, []string, error) {
+	expr, err := parser.ParseExpr(req.GetQuery())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mapper := &recordingRulesMapper{
+		rules:         rules,
+		start:         util.TimeFromMillis(req.GetStart()),
+		end:           util.TimeFromMillis(req.GetEnd()),
+		step:          time.Duration(req.GetStep()) * time.Millisecond,
+		now:           r.rules.currentTime(),
+		lookbackDelta: r.lookbackDelta,
+	}
+
+	mapped, err := astmapper.NewASTExprMapper(mapper).Map(expr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(mapper.used) == 0 {
+		return req, nil, nil
+	}
+
+	rewrittenReq, err := req.WithExpr(mapped)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rewrittenReq, mapper.used, nil
+}
+
+// recordingRulesMapper is an astmapper.ExprMapper replacing the subexpressions matching a recording rule by the
+// series recorded by the rule.
+type recordingRulesMapper struct {
+	// rules are the recording rules to consider, by their expression.
+	rules         map[string][]recordingRule
+	start         time.Time
+	end           time.Time
+	step          time.Duration
+	now           time.Time
+	lookbackDelta time.Duration
+
+	used []string
+}
+
+func (m *recordingRulesMapper) MapExpr(expr parser.Expr) (mapped parser.Expr, finished bool, err error) {
+	switch e := expr.(type) {
+	case *parser.SubqueryExpr:
+		// The expression of a subquery is evaluated at different timestamps than the query itself, so we don't
+		// rewrite it.
+		return expr, true, nil
+	case *parser.AggregateExpr:
+		for _, rule := range m.rules[e.String()] {
+			if !rule.usableFor(m.start, m.end, m.step, m.now, m.lookbackDelta) {
+				continue
+			}
+
+			m.used = append(m.used, rule.record)
+			return recordedSeriesExpr(rule.record), true, nil
+		}
+	}
+
+	return expr, false, nil
+}
+
+// recordedSeriesExpr returns an expression selecting the series recorded by the rule with the given name. The
+// metric name is dropped from the recorded series, so that they have the same labels as the series returned by
+// the rule expression.
+func recordedSeriesExpr(record string) parser.Expr {
+	return &parser.AggregateExpr{
+		Op:       parser.SUM,
+		Without:  true,
+		Grouping: []string{},
+		Expr: &parser.VectorSelector{
+			Name:          record,
+			LabelMatchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, model.MetricNameLabel, record)},
+		},
+	}
+}
+
+// recordingRule is a recording rule which can be used to rewrite queries.
+//
+// The rule is evaluated at the timestamps multiple of its evaluation interval, with no query offset.
+type recordingRule struct {
+	record             string
+	evaluationInterval time.Duration
+
+	// recordingSince is the time since when the rule is considered to have recorded series.
+	recordingSince time.Time
+}
+
+// usableFor returns whether the series recorded by the rule can be used in place of the rule expression, for a
+// query received at now, from start to end and evaluated every step, or an instant query if step is 0.
+func (r recordingRule) usableFor(start, end time.Time, step time.Duration, now time.Time, lookbackDelta time.Duration) bool {
+	if r.evaluationInterval > lookbackDelta {
+		return false
+	}
+
+	// Every timestamp the query is evaluated at must be one the rule is evaluated at.
+	if start.UnixMilli()%r.evaluationInterval.Milliseconds() != 0 {
+		return false
+	}
+	if step > 0 && step%r.evaluationInterval != 0 {
+		return false
+	}
+
+	// The rule must have been evaluated at each of these timestamps. An evaluation is completed before the next one
+	// starts, otherwise the ruler skips the next one.
+	if now.Sub(end) < r.evaluationInterval {
+		return false
+	}
+
+	return !start.Before(r.recordingSince)
+}
+
+// recordingRulesCache caches the recording rules of each tenant which can be used to rewrite queries.
+type recordingRulesCache struct {
+	store                     rulestore.RuleStore
+	defaultEvaluationInterval time.Duration
+	pollInterval              time.Duration
+	limits                    Limits
+	logger                    log.Logger
+	currentTime               func() time.Time
+
+	loadFailures prometheus.Counter
+
+	mtx     sync.Mutex
+	tenants map[string]*tenantRecordingRules
+}
+
+type tenantRecordingRules struct {
+	mtx      sync.Mutex
+	loadedAt time.Time
+
+	// rules are the tenant's recording rules which can be used to rewrite queries, by their expression.
+	rules map[string][]recordingRule
+}
+
+func newRecordingRulesCache(store rulestore.RuleStore, defaultEvaluationInterval, pollInterval time.Duration, limits Limits, logger log.Logger, registerer prometheus.Registerer) *recordingRulesCache {
+	return &recordingRulesCache{
+		store:                     store,
+		defaultEvaluationInterval: defaultEvaluationInterval,
+		pollInterval:              pollInterval,
+		limits:                    limits,
+		logger:                    logger,
+		currentTime:               time.Now,
+		tenants:                   map[string]*tenantRecordingRules{},
+		loadFailures: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_recording_rules_load_failures_total",
+			Help: "Total number of failures while loading the recording rules used to rewrite queries.",
+		}),
+	}
+}
+
+// get returns the tenant's recording rules which can be used to rewrite queries, by their expression. Rules are
+// reloaded from the store once per poll interval. If loading the rules fails, the previously loaded ones are
+// returned.
+func (c *recordingRulesCache) get(ctx context.Context, tenantID string) map[string][]recordingRule {
+	c.mtx.Lock()
+	t, ok := c.tenants[tenantID]
+	if !ok {
+		t = &tenantRecordingRules{}
+		c.tenants[tenantID] = t
+	}
+	c.mtx.Unlock()
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	now := c.currentTime()
+	if !t.loadedAt.IsZero() && now.Sub(t.loadedAt) < c.pollInterval {
+		return t.rules
+	}
+
+	rules, err := c.load(ctx, tenantID)
+	if err != nil {
+		c.loadFailures.Inc()
+		level.Warn(spanlogger.FromContext(ctx, c.logger)).Log("msg", "failed to load recording rules", "user", tenantID, "err", err)
+		return t.rules
+	}
+
+	t.loadedAt = now
+	t.rules = rules
+	return t.rules
+}
+
+func (c *recordingRulesCache) load(ctx context.Context, tenantID string) (map[string][]recordingRule, error) {
+	groups, err := c.store.ListRuleGroupsForUserAndNamespace(ctx, tenantID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := c.store.LoadRuleGroups(ctx, map[string]rulespb.RuleGroupList{tenantID: groups}); err != nil {
+		return nil, err
+	}
+
+	return c.usableRules(ctx, tenantID, groups)
+}
+
+// usableRules returns the recording rules in groups which can be used to rewrite queries, by their expression.
+func (c *recordingRulesCache) usableRules(ctx context.Context, tenantID string, groups rulespb.RuleGroupList) (map[string][]recordingRule, error) {
+	// Sort the groups to consistently pick the same rule when multiple rules have the same expression.
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Namespace != groups[j].Namespace {
+			return groups[i].Namespace < groups[j].Namespace
+		}
+		return groups[i].Name < groups[j].Name
+	})
+
+	// The series recorded by rules with the same name can't be told apart.
+	records := map[string]int{}
+	for _, g := range groups {
+		for _, r := range g.Rules {
+			if r.Record != "" {
+				records[r.Record]++
+			}
+		}
+	}
+
+	var usableGroups rulespb.RuleGroupList
+	for _, g := range groups {
+		if c.evaluationInterval(tenantID, g) > 0 {
+			usableGroups = append(usableGroups, g)
+		}
+	}
+
+	// The rules of a group are considered recording only once the ruler had the time to pick up the last modification
+	// of the group. The rule groups deleted in the meantime have a zero last modification time, and are skipped.
+	lastModified := make([]time.Time, len(usableGroups))
+	err := concurrency.ForEachJob(ctx, len(usableGroups), recordingRulesLoadConcurrency, func(ctx context.Context, idx int) error {
+		g := usableGroups[idx]
+		modified, err := c.store.GetRuleGroupLastModified(ctx, tenantID, g.Namespace, g.Name)
+		if errors.Is(err, rulestore.ErrGroupNotFound) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "get last modification time of rule group namespace=%q, name=%q", g.Namespace, g.Name)
+		}
+
+		lastModified[idx] = modified
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rules := map[string][]recordingRule{}
+
+	for idx, g := range usableGroups {
+		if lastModified[idx].IsZero() {
+			continue
+		}
+
+		interval := c.evaluationInterval(tenantID, g)
+
+		for _, r := range g.Rules {
+			if r.Record == "" || records[r.Record] > 1 || len(r.Labels) > 0 {
+				continue
+			}
+
+			expr, ok := recordingRuleExpr(r.Expr)
+			if !ok {
+				continue
+			}
+
+			rules[expr] = append(rules[expr], recordingRule{
+				record:             r.Record,
+				evaluationInterval: interval,
+				// The ruler may only pick up the rule after two poll intervals, because of the jitter applied
+				// to its syncs and of the rule store cache, and then evaluates it after up to one interval.
+				recordingSince: lastModified[idx].Add(2*c.pollInterval + interval),
+			})
+		}
+	}
+
+	return rules, nil
+}
+
+// evaluationInterval returns the interval the rule group g is evaluated at, or 0 if the recording rules of the group
+// can't be used to rewrite queries because the query-frontend doesn't know which timestamps they're evaluated at.
+func (c *recordingRulesCache) evaluationInterval(tenantID string, g *rulespb.RuleGroupDesc) time.Duration {
+	// Federated rule groups query the series of other tenants.
+	if len(g.SourceTenants) > 0 {
+		return 0
+	}
+
+	// The evaluation timestamps of the groups not aligned on their interval are shifted by an offset depending on the
+	// ruler configuration.
+	if !g.AlignEvaluationTimeOnInterval {
+		return 0
+	}
+
+	// The samples recorded by the groups with a query offset are the values of the rule expressions at a timestamp
+	// preceding their evaluation, which may not be aligned on the interval anymore once the offset is applied.
+	//nolint:staticcheck // We want to intentionally access a deprecated field
+	if g.QueryOffset > 0 || g.EvaluationDelay > 0 || c.limits.EvaluationDelay(tenantID) > 0 {
+		return 0
+	}
+
+	interval := g.Interval
+	if interval <= 0 {
+		interval = c.defaultEvaluationInterval
+	}
+	if interval < time.Millisecond {
+		return 0
+	}
+
+	return interval
+}
+
+// recordingRuleExpr returns the canonical form of the expression of a recording rule, and false if the
+// expression can't be used to rewrite queries because the series it returns don't have the same labels as
+// the series recorded by the rule once their metric name is dropped.
+func recordingRuleExpr(rawExpr string) (string, bool) {
+	expr, err := parser.ParseExpr(rawExpr)
+	if err != nil {
+		return "", false
+	}
+
+	for {
+		paren, ok := expr.(*parser.ParenExpr)
+		if !ok {
+			break
+		}
+		expr = paren.Expr
+	}
+
+	agg, ok := expr.(*parser.AggregateExpr)
+	if !ok {
+		return "", false
+	}
+
+	switch agg.Op {
+	case parser.TOPK, parser.BOTTOMK, parser.LIMITK, parser.LIMIT_RATIO:
+		// These aggregations preserve the labels of the input series, including the metric name.
+		return "", false
+	}
+
+	if !agg.Without {
+		for _, l := range agg.Grouping {
+			if l == model.MetricNameLabel {
+				return "", false
+			}
+		}
+	}
+
+	return agg.String(), true
+}
@@ -0,0 +1,389 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+)
+
+// mockRecordingRulesStore is a rulestore.RuleStore only supporting listing and loading rule groups, and getting
+// when they have been last modified.
+type mockRecordingRulesStore struct {
+	rulestore.RuleStore
+
+	mtx          sync.Mutex
+	groups       map[string]rulespb.RuleGroupList
+	lastModified time.Time
+	err          error
+	loads        int
+}
+
+func newMockRecordingRulesStore(groups map[string]rulespb.RuleGroupList, lastModified time.Time) *mockRecordingRulesStore {
+	return &mockRecordingRulesStore{groups: groups, lastModified: lastModified}
+}
+
+func (m *mockRecordingRulesStore) ListRuleGroupsForUserAndNamespace(_ context.Context, userID string, _ string) (rulespb.RuleGroupList, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.loads++
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	// Return copies of the groups, without their rules, which are only populated by LoadRuleGroups().
+	var list rulespb.RuleGroupList
+	for _, g := range m.groups[userID] {
+		list = append(list, &rulespb.RuleGroupDesc{User: g.User, Namespace: g.Namespace, Name: g.Name})
+	}
+	return list, nil
+}
+
+func (m *mockRecordingRulesStore) LoadRuleGroups(_ context.Context, groupsToLoad map[string]rulespb.RuleGroupList) (rulespb.RuleGroupList, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for userID, groups := range groupsToLoad {
+		for _, g := range groups {
+			for _, stored := range m.groups[userID] {
+				if stored.Namespace == g.Namespace && stored.Name == g.Name {
+					*g = *stored
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockRecordingRulesStore) GetRuleGroupLastModified(_ context.Context, userID, namespace, group string) (time.Time, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for _, stored := range m.groups[userID] {
+		if stored.Namespace == namespace && stored.Name == group {
+			return m.lastModified, nil
+		}
+	}
+	return time.Time{}, rulestore.ErrGroupNotFound
+}
+
+func (m *mockRecordingRulesStore) setGroups(userID string, groups rulespb.RuleGroupList, lastModified time.Time) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.groups[userID] = groups
+	m.lastModified = lastModified
+}
+
+func (m *mockRecordingRulesStore) setError(err error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.err = err
+}
+
+// recordingRuleGroup returns a rule group evaluated at timestamps aligned on its interval.
+func recordingRuleGroup(namespace, name string, interval time.Duration, rules ...*rulespb.RuleDesc) *rulespb.RuleGroupDesc {
+	return &rulespb.RuleGroupDesc{User: "user-1", Namespace: namespace, Name: name, Interval: interval, Rules: rules, AlignEvaluationTimeOnInterval: true}
+}
+
+func recordingRuleDesc(record, expr string) *rulespb.RuleDesc {
+	return &rulespb.RuleDesc{Record: record, Expr: expr}
+}
+
+func TestRecordingRulesMiddleware(t *testing.T) {
+	const (
+		pollInterval = 10 * time.Minute
+		ruleExpr     = `sum by (job) (rate(some_metric{env="prod"}[5m]))`
+	)
+
+	// Rule groups are last modified at lastModified, and their rules considered recording after two poll intervals
+	// and one evaluation interval. Queries are received at now, past the end of all the queries below.
+	lastModified := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	recording := lastModified.Add(time.Hour)
+	now := lastModified.Add(6 * time.Hour).Add(30 * time.Second)
+
+	defaultGroups := rulespb.RuleGroupList{
+		recordingRuleGroup("namespace", "group", time.Minute, recordingRuleDesc("job:some_metric:rate5m", ruleExpr)),
+	}
+
+	rangeRequest := func(query string, start time.Time, step time.Duration) MetricsQueryRequest {
+		return NewPrometheusRangeQueryRequest(queryRangePathSuffix, nil, start.UnixMilli(), start.Add(time.Hour).UnixMilli(), step.Milliseconds(), 0, parseQuery(t, query), Options{}, nil)
+	}
+	instantRequest := func(query string, ts time.Time) MetricsQueryRequest {
+		return NewPrometheusInstantQueryRequest(instantQueryPathSuffix, nil, ts.UnixMilli(), 0, parseQuery(t, query), Options{}, nil)
+	}
+
+	tests := map[string]struct {
+		groups        rulespb.RuleGroupList
+		limits        mockLimits
+		lookbackDelta time.Duration
+		request       MetricsQueryRequest
+
+		expectedQuery string
+		expectedRules []string
+	}{
+		"range query matching a rule": {
+			request:       rangeRequest(`sum by (job) (rate(some_metric{env="prod"}[5m])) / 2`, recording, time.Minute),
+			expectedQuery: `sum without () (job:some_metric:rate5m) / 2`,
+			expectedRules: []string{"job:some_metric:rate5m"},
+		},
+		"range query matching a rule with a different formatting": {
+			request:       rangeRequest(`sum(rate(some_metric{env="prod"}[5m])) by (job)`, recording, 5*time.Minute),
+			expectedQuery: `sum without () (job:some_metric:rate5m)`,
+			expectedRules: []string{"job:some_metric:rate5m"},
+		},
+		"range query matching a rule multiple times": {
+			request:       rangeRequest(`max(sum by (job) (rate(some_metric{env="prod"}[5m]))) / min(sum by (job) (rate(some_metric{env="prod"}[5m])))`, recording, time.Minute),
+			expectedQuery: `max(sum without () (job:some_metric:rate5m)) / min(sum without () (job:some_metric:rate5m))`,
+			expectedRules: []string{"job:some_metric:rate5m", "job:some_metric:rate5m"},
+		},
+		"instant query matching a rule": {
+			request:       instantRequest(`sum by (job) (rate(some_metric{env="prod"}[5m]))`, recording),
+			expectedQuery: `sum without () (job:some_metric:rate5m)`,
+			expectedRules: []string{"job:some_metric:rate5m"},
+		},
+		"query with different label matchers": {
+			request: rangeRequest(`sum by (job) (rate(some_metric{env="dev"}[5m]))`, recording, time.Minute),
+		},
+		"query with different grouping labels": {
+			request: rangeRequest(`sum by (job, instance) (rate(some_metric{env="prod"}[5m]))`, recording, time.Minute),
+		},
+		"query matching a rule within a subquery": {
+			request: rangeRequest(`max_over_time(sum by (job) (rate(some_metric{env="prod"}[5m]))[10m:])`, recording, time.Minute),
+		},
+		"range query with a step smaller than the evaluation interval": {
+			request: rangeRequest(ruleExpr, recording, 30*time.Second),
+		},
+		"range query with a step not multiple of the evaluation interval": {
+			request: rangeRequest(ruleExpr, recording, 90*time.Second),
+		},
+		"range query starting at a timestamp the rule isn't evaluated at": {
+			request: rangeRequest(ruleExpr, recording.Add(30*time.Second), time.Minute),
+		},
+		"instant query at a timestamp the rule isn't evaluated at": {
+			request: instantRequest(ruleExpr, recording.Add(30*time.Second)),
+		},
+		"instant query at a timestamp the rule may not have been evaluated at yet": {
+			request: instantRequest(ruleExpr, now.Truncate(time.Minute)),
+		},
+		"query starting before the rule is recording": {
+			request: rangeRequest(ruleExpr, lastModified.Add(2*pollInterval), time.Minute),
+		},
+		"rule group not evaluated at timestamps aligned on its interval": {
+			groups: rulespb.RuleGroupList{
+				{User: "user-1", Namespace: "namespace", Name: "group", Interval: time.Minute, Rules: []*rulespb.RuleDesc{recordingRuleDesc("job:some_metric:rate5m", ruleExpr)}},
+			},
+			request: rangeRequest(ruleExpr, recording, time.Minute),
+		},
+		"rule group with a query offset": {
+			groups: rulespb.RuleGroupList{
+				{User: "user-1", Namespace: "namespace", Name: "group", Interval: time.Minute, QueryOffset: time.Minute, AlignEvaluationTimeOnInterval: true, Rules: []*rulespb.RuleDesc{recordingRuleDesc("job:some_metric:rate5m", ruleExpr)}},
+			},
+			request: rangeRequest(ruleExpr, recording, time.Minute),
+		},
+		"tenant with an evaluation delay": {
+			limits:  mockLimits{evaluationDelay: time.Minute},
+			request: rangeRequest(ruleExpr, recording, time.Minute),
+		},
+		"evaluation interval greater than the lookback delta": {
+			groups: rulespb.RuleGroupList{
+				recordingRuleGroup("namespace", "group", 10*time.Minute, recordingRuleDesc("job:some_metric:rate5m", ruleExpr)),
+			},
+			request: rangeRequest(ruleExpr, lastModified.Add(2*time.Hour), 10*time.Minute),
+		},
+		"evaluation interval greater than the default lookback delta but not the configured one": {
+			groups: rulespb.RuleGroupList{
+				recordingRuleGroup("namespace", "group", 10*time.Minute, recordingRuleDesc("job:some_metric:rate5m", ruleExpr)),
+			},
+			lookbackDelta: 15 * time.Minute,
+			request:       rangeRequest(ruleExpr, lastModified.Add(2*time.Hour), 10*time.Minute),
+			expectedQuery: `sum without () (job:some_metric:rate5m)`,
+			expectedRules: []string{"job:some_metric:rate5m"},
+		},
+		"rule adding labels": {
+			groups: rulespb.RuleGroupList{
+				recordingRuleGroup("namespace", "group", time.Minute, &rulespb.RuleDesc{
+					Record: "job:some_metric:rate5m",
+					Expr:   ruleExpr,
+					Labels: []mimirpb.LabelAdapter{{Name: "team", Value: "a"}},
+				}),
+			},
+			request: rangeRequest(ruleExpr, recording, time.Minute),
+		},
+		"rules with the same name": {
+			groups: rulespb.RuleGroupList{
+				recordingRuleGroup("namespace", "group-1", time.Minute, recordingRuleDesc("job:some_metric:rate5m", ruleExpr)),
+				recordingRuleGroup("namespace", "group-2", time.Minute, recordingRuleDesc("job:some_metric:rate5m", `sum by (job) (rate(some_metric[5m]))`)),
+			},
+			request: rangeRequest(ruleExpr, recording, time.Minute),
+		},
+		"federated rule group": {
+			groups: rulespb.RuleGroupList{
+				{User: "user-1", Namespace: "namespace", Name: "group", Interval: time.Minute, SourceTenants: []string{"user-2"}, AlignEvaluationTimeOnInterval: true, Rules: []*rulespb.RuleDesc{recordingRuleDesc("job:some_metric:rate5m", ruleExpr)}},
+			},
+			request: rangeRequest(ruleExpr, recording, time.Minute),
+		},
+		"rule preserving the metric name": {
+			groups: rulespb.RuleGroupList{
+				recordingRuleGroup("namespace", "group", time.Minute, recordingRuleDesc("top_some_metric", `topk(5, some_metric)`)),
+			},
+			request: rangeRequest(`topk(5, some_metric)`, recording, time.Minute),
+		},
+		"recording rules evaluation disabled for the tenant": {
+			limits:  mockLimits{recordingRulesEvaluationDisabled: true},
+			request: rangeRequest(ruleExpr, recording, time.Minute),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			groups := tc.groups
+			if groups == nil {
+				groups = defaultGroups
+			}
+
+			reg := prometheus.NewPedanticRegistry()
+			store := newMockRecordingRulesStore(map[string]rulespb.RuleGroupList{"user-1": groups}, lastModified)
+
+			var downstreamReq MetricsQueryRequest
+			downstream := HandlerFunc(func(_ context.Context, req MetricsQueryRequest) (Response, error) {
+				downstreamReq = req
+				return &PrometheusResponse{Status: statusSuccess, Data: &PrometheusData{ResultType: "matrix"}}, nil
+			})
+
+			handler := newRecordingRulesMiddleware(store, time.Minute, pollInterval, tc.lookbackDelta, tc.limits, log.NewNopLogger(), reg).Wrap(downstream)
+			handler.(*recordingRulesMiddleware).rules.currentTime = func() time.Time { return now }
+
+			resp, err := handler.Do(user.InjectOrgID(context.Background(), "user-1"), tc.request)
+			require.NoError(t, err)
+
+			var rulesHeader *PrometheusHeader
+			for _, h := range resp.GetHeaders() {
+				if h.Name == recordingRulesHeader {
+					rulesHeader = h
+				}
+			}
+
+			if tc.expectedQuery == "" {
+				require.Same(t, tc.request, downstreamReq)
+				require.Nil(t, rulesHeader)
+				return
+			}
+
+			require.Equal(t, tc.expectedQuery, downstreamReq.GetQuery())
+			require.Equal(t, tc.request.GetStart(), downstreamReq.GetStart())
+			require.Equal(t, tc.request.GetEnd(), downstreamReq.GetEnd())
+			require.NotNil(t, rulesHeader)
+			require.Equal(t, tc.expectedRules, rulesHeader.Values)
+
+			assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+				# HELP cortex_frontend_recording_rules_rewritten_queries_total Total number of queries rewritten to select the series recorded by recording rules.
+				# TYPE cortex_frontend_recording_rules_rewritten_queries_total counter
+				cortex_frontend_recording_rules_rewritten_queries_total{user="user-1"} 1
+			`), "cortex_frontend_recording_rules_rewritten_queries_total"))
+		})
+	}
+}
+
+func TestRecordingRulesMiddleware_MultiTenantQueries(t *testing.T) {
+	store := newMockRecordingRulesStore(map[string]rulespb.RuleGroupList{}, time.Time{})
+	downstream := HandlerFunc(func(context.Context, MetricsQueryRequest) (Response, error) {
+		return &PrometheusResponse{Status: statusSuccess, Data: &PrometheusData{ResultType: "vector"}}, nil
+	})
+
+	handler := newRecordingRulesMiddleware(store, time.Minute, time.Minute, 0, mockLimits{}, log.NewNopLogger(), nil).Wrap(downstream)
+	req := NewPrometheusInstantQueryRequest(instantQueryPathSuffix, nil, 0, 0, parseQuery(t, "sum(up)"), Options{}, nil)
+
+	_, err := handler.Do(user.InjectOrgID(context.Background(), "user-1|user-2"), req)
+	require.NoError(t, err)
+	require.Zero(t, store.loads)
+}
+
+func TestRecordingRulesCache(t *testing.T) {
+	const pollInterval = 10 * time.Minute
+
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	lastModified := now.Add(-time.Hour)
+
+	store := newMockRecordingRulesStore(map[string]rulespb.RuleGroupList{
+		"user-1": {recordingRuleGroup("namespace", "group", 0, recordingRuleDesc("job:up:sum", "sum by (job) (up)"))},
+	}, lastModified)
+
+	reg := prometheus.NewPedanticRegistry()
+	c := newRecordingRulesCache(store, 30*time.Second, pollInterval, mockLimits{}, log.NewNopLogger(), reg)
+	c.currentTime = func() time.Time { return now }
+	ctx := context.Background()
+
+	// The rules are loaded on the first request.
+	require.Equal(t, map[string][]recordingRule{
+		"sum by (job) (up)": {{
+			record:             "job:up:sum",
+			evaluationInterval: 30 * time.Second, // The default evaluation interval.
+			recordingSince:     lastModified.Add(2*pollInterval + 30*time.Second),
+		}},
+	}, c.get(ctx, "user-1"))
+	require.Equal(t, 1, store.loads)
+
+	// The rules are not reloaded until the poll interval has elapsed.
+	now = now.Add(pollInterval / 2)
+	c.get(ctx, "user-1")
+	require.Equal(t, 1, store.loads)
+
+	// Once reloaded, the rules of a modified group are all considered recording since the group modification,
+	// while the groups not aligned on their interval are skipped.
+	modified := now
+	store.setGroups("user-1", rulespb.RuleGroupList{
+		recordingRuleGroup("namespace", "group", time.Minute,
+			recordingRuleDesc("job:up:sum", "sum by (job) (up)"),
+			recordingRuleDesc("job:up:count", "count by (job) (up)"),
+		),
+		{User: "user-1", Namespace: "namespace", Name: "unaligned", Interval: time.Minute, Rules: []*rulespb.RuleDesc{recordingRuleDesc("job:up:max", "max by (job) (up)")}},
+	}, modified)
+	now = now.Add(pollInterval)
+	require.Equal(t, map[string][]recordingRule{
+		"sum by (job) (up)": {{
+			record:             "job:up:sum",
+			evaluationInterval: time.Minute,
+			recordingSince:     modified.Add(2*pollInterval + time.Minute),
+		}},
+		"count by (job) (up)": {{
+			record:             "job:up:count",
+			evaluationInterval: time.Minute,
+			recordingSince:     modified.Add(2*pollInterval + time.Minute),
+		}},
+	}, c.get(ctx, "user-1"))
+	require.Equal(t, 2, store.loads)
+
+	// A new cache, such as the one of another query-frontend, loads the same rules.
+	other := newRecordingRulesCache(store, 30*time.Second, pollInterval, mockLimits{}, log.NewNopLogger(), nil)
+	other.currentTime = func() time.Time { return now }
+	require.Equal(t, c.get(ctx, "user-1"), other.get(ctx, "user-1"))
+	require.Equal(t, 3, store.loads)
+
+	// If the rules can't be reloaded, the previously loaded ones are used.
+	store.setError(errors.New("store unavailable"))
+	now = now.Add(pollInterval)
+	require.Len(t, c.get(ctx, "user-1"), 2)
+	require.Equal(t, 4, store.loads)
+
+	assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_frontend_recording_rules_load_failures_total Total number of failures while loading the recording rules used to rewrite queries.
+		# TYPE cortex_frontend_recording_rules_load_failures_total counter
+		cortex_frontend_recording_rules_load_failures_total 1
+	`)))
+}
@@ -0,0 +1,646 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/golang/snappy"
+	"github.com/grafana/dskit/cache"
+	"github.com/grafana/dskit/concurrency"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage/remote"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"go.uber.org/atomic"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/querier/api"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+const (
+	remoteReadQueryCachePrefix = "rr:"
+
+	// maxRemoteReadFrameBytes is the maximum size of the frames of STREAMED_XOR_CHUNKS remote read responses
+	// stitched back together by the query-frontend. It's the same size used by queriers.
+	maxRemoteReadFrameBytes = 1024 * 1024
+)
+
+// errRemoteReadSplitFailed is used to stop the execution of the split queries once one of them failed.
+var errRemoteReadSplitFailed = errors.New("remote read split query failed")
+
+// remoteReadSplitAndCacheRoundTripper is a http.RoundTripper splitting the queries of remote read requests by
+// interval, executing the split queries in parallel, and stitching their responses back together in order.
+// The response of split queries which only cover completed historical data is cached, if a cache is configured.
+//
+// Both SAMPLES and STREAMED_XOR_CHUNKS responses are supported. The series of each query are merged across its
+// split queries and sorted by labels, so the responses of all split queries are buffered by the query-frontend,
+// up to maxResponseSize bytes, and STREAMED_XOR_CHUNKS responses are only streamed back to the client once all
+// split queries have completed.
+type remoteReadSplitAndCacheRoundTripper struct {
+	next            http.RoundTripper
+	interval        time.Duration
+	maxResponseSize int
+	limits          Limits
+	logger          log.Logger
+
+	splitQueriesCount prometheus.Counter
+}
+
+func newRemoteReadSplitAndCacheRoundTripper(
+	next http.RoundTripper,
+	interval time.Duration,
+	maxResponseSize int,
+	limits Limits,
+	cache cache.Cache,
+	logger log.Logger,
+	reg prometheus.Registerer,
+) http.RoundTripper {
+	if cache != nil {
+		next = newGenericQueryCacheRoundTripper(cache, newRemoteReadCacheKeyGenerator(limits).cacheKey, &remoteReadQueryTTL{limits: limits}, next, logger, newResultsCacheMetrics(queryTypeRemoteRead, reg))
+	}
+
+	return &remoteReadSplitAndCacheRoundTripper{
+		next:            next,
+		interval:        interval,
+		maxResponseSize: maxResponseSize,
+		limits:          limits,
+		logger:          logger,
+		splitQueriesCount: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_split_remote_read_queries_total",
+			Help: "Total number of underlying remote read queries after the split by interval is applied.",
+		}),
+	}
+}
+
+// remoteReadSplitQuery is a query of a remote read request restricted to a split interval.
+type remoteReadSplitQuery struct {
+	// queryIndex is the index of the original query in the remote read request.
+	queryIndex int
+	query      *prompb.Query
+
+	// minT and maxT are the time range, inclusive, of the data returned for the split query.
+	minT, maxT int64
+}
+
+// remoteReadSplitResult is the result of a split query.
+type remoteReadSplitResult struct {
+	timeseries []*prompb.TimeSeries
+	series     []*prompb.ChunkedSeries
+}
+
+func (r *remoteReadSplitAndCacheRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	readReq, err := parseRemoteReadRequestWithoutConsumingBody(req)
+	if err != nil || readReq == nil {
+		// Let the downstream handle the invalid request.
+		return r.next.RoundTrip(req)
+	}
+
+	respType, ok := remoteReadResponseType(readReq.AcceptedResponseTypes)
+	if !ok {
+		return r.next.RoundTrip(req)
+	}
+
+	splits, err := r.splitQueries(readReq.Queries)
+	if err != nil {
+		return nil, err
+	}
+	if len(splits) == len(readReq.Queries) {
+		// No query needs to be split.
+		return r.next.RoundTrip(req)
+	}
+
+	spanLog := spanlogger.FromContext(ctx, r.logger)
+	spanLog.DebugLog("msg", "split remote read queries", "queries", len(readReq.Queries), "split_queries", len(splits))
+	r.splitQueriesCount.Add(float64(len(splits)))
+
+	results := make([]remoteReadSplitResult, len(splits))
+	responseSize := &remoteReadResponseSizeLimiter{limit: int64(r.maxResponseSize)}
+	var (
+		failedMtx  sync.Mutex
+		failedResp *http.Response
+	)
+
+	// Limit the number of split queries executed in parallel according to the MaxQueryParallelism tenant setting.
+	parallelism := validation.SmallestPositiveIntPerTenant(tenantIDs, r.limits.MaxQueryParallelism)
+	err = concurrency.ForEachJob(ctx, len(splits), parallelism, func(ctx context.Context, idx int) error {
+		resp, err := r.next.RoundTrip(newRemoteReadSplitRequest(ctx, req, splits[idx].query, respType))
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode/100 != 2 {
+			failedMtx.Lock()
+			defer failedMtx.Unlock()
+
+			if failedResp == nil {
+				failedResp = resp
+			} else {
+				_ = resp.Body.Close()
+			}
+			return errRemoteReadSplitFailed
+		}
+
+		defer func() { _ = resp.Body.Close() }()
+		results[idx], err = decodeRemoteReadSplitResponse(resp.Body, respType, responseSize)
+		return err
+	})
+
+	if failedResp != nil {
+		// Return the failed response as is, so that the client gets the same error as if the request wasn't split.
+		return failedResp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if respType == prompb.ReadRequest_STREAMED_XOR_CHUNKS {
+		return stitchRemoteReadChunkedResponses(len(readReq.Queries), splits, results)
+	}
+	return stitchRemoteReadSamplesResponses(len(readReq.Queries), splits, results)
+}
+
+// splitQueries splits the time range of each query by interval. Split queries are returned in the order of their
+// original query, and then of their time range.
+func (r *remoteReadSplitAndCacheRoundTripper) splitQueries(queries []*prompb.Query) ([]remoteReadSplitQuery, error) {
+	var splits []remoteReadSplitQuery
+
+	for i, query := range queries {
+		// The time range of the data returned for a query honors the read hints.
+		orig := &remoteReadQueryRequest{query: query}
+		minT, maxT := orig.GetMinT(), orig.GetMaxT()
+
+		for start := minT; start <= maxT; {
+			end := min(nextIntervalBoundary(start, 1, r.interval), maxT)
+
+			split, err := cloneRemoteReadQuery(query)
+			if err != nil {
+				return nil, err
+			}
+
+			split.StartTimestampMs = start
+			split.EndTimestampMs = end
+			if split.Hints != nil {
+				split.Hints.StartMs = start
+				split.Hints.EndMs = end
+			}
+
+			splits = append(splits, remoteReadSplitQuery{queryIndex: i, query: split, minT: start, maxT: end})
+			start = end + 1
+		}
+
+		// Keep queries with an empty time range as they are, so that the querier handles them.
+		if minT > maxT {
+			splits = append(splits, remoteReadSplitQuery{queryIndex: i, query: query, minT: minT, maxT: maxT})
+		}
+	}
+
+	return splits, nil
+}
+
+// remoteReadResponseType returns the response type the querier will use for a remote read request accepting the
+// input response types, and false if none is supported.
+func remoteReadResponseType(accepted []prompb.ReadRequest_ResponseType) (prompb.ReadRequest_ResponseType, bool) {
+	if len(accepted) == 0 {
+		return prompb.ReadRequest_SAMPLES, true
+	}
+
+	for _, respType := range accepted {
+		switch respType {
+		case prompb.ReadRequest_SAMPLES, prompb.ReadRequest_STREAMED_XOR_CHUNKS:
+			return respType, true
+		}
+	}
+
+	return 0, false
+}
+
+// newRemoteReadSplitRequest returns a copy of the original HTTP request reading the single input query.
+func newRemoteReadSplitRequest(ctx context.Context, orig *http.Request, query *prompb.Query, respType prompb.ReadRequest_ResponseType) *http.Request {
+	// Errors marshalling the request are not expected, since the query has been unmarshalled from a valid request.
+	encoded, _ := marshalRemoteReadRequest(&prompb.ReadRequest{
+		Queries:               []*prompb.Query{query},
+		AcceptedResponseTypes: []prompb.ReadRequest_ResponseType{respType},
+	})
+
+	req := orig.Clone(ctx)
+	req.Body = io.NopCloser(bytes.NewReader(encoded))
+	req.Header.Set("Content-Length", strconv.Itoa(len(encoded)))
+	req.Header.Set("Content-Encoding", "snappy")
+	req.ContentLength = int64(len(encoded))
+
+	return req
+}
+
+// remoteReadResponseSizeLimiter tracks the size of the split query responses of a remote read request buffered
+// by the query-frontend, and fails once they exceed the limit, if any.
+type remoteReadResponseSizeLimiter struct {
+	limit int64
+	size  atomic.Int64
+}
+
+// add adds size bytes to the buffered responses, and returns an error if they exceed the limit.
+func (l *remoteReadResponseSizeLimiter) add(size int) error {
+	if l.limit > 0 && l.size.Add(int64(size)) > l.limit {
+		return apierror.Newf(apierror.TypeTooLargeEntry, "the responses of the split queries of the remote read request exceed the limit of %d bytes (-query-frontend.split-remote-read-queries-max-response-size)", l.limit)
+	}
+
+	return nil
+}
+
+func decodeRemoteReadSplitResponse(body io.Reader, respType prompb.ReadRequest_ResponseType, responseSize *remoteReadResponseSizeLimiter) (remoteReadSplitResult, error) {
+	if respType == prompb.ReadRequest_STREAMED_XOR_CHUNKS {
+		var series []*prompb.ChunkedSeries
+
+		reader := remote.NewChunkedReader(body, remote.DefaultChunkedReadLimit, nil)
+		for {
+			frame := &prompb.ChunkedReadResponse{}
+			if err := reader.NextProto(frame); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return remoteReadSplitResult{}, errors.Wrap(err, "failed to decode remote read split query response")
+			}
+
+			if err := responseSize.add(frame.Size()); err != nil {
+				return remoteReadSplitResult{}, err
+			}
+
+			series = append(series, frame.ChunkedSeries...)
+		}
+
+		return remoteReadSplitResult{series: series}, nil
+	}
+
+	compressed, err := io.ReadAll(body)
+	if err != nil {
+		return remoteReadSplitResult{}, errors.Wrap(err, "failed to read remote read split query response")
+	}
+
+	// Check the size of the decoded response before decoding it.
+	decodedSize, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return remoteReadSplitResult{}, errors.Wrap(err, "failed to decode remote read split query response")
+	}
+	if err := responseSize.add(decodedSize); err != nil {
+		return remoteReadSplitResult{}, err
+	}
+
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return remoteReadSplitResult{}, errors.Wrap(err, "failed to decode remote read split query response")
+	}
+
+	resp := &prompb.ReadResponse{}
+	if err := resp.Unmarshal(data); err != nil {
+		return remoteReadSplitResult{}, errors.Wrap(err, "failed to decode remote read split query response")
+	}
+
+	var timeseries []*prompb.TimeSeries
+	for _, result := range resp.Results {
+		timeseries = append(timeseries, result.Timeseries...)
+	}
+
+	return remoteReadSplitResult{timeseries: timeseries}, nil
+}
+
+// stitchRemoteReadSamplesResponses merges the samples of each series returned by the split queries of each
+// original query. Since split queries don't overlap, and samples are filtered by the querier to the queried
+// time range, the samples are concatenated in the order of the split queries.
+func stitchRemoteReadSamplesResponses(queries int, splits []remoteReadSplitQuery, results []remoteReadSplitResult) (*http.Response, error) {
+	resp := &prompb.ReadResponse{Results: make([]*prompb.QueryResult, queries)}
+
+	for queryIndex := range resp.Results {
+		merged := map[string]*prompb.TimeSeries{}
+
+		for splitIdx, split := range splits {
+			if split.queryIndex != queryIndex {
+				continue
+			}
+
+			for _, ts := range results[splitIdx].timeseries {
+				key := remoteReadSeriesKey(ts.Labels)
+				if existing, ok := merged[key]; ok {
+					existing.Samples = append(existing.Samples, ts.Samples...)
+					existing.Histograms = append(existing.Histograms, ts.Histograms...)
+					continue
+				}
+				merged[key] = ts
+			}
+		}
+
+		result := &prompb.QueryResult{Timeseries: make([]*prompb.TimeSeries, 0, len(merged))}
+		for _, ts := range merged {
+			result.Timeseries = append(result.Timeseries, ts)
+		}
+		sort.Slice(result.Timeseries, func(i, j int) bool {
+			return labels.Compare(remoteReadLabels(result.Timeseries[i].Labels), remoteReadLabels(result.Timeseries[j].Labels)) < 0
+		})
+
+		resp.Results[queryIndex] = result
+	}
+
+	data, err := resp.Marshal()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode remote read response")
+	}
+	encoded := snappy.Encode(nil, data)
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type":     []string{"application/x-protobuf"},
+			"Content-Encoding": []string{"snappy"},
+		},
+		Body:          io.NopCloser(bytes.NewReader(encoded)),
+		ContentLength: int64(len(encoded)),
+	}, nil
+}
+
+// stitchRemoteReadChunkedResponses merges the chunks of each series returned by the split queries of each
+// original query, and encodes them as a STREAMED_XOR_CHUNKS response with series sorted by labels, as expected
+// by clients. Chunks returned by a split query may contain samples outside its time range, so chunks are trimmed
+// to the split time range to not return duplicated samples.
+func stitchRemoteReadChunkedResponses(queries int, splits []remoteReadSplitQuery, results []remoteReadSplitResult) (*http.Response, error) {
+	body := &bytes.Buffer{}
+	writer := remote.NewChunkedWriter(body, nopFlusher{})
+
+	for queryIndex := 0; queryIndex < queries; queryIndex++ {
+		merged := map[string]*prompb.ChunkedSeries{}
+
+		for splitIdx, split := range splits {
+			if split.queryIndex != queryIndex {
+				continue
+			}
+
+			for _, series := range results[splitIdx].series {
+				chks, err := trimRemoteReadChunks(series.Chunks, split.minT, split.maxT)
+				if err != nil {
+					return nil, err
+				}
+
+				key := remoteReadSeriesKey(series.Labels)
+				if existing, ok := merged[key]; ok {
+					existing.Chunks = append(existing.Chunks, chks...)
+					continue
+				}
+				merged[key] = &prompb.ChunkedSeries{Labels: series.Labels, Chunks: chks}
+			}
+		}
+
+		sorted := make([]*prompb.ChunkedSeries, 0, len(merged))
+		for _, series := range merged {
+			if len(series.Chunks) > 0 {
+				sorted = append(sorted, series)
+			}
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			return labels.Compare(remoteReadLabels(sorted[i].Labels), remoteReadLabels(sorted[j].Labels)) < 0
+		})
+
+		for _, series := range sorted {
+			if err := writeRemoteReadChunkedSeries(writer, series, queryIndex); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": []string{api.ContentTypeRemoteReadStreamedChunks}},
+		Body:          io.NopCloser(body),
+		ContentLength: int64(body.Len()),
+	}, nil
+}
+
+// writeRemoteReadChunkedSeries writes the chunks of the series in frames of at most maxRemoteReadFrameBytes,
+// with the same minor inaccuracy as queriers.
+func writeRemoteReadChunkedSeries(writer *remote.ChunkedWriter, series *prompb.ChunkedSeries, queryIndex int) error {
+	labelsSize := 0
+	for _, l := range series.Labels {
+		labelsSize += l.Size()
+	}
+
+	for start := 0; start < len(series.Chunks); {
+		end := start
+		frameBytesRemaining := maxRemoteReadFrameBytes - labelsSize
+		for end < len(series.Chunks) && (end == start || frameBytesRemaining > 0) {
+			frameBytesRemaining -= series.Chunks[end].Size()
+			end++
+		}
+
+		b, err := (&prompb.ChunkedReadResponse{
+			ChunkedSeries: []*prompb.ChunkedSeries{{Labels: series.Labels, Chunks: series.Chunks[start:end]}},
+			QueryIndex:    int64(queryIndex),
+		}).Marshal()
+		if err != nil {
+			return errors.Wrap(err, "failed to encode remote read response")
+		}
+
+		if _, err := writer.Write(b); err != nil {
+			return errors.Wrap(err, "failed to encode remote read response")
+		}
+
+		start = end
+	}
+
+	return nil
+}
+
+// trimRemoteReadChunks returns the chunks with samples outside the minT and maxT time range removed.
+// Chunks fully within the time range are returned as is, while the ones partially overlapping it are re-encoded.
+func trimRemoteReadChunks(chks []prompb.Chunk, minT, maxT int64) ([]prompb.Chunk, error) {
+	trimmed := make([]prompb.Chunk, 0, len(chks))
+
+	for _, chk := range chks {
+		switch {
+		case chk.MaxTimeMs < minT || chk.MinTimeMs > maxT:
+			continue
+		case chk.MinTimeMs >= minT && chk.MaxTimeMs <= maxT:
+			trimmed = append(trimmed, chk)
+			continue
+		}
+
+		reencoded, err := trimRemoteReadChunk(chk, minT, maxT)
+		if err != nil {
+			return nil, err
+		}
+		trimmed = append(trimmed, reencoded...)
+	}
+
+	return trimmed, nil
+}
+
+func trimRemoteReadChunk(chk prompb.Chunk, minT, maxT int64) ([]prompb.Chunk, error) {
+	encoding := chunkenc.Encoding(chk.Type)
+	orig, err := chunkenc.FromData(encoding, chk.Data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode remote read chunk")
+	}
+
+	var (
+		out        []prompb.Chunk
+		curr       chunkenc.Chunk
+		app        chunkenc.Appender
+		newChk     chunkenc.Chunk
+		recoded    bool
+		mint, maxt int64
+	)
+
+	flush := func() {
+		if curr != nil && curr.NumSamples() > 0 {
+			out = append(out, prompb.Chunk{MinTimeMs: mint, MaxTimeMs: maxt, Type: chk.Type, Data: curr.Bytes()})
+		}
+	}
+
+	it := orig.Iterator(nil)
+	for valType := it.Next(); valType != chunkenc.ValNone; valType = it.Next() {
+		t := it.AtT()
+		if t < minT || t > maxT {
+			continue
+		}
+
+		if curr == nil {
+			if curr, err = chunkenc.NewEmptyChunk(encoding); err != nil {
+				return nil, err
+			}
+			if app, err = curr.Appender(); err != nil {
+				return nil, err
+			}
+			mint = t
+		}
+
+		switch valType {
+		case chunkenc.ValFloat:
+			_, v := it.At()
+			app.Append(t, v)
+		case chunkenc.ValHistogram:
+			_, h := it.AtHistogram(nil)
+			newChk, recoded, app, err = app.AppendHistogram(nil, t, h, false)
+		case chunkenc.ValFloatHistogram:
+			_, fh := it.AtFloatHistogram(nil)
+			newChk, recoded, app, err = app.AppendFloatHistogram(nil, t, fh, false)
+		default:
+			return nil, fmt.Errorf("unsupported value type: %v", valType)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Appending a histogram may have started a new chunk, or recoded the current one.
+		if newChk != nil {
+			if !recoded {
+				flush()
+				mint = t
+			}
+			curr = newChk
+			newChk = nil
+		}
+		maxt = t
+	}
+	if err := it.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to decode remote read chunk")
+	}
+
+	flush()
+	return out, nil
+}
+
+func remoteReadSeriesKey(lbls []prompb.Label) string {
+	b := strings.Builder{}
+	for _, l := range lbls {
+		b.WriteString(l.Name)
+		b.WriteRune(stringParamSeparator)
+		b.WriteString(l.Value)
+		b.WriteRune(stringParamSeparator)
+	}
+	return b.String()
+}
+
+func remoteReadLabels(lbls []prompb.Label) labels.Labels {
+	b := labels.NewScratchBuilder(len(lbls))
+	for _, l := range lbls {
+		b.Add(l.Name, l.Value)
+	}
+	b.Sort()
+	return b.Labels()
+}
+
+// nopFlusher is a http.Flusher doing nothing, used to write STREAMED_XOR_CHUNKS responses to a buffer.
+type nopFlusher struct{}
+
+func (nopFlusher) Flush() {}
+
+// remoteReadCacheKeyGenerator generates the cache key of remote read split queries.
+type remoteReadCacheKeyGenerator struct {
+	limits Limits
+}
+
+func newRemoteReadCacheKeyGenerator(limits Limits) remoteReadCacheKeyGenerator {
+	return remoteReadCacheKeyGenerator{limits: limits}
+}
+
+// cacheKey returns the cache key of a remote read request with a single query. Only queries of completed
+// historical data, which ends before the max cache freshness, are cached.
+func (g remoteReadCacheKeyGenerator) cacheKey(r *http.Request) (*GenericQueryCacheKey, error) {
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	readReq, err := parseRemoteReadRequestWithoutConsumingBody(r)
+	if err != nil {
+		return nil, err
+	}
+	if readReq == nil || len(readReq.Queries) != 1 {
+		return nil, ErrUnsupportedRequest
+	}
+
+	query := readReq.Queries[0]
+	maxCacheFreshness := validation.MaxDurationPerTenant(tenantIDs, g.limits.MaxCacheFreshness)
+	maxCacheTime := int64(model.Now().Add(-maxCacheFreshness))
+	if (&remoteReadQueryRequest{query: query}).GetMaxT() > maxCacheTime {
+		return nil, ErrUnsupportedRequest
+	}
+
+	respType, ok := remoteReadResponseType(readReq.AcceptedResponseTypes)
+	if !ok {
+		return nil, ErrUnsupportedRequest
+	}
+
+	return &GenericQueryCacheKey{
+		CacheKey:       respType.String() + string(stringParamSeparator) + query.String(),
+		CacheKeyPrefix: remoteReadQueryCachePrefix,
+	}, nil
+}
+
+type remoteReadQueryTTL struct {
+	limits Limits
+}
+
+func (c *remoteReadQueryTTL) ttl(userID string) time.Duration {
+	return c.limits.ResultsCacheTTL(userID)
+}
@@ -0,0 +1,401 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/golang/snappy"
+	"github.com/grafana/dskit/cache"
+	"github.com/grafana/dskit/user"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage/remote"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/util/teststorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/querier"
+	"github.com/grafana/mimir/pkg/querier/api"
+	"github.com/grafana/mimir/pkg/util/test"
+)
+
+// remoteReadTestSample is a float or histogram sample decoded from a remote read response.
+type remoteReadTestSample struct {
+	t  int64
+	f  float64
+	h  string
+	fh string
+}
+
+func TestRemoteReadSplitAndCacheRoundTripper(t *testing.T) {
+	// Use a short split interval, so that the test data doesn't trigger the TSDB head compaction.
+	const interval = time.Hour
+
+	const maxCacheFreshness = 10 * time.Minute
+
+	// Store float and native histogram series spanning from 3 split intervals ago until now, with a sample every minute.
+	// If now is within the max cache freshness of the start of its split interval, the data ends in the previous one
+	// instead, so that only the split queries of the last split interval are never cached, regardless of the time
+	// the test runs at.
+	end := time.Now().Truncate(time.Minute)
+	if end.Sub(end.Truncate(interval)) < maxCacheFreshness {
+		end = end.Truncate(interval).Add(-time.Minute)
+	}
+	start := end.Truncate(interval).Add(-3*interval + 10*time.Minute)
+	db := teststorage.New(t)
+	t.Cleanup(func() { _ = db.Close() })
+
+	app := db.Appender(context.Background())
+	for i, ts := 0, start; !ts.After(end); i, ts = i+1, ts.Add(time.Minute) {
+		for _, pod := range []string{"pod-1", "pod-2"} {
+			_, err := app.Append(0, labels.FromStrings(labels.MetricName, "some_metric", "pod", pod), ts.UnixMilli(), float64(i))
+			require.NoError(t, err)
+		}
+		_, err := app.AppendHistogram(0, labels.FromStrings(labels.MetricName, "some_histogram"), ts.UnixMilli(), test.GenerateTestHistogram(i), nil)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+
+	// The downstream is a querier remote read handler.
+	downstreamCalls := atomic.NewInt64(0)
+	handler := querier.RemoteReadHandler(db, log.NewNopLogger())
+	downstream := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		downstreamCalls.Inc()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Result(), nil
+	})
+
+	readRequest := func(respType prompb.ReadRequest_ResponseType) *prompb.ReadRequest {
+		return &prompb.ReadRequest{
+			Queries: []*prompb.Query{
+				{
+					Matchers:         []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: labels.MetricName, Value: "some_metric"}},
+					StartTimestampMs: start.Add(5 * time.Minute).UnixMilli(),
+					EndTimestampMs:   end.UnixMilli(),
+				},
+				{
+					Matchers:         []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: labels.MetricName, Value: "some_histogram"}},
+					StartTimestampMs: start.UnixMilli(),
+					EndTimestampMs:   end.UnixMilli(),
+					Hints:            &prompb.ReadHints{StartMs: start.Add(time.Minute).UnixMilli(), EndMs: end.UnixMilli()},
+				},
+				{
+					// A query within a single split interval.
+					Matchers:         []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: "pod", Value: "pod-1"}},
+					StartTimestampMs: end.Add(-interval).UnixMilli(),
+					EndTimestampMs:   end.Add(-interval).UnixMilli(),
+				},
+			},
+			AcceptedResponseTypes: []prompb.ReadRequest_ResponseType{respType},
+		}
+	}
+
+	for _, respType := range []prompb.ReadRequest_ResponseType{prompb.ReadRequest_SAMPLES, prompb.ReadRequest_STREAMED_XOR_CHUNKS} {
+		t.Run(respType.String(), func(t *testing.T) {
+			ctx := user.InjectOrgID(context.Background(), "user-1")
+			readReq := readRequest(respType)
+
+			// Run the request without splitting it, to get the expected result.
+			downstreamCalls.Store(0)
+			expectedResp, err := downstream.RoundTrip(makeTestHTTPRequestFromRemoteRead(readReq).WithContext(ctx))
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, expectedResp.StatusCode)
+			expected := decodeRemoteReadTestResponse(t, expectedResp, readReq)
+			require.Len(t, expected, len(readReq.Queries))
+			require.Len(t, expected[0], 2)
+			require.Len(t, expected[1], 1)
+			require.Len(t, expected[2], 1)
+
+			reg := prometheus.NewPedanticRegistry()
+			c := cache.NewMockCache()
+			rt := newRemoteReadSplitAndCacheRoundTripper(downstream, interval, 0, mockLimits{maxCacheFreshness: maxCacheFreshness, resultsCacheTTL: time.Hour}, c, log.NewNopLogger(), reg)
+
+			// The first two queries span four split intervals, while the last one isn't split.
+			const expectedSplitQueries = 9
+
+			// Run the request twice: the second time, only the split queries of the last interval, which are
+			// not cached because of the max cache freshness, are executed.
+			for i, expectedDownstreamCalls := range []int64{expectedSplitQueries, 2} {
+				downstreamCalls.Store(0)
+				resp, err := rt.RoundTrip(makeTestHTTPRequestFromRemoteRead(readReq).WithContext(ctx))
+				require.NoError(t, err)
+				require.Equal(t, http.StatusOK, resp.StatusCode)
+				require.Equal(t, expectedResp.Header.Get("Content-Type"), resp.Header.Get("Content-Type"))
+				require.Equal(t, expected, decodeRemoteReadTestResponse(t, resp, readReq), "request #%d", i)
+				require.Equal(t, expectedDownstreamCalls, downstreamCalls.Load(), "request #%d", i)
+			}
+
+			assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+				# HELP cortex_frontend_query_result_cache_hits_total Total number of requests (or partial requests) fetched from the results cache.
+				# TYPE cortex_frontend_query_result_cache_hits_total counter
+				cortex_frontend_query_result_cache_hits_total{request_type="remote_read"} 7
+				# HELP cortex_frontend_split_remote_read_queries_total Total number of underlying remote read queries after the split by interval is applied.
+				# TYPE cortex_frontend_split_remote_read_queries_total counter
+				cortex_frontend_split_remote_read_queries_total 18
+			`), "cortex_frontend_query_result_cache_hits_total", "cortex_frontend_split_remote_read_queries_total"))
+		})
+	}
+}
+
+func TestRemoteReadSplitAndCacheRoundTripper_ShouldNotSplitQueriesWithinASingleInterval(t *testing.T) {
+	var downstreamReq *http.Request
+	downstream := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		downstreamReq = req
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+
+	rt := newRemoteReadSplitAndCacheRoundTripper(downstream, 24*time.Hour, 0, mockLimits{}, nil, log.NewNopLogger(), nil)
+	req := makeTestHTTPRequestFromRemoteRead(makeTestRemoteReadRequest())
+	req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+
+	_, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	require.Same(t, req, downstreamReq)
+}
+
+func TestRemoteReadSplitAndCacheRoundTripper_ShouldReturnTheFailedSplitQueryResponse(t *testing.T) {
+	downstream := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		readReq, err := parseRemoteReadRequestWithoutConsumingBody(req)
+		require.NoError(t, err)
+		require.Len(t, readReq.Queries, 1)
+
+		if readReq.Queries[0].StartTimestampMs >= (24 * time.Hour).Milliseconds() {
+			return &http.Response{StatusCode: http.StatusBadRequest, Body: io.NopCloser(strings.NewReader("the query exceeded a limit"))}, nil
+		}
+		// Return an empty SAMPLES response.
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(snappy.Encode(nil, nil)))}, nil
+	})
+
+	rt := newRemoteReadSplitAndCacheRoundTripper(downstream, 24*time.Hour, 0, mockLimits{maxQueryParallelism: 1}, nil, log.NewNopLogger(), nil)
+	req := makeTestHTTPRequestFromRemoteRead(&prompb.ReadRequest{
+		Queries: []*prompb.Query{{
+			Matchers:         []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: labels.MetricName, Value: "some_metric"}},
+			StartTimestampMs: 0,
+			EndTimestampMs:   (48 * time.Hour).Milliseconds(),
+		}},
+	})
+	req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "the query exceeded a limit", string(body))
+}
+
+func TestRemoteReadSplitAndCacheRoundTripper_ShouldFailIfTheSplitQueryResponsesExceedTheMaxResponseSize(t *testing.T) {
+	// Each split query returns the same response.
+	encoded, err := (&prompb.ReadResponse{Results: []*prompb.QueryResult{{
+		Timeseries: []*prompb.TimeSeries{{
+			Labels:  []prompb.Label{{Name: labels.MetricName, Value: "some_metric"}},
+			Samples: []prompb.Sample{{Timestamp: 0, Value: 1}, {Timestamp: 1, Value: 2}},
+		}},
+	}}}).Marshal()
+	require.NoError(t, err)
+
+	downstream := RoundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(snappy.Encode(nil, encoded)))}, nil
+	})
+
+	tests := map[string]struct {
+		maxResponseSize int
+		expectedErr     bool
+	}{
+		"no limit": {
+			maxResponseSize: 0,
+		},
+		"responses within the limit": {
+			maxResponseSize: 3 * len(encoded),
+		},
+		"responses exceeding the limit": {
+			maxResponseSize: 2*len(encoded) + 1,
+			expectedErr:     true,
+		},
+	}
+
+	for name, testData := range tests {
+		t.Run(name, func(t *testing.T) {
+			rt := newRemoteReadSplitAndCacheRoundTripper(downstream, 24*time.Hour, testData.maxResponseSize, mockLimits{}, nil, log.NewNopLogger(), nil)
+
+			// The query is split in 3 split queries.
+			req := makeTestHTTPRequestFromRemoteRead(&prompb.ReadRequest{
+				Queries: []*prompb.Query{{
+					Matchers:         []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: labels.MetricName, Value: "some_metric"}},
+					StartTimestampMs: 0,
+					EndTimestampMs:   (48 * time.Hour).Milliseconds(),
+				}},
+			})
+			req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+
+			resp, err := rt.RoundTrip(req)
+			if testData.expectedErr {
+				require.Error(t, err)
+				response, ok := apierror.HTTPResponseFromError(err)
+				require.True(t, ok)
+				require.Equal(t, http.StatusRequestEntityTooLarge, int(response.Code))
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+}
+
+func TestTrimRemoteReadChunks(t *testing.T) {
+	floatChunk := chunkenc.NewXORChunk()
+	floatApp, err := floatChunk.Appender()
+	require.NoError(t, err)
+
+	histogramChunk := chunkenc.NewHistogramChunk()
+	var histogramApp chunkenc.Appender
+	histogramApp, err = histogramChunk.Appender()
+	require.NoError(t, err)
+
+	for ts := int64(0); ts < 100; ts += 10 {
+		floatApp.Append(ts, float64(ts))
+		_, _, histogramApp, err = histogramApp.AppendHistogram(nil, ts, test.GenerateTestHistogram(int(ts)), false)
+		require.NoError(t, err)
+	}
+
+	for name, chk := range map[string]prompb.Chunk{
+		"float":     {MinTimeMs: 0, MaxTimeMs: 90, Type: prompb.Chunk_XOR, Data: floatChunk.Bytes()},
+		"histogram": {MinTimeMs: 0, MaxTimeMs: 90, Type: prompb.Chunk_HISTOGRAM, Data: histogramChunk.Bytes()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Chunks within the time range are returned as is.
+			trimmed, err := trimRemoteReadChunks([]prompb.Chunk{chk}, 0, 90)
+			require.NoError(t, err)
+			require.Equal(t, []prompb.Chunk{chk}, trimmed)
+
+			// Chunks outside the time range are removed.
+			trimmed, err = trimRemoteReadChunks([]prompb.Chunk{chk}, 91, 200)
+			require.NoError(t, err)
+			require.Empty(t, trimmed)
+
+			// Chunks partially overlapping the time range are re-encoded.
+			trimmed, err = trimRemoteReadChunks([]prompb.Chunk{chk}, 25, 55)
+			require.NoError(t, err)
+			require.Len(t, trimmed, 1)
+			require.Equal(t, int64(30), trimmed[0].MinTimeMs)
+			require.Equal(t, int64(50), trimmed[0].MaxTimeMs)
+			require.Equal(t, chk.Type, trimmed[0].Type)
+
+			samples := decodeRemoteReadTestChunks(t, trimmed)
+			require.Len(t, samples, 3)
+			for i, s := range samples {
+				require.Equal(t, int64(30+10*i), s.t)
+			}
+		})
+	}
+}
+
+// decodeRemoteReadTestResponse returns the samples of each series returned for each query of a remote read request.
+// Samples outside the queried time range, which may be included in chunks, are filtered out.
+func decodeRemoteReadTestResponse(t *testing.T, resp *http.Response, readReq *prompb.ReadRequest) []map[string][]remoteReadTestSample {
+	t.Helper()
+	defer func() { _ = resp.Body.Close() }()
+
+	results := make([]map[string][]remoteReadTestSample, len(readReq.Queries))
+	for i := range results {
+		results[i] = map[string][]remoteReadTestSample{}
+	}
+
+	if readReq.AcceptedResponseTypes[0] == prompb.ReadRequest_STREAMED_XOR_CHUNKS {
+		require.Equal(t, api.ContentTypeRemoteReadStreamedChunks, resp.Header.Get("Content-Type"))
+
+		reader := remote.NewChunkedReader(resp.Body, remote.DefaultChunkedReadLimit, nil)
+		for {
+			frame := &prompb.ChunkedReadResponse{}
+			err := reader.NextProto(frame)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+
+			query := &remoteReadQueryRequest{query: readReq.Queries[frame.QueryIndex]}
+			for _, series := range frame.ChunkedSeries {
+				key := remoteReadLabels(series.Labels).String()
+				for _, s := range decodeRemoteReadTestChunks(t, series.Chunks) {
+					if s.t >= query.GetMinT() && s.t <= query.GetMaxT() {
+						results[frame.QueryIndex][key] = append(results[frame.QueryIndex][key], s)
+					}
+				}
+			}
+		}
+
+		return results
+	}
+
+	compressed, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	data, err := snappy.Decode(nil, compressed)
+	require.NoError(t, err)
+
+	readResp := &prompb.ReadResponse{}
+	require.NoError(t, readResp.Unmarshal(data))
+	require.Len(t, readResp.Results, len(readReq.Queries))
+
+	for queryIndex, result := range readResp.Results {
+		for _, series := range result.Timeseries {
+			key := remoteReadLabels(series.Labels).String()
+			for _, s := range series.Samples {
+				results[queryIndex][key] = append(results[queryIndex][key], remoteReadTestSample{t: s.Timestamp, f: s.Value})
+			}
+			for _, h := range series.Histograms {
+				if h.IsFloatHistogram() {
+					results[queryIndex][key] = append(results[queryIndex][key], remoteReadTestSample{t: h.Timestamp, fh: h.ToFloatHistogram().String()})
+				} else {
+					results[queryIndex][key] = append(results[queryIndex][key], remoteReadTestSample{t: h.Timestamp, h: h.ToIntHistogram().String()})
+				}
+			}
+		}
+	}
+
+	return results
+}
+
+func decodeRemoteReadTestChunks(t *testing.T, chks []prompb.Chunk) []remoteReadTestSample {
+	var samples []remoteReadTestSample
+
+	for _, chk := range chks {
+		c, err := chunkenc.FromData(chunkenc.Encoding(chk.Type), chk.Data)
+		require.NoError(t, err)
+
+		it := c.Iterator(nil)
+		for valType := it.Next(); valType != chunkenc.ValNone; valType = it.Next() {
+			switch valType {
+			case chunkenc.ValFloat:
+				ts, v := it.At()
+				samples = append(samples, remoteReadTestSample{t: ts, f: v})
+			case chunkenc.ValHistogram:
+				ts, h := it.AtHistogram(nil)
+				samples = append(samples, remoteReadTestSample{t: ts, h: h.String()})
+			case chunkenc.ValFloatHistogram:
+				ts, fh := it.AtFloatHistogram(nil)
+				samples = append(samples, remoteReadTestSample{t: ts, fh: fh.String()})
+			}
+		}
+		require.NoError(t, it.Err())
+	}
+
+	return samples
+}
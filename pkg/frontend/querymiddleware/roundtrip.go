@@ -24,6 +24,7 @@ import (
 	"github.com/prometheus/prometheus/promql/parser"
 	"golang.org/x/exp/slices"
 
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
 	"github.com/grafana/mimir/pkg/util"
 )
 
@@ -58,16 +59,22 @@ var (
 
 // Config for query_range middleware chain.
 type Config struct {
-	SplitQueriesByInterval   time.Duration `yaml:"split_queries_by_interval" category:"advanced"`
-	ResultsCacheConfig       `yaml:"results_cache"`
-	CacheResults             bool          `yaml:"cache_results"`
-	MaxRetries               int           `yaml:"max_retries" category:"advanced"`
-	NotRunningTimeout        time.Duration `yaml:"not_running_timeout" category:"advanced"`
-	ShardedQueries           bool          `yaml:"parallelize_shardable_queries"`
-	TargetSeriesPerShard     uint64        `yaml:"query_sharding_target_series_per_shard" category:"advanced"`
-	ShardActiveSeriesQueries bool          `yaml:"shard_active_series_queries" category:"experimental"`
-	UseActiveSeriesDecoder   bool          `yaml:"use_active_series_decoder" category:"experimental"`
-	RemoteReadLimitsEnabled  bool          `yaml:"remote_read_limits_enabled" category:"experimental"`
+	SplitQueriesByInterval     time.Duration `yaml:"split_queries_by_interval" category:"advanced"`
+	ResultsCacheConfig         `yaml:"results_cache"`
+	CacheResults               bool          `yaml:"cache_results"`
+	MaxRetries                 int           `yaml:"max_retries" category:"advanced"`
+	NotRunningTimeout          time.Duration `yaml:"not_running_timeout" category:"advanced"`
+	ShardedQueries             bool          `yaml:"parallelize_shardable_queries"`
+	TargetSeriesPerShard       uint64        `yaml:"query_sharding_target_series_per_shard" category:"advanced"`
+	ShardActiveSeriesQueries   bool          `yaml:"shard_active_series_queries" category:"experimental"`
+	UseActiveSeriesDecoder     bool          `yaml:"use_active_series_decoder" category:"experimental"`
+	RemoteReadLimitsEnabled    bool          `yaml:"remote_read_limits_enabled" category:"experimental"`
+	SplitRemoteReadQueries     bool          `yaml:"split_remote_read_queries" category:"experimental"`
+	DeduplicateInFlightQueries bool          `yaml:"deduplicate_in_flight_queries" category:"experimental"`
+
+	SplitRemoteReadQueriesMaxResponseSize int `yaml:"split_remote_read_queries_max_response_size" category:"experimental"`
+
+	RewriteQueriesUsingRecordingRules bool `yaml:"rewrite_queries_using_recording_rules" category:"experimental"`
 
 	// CacheKeyGenerator allows to inject a CacheKeyGenerator to use for generating cache keys.
 	// If nil, the querymiddleware package uses a DefaultCacheKeyGenerator with SplitQueriesByInterval.
@@ -81,6 +88,13 @@ type Config struct {
 	ExtraInstantQueryMiddlewares []MetricsQueryMiddleware `yaml:"-"`
 	ExtraRangeQueryMiddlewares   []MetricsQueryMiddleware `yaml:"-"`
 
+	// RecordingRulesStore allows to inject the store of the recording rules used to rewrite queries
+	// when RewriteQueriesUsingRecordingRules is enabled. RecordingRulesEvaluationInterval and
+	// RecordingRulesPollInterval are the ruler's default evaluation interval and rules poll interval.
+	RecordingRulesStore              rulestore.RuleStore `yaml:"-"`
+	RecordingRulesEvaluationInterval time.Duration       `yaml:"-"`
+	RecordingRulesPollInterval       time.Duration       `yaml:"-"`
+
 	QueryResultResponseFormat string `yaml:"query_result_response_format"`
 }
 
@@ -96,6 +110,10 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.ShardActiveSeriesQueries, "query-frontend.shard-active-series-queries", false, "True to enable sharding of active series queries.")
 	f.BoolVar(&cfg.UseActiveSeriesDecoder, "query-frontend.use-active-series-decoder", false, "Set to true to use the zero-allocation response decoder for active series queries.")
 	f.BoolVar(&cfg.RemoteReadLimitsEnabled, "query-frontend.remote-read-limits-enabled", false, "True to enable limits enforcement for remote read requests.")
+	f.BoolVar(&cfg.SplitRemoteReadQueries, "query-frontend.split-remote-read-queries", false, "True to split remote read queries by -query-frontend.split-queries-by-interval and execute them in parallel. The results of split queries are cached if -query-frontend.cache-results is enabled.")
+	f.IntVar(&cfg.SplitRemoteReadQueriesMaxResponseSize, "query-frontend.split-remote-read-queries-max-response-size", 256<<20, "Maximum size, in bytes, of the responses of the split queries of a single remote read request, which are buffered in memory by the query-frontend to stitch them back together. Remote read requests exceeding it fail. 0 to disable the limit.")
+	f.BoolVar(&cfg.DeduplicateInFlightQueries, "query-frontend.deduplicate-in-flight-queries", false, "True to share a single execution between identical range and instant queries received concurrently for the same tenant.")
+	f.BoolVar(&cfg.RewriteQueriesUsingRecordingRules, "query-frontend.rewrite-queries-using-recording-rules", false, "True to rewrite the subexpressions of range and instant queries which match the expression of one of the tenant's recording rules, to select the series recorded by the rule instead. Only the rules of groups evaluated at timestamps aligned on their interval, with no query offset, are used, and only for queries evaluated at the timestamps the rules are evaluated at. Requires the ruler storage to be configured.")
 	cfg.ResultsCacheConfig.RegisterFlags(f)
 }
 
@@ -237,12 +255,22 @@ func newQueryTripperware(
 		cacheKeyGenerator = NewDefaultCacheKeyGenerator(codec, cfg.SplitQueriesByInterval)
 	}
 
-	queryRangeMiddleware, queryInstantMiddleware, remoteReadMiddleware := newQueryMiddlewares(cfg, log, limits, codec, c, cacheKeyGenerator, cacheExtractor, engine, registerer)
+	queryRangeMiddleware, queryInstantMiddleware, remoteReadMiddleware := newQueryMiddlewares(cfg, log, limits, codec, c, cacheKeyGenerator, cacheExtractor, engine, engineOpts.LookbackDelta, registerer)
 
 	return func(next http.RoundTripper) http.RoundTripper {
 		queryrange := newLimitedParallelismRoundTripper(next, codec, limits, queryRangeMiddleware...)
 		instant := newLimitedParallelismRoundTripper(next, codec, limits, queryInstantMiddleware...)
-		remoteRead := newRemoteReadRoundTripper(next, remoteReadMiddleware...)
+		remoteRead := next
+		if cfg.SplitRemoteReadQueries && cfg.SplitQueriesByInterval > 0 {
+			var remoteReadCache cache.Cache
+			if cfg.CacheResults {
+				remoteReadCache = c
+			}
+			remoteRead = newRemoteReadSplitAndCacheRoundTripper(remoteRead, cfg.SplitQueriesByInterval, cfg.SplitRemoteReadQueriesMaxResponseSize, limits, remoteReadCache, log, registerer)
+		}
+		if cfg.RemoteReadLimitsEnabled {
+			remoteRead = newRemoteReadRoundTripper(remoteRead, remoteReadMiddleware...)
+		}
 
 		// Wrap next for cardinality, labels queries and all other queries.
 		// That attempts to parse "start" and "end" from the HTTP request and set them in the request's QueryDetails.
@@ -278,7 +306,7 @@ func newQueryTripperware(
 				return activeNativeHistogramMetrics.RoundTrip(r)
 			case IsLabelsQuery(r.URL.Path):
 				return labels.RoundTrip(r)
-			case IsRemoteReadQuery(r.URL.Path) && cfg.RemoteReadLimitsEnabled:
+			case IsRemoteReadQuery(r.URL.Path) && (cfg.RemoteReadLimitsEnabled || cfg.SplitRemoteReadQueries):
 				return remoteRead.RoundTrip(r)
 			default:
 				return next.RoundTrip(r)
@@ -298,6 +326,7 @@ func newQueryMiddlewares(
 	cacheKeyGenerator CacheKeyGenerator,
 	cacheExtractor Extractor,
 	engine *promql.Engine,
+	lookbackDelta time.Duration,
 	registerer prometheus.Registerer,
 ) (queryRangeMiddleware, queryInstantMiddleware, remoteReadMiddleware []MetricsQueryMiddleware) {
 	// Metric used to keep track of each middleware execution duration.
@@ -320,6 +349,32 @@ func newQueryMiddlewares(
 		newStepAlignMiddleware(limits, log, registerer),
 	)
 
+	// Inject the middleware to rewrite queries using recording rules after queries have been checked against the
+	// blocked queries, and before any middleware which relies on the query expression, so that the rewritten query
+	// is the one estimated, split, cached and sharded.
+	var recordingRulesMiddleware MetricsQueryMiddleware
+	if cfg.RewriteQueriesUsingRecordingRules && cfg.RecordingRulesStore != nil {
+		recordingRulesMiddleware = newRecordingRulesMiddleware(cfg.RecordingRulesStore, cfg.RecordingRulesEvaluationInterval, cfg.RecordingRulesPollInterval, lookbackDelta, limits, log, registerer)
+		queryRangeMiddleware = append(queryRangeMiddleware, newInstrumentMiddleware("recording_rules", metrics), recordingRulesMiddleware)
+	}
+
+	// Inject the middleware to estimate the cost of queries, and reject expensive queries. The estimates of the number
+	// of series selected by queries are only available if they're tracked by the cardinality estimation middleware.
+	var cardinalityEstimationCache cache.Cache
+	if cfg.ShardedQueries && cfg.cardinalityBasedShardingEnabled() {
+		cardinalityEstimationCache = cacheClient
+	}
+	queryCostMiddleware := newQueryCostMiddleware(cardinalityEstimationCache, cfg.SplitQueriesByInterval, limits, log, registerer)
+	queryRangeMiddleware = append(queryRangeMiddleware, newInstrumentMiddleware("query_cost", metrics), queryCostMiddleware)
+
+	// Inject the middleware to deduplicate in-flight queries after the request has been step aligned, so that queries
+	// only differing by their alignment can be deduplicated, and before it's split and looked up in the results cache.
+	var deduplicationMiddleware MetricsQueryMiddleware
+	if cfg.DeduplicateInFlightQueries {
+		deduplicationMiddleware = newDeduplicationMiddleware(log, registerer)
+		queryRangeMiddleware = append(queryRangeMiddleware, newInstrumentMiddleware("deduplication", metrics), deduplicationMiddleware)
+	}
+
 	// Inject the middleware to split requests by interval + results cache (if at least one of the two is enabled).
 	if cfg.SplitQueriesByInterval > 0 || cfg.CacheResults {
 		queryRangeMiddleware = append(queryRangeMiddleware, newInstrumentMiddleware("split_by_interval_and_results_cache", metrics), newSplitAndCacheMiddleware(
@@ -345,6 +400,18 @@ func newQueryMiddlewares(
 		queryBlockerMiddleware,
 	)
 
+	// Instant queries split by interval can't be rewritten, because their subexpressions have been embedded
+	// into the split query.
+	if recordingRulesMiddleware != nil {
+		queryInstantMiddleware = append(queryInstantMiddleware, newInstrumentMiddleware("recording_rules", metrics), recordingRulesMiddleware)
+	}
+
+	queryInstantMiddleware = append(queryInstantMiddleware, newInstrumentMiddleware("query_cost", metrics), queryCostMiddleware)
+
+	if cfg.DeduplicateInFlightQueries {
+		queryInstantMiddleware = append(queryInstantMiddleware, newInstrumentMiddleware("deduplication", metrics), deduplicationMiddleware)
+	}
+
 	// Inject the extra middlewares provided by the user before the query sharding middleware.
 	if len(cfg.ExtraInstantQueryMiddlewares) > 0 {
 		queryInstantMiddleware = append(queryInstantMiddleware, cfg.ExtraInstantQueryMiddlewares...)
@@ -531,10 +531,17 @@ func TestMiddlewaresConsistency(t *testing.T) {
 	cfg := makeTestConfig()
 	cfg.CacheResults = true
 	cfg.ShardedQueries = true
+	cfg.DeduplicateInFlightQueries = true
+	cfg.TargetSeriesPerShard = 1000
+	cfg.RewriteQueriesUsingRecordingRules = true
+	cfg.RecordingRulesStore = newMockRecordingRulesStore(nil, time.Time{})
 
 	// Ensure all features are enabled, so that we assert on all middlewares.
 	require.NotZero(t, cfg.CacheResults)
 	require.NotZero(t, cfg.ShardedQueries)
+	require.NotZero(t, cfg.DeduplicateInFlightQueries)
+	require.NotZero(t, cfg.RewriteQueriesUsingRecordingRules)
+	require.NotZero(t, cfg.TargetSeriesPerShard)
 	require.NotZero(t, cfg.SplitQueriesByInterval)
 	require.NotZero(t, cfg.MaxRetries)
 
@@ -549,6 +556,7 @@ func TestMiddlewaresConsistency(t *testing.T) {
 		nil,
 		nil,
 		promql.NewEngine(promql.EngineOpts{}),
+		0,
 		nil,
 	)
 
@@ -567,8 +575,12 @@ func TestMiddlewaresConsistency(t *testing.T) {
 		"remote read": {
 			instances: remoteReadMiddlewares,
 			exceptions: []string{
+				"deduplicationMiddleware", // No deduplication of in-flight requests.
 				"instrumentMiddleware",
-				"querySharding", // No query sharding support.
+				"cardinalityEstimation",    // No query sharding support.
+				"queryCostMiddleware",      // Relies on the cardinality estimation.
+				"querySharding",            // No query sharding support.
+				"recordingRulesMiddleware", // Remote read requests have no PromQL expression to rewrite.
 				"retry",
 				"splitAndCacheMiddleware",               // No time splitting and results cache support.
 				"splitInstantQueryByIntervalMiddleware", // Not applicable because specific to instant queries.
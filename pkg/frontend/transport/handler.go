@@ -83,6 +83,7 @@ type Handler struct {
 	queryChunkBytes *prometheus.CounterVec
 	queryChunks     *prometheus.CounterVec
 	queryIndexBytes *prometheus.CounterVec
+	querySamples    *prometheus.CounterVec
 	activeUsers     *util.ActiveUsersCleanupService
 
 	mtx              sync.Mutex
@@ -128,6 +129,11 @@ func NewHandler(cfg HandlerConfig, roundTripper http.RoundTripper, log log.Logge
 			Help: "Number of TSDB index bytes fetched from store-gateway to execute a query.",
 		}, []string{"user"})
 
+		h.querySamples = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_query_samples_processed_total",
+			Help: "Number of samples processed by the query engine to execute a query.",
+		}, []string{"user"})
+
 		h.activeUsers = util.NewActiveUsersCleanupWithDefaultValues(func(user string) {
 			h.querySeconds.DeleteLabelValues(user, "true")
 			h.querySeconds.DeleteLabelValues(user, "false")
@@ -135,6 +141,7 @@ func NewHandler(cfg HandlerConfig, roundTripper http.RoundTripper, log log.Logge
 			h.queryChunkBytes.DeleteLabelValues(user)
 			h.queryChunks.DeleteLabelValues(user)
 			h.queryIndexBytes.DeleteLabelValues(user)
+			h.querySamples.DeleteLabelValues(user)
 		})
 		// If cleaner stops or fail, we will simply not clean the metrics for inactive users.
 		_ = h.activeUsers.StartAsync(context.Background())
@@ -299,6 +306,7 @@ func (f *Handler) reportQueryStats(
 	numBytes := stats.LoadFetchedChunkBytes()
 	numChunks := stats.LoadFetchedChunks()
 	numIndexBytes := stats.LoadFetchedIndexBytes()
+	numSamples := stats.LoadSamplesProcessed()
 	sharded := strconv.FormatBool(stats.GetShardedQueries() > 0)
 
 	if stats != nil {
@@ -308,6 +316,7 @@ func (f *Handler) reportQueryStats(
 		f.queryChunkBytes.WithLabelValues(userID).Add(float64(numBytes))
 		f.queryChunks.WithLabelValues(userID).Add(float64(numChunks))
 		f.queryIndexBytes.WithLabelValues(userID).Add(float64(numIndexBytes))
+		f.querySamples.WithLabelValues(userID).Add(float64(numSamples))
 		f.activeUsers.UpdateUserTimestamp(userID, time.Now())
 	}
 
@@ -331,6 +340,8 @@ func (f *Handler) reportQueryStats(
 		"split_queries", stats.LoadSplitQueries(),
 		"estimated_series_count", stats.GetEstimatedSeriesCount(),
 		"queue_time_seconds", stats.LoadQueueTime().Seconds(),
+		"samples_processed", numSamples,
+		"peak_samples", stats.LoadPeakSamples(),
 	}, formatQueryString(details, queryString)...)
 
 	if details != nil {
@@ -351,6 +362,10 @@ func (f *Handler) reportQueryStats(
 		)
 	}
 
+	if operatorStats := stats.LoadOperatorStats(); len(operatorStats) > 0 {
+		logMessage = append(logMessage, "operator_stats", formatOperatorStats(operatorStats))
+	}
+
 	// Log the read consistency only when explicitly defined.
 	if consistency, ok := querierapi.ReadConsistencyFromContext(r.Context()); ok {
 		logMessage = append(logMessage, "read_consistency", consistency)
@@ -429,6 +444,23 @@ func filterHeadersToLog(headersToLog []string) (filtered []string) {
 	return filtered
 }
 
+// formatOperatorStats formats the statistics for each operator as a single string, with one entry per operator
+// separated by semicolons.
+func formatOperatorStats(operatorStats []querier_stats.OperatorStats) string {
+	b := strings.Builder{}
+
+	for i, o := range operatorStats {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+
+		fmt.Fprintf(&b, "%s %q wall_time=%s input_series=%d output_series=%d samples_processed=%d peak_estimated_memory_consumption_bytes=%d",
+			o.Position, o.Operator, o.WallTime, o.InputSeries, o.OutputSeries, o.SamplesProcessed, o.PeakEstimatedMemoryConsumptionBytes)
+	}
+
+	return b.String()
+}
+
 func formatRequestHeaders(h *http.Header, headersToLog []string) (fields []any) {
 	fields = append(fields, cacheControlLogField, h.Get(cacheControlHeader))
 	for _, s := range headersToLog {
@@ -39,6 +39,7 @@ import (
 	apierror "github.com/grafana/mimir/pkg/api/error"
 	"github.com/grafana/mimir/pkg/frontend/querymiddleware"
 	"github.com/grafana/mimir/pkg/querier/api"
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/util/activitytracker"
 )
 
@@ -114,7 +115,7 @@ func TestHandler_ServeHTTP(t *testing.T) {
 				"query": []string{"some_metric"},
 				"time":  []string{"42"},
 			},
-			expectedMetrics:         5,
+			expectedMetrics:         6,
 			expectedActivity:        "user:12345 UA:test-user-agent req:POST /api/v1/query query=some_metric&time=42",
 			expectedReadConsistency: "",
 		},
@@ -132,7 +133,7 @@ func TestHandler_ServeHTTP(t *testing.T) {
 				"query": []string{"some_metric"},
 				"time":  []string{"42"},
 			},
-			expectedMetrics:         5,
+			expectedMetrics:         6,
 			expectedActivity:        "user:12345 UA:test-user-agent req:GET /api/v1/query query=some_metric&time=42",
 			expectedReadConsistency: "",
 		},
@@ -150,7 +151,7 @@ func TestHandler_ServeHTTP(t *testing.T) {
 				"query": []string{"some_metric"},
 				"time":  []string{"42"},
 			},
-			expectedMetrics:         5,
+			expectedMetrics:         6,
 			expectedActivity:        "user:12345 UA:test-user-agent req:GET /api/v1/query query=some_metric&time=42",
 			expectedReadConsistency: api.ReadConsistencyStrong,
 		},
@@ -165,7 +166,7 @@ func TestHandler_ServeHTTP(t *testing.T) {
 			downstreamResponse:      makeSuccessfulDownstreamResponse(),
 			expectedStatusCode:      200,
 			expectedParams:          url.Values{},
-			expectedMetrics:         5,
+			expectedMetrics:         6,
 			expectedActivity:        "user:12345 UA:test-user-agent req:GET /api/v1/query (no params)",
 			expectedReadConsistency: "",
 		},
@@ -224,7 +225,7 @@ func TestHandler_ServeHTTP(t *testing.T) {
 			},
 			downstreamResponse: makeSuccessfulDownstreamResponse(),
 			expectedActivity:   "user:12345 UA:test-user-agent req:GET /api/v1/read end_0=42&end_1=20&hints_1=%7B%22step_ms%22%3A1000%7D&matchers_0=%7B__name__%3D%22some_metric%22%2Cfoo%3D~%22.%2Abar.%2A%22%7D&matchers_1=%7B__name__%3D%22up%22%7D&start_0=0&start_1=10",
-			expectedMetrics:    5,
+			expectedMetrics:    6,
 			expectedStatusCode: 200,
 			expectedParams: url.Values{
 				"matchers_0": []string{"{__name__=\"some_metric\",foo=~\".*bar.*\"}"},
@@ -248,7 +249,7 @@ func TestHandler_ServeHTTP(t *testing.T) {
 				"query": []string{"some_metric"},
 				"time":  []string{"42"},
 			},
-			expectedMetrics:         5,
+			expectedMetrics:         6,
 			expectedActivity:        "user:12345 UA: req:GET /api/v1/query query=some_metric&time=42",
 			expectedReadConsistency: "",
 		},
@@ -264,7 +265,7 @@ func TestHandler_ServeHTTP(t *testing.T) {
 				"query": []string{"some_metric"},
 				"time":  []string{"42"},
 			},
-			expectedMetrics:         5,
+			expectedMetrics:         6,
 			expectedActivity:        "user:12345 UA: req:GET /api/v1/query query=some_metric&time=42",
 			expectedReadConsistency: "",
 		},
@@ -280,7 +281,7 @@ func TestHandler_ServeHTTP(t *testing.T) {
 				"query": []string{"some_metric"},
 				"time":  []string{"42"},
 			},
-			expectedMetrics:         5,
+			expectedMetrics:         6,
 			expectedActivity:        "user:12345 UA: req:GET /api/v1/query query=some_metric&time=42",
 			expectedReadConsistency: "",
 		},
@@ -327,6 +328,7 @@ func TestHandler_ServeHTTP(t *testing.T) {
 				"cortex_query_fetched_chunk_bytes_total",
 				"cortex_query_fetched_chunks_total",
 				"cortex_query_fetched_index_bytes_total",
+				"cortex_query_samples_processed_total",
 			)
 
 			assert.NoError(t, err)
@@ -359,6 +361,8 @@ func TestHandler_ServeHTTP(t *testing.T) {
 				require.EqualValues(t, 0, msg["split_queries"])
 				require.EqualValues(t, 0, msg["estimated_series_count"])
 				require.EqualValues(t, 0, msg["queue_time_seconds"])
+				require.EqualValues(t, 0, msg["samples_processed"])
+				require.EqualValues(t, 0, msg["peak_samples"])
 
 				if tt.expectedStatusCode >= 200 && tt.expectedStatusCode < 300 {
 					require.Equal(t, "success", msg["status"])
@@ -427,7 +431,7 @@ func TestHandler_FailedRoundTrip(t *testing.T) {
 				return nil, context.Canceled
 			},
 			expectedStatusCode:  StatusClientClosedRequest,
-			expectedMetrics:     5,
+			expectedMetrics:     6,
 			expectedStatusLog:   "canceled",
 			expectQueryParamLog: false,
 		},
@@ -442,7 +446,7 @@ func TestHandler_FailedRoundTrip(t *testing.T) {
 				}, nil
 			},
 			expectedStatusCode:  http.StatusInternalServerError,
-			expectedMetrics:     5,
+			expectedMetrics:     6,
 			expectedStatusLog:   "failed",
 			expectQueryParamLog: false,
 		},
@@ -468,6 +472,7 @@ func TestHandler_FailedRoundTrip(t *testing.T) {
 				"cortex_query_fetched_chunk_bytes_total",
 				"cortex_query_fetched_chunks_total",
 				"cortex_query_fetched_index_bytes_total",
+				"cortex_query_samples_processed_total",
 			)
 			require.NoError(t, err)
 
@@ -864,3 +869,15 @@ func TestFormatRequestHeaders(t *testing.T) {
 
 	assert.Equal(t, expected, fields)
 }
+
+func TestFormatOperatorStats(t *testing.T) {
+	operatorStats := []querier_stats.OperatorStats{
+		{Position: "0", Operator: "Aggregation: sum by (env)", WallTime: 2 * time.Millisecond, InputSeries: 3, OutputSeries: 2, SamplesProcessed: 18, PeakEstimatedMemoryConsumptionBytes: 1024},
+		{Position: "0.0", Operator: "InstantVectorSelector", WallTime: 5 * time.Millisecond, OutputSeries: 3, SamplesProcessed: 18, PeakEstimatedMemoryConsumptionBytes: 512},
+	}
+
+	expected := `0 "Aggregation: sum by (env)" wall_time=2ms input_series=3 output_series=2 samples_processed=18 peak_estimated_memory_consumption_bytes=1024; ` +
+		`0.0 "InstantVectorSelector" wall_time=5ms input_series=0 output_series=3 samples_processed=18 peak_estimated_memory_consumption_bytes=512`
+
+	assert.Equal(t, expected, formatOperatorStats(operatorStats))
+}
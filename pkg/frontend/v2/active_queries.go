@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package v2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/concurrency"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/mimir/pkg/scheduler/schedulerpb"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+const (
+	activeQueryStateQueued    = "queued"
+	activeQueryStateExecuting = "executing"
+
+	// activeQueryIDSeparator separates the query ID from the address of the query-frontend
+	// which enqueued the query in the ID of an active query.
+	activeQueryIDSeparator = "@"
+)
+
+var errInvalidActiveQueryID = errors.New("invalid active query ID")
+
+// ActiveQuery is a query queued in a query-scheduler or being executed by a querier.
+//
+// Queries split or sharded by the query-frontend are made of multiple active queries, and canceling any of them
+// fails the whole query.
+type ActiveQuery struct {
+	// ID identifies the query across all query-frontends.
+	ID     string `json:"id"`
+	Tenant string `json:"tenant"`
+	Path   string `json:"path"`
+	Query  string `json:"query,omitempty"`
+	State  string `json:"state"`
+
+	// StartTime is when the query was enqueued in the query-scheduler.
+	StartTime time.Time `json:"startTime"`
+
+	// Querier and QueueTimeSeconds are only set once the query is being executed by a querier.
+	Querier          string  `json:"querier,omitempty"`
+	QueueTimeSeconds float64 `json:"queueTimeSeconds,omitempty"`
+}
+
+type activeQueriesResponse struct {
+	Status string        `json:"status"`
+	Data   []ActiveQuery `json:"data"`
+}
+
+// ActiveQueries returns the queries of the tenant that are currently queued or being executed, across all
+// query-frontends connected to the same query-schedulers as this query-frontend.
+func (f *Frontend) ActiveQueries(ctx context.Context, userID string) ([]ActiveQuery, error) {
+	clients := f.schedulerWorkers.getSchedulerClients()
+	addrs := make([]string, 0, len(clients))
+	for addr := range clients {
+		addrs = append(addrs, addr)
+	}
+
+	var (
+		mtx     sync.Mutex
+		queries []ActiveQuery
+	)
+
+	err := concurrency.ForEachJob(ctx, len(addrs), len(addrs), func(ctx context.Context, idx int) error {
+		resp, err := clients[addrs[idx]].ListActiveQueries(ctx, &schedulerpb.ListActiveQueriesRequest{UserID: userID})
+		if err != nil {
+			return errors.Wrapf(err, "failed to list active queries from query-scheduler %s", addrs[idx])
+		}
+
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		for _, q := range resp.Queries {
+			queries = append(queries, activeQueryFromProto(q))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Oldest queries first.
+	sort.Slice(queries, func(i, j int) bool {
+		return queries[i].StartTime.Before(queries[j].StartTime)
+	})
+
+	return queries, nil
+}
+
+func activeQueryFromProto(q *schedulerpb.ActiveQuery) ActiveQuery {
+	query := ActiveQuery{
+		ID:        formatActiveQueryID(q.FrontendAddress, q.QueryID),
+		Tenant:    q.UserID,
+		Path:      q.Path,
+		Query:     q.Query,
+		State:     activeQueryStateQueued,
+		StartTime: time.Unix(0, q.EnqueueTimeUnixNanos).UTC(),
+	}
+
+	if q.QuerierID != "" {
+		query.State = activeQueryStateExecuting
+		query.Querier = q.QuerierID
+		query.QueueTimeSeconds = time.Duration(q.QueueTimeNanos).Seconds()
+	}
+
+	return query
+}
+
+// CancelActiveQuery cancels the tenant's active query with the given ID, if it is still queued or being executed.
+// It returns false if no such query was found.
+func (f *Frontend) CancelActiveQuery(ctx context.Context, userID, id string) (bool, error) {
+	frontendAddress, queryID, err := parseActiveQueryID(id)
+	if err != nil {
+		return false, err
+	}
+
+	req := &schedulerpb.CancelActiveQueryRequest{
+		UserID:          userID,
+		QueryID:         queryID,
+		FrontendAddress: frontendAddress,
+	}
+
+	clients := f.schedulerWorkers.getSchedulerClients()
+	addrs := make([]string, 0, len(clients))
+	for addr := range clients {
+		addrs = append(addrs, addr)
+	}
+
+	var (
+		mtx      sync.Mutex
+		canceled bool
+	)
+
+	// The query-frontend doesn't know which query-scheduler the query was enqueued to, so we ask all of them.
+	err = concurrency.ForEachJob(ctx, len(addrs), len(addrs), func(ctx context.Context, idx int) error {
+		resp, err := clients[addrs[idx]].CancelActiveQuery(ctx, req)
+		if err != nil {
+			return errors.Wrapf(err, "failed to cancel active query in query-scheduler %s", addrs[idx])
+		}
+
+		mtx.Lock()
+		canceled = canceled || resp.Canceled
+		mtx.Unlock()
+		return nil
+	})
+
+	return canceled, err
+}
+
+func formatActiveQueryID(frontendAddress string, queryID uint64) string {
+	return strconv.FormatUint(queryID, 10) + activeQueryIDSeparator + frontendAddress
+}
+
+func parseActiveQueryID(id string) (string, uint64, error) {
+	rawQueryID, frontendAddress, ok := strings.Cut(id, activeQueryIDSeparator)
+	if !ok || frontendAddress == "" {
+		return "", 0, errInvalidActiveQueryID
+	}
+
+	queryID, err := strconv.ParseUint(rawQueryID, 10, 64)
+	if err != nil {
+		return "", 0, errInvalidActiveQueryID
+	}
+
+	return frontendAddress, queryID, nil
+}
+
+// ActiveQueriesHandler lists the tenant's queries that are currently queued or being executed.
+func (f *Frontend) ActiveQueriesHandler(w http.ResponseWriter, r *http.Request) {
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	queries, err := f.ActiveQueries(r.Context(), tenant.JoinTenantIDs(tenantIDs))
+	if err != nil {
+		level.Warn(f.log).Log("msg", "failed to list active queries", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if queries == nil {
+		queries = []ActiveQuery{}
+	}
+
+	util.WriteJSONResponse(w, activeQueriesResponse{Status: "success", Data: queries})
+}
+
+// CancelActiveQueryHandler cancels one of the tenant's queries that is currently queued or being executed.
+// The query is canceled in the querier executing it, including any request the querier has in progress to
+// ingesters and store-gateways, and the client which issued the query receives an error.
+func (f *Frontend) CancelActiveQueryHandler(w http.ResponseWriter, r *http.Request) {
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	canceled, err := f.CancelActiveQuery(r.Context(), tenant.JoinTenantIDs(tenantIDs), id)
+	switch {
+	case errors.Is(err, errInvalidActiveQueryID):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil && !canceled:
+		level.Warn(f.log).Log("msg", "failed to cancel active query", "id", id, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	case !canceled:
+		http.Error(w, fmt.Sprintf("active query %s not found", id), http.StatusNotFound)
+		return
+	}
+
+	level.Info(f.log).Log("msg", "canceled active query", "id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package v2
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/scheduler/schedulerpb"
+)
+
+func TestFrontend_ActiveQueriesHandler(t *testing.T) {
+	f, ms := setupFrontend(t, nil, nil)
+
+	enqueueTime := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	ms.checkWithLock(func() {
+		ms.activeQueries = []*schedulerpb.ActiveQuery{
+			{
+				QueryID:              2,
+				FrontendAddress:      "frontend-1:9095",
+				UserID:               "user-1",
+				Path:                 "/prometheus/api/v1/query_range",
+				Query:                "sum(rate(some_metric[5m]))",
+				EnqueueTimeUnixNanos: enqueueTime.Add(time.Second).UnixNano(),
+				QuerierID:            "querier-1",
+				QueueTimeNanos:       (500 * time.Millisecond).Nanoseconds(),
+			},
+			{
+				QueryID:              1,
+				FrontendAddress:      "frontend-2:9095",
+				UserID:               "user-1",
+				Path:                 "/prometheus/api/v1/query",
+				Query:                "up",
+				EnqueueTimeUnixNanos: enqueueTime.UnixNano(),
+			},
+			{
+				QueryID:              3,
+				FrontendAddress:      "frontend-1:9095",
+				UserID:               "user-2",
+				Path:                 "/prometheus/api/v1/query",
+				Query:                "up",
+				EnqueueTimeUnixNanos: enqueueTime.UnixNano(),
+			},
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/prometheus/api/v1/active_queries", nil)
+	req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+	rec := httptest.NewRecorder()
+	f.ActiveQueriesHandler(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp activeQueriesResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, "success", resp.Status)
+	require.Equal(t, []ActiveQuery{
+		{
+			ID:        "1@frontend-2:9095",
+			Tenant:    "user-1",
+			Path:      "/prometheus/api/v1/query",
+			Query:     "up",
+			State:     activeQueryStateQueued,
+			StartTime: enqueueTime,
+		},
+		{
+			ID:               "2@frontend-1:9095",
+			Tenant:           "user-1",
+			Path:             "/prometheus/api/v1/query_range",
+			Query:            "sum(rate(some_metric[5m]))",
+			State:            activeQueryStateExecuting,
+			StartTime:        enqueueTime.Add(time.Second),
+			Querier:          "querier-1",
+			QueueTimeSeconds: 0.5,
+		},
+	}, resp.Data)
+}
+
+func TestFrontend_CancelActiveQueryHandler(t *testing.T) {
+	f, ms := setupFrontend(t, nil, nil)
+	ms.checkWithLock(func() {
+		ms.activeQueries = []*schedulerpb.ActiveQuery{
+			{QueryID: 1, FrontendAddress: "frontend-1:9095", UserID: "user-1"},
+		}
+	})
+
+	tests := map[string]struct {
+		userID       string
+		id           string
+		expectedCode int
+	}{
+		"query found": {
+			userID:       "user-1",
+			id:           "1@frontend-1:9095",
+			expectedCode: http.StatusNoContent,
+		},
+		"query not found": {
+			userID:       "user-1",
+			id:           "2@frontend-1:9095",
+			expectedCode: http.StatusNotFound,
+		},
+		"query belongs to another tenant": {
+			userID:       "user-2",
+			id:           "1@frontend-1:9095",
+			expectedCode: http.StatusNotFound,
+		},
+		"invalid ID": {
+			userID:       "user-1",
+			id:           "frontend-1:9095",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/prometheus/api/v1/active_queries/"+tc.id, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tc.id})
+			req = req.WithContext(user.InjectOrgID(req.Context(), tc.userID))
+			rec := httptest.NewRecorder()
+			f.CancelActiveQueryHandler(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+		})
+	}
+
+	ms.checkWithLock(func() {
+		require.Len(t, ms.cancelRequests, 3)
+		require.Contains(t, ms.cancelRequests, &schedulerpb.CancelActiveQueryRequest{UserID: "user-1", QueryID: 1, FrontendAddress: "frontend-1:9095"})
+	})
+}
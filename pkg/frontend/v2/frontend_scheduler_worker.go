@@ -217,6 +217,19 @@ func (f *frontendSchedulerWorkers) getWorkersCount() int {
 	return len(f.workers)
 }
 
+// getSchedulerClients returns a client for each query-scheduler the query-frontend is connected to, keyed by address.
+func (f *frontendSchedulerWorkers) getSchedulerClients() map[string]schedulerpb.SchedulerForFrontendClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	clients := make(map[string]schedulerpb.SchedulerForFrontendClient, len(f.workers))
+	for addr, w := range f.workers {
+		clients[addr] = schedulerpb.NewSchedulerForFrontendClient(w.conn)
+	}
+
+	return clients
+}
+
 func (f *frontendSchedulerWorkers) connectToScheduler(ctx context.Context, address string) (*grpc.ClientConn, error) {
 	// Because we only use single long-running method, it doesn't make sense to inject user ID, send over tracing or add metrics.
 	opts, err := f.cfg.GRPCClientConfig.DialOption(nil, nil)
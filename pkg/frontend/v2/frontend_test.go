@@ -578,6 +578,9 @@ type mockScheduler struct {
 	mu           sync.Mutex
 	frontendAddr map[string]int
 	msgs         []*schedulerpb.FrontendToScheduler
+
+	activeQueries  []*schedulerpb.ActiveQuery
+	cancelRequests []*schedulerpb.CancelActiveQueryRequest
 }
 
 func newMockScheduler(t *testing.T, f *Frontend, replyFunc func(f *Frontend, msg *schedulerpb.FrontendToScheduler) *schedulerpb.SchedulerToFrontend) *mockScheduler {
@@ -627,6 +630,32 @@ func (m *mockScheduler) FrontendLoop(frontend schedulerpb.SchedulerForFrontend_F
 	}
 }
 
+func (m *mockScheduler) ListActiveQueries(_ context.Context, req *schedulerpb.ListActiveQueriesRequest) (*schedulerpb.ListActiveQueriesResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resp := &schedulerpb.ListActiveQueriesResponse{}
+	for _, q := range m.activeQueries {
+		if q.UserID == req.UserID {
+			resp.Queries = append(resp.Queries, q)
+		}
+	}
+	return resp, nil
+}
+
+func (m *mockScheduler) CancelActiveQuery(_ context.Context, req *schedulerpb.CancelActiveQueryRequest) (*schedulerpb.CancelActiveQueryResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cancelRequests = append(m.cancelRequests, req)
+	for _, q := range m.activeQueries {
+		if q.UserID == req.UserID && q.QueryID == req.QueryID && q.FrontendAddress == req.FrontendAddress {
+			return &schedulerpb.CancelActiveQueryResponse{Canceled: true}, nil
+		}
+	}
+	return &schedulerpb.CancelActiveQueryResponse{Canceled: false}, nil
+}
+
 func TestConfig_Validate(t *testing.T) {
 	tests := map[string]struct {
 		setup       func(cfg *Config)
@@ -761,6 +761,8 @@ func New(cfg Config, reg prometheus.Registerer) (*Mimir, error) {
 			"/frontend.Frontend/NotifyClientShutdown",
 			"/ruler.Ruler/SyncRules",
 			"/schedulerpb.SchedulerForFrontend/FrontendLoop",
+			"/schedulerpb.SchedulerForFrontend/ListActiveQueries",
+			"/schedulerpb.SchedulerForFrontend/CancelActiveQuery",
 			"/schedulerpb.SchedulerForQuerier/QuerierLoop",
 			"/schedulerpb.SchedulerForQuerier/NotifyQuerierShutdown",
 		})
@@ -769,6 +769,7 @@ func TestGrpcAuthMiddleware(t *testing.T) {
 		require.NoError(t, err)
 
 		schedulerpb.RegisterSchedulerForQuerierServer(c.Server.GRPC, msch)
+		schedulerpb.RegisterSchedulerForFrontendServer(c.Server.GRPC, msch)
 		frontendv1pb.RegisterFrontendServer(c.Server.GRPC, msch)
 		ruler.RegisterRulerServer(c.Server.GRPC, msch)
 
@@ -810,6 +811,19 @@ func TestGrpcAuthMiddleware(t *testing.T) {
 		require.NoError(t, err)
 		require.True(t, msch.rulerSyncRulesCalled.Load())
 	}
+	{
+		// Verify that we can call schedulerClient.ListActiveQueries and schedulerClient.CancelActiveQuery without user in the context,
+		// and we don't get any error. The tenant is carried by the request itself.
+		require.False(t, msch.listActiveQueriesCalled.Load())
+		require.False(t, msch.cancelActiveQueryCalled.Load())
+		schedulerClient := schedulerpb.NewSchedulerForFrontendClient(conn)
+		_, err = schedulerClient.ListActiveQueries(ctx, &schedulerpb.ListActiveQueriesRequest{UserID: "random-user-id"})
+		require.NoError(t, err)
+		_, err = schedulerClient.CancelActiveQuery(ctx, &schedulerpb.CancelActiveQueryRequest{UserID: "random-user-id", QueryID: 1, FrontendAddress: "random-frontend"})
+		require.NoError(t, err)
+		require.True(t, msch.listActiveQueriesCalled.Load())
+		require.True(t, msch.cancelActiveQueryCalled.Load())
+	}
 }
 
 func TestFlagDefaults(t *testing.T) {
@@ -1000,9 +1014,11 @@ func getHostnameAndRandomPort(t *testing.T) (string, int) {
 }
 
 type mockGrpcServiceHandler struct {
-	clientShutdownCalled  atomic.Bool
-	querierShutdownCalled atomic.Bool
-	rulerSyncRulesCalled  atomic.Bool
+	clientShutdownCalled    atomic.Bool
+	querierShutdownCalled   atomic.Bool
+	rulerSyncRulesCalled    atomic.Bool
+	listActiveQueriesCalled atomic.Bool
+	cancelActiveQueryCalled atomic.Bool
 }
 
 func (m *mockGrpcServiceHandler) NotifyClientShutdown(_ context.Context, _ *frontendv1pb.NotifyClientShutdownRequest) (*frontendv1pb.NotifyClientShutdownResponse, error) {
@@ -1031,3 +1047,17 @@ func (m *mockGrpcServiceHandler) Process(_ frontendv1pb.Frontend_ProcessServer)
 func (m *mockGrpcServiceHandler) QuerierLoop(_ schedulerpb.SchedulerForQuerier_QuerierLoopServer) error {
 	panic("implement me")
 }
+
+func (m *mockGrpcServiceHandler) FrontendLoop(_ schedulerpb.SchedulerForFrontend_FrontendLoopServer) error {
+	panic("implement me")
+}
+
+func (m *mockGrpcServiceHandler) ListActiveQueries(_ context.Context, _ *schedulerpb.ListActiveQueriesRequest) (*schedulerpb.ListActiveQueriesResponse, error) {
+	m.listActiveQueriesCalled.Store(true)
+	return &schedulerpb.ListActiveQueriesResponse{}, nil
+}
+
+func (m *mockGrpcServiceHandler) CancelActiveQuery(_ context.Context, _ *schedulerpb.CancelActiveQueryRequest) (*schedulerpb.CancelActiveQueryResponse, error) {
+	m.cancelActiveQueryCalled.Store(true)
+	return &schedulerpb.CancelActiveQueryResponse{}, nil
+}
@@ -709,6 +709,16 @@ func (t *Mimir) initQueryFrontendTripperware() (serv services.Service, err error
 
 	engineOpts, engineExperimentalFunctionsEnabled := engine.NewPromQLEngineOptions(t.Cfg.Querier.EngineConfig, t.ActivityTracker, util_log.Logger, promqlEngineRegisterer)
 
+	if t.Cfg.Frontend.QueryMiddleware.RewriteQueriesUsingRecordingRules {
+		if t.RulerCachedStorage == nil {
+			return nil, errors.New("the ruler storage must be configured to rewrite queries using recording rules")
+		}
+
+		t.Cfg.Frontend.QueryMiddleware.RecordingRulesStore = t.RulerCachedStorage
+		t.Cfg.Frontend.QueryMiddleware.RecordingRulesEvaluationInterval = t.Cfg.Ruler.EvaluationInterval
+		t.Cfg.Frontend.QueryMiddleware.RecordingRulesPollInterval = t.Cfg.Ruler.PollInterval
+	}
+
 	tripperware, err := querymiddleware.NewTripperware(
 		t.Cfg.Frontend.QueryMiddleware,
 		util_log.Logger,
@@ -754,6 +764,9 @@ func (t *Mimir) initQueryFrontend() (serv services.Service, err error) {
 		t.API.RegisterQueryFrontend2(frontendV2)
 		frontendSvc = frontendV2
 	}
+	if frontendV2 == nil {
+		t.API.RegisterActiveQueriesNotSupported()
+	}
 
 	// Wrap roundtripper into Tripperware and then wrap this with the roundtripper that checks
 	// that the frontend is ready to receive requests when running v1 or v2 of the query-frontend,
@@ -793,6 +806,11 @@ func (t *Mimir) initQueryFrontend() (serv services.Service, err error) {
 }
 
 func (t *Mimir) initRulerStorage() (serv services.Service, err error) {
+	// The ruler storage is only used by the query-frontend if queries are rewritten using recording rules.
+	if !t.Cfg.isAnyModuleEnabled(Ruler, Backend, All) && !t.Cfg.Frontend.QueryMiddleware.RewriteQueriesUsingRecordingRules {
+		return
+	}
+
 	// If the ruler is not configured and Mimir is running in monolithic or read-write mode, then we just skip starting the ruler.
 	if t.Cfg.isAnyModuleEnabled(Backend, All) && t.Cfg.RulerStorage.IsDefaults() {
 		level.Info(util_log.Logger).Log("msg", "The ruler is not being started because you need to configure the ruler storage.")
@@ -1114,7 +1132,7 @@ func (t *Mimir) setupModuleManager() error {
 		Queryable:                {Overrides, DistributorService, IngesterRing, IngesterPartitionRing, API, StoreQueryable, MemberlistKV},
 		Querier:                  {TenantFederation, Vault},
 		StoreQueryable:           {Overrides, MemberlistKV},
-		QueryFrontendTripperware: {API, Overrides, QueryFrontendCodec},
+		QueryFrontendTripperware: {API, Overrides, QueryFrontendCodec, RulerStorage},
 		QueryFrontend:            {QueryFrontendTripperware, MemberlistKV, Vault},
 		QueryScheduler:           {API, Overrides, MemberlistKV, Vault},
 		Ruler:                    {DistributorService, StoreQueryable, RulerStorage, Vault},
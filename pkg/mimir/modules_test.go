@@ -146,6 +146,27 @@ func TestMimir_InitRulerStorage(t *testing.T) {
 			}(),
 			expectedInit: true,
 		},
+		"should not init the ruler storage with target=query-frontend": {
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Target = []string{"query-frontend"}
+				cfg.RulerStorage.Backend = "local"
+				cfg.RulerStorage.Local.Directory = os.TempDir()
+				return cfg
+			}(),
+			expectedInit: false,
+		},
+		"should init the ruler storage with target=query-frontend when rewriting queries using recording rules": {
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Target = []string{"query-frontend"}
+				cfg.Frontend.QueryMiddleware.RewriteQueriesUsingRecordingRules = true
+				cfg.RulerStorage.Backend = "local"
+				cfg.RulerStorage.Local.Directory = os.TempDir()
+				return cfg
+			}(),
+			expectedInit: true,
+		},
 	}
 
 	for testName, testData := range tests {
@@ -0,0 +1,331 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package mimirpb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/histogram"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the Prometheus remote-write 2.0 protobuf messages (io.prometheus.write.v2).
+const (
+	rw2RequestSymbolsField    = 4
+	rw2RequestTimeseriesField = 5
+
+	rw2TimeSeriesLabelsRefsField       = 1
+	rw2TimeSeriesSamplesField          = 2
+	rw2TimeSeriesHistogramsField       = 3
+	rw2TimeSeriesExemplarsField        = 4
+	rw2TimeSeriesMetadataField         = 5
+	rw2TimeSeriesCreatedTimestampField = 6
+
+	rw2ExemplarLabelsRefsField = 1
+	rw2ExemplarValueField      = 2
+	rw2ExemplarTimestampField  = 3
+
+	rw2MetadataTypeField    = 1
+	rw2MetadataHelpRefField = 3
+	rw2MetadataUnitRefField = 4
+)
+
+var errInvalidRW2Request = errors.New("invalid remote-write 2.0 request")
+
+// PreallocWriteRequestRW2 wraps a PreallocWriteRequest to unmarshal it from a Prometheus remote-write 2.0
+// request (io.prometheus.write.v2.Request).
+type PreallocWriteRequestRW2 struct {
+	*PreallocWriteRequest
+
+	// IgnoredCreatedTimestamps is the number of series of the unmarshalled request with a created timestamp,
+	// which is not supported and is ignored.
+	IgnoredCreatedTimestamps int
+}
+
+// Unmarshal implements proto.Unmarshaler. The remote-write 2.0 request is decoded straight into the WriteRequest,
+// without an intermediate copy: labels, exemplar labels and metadata reference the symbols of the request, which
+// are yolo strings backed by the input data slice, so the input data slice is retained.
+//
+// Samples and native histograms share the same encoding of remote-write 1.0 and are unmarshalled as is, except
+// native histograms with custom buckets, which are not supported and are rejected.
+// The metadata of each series is converted to a metric metadata, keyed by the metric name of the series.
+// Created timestamps are not supported yet: they're ignored, and counted in IgnoredCreatedTimestamps.
+func (p *PreallocWriteRequestRW2) Unmarshal(dAtA []byte) error {
+	p.Timeseries = PreallocTimeseriesSliceFromPool()
+
+	// Symbols can be encoded after the series referencing them, so they're collected first.
+	var symbols []string
+	err := rangeRW2Fields(dAtA, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if num != rw2RequestSymbolsField {
+			return nil
+		}
+		if typ != protowire.BytesType {
+			return fmt.Errorf("%w: wrong wire type %d for field symbols", errInvalidRW2Request, typ)
+		}
+		symbols = append(symbols, yoloString(value))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(symbols) > 0 && symbols[0] != "" {
+		return fmt.Errorf("%w: the first symbol must be an empty string", errInvalidRW2Request)
+	}
+
+	var (
+		refs         []uint32
+		metadataSeen = map[string]struct{}{}
+	)
+	return rangeRW2Fields(dAtA, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if num != rw2RequestTimeseriesField {
+			return nil
+		}
+		if typ != protowire.BytesType {
+			return fmt.Errorf("%w: wrong wire type %d for field timeseries", errInvalidRW2Request, typ)
+		}
+
+		ts := PreallocTimeseries{TimeSeries: TimeseriesFromPool()}
+		metadata, createdTimestamp, err := unmarshalRW2TimeSeries(ts.TimeSeries, value, symbols, &refs)
+		p.Timeseries = append(p.Timeseries, ts)
+		if err != nil {
+			return err
+		}
+		if createdTimestamp != 0 {
+			p.IgnoredCreatedTimestamps++
+		}
+
+		if metadata != nil {
+			for _, l := range ts.Labels {
+				if l.Name == model.MetricNameLabel {
+					metadata.MetricFamilyName = l.Value
+					break
+				}
+			}
+			if _, ok := metadataSeen[metadata.MetricFamilyName]; !ok {
+				metadataSeen[metadata.MetricFamilyName] = struct{}{}
+				p.Metadata = append(p.Metadata, metadata)
+			}
+		}
+		return nil
+	})
+}
+
+// unmarshalRW2TimeSeries decodes a remote-write 2.0 series into ts, and returns its metadata, or nil if the
+// series has no metadata, and its created timestamp. The refs buffer is used to decode symbol references.
+func unmarshalRW2TimeSeries(ts *TimeSeries, dAtA []byte, symbols []string, refs *[]uint32) (*MetricMetadata, int64, error) {
+	var (
+		metadata         *MetricMetadata
+		createdTimestamp int64
+	)
+
+	*refs = (*refs)[:0]
+	err := rangeRW2Fields(dAtA, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		switch num {
+		case rw2TimeSeriesLabelsRefsField:
+			var err error
+			*refs, err = appendRW2Refs(*refs, typ, value)
+			return err
+
+		case rw2TimeSeriesSamplesField:
+			if typ != protowire.BytesType {
+				return fmt.Errorf("%w: wrong wire type %d for field samples", errInvalidRW2Request, typ)
+			}
+			ts.Samples = append(ts.Samples, Sample{})
+			return ts.Samples[len(ts.Samples)-1].Unmarshal(value)
+
+		case rw2TimeSeriesHistogramsField:
+			if typ != protowire.BytesType {
+				return fmt.Errorf("%w: wrong wire type %d for field histograms", errInvalidRW2Request, typ)
+			}
+			ts.Histograms = append(ts.Histograms, Histogram{})
+			h := &ts.Histograms[len(ts.Histograms)-1]
+			if err := h.Unmarshal(value); err != nil {
+				return err
+			}
+			// The custom values of the buckets can't be represented by Histogram.
+			if histogram.IsCustomBucketsSchema(h.Schema) {
+				return fmt.Errorf("%w: native histograms with custom buckets are not supported", errInvalidRW2Request)
+			}
+			return nil
+
+		case rw2TimeSeriesExemplarsField:
+			if typ != protowire.BytesType {
+				return fmt.Errorf("%w: wrong wire type %d for field exemplars", errInvalidRW2Request, typ)
+			}
+			ts.Exemplars = append(ts.Exemplars, Exemplar{})
+			return unmarshalRW2Exemplar(&ts.Exemplars[len(ts.Exemplars)-1], value, symbols)
+
+		case rw2TimeSeriesMetadataField:
+			if typ != protowire.BytesType {
+				return fmt.Errorf("%w: wrong wire type %d for field metadata", errInvalidRW2Request, typ)
+			}
+			var err error
+			metadata, err = unmarshalRW2Metadata(value, symbols)
+			return err
+
+		case rw2TimeSeriesCreatedTimestampField:
+			if typ != protowire.VarintType {
+				return fmt.Errorf("%w: wrong wire type %d for field created_timestamp", errInvalidRW2Request, typ)
+			}
+			v, _ := protowire.ConsumeVarint(value)
+			createdTimestamp = int64(v)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ts.Labels, err = appendRW2Labels(ts.Labels, *refs, symbols)
+	return metadata, createdTimestamp, err
+}
+
+func unmarshalRW2Exemplar(e *Exemplar, dAtA []byte, symbols []string) error {
+	var refs []uint32
+
+	err := rangeRW2Fields(dAtA, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		switch num {
+		case rw2ExemplarLabelsRefsField:
+			var err error
+			refs, err = appendRW2Refs(refs, typ, value)
+			return err
+
+		case rw2ExemplarValueField:
+			if typ != protowire.Fixed64Type {
+				return fmt.Errorf("%w: wrong wire type %d for field value", errInvalidRW2Request, typ)
+			}
+			v, _ := protowire.ConsumeFixed64(value)
+			e.Value = math.Float64frombits(v)
+
+		case rw2ExemplarTimestampField:
+			if typ != protowire.VarintType {
+				return fmt.Errorf("%w: wrong wire type %d for field timestamp", errInvalidRW2Request, typ)
+			}
+			v, _ := protowire.ConsumeVarint(value)
+			e.TimestampMs = int64(v)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	e.Labels, err = appendRW2Labels(e.Labels, refs, symbols)
+	return err
+}
+
+// unmarshalRW2Metadata decodes a remote-write 2.0 metadata, and returns nil if it's empty.
+func unmarshalRW2Metadata(dAtA []byte, symbols []string) (*MetricMetadata, error) {
+	metadata := &MetricMetadata{}
+
+	err := rangeRW2Fields(dAtA, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if num != rw2MetadataTypeField && num != rw2MetadataHelpRefField && num != rw2MetadataUnitRefField {
+			return nil
+		}
+		if typ != protowire.VarintType {
+			return fmt.Errorf("%w: wrong wire type %d for metadata field %d", errInvalidRW2Request, typ, num)
+		}
+		v, _ := protowire.ConsumeVarint(value)
+
+		switch num {
+		case rw2MetadataTypeField:
+			// The metric types of remote-write 2.0 have the same values of the metric metadata ones.
+			metadata.Type = MetricMetadata_MetricType(v)
+		case rw2MetadataHelpRefField:
+			if v >= uint64(len(symbols)) {
+				return fmt.Errorf("%w: help symbol reference %d out of range", errInvalidRW2Request, v)
+			}
+			metadata.Help = symbols[v]
+		case rw2MetadataUnitRefField:
+			if v >= uint64(len(symbols)) {
+				return fmt.Errorf("%w: unit symbol reference %d out of range", errInvalidRW2Request, v)
+			}
+			metadata.Unit = symbols[v]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if metadata.Type == UNKNOWN && metadata.Help == "" && metadata.Unit == "" {
+		return nil, nil
+	}
+	return metadata, nil
+}
+
+// appendRW2Labels appends to lbls the labels whose name and value symbols are referenced in pairs by refs.
+func appendRW2Labels(lbls []LabelAdapter, refs []uint32, symbols []string) ([]LabelAdapter, error) {
+	if len(refs)%2 != 0 {
+		return lbls, fmt.Errorf("%w: odd number of label symbol references", errInvalidRW2Request)
+	}
+
+	for i := 0; i < len(refs); i += 2 {
+		nameRef, valueRef := refs[i], refs[i+1]
+		if int(nameRef) >= len(symbols) || int(valueRef) >= len(symbols) {
+			return lbls, fmt.Errorf("%w: label symbol reference out of range", errInvalidRW2Request)
+		}
+		lbls = append(lbls, LabelAdapter{Name: symbols[nameRef], Value: symbols[valueRef]})
+	}
+
+	return lbls, nil
+}
+
+// appendRW2Refs appends to refs the symbol references encoded in value, which may be packed or not.
+func appendRW2Refs(refs []uint32, typ protowire.Type, value []byte) ([]uint32, error) {
+	switch typ {
+	case protowire.VarintType:
+		v, _ := protowire.ConsumeVarint(value)
+		return append(refs, uint32(v)), nil
+
+	case protowire.BytesType:
+		for len(value) > 0 {
+			v, n := protowire.ConsumeVarint(value)
+			if n < 0 {
+				return refs, fmt.Errorf("%w: %v", errInvalidRW2Request, protowire.ParseError(n))
+			}
+			refs = append(refs, uint32(v))
+			value = value[n:]
+		}
+		return refs, nil
+	}
+
+	return refs, fmt.Errorf("%w: wrong wire type %d for symbol references", errInvalidRW2Request, typ)
+}
+
+// rangeRW2Fields calls f for each field of the protobuf message encoded in dAtA. The value passed to f is the
+// content of length-delimited fields, and the raw encoding of the other fields.
+func rangeRW2Fields(dAtA []byte, f func(num protowire.Number, typ protowire.Type, value []byte) error) error {
+	for len(dAtA) > 0 {
+		num, typ, n := protowire.ConsumeTag(dAtA)
+		if n < 0 {
+			return fmt.Errorf("%w: %v", errInvalidRW2Request, protowire.ParseError(n))
+		}
+		dAtA = dAtA[n:]
+
+		var value []byte
+		if typ == protowire.BytesType {
+			value, n = protowire.ConsumeBytes(dAtA)
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, dAtA)
+			if n >= 0 {
+				value = dAtA[:n]
+			}
+		}
+		if n < 0 {
+			return fmt.Errorf("%w: %v", errInvalidRW2Request, protowire.ParseError(n))
+		}
+		dAtA = dAtA[n:]
+
+		if err := f(num, typ, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
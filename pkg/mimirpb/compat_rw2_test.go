@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package mimirpb
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	writev2 "github.com/prometheus/prometheus/prompb/io/prometheus/write/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/util/test"
+)
+
+func TestPreallocWriteRequestRW2_Unmarshal(t *testing.T) {
+	symbols := writev2.NewSymbolTable()
+	seriesLabels := labels.FromStrings(labels.MetricName, "http_requests_total", "job", "api", "instance", "host-1")
+	histogramLabels := labels.FromStrings(labels.MetricName, "http_request_duration_seconds", "job", "api")
+	exemplarLabels := labels.FromStrings("trace_id", "1234")
+
+	rw2 := &writev2.Request{
+		Timeseries: []writev2.TimeSeries{
+			{
+				LabelsRefs: symbols.SymbolizeLabels(seriesLabels, nil),
+				Samples:    []writev2.Sample{{Value: 1, Timestamp: 10}, {Value: 2, Timestamp: 20}},
+				Exemplars:  []writev2.Exemplar{{LabelsRefs: symbols.SymbolizeLabels(exemplarLabels, nil), Value: 1.5, Timestamp: 15}},
+				Metadata: writev2.Metadata{
+					Type:    writev2.Metadata_METRIC_TYPE_COUNTER,
+					HelpRef: symbols.Symbolize("Total number of HTTP requests."),
+				},
+				CreatedTimestamp: 5,
+			},
+			{
+				LabelsRefs: symbols.SymbolizeLabels(histogramLabels, nil),
+				Histograms: []writev2.Histogram{
+					writev2.FromIntHistogram(30, test.GenerateTestHistogram(1)),
+					writev2.FromFloatHistogram(40, test.GenerateTestFloatHistogram(2)),
+				},
+				Metadata: writev2.Metadata{
+					Type:    writev2.Metadata_METRIC_TYPE_HISTOGRAM,
+					UnitRef: symbols.Symbolize("seconds"),
+				},
+			},
+			{
+				// Series with the same metric name of the first one, whose metadata is deduplicated.
+				LabelsRefs: symbols.SymbolizeLabels(labels.FromStrings(labels.MetricName, "http_requests_total", "job", "api", "instance", "host-2"), nil),
+				Samples:    []writev2.Sample{{Value: 3, Timestamp: 10}},
+				Metadata: writev2.Metadata{
+					Type:    writev2.Metadata_METRIC_TYPE_COUNTER,
+					HelpRef: symbols.Symbolize("Total number of HTTP requests."),
+				},
+			},
+			{
+				// Series without metadata.
+				LabelsRefs: symbols.SymbolizeLabels(labels.FromStrings(labels.MetricName, "up"), nil),
+				Samples:    []writev2.Sample{{Value: 1, Timestamp: 10}},
+			},
+		},
+	}
+	rw2.Symbols = symbols.Symbols()
+
+	data, err := rw2.Marshal()
+	require.NoError(t, err)
+
+	req := &PreallocWriteRequest{}
+	rw2Req := &PreallocWriteRequestRW2{PreallocWriteRequest: req}
+	require.NoError(t, rw2Req.Unmarshal(data))
+	t.Cleanup(func() { ReuseSlice(req.Timeseries) })
+
+	// Created timestamps are ignored.
+	assert.Equal(t, 1, rw2Req.IgnoredCreatedTimestamps)
+
+	require.Len(t, req.Timeseries, 4)
+	assert.Equal(t, FromLabelsToLabelAdapters(seriesLabels), req.Timeseries[0].Labels)
+	assert.Equal(t, []Sample{{Value: 1, TimestampMs: 10}, {Value: 2, TimestampMs: 20}}, req.Timeseries[0].Samples)
+	assert.Equal(t, []Exemplar{{Labels: FromLabelsToLabelAdapters(exemplarLabels), Value: 1.5, TimestampMs: 15}}, req.Timeseries[0].Exemplars)
+	assert.Empty(t, req.Timeseries[0].Histograms)
+
+	assert.Equal(t, FromLabelsToLabelAdapters(histogramLabels), req.Timeseries[1].Labels)
+	assert.Empty(t, req.Timeseries[1].Samples)
+	assert.Equal(t, []Histogram{
+		FromHistogramToHistogramProto(30, test.GenerateTestHistogram(1)),
+		FromFloatHistogramToHistogramProto(40, test.GenerateTestFloatHistogram(2)),
+	}, req.Timeseries[1].Histograms)
+
+	assert.Equal(t, []Sample{{Value: 3, TimestampMs: 10}}, req.Timeseries[2].Samples)
+	assert.Equal(t, FromLabelsToLabelAdapters(labels.FromStrings(labels.MetricName, "up")), req.Timeseries[3].Labels)
+
+	assert.Equal(t, []*MetricMetadata{
+		{Type: COUNTER, MetricFamilyName: "http_requests_total", Help: "Total number of HTTP requests."},
+		{Type: HISTOGRAM, MetricFamilyName: "http_request_duration_seconds", Unit: "seconds"},
+	}, req.Metadata)
+
+	// The unmarshalled series must not be marshalled again from the remote-write 2.0 data.
+	for _, ts := range req.Timeseries {
+		assert.Nil(t, ts.marshalledData)
+	}
+}
+
+func TestPreallocWriteRequestRW2_Unmarshal_InvalidRequests(t *testing.T) {
+	tests := map[string]*writev2.Request{
+		"label symbol reference out of range": {
+			Symbols:    []string{"", labels.MetricName, "up"},
+			Timeseries: []writev2.TimeSeries{{LabelsRefs: []uint32{1, 3}}},
+		},
+		"odd number of label symbol references": {
+			Symbols:    []string{"", labels.MetricName, "up"},
+			Timeseries: []writev2.TimeSeries{{LabelsRefs: []uint32{1, 2, 1}}},
+		},
+		"exemplar label symbol reference out of range": {
+			Symbols: []string{"", labels.MetricName, "up"},
+			Timeseries: []writev2.TimeSeries{{
+				LabelsRefs: []uint32{1, 2},
+				Exemplars:  []writev2.Exemplar{{LabelsRefs: []uint32{1, 5}}},
+			}},
+		},
+		"first symbol not empty": {
+			Symbols:    []string{labels.MetricName, "up"},
+			Timeseries: []writev2.TimeSeries{{LabelsRefs: []uint32{0, 1}}},
+		},
+		"native histogram with custom buckets": {
+			Symbols: []string{"", labels.MetricName, "up"},
+			Timeseries: []writev2.TimeSeries{{
+				LabelsRefs: []uint32{1, 2},
+				Histograms: []writev2.Histogram{writev2.FromFloatHistogram(10, &histogram.FloatHistogram{
+					Schema:          histogram.CustomBucketsSchema,
+					Count:           3,
+					Sum:             5,
+					PositiveSpans:   []histogram.Span{{Offset: 0, Length: 2}},
+					PositiveBuckets: []float64{1, 2},
+					CustomValues:    []float64{1, 2},
+				})},
+			}},
+		},
+		"metadata help symbol reference out of range": {
+			Symbols: []string{"", labels.MetricName, "up"},
+			Timeseries: []writev2.TimeSeries{{
+				LabelsRefs: []uint32{1, 2},
+				Metadata:   writev2.Metadata{Type: writev2.Metadata_METRIC_TYPE_GAUGE, HelpRef: 10},
+			}},
+		},
+	}
+
+	for name, rw2 := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := rw2.Marshal()
+			require.NoError(t, err)
+
+			req := &PreallocWriteRequest{}
+			err = (&PreallocWriteRequestRW2{PreallocWriteRequest: req}).Unmarshal(data)
+			require.ErrorIs(t, err, errInvalidRW2Request)
+			ReuseSlice(req.Timeseries)
+		})
+	}
+
+	t.Run("truncated data", func(t *testing.T) {
+		data, err := (&writev2.Request{Symbols: []string{"", "foo"}}).Marshal()
+		require.NoError(t, err)
+
+		req := &PreallocWriteRequest{}
+		err = (&PreallocWriteRequestRW2{PreallocWriteRequest: req}).Unmarshal(data[:len(data)-1])
+		require.ErrorIs(t, err, errInvalidRW2Request)
+	})
+}
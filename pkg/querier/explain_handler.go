@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/grafana/dskit/grpcutil"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/storage"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/streamingpromql/compat"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+type explainQueryResult struct {
+	Status string                 `json:"status"`
+	Data   explainQueryResultData `json:"data"`
+}
+
+type explainQueryResultData struct {
+	compat.QueryExplanation
+
+	// PlanText is the plan rendered as a text tree, if the query would be evaluated by Mimir's query engine.
+	PlanText string `json:"planText,omitempty"`
+}
+
+// NewQueryExplainHandler creates a http.Handler that describes how engine would evaluate a query, without
+// evaluating it.
+//
+// It accepts the same parameters as the instant and range query endpoints: the query is explained as a range query
+// if the step parameter is set, or as an instant query otherwise.
+func NewQueryExplainHandler(engine promql.QueryEngine, queryable storage.Queryable) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		qs := r.FormValue("query")
+		if qs == "" {
+			writeExplainError(w, apierror.New(apierror.TypeBadData, "missing query parameter"))
+			return
+		}
+
+		var explanation compat.QueryExplanation
+		var err error
+
+		if r.FormValue("step") == "" {
+			var ts int64
+			ts, err = util.ParseTimeParam(r, "time", time.Now().UnixMilli())
+			if err != nil {
+				writeExplainError(w, explainParamError(err, "time"))
+				return
+			}
+
+			explanation, err = compat.ExplainInstantQuery(r.Context(), engine, queryable, nil, qs, util.TimeFromMillis(ts))
+		} else {
+			var start, end, step int64
+			start, end, step, apiErr := parseExplainRangeParams(r)
+			if apiErr != nil {
+				writeExplainError(w, apiErr)
+				return
+			}
+
+			explanation, err = compat.ExplainRangeQuery(r.Context(), engine, queryable, nil, qs, util.TimeFromMillis(start), util.TimeFromMillis(end), time.Duration(step)*time.Millisecond)
+		}
+
+		if err != nil {
+			// Consistent with the query endpoints, errors creating the query are returned as invalid parameter errors.
+			writeExplainError(w, apierror.Newf(apierror.TypeBadData, "invalid parameter \"query\": %s", err.Error()))
+			return
+		}
+
+		result := explainQueryResult{
+			Status: statusSuccess,
+			Data:   explainQueryResultData{QueryExplanation: explanation},
+		}
+
+		if explanation.Plan != nil {
+			result.Data.PlanText = explanation.Plan.String()
+		}
+
+		util.WriteJSONResponse(w, result)
+	})
+}
+
+// parseExplainRangeParams parses the start, end and step parameters of a range query, in milliseconds, consistent
+// with the range query endpoint.
+func parseExplainRangeParams(r *http.Request) (start, end, step int64, apiErr *apierror.APIError) {
+	start, err := util.ParseTime(r.FormValue("start"))
+	if err != nil {
+		return 0, 0, 0, explainParamError(err, "start")
+	}
+
+	end, err = util.ParseTime(r.FormValue("end"))
+	if err != nil {
+		return 0, 0, 0, explainParamError(err, "end")
+	}
+
+	if end < start {
+		return 0, 0, 0, apierror.New(apierror.TypeBadData, `invalid parameter "end": end timestamp must not be before start time`)
+	}
+
+	step, err = util.ParseDurationMs(r.FormValue("step"))
+	if err != nil {
+		return 0, 0, 0, explainParamError(err, "step")
+	}
+
+	if step <= 0 {
+		return 0, 0, 0, apierror.New(apierror.TypeBadData, `invalid parameter "step": zero or negative query resolution step widths are not accepted. Try a positive integer`)
+	}
+
+	// For safety, limit the number of returned points per timeseries.
+	if (end-start)/step > 11000 {
+		return 0, 0, 0, apierror.New(apierror.TypeBadData, "exceeded maximum resolution of 11,000 points per timeseries. Try decreasing the query resolution (?step=XX)")
+	}
+
+	return start, end, step, nil
+}
+
+// explainParamError returns the error parsing the given parameter as a bad data API error.
+func explainParamError(err error, paramName string) *apierror.APIError {
+	if status, ok := grpcutil.ErrorToStatus(err); ok {
+		return apierror.Newf(apierror.TypeBadData, "invalid parameter %q: %s", paramName, status.Message())
+	}
+	return apierror.Newf(apierror.TypeBadData, "invalid parameter %q: %s", paramName, err.Error())
+}
+
+func writeExplainError(w http.ResponseWriter, err *apierror.APIError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.StatusCode())
+
+	if body, encodeErr := err.EncodeJSON(); encodeErr == nil {
+		_, _ = w.Write(body)
+	}
+}
@@ -0,0 +1,231 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/streamingpromql"
+	"github.com/grafana/mimir/pkg/streamingpromql/compat"
+)
+
+func TestQueryExplainHandler(t *testing.T) {
+	opts := streamingpromql.NewTestEngineOpts()
+	mimirEngine, err := streamingpromql.NewEngine(opts, streamingpromql.NewStaticQueryLimitsProvider(0, 0), stats.NewQueryMetrics(nil), log.NewNopLogger())
+	require.NoError(t, err)
+	engine := compat.NewEngineWithFallback(mimirEngine, promql.NewEngine(opts), prometheus.NewPedanticRegistry(), log.NewNopLogger())
+
+	testCases := map[string]struct {
+		queryParams url.Values
+		headers     http.Header
+
+		expectedStatusCode int
+		expectedJSON       string
+	}{
+		"instant query": {
+			queryParams: url.Values{
+				"query": {`sum by (env) (rate(some_metric{env="prod"}[5m]))`},
+				"time":  {"2024-03-22T03:00:00Z"},
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedJSON: `
+				{
+					"status": "success",
+					"data": {
+						"engine": "mimir",
+						"plan": {
+							"operator": "Aggregation",
+							"details": "sum",
+							"grouping": ["env"],
+							"timeRange": {"start": "2024-03-22T03:00:00Z", "end": "2024-03-22T03:00:00Z"},
+							"children": [
+								{
+									"operator": "DeduplicateSeries",
+									"timeRange": {"start": "2024-03-22T03:00:00Z", "end": "2024-03-22T03:00:00Z"},
+									"children": [
+										{
+											"operator": "FunctionOverRangeVector",
+											"details": "rate",
+											"timeRange": {"start": "2024-03-22T03:00:00Z", "end": "2024-03-22T03:00:00Z"},
+											"children": [
+												{
+													"operator": "RangeVectorSelector",
+													"details": "[5m]",
+													"matchers": ["env=\"prod\"", "__name__=\"some_metric\""],
+													"timeRange": {"start": "2024-03-22T03:00:00Z", "end": "2024-03-22T03:00:00Z"}
+												}
+											]
+										}
+									]
+								}
+							]
+						},
+						"planText": "Aggregation: sum by (env) [at 2024-03-22T03:00:00Z]\n└─ DeduplicateSeries\n   └─ FunctionOverRangeVector: rate\n      └─ RangeVectorSelector: [5m] {env=\"prod\", __name__=\"some_metric\"}\n"
+					}
+				}
+			`,
+		},
+		"range query": {
+			queryParams: url.Values{
+				"query": {`some_metric`},
+				"start": {"1711076400"},
+				"end":   {"1711077000"},
+				"step":  {"1m"},
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedJSON: `
+				{
+					"status": "success",
+					"data": {
+						"engine": "mimir",
+						"plan": {
+							"operator": "InstantVectorSelector",
+							"matchers": ["__name__=\"some_metric\""],
+							"timeRange": {"start": "2024-03-22T03:00:00Z", "end": "2024-03-22T03:10:00Z", "step": "1m"}
+						},
+						"planText": "InstantVectorSelector {__name__=\"some_metric\"} [from 2024-03-22T03:00:00Z to 2024-03-22T03:10:00Z with step 1m]\n"
+					}
+				}
+			`,
+		},
+		"query not supported by Mimir's query engine": {
+			queryParams: url.Values{
+				"query": {`holt_winters(some_metric[5m], 0.5, 0.5)`},
+				"time":  {"1711076400"},
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedJSON: `
+				{
+					"status": "success",
+					"data": {
+						"engine": "prometheus",
+						"fallbackReason": "'holt_winters' function"
+					}
+				}
+			`,
+		},
+		"fallback forced by HTTP header": {
+			queryParams: url.Values{
+				"query": {`some_metric`},
+				"time":  {"1711076400"},
+			},
+			headers: http.Header{
+				"X-Mimir-Force-Prometheus-Engine": []string{"true"},
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedJSON: `
+				{
+					"status": "success",
+					"data": {
+						"engine": "prometheus",
+						"fallbackReason": "fallback forced by HTTP header"
+					}
+				}
+			`,
+		},
+		"missing query": {
+			queryParams:        url.Values{},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedJSON:       `{"status": "error", "errorType": "bad_data", "error": "missing query parameter"}`,
+		},
+		"invalid query": {
+			queryParams: url.Values{
+				"query": {`sum(`},
+				"time":  {"1711076400"},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedJSON:       `{"status": "error", "errorType": "bad_data", "error": "invalid parameter \"query\": 1:5: parse error: unclosed left parenthesis"}`,
+		},
+		"invalid time": {
+			queryParams: url.Values{
+				"query": {`some_metric`},
+				"time":  {"foo"},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedJSON:       `{"status": "error", "errorType": "bad_data", "error": "invalid parameter \"time\": cannot parse \"foo\" to a valid timestamp"}`,
+		},
+		"range query with missing start": {
+			queryParams: url.Values{
+				"query": {`some_metric`},
+				"end":   {"1711077000"},
+				"step":  {"60"},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedJSON:       `{"status": "error", "errorType": "bad_data", "error": "invalid parameter \"start\": cannot parse \"\" to a valid timestamp"}`,
+		},
+		"range query with end before start": {
+			queryParams: url.Values{
+				"query": {`some_metric`},
+				"start": {"1711077000"},
+				"end":   {"1711076400"},
+				"step":  {"60"},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedJSON:       `{"status": "error", "errorType": "bad_data", "error": "invalid parameter \"end\": end timestamp must not be before start time"}`,
+		},
+		"range query with invalid step": {
+			queryParams: url.Values{
+				"query": {`some_metric`},
+				"start": {"1711076400"},
+				"end":   {"1711077000"},
+				"step":  {"-1"},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedJSON:       `{"status": "error", "errorType": "bad_data", "error": "invalid parameter \"step\": zero or negative query resolution step widths are not accepted. Try a positive integer"}`,
+		},
+		"range query with unparseable step": {
+			queryParams: url.Values{
+				"query": {`some_metric`},
+				"start": {"1711076400"},
+				"end":   {"1711077000"},
+				"step":  {"foo"},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedJSON:       `{"status": "error", "errorType": "bad_data", "error": "invalid parameter \"step\": cannot parse \"foo\" to a valid duration"}`,
+		},
+		"range query with too many points": {
+			queryParams: url.Values{
+				"query": {`some_metric`},
+				"start": {"2024-03-22T00:00:00Z"},
+				"end":   {"2024-03-23T00:00:00Z"},
+				"step":  {"1s"},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedJSON:       `{"status": "error", "errorType": "bad_data", "error": "exceeded maximum resolution of 11,000 points per timeseries. Try decreasing the query resolution (?step=XX)"}`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			handler := compat.EngineFallbackInjector{}.Wrap(NewQueryExplainHandler(engine, nil))
+
+			request, err := http.NewRequest("GET", "/api/v1/explain_query", nil)
+			require.NoError(t, err)
+			request.URL.RawQuery = tc.queryParams.Encode()
+
+			for name, values := range tc.headers {
+				request.Header[name] = values
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			require.Equal(t, tc.expectedStatusCode, recorder.Result().StatusCode)
+			require.Equal(t, "application/json", recorder.Result().Header.Get("Content-Type"))
+			responseBody, err := io.ReadAll(recorder.Result().Body)
+			require.NoError(t, err)
+
+			require.JSONEq(t, tc.expectedJSON, string(responseBody))
+		})
+	}
+}
@@ -635,3 +635,12 @@ func (p *tenantQueryLimitsProvider) GetMaxEstimatedMemoryConsumptionPerQuery(ctx
 
 	return p.limits.MaxEstimatedMemoryConsumptionPerQuery(tenantID), nil
 }
+
+func (p *tenantQueryLimitsProvider) GetMaxConcurrentSubtreePrefetchesPerQuery(ctx context.Context) (int, error) {
+	tenantID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return p.limits.MaxConcurrentSubtreePrefetchesPerQuery(tenantID), nil
+}
@@ -7,6 +7,7 @@ package stats
 
 import (
 	"context"
+	"sync"
 	"sync/atomic" //lint:ignore faillint we can't use go.uber.org/atomic with a protobuf struct without wrapping it.
 	"time"
 
@@ -17,6 +18,79 @@ type contextKey int
 
 var ctxKey = contextKey(0)
 
+// Stats holds the statistics of a query. It's declared here, rather than generated from stats.proto, to guard the
+// operator statistics, which can't be updated atomically, with a mutex. For this reason, Stats must not be copied:
+// use Copy instead.
+type Stats struct {
+	// The sum of all wall time spent in the querier to execute the query.
+	WallTime time.Duration `protobuf:"bytes,1,opt,name=wall_time,json=wallTime,proto3,stdduration" json:"wall_time"`
+	// The number of series fetched for the query
+	FetchedSeriesCount uint64 `protobuf:"varint,2,opt,name=fetched_series_count,json=fetchedSeriesCount,proto3" json:"fetched_series_count,omitempty"`
+	// The number of bytes of the chunks fetched for the query, after any deduplication
+	FetchedChunkBytes uint64 `protobuf:"varint,3,opt,name=fetched_chunk_bytes,json=fetchedChunkBytes,proto3" json:"fetched_chunk_bytes,omitempty"`
+	// The number of chunks fetched for the query, after any deduplication
+	FetchedChunksCount uint64 `protobuf:"varint,4,opt,name=fetched_chunks_count,json=fetchedChunksCount,proto3" json:"fetched_chunks_count,omitempty"`
+	// The number of sharded queries executed. 0 if sharding is disabled or the query can't be sharded.
+	ShardedQueries uint32 `protobuf:"varint,5,opt,name=sharded_queries,json=shardedQueries,proto3" json:"sharded_queries,omitempty"`
+	// The number of split partial queries executed. 0 if splitting is disabled or the query can't be split.
+	SplitQueries uint32 `protobuf:"varint,6,opt,name=split_queries,json=splitQueries,proto3" json:"split_queries,omitempty"`
+	// The number of index bytes fetched on the store-gateway for the query
+	FetchedIndexBytes uint64 `protobuf:"varint,7,opt,name=fetched_index_bytes,json=fetchedIndexBytes,proto3" json:"fetched_index_bytes,omitempty"`
+	// The estimated number of series to be fetched for the query
+	EstimatedSeriesCount uint64 `protobuf:"varint,8,opt,name=estimated_series_count,json=estimatedSeriesCount,proto3" json:"estimated_series_count,omitempty"`
+	// The sum of durations that the query spent in the queue, before it was handled by querier.
+	QueueTime time.Duration `protobuf:"bytes,9,opt,name=queue_time,json=queueTime,proto3,stdduration" json:"queue_time"`
+	// Statistics for each operator used to evaluate the query, if it was evaluated by Mimir's query engine.
+	OperatorStats []OperatorStats `protobuf:"bytes,10,rep,name=operator_stats,json=operatorStats,proto3" json:"operator_stats"`
+	// The total number of samples processed by the PromQL engine to evaluate the query.
+	SamplesProcessed uint64 `protobuf:"varint,11,opt,name=samples_processed,json=samplesProcessed,proto3" json:"samples_processed,omitempty"`
+	// The peak number of samples held in memory by the PromQL engine while evaluating the query.
+	PeakSamples uint64 `protobuf:"varint,12,opt,name=peak_samples,json=peakSamples,proto3" json:"peak_samples,omitempty"`
+
+	// operatorStatsMtx guards OperatorStats.
+	operatorStatsMtx sync.Mutex
+}
+
+// Equal reports whether that is a *Stats with the same statistics. Unlike the generated Equal methods, it doesn't
+// accept a Stats value, which can't be copied.
+func (s *Stats) Equal(that interface{}) bool {
+	if that == nil {
+		return s == nil
+	}
+
+	other, ok := that.(*Stats)
+	if !ok {
+		return false
+	}
+	if other == nil || s == nil {
+		return other == s
+	}
+
+	if s.WallTime != other.WallTime ||
+		s.FetchedSeriesCount != other.FetchedSeriesCount ||
+		s.FetchedChunkBytes != other.FetchedChunkBytes ||
+		s.FetchedChunksCount != other.FetchedChunksCount ||
+		s.ShardedQueries != other.ShardedQueries ||
+		s.SplitQueries != other.SplitQueries ||
+		s.FetchedIndexBytes != other.FetchedIndexBytes ||
+		s.EstimatedSeriesCount != other.EstimatedSeriesCount ||
+		s.QueueTime != other.QueueTime ||
+		s.SamplesProcessed != other.SamplesProcessed ||
+		s.PeakSamples != other.PeakSamples {
+		return false
+	}
+
+	if len(s.OperatorStats) != len(other.OperatorStats) {
+		return false
+	}
+	for i := range s.OperatorStats {
+		if !s.OperatorStats[i].Equal(&other.OperatorStats[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 // ContextWithEmptyStats returns a context with empty stats.
 func ContextWithEmptyStats(ctx context.Context) (*Stats, context.Context) {
 	stats := &Stats{}
@@ -187,6 +261,105 @@ func (s *Stats) LoadQueueTime() time.Duration {
 	return time.Duration(atomic.LoadInt64((*int64)(&s.QueueTime)))
 }
 
+// AddSamplesProcessed adds to the number of samples processed by the PromQL engine.
+func (s *Stats) AddSamplesProcessed(samples uint64) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddUint64(&s.SamplesProcessed, samples)
+}
+
+// LoadSamplesProcessed returns the number of samples processed by the PromQL engine.
+func (s *Stats) LoadSamplesProcessed() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&s.SamplesProcessed)
+}
+
+// UpdatePeakSamples sets the peak number of samples held in memory to samples, if it is greater than the current peak.
+//
+// The peak is not summed across queries: queries evaluated separately (eg. sharded or split queries) don't
+// necessarily hold samples in memory at the same time.
+func (s *Stats) UpdatePeakSamples(samples uint64) {
+	if s == nil {
+		return
+	}
+
+	for {
+		current := atomic.LoadUint64(&s.PeakSamples)
+		if samples <= current || atomic.CompareAndSwapUint64(&s.PeakSamples, current, samples) {
+			return
+		}
+	}
+}
+
+// LoadPeakSamples returns the peak number of samples held in memory by the PromQL engine.
+func (s *Stats) LoadPeakSamples() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&s.PeakSamples)
+}
+
+// AddOperatorStats adds statistics for operators used to evaluate a query.
+//
+// Statistics for an operator with the same position and description as an operator already present are combined
+// with the existing statistics, so that statistics from queries with the same plan (eg. sharded or split queries)
+// are aggregated.
+func (s *Stats) AddOperatorStats(operators ...OperatorStats) {
+	if s == nil || len(operators) == 0 {
+		return
+	}
+
+	s.operatorStatsMtx.Lock()
+	defer s.operatorStatsMtx.Unlock()
+
+	type operatorKey struct{ position, operator string }
+	existing := make(map[operatorKey]int, len(s.OperatorStats))
+	for i, o := range s.OperatorStats {
+		existing[operatorKey{o.Position, o.Operator}] = i
+	}
+
+	for _, o := range operators {
+		key := operatorKey{o.Position, o.Operator}
+		i, ok := existing[key]
+		if !ok {
+			existing[key] = len(s.OperatorStats)
+			s.OperatorStats = append(s.OperatorStats, o)
+			continue
+		}
+
+		e := &s.OperatorStats[i]
+		e.WallTime += o.WallTime
+		e.InputSeries += o.InputSeries
+		e.OutputSeries += o.OutputSeries
+		e.SamplesProcessed += o.SamplesProcessed
+		e.PeakEstimatedMemoryConsumptionBytes = max(e.PeakEstimatedMemoryConsumptionBytes, o.PeakEstimatedMemoryConsumptionBytes)
+	}
+}
+
+// LoadOperatorStats returns a copy of the statistics for each operator used to evaluate a query.
+func (s *Stats) LoadOperatorStats() []OperatorStats {
+	if s == nil {
+		return nil
+	}
+
+	s.operatorStatsMtx.Lock()
+	defer s.operatorStatsMtx.Unlock()
+
+	if len(s.OperatorStats) == 0 {
+		return nil
+	}
+
+	c := make([]OperatorStats, len(s.OperatorStats))
+	copy(c, s.OperatorStats)
+	return c
+}
+
 // Merge the provided Stats into this one.
 func (s *Stats) Merge(other *Stats) {
 	if s == nil || other == nil {
@@ -202,6 +375,9 @@ func (s *Stats) Merge(other *Stats) {
 	s.AddFetchedIndexBytes(other.LoadFetchedIndexBytes())
 	s.AddEstimatedSeriesCount(other.LoadEstimatedSeriesCount())
 	s.AddQueueTime(other.LoadQueueTime())
+	s.AddSamplesProcessed(other.LoadSamplesProcessed())
+	s.UpdatePeakSamples(other.LoadPeakSamples())
+	s.AddOperatorStats(other.LoadOperatorStats()...)
 }
 
 // Copy returns a copy of the stats. Use this rather than regular struct assignment
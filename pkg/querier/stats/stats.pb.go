@@ -29,27 +29,6 @@ var _ = time.Kitchen
 // proto package needs to be updated.
 const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
 
-type Stats struct {
-	// The sum of all wall time spent in the querier to execute the query.
-	WallTime time.Duration `protobuf:"bytes,1,opt,name=wall_time,json=wallTime,proto3,stdduration" json:"wall_time"`
-	// The number of series fetched for the query
-	FetchedSeriesCount uint64 `protobuf:"varint,2,opt,name=fetched_series_count,json=fetchedSeriesCount,proto3" json:"fetched_series_count,omitempty"`
-	// The number of bytes of the chunks fetched for the query, after any deduplication
-	FetchedChunkBytes uint64 `protobuf:"varint,3,opt,name=fetched_chunk_bytes,json=fetchedChunkBytes,proto3" json:"fetched_chunk_bytes,omitempty"`
-	// The number of chunks fetched for the query, after any deduplication
-	FetchedChunksCount uint64 `protobuf:"varint,4,opt,name=fetched_chunks_count,json=fetchedChunksCount,proto3" json:"fetched_chunks_count,omitempty"`
-	// The number of sharded queries executed. 0 if sharding is disabled or the query can't be sharded.
-	ShardedQueries uint32 `protobuf:"varint,5,opt,name=sharded_queries,json=shardedQueries,proto3" json:"sharded_queries,omitempty"`
-	// The number of split partial queries executed. 0 if splitting is disabled or the query can't be split.
-	SplitQueries uint32 `protobuf:"varint,6,opt,name=split_queries,json=splitQueries,proto3" json:"split_queries,omitempty"`
-	// The number of index bytes fetched on the store-gateway for the query
-	FetchedIndexBytes uint64 `protobuf:"varint,7,opt,name=fetched_index_bytes,json=fetchedIndexBytes,proto3" json:"fetched_index_bytes,omitempty"`
-	// The estimated number of series to be fetched for the query
-	EstimatedSeriesCount uint64 `protobuf:"varint,8,opt,name=estimated_series_count,json=estimatedSeriesCount,proto3" json:"estimated_series_count,omitempty"`
-	// The sum of durations that the query spent in the queue, before it was handled by querier.
-	QueueTime time.Duration `protobuf:"bytes,9,opt,name=queue_time,json=queueTime,proto3,stdduration" json:"queue_time"`
-}
-
 func (m *Stats) Reset()      { *m = Stats{} }
 func (*Stats) ProtoMessage() {}
 func (*Stats) Descriptor() ([]byte, []int) {
@@ -145,48 +124,180 @@ func (m *Stats) GetQueueTime() time.Duration {
 	return 0
 }
 
+func (m *Stats) GetOperatorStats() []OperatorStats {
+	if m != nil {
+		return m.OperatorStats
+	}
+	return nil
+}
+
+func (m *Stats) GetSamplesProcessed() uint64 {
+	if m != nil {
+		return m.SamplesProcessed
+	}
+	return 0
+}
+
+func (m *Stats) GetPeakSamples() uint64 {
+	if m != nil {
+		return m.PeakSamples
+	}
+	return 0
+}
+
+type OperatorStats struct {
+	// The position of the operator in the query plan: the root operator is at position 0, the operators it consumes are at positions 0.0, 0.1 and so on.
+	Position string `protobuf:"bytes,1,opt,name=position,proto3" json:"position,omitempty"`
+	// A description of the operator, eg. "Aggregation: sum by (env)".
+	Operator string `protobuf:"bytes,2,opt,name=operator,proto3" json:"operator,omitempty"`
+	// The wall time spent in the operator, excluding time spent in the operators it consumes.
+	WallTime time.Duration `protobuf:"bytes,3,opt,name=wall_time,json=wallTime,proto3,stdduration" json:"wall_time"`
+	// The number of series the operator consumed from the operators it consumes.
+	InputSeries uint64 `protobuf:"varint,4,opt,name=input_series,json=inputSeries,proto3" json:"input_series,omitempty"`
+	// The number of series the operator produced.
+	OutputSeries uint64 `protobuf:"varint,5,opt,name=output_series,json=outputSeries,proto3" json:"output_series,omitempty"`
+	// The number of samples the operator consumed from the operators it consumes, or, for operators that don't consume other operators, the number of samples it produced.
+	SamplesProcessed uint64 `protobuf:"varint,6,opt,name=samples_processed,json=samplesProcessed,proto3" json:"samples_processed,omitempty"`
+	// The peak estimated memory consumption while evaluating the operator, including the operators it consumes.
+	PeakEstimatedMemoryConsumptionBytes uint64 `protobuf:"varint,7,opt,name=peak_estimated_memory_consumption_bytes,json=peakEstimatedMemoryConsumptionBytes,proto3" json:"peak_estimated_memory_consumption_bytes,omitempty"`
+}
+
+func (m *OperatorStats) Reset()      { *m = OperatorStats{} }
+func (*OperatorStats) ProtoMessage() {}
+func (*OperatorStats) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b4756a0aec8b9d44, []int{1}
+}
+func (m *OperatorStats) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *OperatorStats) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_OperatorStats.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *OperatorStats) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OperatorStats.Merge(m, src)
+}
+func (m *OperatorStats) XXX_Size() int {
+	return m.Size()
+}
+func (m *OperatorStats) XXX_DiscardUnknown() {
+	xxx_messageInfo_OperatorStats.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OperatorStats proto.InternalMessageInfo
+
+func (m *OperatorStats) GetPosition() string {
+	if m != nil {
+		return m.Position
+	}
+	return ""
+}
+
+func (m *OperatorStats) GetOperator() string {
+	if m != nil {
+		return m.Operator
+	}
+	return ""
+}
+
+func (m *OperatorStats) GetWallTime() time.Duration {
+	if m != nil {
+		return m.WallTime
+	}
+	return 0
+}
+
+func (m *OperatorStats) GetInputSeries() uint64 {
+	if m != nil {
+		return m.InputSeries
+	}
+	return 0
+}
+
+func (m *OperatorStats) GetOutputSeries() uint64 {
+	if m != nil {
+		return m.OutputSeries
+	}
+	return 0
+}
+
+func (m *OperatorStats) GetSamplesProcessed() uint64 {
+	if m != nil {
+		return m.SamplesProcessed
+	}
+	return 0
+}
+
+func (m *OperatorStats) GetPeakEstimatedMemoryConsumptionBytes() uint64 {
+	if m != nil {
+		return m.PeakEstimatedMemoryConsumptionBytes
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Stats)(nil), "stats.Stats")
+	proto.RegisterType((*OperatorStats)(nil), "stats.OperatorStats")
 }
 
 func init() { proto.RegisterFile("stats.proto", fileDescriptor_b4756a0aec8b9d44) }
 
 var fileDescriptor_b4756a0aec8b9d44 = []byte{
-	// 381 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x92, 0xbf, 0x4e, 0xfa, 0x50,
-	0x14, 0xc7, 0x7b, 0x7f, 0xfc, 0xf9, 0xc1, 0x45, 0x34, 0x56, 0x62, 0x2a, 0xc3, 0x81, 0xe8, 0x20,
-	0x53, 0x31, 0xea, 0xe6, 0x62, 0x8a, 0x8b, 0xa3, 0xe0, 0xe4, 0xd2, 0x14, 0x7a, 0x29, 0x8d, 0xa5,
-	0x17, 0x7a, 0x6f, 0xa3, 0x6e, 0x3e, 0x82, 0xa3, 0x8f, 0xe0, 0xa3, 0x30, 0xb2, 0x98, 0x30, 0xa9,
-	0x94, 0xc5, 0x91, 0x47, 0x30, 0xf7, 0xb6, 0x25, 0xe0, 0xe4, 0xd6, 0x7b, 0x3e, 0xe7, 0x93, 0xef,
-	0xc9, 0x39, 0xc5, 0x25, 0xc6, 0x2d, 0xce, 0xf4, 0x51, 0x40, 0x39, 0x55, 0x73, 0xf2, 0x51, 0xad,
-	0x38, 0xd4, 0xa1, 0xb2, 0xd2, 0x14, 0x5f, 0x31, 0xac, 0x82, 0x43, 0xa9, 0xe3, 0x91, 0xa6, 0x7c,
-	0x75, 0xc3, 0x7e, 0xd3, 0x0e, 0x03, 0x8b, 0xbb, 0xd4, 0x8f, 0xf9, 0xe1, 0x7b, 0x06, 0xe7, 0x3a,
-	0xc2, 0x57, 0x2f, 0x71, 0xf1, 0xc1, 0xf2, 0x3c, 0x93, 0xbb, 0x43, 0xa2, 0xa1, 0x3a, 0x6a, 0x94,
-	0x4e, 0x0f, 0xf4, 0xd8, 0xd6, 0x53, 0x5b, 0xbf, 0x4a, 0x6c, 0xa3, 0x30, 0xf9, 0xa8, 0x29, 0xaf,
-	0x9f, 0x35, 0xd4, 0x2e, 0x08, 0xeb, 0xd6, 0x1d, 0x12, 0xf5, 0x04, 0x57, 0xfa, 0x84, 0xf7, 0x06,
-	0xc4, 0x36, 0x19, 0x09, 0x5c, 0xc2, 0xcc, 0x1e, 0x0d, 0x7d, 0xae, 0xfd, 0xab, 0xa3, 0x46, 0xb6,
-	0xad, 0x26, 0xac, 0x23, 0x51, 0x4b, 0x10, 0x55, 0xc7, 0x7b, 0xa9, 0xd1, 0x1b, 0x84, 0xfe, 0xbd,
-	0xd9, 0x7d, 0xe2, 0x84, 0x69, 0x19, 0x29, 0xec, 0x26, 0xa8, 0x25, 0x88, 0x21, 0xc0, 0x7a, 0x82,
-	0xec, 0x4f, 0x13, 0xb2, 0x1b, 0x09, 0x52, 0x48, 0x12, 0x8e, 0xf1, 0x0e, 0x1b, 0x58, 0x81, 0x4d,
-	0x6c, 0x73, 0x1c, 0xca, 0x64, 0x2d, 0x57, 0x47, 0x8d, 0x72, 0x7b, 0x3b, 0x29, 0xdf, 0xc4, 0x55,
-	0xf5, 0x08, 0x97, 0xd9, 0xc8, 0x73, 0xf9, 0xaa, 0x2d, 0x2f, 0xdb, 0xb6, 0x64, 0x31, 0x6d, 0x5a,
-	0x9b, 0xd7, 0xf5, 0x6d, 0xf2, 0x98, 0xcc, 0xfb, 0x7f, 0x63, 0xde, 0x6b, 0x41, 0xe2, 0x79, 0xcf,
-	0xf1, 0x3e, 0x61, 0xdc, 0x1d, 0x5a, 0xfc, 0xf7, 0x4e, 0x0a, 0x52, 0xa9, 0xac, 0xe8, 0xfa, 0x56,
-	0x0c, 0x8c, 0xc7, 0x21, 0x09, 0x49, 0x7c, 0x8a, 0xe2, 0xdf, 0x4f, 0x51, 0x94, 0x9a, 0xb8, 0x85,
-	0x71, 0x31, 0x9d, 0x83, 0x32, 0x9b, 0x83, 0xb2, 0x9c, 0x03, 0x7a, 0x8e, 0x00, 0xbd, 0x45, 0x80,
-	0x26, 0x11, 0xa0, 0x69, 0x04, 0xe8, 0x2b, 0x02, 0xf4, 0x1d, 0x81, 0xb2, 0x8c, 0x00, 0xbd, 0x2c,
-	0x40, 0x99, 0x2e, 0x40, 0x99, 0x2d, 0x40, 0xb9, 0x8b, 0x7f, 0xa5, 0x6e, 0x5e, 0x86, 0x9c, 0xfd,
-	0x04, 0x00, 0x00, 0xff, 0xff, 0x86, 0xcb, 0x53, 0xdd, 0x67, 0x02, 0x00, 0x00,
-}
-
-func (this *Stats) Equal(that interface{}) bool {
+	// 573 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x54, 0x3f, 0x6f, 0xd3, 0x40,
+	0x14, 0xf7, 0x35, 0x7f, 0x48, 0xce, 0x49, 0xa1, 0x26, 0x42, 0x26, 0xc3, 0x25, 0x6d, 0x87, 0x46,
+	0x42, 0x72, 0x50, 0x61, 0x82, 0x05, 0x12, 0x18, 0x18, 0x10, 0xe0, 0x74, 0x62, 0xb1, 0x9c, 0xf8,
+	0x9a, 0x58, 0x8d, 0x7d, 0xae, 0xef, 0x2c, 0xe8, 0xc6, 0x47, 0x60, 0x64, 0x84, 0x8d, 0x8f, 0xd2,
+	0x31, 0x03, 0x43, 0x27, 0x20, 0xce, 0xd2, 0x31, 0xe2, 0x13, 0xa0, 0x7b, 0x3e, 0xa7, 0x09, 0x02,
+	0x09, 0x75, 0xf3, 0xfb, 0xfd, 0xf1, 0x7b, 0x7e, 0xef, 0x27, 0x63, 0x9d, 0x0b, 0x57, 0x70, 0x2b,
+	0x8a, 0x99, 0x60, 0x46, 0x09, 0x8a, 0x66, 0x63, 0xcc, 0xc6, 0x0c, 0x90, 0xae, 0x7c, 0xca, 0xc8,
+	0x26, 0x19, 0x33, 0x36, 0x9e, 0xd2, 0x2e, 0x54, 0xc3, 0xe4, 0xb8, 0xeb, 0x25, 0xb1, 0x2b, 0x7c,
+	0x16, 0x66, 0xfc, 0xde, 0xaf, 0x22, 0x2e, 0x0d, 0xa4, 0xdf, 0x78, 0x82, 0xab, 0xef, 0xdc, 0xe9,
+	0xd4, 0x11, 0x7e, 0x40, 0x4d, 0xd4, 0x46, 0x1d, 0xfd, 0xf0, 0xae, 0x95, 0xb9, 0xad, 0xdc, 0x6d,
+	0x3d, 0x53, 0xee, 0x5e, 0xe5, 0xfc, 0x7b, 0x4b, 0xfb, 0xf4, 0xa3, 0x85, 0xec, 0x8a, 0x74, 0x1d,
+	0xf9, 0x01, 0x35, 0xee, 0xe3, 0xc6, 0x31, 0x15, 0xa3, 0x09, 0xf5, 0x1c, 0x4e, 0x63, 0x9f, 0x72,
+	0x67, 0xc4, 0x92, 0x50, 0x98, 0x5b, 0x6d, 0xd4, 0x29, 0xda, 0x86, 0xe2, 0x06, 0x40, 0xf5, 0x25,
+	0x63, 0x58, 0xf8, 0x76, 0xee, 0x18, 0x4d, 0x92, 0xf0, 0xc4, 0x19, 0x9e, 0x09, 0xca, 0xcd, 0x02,
+	0x18, 0x76, 0x14, 0xd5, 0x97, 0x4c, 0x4f, 0x12, 0xeb, 0x1d, 0x40, 0x9f, 0x77, 0x28, 0x6e, 0x74,
+	0x00, 0x83, 0xea, 0x70, 0x80, 0x6f, 0xf2, 0x89, 0x1b, 0x7b, 0xd4, 0x73, 0x4e, 0x13, 0xe8, 0x6c,
+	0x96, 0xda, 0xa8, 0x53, 0xb7, 0xb7, 0x15, 0xfc, 0x26, 0x43, 0x8d, 0x7d, 0x5c, 0xe7, 0xd1, 0xd4,
+	0x17, 0x2b, 0x59, 0x19, 0x64, 0x35, 0x00, 0x73, 0xd1, 0xda, 0xbc, 0x7e, 0xe8, 0xd1, 0xf7, 0x6a,
+	0xde, 0x1b, 0x1b, 0xf3, 0xbe, 0x90, 0x4c, 0x36, 0xef, 0x43, 0x7c, 0x87, 0x72, 0xe1, 0x07, 0xae,
+	0xf8, 0x73, 0x27, 0x15, 0xb0, 0x34, 0x56, 0xec, 0xfa, 0x56, 0x7a, 0x18, 0x9f, 0x26, 0x34, 0xa1,
+	0xd9, 0x29, 0xaa, 0xff, 0x7f, 0x8a, 0x2a, 0xd8, 0xe0, 0x16, 0x4f, 0xf1, 0x36, 0x8b, 0x68, 0xec,
+	0x0a, 0x16, 0x3b, 0x90, 0x0f, 0x13, 0xb7, 0x0b, 0x1d, 0xfd, 0xb0, 0x61, 0x41, 0x65, 0xbd, 0x52,
+	0x24, 0xdc, 0xbe, 0x57, 0x94, 0xaf, 0xb0, 0xeb, 0x6c, 0x1d, 0x34, 0xee, 0xe1, 0x1d, 0xee, 0x06,
+	0xd1, 0x94, 0x72, 0x27, 0x8a, 0xd9, 0x88, 0x72, 0x4e, 0x3d, 0x53, 0x87, 0xb9, 0x6f, 0x29, 0xe2,
+	0x75, 0x8e, 0x1b, 0xbb, 0xb8, 0x16, 0x51, 0xf7, 0xc4, 0x51, 0x84, 0x59, 0x03, 0x9d, 0x2e, 0xb1,
+	0x41, 0x06, 0x3d, 0xaa, 0x5c, 0x7e, 0x6e, 0x69, 0xcb, 0x2f, 0x2d, 0x6d, 0xef, 0xdb, 0x16, 0xae,
+	0x6f, 0x0c, 0x60, 0x34, 0x71, 0x25, 0x62, 0xdc, 0x97, 0xdf, 0x03, 0xd9, 0xab, 0xda, 0xab, 0x5a,
+	0x72, 0xf9, 0x60, 0x10, 0xa5, 0xaa, 0xbd, 0xaa, 0x37, 0x43, 0x5b, 0xb8, 0x4e, 0x68, 0x77, 0x71,
+	0xcd, 0x0f, 0xa3, 0x44, 0xa8, 0xf3, 0xa8, 0x28, 0xe9, 0x80, 0x0d, 0x56, 0xd1, 0x60, 0x89, 0x58,
+	0xd3, 0x94, 0x40, 0x53, 0xcb, 0x40, 0x25, 0xfa, 0xeb, 0xb6, 0xca, 0xff, 0xd8, 0xd6, 0x11, 0x3e,
+	0x80, 0x6d, 0x5d, 0x85, 0x23, 0xa0, 0x01, 0x8b, 0xcf, 0x9c, 0x11, 0x0b, 0x79, 0x12, 0x44, 0x72,
+	0xda, 0x8d, 0x6c, 0xed, 0x4b, 0xf9, 0xf3, 0x5c, 0xfd, 0x12, 0xc4, 0xfd, 0x2b, 0x2d, 0xa4, 0xad,
+	0xf7, 0x78, 0x36, 0x27, 0xda, 0xc5, 0x9c, 0x68, 0xcb, 0x39, 0x41, 0x1f, 0x52, 0x82, 0xbe, 0xa6,
+	0x04, 0x9d, 0xa7, 0x04, 0xcd, 0x52, 0x82, 0x7e, 0xa6, 0x04, 0x5d, 0xa6, 0x44, 0x5b, 0xa6, 0x04,
+	0x7d, 0x5c, 0x10, 0x6d, 0xb6, 0x20, 0xda, 0xc5, 0x82, 0x68, 0x6f, 0xb3, 0xdf, 0xc7, 0xb0, 0x0c,
+	0xeb, 0x7a, 0xf0, 0x7b, 0x00, 0xce, 0xe0, 0xa0, 0x25, 0x5b, 0x04, 0x00, 0x00,
+}
+
+func (this *OperatorStats) Equal(that interface{}) bool {
 	if that == nil {
 		return this == nil
 	}
 
-	that1, ok := that.(*Stats)
+	that1, ok := that.(*OperatorStats)
 	if !ok {
-		that2, ok := that.(Stats)
+		that2, ok := that.(OperatorStats)
 		if ok {
 			that1 = &that2
 		} else {
@@ -198,31 +309,25 @@ func (this *Stats) Equal(that interface{}) bool {
 	} else if this == nil {
 		return false
 	}
-	if this.WallTime != that1.WallTime {
-		return false
-	}
-	if this.FetchedSeriesCount != that1.FetchedSeriesCount {
+	if this.Position != that1.Position {
 		return false
 	}
-	if this.FetchedChunkBytes != that1.FetchedChunkBytes {
+	if this.Operator != that1.Operator {
 		return false
 	}
-	if this.FetchedChunksCount != that1.FetchedChunksCount {
-		return false
-	}
-	if this.ShardedQueries != that1.ShardedQueries {
+	if this.WallTime != that1.WallTime {
 		return false
 	}
-	if this.SplitQueries != that1.SplitQueries {
+	if this.InputSeries != that1.InputSeries {
 		return false
 	}
-	if this.FetchedIndexBytes != that1.FetchedIndexBytes {
+	if this.OutputSeries != that1.OutputSeries {
 		return false
 	}
-	if this.EstimatedSeriesCount != that1.EstimatedSeriesCount {
+	if this.SamplesProcessed != that1.SamplesProcessed {
 		return false
 	}
-	if this.QueueTime != that1.QueueTime {
+	if this.PeakEstimatedMemoryConsumptionBytes != that1.PeakEstimatedMemoryConsumptionBytes {
 		return false
 	}
 	return true
@@ -231,7 +336,7 @@ func (this *Stats) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 13)
+	s := make([]string, 0, 16)
 	s = append(s, "&stats.Stats{")
 	s = append(s, "WallTime: "+fmt.Sprintf("%#v", this.WallTime)+",\n")
 	s = append(s, "FetchedSeriesCount: "+fmt.Sprintf("%#v", this.FetchedSeriesCount)+",\n")
@@ -242,6 +347,31 @@ func (this *Stats) GoString() string {
 	s = append(s, "FetchedIndexBytes: "+fmt.Sprintf("%#v", this.FetchedIndexBytes)+",\n")
 	s = append(s, "EstimatedSeriesCount: "+fmt.Sprintf("%#v", this.EstimatedSeriesCount)+",\n")
 	s = append(s, "QueueTime: "+fmt.Sprintf("%#v", this.QueueTime)+",\n")
+	if this.OperatorStats != nil {
+		vs := make([]*OperatorStats, len(this.OperatorStats))
+		for i := range vs {
+			vs[i] = &this.OperatorStats[i]
+		}
+		s = append(s, "OperatorStats: "+fmt.Sprintf("%#v", vs)+",\n")
+	}
+	s = append(s, "SamplesProcessed: "+fmt.Sprintf("%#v", this.SamplesProcessed)+",\n")
+	s = append(s, "PeakSamples: "+fmt.Sprintf("%#v", this.PeakSamples)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *OperatorStats) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 11)
+	s = append(s, "&stats.OperatorStats{")
+	s = append(s, "Position: "+fmt.Sprintf("%#v", this.Position)+",\n")
+	s = append(s, "Operator: "+fmt.Sprintf("%#v", this.Operator)+",\n")
+	s = append(s, "WallTime: "+fmt.Sprintf("%#v", this.WallTime)+",\n")
+	s = append(s, "InputSeries: "+fmt.Sprintf("%#v", this.InputSeries)+",\n")
+	s = append(s, "OutputSeries: "+fmt.Sprintf("%#v", this.OutputSeries)+",\n")
+	s = append(s, "SamplesProcessed: "+fmt.Sprintf("%#v", this.SamplesProcessed)+",\n")
+	s = append(s, "PeakEstimatedMemoryConsumptionBytes: "+fmt.Sprintf("%#v", this.PeakEstimatedMemoryConsumptionBytes)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -273,6 +403,30 @@ func (m *Stats) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.PeakSamples != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.PeakSamples))
+		i--
+		dAtA[i] = 0x60
+	}
+	if m.SamplesProcessed != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.SamplesProcessed))
+		i--
+		dAtA[i] = 0x58
+	}
+	if len(m.OperatorStats) > 0 {
+		for iNdEx := len(m.OperatorStats) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.OperatorStats[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintStats(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x52
+		}
+	}
 	n1, err1 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.QueueTime, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.QueueTime):])
 	if err1 != nil {
 		return 0, err1
@@ -327,6 +481,71 @@ func (m *Stats) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *OperatorStats) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *OperatorStats) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *OperatorStats) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.PeakEstimatedMemoryConsumptionBytes != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.PeakEstimatedMemoryConsumptionBytes))
+		i--
+		dAtA[i] = 0x38
+	}
+	if m.SamplesProcessed != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.SamplesProcessed))
+		i--
+		dAtA[i] = 0x30
+	}
+	if m.OutputSeries != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.OutputSeries))
+		i--
+		dAtA[i] = 0x28
+	}
+	if m.InputSeries != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.InputSeries))
+		i--
+		dAtA[i] = 0x20
+	}
+	n3, err3 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.WallTime, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.WallTime):])
+	if err3 != nil {
+		return 0, err3
+	}
+	i -= n3
+	i = encodeVarintStats(dAtA, i, uint64(n3))
+	i--
+	dAtA[i] = 0x1a
+	if len(m.Operator) > 0 {
+		i -= len(m.Operator)
+		copy(dAtA[i:], m.Operator)
+		i = encodeVarintStats(dAtA, i, uint64(len(m.Operator)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.Position) > 0 {
+		i -= len(m.Position)
+		copy(dAtA[i:], m.Position)
+		i = encodeVarintStats(dAtA, i, uint64(len(m.Position)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
 func encodeVarintStats(dAtA []byte, offset int, v uint64) int {
 	offset -= sovStats(v)
 	base := offset
@@ -369,6 +588,49 @@ func (m *Stats) Size() (n int) {
 	}
 	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.QueueTime)
 	n += 1 + l + sovStats(uint64(l))
+	if len(m.OperatorStats) > 0 {
+		for _, e := range m.OperatorStats {
+			l = e.Size()
+			n += 1 + l + sovStats(uint64(l))
+		}
+	}
+	if m.SamplesProcessed != 0 {
+		n += 1 + sovStats(uint64(m.SamplesProcessed))
+	}
+	if m.PeakSamples != 0 {
+		n += 1 + sovStats(uint64(m.PeakSamples))
+	}
+	return n
+}
+
+func (m *OperatorStats) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Position)
+	if l > 0 {
+		n += 1 + l + sovStats(uint64(l))
+	}
+	l = len(m.Operator)
+	if l > 0 {
+		n += 1 + l + sovStats(uint64(l))
+	}
+	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.WallTime)
+	n += 1 + l + sovStats(uint64(l))
+	if m.InputSeries != 0 {
+		n += 1 + sovStats(uint64(m.InputSeries))
+	}
+	if m.OutputSeries != 0 {
+		n += 1 + sovStats(uint64(m.OutputSeries))
+	}
+	if m.SamplesProcessed != 0 {
+		n += 1 + sovStats(uint64(m.SamplesProcessed))
+	}
+	if m.PeakEstimatedMemoryConsumptionBytes != 0 {
+		n += 1 + sovStats(uint64(m.PeakEstimatedMemoryConsumptionBytes))
+	}
 	return n
 }
 
@@ -382,6 +644,11 @@ func (this *Stats) String() string {
 	if this == nil {
 		return "nil"
 	}
+	repeatedStringForOperatorStats := "[]OperatorStats{"
+	for _, f := range this.OperatorStats {
+		repeatedStringForOperatorStats += strings.Replace(strings.Replace(f.String(), "OperatorStats", "OperatorStats", 1), `&`, ``, 1) + ","
+	}
+	repeatedStringForOperatorStats += "}"
 	s := strings.Join([]string{`&Stats{`,
 		`WallTime:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.WallTime), "Duration", "durationpb.Duration", 1), `&`, ``, 1) + `,`,
 		`FetchedSeriesCount:` + fmt.Sprintf("%v", this.FetchedSeriesCount) + `,`,
@@ -392,6 +659,25 @@ func (this *Stats) String() string {
 		`FetchedIndexBytes:` + fmt.Sprintf("%v", this.FetchedIndexBytes) + `,`,
 		`EstimatedSeriesCount:` + fmt.Sprintf("%v", this.EstimatedSeriesCount) + `,`,
 		`QueueTime:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.QueueTime), "Duration", "durationpb.Duration", 1), `&`, ``, 1) + `,`,
+		`OperatorStats:` + repeatedStringForOperatorStats + `,`,
+		`SamplesProcessed:` + fmt.Sprintf("%v", this.SamplesProcessed) + `,`,
+		`PeakSamples:` + fmt.Sprintf("%v", this.PeakSamples) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *OperatorStats) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&OperatorStats{`,
+		`Position:` + fmt.Sprintf("%v", this.Position) + `,`,
+		`Operator:` + fmt.Sprintf("%v", this.Operator) + `,`,
+		`WallTime:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.WallTime), "Duration", "durationpb.Duration", 1), `&`, ``, 1) + `,`,
+		`InputSeries:` + fmt.Sprintf("%v", this.InputSeries) + `,`,
+		`OutputSeries:` + fmt.Sprintf("%v", this.OutputSeries) + `,`,
+		`SamplesProcessed:` + fmt.Sprintf("%v", this.SamplesProcessed) + `,`,
+		`PeakEstimatedMemoryConsumptionBytes:` + fmt.Sprintf("%v", this.PeakEstimatedMemoryConsumptionBytes) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -632,6 +918,304 @@ func (m *Stats) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field OperatorStats", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStats
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthStats
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.OperatorStats = append(m.OperatorStats, OperatorStats{})
+			if err := m.OperatorStats[len(m.OperatorStats)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 11:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SamplesProcessed", wireType)
+			}
+			m.SamplesProcessed = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SamplesProcessed |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 12:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PeakSamples", wireType)
+			}
+			m.PeakSamples = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.PeakSamples |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStats(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStats
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthStats
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *OperatorStats) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStats
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: OperatorStats: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: OperatorStats: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Position", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStats
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthStats
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Position = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Operator", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStats
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthStats
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Operator = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field WallTime", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStats
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthStats
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := github_com_gogo_protobuf_types.StdDurationUnmarshal(&m.WallTime, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field InputSeries", wireType)
+			}
+			m.InputSeries = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.InputSeries |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field OutputSeries", wireType)
+			}
+			m.OutputSeries = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.OutputSeries |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SamplesProcessed", wireType)
+			}
+			m.SamplesProcessed = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SamplesProcessed |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PeakEstimatedMemoryConsumptionBytes", wireType)
+			}
+			m.PeakEstimatedMemoryConsumptionBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.PeakEstimatedMemoryConsumptionBytes |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipStats(dAtA[iNdEx:])
@@ -7,6 +7,7 @@ package stats
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -132,6 +133,92 @@ func TestStats_QueueTime(t *testing.T) {
 	})
 }
 
+func TestStats_SamplesProcessed(t *testing.T) {
+	t.Run("add and load samples processed", func(t *testing.T) {
+		stats, _ := ContextWithEmptyStats(context.Background())
+		stats.AddSamplesProcessed(10)
+		stats.AddSamplesProcessed(20)
+
+		assert.Equal(t, uint64(30), stats.LoadSamplesProcessed())
+	})
+
+	t.Run("add and load samples processed nil receiver", func(t *testing.T) {
+		var stats *Stats
+		stats.AddSamplesProcessed(10)
+
+		assert.Equal(t, uint64(0), stats.LoadSamplesProcessed())
+	})
+}
+
+func TestStats_PeakSamples(t *testing.T) {
+	t.Run("update and load peak samples", func(t *testing.T) {
+		stats, _ := ContextWithEmptyStats(context.Background())
+		stats.UpdatePeakSamples(10)
+		stats.UpdatePeakSamples(30)
+		stats.UpdatePeakSamples(20)
+
+		assert.Equal(t, uint64(30), stats.LoadPeakSamples())
+	})
+
+	t.Run("update and load peak samples nil receiver", func(t *testing.T) {
+		var stats *Stats
+		stats.UpdatePeakSamples(10)
+
+		assert.Equal(t, uint64(0), stats.LoadPeakSamples())
+	})
+}
+
+func TestStats_OperatorStats(t *testing.T) {
+	t.Run("add and load operator stats", func(t *testing.T) {
+		stats, _ := ContextWithEmptyStats(context.Background())
+		stats.AddOperatorStats(
+			OperatorStats{Position: "0", Operator: "Aggregation: sum", WallTime: time.Second, InputSeries: 10, OutputSeries: 1, SamplesProcessed: 100, PeakEstimatedMemoryConsumptionBytes: 1000},
+			OperatorStats{Position: "0.0", Operator: "InstantVectorSelector", WallTime: 2 * time.Second, OutputSeries: 10, SamplesProcessed: 100, PeakEstimatedMemoryConsumptionBytes: 500},
+		)
+		stats.AddOperatorStats(
+			OperatorStats{Position: "0", Operator: "Aggregation: sum", WallTime: time.Second, InputSeries: 5, OutputSeries: 1, SamplesProcessed: 50, PeakEstimatedMemoryConsumptionBytes: 800},
+			OperatorStats{Position: "0.0", Operator: "FunctionOverInstantVector: abs", WallTime: time.Second, InputSeries: 5, OutputSeries: 5, SamplesProcessed: 50, PeakEstimatedMemoryConsumptionBytes: 600},
+		)
+
+		assert.Equal(t, []OperatorStats{
+			{Position: "0", Operator: "Aggregation: sum", WallTime: 2 * time.Second, InputSeries: 15, OutputSeries: 2, SamplesProcessed: 150, PeakEstimatedMemoryConsumptionBytes: 1000},
+			{Position: "0.0", Operator: "InstantVectorSelector", WallTime: 2 * time.Second, OutputSeries: 10, SamplesProcessed: 100, PeakEstimatedMemoryConsumptionBytes: 500},
+			{Position: "0.0", Operator: "FunctionOverInstantVector: abs", WallTime: time.Second, InputSeries: 5, OutputSeries: 5, SamplesProcessed: 50, PeakEstimatedMemoryConsumptionBytes: 600},
+		}, stats.LoadOperatorStats())
+	})
+
+	t.Run("add operator stats concurrently", func(t *testing.T) {
+		stats, _ := ContextWithEmptyStats(context.Background())
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				partial := &Stats{}
+				partial.AddOperatorStats(
+					OperatorStats{Position: "0", Operator: "Aggregation: sum", WallTime: time.Second, OutputSeries: 1},
+					OperatorStats{Position: "0", Operator: "Aggregation: sum", WallTime: time.Second, OutputSeries: 1},
+				)
+				stats.Merge(partial)
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, []OperatorStats{
+			{Position: "0", Operator: "Aggregation: sum", WallTime: 20 * time.Second, OutputSeries: 20},
+		}, stats.LoadOperatorStats())
+	})
+
+	t.Run("add and load operator stats nil receiver", func(t *testing.T) {
+		var stats *Stats
+		stats.AddOperatorStats(OperatorStats{Position: "0", Operator: "Aggregation: sum", WallTime: time.Second})
+
+		assert.Nil(t, stats.LoadOperatorStats())
+	})
+}
+
 func TestStats_Merge(t *testing.T) {
 	t.Run("merge two stats objects", func(t *testing.T) {
 		stats1 := &Stats{}
@@ -142,6 +229,9 @@ func TestStats_Merge(t *testing.T) {
 		stats1.AddShardedQueries(20)
 		stats1.AddSplitQueries(10)
 		stats1.AddQueueTime(5 * time.Second)
+		stats1.AddSamplesProcessed(100)
+		stats1.UpdatePeakSamples(40)
+		stats1.AddOperatorStats(OperatorStats{Position: "0", Operator: "InstantVectorSelector", WallTime: time.Second, OutputSeries: 2})
 
 		stats2 := &Stats{}
 		stats2.AddWallTime(time.Second)
@@ -151,6 +241,9 @@ func TestStats_Merge(t *testing.T) {
 		stats2.AddShardedQueries(21)
 		stats2.AddSplitQueries(11)
 		stats2.AddQueueTime(10 * time.Second)
+		stats2.AddSamplesProcessed(200)
+		stats2.UpdatePeakSamples(30)
+		stats2.AddOperatorStats(OperatorStats{Position: "0", Operator: "InstantVectorSelector", WallTime: time.Second, OutputSeries: 3})
 
 		stats1.Merge(stats2)
 
@@ -161,6 +254,9 @@ func TestStats_Merge(t *testing.T) {
 		assert.Equal(t, uint32(41), stats1.LoadShardedQueries())
 		assert.Equal(t, uint32(21), stats1.LoadSplitQueries())
 		assert.Equal(t, 15*time.Second, stats1.LoadQueueTime())
+		assert.Equal(t, uint64(300), stats1.LoadSamplesProcessed())
+		assert.Equal(t, uint64(40), stats1.LoadPeakSamples())
+		assert.Equal(t, []OperatorStats{{Position: "0", Operator: "InstantVectorSelector", WallTime: 2 * time.Second, OutputSeries: 5}}, stats1.LoadOperatorStats())
 	})
 
 	t.Run("merge two nil stats objects", func(t *testing.T) {
@@ -176,6 +272,8 @@ func TestStats_Merge(t *testing.T) {
 		assert.Equal(t, uint32(0), stats1.LoadShardedQueries())
 		assert.Equal(t, uint32(0), stats1.LoadSplitQueries())
 		assert.Equal(t, time.Duration(0), stats1.LoadQueueTime())
+		assert.Equal(t, uint64(0), stats1.LoadSamplesProcessed())
+		assert.Equal(t, uint64(0), stats1.LoadPeakSamples())
 	})
 }
 
@@ -190,6 +288,11 @@ func TestStats_Copy(t *testing.T) {
 		FetchedIndexBytes:    7,
 		EstimatedSeriesCount: 8,
 		QueueTime:            9,
+		SamplesProcessed:     12,
+		PeakSamples:          13,
+		OperatorStats: []OperatorStats{
+			{Position: "0", Operator: "InstantVectorSelector", WallTime: 10, OutputSeries: 11},
+		},
 	}
 	s2 := s1.Copy()
 	assert.NotSame(t, s1, s2)
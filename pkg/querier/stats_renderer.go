@@ -0,0 +1,30 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+
+	promql_stats "github.com/prometheus/prometheus/util/stats"
+	v1 "github.com/prometheus/prometheus/web/api/v1"
+
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
+)
+
+// StatsRenderer implements github.com/prometheus/prometheus/web/api/v1.StatsRenderer.
+//
+// It records the number of samples processed by the query engine in the query's stats, so that they are returned to
+// the query-frontend, and then renders the engine statistics in the same way as Prometheus.
+func StatsRenderer(ctx context.Context, s *promql_stats.Statistics, param string) promql_stats.QueryStats {
+	if s == nil {
+		return nil
+	}
+
+	if s.Samples != nil {
+		queryStats := querier_stats.FromContext(ctx)
+		queryStats.AddSamplesProcessed(uint64(s.Samples.TotalSamples))
+		queryStats.UpdatePeakSamples(uint64(s.Samples.PeakSamples))
+	}
+
+	return v1.DefaultStatsRenderer(ctx, s, param)
+}
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+	"testing"
+
+	promql_stats "github.com/prometheus/prometheus/util/stats"
+	"github.com/stretchr/testify/require"
+
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
+)
+
+func TestStatsRenderer(t *testing.T) {
+	engineStats := &promql_stats.Statistics{
+		Timers:  promql_stats.NewQueryTimers(),
+		Samples: &promql_stats.QuerySamples{TotalSamples: 100, PeakSamples: 20},
+	}
+
+	t.Run("records samples in query stats", func(t *testing.T) {
+		queryStats, ctx := querier_stats.ContextWithEmptyStats(context.Background())
+		queryStats.UpdatePeakSamples(30)
+
+		require.Nil(t, StatsRenderer(ctx, engineStats, ""))
+		require.Equal(t, uint64(100), queryStats.LoadSamplesProcessed())
+		require.Equal(t, uint64(30), queryStats.LoadPeakSamples())
+	})
+
+	t.Run("renders engine statistics if requested", func(t *testing.T) {
+		rendered := StatsRenderer(context.Background(), engineStats, "all")
+		require.NotNil(t, rendered)
+
+		builtin, ok := rendered.(*promql_stats.BuiltinStats)
+		require.True(t, ok)
+		require.Equal(t, int64(100), builtin.Samples.TotalQueryableSamples)
+		require.Equal(t, 20, builtin.Samples.PeakSamples)
+	})
+
+	t.Run("no engine statistics", func(t *testing.T) {
+		queryStats, ctx := querier_stats.ContextWithEmptyStats(context.Background())
+
+		require.Nil(t, StatsRenderer(ctx, nil, "all"))
+		require.Zero(t, queryStats.LoadSamplesProcessed())
+	})
+}
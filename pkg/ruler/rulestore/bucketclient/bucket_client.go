@@ -13,6 +13,7 @@ import (
 	"io"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -205,6 +206,22 @@ func (b *BucketRuleStore) GetRuleGroup(ctx context.Context, userID string, names
 	return b.getRuleGroup(ctx, userID, namespace, group, nil)
 }
 
+// GetRuleGroupLastModified implements rules.RuleStore.
+func (b *BucketRuleStore) GetRuleGroupLastModified(ctx context.Context, userID string, namespace string, group string) (time.Time, error) {
+	userBucket := bucket.NewUserBucketClient(userID, b.bucket, b.cfgProvider)
+	objectKey := getRuleGroupObjectKey(namespace, group)
+
+	attrs, err := userBucket.Attributes(ctx, objectKey)
+	if userBucket.IsObjNotFoundErr(err) {
+		return time.Time{}, rulestore.ErrGroupNotFound
+	}
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "failed to get attributes of rule group %s", objectKey)
+	}
+
+	return attrs.LastModified, nil
+}
+
 // SetRuleGroup implements rules.RuleStore.
 func (b *BucketRuleStore) SetRuleGroup(ctx context.Context, userID string, namespace string, group *rulespb.RuleGroupDesc) error {
 	userBucket := bucket.NewUserBucketClient(userID, b.bucket, b.cfgProvider)
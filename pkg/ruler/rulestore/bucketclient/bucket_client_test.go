@@ -266,6 +266,22 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestGetRuleGroupLastModified(t *testing.T) {
+	rs := NewBucketRuleStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+
+	before := time.Now()
+	require.NoError(t, rs.SetRuleGroup(context.Background(), "user1", "namespace", rulespb.ToProto("user1", "namespace", rulefmt.RuleGroup{Name: "group"})))
+	after := time.Now()
+
+	lastModified, err := rs.GetRuleGroupLastModified(context.Background(), "user1", "namespace", "group")
+	require.NoError(t, err)
+	require.False(t, lastModified.Before(before))
+	require.False(t, lastModified.After(after))
+
+	_, err = rs.GetRuleGroupLastModified(context.Background(), "user1", "namespace", "missing")
+	require.ErrorIs(t, err, rulestore.ErrGroupNotFound)
+}
+
 func getSortedObjectKeys(bucketClient interface{}) []string {
 	if typed, ok := bucketClient.(*objstore.InMemBucket); ok {
 		var keys []string
@@ -9,6 +9,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/prometheus/model/rulefmt"
@@ -111,6 +112,25 @@ func (l *Client) GetRuleGroup(ctx context.Context, userID string, namespace stri
 	return nil, rulestore.ErrGroupNotFound
 }
 
+// GetRuleGroupLastModified implements RuleStore. Since all the rule groups of a namespace are stored in the
+// same file, it returns when any rule group of the namespace has been last modified.
+func (l *Client) GetRuleGroupLastModified(_ context.Context, userID string, namespace string, _ string) (time.Time, error) {
+	if namespace == "" {
+		return time.Time{}, errors.New("empty namespace")
+	}
+
+	filename := filepath.Join(l.cfg.Directory, userID, namespace)
+	info, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		return time.Time{}, rulestore.ErrGroupNotFound
+	}
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "unable to stat rule file %s", filename)
+	}
+
+	return info.ModTime(), nil
+}
+
 // SetRuleGroup implements RuleStore
 func (l *Client) SetRuleGroup(_ context.Context, _, _ string, _ *rulespb.RuleGroupDesc) error {
 	return errors.New("SetRuleGroup unsupported in rule local store")
@@ -8,6 +8,7 @@ package rulestore
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
 )
@@ -49,6 +50,11 @@ type RuleStore interface {
 	LoadRuleGroups(ctx context.Context, groupsToLoad map[string]rulespb.RuleGroupList) (missing rulespb.RuleGroupList, err error)
 
 	GetRuleGroup(ctx context.Context, userID, namespace, group string) (*rulespb.RuleGroupDesc, error)
+
+	// GetRuleGroupLastModified returns when the rule group has been last modified in the store.
+	// It returns ErrGroupNotFound if the rule group does not exist.
+	GetRuleGroupLastModified(ctx context.Context, userID, namespace, group string) (time.Time, error)
+
 	SetRuleGroup(ctx context.Context, userID, namespace string, group *rulespb.RuleGroupDesc) error
 
 	// DeleteRuleGroup deletes single rule group.
@@ -142,6 +142,13 @@ func (m *mockRuleStore) GetRuleGroup(_ context.Context, userID string, namespace
 	return nil, rulestore.ErrGroupNotFound
 }
 
+func (m *mockRuleStore) GetRuleGroupLastModified(ctx context.Context, userID string, namespace string, group string) (time.Time, error) {
+	if _, err := m.GetRuleGroup(ctx, userID, namespace, group); err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, nil
+}
+
 func (m *mockRuleStore) SetRuleGroup(_ context.Context, userID string, namespace string, group *rulespb.RuleGroupDesc) error {
 	m.mtx.Lock()
 	defer m.mtx.Unlock()
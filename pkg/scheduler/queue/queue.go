@@ -55,6 +55,10 @@ type SchedulerRequest struct {
 
 	EnqueueTime time.Time
 
+	// QuerierID and DequeueTime are set once the request has been dequeued and forwarded to a querier.
+	QuerierID   string
+	DequeueTime time.Time
+
 	Ctx        context.Context
 	CancelFunc context.CancelCauseFunc
 	QueueSpan  opentracing.Span
@@ -33,6 +33,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	apierror "github.com/grafana/mimir/pkg/api/error"
 	"github.com/grafana/mimir/pkg/frontend/v2/frontendv2pb"
 	"github.com/grafana/mimir/pkg/scheduler/queue"
 	"github.com/grafana/mimir/pkg/scheduler/schedulerdiscovery"
@@ -45,6 +46,10 @@ import (
 var errEnqueuingRequestFailed = cancellation.NewErrorf("enqueuing request failed")
 var errFrontendDisconnected = cancellation.NewErrorf("frontend disconnected")
 
+var errActiveQueriesNoTenant = status.Error(codes.InvalidArgument, "no tenant specified in the active queries request")
+
+const activeQueryCanceledReason = "query canceled through the active queries API"
+
 // Scheduler is responsible for queueing and dispatching queries to Queriers.
 type Scheduler struct {
 	services.Service
@@ -405,6 +410,117 @@ func (s *Scheduler) cancelRequestAndRemoveFromPending(key queue.RequestKey, reas
 	return req
 }
 
+// ListActiveQueries returns the queries of the requested tenant that are currently queued or being executed by a querier.
+//
+// Like FrontendLoop, it is called by query-frontends on behalf of any tenant, so the tenant is taken from the request
+// rather than from the gRPC metadata.
+func (s *Scheduler) ListActiveQueries(_ context.Context, req *schedulerpb.ListActiveQueriesRequest) (*schedulerpb.ListActiveQueriesResponse, error) {
+	if req.UserID == "" {
+		return nil, errActiveQueriesNoTenant
+	}
+
+	s.inflightRequestsMu.Lock()
+	queries := make([]*schedulerpb.ActiveQuery, 0, len(s.schedulerInflightRequests))
+	requests := make([]*httpgrpc.HTTPRequest, 0, len(s.schedulerInflightRequests))
+	for _, r := range s.schedulerInflightRequests {
+		if r.UserID != req.UserID {
+			continue
+		}
+
+		query := &schedulerpb.ActiveQuery{
+			QueryID:              r.QueryID,
+			FrontendAddress:      r.FrontendAddr,
+			UserID:               r.UserID,
+			EnqueueTimeUnixNanos: r.EnqueueTime.UnixNano(),
+			QuerierID:            r.QuerierID,
+		}
+		if r.QuerierID != "" {
+			query.QueueTimeNanos = r.DequeueTime.Sub(r.EnqueueTime).Nanoseconds()
+		}
+
+		queries = append(queries, query)
+		requests = append(requests, r.Request)
+	}
+	s.inflightRequestsMu.Unlock()
+
+	// Parse the HTTP requests outside the lock, to not block the scheduling of queries.
+	for i, query := range queries {
+		query.Path, query.Query = activeQueryPathAndExpression(requests[i])
+	}
+
+	return &schedulerpb.ListActiveQueriesResponse{Queries: queries}, nil
+}
+
+// activeQueryPathAndExpression returns the path of req and the PromQL expression it contains, if any.
+func activeQueryPathAndExpression(req *httpgrpc.HTTPRequest) (string, string) {
+	if req == nil {
+		return "", ""
+	}
+
+	httpReq, err := httpgrpc.ToHTTPRequest(context.Background(), req)
+	if err != nil {
+		return "", ""
+	}
+
+	if err := httpReq.ParseForm(); err != nil {
+		return httpReq.URL.Path, ""
+	}
+
+	return httpReq.URL.Path, httpReq.Form.Get("query")
+}
+
+// CancelActiveQuery cancels a query of the requested tenant that is currently queued or being executed by a querier.
+//
+// If the query is being executed, the querier is notified of the cancellation and reports the outcome to the frontend.
+// If the query is still queued, the cancellation is reported to the frontend directly.
+func (s *Scheduler) CancelActiveQuery(ctx context.Context, req *schedulerpb.CancelActiveQueryRequest) (*schedulerpb.CancelActiveQueryResponse, error) {
+	if req.UserID == "" {
+		return nil, errActiveQueriesNoTenant
+	}
+
+	key := queue.NewSchedulerRequestKey(req.FrontendAddress, req.QueryID)
+
+	s.inflightRequestsMu.Lock()
+	schedulerReq := s.schedulerInflightRequests[key]
+	found := schedulerReq != nil && schedulerReq.UserID == req.UserID
+	queued := found && schedulerReq.QuerierID == ""
+	s.inflightRequestsMu.Unlock()
+
+	if !found {
+		return &schedulerpb.CancelActiveQueryResponse{Canceled: false}, nil
+	}
+
+	level.Info(s.log).Log("msg", "canceling query through the active queries API", "user", req.UserID, "frontend", req.FrontendAddress, "queryID", req.QueryID, "queued", queued)
+	s.cancelRequestAndRemoveFromPending(key, activeQueryCanceledReason)
+
+	if queued {
+		// The request will be discarded once dequeued, without being forwarded to a querier,
+		// so we notify the frontend, which would otherwise wait for a response until it times out.
+		apiErr := apierror.New(apierror.TypeCanceled, activeQueryCanceledReason)
+		body, err := apiErr.EncodeJSON()
+		if err != nil {
+			return nil, err
+		}
+
+		s.forwardResponseToFrontend(ctx, schedulerReq, &httpgrpc.HTTPResponse{
+			Code:    int32(apiErr.StatusCode()),
+			Headers: []*httpgrpc.Header{{Key: "Content-Type", Values: []string{"application/json"}}},
+			Body:    body,
+		}, apiErr)
+	}
+
+	return &schedulerpb.CancelActiveQueryResponse{Canceled: true}, nil
+}
+
+// markRequestForwardedToQuerier records that req has been dequeued and is being forwarded to the querier with querierID.
+func (s *Scheduler) markRequestForwardedToQuerier(req *queue.SchedulerRequest, querierID string, dequeueTime time.Time) {
+	s.inflightRequestsMu.Lock()
+	defer s.inflightRequestsMu.Unlock()
+
+	req.QuerierID = querierID
+	req.DequeueTime = dequeueTime
+}
+
 // QuerierLoop is started by querier to receive queries from scheduler.
 func (s *Scheduler) QuerierLoop(querier schedulerpb.SchedulerForQuerier_QuerierLoopServer) error {
 	resp, err := querier.Recv()
@@ -437,7 +553,8 @@ func (s *Scheduler) QuerierLoop(querier schedulerpb.SchedulerForQuerier_QuerierL
 
 		schedulerReq := queueReq.(*queue.SchedulerRequest)
 
-		queueTime := time.Since(schedulerReq.EnqueueTime)
+		dequeueTime := time.Now()
+		queueTime := dequeueTime.Sub(schedulerReq.EnqueueTime)
 		additionalQueueDimensionLabels := strings.Join(schedulerReq.AdditionalQueueDimensions, ":")
 		s.queueDuration.WithLabelValues(schedulerReq.UserID, additionalQueueDimensionLabels).Observe(queueTime.Seconds())
 		schedulerReq.QueueSpan.Finish()
@@ -462,6 +579,7 @@ func (s *Scheduler) QuerierLoop(querier schedulerpb.SchedulerForQuerier_QuerierL
 			continue
 		}
 
+		s.markRequestForwardedToQuerier(schedulerReq, querierID, dequeueTime)
 		if err := s.forwardRequestToQuerier(querier, schedulerReq, queueTime); err != nil {
 			return err
 		}
@@ -524,6 +642,15 @@ func (s *Scheduler) forwardRequestToQuerier(querier schedulerpb.SchedulerForQuer
 }
 
 func (s *Scheduler) forwardErrorToFrontend(ctx context.Context, req *queue.SchedulerRequest, requestErr error) {
+	s.forwardResponseToFrontend(ctx, req, &httpgrpc.HTTPResponse{
+		Code: http.StatusInternalServerError,
+		Body: []byte(requestErr.Error()),
+	}, requestErr)
+}
+
+// forwardResponseToFrontend sends resp to the frontend as the result of req, in place of the querier.
+// requestErr is the reason the scheduler is responding, and is only used for logging.
+func (s *Scheduler) forwardResponseToFrontend(ctx context.Context, req *queue.SchedulerRequest, resp *httpgrpc.HTTPResponse, requestErr error) {
 	opts, err := s.cfg.GRPCClientConfig.DialOption([]grpc.UnaryClientInterceptor{
 		otgrpc.OpenTracingClientInterceptor(opentracing.GlobalTracer()),
 		middleware.ClientUserHeaderInterceptor},
@@ -548,11 +675,8 @@ func (s *Scheduler) forwardErrorToFrontend(ctx context.Context, req *queue.Sched
 
 	userCtx := user.InjectOrgID(ctx, req.UserID)
 	_, err = client.QueryResult(userCtx, &frontendv2pb.QueryResultRequest{
-		QueryID: req.QueryID,
-		HttpResponse: &httpgrpc.HTTPResponse{
-			Code: http.StatusInternalServerError,
-			Body: []byte(requestErr.Error()),
-		},
+		QueryID:      req.QueryID,
+		HttpResponse: resp,
 	})
 
 	if err != nil {
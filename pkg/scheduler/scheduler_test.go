@@ -469,6 +469,142 @@ func TestSchedulerForwardsErrorToFrontend(t *testing.T) {
 	verifyQueryComponentUtilizationLeft(t, scheduler)
 }
 
+func TestSchedulerListActiveQueries(t *testing.T) {
+	_, frontendClient, querierClient := setupScheduler(t, nil)
+
+	frontendLoop := initFrontendLoop(t, frontendClient, "frontend-12345")
+	frontendToScheduler(t, frontendLoop, &schedulerpb.FrontendToScheduler{
+		Type:        schedulerpb.ENQUEUE,
+		QueryID:     1,
+		UserID:      "test",
+		HttpRequest: &httpgrpc.HTTPRequest{Method: "GET", Url: "/prometheus/api/v1/query?query=up"},
+	})
+
+	// Dequeue the first query, so that it's being executed by the querier.
+	querierLoop := initQuerierLoop(t, querierClient, "querier-1")
+	_, err := querierLoop.Recv()
+	require.NoError(t, err)
+
+	frontendToScheduler(t, frontendLoop, &schedulerpb.FrontendToScheduler{
+		Type:    schedulerpb.ENQUEUE,
+		QueryID: 2,
+		UserID:  "test",
+		HttpRequest: &httpgrpc.HTTPRequest{
+			Method:  "POST",
+			Url:     "/prometheus/api/v1/query_range",
+			Headers: []*httpgrpc.Header{{Key: "Content-Type", Values: []string{"application/x-www-form-urlencoded"}}},
+			Body:    []byte("query=sum%28rate%28some_metric%5B5m%5D%29%29&start=0&end=3600&step=60"),
+		},
+	})
+	frontendToScheduler(t, frontendLoop, &schedulerpb.FrontendToScheduler{
+		Type:        schedulerpb.ENQUEUE,
+		QueryID:     3,
+		UserID:      "another",
+		HttpRequest: &httpgrpc.HTTPRequest{Method: "GET", Url: "/prometheus/api/v1/query?query=up"},
+	})
+
+	_, err = frontendClient.ListActiveQueries(context.Background(), &schedulerpb.ListActiveQueriesRequest{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	resp, err := frontendClient.ListActiveQueries(context.Background(), &schedulerpb.ListActiveQueriesRequest{UserID: "test"})
+	require.NoError(t, err)
+	require.Len(t, resp.Queries, 2)
+
+	queries := map[uint64]*schedulerpb.ActiveQuery{}
+	for _, q := range resp.Queries {
+		require.Equal(t, "test", q.UserID)
+		require.Equal(t, "frontend-12345", q.FrontendAddress)
+		require.Greater(t, q.EnqueueTimeUnixNanos, int64(0))
+		queries[q.QueryID] = q
+	}
+
+	require.Equal(t, "/prometheus/api/v1/query", queries[1].Path)
+	require.Equal(t, "up", queries[1].Query)
+	require.Equal(t, "querier-1", queries[1].QuerierID)
+	require.Greater(t, queries[1].QueueTimeNanos, int64(0))
+
+	require.Equal(t, "/prometheus/api/v1/query_range", queries[2].Path)
+	require.Equal(t, "sum(rate(some_metric[5m]))", queries[2].Query)
+	require.Empty(t, queries[2].QuerierID)
+	require.Zero(t, queries[2].QueueTimeNanos)
+
+	// Complete the query being executed.
+	require.NoError(t, querierLoop.Send(&schedulerpb.QuerierToScheduler{}))
+	test.Poll(t, time.Second, 1, func() interface{} {
+		resp, err := frontendClient.ListActiveQueries(context.Background(), &schedulerpb.ListActiveQueriesRequest{UserID: "test"})
+		require.NoError(t, err)
+		return len(resp.Queries)
+	})
+}
+
+func TestSchedulerCancelActiveQuery(t *testing.T) {
+	t.Run("queued query", func(t *testing.T) {
+		scheduler, frontendClient, querierClient := setupScheduler(t, nil)
+		fm, frontendAddress := startFrontendMock(t)
+
+		frontendLoop := initFrontendLoop(t, frontendClient, frontendAddress)
+		frontendToScheduler(t, frontendLoop, &schedulerpb.FrontendToScheduler{
+			Type:        schedulerpb.ENQUEUE,
+			QueryID:     1,
+			UserID:      "test",
+			HttpRequest: &httpgrpc.HTTPRequest{Method: "GET", Url: "/hello"},
+		})
+
+		// Queries of other tenants can't be canceled.
+		resp, err := frontendClient.CancelActiveQuery(context.Background(), &schedulerpb.CancelActiveQueryRequest{UserID: "another", QueryID: 1, FrontendAddress: frontendAddress})
+		require.NoError(t, err)
+		require.False(t, resp.Canceled)
+
+		resp, err = frontendClient.CancelActiveQuery(context.Background(), &schedulerpb.CancelActiveQueryRequest{UserID: "test", QueryID: 1, FrontendAddress: frontendAddress})
+		require.NoError(t, err)
+		require.True(t, resp.Canceled)
+
+		// The frontend is notified of the cancellation, and the query is never forwarded to a querier.
+		httpResp := fm.getRequest(1)
+		require.NotNil(t, httpResp)
+		require.Equal(t, int32(499), httpResp.Code)
+		require.Contains(t, string(httpResp.Body), `"errorType":"canceled"`)
+
+		querierLoop := initQuerierLoop(t, querierClient, "querier-1")
+		verifyQuerierDoesntReceiveRequest(t, querierLoop, 100*time.Millisecond)
+		verifyNoPendingRequestsLeft(t, scheduler)
+
+		// The query is no longer active.
+		resp, err = frontendClient.CancelActiveQuery(context.Background(), &schedulerpb.CancelActiveQueryRequest{UserID: "test", QueryID: 1, FrontendAddress: frontendAddress})
+		require.NoError(t, err)
+		require.False(t, resp.Canceled)
+	})
+
+	t.Run("query being executed", func(t *testing.T) {
+		scheduler, frontendClient, querierClient := setupScheduler(t, nil)
+		fm, frontendAddress := startFrontendMock(t)
+
+		frontendLoop := initFrontendLoop(t, frontendClient, frontendAddress)
+		frontendToScheduler(t, frontendLoop, &schedulerpb.FrontendToScheduler{
+			Type:        schedulerpb.ENQUEUE,
+			QueryID:     1,
+			UserID:      "test",
+			HttpRequest: &httpgrpc.HTTPRequest{Method: "GET", Url: "/hello"},
+		})
+
+		querierLoop := initQuerierLoop(t, querierClient, "querier-1")
+		_, err := querierLoop.Recv()
+		require.NoError(t, err)
+
+		resp, err := frontendClient.CancelActiveQuery(context.Background(), &schedulerpb.CancelActiveQueryRequest{UserID: "test", QueryID: 1, FrontendAddress: frontendAddress})
+		require.NoError(t, err)
+		require.True(t, resp.Canceled)
+
+		// The querier is notified of the cancellation, and is responsible for reporting it to the frontend.
+		_, err = querierLoop.Recv()
+		require.Equal(t, codes.Canceled, status.Code(err))
+		require.Nil(t, fm.getRequest(1))
+
+		verifyNoPendingRequestsLeft(t, scheduler)
+		verifyQueryComponentUtilizationLeft(t, scheduler)
+	})
+}
+
 func TestSchedulerQueueMetrics(t *testing.T) {
 	reg := prometheus.NewPedanticRegistry()
 
@@ -632,6 +768,27 @@ func (f *frontendMock) QueryResultStream(_ frontendv2pb.FrontendForQuerier_Query
 	panic("unexpected call to QueryResultStream")
 }
 
+// startFrontendMock starts a gRPC server serving a frontendMock, and returns the mock and the server's address.
+func startFrontendMock(t *testing.T) (*frontendMock, string) {
+	fm := &frontendMock{resp: map[uint64]*httpgrpc.HTTPResponse{}}
+
+	frontendGrpcServer := grpc.NewServer()
+	frontendv2pb.RegisterFrontendForQuerierServer(frontendGrpcServer, fm)
+
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	go func() {
+		_ = frontendGrpcServer.Serve(l)
+	}()
+
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+
+	return fm, l.Addr().String()
+}
+
 func (f *frontendMock) getRequest(queryID uint64) *httpgrpc.HTTPResponse {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -441,6 +441,299 @@ func (m *NotifyQuerierShutdownResponse) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_NotifyQuerierShutdownResponse proto.InternalMessageInfo
 
+type ListActiveQueriesRequest struct {
+	UserID string `protobuf:"bytes,1,opt,name=userID,proto3" json:"userID,omitempty"`
+}
+
+func (m *ListActiveQueriesRequest) Reset()      { *m = ListActiveQueriesRequest{} }
+func (*ListActiveQueriesRequest) ProtoMessage() {}
+func (*ListActiveQueriesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2b3fc28395a6d9c5, []int{6}
+}
+func (m *ListActiveQueriesRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ListActiveQueriesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ListActiveQueriesRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *ListActiveQueriesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListActiveQueriesRequest.Merge(m, src)
+}
+func (m *ListActiveQueriesRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *ListActiveQueriesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListActiveQueriesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListActiveQueriesRequest proto.InternalMessageInfo
+
+func (m *ListActiveQueriesRequest) GetUserID() string {
+	if m != nil {
+		return m.UserID
+	}
+	return ""
+}
+
+type ListActiveQueriesResponse struct {
+	Queries []*ActiveQuery `protobuf:"bytes,1,rep,name=queries,proto3" json:"queries,omitempty"`
+}
+
+func (m *ListActiveQueriesResponse) Reset()      { *m = ListActiveQueriesResponse{} }
+func (*ListActiveQueriesResponse) ProtoMessage() {}
+func (*ListActiveQueriesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2b3fc28395a6d9c5, []int{7}
+}
+func (m *ListActiveQueriesResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ListActiveQueriesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ListActiveQueriesResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *ListActiveQueriesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListActiveQueriesResponse.Merge(m, src)
+}
+func (m *ListActiveQueriesResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *ListActiveQueriesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListActiveQueriesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListActiveQueriesResponse proto.InternalMessageInfo
+
+func (m *ListActiveQueriesResponse) GetQueries() []*ActiveQuery {
+	if m != nil {
+		return m.Queries
+	}
+	return nil
+}
+
+type ActiveQuery struct {
+	// Query ID and address of the frontend which enqueued the query. Together they identify the query.
+	QueryID         uint64 `protobuf:"varint,1,opt,name=queryID,proto3" json:"queryID,omitempty"`
+	FrontendAddress string `protobuf:"bytes,2,opt,name=frontendAddress,proto3" json:"frontendAddress,omitempty"`
+	UserID          string `protobuf:"bytes,3,opt,name=userID,proto3" json:"userID,omitempty"`
+	// Path of the HTTP request and the PromQL expression of the query, if any.
+	Path  string `protobuf:"bytes,4,opt,name=path,proto3" json:"path,omitempty"`
+	Query string `protobuf:"bytes,5,opt,name=query,proto3" json:"query,omitempty"`
+	// When the query was enqueued, in Unix nanoseconds.
+	EnqueueTimeUnixNanos int64 `protobuf:"varint,6,opt,name=enqueueTimeUnixNanos,proto3" json:"enqueueTimeUnixNanos,omitempty"`
+	// ID of the querier executing the query, or empty if the query is still queued.
+	QuerierID string `protobuf:"bytes,7,opt,name=querierID,proto3" json:"querierID,omitempty"`
+	// How much time did query spend in the queue. Set only once the query has been forwarded to a querier.
+	QueueTimeNanos int64 `protobuf:"varint,8,opt,name=queueTimeNanos,proto3" json:"queueTimeNanos,omitempty"`
+}
+
+func (m *ActiveQuery) Reset()      { *m = ActiveQuery{} }
+func (*ActiveQuery) ProtoMessage() {}
+func (*ActiveQuery) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2b3fc28395a6d9c5, []int{8}
+}
+func (m *ActiveQuery) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ActiveQuery) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ActiveQuery.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *ActiveQuery) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ActiveQuery.Merge(m, src)
+}
+func (m *ActiveQuery) XXX_Size() int {
+	return m.Size()
+}
+func (m *ActiveQuery) XXX_DiscardUnknown() {
+	xxx_messageInfo_ActiveQuery.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ActiveQuery proto.InternalMessageInfo
+
+func (m *ActiveQuery) GetQueryID() uint64 {
+	if m != nil {
+		return m.QueryID
+	}
+	return 0
+}
+
+func (m *ActiveQuery) GetFrontendAddress() string {
+	if m != nil {
+		return m.FrontendAddress
+	}
+	return ""
+}
+
+func (m *ActiveQuery) GetUserID() string {
+	if m != nil {
+		return m.UserID
+	}
+	return ""
+}
+
+func (m *ActiveQuery) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *ActiveQuery) GetQuery() string {
+	if m != nil {
+		return m.Query
+	}
+	return ""
+}
+
+func (m *ActiveQuery) GetEnqueueTimeUnixNanos() int64 {
+	if m != nil {
+		return m.EnqueueTimeUnixNanos
+	}
+	return 0
+}
+
+func (m *ActiveQuery) GetQuerierID() string {
+	if m != nil {
+		return m.QuerierID
+	}
+	return ""
+}
+
+func (m *ActiveQuery) GetQueueTimeNanos() int64 {
+	if m != nil {
+		return m.QueueTimeNanos
+	}
+	return 0
+}
+
+type CancelActiveQueryRequest struct {
+	UserID          string `protobuf:"bytes,1,opt,name=userID,proto3" json:"userID,omitempty"`
+	QueryID         uint64 `protobuf:"varint,2,opt,name=queryID,proto3" json:"queryID,omitempty"`
+	FrontendAddress string `protobuf:"bytes,3,opt,name=frontendAddress,proto3" json:"frontendAddress,omitempty"`
+}
+
+func (m *CancelActiveQueryRequest) Reset()      { *m = CancelActiveQueryRequest{} }
+func (*CancelActiveQueryRequest) ProtoMessage() {}
+func (*CancelActiveQueryRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2b3fc28395a6d9c5, []int{9}
+}
+func (m *CancelActiveQueryRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *CancelActiveQueryRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_CancelActiveQueryRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *CancelActiveQueryRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CancelActiveQueryRequest.Merge(m, src)
+}
+func (m *CancelActiveQueryRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *CancelActiveQueryRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CancelActiveQueryRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CancelActiveQueryRequest proto.InternalMessageInfo
+
+func (m *CancelActiveQueryRequest) GetUserID() string {
+	if m != nil {
+		return m.UserID
+	}
+	return ""
+}
+
+func (m *CancelActiveQueryRequest) GetQueryID() uint64 {
+	if m != nil {
+		return m.QueryID
+	}
+	return 0
+}
+
+func (m *CancelActiveQueryRequest) GetFrontendAddress() string {
+	if m != nil {
+		return m.FrontendAddress
+	}
+	return ""
+}
+
+type CancelActiveQueryResponse struct {
+	// Whether the query was found and canceled.
+	Canceled bool `protobuf:"varint,1,opt,name=canceled,proto3" json:"canceled,omitempty"`
+}
+
+func (m *CancelActiveQueryResponse) Reset()      { *m = CancelActiveQueryResponse{} }
+func (*CancelActiveQueryResponse) ProtoMessage() {}
+func (*CancelActiveQueryResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2b3fc28395a6d9c5, []int{10}
+}
+func (m *CancelActiveQueryResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *CancelActiveQueryResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_CancelActiveQueryResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *CancelActiveQueryResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CancelActiveQueryResponse.Merge(m, src)
+}
+func (m *CancelActiveQueryResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *CancelActiveQueryResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_CancelActiveQueryResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CancelActiveQueryResponse proto.InternalMessageInfo
+
+func (m *CancelActiveQueryResponse) GetCanceled() bool {
+	if m != nil {
+		return m.Canceled
+	}
+	return false
+}
+
 func init() {
 	proto.RegisterEnum("schedulerpb.FrontendToSchedulerType", FrontendToSchedulerType_name, FrontendToSchedulerType_value)
 	proto.RegisterEnum("schedulerpb.SchedulerToFrontendStatus", SchedulerToFrontendStatus_name, SchedulerToFrontendStatus_value)
@@ -450,55 +743,72 @@ func init() {
 	proto.RegisterType((*SchedulerToFrontend)(nil), "schedulerpb.SchedulerToFrontend")
 	proto.RegisterType((*NotifyQuerierShutdownRequest)(nil), "schedulerpb.NotifyQuerierShutdownRequest")
 	proto.RegisterType((*NotifyQuerierShutdownResponse)(nil), "schedulerpb.NotifyQuerierShutdownResponse")
+	proto.RegisterType((*ListActiveQueriesRequest)(nil), "schedulerpb.ListActiveQueriesRequest")
+	proto.RegisterType((*ListActiveQueriesResponse)(nil), "schedulerpb.ListActiveQueriesResponse")
+	proto.RegisterType((*ActiveQuery)(nil), "schedulerpb.ActiveQuery")
+	proto.RegisterType((*CancelActiveQueryRequest)(nil), "schedulerpb.CancelActiveQueryRequest")
+	proto.RegisterType((*CancelActiveQueryResponse)(nil), "schedulerpb.CancelActiveQueryResponse")
 }
 
 func init() { proto.RegisterFile("scheduler.proto", fileDescriptor_2b3fc28395a6d9c5) }
 
 var fileDescriptor_2b3fc28395a6d9c5 = []byte{
-	// 690 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x94, 0x4f, 0x53, 0xda, 0x40,
-	0x18, 0xc6, 0xb3, 0xfc, 0x53, 0x5e, 0xac, 0xd2, 0x55, 0x5b, 0x64, 0x6c, 0xcc, 0x30, 0x1d, 0x87,
-	0x7a, 0x00, 0x87, 0x1e, 0xda, 0x83, 0xd3, 0x19, 0xaa, 0xb1, 0x32, 0xb5, 0x41, 0x96, 0x30, 0xfd,
-	0x73, 0x61, 0x02, 0x59, 0x21, 0xa3, 0x66, 0x63, 0x36, 0x99, 0x0e, 0xb7, 0x7e, 0x84, 0x7e, 0x8c,
-	0x7e, 0x92, 0x4e, 0x8f, 0x1e, 0x3d, 0xf4, 0x50, 0xe3, 0xa5, 0x47, 0x2f, 0xbd, 0x77, 0x08, 0x81,
-	0x06, 0x0a, 0xea, 0x6d, 0xf7, 0xe5, 0x79, 0xd8, 0x7d, 0x7e, 0xef, 0x9b, 0x85, 0x25, 0xde, 0xee,
-	0x52, 0xdd, 0x3d, 0xa5, 0x76, 0xc1, 0xb2, 0x99, 0xc3, 0x70, 0x6a, 0x54, 0xb0, 0x5a, 0xd9, 0x95,
-	0x0e, 0xeb, 0x30, 0xbf, 0x5e, 0xec, 0xaf, 0x06, 0x92, 0xec, 0x76, 0xc7, 0x70, 0xba, 0x6e, 0xab,
-	0xd0, 0x66, 0x67, 0xc5, 0x8e, 0xad, 0x1d, 0x6b, 0xa6, 0x56, 0xd4, 0xf9, 0x89, 0xe1, 0x14, 0xbb,
-	0x8e, 0x63, 0x75, 0x6c, 0xab, 0x3d, 0x5a, 0x0c, 0x1c, 0xb9, 0x12, 0xe0, 0x9a, 0x4b, 0x6d, 0x83,
-	0xda, 0x2a, 0xab, 0x0f, 0xff, 0x1f, 0xaf, 0x43, 0xf2, 0x7c, 0x50, 0xad, 0xec, 0x65, 0x90, 0x84,
-	0xf2, 0x49, 0xf2, 0xaf, 0x90, 0xfb, 0x83, 0x00, 0x8f, 0xb4, 0x2a, 0x0b, 0xfc, 0x38, 0x03, 0x73,
-	0x7d, 0x4d, 0x2f, 0xb0, 0xc4, 0xc8, 0x70, 0x8b, 0x5f, 0x40, 0xaa, 0x7f, 0x2c, 0xa1, 0xe7, 0x2e,
-	0xe5, 0x4e, 0x26, 0x22, 0xa1, 0x7c, 0xaa, 0xb4, 0x5a, 0x18, 0x5d, 0xe5, 0x40, 0x55, 0x8f, 0x82,
-	0x1f, 0x49, 0x58, 0x89, 0xf3, 0xb0, 0x74, 0x6c, 0x33, 0xd3, 0xa1, 0xa6, 0x5e, 0xd6, 0x75, 0x9b,
-	0x72, 0x9e, 0x89, 0xfa, 0xb7, 0x99, 0x2c, 0xe3, 0x47, 0x90, 0x70, 0xb9, 0x7f, 0xdd, 0x98, 0x2f,
-	0x08, 0x76, 0x38, 0x07, 0x0b, 0xdc, 0xd1, 0x1c, 0x2e, 0x9b, 0x5a, 0xeb, 0x94, 0xea, 0x99, 0xb8,
-	0x84, 0xf2, 0xf3, 0x64, 0xac, 0x86, 0x37, 0x61, 0xf1, 0xdc, 0xa5, 0x2e, 0x55, 0x8d, 0x33, 0xaa,
-	0x68, 0x26, 0xe3, 0x99, 0x84, 0x84, 0xf2, 0x51, 0x32, 0x51, 0xcd, 0x7d, 0x8f, 0xc0, 0xf2, 0x7e,
-	0x70, 0x6e, 0x98, 0xd6, 0x4b, 0x88, 0x39, 0x3d, 0x8b, 0xfa, 0xa9, 0x17, 0x4b, 0x4f, 0x0b, 0xa1,
-	0x3e, 0x15, 0xa6, 0xe8, 0xd5, 0x9e, 0x45, 0x89, 0xef, 0x98, 0x96, 0x2f, 0x32, 0x3d, 0x5f, 0x08,
-	0x6e, 0x74, 0x1c, 0xee, 0xac, 0xe4, 0x13, 0xd0, 0xe3, 0xf7, 0x86, 0x3e, 0x89, 0x2c, 0x31, 0x05,
-	0xd9, 0x0e, 0xac, 0x69, 0xba, 0x6e, 0x38, 0x06, 0x33, 0xb5, 0xd3, 0x5a, 0x1f, 0xd3, 0x9e, 0x71,
-	0x46, 0x4d, 0x6e, 0x30, 0x93, 0x67, 0xe6, 0xa4, 0x68, 0x3e, 0x49, 0x66, 0x0b, 0x72, 0x27, 0xb0,
-	0x1c, 0x9a, 0x9f, 0x21, 0x22, 0xfc, 0x0a, 0x12, 0xfd, 0x43, 0x5c, 0x1e, 0x90, 0xdc, 0x1c, 0x23,
-	0x39, 0xc5, 0x51, 0xf7, 0xd5, 0x24, 0x70, 0xe1, 0x15, 0x88, 0x53, 0xdb, 0x66, 0x76, 0xc0, 0x70,
-	0xb0, 0xc9, 0xed, 0xc0, 0xba, 0xc2, 0x1c, 0xe3, 0xb8, 0x17, 0xcc, 0x69, 0xbd, 0xeb, 0x3a, 0x3a,
-	0xfb, 0x6c, 0x0e, 0xe3, 0xde, 0x3e, 0xeb, 0x1b, 0xf0, 0x64, 0x86, 0x9b, 0x5b, 0xcc, 0xe4, 0x74,
-	0x6b, 0x07, 0x1e, 0xcf, 0xe8, 0x31, 0x9e, 0x87, 0x58, 0x45, 0xa9, 0xa8, 0x69, 0x01, 0xa7, 0x60,
-	0x4e, 0x56, 0x6a, 0x0d, 0xb9, 0x21, 0xa7, 0x11, 0x06, 0x48, 0xec, 0x96, 0x95, 0x5d, 0xf9, 0x30,
-	0x1d, 0xd9, 0x6a, 0xc3, 0xda, 0xcc, 0x5c, 0x38, 0x01, 0x91, 0xea, 0xdb, 0xb4, 0x80, 0x25, 0x58,
-	0x57, 0xab, 0xd5, 0xe6, 0xbb, 0xb2, 0xf2, 0xb1, 0x49, 0xe4, 0x5a, 0x43, 0xae, 0xab, 0xf5, 0xe6,
-	0x91, 0x4c, 0x9a, 0xaa, 0xac, 0x94, 0x15, 0x35, 0x8d, 0x70, 0x12, 0xe2, 0x32, 0x21, 0x55, 0x92,
-	0x8e, 0xe0, 0x87, 0xf0, 0xa0, 0x7e, 0xd0, 0x50, 0xd5, 0x8a, 0xf2, 0xa6, 0xb9, 0x57, 0x7d, 0xaf,
-	0xa4, 0xa3, 0xa5, 0x9f, 0x28, 0xc4, 0x7b, 0x9f, 0xd9, 0xc3, 0x0f, 0xb6, 0x01, 0xa9, 0x60, 0x79,
-	0xc8, 0x98, 0x85, 0x37, 0xc6, 0x70, 0xff, 0xff, 0x2a, 0x64, 0x37, 0x66, 0xf5, 0x23, 0xd0, 0xe6,
-	0x84, 0x3c, 0xda, 0x46, 0xd8, 0x84, 0xd5, 0xa9, 0xc8, 0xf0, 0xb3, 0x31, 0xff, 0x6d, 0x4d, 0xc9,
-	0x6e, 0xdd, 0x47, 0x3a, 0xe8, 0x40, 0xc9, 0x82, 0x95, 0x70, 0xba, 0xd1, 0x38, 0x7d, 0x80, 0x85,
-	0xe1, 0xda, 0xcf, 0x27, 0xdd, 0xf5, 0x61, 0x66, 0xa5, 0xbb, 0x06, 0x6e, 0x90, 0xf0, 0x75, 0xf9,
-	0xe2, 0x4a, 0x14, 0x2e, 0xaf, 0x44, 0xe1, 0xe6, 0x4a, 0x44, 0x5f, 0x3c, 0x11, 0x7d, 0xf3, 0x44,
-	0xf4, 0xc3, 0x13, 0xd1, 0x85, 0x27, 0xa2, 0x5f, 0x9e, 0x88, 0x7e, 0x7b, 0xa2, 0x70, 0xe3, 0x89,
-	0xe8, 0xeb, 0xb5, 0x28, 0x5c, 0x5c, 0x8b, 0xc2, 0xe5, 0xb5, 0x28, 0x7c, 0x0a, 0xbf, 0xdf, 0xad,
-	0x84, 0xff, 0xfc, 0x3e, 0xff, 0x1b, 0x00, 0x00, 0xff, 0xff, 0x68, 0xe9, 0x45, 0x9c, 0xe6, 0x05,
+	// 882 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x56, 0x41, 0x6f, 0xe3, 0x44,
+	0x14, 0xce, 0x38, 0x69, 0x9a, 0xbc, 0x2c, 0xbb, 0xe9, 0x6c, 0x17, 0xdc, 0xa8, 0xb8, 0x91, 0x05,
+	0x55, 0xe8, 0x21, 0x5d, 0x99, 0xc3, 0x72, 0xa8, 0x90, 0x42, 0xeb, 0x65, 0x2b, 0x8a, 0xb3, 0x9d,
+	0x38, 0x20, 0xb8, 0x44, 0x4e, 0x3c, 0x4d, 0xac, 0x6d, 0x3d, 0xae, 0x67, 0xbc, 0x90, 0x1b, 0x7f,
+	0x00, 0x89, 0x9f, 0xc1, 0x2f, 0xe0, 0x27, 0x20, 0x8e, 0x3d, 0xae, 0x38, 0xd1, 0xf4, 0xc2, 0x71,
+	0x2f, 0xdc, 0x51, 0x6c, 0x27, 0xb5, 0x53, 0xa7, 0xe9, 0x6d, 0xe6, 0xcd, 0xf7, 0xe6, 0xcd, 0xfb,
+	0xde, 0xfb, 0x9e, 0x0d, 0x4f, 0xf8, 0x60, 0x44, 0xed, 0xe0, 0x9c, 0xfa, 0x4d, 0xcf, 0x67, 0x82,
+	0xe1, 0xca, 0xdc, 0xe0, 0xf5, 0x6b, 0x9b, 0x43, 0x36, 0x64, 0xa1, 0x7d, 0x7f, 0xba, 0x8a, 0x20,
+	0xb5, 0xe7, 0x43, 0x47, 0x8c, 0x82, 0x7e, 0x73, 0xc0, 0x2e, 0xf6, 0x87, 0xbe, 0x75, 0x66, 0xb9,
+	0xd6, 0xbe, 0xcd, 0xdf, 0x38, 0x62, 0x7f, 0x24, 0x84, 0x37, 0xf4, 0xbd, 0xc1, 0x7c, 0x11, 0x79,
+	0xa8, 0x1a, 0xe0, 0xd3, 0x80, 0xfa, 0x0e, 0xf5, 0x4d, 0xd6, 0x99, 0xdd, 0x8f, 0xb7, 0xa1, 0x7c,
+	0x19, 0x59, 0x8f, 0x8f, 0x64, 0x54, 0x47, 0x8d, 0x32, 0xb9, 0x35, 0xa8, 0xff, 0x21, 0xc0, 0x73,
+	0xac, 0xc9, 0x62, 0x7f, 0x2c, 0xc3, 0xfa, 0x14, 0x33, 0x8e, 0x5d, 0x0a, 0x64, 0xb6, 0xc5, 0x2f,
+	0xa0, 0x32, 0x0d, 0x4b, 0xe8, 0x65, 0x40, 0xb9, 0x90, 0xa5, 0x3a, 0x6a, 0x54, 0xb4, 0x67, 0xcd,
+	0xf9, 0x53, 0x5e, 0x99, 0xe6, 0xeb, 0xf8, 0x90, 0x24, 0x91, 0xb8, 0x01, 0x4f, 0xce, 0x7c, 0xe6,
+	0x0a, 0xea, 0xda, 0x2d, 0xdb, 0xf6, 0x29, 0xe7, 0x72, 0x3e, 0x7c, 0xcd, 0xa2, 0x19, 0x7f, 0x08,
+	0xc5, 0x80, 0x87, 0xcf, 0x2d, 0x84, 0x80, 0x78, 0x87, 0x55, 0x78, 0xc4, 0x85, 0x25, 0xb8, 0xee,
+	0x5a, 0xfd, 0x73, 0x6a, 0xcb, 0x6b, 0x75, 0xd4, 0x28, 0x91, 0x94, 0x0d, 0xef, 0xc2, 0xe3, 0xcb,
+	0x80, 0x06, 0xd4, 0x74, 0x2e, 0xa8, 0x61, 0xb9, 0x8c, 0xcb, 0xc5, 0x3a, 0x6a, 0xe4, 0xc9, 0x82,
+	0x55, 0xfd, 0x53, 0x82, 0xa7, 0x2f, 0xe3, 0xb8, 0x49, 0xb6, 0xbe, 0x80, 0x82, 0x18, 0x7b, 0x34,
+	0xcc, 0xfa, 0xb1, 0xf6, 0x49, 0x33, 0x51, 0xa7, 0x66, 0x06, 0xde, 0x1c, 0x7b, 0x94, 0x84, 0x1e,
+	0x59, 0xf9, 0x49, 0xd9, 0xf9, 0x25, 0xc8, 0xcd, 0xa7, 0xc9, 0x5d, 0x96, 0xf9, 0x02, 0xe9, 0x6b,
+	0x0f, 0x26, 0x7d, 0x91, 0xb2, 0x62, 0x06, 0x65, 0x07, 0xb0, 0x65, 0xd9, 0xb6, 0x23, 0x1c, 0xe6,
+	0x5a, 0xe7, 0xa7, 0x01, 0x0d, 0xe8, 0x91, 0x73, 0x41, 0x5d, 0xee, 0x30, 0x97, 0xcb, 0xeb, 0xf5,
+	0x7c, 0xa3, 0x4c, 0x96, 0x03, 0xd4, 0x37, 0xf0, 0x34, 0xd1, 0x3f, 0x33, 0x8a, 0xf0, 0x97, 0x50,
+	0x9c, 0x06, 0x09, 0x78, 0xcc, 0xe4, 0x6e, 0x8a, 0xc9, 0x0c, 0x8f, 0x4e, 0x88, 0x26, 0xb1, 0x17,
+	0xde, 0x84, 0x35, 0xea, 0xfb, 0xcc, 0x8f, 0x39, 0x8c, 0x36, 0xea, 0x01, 0x6c, 0x1b, 0x4c, 0x38,
+	0x67, 0xe3, 0xb8, 0x4f, 0x3b, 0xa3, 0x40, 0xd8, 0xec, 0x27, 0x77, 0x96, 0xee, 0xfd, 0xbd, 0xbe,
+	0x03, 0x1f, 0x2f, 0xf1, 0xe6, 0x1e, 0x73, 0x39, 0x55, 0x35, 0x90, 0x4f, 0x1c, 0x2e, 0x5a, 0x03,
+	0xe1, 0xbc, 0xa5, 0x11, 0x88, 0xcf, 0xae, 0xbe, 0x2d, 0x0d, 0x4a, 0x96, 0x46, 0x6d, 0xc3, 0x56,
+	0x86, 0x4f, 0x74, 0x21, 0xd6, 0xa2, 0x4a, 0x3b, 0x74, 0x4a, 0x43, 0xbe, 0x51, 0xd1, 0xe4, 0x14,
+	0x0d, 0xb7, 0x4e, 0x63, 0x32, 0x03, 0xaa, 0xbf, 0x4a, 0x50, 0x49, 0x1c, 0xdc, 0x23, 0xc5, 0x87,
+	0x77, 0xdc, 0xed, 0xe3, 0xf3, 0xa9, 0xbe, 0xc2, 0x50, 0xf0, 0x2c, 0x31, 0x8a, 0xbb, 0x2d, 0x5c,
+	0x4f, 0x99, 0x0f, 0x03, 0x84, 0x5d, 0x56, 0x26, 0xd1, 0x06, 0x6b, 0xb0, 0x49, 0xdd, 0xb9, 0x86,
+	0xba, 0xae, 0xf3, 0x73, 0x52, 0x5d, 0x99, 0x67, 0xe9, 0x6a, 0xac, 0x2f, 0x54, 0x23, 0x43, 0xa9,
+	0xa5, 0x4c, 0xa5, 0xbe, 0x05, 0xf9, 0xd0, 0x72, 0x07, 0xf4, 0x3c, 0xc9, 0xd6, 0xfd, 0x45, 0x49,
+	0x72, 0x26, 0xad, 0xe4, 0x2c, 0x7b, 0x0a, 0xa9, 0x2f, 0x60, 0x2b, 0x23, 0x6e, 0x5c, 0xd8, 0x1a,
+	0x94, 0x06, 0xe1, 0x21, 0xb5, 0xc3, 0xd0, 0x25, 0x32, 0xdf, 0xef, 0x1d, 0xc0, 0x47, 0x4b, 0x26,
+	0x05, 0x2e, 0x41, 0xe1, 0xd8, 0x38, 0x36, 0xab, 0x39, 0x5c, 0x81, 0x75, 0xdd, 0x38, 0xed, 0xea,
+	0x5d, 0xbd, 0x8a, 0x30, 0x40, 0xf1, 0xb0, 0x65, 0x1c, 0xea, 0x27, 0x55, 0x69, 0x6f, 0x00, 0x5b,
+	0x4b, 0xd5, 0x81, 0x8b, 0x20, 0xb5, 0xbf, 0xa9, 0xe6, 0x70, 0x1d, 0xb6, 0xcd, 0x76, 0xbb, 0xf7,
+	0x6d, 0xcb, 0xf8, 0xa1, 0x47, 0xf4, 0xd3, 0xae, 0xde, 0x31, 0x3b, 0xbd, 0xd7, 0x3a, 0xe9, 0x99,
+	0xba, 0xd1, 0x32, 0xcc, 0x2a, 0xc2, 0x65, 0x58, 0xd3, 0x09, 0x69, 0x93, 0xaa, 0x84, 0x37, 0xe0,
+	0x83, 0xce, 0xab, 0xae, 0x69, 0x1e, 0x1b, 0x5f, 0xf7, 0x8e, 0xda, 0xdf, 0x1b, 0xd5, 0xbc, 0xf6,
+	0x37, 0x4a, 0xa8, 0xf6, 0x25, 0xf3, 0x67, 0x63, 0xbf, 0x03, 0x95, 0x78, 0x79, 0xc2, 0x98, 0x87,
+	0x77, 0x52, 0xdd, 0x7a, 0xf7, 0xdb, 0x52, 0xdb, 0x59, 0xa6, 0xea, 0x18, 0xdb, 0x40, 0xcf, 0x11,
+	0x76, 0xe1, 0x59, 0xa6, 0xec, 0xf0, 0x67, 0x29, 0xef, 0xfb, 0x84, 0x5d, 0xdb, 0x7b, 0x08, 0x34,
+	0xaa, 0x8d, 0xf6, 0x87, 0x04, 0x9b, 0xc9, 0xe4, 0xe6, 0x33, 0xe9, 0x3b, 0x78, 0x34, 0x5b, 0x87,
+	0xe9, 0xd5, 0x57, 0x4d, 0xf7, 0x5a, 0x7d, 0xd5, 0xd4, 0x0a, 0x13, 0xec, 0xc3, 0xc6, 0x9d, 0x11,
+	0x80, 0x3f, 0x4d, 0xb9, 0x2e, 0x1b, 0x2b, 0xb5, 0xdd, 0x55, 0xb0, 0xb8, 0xe1, 0xfa, 0xb0, 0x71,
+	0xa7, 0x1b, 0x17, 0x62, 0x2c, 0x53, 0x49, 0x6d, 0x77, 0x15, 0x2c, 0x8a, 0xf1, 0x55, 0xeb, 0xea,
+	0x5a, 0xc9, 0xbd, 0xbb, 0x56, 0x72, 0xef, 0xaf, 0x15, 0xf4, 0xcb, 0x44, 0x41, 0xbf, 0x4f, 0x14,
+	0xf4, 0xd7, 0x44, 0x41, 0x57, 0x13, 0x05, 0xfd, 0x33, 0x51, 0xd0, 0xbf, 0x13, 0x25, 0xf7, 0x7e,
+	0xa2, 0xa0, 0xdf, 0x6e, 0x94, 0xdc, 0xd5, 0x8d, 0x92, 0x7b, 0x77, 0xa3, 0xe4, 0x7e, 0x4c, 0xfe,
+	0xca, 0xf4, 0x8b, 0xe1, 0x9f, 0xc8, 0xe7, 0xff, 0x0f, 0x00, 0xae, 0xb0, 0x95, 0x1b, 0xf1, 0x08,
 	0x00, 0x00,
 }
 
@@ -698,56 +1008,208 @@ func (this *NotifyQuerierShutdownResponse) Equal(that interface{}) bool {
 	}
 	return true
 }
-func (this *QuerierToScheduler) GoString() string {
-	if this == nil {
-		return "nil"
+func (this *ListActiveQueriesRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
 	}
-	s := make([]string, 0, 5)
-	s = append(s, "&schedulerpb.QuerierToScheduler{")
-	s = append(s, "QuerierID: "+fmt.Sprintf("%#v", this.QuerierID)+",\n")
-	s = append(s, "}")
-	return strings.Join(s, "")
-}
-func (this *SchedulerToQuerier) GoString() string {
-	if this == nil {
-		return "nil"
+
+	that1, ok := that.(*ListActiveQueriesRequest)
+	if !ok {
+		that2, ok := that.(ListActiveQueriesRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
 	}
-	s := make([]string, 0, 10)
-	s = append(s, "&schedulerpb.SchedulerToQuerier{")
-	s = append(s, "QueryID: "+fmt.Sprintf("%#v", this.QueryID)+",\n")
-	if this.HttpRequest != nil {
-		s = append(s, "HttpRequest: "+fmt.Sprintf("%#v", this.HttpRequest)+",\n")
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
 	}
-	s = append(s, "FrontendAddress: "+fmt.Sprintf("%#v", this.FrontendAddress)+",\n")
-	s = append(s, "UserID: "+fmt.Sprintf("%#v", this.UserID)+",\n")
-	s = append(s, "StatsEnabled: "+fmt.Sprintf("%#v", this.StatsEnabled)+",\n")
-	s = append(s, "QueueTimeNanos: "+fmt.Sprintf("%#v", this.QueueTimeNanos)+",\n")
-	s = append(s, "}")
-	return strings.Join(s, "")
+	if this.UserID != that1.UserID {
+		return false
+	}
+	return true
 }
-func (this *FrontendToScheduler) GoString() string {
-	if this == nil {
-		return "nil"
+func (this *ListActiveQueriesResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
 	}
-	s := make([]string, 0, 11)
-	s = append(s, "&schedulerpb.FrontendToScheduler{")
-	s = append(s, "Type: "+fmt.Sprintf("%#v", this.Type)+",\n")
-	s = append(s, "FrontendAddress: "+fmt.Sprintf("%#v", this.FrontendAddress)+",\n")
-	s = append(s, "QueryID: "+fmt.Sprintf("%#v", this.QueryID)+",\n")
-	s = append(s, "UserID: "+fmt.Sprintf("%#v", this.UserID)+",\n")
-	if this.HttpRequest != nil {
-		s = append(s, "HttpRequest: "+fmt.Sprintf("%#v", this.HttpRequest)+",\n")
+
+	that1, ok := that.(*ListActiveQueriesResponse)
+	if !ok {
+		that2, ok := that.(ListActiveQueriesResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
 	}
-	s = append(s, "StatsEnabled: "+fmt.Sprintf("%#v", this.StatsEnabled)+",\n")
-	s = append(s, "AdditionalQueueDimensions: "+fmt.Sprintf("%#v", this.AdditionalQueueDimensions)+",\n")
-	s = append(s, "}")
-	return strings.Join(s, "")
-}
-func (this *SchedulerToFrontend) GoString() string {
-	if this == nil {
-		return "nil"
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
 	}
-	s := make([]string, 0, 6)
+	if len(this.Queries) != len(that1.Queries) {
+		return false
+	}
+	for i := range this.Queries {
+		if !this.Queries[i].Equal(that1.Queries[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *ActiveQuery) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*ActiveQuery)
+	if !ok {
+		that2, ok := that.(ActiveQuery)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.QueryID != that1.QueryID {
+		return false
+	}
+	if this.FrontendAddress != that1.FrontendAddress {
+		return false
+	}
+	if this.UserID != that1.UserID {
+		return false
+	}
+	if this.Path != that1.Path {
+		return false
+	}
+	if this.Query != that1.Query {
+		return false
+	}
+	if this.EnqueueTimeUnixNanos != that1.EnqueueTimeUnixNanos {
+		return false
+	}
+	if this.QuerierID != that1.QuerierID {
+		return false
+	}
+	if this.QueueTimeNanos != that1.QueueTimeNanos {
+		return false
+	}
+	return true
+}
+func (this *CancelActiveQueryRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*CancelActiveQueryRequest)
+	if !ok {
+		that2, ok := that.(CancelActiveQueryRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.UserID != that1.UserID {
+		return false
+	}
+	if this.QueryID != that1.QueryID {
+		return false
+	}
+	if this.FrontendAddress != that1.FrontendAddress {
+		return false
+	}
+	return true
+}
+func (this *CancelActiveQueryResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*CancelActiveQueryResponse)
+	if !ok {
+		that2, ok := that.(CancelActiveQueryResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Canceled != that1.Canceled {
+		return false
+	}
+	return true
+}
+func (this *QuerierToScheduler) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&schedulerpb.QuerierToScheduler{")
+	s = append(s, "QuerierID: "+fmt.Sprintf("%#v", this.QuerierID)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *SchedulerToQuerier) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 10)
+	s = append(s, "&schedulerpb.SchedulerToQuerier{")
+	s = append(s, "QueryID: "+fmt.Sprintf("%#v", this.QueryID)+",\n")
+	if this.HttpRequest != nil {
+		s = append(s, "HttpRequest: "+fmt.Sprintf("%#v", this.HttpRequest)+",\n")
+	}
+	s = append(s, "FrontendAddress: "+fmt.Sprintf("%#v", this.FrontendAddress)+",\n")
+	s = append(s, "UserID: "+fmt.Sprintf("%#v", this.UserID)+",\n")
+	s = append(s, "StatsEnabled: "+fmt.Sprintf("%#v", this.StatsEnabled)+",\n")
+	s = append(s, "QueueTimeNanos: "+fmt.Sprintf("%#v", this.QueueTimeNanos)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *FrontendToScheduler) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 11)
+	s = append(s, "&schedulerpb.FrontendToScheduler{")
+	s = append(s, "Type: "+fmt.Sprintf("%#v", this.Type)+",\n")
+	s = append(s, "FrontendAddress: "+fmt.Sprintf("%#v", this.FrontendAddress)+",\n")
+	s = append(s, "QueryID: "+fmt.Sprintf("%#v", this.QueryID)+",\n")
+	s = append(s, "UserID: "+fmt.Sprintf("%#v", this.UserID)+",\n")
+	if this.HttpRequest != nil {
+		s = append(s, "HttpRequest: "+fmt.Sprintf("%#v", this.HttpRequest)+",\n")
+	}
+	s = append(s, "StatsEnabled: "+fmt.Sprintf("%#v", this.StatsEnabled)+",\n")
+	s = append(s, "AdditionalQueueDimensions: "+fmt.Sprintf("%#v", this.AdditionalQueueDimensions)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *SchedulerToFrontend) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
 	s = append(s, "&schedulerpb.SchedulerToFrontend{")
 	s = append(s, "Status: "+fmt.Sprintf("%#v", this.Status)+",\n")
 	s = append(s, "Error: "+fmt.Sprintf("%#v", this.Error)+",\n")
@@ -773,6 +1235,67 @@ func (this *NotifyQuerierShutdownResponse) GoString() string {
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
+func (this *ListActiveQueriesRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&schedulerpb.ListActiveQueriesRequest{")
+	s = append(s, "UserID: "+fmt.Sprintf("%#v", this.UserID)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *ListActiveQueriesResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&schedulerpb.ListActiveQueriesResponse{")
+	if this.Queries != nil {
+		s = append(s, "Queries: "+fmt.Sprintf("%#v", this.Queries)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *ActiveQuery) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 12)
+	s = append(s, "&schedulerpb.ActiveQuery{")
+	s = append(s, "QueryID: "+fmt.Sprintf("%#v", this.QueryID)+",\n")
+	s = append(s, "FrontendAddress: "+fmt.Sprintf("%#v", this.FrontendAddress)+",\n")
+	s = append(s, "UserID: "+fmt.Sprintf("%#v", this.UserID)+",\n")
+	s = append(s, "Path: "+fmt.Sprintf("%#v", this.Path)+",\n")
+	s = append(s, "Query: "+fmt.Sprintf("%#v", this.Query)+",\n")
+	s = append(s, "EnqueueTimeUnixNanos: "+fmt.Sprintf("%#v", this.EnqueueTimeUnixNanos)+",\n")
+	s = append(s, "QuerierID: "+fmt.Sprintf("%#v", this.QuerierID)+",\n")
+	s = append(s, "QueueTimeNanos: "+fmt.Sprintf("%#v", this.QueueTimeNanos)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *CancelActiveQueryRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&schedulerpb.CancelActiveQueryRequest{")
+	s = append(s, "UserID: "+fmt.Sprintf("%#v", this.UserID)+",\n")
+	s = append(s, "QueryID: "+fmt.Sprintf("%#v", this.QueryID)+",\n")
+	s = append(s, "FrontendAddress: "+fmt.Sprintf("%#v", this.FrontendAddress)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *CancelActiveQueryResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&schedulerpb.CancelActiveQueryResponse{")
+	s = append(s, "Canceled: "+fmt.Sprintf("%#v", this.Canceled)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
 func valueToGoStringScheduler(v interface{}, typ string) string {
 	rv := reflect.ValueOf(v)
 	if rv.IsNil() {
@@ -956,6 +1479,10 @@ type SchedulerForFrontendClient interface {
 	// parties... if connection breaks, frontend can cancel (and possibly retry on different scheduler) all pending
 	// requests sent to this scheduler, while scheduler can cancel queued requests from given frontend.
 	FrontendLoop(ctx context.Context, opts ...grpc.CallOption) (SchedulerForFrontend_FrontendLoopClient, error)
+	// Returns the queries of the given tenant that are currently queued or being executed by a querier.
+	ListActiveQueries(ctx context.Context, in *ListActiveQueriesRequest, opts ...grpc.CallOption) (*ListActiveQueriesResponse, error)
+	// Cancels a query that is currently queued or being executed by a querier.
+	CancelActiveQuery(ctx context.Context, in *CancelActiveQueryRequest, opts ...grpc.CallOption) (*CancelActiveQueryResponse, error)
 }
 
 type schedulerForFrontendClient struct {
@@ -997,6 +1524,24 @@ func (x *schedulerForFrontendFrontendLoopClient) Recv() (*SchedulerToFrontend, e
 	return m, nil
 }
 
+func (c *schedulerForFrontendClient) ListActiveQueries(ctx context.Context, in *ListActiveQueriesRequest, opts ...grpc.CallOption) (*ListActiveQueriesResponse, error) {
+	out := new(ListActiveQueriesResponse)
+	err := c.cc.Invoke(ctx, "/schedulerpb.SchedulerForFrontend/ListActiveQueries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *schedulerForFrontendClient) CancelActiveQuery(ctx context.Context, in *CancelActiveQueryRequest, opts ...grpc.CallOption) (*CancelActiveQueryResponse, error) {
+	out := new(CancelActiveQueryResponse)
+	err := c.cc.Invoke(ctx, "/schedulerpb.SchedulerForFrontend/CancelActiveQuery", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SchedulerForFrontendServer is the server API for SchedulerForFrontend service.
 type SchedulerForFrontendServer interface {
 	// After calling this method, both Frontend and Scheduler enter a loop. Frontend will keep sending ENQUEUE and
@@ -1006,6 +1551,10 @@ type SchedulerForFrontendServer interface {
 	// parties... if connection breaks, frontend can cancel (and possibly retry on different scheduler) all pending
 	// requests sent to this scheduler, while scheduler can cancel queued requests from given frontend.
 	FrontendLoop(SchedulerForFrontend_FrontendLoopServer) error
+	// Returns the queries of the given tenant that are currently queued or being executed by a querier.
+	ListActiveQueries(context.Context, *ListActiveQueriesRequest) (*ListActiveQueriesResponse, error)
+	// Cancels a query that is currently queued or being executed by a querier.
+	CancelActiveQuery(context.Context, *CancelActiveQueryRequest) (*CancelActiveQueryResponse, error)
 }
 
 // UnimplementedSchedulerForFrontendServer can be embedded to have forward compatible implementations.
@@ -1015,6 +1564,12 @@ type UnimplementedSchedulerForFrontendServer struct {
 func (*UnimplementedSchedulerForFrontendServer) FrontendLoop(srv SchedulerForFrontend_FrontendLoopServer) error {
 	return status.Errorf(codes.Unimplemented, "method FrontendLoop not implemented")
 }
+func (*UnimplementedSchedulerForFrontendServer) ListActiveQueries(ctx context.Context, req *ListActiveQueriesRequest) (*ListActiveQueriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListActiveQueries not implemented")
+}
+func (*UnimplementedSchedulerForFrontendServer) CancelActiveQuery(ctx context.Context, req *CancelActiveQueryRequest) (*CancelActiveQueryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelActiveQuery not implemented")
+}
 
 func RegisterSchedulerForFrontendServer(s *grpc.Server, srv SchedulerForFrontendServer) {
 	s.RegisterService(&_SchedulerForFrontend_serviceDesc, srv)
@@ -1046,10 +1601,55 @@ func (x *schedulerForFrontendFrontendLoopServer) Recv() (*FrontendToScheduler, e
 	return m, nil
 }
 
+func _SchedulerForFrontend_ListActiveQueries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListActiveQueriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SchedulerForFrontendServer).ListActiveQueries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/schedulerpb.SchedulerForFrontend/ListActiveQueries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SchedulerForFrontendServer).ListActiveQueries(ctx, req.(*ListActiveQueriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SchedulerForFrontend_CancelActiveQuery_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelActiveQueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SchedulerForFrontendServer).CancelActiveQuery(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/schedulerpb.SchedulerForFrontend/CancelActiveQuery",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SchedulerForFrontendServer).CancelActiveQuery(ctx, req.(*CancelActiveQueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _SchedulerForFrontend_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "schedulerpb.SchedulerForFrontend",
 	HandlerType: (*SchedulerForFrontendServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListActiveQueries",
+			Handler:    _SchedulerForFrontend_ListActiveQueries_Handler,
+		},
+		{
+			MethodName: "CancelActiveQuery",
+			Handler:    _SchedulerForFrontend_CancelActiveQuery_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "FrontendLoop",
@@ -1326,26 +1926,241 @@ func (m *NotifyQuerierShutdownResponse) MarshalToSizedBuffer(dAtA []byte) (int,
 	return len(dAtA) - i, nil
 }
 
-func encodeVarintScheduler(dAtA []byte, offset int, v uint64) int {
-	offset -= sovScheduler(v)
-	base := offset
-	for v >= 1<<7 {
-		dAtA[offset] = uint8(v&0x7f | 0x80)
-		v >>= 7
-		offset++
+func (m *ListActiveQueriesRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
-	dAtA[offset] = uint8(v)
-	return base
+	return dAtA[:n], nil
 }
-func (m *QuerierToScheduler) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	l = len(m.QuerierID)
-	if l > 0 {
-		n += 1 + l + sovScheduler(uint64(l))
+
+func (m *ListActiveQueriesRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ListActiveQueriesRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.UserID) > 0 {
+		i -= len(m.UserID)
+		copy(dAtA[i:], m.UserID)
+		i = encodeVarintScheduler(dAtA, i, uint64(len(m.UserID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *ListActiveQueriesResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ListActiveQueriesResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ListActiveQueriesResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Queries) > 0 {
+		for iNdEx := len(m.Queries) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Queries[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintScheduler(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *ActiveQuery) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ActiveQuery) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ActiveQuery) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.QueueTimeNanos != 0 {
+		i = encodeVarintScheduler(dAtA, i, uint64(m.QueueTimeNanos))
+		i--
+		dAtA[i] = 0x40
+	}
+	if len(m.QuerierID) > 0 {
+		i -= len(m.QuerierID)
+		copy(dAtA[i:], m.QuerierID)
+		i = encodeVarintScheduler(dAtA, i, uint64(len(m.QuerierID)))
+		i--
+		dAtA[i] = 0x3a
+	}
+	if m.EnqueueTimeUnixNanos != 0 {
+		i = encodeVarintScheduler(dAtA, i, uint64(m.EnqueueTimeUnixNanos))
+		i--
+		dAtA[i] = 0x30
+	}
+	if len(m.Query) > 0 {
+		i -= len(m.Query)
+		copy(dAtA[i:], m.Query)
+		i = encodeVarintScheduler(dAtA, i, uint64(len(m.Query)))
+		i--
+		dAtA[i] = 0x2a
+	}
+	if len(m.Path) > 0 {
+		i -= len(m.Path)
+		copy(dAtA[i:], m.Path)
+		i = encodeVarintScheduler(dAtA, i, uint64(len(m.Path)))
+		i--
+		dAtA[i] = 0x22
+	}
+	if len(m.UserID) > 0 {
+		i -= len(m.UserID)
+		copy(dAtA[i:], m.UserID)
+		i = encodeVarintScheduler(dAtA, i, uint64(len(m.UserID)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.FrontendAddress) > 0 {
+		i -= len(m.FrontendAddress)
+		copy(dAtA[i:], m.FrontendAddress)
+		i = encodeVarintScheduler(dAtA, i, uint64(len(m.FrontendAddress)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.QueryID != 0 {
+		i = encodeVarintScheduler(dAtA, i, uint64(m.QueryID))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *CancelActiveQueryRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *CancelActiveQueryRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *CancelActiveQueryRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.FrontendAddress) > 0 {
+		i -= len(m.FrontendAddress)
+		copy(dAtA[i:], m.FrontendAddress)
+		i = encodeVarintScheduler(dAtA, i, uint64(len(m.FrontendAddress)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if m.QueryID != 0 {
+		i = encodeVarintScheduler(dAtA, i, uint64(m.QueryID))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.UserID) > 0 {
+		i -= len(m.UserID)
+		copy(dAtA[i:], m.UserID)
+		i = encodeVarintScheduler(dAtA, i, uint64(len(m.UserID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *CancelActiveQueryResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *CancelActiveQueryResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *CancelActiveQueryResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Canceled {
+		i--
+		if m.Canceled {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintScheduler(dAtA []byte, offset int, v uint64) int {
+	offset -= sovScheduler(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *QuerierToScheduler) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.QuerierID)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
 	}
 	return n
 }
@@ -1454,58 +2269,156 @@ func (m *NotifyQuerierShutdownResponse) Size() (n int) {
 	return n
 }
 
-func sovScheduler(x uint64) (n int) {
-	return (math_bits.Len64(x|1) + 6) / 7
-}
-func sozScheduler(x uint64) (n int) {
-	return sovScheduler(uint64((x << 1) ^ uint64((int64(x) >> 63))))
-}
-func (this *QuerierToScheduler) String() string {
-	if this == nil {
-		return "nil"
+func (m *ListActiveQueriesRequest) Size() (n int) {
+	if m == nil {
+		return 0
 	}
-	s := strings.Join([]string{`&QuerierToScheduler{`,
-		`QuerierID:` + fmt.Sprintf("%v", this.QuerierID) + `,`,
-		`}`,
-	}, "")
-	return s
-}
-func (this *SchedulerToQuerier) String() string {
-	if this == nil {
-		return "nil"
+	var l int
+	_ = l
+	l = len(m.UserID)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
 	}
-	s := strings.Join([]string{`&SchedulerToQuerier{`,
-		`QueryID:` + fmt.Sprintf("%v", this.QueryID) + `,`,
-		`HttpRequest:` + strings.Replace(fmt.Sprintf("%v", this.HttpRequest), "HTTPRequest", "httpgrpc.HTTPRequest", 1) + `,`,
-		`FrontendAddress:` + fmt.Sprintf("%v", this.FrontendAddress) + `,`,
-		`UserID:` + fmt.Sprintf("%v", this.UserID) + `,`,
-		`StatsEnabled:` + fmt.Sprintf("%v", this.StatsEnabled) + `,`,
-		`QueueTimeNanos:` + fmt.Sprintf("%v", this.QueueTimeNanos) + `,`,
-		`}`,
-	}, "")
-	return s
+	return n
 }
-func (this *FrontendToScheduler) String() string {
-	if this == nil {
-		return "nil"
+
+func (m *ListActiveQueriesResponse) Size() (n int) {
+	if m == nil {
+		return 0
 	}
-	s := strings.Join([]string{`&FrontendToScheduler{`,
-		`Type:` + fmt.Sprintf("%v", this.Type) + `,`,
-		`FrontendAddress:` + fmt.Sprintf("%v", this.FrontendAddress) + `,`,
-		`QueryID:` + fmt.Sprintf("%v", this.QueryID) + `,`,
-		`UserID:` + fmt.Sprintf("%v", this.UserID) + `,`,
-		`HttpRequest:` + strings.Replace(fmt.Sprintf("%v", this.HttpRequest), "HTTPRequest", "httpgrpc.HTTPRequest", 1) + `,`,
-		`StatsEnabled:` + fmt.Sprintf("%v", this.StatsEnabled) + `,`,
-		`AdditionalQueueDimensions:` + fmt.Sprintf("%v", this.AdditionalQueueDimensions) + `,`,
-		`}`,
-	}, "")
-	return s
+	var l int
+	_ = l
+	if len(m.Queries) > 0 {
+		for _, e := range m.Queries {
+			l = e.Size()
+			n += 1 + l + sovScheduler(uint64(l))
+		}
+	}
+	return n
 }
-func (this *SchedulerToFrontend) String() string {
-	if this == nil {
-		return "nil"
+
+func (m *ActiveQuery) Size() (n int) {
+	if m == nil {
+		return 0
 	}
-	s := strings.Join([]string{`&SchedulerToFrontend{`,
+	var l int
+	_ = l
+	if m.QueryID != 0 {
+		n += 1 + sovScheduler(uint64(m.QueryID))
+	}
+	l = len(m.FrontendAddress)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
+	}
+	l = len(m.UserID)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
+	}
+	l = len(m.Path)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
+	}
+	l = len(m.Query)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
+	}
+	if m.EnqueueTimeUnixNanos != 0 {
+		n += 1 + sovScheduler(uint64(m.EnqueueTimeUnixNanos))
+	}
+	l = len(m.QuerierID)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
+	}
+	if m.QueueTimeNanos != 0 {
+		n += 1 + sovScheduler(uint64(m.QueueTimeNanos))
+	}
+	return n
+}
+
+func (m *CancelActiveQueryRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.UserID)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
+	}
+	if m.QueryID != 0 {
+		n += 1 + sovScheduler(uint64(m.QueryID))
+	}
+	l = len(m.FrontendAddress)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
+	}
+	return n
+}
+
+func (m *CancelActiveQueryResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Canceled {
+		n += 2
+	}
+	return n
+}
+
+func sovScheduler(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozScheduler(x uint64) (n int) {
+	return sovScheduler(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (this *QuerierToScheduler) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&QuerierToScheduler{`,
+		`QuerierID:` + fmt.Sprintf("%v", this.QuerierID) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *SchedulerToQuerier) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&SchedulerToQuerier{`,
+		`QueryID:` + fmt.Sprintf("%v", this.QueryID) + `,`,
+		`HttpRequest:` + strings.Replace(fmt.Sprintf("%v", this.HttpRequest), "HTTPRequest", "httpgrpc.HTTPRequest", 1) + `,`,
+		`FrontendAddress:` + fmt.Sprintf("%v", this.FrontendAddress) + `,`,
+		`UserID:` + fmt.Sprintf("%v", this.UserID) + `,`,
+		`StatsEnabled:` + fmt.Sprintf("%v", this.StatsEnabled) + `,`,
+		`QueueTimeNanos:` + fmt.Sprintf("%v", this.QueueTimeNanos) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *FrontendToScheduler) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&FrontendToScheduler{`,
+		`Type:` + fmt.Sprintf("%v", this.Type) + `,`,
+		`FrontendAddress:` + fmt.Sprintf("%v", this.FrontendAddress) + `,`,
+		`QueryID:` + fmt.Sprintf("%v", this.QueryID) + `,`,
+		`UserID:` + fmt.Sprintf("%v", this.UserID) + `,`,
+		`HttpRequest:` + strings.Replace(fmt.Sprintf("%v", this.HttpRequest), "HTTPRequest", "httpgrpc.HTTPRequest", 1) + `,`,
+		`StatsEnabled:` + fmt.Sprintf("%v", this.StatsEnabled) + `,`,
+		`AdditionalQueueDimensions:` + fmt.Sprintf("%v", this.AdditionalQueueDimensions) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *SchedulerToFrontend) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&SchedulerToFrontend{`,
 		`Status:` + fmt.Sprintf("%v", this.Status) + `,`,
 		`Error:` + fmt.Sprintf("%v", this.Error) + `,`,
 		`}`,
@@ -1526,20 +2439,623 @@ func (this *NotifyQuerierShutdownResponse) String() string {
 	if this == nil {
 		return "nil"
 	}
-	s := strings.Join([]string{`&NotifyQuerierShutdownResponse{`,
-		`}`,
-	}, "")
-	return s
-}
-func valueToStringScheduler(v interface{}) string {
-	rv := reflect.ValueOf(v)
-	if rv.IsNil() {
-		return "nil"
+	s := strings.Join([]string{`&NotifyQuerierShutdownResponse{`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ListActiveQueriesRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ListActiveQueriesRequest{`,
+		`UserID:` + fmt.Sprintf("%v", this.UserID) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ListActiveQueriesResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForQueries := "[]*ActiveQuery{"
+	for _, f := range this.Queries {
+		repeatedStringForQueries += strings.Replace(f.String(), "ActiveQuery", "ActiveQuery", 1) + ","
+	}
+	repeatedStringForQueries += "}"
+	s := strings.Join([]string{`&ListActiveQueriesResponse{`,
+		`Queries:` + repeatedStringForQueries + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ActiveQuery) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ActiveQuery{`,
+		`QueryID:` + fmt.Sprintf("%v", this.QueryID) + `,`,
+		`FrontendAddress:` + fmt.Sprintf("%v", this.FrontendAddress) + `,`,
+		`UserID:` + fmt.Sprintf("%v", this.UserID) + `,`,
+		`Path:` + fmt.Sprintf("%v", this.Path) + `,`,
+		`Query:` + fmt.Sprintf("%v", this.Query) + `,`,
+		`EnqueueTimeUnixNanos:` + fmt.Sprintf("%v", this.EnqueueTimeUnixNanos) + `,`,
+		`QuerierID:` + fmt.Sprintf("%v", this.QuerierID) + `,`,
+		`QueueTimeNanos:` + fmt.Sprintf("%v", this.QueueTimeNanos) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *CancelActiveQueryRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&CancelActiveQueryRequest{`,
+		`UserID:` + fmt.Sprintf("%v", this.UserID) + `,`,
+		`QueryID:` + fmt.Sprintf("%v", this.QueryID) + `,`,
+		`FrontendAddress:` + fmt.Sprintf("%v", this.FrontendAddress) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *CancelActiveQueryResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&CancelActiveQueryResponse{`,
+		`Canceled:` + fmt.Sprintf("%v", this.Canceled) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func valueToStringScheduler(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("*%v", pv)
+}
+func (m *QuerierToScheduler) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowScheduler
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: QuerierToScheduler: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: QuerierToScheduler: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QuerierID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.QuerierID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipScheduler(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SchedulerToQuerier) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowScheduler
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SchedulerToQuerier: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SchedulerToQuerier: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QueryID", wireType)
+			}
+			m.QueryID = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.QueryID |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field HttpRequest", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.HttpRequest == nil {
+				m.HttpRequest = &httpgrpc.HTTPRequest{}
+			}
+			if err := m.HttpRequest.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FrontendAddress", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.FrontendAddress = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field UserID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.UserID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StatsEnabled", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.StatsEnabled = bool(v != 0)
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QueueTimeNanos", wireType)
+			}
+			m.QueueTimeNanos = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.QueueTimeNanos |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipScheduler(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowScheduler
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: FrontendToScheduler: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: FrontendToScheduler: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Type", wireType)
+			}
+			m.Type = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Type |= FrontendToSchedulerType(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FrontendAddress", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.FrontendAddress = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QueryID", wireType)
+			}
+			m.QueryID = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.QueryID |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field UserID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.UserID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field HttpRequest", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.HttpRequest == nil {
+				m.HttpRequest = &httpgrpc.HTTPRequest{}
+			}
+			if err := m.HttpRequest.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StatsEnabled", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.StatsEnabled = bool(v != 0)
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AdditionalQueueDimensions", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.AdditionalQueueDimensions = append(m.AdditionalQueueDimensions, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipScheduler(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
 	}
-	pv := reflect.Indirect(rv).Interface()
-	return fmt.Sprintf("*%v", pv)
+	return nil
 }
-func (m *QuerierToScheduler) Unmarshal(dAtA []byte) error {
+func (m *SchedulerToFrontend) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1562,15 +3078,34 @@ func (m *QuerierToScheduler) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: QuerierToScheduler: wiretype end group for non-group")
+			return fmt.Errorf("proto: SchedulerToFrontend: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: QuerierToScheduler: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: SchedulerToFrontend: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Status", wireType)
+			}
+			m.Status = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Status |= SchedulerToFrontendStatus(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field QuerierID", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Error", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -1598,7 +3133,7 @@ func (m *QuerierToScheduler) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.QuerierID = string(dAtA[iNdEx:postIndex])
+			m.Error = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -1624,7 +3159,7 @@ func (m *QuerierToScheduler) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SchedulerToQuerier) Unmarshal(dAtA []byte) error {
+func (m *NotifyQuerierShutdownRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1647,36 +3182,17 @@ func (m *SchedulerToQuerier) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SchedulerToQuerier: wiretype end group for non-group")
+			return fmt.Errorf("proto: NotifyQuerierShutdownRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SchedulerToQuerier: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: NotifyQuerierShutdownRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field QueryID", wireType)
-			}
-			m.QueryID = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowScheduler
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.QueryID |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field HttpRequest", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field QuerierID", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowScheduler
@@ -1686,31 +3202,133 @@ func (m *SchedulerToQuerier) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthScheduler
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthScheduler
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.HttpRequest == nil {
-				m.HttpRequest = &httpgrpc.HTTPRequest{}
+			m.QuerierID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipScheduler(dAtA[iNdEx:])
+			if err != nil {
+				return err
 			}
-			if err := m.HttpRequest.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			if skippy < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *NotifyQuerierShutdownResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowScheduler
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: NotifyQuerierShutdownResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: NotifyQuerierShutdownResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipScheduler(dAtA[iNdEx:])
+			if err != nil {
 				return err
 			}
-			iNdEx = postIndex
-		case 3:
+			if skippy < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ListActiveQueriesRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowScheduler
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ListActiveQueriesRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ListActiveQueriesRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field FrontendAddress", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field UserID", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -1738,13 +3356,66 @@ func (m *SchedulerToQuerier) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.FrontendAddress = string(dAtA[iNdEx:postIndex])
+			m.UserID = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 4:
+		default:
+			iNdEx = preIndex
+			skippy, err := skipScheduler(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ListActiveQueriesResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowScheduler
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ListActiveQueriesResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ListActiveQueriesResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field UserID", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Queries", wireType)
 			}
-			var stringLen uint64
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowScheduler
@@ -1754,63 +3425,26 @@ func (m *SchedulerToQuerier) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthScheduler
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + msglen
 			if postIndex < 0 {
 				return ErrInvalidLengthScheduler
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.UserID = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 5:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field StatsEnabled", wireType)
-			}
-			var v int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowScheduler
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				v |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			m.StatsEnabled = bool(v != 0)
-		case 6:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field QueueTimeNanos", wireType)
-			}
-			m.QueueTimeNanos = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowScheduler
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.QueueTimeNanos |= int64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+			m.Queries = append(m.Queries, &ActiveQuery{})
+			if err := m.Queries[len(m.Queries)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
 			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipScheduler(dAtA[iNdEx:])
@@ -1835,7 +3469,7 @@ func (m *SchedulerToQuerier) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
+func (m *ActiveQuery) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1858,17 +3492,17 @@ func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: FrontendToScheduler: wiretype end group for non-group")
+			return fmt.Errorf("proto: ActiveQuery: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: FrontendToScheduler: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: ActiveQuery: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Type", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field QueryID", wireType)
 			}
-			m.Type = 0
+			m.QueryID = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowScheduler
@@ -1878,7 +3512,7 @@ func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Type |= FrontendToSchedulerType(b&0x7F) << shift
+				m.QueryID |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
@@ -1916,10 +3550,10 @@ func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
 			m.FrontendAddress = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field QueryID", wireType)
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field UserID", wireType)
 			}
-			m.QueryID = 0
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowScheduler
@@ -1929,14 +3563,27 @@ func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.QueryID |= uint64(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.UserID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		case 4:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field UserID", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Path", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -1964,13 +3611,13 @@ func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.UserID = string(dAtA[iNdEx:postIndex])
+			m.Path = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 5:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field HttpRequest", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Query", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowScheduler
@@ -1980,33 +3627,29 @@ func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthScheduler
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthScheduler
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.HttpRequest == nil {
-				m.HttpRequest = &httpgrpc.HTTPRequest{}
-			}
-			if err := m.HttpRequest.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.Query = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 6:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field StatsEnabled", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field EnqueueTimeUnixNanos", wireType)
 			}
-			var v int
+			m.EnqueueTimeUnixNanos = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowScheduler
@@ -2016,15 +3659,14 @@ func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				v |= int(b&0x7F) << shift
+				m.EnqueueTimeUnixNanos |= int64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			m.StatsEnabled = bool(v != 0)
 		case 7:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field AdditionalQueueDimensions", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field QuerierID", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -2052,8 +3694,27 @@ func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.AdditionalQueueDimensions = append(m.AdditionalQueueDimensions, string(dAtA[iNdEx:postIndex]))
+			m.QuerierID = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QueueTimeNanos", wireType)
+			}
+			m.QueueTimeNanos = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.QueueTimeNanos |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipScheduler(dAtA[iNdEx:])
@@ -2078,7 +3739,7 @@ func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SchedulerToFrontend) Unmarshal(dAtA []byte) error {
+func (m *CancelActiveQueryRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2101,34 +3762,15 @@ func (m *SchedulerToFrontend) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SchedulerToFrontend: wiretype end group for non-group")
+			return fmt.Errorf("proto: CancelActiveQueryRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SchedulerToFrontend: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: CancelActiveQueryRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Status", wireType)
-			}
-			m.Status = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowScheduler
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Status |= SchedulerToFrontendStatus(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Error", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field UserID", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -2156,64 +3798,30 @@ func (m *SchedulerToFrontend) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Error = string(dAtA[iNdEx:postIndex])
+			m.UserID = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipScheduler(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthScheduler
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthScheduler
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *NotifyQuerierShutdownRequest) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowScheduler
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QueryID", wireType)
 			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
+			m.QueryID = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.QueryID |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: NotifyQuerierShutdownRequest: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: NotifyQuerierShutdownRequest: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+		case 3:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field QuerierID", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field FrontendAddress", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -2241,7 +3849,7 @@ func (m *NotifyQuerierShutdownRequest) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.QuerierID = string(dAtA[iNdEx:postIndex])
+			m.FrontendAddress = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -2267,7 +3875,7 @@ func (m *NotifyQuerierShutdownRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *NotifyQuerierShutdownResponse) Unmarshal(dAtA []byte) error {
+func (m *CancelActiveQueryResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2290,12 +3898,32 @@ func (m *NotifyQuerierShutdownResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: NotifyQuerierShutdownResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: CancelActiveQueryResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: NotifyQuerierShutdownResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: CancelActiveQueryResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Canceled", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Canceled = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipScheduler(dAtA[iNdEx:])
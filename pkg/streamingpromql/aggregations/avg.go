@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/engine.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package aggregations
+
+import (
+	"math"
+
+	"github.com/prometheus/prometheus/model/histogram"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+type AvgAggregationGroup struct {
+	floatMeans   []float64
+	floatCounts  []float64
+	floatPresent []bool
+
+	histogramMeans      []*histogram.FloatHistogram
+	histogramCounts     []float64
+	histogramPointCount int
+}
+
+func (g *AvgAggregationGroup) AccumulateSeries(data types.InstantVectorSeriesData, steps int, start int64, interval int64, pool *pooling.LimitingPool) error {
+	if len(data.Floats) > 0 {
+		if err := g.accumulateFloats(data, steps, start, interval, pool); err != nil {
+			return err
+		}
+	}
+
+	if len(data.Histograms) > 0 {
+		if err := g.accumulateHistograms(data, steps, start, interval, pool); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *AvgAggregationGroup) accumulateFloats(data types.InstantVectorSeriesData, steps int, start int64, interval int64, pool *pooling.LimitingPool) error {
+	var err error
+	if g.floatMeans == nil {
+		// First series with float values for this group, populate it.
+		if g.floatMeans, err = pool.GetFloatSlice(steps); err != nil {
+			return err
+		}
+
+		if g.floatCounts, err = pool.GetFloatSlice(steps); err != nil {
+			return err
+		}
+
+		if g.floatPresent, err = pool.GetBoolSlice(steps); err != nil {
+			return err
+		}
+
+		g.floatMeans = g.floatMeans[:steps]
+		g.floatCounts = g.floatCounts[:steps]
+		g.floatPresent = g.floatPresent[:steps]
+	}
+
+	for _, p := range data.Floats {
+		idx := (p.T - start) / interval
+		g.floatCounts[idx]++
+
+		if !g.floatPresent[idx] {
+			// First point for this step, nothing to average yet.
+			g.floatMeans[idx] = p.F
+			g.floatPresent[idx] = true
+			continue
+		}
+
+		if math.IsInf(g.floatMeans[idx], 0) {
+			if math.IsInf(p.F, 0) && (g.floatMeans[idx] > 0) == (p.F > 0) {
+				// The mean and the new value are Inf of the same sign. They can't be subtracted,
+				// but the value of the mean is correct already.
+				continue
+			}
+
+			if !math.IsInf(p.F, 0) && !math.IsNaN(p.F) {
+				// At this stage, the mean is an infinite. If the added value is neither an Inf or a NaN,
+				// we can keep that mean value.
+				// This is required because our calculation below removes the mean value, which would
+				// look like Inf += x - Inf and end up as a NaN.
+				continue
+			}
+		}
+
+		// Divide each side of the `-` by the count to avoid float64 overflows.
+		g.floatMeans[idx] += p.F/g.floatCounts[idx] - g.floatMeans[idx]/g.floatCounts[idx]
+	}
+
+	return nil
+}
+
+func (g *AvgAggregationGroup) accumulateHistograms(data types.InstantVectorSeriesData, steps int, start int64, interval int64, pool *pooling.LimitingPool) error {
+	var err error
+	if g.histogramMeans == nil {
+		// First series with histogram values for this group, populate it.
+		if g.histogramMeans, err = pool.GetHistogramPointerSlice(steps); err != nil {
+			return err
+		}
+
+		if g.histogramCounts, err = pool.GetFloatSlice(steps); err != nil {
+			return err
+		}
+
+		g.histogramMeans = g.histogramMeans[:steps]
+		g.histogramCounts = g.histogramCounts[:steps]
+	}
+
+	for _, p := range data.Histograms {
+		idx := (p.T - start) / interval
+		g.histogramCounts[idx]++
+
+		if g.histogramMeans[idx] == nil {
+			// We copy here because we modify the histogram later on.
+			// It is necessary to preserve the original Histogram in case of any range-queries using lookback.
+			g.histogramMeans[idx] = p.H.Copy()
+			g.histogramPointCount++
+			continue
+		}
+
+		left := p.H.Copy().Div(g.histogramCounts[idx])
+		right := g.histogramMeans[idx].Copy().Div(g.histogramCounts[idx])
+
+		toAdd, err := left.Sub(right)
+		if err != nil {
+			return err
+		}
+
+		if _, err := g.histogramMeans[idx].Add(toAdd); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *AvgAggregationGroup) ComputeOutputSeries(start int64, interval int64, pool *pooling.LimitingPool) (types.InstantVectorSeriesData, error) {
+	floatPointCount := g.reconcileAndCountFloatPoints()
+	floatPoints, err := floatPointsFromValues(g.floatMeans, g.floatPresent, floatPointCount, start, interval, pool)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	histogramPoints, err := histogramPointsFromValues(g.histogramMeans, g.histogramPointCount, start, interval, pool)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	pool.PutFloatSlice(g.floatMeans)
+	pool.PutFloatSlice(g.floatCounts)
+	pool.PutBoolSlice(g.floatPresent)
+	pool.PutHistogramPointerSlice(g.histogramMeans)
+	pool.PutFloatSlice(g.histogramCounts)
+
+	return types.InstantVectorSeriesData{Floats: floatPoints, Histograms: histogramPoints}, nil
+}
+
+// reconcileAndCountFloatPoints returns the number of steps with a float average, and removes any steps that have
+// both float and histogram samples, as the average of a mix of floats and histograms is undefined.
+func (g *AvgAggregationGroup) reconcileAndCountFloatPoints() int {
+	floatPointCount := 0
+
+	for idx, present := range g.floatPresent {
+		if !present {
+			continue
+		}
+
+		if len(g.histogramMeans) > 0 && g.histogramMeans[idx] != nil {
+			g.floatPresent[idx] = false
+			g.histogramMeans[idx] = nil
+			g.histogramPointCount--
+			continue
+		}
+
+		floatPointCount++
+	}
+
+	return floatPointCount
+}
@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package aggregations
+
+import (
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// AggregationGroup accumulates series that have been grouped together and computes the output series data.
+type AggregationGroup interface {
+	// AccumulateSeries takes in a series as part of the group.
+	// AccumulateSeries must not retain references to data, as the caller will return it to the pool once
+	// AccumulateSeries returns.
+	AccumulateSeries(data types.InstantVectorSeriesData, steps int, start int64, interval int64, pool *pooling.LimitingPool) error
+
+	// ComputeOutputSeries does any final calculations and returns the grouped series data.
+	// ComputeOutputSeries must release all resources held by the group, and the group must not be used after
+	// ComputeOutputSeries is called.
+	ComputeOutputSeries(start int64, interval int64, pool *pooling.LimitingPool) (types.InstantVectorSeriesData, error)
+}
+
+type AggregationGroupFactory func() AggregationGroup
+
+var AggregationGroupFactories = map[parser.ItemType]AggregationGroupFactory{
+	parser.AVG:    func() AggregationGroup { return &AvgAggregationGroup{} },
+	parser.COUNT:  func() AggregationGroup { return NewCountGroupAggregationGroup(true) },
+	parser.GROUP:  func() AggregationGroup { return NewCountGroupAggregationGroup(false) },
+	parser.MAX:    func() AggregationGroup { return NewMinMaxAggregationGroup(true) },
+	parser.MIN:    func() AggregationGroup { return NewMinMaxAggregationGroup(false) },
+	parser.STDDEV: func() AggregationGroup { return NewStddevStdvarAggregationGroup(true) },
+	parser.STDVAR: func() AggregationGroup { return NewStddevStdvarAggregationGroup(false) },
+	parser.SUM:    func() AggregationGroup { return &SumAggregationGroup{} },
+}
+
+// floatPointsFromValues returns a slice of points containing each value in values where the corresponding
+// element of present is true.
+func floatPointsFromValues(values []float64, present []bool, pointCount int, start int64, interval int64, pool *pooling.LimitingPool) ([]promql.FPoint, error) {
+	if pointCount == 0 {
+		return nil, nil
+	}
+
+	points, err := pool.GetFPointSlice(pointCount)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, havePoint := range present {
+		if havePoint {
+			t := start + int64(i)*interval
+			points = append(points, promql.FPoint{T: t, F: values[i]})
+		}
+	}
+
+	return points, nil
+}
+
+// histogramPointsFromValues returns a slice of points containing each non-nil histogram in histograms.
+func histogramPointsFromValues(histograms []*histogram.FloatHistogram, pointCount int, start int64, interval int64, pool *pooling.LimitingPool) ([]promql.HPoint, error) {
+	if pointCount == 0 {
+		return nil, nil
+	}
+
+	points, err := pool.GetHPointSlice(pointCount)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, h := range histograms {
+		if h != nil {
+			t := start + int64(i)*interval
+			points = append(points, promql.HPoint{T: t, H: h.Compact(0)})
+		}
+	}
+
+	return points, nil
+}
@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/engine.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package aggregations
+
+import (
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// CountGroupAggregationGroup implements both the count and group aggregations, as they only differ in the
+// value produced for each step.
+type CountGroupAggregationGroup struct {
+	values []float64
+
+	accumulatePoint func(idx int64)
+}
+
+// NewCountGroupAggregationGroup creates an aggregation group for the count aggregation if count is true, or group otherwise.
+func NewCountGroupAggregationGroup(count bool) *CountGroupAggregationGroup {
+	g := &CountGroupAggregationGroup{}
+	if count {
+		g.accumulatePoint = g.countAccumulatePoint
+	} else {
+		g.accumulatePoint = g.groupAccumulatePoint
+	}
+	return g
+}
+
+func (g *CountGroupAggregationGroup) countAccumulatePoint(idx int64) {
+	g.values[idx]++
+}
+
+func (g *CountGroupAggregationGroup) groupAccumulatePoint(idx int64) {
+	g.values[idx] = 1
+}
+
+func (g *CountGroupAggregationGroup) AccumulateSeries(data types.InstantVectorSeriesData, steps int, start int64, interval int64, pool *pooling.LimitingPool) error {
+	if (len(data.Floats) > 0 || len(data.Histograms) > 0) && g.values == nil {
+		// First series with values for this group, populate it.
+		var err error
+		if g.values, err = pool.GetFloatSlice(steps); err != nil {
+			return err
+		}
+
+		g.values = g.values[:steps]
+	}
+
+	for _, p := range data.Floats {
+		g.accumulatePoint((p.T - start) / interval)
+	}
+
+	// Both count and group consider native histograms in the same way as floats.
+	for _, p := range data.Histograms {
+		g.accumulatePoint((p.T - start) / interval)
+	}
+
+	return nil
+}
+
+func (g *CountGroupAggregationGroup) ComputeOutputSeries(start int64, interval int64, pool *pooling.LimitingPool) (types.InstantVectorSeriesData, error) {
+	floatPointCount := 0
+	for _, v := range g.values {
+		if v > 0 {
+			floatPointCount++
+		}
+	}
+
+	var floatPoints []promql.FPoint
+	if floatPointCount > 0 {
+		var err error
+		floatPoints, err = pool.GetFPointSlice(floatPointCount)
+		if err != nil {
+			return types.InstantVectorSeriesData{}, err
+		}
+
+		for i, v := range g.values {
+			if v > 0 {
+				t := start + int64(i)*interval
+				floatPoints = append(floatPoints, promql.FPoint{T: t, F: v})
+			}
+		}
+	}
+
+	pool.PutFloatSlice(g.values)
+
+	return types.InstantVectorSeriesData{Floats: floatPoints}, nil
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/engine.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package aggregations
+
+import (
+	"math"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+type MinMaxAggregationGroup struct {
+	floatValues  []float64
+	floatPresent []bool
+
+	accumulatePoint func(idx int64, f float64)
+}
+
+// NewMinMaxAggregationGroup creates an aggregation group for the max aggregation if max is true, or min otherwise.
+func NewMinMaxAggregationGroup(max bool) *MinMaxAggregationGroup {
+	g := &MinMaxAggregationGroup{}
+	if max {
+		g.accumulatePoint = g.maxAccumulatePoint
+	} else {
+		g.accumulatePoint = g.minAccumulatePoint
+	}
+	return g
+}
+
+func (g *MinMaxAggregationGroup) maxAccumulatePoint(idx int64, f float64) {
+	if !g.floatPresent[idx] || g.floatValues[idx] < f || math.IsNaN(g.floatValues[idx]) {
+		g.floatValues[idx] = f
+		g.floatPresent[idx] = true
+	}
+}
+
+func (g *MinMaxAggregationGroup) minAccumulatePoint(idx int64, f float64) {
+	if !g.floatPresent[idx] || g.floatValues[idx] > f || math.IsNaN(g.floatValues[idx]) {
+		g.floatValues[idx] = f
+		g.floatPresent[idx] = true
+	}
+}
+
+func (g *MinMaxAggregationGroup) AccumulateSeries(data types.InstantVectorSeriesData, steps int, start int64, interval int64, pool *pooling.LimitingPool) error {
+	// Native histograms are ignored for min and max.
+	if len(data.Floats) == 0 {
+		return nil
+	}
+
+	if g.floatValues == nil {
+		// First series with float values for this group, populate it.
+		var err error
+		if g.floatValues, err = pool.GetFloatSlice(steps); err != nil {
+			return err
+		}
+
+		if g.floatPresent, err = pool.GetBoolSlice(steps); err != nil {
+			return err
+		}
+
+		g.floatValues = g.floatValues[:steps]
+		g.floatPresent = g.floatPresent[:steps]
+	}
+
+	for _, p := range data.Floats {
+		idx := (p.T - start) / interval
+		g.accumulatePoint(idx, p.F)
+	}
+
+	return nil
+}
+
+func (g *MinMaxAggregationGroup) ComputeOutputSeries(start int64, interval int64, pool *pooling.LimitingPool) (types.InstantVectorSeriesData, error) {
+	floatPointCount := 0
+	for _, p := range g.floatPresent {
+		if p {
+			floatPointCount++
+		}
+	}
+
+	floatPoints, err := floatPointsFromValues(g.floatValues, g.floatPresent, floatPointCount, start, interval, pool)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	pool.PutFloatSlice(g.floatValues)
+	pool.PutBoolSlice(g.floatPresent)
+
+	return types.InstantVectorSeriesData{Floats: floatPoints}, nil
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/quantile.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package aggregations
+
+import (
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/floats"
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// QuantileAggregationGroup computes the φ-quantile of the values in a group at each step.
+//
+// Computing a quantile requires all values for a step, so this group buffers every float value it
+// receives until ComputeOutputSeries is called.
+type QuantileAggregationGroup struct {
+	q float64
+
+	// One slice of values per step. A nil slice means no values have been seen for that step.
+	values [][]float64
+}
+
+func NewQuantileAggregationGroup(q float64) *QuantileAggregationGroup {
+	return &QuantileAggregationGroup{q: q}
+}
+
+func (g *QuantileAggregationGroup) AccumulateSeries(data types.InstantVectorSeriesData, steps int, start int64, interval int64, pool *pooling.LimitingPool) error {
+	// Native histograms are ignored for quantile.
+	if len(data.Floats) == 0 {
+		return nil
+	}
+
+	if g.values == nil {
+		g.values = make([][]float64, steps)
+	}
+
+	for _, p := range data.Floats {
+		idx := (p.T - start) / interval
+		values := g.values[idx]
+
+		if len(values) == cap(values) {
+			// Either this is the first value for this step, or we've run out of space: get a (larger) slice from the pool.
+			newValues, err := pool.GetFloatSlice(max(2*cap(values), 1))
+			if err != nil {
+				return err
+			}
+
+			newValues = append(newValues, values...)
+			pool.PutFloatSlice(values)
+			values = newValues
+		}
+
+		g.values[idx] = append(values, p.F)
+	}
+
+	return nil
+}
+
+func (g *QuantileAggregationGroup) ComputeOutputSeries(start int64, interval int64, pool *pooling.LimitingPool) (types.InstantVectorSeriesData, error) {
+	pointCount := 0
+	for _, values := range g.values {
+		if len(values) > 0 {
+			pointCount++
+		}
+	}
+
+	var points []promql.FPoint
+	if pointCount > 0 {
+		var err error
+		points, err = pool.GetFPointSlice(pointCount)
+		if err != nil {
+			return types.InstantVectorSeriesData{}, err
+		}
+	}
+
+	for i, values := range g.values {
+		if len(values) == 0 {
+			continue
+		}
+
+		t := start + int64(i)*interval
+		points = append(points, promql.FPoint{T: t, F: floats.Quantile(g.q, values)})
+		pool.PutFloatSlice(values)
+	}
+
+	g.values = nil
+
+	return types.InstantVectorSeriesData{Floats: points}, nil
+}
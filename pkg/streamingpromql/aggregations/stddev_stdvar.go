@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/engine.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package aggregations
+
+import (
+	"math"
+
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// StddevStdvarAggregationGroup implements both the stddev and stdvar aggregations, using Welford's online algorithm
+// to compute the variance of each step.
+type StddevStdvarAggregationGroup struct {
+	floats     []float64 // Sum of squared deviations from the mean for each step.
+	floatMeans []float64
+	counts     []float64
+
+	isStdDev bool
+}
+
+// NewStddevStdvarAggregationGroup creates an aggregation group for the stddev aggregation if stddev is true, or stdvar otherwise.
+func NewStddevStdvarAggregationGroup(stddev bool) *StddevStdvarAggregationGroup {
+	return &StddevStdvarAggregationGroup{isStdDev: stddev}
+}
+
+func (g *StddevStdvarAggregationGroup) AccumulateSeries(data types.InstantVectorSeriesData, steps int, start int64, interval int64, pool *pooling.LimitingPool) error {
+	// Native histograms are ignored for stddev and stdvar.
+	if len(data.Floats) == 0 {
+		return nil
+	}
+
+	if g.floats == nil {
+		// First series with float values for this group, populate it.
+		var err error
+		if g.floats, err = pool.GetFloatSlice(steps); err != nil {
+			return err
+		}
+
+		if g.floatMeans, err = pool.GetFloatSlice(steps); err != nil {
+			return err
+		}
+
+		if g.counts, err = pool.GetFloatSlice(steps); err != nil {
+			return err
+		}
+
+		g.floats = g.floats[:steps]
+		g.floatMeans = g.floatMeans[:steps]
+		g.counts = g.counts[:steps]
+	}
+
+	for _, p := range data.Floats {
+		idx := (p.T - start) / interval
+		g.counts[idx]++
+		delta := p.F - g.floatMeans[idx]
+		g.floatMeans[idx] += delta / g.counts[idx]
+		g.floats[idx] += delta * (p.F - g.floatMeans[idx])
+	}
+
+	return nil
+}
+
+func (g *StddevStdvarAggregationGroup) ComputeOutputSeries(start int64, interval int64, pool *pooling.LimitingPool) (types.InstantVectorSeriesData, error) {
+	floatPointCount := 0
+	for _, c := range g.counts {
+		if c > 0 {
+			floatPointCount++
+		}
+	}
+
+	var floatPoints []promql.FPoint
+	if floatPointCount > 0 {
+		var err error
+		floatPoints, err = pool.GetFPointSlice(floatPointCount)
+		if err != nil {
+			return types.InstantVectorSeriesData{}, err
+		}
+
+		for i, c := range g.counts {
+			if c == 0 {
+				continue
+			}
+
+			t := start + int64(i)*interval
+			f := g.floats[i] / c
+			if g.isStdDev {
+				f = math.Sqrt(f)
+			}
+
+			floatPoints = append(floatPoints, promql.FPoint{T: t, F: f})
+		}
+	}
+
+	pool.PutFloatSlice(g.floats)
+	pool.PutFloatSlice(g.floatMeans)
+	pool.PutFloatSlice(g.counts)
+
+	return types.InstantVectorSeriesData{Floats: floatPoints}, nil
+}
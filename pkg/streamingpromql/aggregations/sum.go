@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/engine.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package aggregations
+
+import (
+	"github.com/prometheus/prometheus/model/histogram"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+type SumAggregationGroup struct {
+	// Sum, presence, and histograms for each step.
+	floatSums           []float64
+	floatPresent        []bool
+	histogramSums       []*histogram.FloatHistogram
+	histogramPointCount int
+}
+
+func (g *SumAggregationGroup) AccumulateSeries(data types.InstantVectorSeriesData, steps int, start int64, interval int64, pool *pooling.LimitingPool) error {
+	var err error
+	if len(data.Floats) > 0 && g.floatSums == nil {
+		// First series with float values for this group, populate it.
+		g.floatSums, err = pool.GetFloatSlice(steps)
+		if err != nil {
+			return err
+		}
+
+		g.floatPresent, err = pool.GetBoolSlice(steps)
+		if err != nil {
+			return err
+		}
+		g.floatSums = g.floatSums[:steps]
+		g.floatPresent = g.floatPresent[:steps]
+	}
+
+	if len(data.Histograms) > 0 && g.histogramSums == nil {
+		// First series with histogram values for this group, populate it.
+		g.histogramSums, err = pool.GetHistogramPointerSlice(steps)
+		if err != nil {
+			return err
+		}
+		g.histogramSums = g.histogramSums[:steps]
+	}
+
+	for _, p := range data.Floats {
+		idx := (p.T - start) / interval
+		g.floatSums[idx] += p.F
+		g.floatPresent[idx] = true
+	}
+
+	for _, p := range data.Histograms {
+		idx := (p.T - start) / interval
+		if g.histogramSums[idx] == nil {
+			// We copy here because we modify the histogram through Add later on.
+			// It is necessary to preserve the original Histogram in case of any range-queries using lookback.
+			g.histogramSums[idx] = p.H.Copy()
+			// We already have to do the check if the histogram exists at this idx,
+			// so we can count the histogram points present at this point instead
+			// of needing to loop again later like we do for floats.
+			g.histogramPointCount++
+		} else {
+			g.histogramSums[idx], err = g.histogramSums[idx].Add(p.H)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (g *SumAggregationGroup) ComputeOutputSeries(start int64, interval int64, pool *pooling.LimitingPool) (types.InstantVectorSeriesData, error) {
+	floatPointCount := g.reconcileAndCountFloatPoints()
+	floatPoints, err := floatPointsFromValues(g.floatSums, g.floatPresent, floatPointCount, start, interval, pool)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	histogramPoints, err := histogramPointsFromValues(g.histogramSums, g.histogramPointCount, start, interval, pool)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	pool.PutFloatSlice(g.floatSums)
+	pool.PutBoolSlice(g.floatPresent)
+	pool.PutHistogramPointerSlice(g.histogramSums)
+
+	return types.InstantVectorSeriesData{Floats: floatPoints, Histograms: histogramPoints}, nil
+}
+
+// reconcileAndCountFloatPoints will return the number of points with a float present.
+// It also takes the opportunity whilst looping through the floats to check if there
+// is a conflicting Histogram present. If both are present, an empty vector should
+// be returned. So this method removes the float+histogram where they conflict.
+func (g *SumAggregationGroup) reconcileAndCountFloatPoints() int {
+	// It would be possible to calculate the number of points when constructing
+	// the series groups. However, it requires checking each point at each input
+	// series which is more costly than looping again here and just checking each
+	// point of the already grouped series.
+	// See: https://github.com/grafana/mimir/pull/8442
+	// We also take two different approaches here: One with extra checks if we
+	// have both Floats and Histograms present, and one without these checks
+	// so we don't have to do it at every point.
+	floatPointCount := 0
+	if len(g.floatPresent) > 0 && len(g.histogramSums) > 0 {
+		for idx, present := range g.floatPresent {
+			if present {
+				if g.histogramSums[idx] != nil {
+					// If a mix of histogram samples and float samples, the corresponding vector element is removed from the output vector entirely.
+					g.floatPresent[idx] = false
+					g.histogramSums[idx] = nil
+					g.histogramPointCount--
+				} else {
+					floatPointCount++
+				}
+			}
+		}
+	} else {
+		for _, p := range g.floatPresent {
+			if p {
+				floatPointCount++
+			}
+		}
+	}
+	return floatPointCount
+}
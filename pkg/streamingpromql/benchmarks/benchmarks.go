@@ -105,9 +105,9 @@ func TestCases(metricSizes []int) []BenchCase {
 		//{
 		//	Expr: "holt_winters(a_X[1d], 0.3, 0.3)",
 		//},
-		//{
-		//	Expr: "changes(a_X[1d])",
-		//},
+		{
+			Expr: "changes(a_X[1d])",
+		},
 		{
 			Expr: "rate(a_X[1d])",
 		},
@@ -115,9 +115,16 @@ func TestCases(metricSizes []int) []BenchCase {
 		{
 			Expr: "rate(nh_X[1h])",
 		},
-		//{
-		//	Expr: "absent_over_time(a_X[1d])",
-		//},
+		{
+			Expr: "absent_over_time(a_X[1d])",
+		},
+		// Subqueries.
+		{
+			Expr: "sum_over_time(a_X[10m:3m])",
+		},
+		{
+			Expr: "max_over_time(rate(a_X[5m])[1h:1m])",
+		},
 		//// Unary operators.
 		//{
 		//	Expr: "-a_X",
@@ -143,28 +150,40 @@ func TestCases(metricSizes []int) []BenchCase {
 		{
 			Expr: `a_2000 - b_2000{l="1234"}`,
 		},
-		//{
-		//	Expr: "a_X and b_X{l=~'.*[0-4]$'}",
-		//},
-		//{
-		//	Expr: "a_X or b_X{l=~'.*[0-4]$'}",
-		//},
-		//{
-		//	Expr: "a_X unless b_X{l=~'.*[0-4]$'}",
-		//},
-		//{
-		//	Expr: "a_X and b_X{l='notfound'}",
-		//},
-		//// Simple functions.
-		//{
-		//	Expr: "abs(a_X)",
-		//},
-		//{
-		//	Expr: "label_replace(a_X, 'l2', '$1', 'l', '(.*)')",
-		//},
-		//{
-		//	Expr: "label_join(a_X, 'l2', '-', 'l', 'l')",
-		//},
+		{
+			Expr: "a_X * 2",
+		},
+		{
+			Expr: "a_X > bool 0.5",
+		},
+		{
+			Expr: "a_X > b_X",
+		},
+		{
+			Expr: "a_X >= bool b_X",
+		},
+		{
+			Expr: "a_X and b_X{l=~'.*[0-4]$'}",
+		},
+		{
+			Expr: "a_X or b_X{l=~'.*[0-4]$'}",
+		},
+		{
+			Expr: "a_X unless b_X{l=~'.*[0-4]$'}",
+		},
+		{
+			Expr: "a_X and b_X{l='notfound'}",
+		},
+		// Simple functions.
+		{
+			Expr: "abs(a_X)",
+		},
+		{
+			Expr: "label_replace(a_X, 'l2', '$1', 'l', '(.*)')",
+		},
+		{
+			Expr: "label_join(a_X, 'l2', '-', 'l', 'l')",
+		},
 		// Simple aggregations.
 		{
 			Expr: "sum(a_X)",
@@ -184,16 +203,28 @@ func TestCases(metricSizes []int) []BenchCase {
 		{
 			Expr: "sum by (l)(nh_X)",
 		},
-		//{
-		//	Expr: "count_values('value', h_X)",
-		//  Steps: 100,
-		//},
-		//{
-		//	Expr: "topk(1, a_X)",
-		//},
-		//{
-		//	Expr: "topk(5, a_X)",
-		//},
+		{
+			Expr: "avg(a_X)",
+		},
+		{
+			Expr: "max by (l)(h_X)",
+		},
+		{
+			Expr: "stddev(a_X)",
+		},
+		{
+			Expr:  "count_values('value', h_X)",
+			Steps: 100,
+		},
+		{
+			Expr: "topk(1, a_X)",
+		},
+		{
+			Expr: "topk(5, a_X)",
+		},
+		{
+			Expr: "quantile(0.9, a_X)",
+		},
 		//// Combinations.
 		{
 			Expr: "rate(a_X[1m]) + rate(b_X[1m])",
@@ -210,26 +241,29 @@ func TestCases(metricSizes []int) []BenchCase {
 		{
 			Expr: "sum without (l)(rate(a_X[1m])) / sum without (l)(rate(b_X[1m]))",
 		},
-		//{
-		//	Expr: "histogram_quantile(0.9, rate(h_X[5m]))",
-		//},
-		//// Many-to-one join.
-		//{
-		//	Expr: "a_X + on(l) group_right a_one",
-		//},
-		//// Label compared to blank string.
-		//{
-		//	Expr:  "count({__name__!=\"\"})",
-		//	Steps: 1,
-		//},
-		//{
-		//	Expr:  "count({__name__!=\"\",l=\"\"})",
-		//	Steps: 1,
-		//},
-		//// Functions which have special handling inside eval()
-		//{
-		//	Expr: "timestamp(a_X)",
-		//},
+		{
+			Expr: "histogram_quantile(0.9, rate(h_X[5m]))",
+		},
+		{
+			Expr: "histogram_quantile(0.9, rate(nh_X[5m]))",
+		},
+		// Many-to-one join.
+		{
+			Expr: "a_X + on() group_left a_1",
+		},
+		// Label compared to blank string.
+		{
+			Expr:  "count({__name__!=\"\"})",
+			Steps: 1,
+		},
+		{
+			Expr:  "count({__name__!=\"\",l=\"\"})",
+			Steps: 1,
+		},
+		// Functions which have special handling inside eval()
+		{
+			Expr: "timestamp(a_X)",
+		},
 	}
 
 	// X in an expr will be replaced by different metric sizes.
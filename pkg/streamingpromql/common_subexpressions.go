@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package streamingpromql
+
+import (
+	"time"
+
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// findCommonSubexpressions returns the instant vector selectors and function calls producing instant vectors that
+// appear more than once in expr.
+//
+// Each of these expressions can be evaluated once, and the result shared between every place it appears.
+func findCommonSubexpressions(expr parser.Expr) map[parser.Expr]struct{} {
+	candidates := map[string][]parser.Expr{}
+	excluded := map[parser.Expr]struct{}{}
+
+	parser.Inspect(expr, func(node parser.Node, path []parser.Node) error {
+		switch n := node.(type) {
+		case *parser.VectorSelector:
+			if len(path) > 0 {
+				if _, isRangeVectorSelector := path[len(path)-1].(*parser.MatrixSelector); isRangeVectorSelector {
+					return nil
+				}
+			}
+		case *parser.Call:
+			if selector, ok := timestampFunctionVectorSelector(n); ok {
+				excluded[selector] = struct{}{}
+			}
+
+			if n.Type() != parser.ValueTypeVector {
+				return nil
+			}
+		default:
+			return nil
+		}
+
+		e := node.(parser.Expr)
+		s := e.String()
+		candidates[s] = append(candidates[s], e)
+
+		return nil
+	})
+
+	common := map[parser.Expr]struct{}{}
+
+	for _, exprs := range candidates {
+		if len(exprs) < 2 {
+			continue
+		}
+
+		for _, e := range exprs {
+			if _, isExcluded := excluded[e]; !isExcluded {
+				common[e] = struct{}{}
+			}
+		}
+	}
+
+	return common
+}
+
+// timestampFunctionVectorSelector returns the vector selector passed to call if call is timestamp() over a vector
+// selector.
+//
+// timestamp() over a vector selector configures the selector to return the timestamp of each sample, so the selector
+// can't be shared with any other expression, and must be evaluated at every time step, even if it is step invariant.
+func timestampFunctionVectorSelector(call *parser.Call) (*parser.VectorSelector, bool) {
+	if call.Func.Name != "timestamp" || len(call.Args) != 1 {
+		return nil, false
+	}
+
+	selector, ok := unwrapParenAndStepInvariantExpr(call.Args[0]).(*parser.VectorSelector)
+	return selector, ok
+}
+
+// subexpressionKey identifies a common subexpression evaluated over a particular time range.
+//
+// The same expression can be evaluated over different time ranges in the same query (eg. inside and outside a subquery),
+// and results can only be shared between instances evaluated over the same time range.
+type subexpressionKey struct {
+	expr     string
+	start    int64
+	end      int64
+	interval time.Duration
+}
+
+func newSubexpressionKey(expr parser.Expr, timeRange types.QueryTimeRange) subexpressionKey {
+	return subexpressionKey{
+		expr:     expr.String(),
+		start:    timestamp.FromTime(timeRange.Start),
+		end:      timestamp.FromTime(timeRange.End),
+		interval: timeRange.Interval,
+	}
+}
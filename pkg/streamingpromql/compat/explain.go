@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+const (
+	mimirEngineName      = "mimir"
+	prometheusEngineName = "prometheus"
+)
+
+// QueryPlanner is implemented by query engines that can describe the plan they would use to evaluate a query,
+// without evaluating it.
+type QueryPlanner interface {
+	InstantQueryPlan(ctx context.Context, q storage.Queryable, opts promql.QueryOpts, qs string, ts time.Time) (*types.PlanNode, error)
+	RangeQueryPlan(ctx context.Context, q storage.Queryable, opts promql.QueryOpts, qs string, start, end time.Time, interval time.Duration) (*types.PlanNode, error)
+}
+
+// QueryExplanation describes how a query would be evaluated.
+type QueryExplanation struct {
+	// Engine is the engine that would evaluate the query: either "mimir" or "prometheus".
+	Engine string `json:"engine"`
+
+	// FallbackReason is the reason the query would fall back to Prometheus' engine, if Mimir's query engine is
+	// configured with fallback enabled but does not support the query.
+	FallbackReason string `json:"fallbackReason,omitempty"`
+
+	// Plan describes the operators Mimir's query engine would use to evaluate the query, if it would evaluate it.
+	Plan *types.PlanNode `json:"plan,omitempty"`
+}
+
+// ExplainInstantQuery describes how engine would evaluate qs as an instant query, without evaluating it.
+func ExplainInstantQuery(ctx context.Context, engine promql.QueryEngine, q storage.Queryable, opts promql.QueryOpts, qs string, ts time.Time) (QueryExplanation, error) {
+	return explain(ctx, engine, func(planner QueryPlanner) (*types.PlanNode, error) {
+		return planner.InstantQueryPlan(ctx, q, opts, qs, ts)
+	})
+}
+
+// ExplainRangeQuery describes how engine would evaluate qs as a range query, without evaluating it.
+func ExplainRangeQuery(ctx context.Context, engine promql.QueryEngine, q storage.Queryable, opts promql.QueryOpts, qs string, start, end time.Time, interval time.Duration) (QueryExplanation, error) {
+	return explain(ctx, engine, func(planner QueryPlanner) (*types.PlanNode, error) {
+		return planner.RangeQueryPlan(ctx, q, opts, qs, start, end, interval)
+	})
+}
+
+func explain(ctx context.Context, engine promql.QueryEngine, plan func(QueryPlanner) (*types.PlanNode, error)) (QueryExplanation, error) {
+	switch e := engine.(type) {
+	case *EngineWithFallback:
+		if isForceFallbackEnabled(ctx) {
+			return QueryExplanation{Engine: prometheusEngineName, FallbackReason: fallbackForcedByHTTPHeader}, nil
+		}
+
+		planner, ok := e.preferred.(QueryPlanner)
+		if !ok {
+			return QueryExplanation{}, fmt.Errorf("preferred query engine %T does not support explaining queries", e.preferred)
+		}
+
+		p, err := plan(planner)
+		if err == nil {
+			return QueryExplanation{Engine: mimirEngineName, Plan: p}, nil
+		}
+
+		notSupportedErr := NotSupportedError{}
+		if !errors.As(err, &notSupportedErr) {
+			return QueryExplanation{}, err
+		}
+
+		return QueryExplanation{Engine: prometheusEngineName, FallbackReason: notSupportedErr.reason}, nil
+
+	case QueryPlanner:
+		p, err := plan(e)
+		if err != nil {
+			return QueryExplanation{}, err
+		}
+
+		return QueryExplanation{Engine: mimirEngineName, Plan: p}, nil
+
+	case *promql.Engine:
+		return QueryExplanation{Engine: prometheusEngineName}, nil
+
+	default:
+		return QueryExplanation{}, fmt.Errorf("query engine %T does not support explaining queries", engine)
+	}
+}
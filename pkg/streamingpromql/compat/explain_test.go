@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+func TestExplainQuery(t *testing.T) {
+	explainers := map[string]func(ctx context.Context, engine promql.QueryEngine, expr string) (QueryExplanation, error){
+		"instant query": func(ctx context.Context, engine promql.QueryEngine, expr string) (QueryExplanation, error) {
+			return ExplainInstantQuery(ctx, engine, nil, nil, expr, time.Now())
+		},
+		"range query": func(ctx context.Context, engine promql.QueryEngine, expr string) (QueryExplanation, error) {
+			return ExplainRangeQuery(ctx, engine, nil, nil, expr, time.Now().Add(-time.Minute), time.Now(), time.Second)
+		},
+	}
+
+	for name, explain := range explainers {
+		t.Run(name, func(t *testing.T) {
+			newEngineWithFallback := func() promql.QueryEngine {
+				return NewEngineWithFallback(newFakePlanningEngine(), newFakeEngineThatSupportsAllQueries(), prometheus.NewPedanticRegistry(), log.NewNopLogger())
+			}
+
+			t.Run("engine with fallback, supported expression", func(t *testing.T) {
+				explanation, err := explain(context.Background(), newEngineWithFallback(), "a_supported_expression")
+				require.NoError(t, err)
+				require.Equal(t, QueryExplanation{Engine: "mimir", Plan: fakePlan}, explanation)
+			})
+
+			t.Run("engine with fallback, unsupported expression", func(t *testing.T) {
+				explanation, err := explain(context.Background(), newEngineWithFallback(), "a_non_supported_expression")
+				require.NoError(t, err)
+				require.Equal(t, QueryExplanation{Engine: "prometheus", FallbackReason: "this expression is not supported"}, explanation)
+			})
+
+			t.Run("engine with fallback, invalid expression", func(t *testing.T) {
+				_, err := explain(context.Background(), newEngineWithFallback(), "an_invalid_expression")
+				require.EqualError(t, err, "the query is invalid")
+			})
+
+			t.Run("engine with fallback, fallback forced by HTTP header", func(t *testing.T) {
+				explanation, err := explain(withForceFallbackEnabled(context.Background()), newEngineWithFallback(), "a_supported_expression")
+				require.NoError(t, err)
+				require.Equal(t, QueryExplanation{Engine: "prometheus", FallbackReason: "fallback forced by HTTP header"}, explanation)
+			})
+
+			t.Run("Mimir's query engine without fallback, supported expression", func(t *testing.T) {
+				explanation, err := explain(context.Background(), newFakePlanningEngine(), "a_supported_expression")
+				require.NoError(t, err)
+				require.Equal(t, QueryExplanation{Engine: "mimir", Plan: fakePlan}, explanation)
+			})
+
+			t.Run("Mimir's query engine without fallback, unsupported expression", func(t *testing.T) {
+				_, err := explain(context.Background(), newFakePlanningEngine(), "a_non_supported_expression")
+				require.EqualError(t, err, "not supported by streaming engine: this expression is not supported")
+			})
+
+			t.Run("Prometheus' engine", func(t *testing.T) {
+				explanation, err := explain(context.Background(), promql.NewEngine(promql.EngineOpts{}), "a_supported_expression")
+				require.NoError(t, err)
+				require.Equal(t, QueryExplanation{Engine: "prometheus"}, explanation)
+			})
+
+			t.Run("unknown engine", func(t *testing.T) {
+				_, err := explain(context.Background(), newFakeEngineThatSupportsAllQueries(), "a_supported_expression")
+				require.EqualError(t, err, "query engine *compat.fakeEngineThatSupportsAllQueries does not support explaining queries")
+			})
+		})
+	}
+}
+
+var fakePlan = &types.PlanNode{Operator: "FakeOperator"}
+
+type fakePlanningEngine struct {
+	*fakeEngineThatSupportsLimitedQueries
+}
+
+func newFakePlanningEngine() *fakePlanningEngine {
+	return &fakePlanningEngine{newFakeEngineThatSupportsLimitedQueries()}
+}
+
+func (f *fakePlanningEngine) InstantQueryPlan(ctx context.Context, q storage.Queryable, opts promql.QueryOpts, qs string, ts time.Time) (*types.PlanNode, error) {
+	if _, err := f.NewInstantQuery(ctx, q, opts, qs, ts); err != nil {
+		return nil, err
+	}
+
+	return fakePlan, nil
+}
+
+func (f *fakePlanningEngine) RangeQueryPlan(ctx context.Context, q storage.Queryable, opts promql.QueryOpts, qs string, start, end time.Time, interval time.Duration) (*types.PlanNode, error) {
+	if _, err := f.NewRangeQuery(ctx, q, opts, qs, start, end, interval); err != nil {
+		return nil, err
+	}
+
+	return fakePlan, nil
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/prometheus/prometheus/storage"
 
 	"github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
 )
 
 const defaultLookbackDelta = 5 * time.Minute // This should be the same value as github.com/prometheus/prometheus/promql.defaultLookbackDelta.
@@ -41,10 +42,11 @@ func NewEngine(opts promql.EngineOpts, limitsProvider QueryLimitsProvider, metri
 	}
 
 	return &Engine{
-		lookbackDelta:      lookbackDelta,
-		timeout:            opts.Timeout,
-		limitsProvider:     limitsProvider,
-		activeQueryTracker: opts.ActiveQueryTracker,
+		lookbackDelta:            lookbackDelta,
+		timeout:                  opts.Timeout,
+		limitsProvider:           limitsProvider,
+		activeQueryTracker:       opts.ActiveQueryTracker,
+		noStepSubqueryIntervalFn: opts.NoStepSubqueryIntervalFn,
 
 		logger: logger,
 		estimatedPeakMemoryConsumption: promauto.With(opts.Reg).NewHistogram(prometheus.HistogramOpts{
@@ -57,10 +59,11 @@ func NewEngine(opts promql.EngineOpts, limitsProvider QueryLimitsProvider, metri
 }
 
 type Engine struct {
-	lookbackDelta      time.Duration
-	timeout            time.Duration
-	limitsProvider     QueryLimitsProvider
-	activeQueryTracker promql.QueryTracker
+	lookbackDelta            time.Duration
+	timeout                  time.Duration
+	limitsProvider           QueryLimitsProvider
+	activeQueryTracker       promql.QueryTracker
+	noStepSubqueryIntervalFn func(rangeMillis int64) int64
 
 	logger                                    log.Logger
 	estimatedPeakMemoryConsumption            prometheus.Histogram
@@ -83,24 +86,57 @@ func (e *Engine) NewRangeQuery(ctx context.Context, q storage.Queryable, opts pr
 	return newQuery(ctx, q, opts, qs, start, end, interval, e)
 }
 
+// InstantQueryPlan returns the plan the engine would use to evaluate qs as an instant query, without evaluating it.
+func (e *Engine) InstantQueryPlan(ctx context.Context, q storage.Queryable, opts promql.QueryOpts, qs string, ts time.Time) (*types.PlanNode, error) {
+	query, err := e.NewInstantQuery(ctx, q, opts, qs, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	defer query.Close()
+
+	return query.(*Query).Plan(), nil
+}
+
+// RangeQueryPlan returns the plan the engine would use to evaluate qs as a range query, without evaluating it.
+func (e *Engine) RangeQueryPlan(ctx context.Context, q storage.Queryable, opts promql.QueryOpts, qs string, start, end time.Time, interval time.Duration) (*types.PlanNode, error) {
+	query, err := e.NewRangeQuery(ctx, q, opts, qs, start, end, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	defer query.Close()
+
+	return query.(*Query).Plan(), nil
+}
+
 type QueryLimitsProvider interface {
 	// GetMaxEstimatedMemoryConsumptionPerQuery returns the maximum estimated memory allowed to be consumed by a query in bytes, or 0 to disable the limit.
 	GetMaxEstimatedMemoryConsumptionPerQuery(ctx context.Context) (uint64, error)
+
+	// GetMaxConcurrentSubtreePrefetchesPerQuery returns the maximum number of subtrees of a query that can be prefetched concurrently, or 0 to disable prefetching.
+	GetMaxConcurrentSubtreePrefetchesPerQuery(ctx context.Context) (int, error)
 }
 
 // NewStaticQueryLimitsProvider returns a QueryLimitsProvider that always returns the provided limits.
 //
 // This should generally only be used in tests.
-func NewStaticQueryLimitsProvider(maxEstimatedMemoryConsumptionPerQuery uint64) QueryLimitsProvider {
+func NewStaticQueryLimitsProvider(maxEstimatedMemoryConsumptionPerQuery uint64, maxConcurrentSubtreePrefetchesPerQuery int) QueryLimitsProvider {
 	return staticQueryLimitsProvider{
-		maxEstimatedMemoryConsumptionPerQuery: maxEstimatedMemoryConsumptionPerQuery,
+		maxEstimatedMemoryConsumptionPerQuery:  maxEstimatedMemoryConsumptionPerQuery,
+		maxConcurrentSubtreePrefetchesPerQuery: maxConcurrentSubtreePrefetchesPerQuery,
 	}
 }
 
 type staticQueryLimitsProvider struct {
-	maxEstimatedMemoryConsumptionPerQuery uint64
+	maxEstimatedMemoryConsumptionPerQuery  uint64
+	maxConcurrentSubtreePrefetchesPerQuery int
 }
 
 func (p staticQueryLimitsProvider) GetMaxEstimatedMemoryConsumptionPerQuery(_ context.Context) (uint64, error) {
 	return p.maxEstimatedMemoryConsumptionPerQuery, nil
 }
+
+func (p staticQueryLimitsProvider) GetMaxConcurrentSubtreePrefetchesPerQuery(_ context.Context) (int, error) {
+	return p.maxConcurrentSubtreePrefetchesPerQuery, nil
+}
@@ -23,42 +23,36 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/timestamp"
 	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/promql/promqltest"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/util/annotations"
+	promql_stats "github.com/prometheus/prometheus/util/stats"
 	"github.com/stretchr/testify/require"
 	"github.com/uber/jaeger-client-go"
+	"go.uber.org/atomic"
 
 	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/streamingpromql/compat"
 	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
 	"github.com/grafana/mimir/pkg/util/globalerror"
 	"github.com/grafana/mimir/pkg/util/test"
 )
 
 func TestUnsupportedPromQLFeatures(t *testing.T) {
 	opts := NewTestEngineOpts()
-	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0), stats.NewQueryMetrics(nil), log.NewNopLogger())
+	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0, 0), stats.NewQueryMetrics(nil), log.NewNopLogger())
 	require.NoError(t, err)
 	ctx := context.Background()
 
 	// The goal of this is not to list every conceivable expression that is unsupported, but to cover all the
 	// different cases and make sure we produce a reasonable error message when these cases are encountered.
 	unsupportedExpressions := map[string]string{
-		"1 + 2":                      "scalar value as top-level expression",
-		"1 + metric{}":               "binary expression with scalars",
-		"metric{} + 1":               "binary expression with scalars",
-		"metric{} < other_metric{}":  "binary expression with '<'",
-		"metric{} or other_metric{}": "binary expression with many-to-many matching",
-		"metric{} + on() group_left() other_metric{}":  "binary expression with many-to-one matching",
-		"metric{} + on() group_right() other_metric{}": "binary expression with one-to-many matching",
-		"1":                            "scalar value as top-level expression",
-		"metric{} offset 2h":           "instant vector selector with 'offset'",
-		"avg(metric{})":                "'avg' aggregation",
-		"rate(metric{}[5m] offset 2h)": "range vector selector with 'offset'",
-		"rate(metric{}[5m:1m])":        "PromQL expression type *parser.SubqueryExpr",
-		"avg_over_time(metric{}[5m])":  "'avg_over_time' function",
-		"-sum(metric{})":               "PromQL expression type *parser.UnaryExpr",
+		"topk(scalar(metric{}), metric{})":     "'topk' aggregation with non-literal parameter",
+		"holt_winters(metric{}[5m], 0.5, 0.5)": "'holt_winters' function",
+		"-sum(metric{})":                       "PromQL expression type *parser.UnaryExpr",
+		"-time()":                              "PromQL expression type *parser.UnaryExpr for scalars",
 	}
 
 	for expression, expectedError := range unsupportedExpressions {
@@ -79,9 +73,7 @@ func TestUnsupportedPromQLFeatures(t *testing.T) {
 
 	// These expressions are also unsupported, but are only valid as instant queries.
 	unsupportedInstantQueryExpressions := map[string]string{
-		"'a'":                    "string value as top-level expression",
-		"metric{}[5m] offset 2h": "range vector selector with 'offset'",
-		"metric{}[5m:1m]":        "PromQL expression type *parser.SubqueryExpr",
+		"'a'": "string value as top-level expression",
 	}
 
 	for expression, expectedError := range unsupportedInstantQueryExpressions {
@@ -97,7 +89,7 @@ func TestUnsupportedPromQLFeatures(t *testing.T) {
 
 func TestNewRangeQuery_InvalidQueryTime(t *testing.T) {
 	opts := NewTestEngineOpts()
-	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0), stats.NewQueryMetrics(nil), log.NewNopLogger())
+	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0, 0), stats.NewQueryMetrics(nil), log.NewNopLogger())
 	require.NoError(t, err)
 	ctx := context.Background()
 
@@ -111,7 +103,7 @@ func TestNewRangeQuery_InvalidQueryTime(t *testing.T) {
 
 func TestNewRangeQuery_InvalidExpressionTypes(t *testing.T) {
 	opts := NewTestEngineOpts()
-	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0), stats.NewQueryMetrics(nil), log.NewNopLogger())
+	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0, 0), stats.NewQueryMetrics(nil), log.NewNopLogger())
 	require.NoError(t, err)
 	ctx := context.Background()
 
@@ -127,7 +119,10 @@ func TestNewRangeQuery_InvalidExpressionTypes(t *testing.T) {
 // Once the streaming engine supports all PromQL features exercised by Prometheus' test cases, we can remove these files and instead call promql.RunBuiltinTests here instead.
 func TestUpstreamTestCases(t *testing.T) {
 	opts := NewTestEngineOpts()
-	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0), stats.NewQueryMetrics(nil), log.NewNopLogger())
+	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0, 0), stats.NewQueryMetrics(nil), log.NewNopLogger())
+	require.NoError(t, err)
+
+	prefetchingEngine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0, 2), stats.NewQueryMetrics(nil), log.NewNopLogger())
 	require.NoError(t, err)
 
 	testdataFS := os.DirFS("./testdata")
@@ -143,14 +138,27 @@ func TestUpstreamTestCases(t *testing.T) {
 			testScript, err := io.ReadAll(f)
 			require.NoError(t, err)
 
-			promqltest.RunTest(t, string(testScript), engine)
+			t.Run("prefetching disabled", func(t *testing.T) {
+				promqltest.RunTest(t, string(testScript), engine)
+			})
+
+			t.Run("prefetching enabled", func(t *testing.T) {
+				promqltest.RunTest(t, string(testScript), prefetchingEngine)
+			})
 		})
 	}
 }
 
 func TestOurTestCases(t *testing.T) {
+	// Some of our test cases use limitk and limit_ratio, which are experimental.
+	t.Cleanup(func() { parser.EnableExperimentalFunctions = false })
+	parser.EnableExperimentalFunctions = true
+
 	opts := NewTestEngineOpts()
-	mimirEngine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0), stats.NewQueryMetrics(nil), log.NewNopLogger())
+	mimirEngine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0, 0), stats.NewQueryMetrics(nil), log.NewNopLogger())
+	require.NoError(t, err)
+
+	prefetchingMimirEngine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0, 2), stats.NewQueryMetrics(nil), log.NewNopLogger())
 	require.NoError(t, err)
 
 	prometheusEngine := promql.NewEngine(opts)
@@ -174,6 +182,10 @@ func TestOurTestCases(t *testing.T) {
 				promqltest.RunTest(t, testScript, mimirEngine)
 			})
 
+			t.Run("Mimir's engine with prefetching", func(t *testing.T) {
+				promqltest.RunTest(t, testScript, prefetchingMimirEngine)
+			})
+
 			// Run the tests against Prometheus' engine to ensure our test cases are valid.
 			t.Run("Prometheus' engine", func(t *testing.T) {
 				promqltest.RunTest(t, testScript, prometheusEngine)
@@ -188,7 +200,7 @@ func TestOurTestCases(t *testing.T) {
 // So instead, we test these few cases here instead.
 func TestRangeVectorSelectors(t *testing.T) {
 	opts := NewTestEngineOpts()
-	mimirEngine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0), stats.NewQueryMetrics(nil), log.NewNopLogger())
+	mimirEngine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0, 0), stats.NewQueryMetrics(nil), log.NewNopLogger())
 	require.NoError(t, err)
 
 	prometheusEngine := promql.NewEngine(opts)
@@ -238,6 +250,50 @@ func TestRangeVectorSelectors(t *testing.T) {
 				},
 			},
 		},
+		"matches series with points in range, with offset": {
+			expr: "some_metric[1m] offset 1m",
+			ts:   baseT.Add(3 * time.Minute),
+			expected: &promql.Result{
+				Value: promql.Matrix{
+					{
+						Metric: labels.FromStrings("__name__", "some_metric", "env", "1"),
+						Floats: []promql.FPoint{
+							{T: timestamp.FromTime(baseT.Add(time.Minute)), F: 1},
+							{T: timestamp.FromTime(baseT.Add(2 * time.Minute)), F: 2},
+						},
+					},
+					{
+						Metric: labels.FromStrings("__name__", "some_metric", "env", "2"),
+						Floats: []promql.FPoint{
+							{T: timestamp.FromTime(baseT.Add(time.Minute)), F: 2},
+							{T: timestamp.FromTime(baseT.Add(2 * time.Minute)), F: 4},
+						},
+					},
+				},
+			},
+		},
+		"matches series with points in range, with @ modifier and offset": {
+			expr: "some_metric[1m] @ 240 offset 2m",
+			ts:   baseT,
+			expected: &promql.Result{
+				Value: promql.Matrix{
+					{
+						Metric: labels.FromStrings("__name__", "some_metric", "env", "1"),
+						Floats: []promql.FPoint{
+							{T: timestamp.FromTime(baseT.Add(time.Minute)), F: 1},
+							{T: timestamp.FromTime(baseT.Add(2 * time.Minute)), F: 2},
+						},
+					},
+					{
+						Metric: labels.FromStrings("__name__", "some_metric", "env", "2"),
+						Floats: []promql.FPoint{
+							{T: timestamp.FromTime(baseT.Add(time.Minute)), F: 2},
+							{T: timestamp.FromTime(baseT.Add(2 * time.Minute)), F: 4},
+						},
+					},
+				},
+			},
+		},
 		"matches no series": {
 			expr: "some_nonexistent_metric[1m]",
 			ts:   baseT,
@@ -597,9 +653,122 @@ func TestRangeVectorSelectors(t *testing.T) {
 	}
 }
 
+func TestSubqueriesAsTopLevelExpression(t *testing.T) {
+	opts := NewTestEngineOpts()
+	mimirEngine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0, 0), stats.NewQueryMetrics(nil), log.NewNopLogger())
+	require.NoError(t, err)
+
+	prometheusEngine := promql.NewEngine(opts)
+
+	baseT := timestamp.Time(0)
+	storage := promqltest.LoadedStorage(t, `
+		load 1m
+			some_metric{env="1"} 0+1x10
+			some_metric{env="2"} 0+2x10
+	`)
+
+	t.Cleanup(func() { require.NoError(t, storage.Close()) })
+
+	testCases := map[string]struct {
+		expr     string
+		ts       time.Time
+		expected promql.Matrix
+	}{
+		"subquery aligned to query time": {
+			expr: "some_metric[2m:1m]",
+			ts:   baseT.Add(5 * time.Minute),
+			expected: promql.Matrix{
+				{
+					Metric: labels.FromStrings("__name__", "some_metric", "env", "1"),
+					Floats: []promql.FPoint{{T: 180000, F: 3}, {T: 240000, F: 4}, {T: 300000, F: 5}},
+				},
+				{
+					Metric: labels.FromStrings("__name__", "some_metric", "env", "2"),
+					Floats: []promql.FPoint{{T: 180000, F: 6}, {T: 240000, F: 8}, {T: 300000, F: 10}},
+				},
+			},
+		},
+		"subquery not aligned to query time": {
+			expr: "some_metric[2m:1m]",
+			ts:   baseT.Add(5*time.Minute + 30*time.Second),
+			expected: promql.Matrix{
+				{
+					Metric: labels.FromStrings("__name__", "some_metric", "env", "1"),
+					Floats: []promql.FPoint{{T: 240000, F: 4}, {T: 300000, F: 5}},
+				},
+				{
+					Metric: labels.FromStrings("__name__", "some_metric", "env", "2"),
+					Floats: []promql.FPoint{{T: 240000, F: 8}, {T: 300000, F: 10}},
+				},
+			},
+		},
+		"subquery with offset": {
+			expr: "some_metric[2m:1m] offset 1m",
+			ts:   baseT.Add(5 * time.Minute),
+			expected: promql.Matrix{
+				{
+					Metric: labels.FromStrings("__name__", "some_metric", "env", "1"),
+					Floats: []promql.FPoint{{T: 120000, F: 2}, {T: 180000, F: 3}, {T: 240000, F: 4}},
+				},
+				{
+					Metric: labels.FromStrings("__name__", "some_metric", "env", "2"),
+					Floats: []promql.FPoint{{T: 120000, F: 4}, {T: 180000, F: 6}, {T: 240000, F: 8}},
+				},
+			},
+		},
+		"subquery with @": {
+			expr: "some_metric[2m:1m] @ 120",
+			ts:   baseT.Add(5 * time.Minute),
+			expected: promql.Matrix{
+				{
+					Metric: labels.FromStrings("__name__", "some_metric", "env", "1"),
+					Floats: []promql.FPoint{{T: 0, F: 0}, {T: 60000, F: 1}, {T: 120000, F: 2}},
+				},
+				{
+					Metric: labels.FromStrings("__name__", "some_metric", "env", "2"),
+					Floats: []promql.FPoint{{T: 0, F: 0}, {T: 60000, F: 2}, {T: 120000, F: 4}},
+				},
+			},
+		},
+		"subquery over expression": {
+			expr: `sum(some_metric)[2m:1m]`,
+			ts:   baseT.Add(5 * time.Minute),
+			expected: promql.Matrix{
+				{
+					Metric: labels.EmptyLabels(),
+					Floats: []promql.FPoint{{T: 180000, F: 9}, {T: 240000, F: 12}, {T: 300000, F: 15}},
+				},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			runTest := func(t *testing.T, eng promql.QueryEngine) {
+				q, err := eng.NewInstantQuery(context.Background(), storage, nil, testCase.expr, testCase.ts)
+				require.NoError(t, err)
+				defer q.Close()
+
+				res := q.Exec(context.Background())
+				require.NoError(t, res.Err)
+				require.Equal(t, testCase.expected, res.Value)
+			}
+
+			t.Run("Mimir's engine", func(t *testing.T) {
+				runTest(t, mimirEngine)
+			})
+
+			// Run the tests against Prometheus' engine to ensure our test cases are valid.
+			t.Run("Prometheus' engine", func(t *testing.T) {
+				runTest(t, prometheusEngine)
+			})
+		})
+	}
+}
+
 func TestQueryCancellation(t *testing.T) {
 	opts := NewTestEngineOpts()
-	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0), stats.NewQueryMetrics(nil), log.NewNopLogger())
+	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0, 0), stats.NewQueryMetrics(nil), log.NewNopLogger())
 	require.NoError(t, err)
 
 	// Simulate the query being cancelled by another goroutine by waiting for the Select() call to be made,
@@ -627,7 +796,7 @@ func TestQueryCancellation(t *testing.T) {
 func TestQueryTimeout(t *testing.T) {
 	opts := NewTestEngineOpts()
 	opts.Timeout = 20 * time.Millisecond
-	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0), stats.NewQueryMetrics(nil), log.NewNopLogger())
+	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0, 0), stats.NewQueryMetrics(nil), log.NewNopLogger())
 	require.NoError(t, err)
 
 	// Simulate the query doing some work and check that the query context has been cancelled.
@@ -693,7 +862,7 @@ func (w cancellationQuerier) waitForCancellation(ctx context.Context) error {
 
 func TestQueryContextCancelledOnceQueryFinished(t *testing.T) {
 	opts := NewTestEngineOpts()
-	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0), stats.NewQueryMetrics(nil), log.NewNopLogger())
+	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0, 0), stats.NewQueryMetrics(nil), log.NewNopLogger())
 	require.NoError(t, err)
 
 	storage := promqltest.LoadedStorage(t, `
@@ -905,7 +1074,7 @@ func TestMemoryConsumptionLimit_SingleQueries(t *testing.T) {
 		opts := NewTestEngineOpts()
 		opts.Reg = reg
 
-		engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(limit), stats.NewQueryMetrics(reg), log.NewNopLogger())
+		engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(limit, 0), stats.NewQueryMetrics(reg), log.NewNopLogger())
 		require.NoError(t, err)
 
 		tracer, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), jaeger.NewNullReporter())
@@ -921,8 +1090,8 @@ func TestMemoryConsumptionLimit_SingleQueries(t *testing.T) {
 
 		jaegerSpan, ok := span.(*jaeger.Span)
 		require.True(t, ok)
-		require.Len(t, jaegerSpan.Logs(), 1)
-		traceLog := jaegerSpan.Logs()[0]
+		require.NotEmpty(t, jaegerSpan.Logs())
+		traceLog := jaegerSpan.Logs()[0] // Subsequent logs contain the statistics for each operator.
 		expectedFields := []otlog.Field{
 			otlog.String("level", "info"),
 			otlog.String("msg", "query stats"),
@@ -988,7 +1157,7 @@ func TestMemoryConsumptionLimit_MultipleQueries(t *testing.T) {
 	opts.Reg = reg
 
 	limit := 3 * 8 * pooling.FPointSize // Allow up to three series with five points (which will be rounded up to 8, the nearest power of 2)
-	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(limit), stats.NewQueryMetrics(reg), log.NewNopLogger())
+	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(limit, 0), stats.NewQueryMetrics(reg), log.NewNopLogger())
 	require.NoError(t, err)
 
 	runQuery := func(expr string, shouldSucceed bool) {
@@ -1055,7 +1224,7 @@ func TestActiveQueryTracker(t *testing.T) {
 			opts := NewTestEngineOpts()
 			tracker := &testQueryTracker{}
 			opts.ActiveQueryTracker = tracker
-			engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0), stats.NewQueryMetrics(nil), log.NewNopLogger())
+			engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0, 0), stats.NewQueryMetrics(nil), log.NewNopLogger())
 			require.NoError(t, err)
 
 			innerStorage := promqltest.LoadedStorage(t, "")
@@ -1162,7 +1331,7 @@ func TestActiveQueryTracker_WaitingForTrackerIncludesQueryTimeout(t *testing.T)
 	opts := NewTestEngineOpts()
 	opts.Timeout = 10 * time.Millisecond
 	opts.ActiveQueryTracker = tracker
-	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0), stats.NewQueryMetrics(nil), log.NewNopLogger())
+	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0, 0), stats.NewQueryMetrics(nil), log.NewNopLogger())
 	require.NoError(t, err)
 
 	queryTypes := map[string]func() (promql.Query, error){
@@ -1215,3 +1384,395 @@ func (t *timeoutTestingQueryTracker) Insert(ctx context.Context, _ string) (int,
 func (t *timeoutTestingQueryTracker) Delete(_ int) {
 	panic("should not be called")
 }
+
+func TestQueryPlan(t *testing.T) {
+	opts := NewTestEngineOpts()
+	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0, 0), stats.NewQueryMetrics(nil), log.NewNopLogger())
+	require.NoError(t, err)
+	planner := engine.(compat.QueryPlanner)
+	ctx := context.Background()
+
+	start := time.Date(2024, 3, 22, 3, 0, 0, 0, time.UTC)
+	end := start.Add(10 * time.Minute)
+
+	testCases := map[string]struct {
+		expr          string
+		instant       bool
+		expectedPlan  string
+		expectedError string
+	}{
+		"instant vector selector": {
+			expr:    `some_metric{env="prod"}`,
+			instant: true,
+			expectedPlan: `InstantVectorSelector {env="prod", __name__="some_metric"} [at 2024-03-22T03:00:00Z]
+`,
+		},
+		"aggregation over function over range vector selector with modifiers": {
+			expr: `sum by (env) (rate(some_metric[5m] offset 1m))`,
+			expectedPlan: `Aggregation: sum by (env) [from 2024-03-22T03:00:00Z to 2024-03-22T03:10:00Z with step 1m]
+└─ DeduplicateSeries
+   └─ FunctionOverRangeVector: rate
+      └─ RangeVectorSelector: [5m] offset 1m {__name__="some_metric"}
+`,
+		},
+		"binary operation with vector matching": {
+			expr: `a / on (env) group_left (pod) b`,
+			expectedPlan: `GroupedBinaryOperation: / on (env) group_left (pod) [from 2024-03-22T03:00:00Z to 2024-03-22T03:10:00Z with step 1m]
+├─ InstantVectorSelector {__name__="a"}
+└─ InstantVectorSelector {__name__="b"}
+`,
+		},
+		"function with scalar argument": {
+			expr: `histogram_quantile(0.9, some_metric)`,
+			expectedPlan: `DeduplicateSeries [from 2024-03-22T03:00:00Z to 2024-03-22T03:10:00Z with step 1m]
+└─ HistogramFunction: histogram_quantile
+   ├─ ScalarConstant: 0.9
+   └─ InstantVectorSelector {__name__="some_metric"}
+`,
+		},
+		"vector and scalar": {
+			expr: `2 * some_metric > bool 1`,
+			expectedPlan: `DeduplicateSeries [from 2024-03-22T03:00:00Z to 2024-03-22T03:10:00Z with step 1m]
+└─ VectorScalarBinaryOperation: > bool
+   ├─ DeduplicateSeries
+   │  └─ VectorScalarBinaryOperation: *
+   │     ├─ ScalarConstant: 2
+   │     └─ InstantVectorSelector {__name__="some_metric"}
+   └─ ScalarConstant: 1
+`,
+		},
+		"subquery": {
+			expr:    `max_over_time(some_metric[10m:1m] @ 1711076400)`,
+			instant: true,
+			expectedPlan: `DeduplicateSeries [at 2024-03-22T03:00:00Z]
+└─ FunctionOverRangeVector: max_over_time
+   └─ Subquery: [10m] @ 2024-03-22T03:00:00Z
+      └─ InstantVectorSelector {__name__="some_metric"} [from 2024-03-22T02:50:00Z to 2024-03-22T03:00:00Z with step 1m]
+`,
+		},
+		"common subexpression": {
+			expr: `sum(some_metric) / count(some_metric)`,
+			expectedPlan: `BinaryOperation: / [from 2024-03-22T03:00:00Z to 2024-03-22T03:10:00Z with step 1m]
+├─ Aggregation: sum
+│  └─ InstantVectorDuplicationConsumer: consumer 1 of 2
+│     └─ InstantVectorSelector {__name__="some_metric"}
+└─ Aggregation: count
+   └─ InstantVectorDuplicationConsumer: consumer 2 of 2
+`,
+		},
+		"common subexpression evaluated over different time ranges": {
+			expr: `max_over_time(some_metric[10m:1m]) - some_metric`,
+			expectedPlan: `BinaryOperation: - [from 2024-03-22T03:00:00Z to 2024-03-22T03:10:00Z with step 1m]
+├─ DeduplicateSeries
+│  └─ FunctionOverRangeVector: max_over_time
+│     └─ Subquery: [10m]
+│        └─ InstantVectorDuplicationConsumer: consumer 1 of 1 [from 2024-03-22T02:50:00Z to 2024-03-22T03:10:00Z with step 1m]
+│           └─ InstantVectorSelector {__name__="some_metric"}
+└─ InstantVectorDuplicationConsumer: consumer 1 of 1
+   └─ InstantVectorSelector {__name__="some_metric"}
+`,
+		},
+		"step invariant expression": {
+			expr: `sum(some_metric @ 1711076400)`,
+			expectedPlan: `StepInvariantInstantVectorOperator [from 2024-03-22T03:00:00Z to 2024-03-22T03:10:00Z with step 1m]
+└─ Aggregation: sum [at 2024-03-22T03:00:00Z]
+   └─ InstantVectorSelector: @ 2024-03-22T03:00:00Z {__name__="some_metric"}
+`,
+		},
+		"unsupported expression": {
+			expr:          `holt_winters(some_metric[5m], 0.5, 0.5)`,
+			expectedError: "not supported by streaming engine: 'holt_winters' function",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var plan *types.PlanNode
+			if testCase.instant {
+				plan, err = planner.InstantQueryPlan(ctx, nil, nil, testCase.expr, start)
+			} else {
+				plan, err = planner.RangeQueryPlan(ctx, nil, nil, testCase.expr, start, end, time.Minute)
+			}
+
+			if testCase.expectedError != "" {
+				require.EqualError(t, err, testCase.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, testCase.expectedPlan, plan.String())
+		})
+	}
+}
+
+func TestOperatorStats(t *testing.T) {
+	storage := promqltest.LoadedStorage(t, `
+		load 1m
+			some_metric{env="prod", idx="1"} 0+1x10
+			some_metric{env="prod", idx="2"} 0+1x10
+			some_metric{env="test", idx="3"} 0+1x10
+	`)
+	t.Cleanup(func() { require.NoError(t, storage.Close()) })
+
+	opts := NewTestEngineOpts()
+	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0, 0), stats.NewQueryMetrics(nil), log.NewNopLogger())
+	require.NoError(t, err)
+
+	queryStats, ctx := stats.ContextWithEmptyStats(context.Background())
+	q, err := engine.NewRangeQuery(ctx, storage, nil, `sum by (env) (rate(some_metric[5m])) * 2`, timestamp.Time(0).Add(5*time.Minute), timestamp.Time(0).Add(10*time.Minute), time.Minute)
+	require.NoError(t, err)
+	defer q.Close()
+
+	res := q.Exec(ctx)
+	require.NoError(t, res.Err)
+
+	operatorStats := queryStats.LoadOperatorStats()
+
+	for _, o := range operatorStats {
+		require.GreaterOrEqual(t, o.WallTime, time.Duration(0))
+	}
+
+	require.Greater(t, operatorStats[0].PeakEstimatedMemoryConsumptionBytes, uint64(0), "root operator should have consumed memory")
+
+	type operatorSummary struct {
+		Position         string
+		Operator         string
+		InputSeries      uint64
+		OutputSeries     uint64
+		SamplesProcessed uint64
+	}
+
+	summaries := make([]operatorSummary, 0, len(operatorStats))
+	for _, o := range operatorStats {
+		summaries = append(summaries, operatorSummary{o.Position, o.Operator, o.InputSeries, o.OutputSeries, o.SamplesProcessed})
+	}
+
+	expected := []operatorSummary{
+		{Position: "0", Operator: "DeduplicateSeries", InputSeries: 2, OutputSeries: 2, SamplesProcessed: 6 * 2},
+		{Position: "0.0", Operator: "VectorScalarBinaryOperation: *", InputSeries: 2, OutputSeries: 2, SamplesProcessed: 6*2 + 6},
+		{Position: "0.0.0", Operator: "Aggregation: sum by (env)", InputSeries: 3, OutputSeries: 2, SamplesProcessed: 6 * 3},
+		{Position: "0.0.0.0", Operator: "DeduplicateSeries", InputSeries: 3, OutputSeries: 3, SamplesProcessed: 6 * 3},
+		{Position: "0.0.0.0.0", Operator: "FunctionOverRangeVector: rate", InputSeries: 3, OutputSeries: 3, SamplesProcessed: 6 * 3 * 6}, // Each 5m range contains 6 samples.
+		{Position: "0.0.0.0.0.0", Operator: "RangeVectorSelector: [5m]", InputSeries: 0, OutputSeries: 3, SamplesProcessed: 6 * 3 * 6},
+		{Position: "0.0.1", Operator: "ScalarConstant: 2", InputSeries: 0, OutputSeries: 0, SamplesProcessed: 6},
+	}
+
+	require.Equal(t, expected, summaries)
+}
+
+func TestQueryStats_Samples(t *testing.T) {
+	storage := promqltest.LoadedStorage(t, `
+		load 1m
+			some_metric{env="prod", idx="1"} 0+1x10
+			some_metric{env="prod", idx="2"} 0+1x10
+			some_metric{env="test", idx="3"} 0+1x5
+			some_histogram{env="prod"} {{schema:0 sum:5 count:4 buckets:[1 2 1]}}+{{sum:2 count:1 buckets:[1]}}x10
+	`)
+	t.Cleanup(func() { require.NoError(t, storage.Close()) })
+
+	opts := NewTestEngineOpts()
+	prometheusEngine := promql.NewEngine(opts)
+	mimirEngine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0, 0), stats.NewQueryMetrics(nil), log.NewNopLogger())
+	require.NoError(t, err)
+
+	expressions := []string{
+		`some_metric`,
+		`sum by (env) (some_metric)`,
+		`rate(some_metric[5m])`,
+		`sum(rate(some_metric[5m])) * 2`,
+		`some_metric offset 2m`,
+		`some_histogram`,
+		`rate(some_histogram[3m])`,
+	}
+
+	run := func(t *testing.T, engine promql.QueryEngine, expr string) *promql_stats.QuerySamples {
+		start, end := timestamp.Time(0).Add(5*time.Minute), timestamp.Time(0).Add(10*time.Minute)
+		q, err := engine.NewRangeQuery(context.Background(), storage, nil, expr, start, end, time.Minute)
+		require.NoError(t, err)
+		defer q.Close()
+
+		res := q.Exec(context.Background())
+		require.NoError(t, res.Err)
+
+		return q.Stats().Samples
+	}
+
+	for _, expr := range expressions {
+		t.Run(expr, func(t *testing.T) {
+			prometheusSamples := run(t, prometheusEngine, expr)
+			mimirSamples := run(t, mimirEngine, expr)
+
+			require.Equal(t, prometheusSamples.TotalSamples, mimirSamples.TotalSamples)
+			require.Greater(t, mimirSamples.PeakSamples, 0)
+		})
+	}
+
+	t.Run("common subexpression", func(t *testing.T) {
+		// Prometheus' engine evaluates some_metric twice, but we only evaluate it once.
+		expr := `some_metric + some_metric`
+		prometheusSamples := run(t, prometheusEngine, expr)
+		mimirSamples := run(t, mimirEngine, expr)
+
+		require.Equal(t, prometheusSamples.TotalSamples/2, mimirSamples.TotalSamples)
+	})
+}
+
+func TestPrefetching(t *testing.T) {
+	storage := promqltest.LoadedStorage(t, `
+		load 1m
+			metric_a{env="1"} 0+1x10
+			metric_a{env="2"} 0+2x10
+			metric_b{env="1"} 0+3x10
+			metric_b{env="2"} 0+4x10
+			metric_c{env="1"} 0+5x10
+			metric_c{env="2"} 0+6x10
+	`)
+	t.Cleanup(func() { require.NoError(t, storage.Close()) })
+
+	testCases := map[string]struct {
+		expr                         string
+		maxConcurrentPrefetches      int
+		expectedMaxConcurrentSelects int
+	}{
+		"prefetching disabled": {
+			expr:                         `metric_a + metric_b + metric_c`,
+			maxConcurrentPrefetches:      0,
+			expectedMaxConcurrentSelects: 1,
+		},
+		"binary operations, limit allows one prefetch": {
+			expr:                         `metric_a + metric_b + metric_c`,
+			maxConcurrentPrefetches:      1,
+			expectedMaxConcurrentSelects: 2,
+		},
+		"binary operations, limit allows every sibling to be prefetched": {
+			expr:                         `metric_a + metric_b + metric_c`,
+			maxConcurrentPrefetches:      2,
+			expectedMaxConcurrentSelects: 3,
+		},
+		"binary operations, limit higher than number of siblings": {
+			expr:                         `metric_a + metric_b + metric_c`,
+			maxConcurrentPrefetches:      10,
+			expectedMaxConcurrentSelects: 3,
+		},
+		"binary operation between scalar and instant vector": {
+			expr:                         `scalar(sum(metric_a)) * metric_b`,
+			maxConcurrentPrefetches:      10,
+			expectedMaxConcurrentSelects: 2,
+		},
+		"binary operation between scalars": {
+			expr:                         `metric_a * (scalar(sum(metric_b)) * scalar(sum(metric_c)))`,
+			maxConcurrentPrefetches:      10,
+			expectedMaxConcurrentSelects: 3,
+		},
+		"function with multiple arguments": {
+			expr:                         `clamp(metric_a, scalar(min(metric_b)), scalar(max(metric_c)))`,
+			maxConcurrentPrefetches:      10,
+			expectedMaxConcurrentSelects: 3,
+		},
+		"sibling that does not select series": {
+			expr:                         `metric_a + vector(1)`,
+			maxConcurrentPrefetches:      10,
+			expectedMaxConcurrentSelects: 1,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			opts := NewTestEngineOpts()
+			prometheusEngine := promql.NewEngine(opts)
+			mimirEngine, err := NewEngine(opts, NewStaticQueryLimitsProvider(0, testCase.maxConcurrentPrefetches), stats.NewQueryMetrics(nil), log.NewNopLogger())
+			require.NoError(t, err)
+
+			queryable := &concurrencyTrackingQueryable{inner: storage, selectDuration: 100 * time.Millisecond}
+			start, end := timestamp.Time(0), timestamp.Time(0).Add(10*time.Minute)
+
+			q, err := mimirEngine.NewRangeQuery(context.Background(), queryable, nil, testCase.expr, start, end, time.Minute)
+			require.NoError(t, err)
+			defer q.Close()
+
+			res := q.Exec(context.Background())
+			require.NoError(t, res.Err)
+			require.Equal(t, testCase.expectedMaxConcurrentSelects, int(queryable.maxConcurrentSelects.Load()))
+
+			prometheusQuery, err := prometheusEngine.NewRangeQuery(context.Background(), storage, nil, testCase.expr, start, end, time.Minute)
+			require.NoError(t, err)
+			defer prometheusQuery.Close()
+
+			expected := prometheusQuery.Exec(context.Background())
+			require.NoError(t, expected.Err)
+			require.Equal(t, expected.Value, res.Value)
+		})
+	}
+}
+
+func TestPrefetching_MemoryConsumptionLimit(t *testing.T) {
+	storage := promqltest.LoadedStorage(t, `
+		load 1m
+			metric_a{env="1"} 0+1x10
+			metric_b{env="1"} 0+2x10
+	`)
+	t.Cleanup(func() { require.NoError(t, storage.Close()) })
+
+	// Each series has 11 samples, which will be rounded up to 16 (the nearest power of two) by the bucketed pool.
+	// Allow only one series to be held in memory at once, so that the query fails once both sides have been read.
+	limit := 16 * pooling.FPointSize
+
+	reg := prometheus.NewPedanticRegistry()
+	opts := NewTestEngineOpts()
+	opts.Reg = reg
+	engine, err := NewEngine(opts, NewStaticQueryLimitsProvider(limit, 2), stats.NewQueryMetrics(reg), log.NewNopLogger())
+	require.NoError(t, err)
+
+	q, err := engine.NewRangeQuery(context.Background(), storage, nil, `metric_a + metric_b`, timestamp.Time(0), timestamp.Time(0).Add(10*time.Minute), time.Minute)
+	require.NoError(t, err)
+	defer q.Close()
+
+	res := q.Exec(context.Background())
+	require.ErrorContains(t, res.Err, globalerror.MaxEstimatedMemoryConsumptionPerQuery.Error())
+
+	peakMemoryConsumptionHistogram := getHistogram(t, reg, "cortex_mimir_query_engine_estimated_query_peak_memory_consumption")
+	require.LessOrEqual(t, peakMemoryConsumptionHistogram.GetSampleSum(), float64(limit))
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(rejectedMetrics(1)), "cortex_querier_queries_rejected_total"))
+}
+
+// concurrencyTrackingQueryable records the maximum number of Select calls in progress at once.
+//
+// Each Select call takes at least selectDuration, so that calls made concurrently overlap.
+type concurrencyTrackingQueryable struct {
+	inner          storage.Queryable
+	selectDuration time.Duration
+
+	concurrentSelects    atomic.Int64
+	maxConcurrentSelects atomic.Int64
+}
+
+func (q *concurrencyTrackingQueryable) Querier(mint, maxt int64) (storage.Querier, error) {
+	innerQuerier, err := q.inner.Querier(mint, maxt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &concurrencyTrackingQuerier{queryable: q, Querier: innerQuerier}, nil
+}
+
+type concurrencyTrackingQuerier struct {
+	storage.Querier
+	queryable *concurrencyTrackingQueryable
+}
+
+func (q *concurrencyTrackingQuerier) Select(ctx context.Context, sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	concurrent := q.queryable.concurrentSelects.Inc()
+	defer q.queryable.concurrentSelects.Dec()
+
+	for {
+		m := q.queryable.maxConcurrentSelects.Load()
+		if concurrent <= m || q.queryable.maxConcurrentSelects.CompareAndSwap(m, concurrent) {
+			break
+		}
+	}
+
+	time.Sleep(q.queryable.selectDuration)
+
+	return q.Querier.Select(ctx, sortSeries, hints, matchers...)
+}
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/quantile.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package floats
+
+import (
+	"math"
+	"slices"
+)
+
+// Quantile calculates the given quantile of values.
+//
+// The quantile value is interpolated assuming a linear distribution between the values in values.
+// If values is empty or q is NaN, NaN is returned. If q < 0, -Inf is returned, and if q > 1, +Inf is returned.
+//
+// Quantile sorts values in place.
+func Quantile(q float64, values []float64) float64 {
+	if len(values) == 0 || math.IsNaN(q) {
+		return math.NaN()
+	}
+	if q < 0 {
+		return math.Inf(-1)
+	}
+	if q > 1 {
+		return math.Inf(+1)
+	}
+
+	// NaN values sort before all other values, matching Prometheus' engine.
+	slices.SortFunc(values, func(a, b float64) int {
+		switch {
+		case math.IsNaN(a) && math.IsNaN(b):
+			return 0
+		case math.IsNaN(a):
+			return -1
+		case math.IsNaN(b):
+			return 1
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	n := float64(len(values))
+	// When the q argument is NaN or outside [0, 1], it has been handled above.
+	rank := q * (n - 1)
+
+	lowerIndex := math.Max(0, math.Floor(rank))
+	upperIndex := math.Min(n-1, lowerIndex+1)
+
+	weight := rank - math.Floor(rank)
+	return values[int(lowerIndex)]*(1-weight) + values[int(upperIndex)]*weight
+}
@@ -4,6 +4,8 @@ package streamingpromql
 
 import (
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/grafana/mimir/pkg/streamingpromql/functions"
 	"github.com/grafana/mimir/pkg/streamingpromql/operators"
@@ -11,7 +13,7 @@ import (
 	"github.com/grafana/mimir/pkg/streamingpromql/types"
 )
 
-type InstantVectorFunctionOperatorFactory func(args []types.Operator, pool *pooling.LimitingPool) (types.InstantVectorOperator, error)
+type InstantVectorFunctionOperatorFactory func(args []types.Operator, pool *pooling.LimitingPool, timeRange types.QueryTimeRange) (types.InstantVectorOperator, error)
 
 // SingleInputVectorFunctionOperatorFactory creates an InstantVectorFunctionOperatorFactory for functions
 // that have exactly 1 argument (v instant-vector).
@@ -21,7 +23,7 @@ type InstantVectorFunctionOperatorFactory func(args []types.Operator, pool *pool
 //   - metadataFunc: The function for handling metadata
 //   - seriesDataFunc: The function to handle series data
 func SingleInputVectorFunctionOperatorFactory(name string, metadataFunc functions.SeriesMetadataFunction, seriesDataFunc functions.InstantVectorFunction) InstantVectorFunctionOperatorFactory {
-	return func(args []types.Operator, pool *pooling.LimitingPool) (types.InstantVectorOperator, error) {
+	return func(args []types.Operator, pool *pooling.LimitingPool, _ types.QueryTimeRange) (types.InstantVectorOperator, error) {
 		if len(args) != 1 {
 			// Should be caught by the PromQL parser, but we check here for safety.
 			return nil, fmt.Errorf("expected exactly 1 argument for %s, got %v", name, len(args))
@@ -34,6 +36,7 @@ func SingleInputVectorFunctionOperatorFactory(name string, metadataFunc function
 		}
 
 		return &operators.FunctionOverInstantVector{
+			Name:  name,
 			Inner: inner,
 			Pool:  pool,
 
@@ -50,7 +53,21 @@ func SingleInputVectorFunctionOperatorFactory(name string, metadataFunc function
 //   - name: The name of the function.
 //   - seriesDataFunc: The function to handle series data
 func TransformationFunctionOperatorFactory(name string, seriesDataFunc functions.InstantVectorFunction) InstantVectorFunctionOperatorFactory {
-	return SingleInputVectorFunctionOperatorFactory(name, functions.DropSeriesName, seriesDataFunc)
+	return deduplicateAndMergeSeries(SingleInputVectorFunctionOperatorFactory(name, functions.DropSeriesName, seriesDataFunc))
+}
+
+// deduplicateAndMergeSeries wraps the operators created by factory, which drop the series __name__ label, so that
+// the series left with the same labels are merged, or an error is returned if more than one of them has a point
+// at the same timestamp, like Prometheus' engine does.
+func deduplicateAndMergeSeries(factory InstantVectorFunctionOperatorFactory) InstantVectorFunctionOperatorFactory {
+	return func(args []types.Operator, pool *pooling.LimitingPool, timeRange types.QueryTimeRange) (types.InstantVectorOperator, error) {
+		o, err := factory(args, pool, timeRange)
+		if err != nil {
+			return nil, err
+		}
+
+		return operators.NewDeduplicateAndMergeSeries(o, pool), nil
+	}
 }
 
 // LabelManipulationFunctionOperatorFactory creates an InstantVectorFunctionOperator for functions
@@ -69,52 +86,503 @@ func LabelManipulationFunctionOperatorFactory(name string, metadataFunc function
 	return SingleInputVectorFunctionOperatorFactory(name, metadataFunc, functions.Passthrough)
 }
 
-func createRateFunctionOperator(args []types.Operator, pool *pooling.LimitingPool) (types.InstantVectorOperator, error) {
+// FunctionOverRangeVectorOperatorFactory creates an InstantVectorFunctionOperatorFactory for functions
+// that have exactly 1 argument (v range-vector).
+//
+// Parameters:
+//   - name: The name of the function.
+//   - metadataFunc: The function for handling metadata
+//   - stepFunc: The function to compute the result for each time step
+func FunctionOverRangeVectorOperatorFactory(name string, metadataFunc functions.SeriesMetadataFunction, stepFunc functions.RangeVectorStepFunction) InstantVectorFunctionOperatorFactory {
+	return func(args []types.Operator, pool *pooling.LimitingPool, _ types.QueryTimeRange) (types.InstantVectorOperator, error) {
+		if len(args) != 1 {
+			// Should be caught by the PromQL parser, but we check here for safety.
+			return nil, fmt.Errorf("expected exactly 1 argument for %s, got %v", name, len(args))
+		}
+
+		inner, ok := args[0].(types.RangeVectorOperator)
+		if !ok {
+			// Should be caught by the PromQL parser, but we check here for safety.
+			return nil, fmt.Errorf("expected a range vector argument for %s, got %T", name, args[0])
+		}
+
+		return &operators.FunctionOverRangeVector{
+			Name:  name,
+			Inner: inner,
+			Pool:  pool,
+
+			MetadataFunc: metadataFunc,
+			StepFunc:     stepFunc,
+		}, nil
+	}
+}
+
+// RangeVectorTransformationFunctionOperatorFactory creates an InstantVectorFunctionOperatorFactory for functions
+// that have exactly 1 argument (v range-vector), and drop the series __name__ label.
+//
+// Parameters:
+//   - name: The name of the function.
+//   - stepFunc: The function to compute the result for each time step
+func RangeVectorTransformationFunctionOperatorFactory(name string, stepFunc functions.RangeVectorStepFunction) InstantVectorFunctionOperatorFactory {
+	return deduplicateSeries(FunctionOverRangeVectorOperatorFactory(name, functions.DropSeriesName, stepFunc))
+}
+
+// deduplicateSeries wraps the operators created by factory, which drop the series __name__ label, so that an error
+// is returned if more than one of the series left with the same labels has points, like Prometheus' engine does
+// for the functions over range vectors.
+func deduplicateSeries(factory InstantVectorFunctionOperatorFactory) InstantVectorFunctionOperatorFactory {
+	return func(args []types.Operator, pool *pooling.LimitingPool, timeRange types.QueryTimeRange) (types.InstantVectorOperator, error) {
+		o, err := factory(args, pool, timeRange)
+		if err != nil {
+			return nil, err
+		}
+
+		return operators.NewDeduplicateSeries(o, pool), nil
+	}
+}
+
+// RangeVectorWithScalarFunctionOperatorFactory creates an InstantVectorFunctionOperatorFactory for functions
+// that have exactly 2 arguments (one range-vector and one scalar, in either order), and drop the series __name__ label.
+//
+// Parameters:
+//   - name: The name of the function.
+//   - rangeVectorArgIndex: The index of the range vector argument
+//   - stepFunc: The function to compute the result for each time step, which receives the scalar argument as its only scalar argument
+func RangeVectorWithScalarFunctionOperatorFactory(name string, rangeVectorArgIndex int, stepFunc functions.RangeVectorStepFunction) InstantVectorFunctionOperatorFactory {
+	return func(args []types.Operator, pool *pooling.LimitingPool, _ types.QueryTimeRange) (types.InstantVectorOperator, error) {
+		if len(args) != 2 {
+			// Should be caught by the PromQL parser, but we check here for safety.
+			return nil, fmt.Errorf("expected exactly 2 arguments for %s, got %v", name, len(args))
+		}
+
+		inner, ok := args[rangeVectorArgIndex].(types.RangeVectorOperator)
+		if !ok {
+			// Should be caught by the PromQL parser, but we check here for safety.
+			return nil, fmt.Errorf("expected a range vector argument for %s, got %T", name, args[rangeVectorArgIndex])
+		}
+
+		scalarArgIndex := 1 - rangeVectorArgIndex
+		scalarArg, ok := args[scalarArgIndex].(types.ScalarOperator)
+		if !ok {
+			// Should be caught by the PromQL parser, but we check here for safety.
+			return nil, fmt.Errorf("expected a scalar argument for %s, got %T", name, args[scalarArgIndex])
+		}
+
+		f := &operators.FunctionOverRangeVector{
+			Name:       name,
+			Inner:      inner,
+			ScalarArgs: []types.ScalarOperator{scalarArg},
+			Pool:       pool,
+
+			MetadataFunc: functions.DropSeriesName,
+			StepFunc:     stepFunc,
+		}
+
+		return operators.NewDeduplicateSeries(f, pool), nil
+	}
+}
+
+// HistogramFunctionOperatorFactory creates an InstantVectorFunctionOperatorFactory for functions
+// that have one or more scalar arguments followed by a single instant vector of classic or native histograms,
+// such as histogram_quantile and histogram_fraction.
+//
+// Parameters:
+//   - name: The name of the function.
+//   - scalarArgCount: The number of scalar arguments before the instant vector argument
+//   - classicHistogramFunc: The function to compute the result for a classic histogram at each time step
+//   - nativeHistogramFunc: The function to compute the result for a native histogram at each time step
+func HistogramFunctionOperatorFactory(name string, scalarArgCount int, classicHistogramFunc functions.ClassicHistogramFunction, nativeHistogramFunc functions.NativeHistogramFunction) InstantVectorFunctionOperatorFactory {
+	return func(args []types.Operator, pool *pooling.LimitingPool, _ types.QueryTimeRange) (types.InstantVectorOperator, error) {
+		if len(args) != scalarArgCount+1 {
+			// Should be caught by the PromQL parser, but we check here for safety.
+			return nil, fmt.Errorf("expected exactly %v arguments for %s, got %v", scalarArgCount+1, name, len(args))
+		}
+
+		scalarArgs := make([]types.ScalarOperator, 0, scalarArgCount)
+		for _, arg := range args[:scalarArgCount] {
+			scalarArg, ok := arg.(types.ScalarOperator)
+			if !ok {
+				// Should be caught by the PromQL parser, but we check here for safety.
+				return nil, fmt.Errorf("expected a scalar argument for %s, got %T", name, arg)
+			}
+
+			scalarArgs = append(scalarArgs, scalarArg)
+		}
+
+		inner, ok := args[scalarArgCount].(types.InstantVectorOperator)
+		if !ok {
+			// Should be caught by the PromQL parser, but we check here for safety.
+			return nil, fmt.Errorf("expected an instant vector argument for %s, got %T", name, args[scalarArgCount])
+		}
+
+		f := &operators.HistogramFunction{
+			Name:       name,
+			Inner:      inner,
+			ScalarArgs: scalarArgs,
+			Pool:       pool,
+
+			ClassicHistogramFunc: classicHistogramFunc,
+			NativeHistogramFunc:  nativeHistogramFunc,
+		}
+
+		// Histograms with different names but otherwise identical labels produce series with the same labels.
+		return operators.NewDeduplicateAndMergeSeries(f, pool), nil
+	}
+}
+
+// TransformationWithScalarArgsFunctionOperatorFactory creates an InstantVectorFunctionOperatorFactory for functions
+// that have an instant vector argument followed by between minScalarArgCount and maxScalarArgCount scalar arguments,
+// and drop the series __name__ label.
+//
+// Parameters:
+//   - name: The name of the function.
+//   - minScalarArgCount: The minimum number of scalar arguments
+//   - maxScalarArgCount: The maximum number of scalar arguments
+//   - seriesDataFunc: The function to handle series data, which receives the values of the scalar arguments
+func TransformationWithScalarArgsFunctionOperatorFactory(name string, minScalarArgCount int, maxScalarArgCount int, seriesDataFunc functions.InstantVectorFunction) InstantVectorFunctionOperatorFactory {
+	return func(args []types.Operator, pool *pooling.LimitingPool, _ types.QueryTimeRange) (types.InstantVectorOperator, error) {
+		if len(args) < minScalarArgCount+1 || len(args) > maxScalarArgCount+1 {
+			// Should be caught by the PromQL parser, but we check here for safety.
+			return nil, fmt.Errorf("expected between %v and %v arguments for %s, got %v", minScalarArgCount+1, maxScalarArgCount+1, name, len(args))
+		}
+
+		inner, ok := args[0].(types.InstantVectorOperator)
+		if !ok {
+			// Should be caught by the PromQL parser, but we check here for safety.
+			return nil, fmt.Errorf("expected an instant vector argument for %s, got %T", name, args[0])
+		}
+
+		scalarArgs := make([]types.ScalarOperator, 0, len(args)-1)
+		for _, arg := range args[1:] {
+			scalarArg, ok := arg.(types.ScalarOperator)
+			if !ok {
+				// Should be caught by the PromQL parser, but we check here for safety.
+				return nil, fmt.Errorf("expected a scalar argument for %s, got %T", name, arg)
+			}
+
+			scalarArgs = append(scalarArgs, scalarArg)
+		}
+
+		f := &operators.FunctionOverInstantVector{
+			Name:       name,
+			Inner:      inner,
+			ScalarArgs: scalarArgs,
+			Pool:       pool,
+
+			MetadataFunc:   functions.DropSeriesName,
+			SeriesDataFunc: seriesDataFunc,
+		}
+
+		return operators.NewDeduplicateAndMergeSeries(f, pool), nil
+	}
+}
+
+// DateFunctionOperatorFactory creates an InstantVectorFunctionOperatorFactory for functions that have an optional
+// instant vector argument, and drop the series __name__ label, such as hour and month.
+//
+// If the instant vector argument is omitted, the function is evaluated over vector(time()).
+//
+// Parameters:
+//   - name: The name of the function.
+//   - seriesDataFunc: The function to handle series data
+func DateFunctionOperatorFactory(name string, seriesDataFunc functions.InstantVectorFunction) InstantVectorFunctionOperatorFactory {
+	return func(args []types.Operator, pool *pooling.LimitingPool, timeRange types.QueryTimeRange) (types.InstantVectorOperator, error) {
+		var inner types.InstantVectorOperator
+
+		switch len(args) {
+		case 0:
+			inner = &operators.ScalarToInstantVector{
+				Scalar: operators.NewTimeFunction(timeRange.Start, timeRange.End, timeRange.Interval, pool),
+			}
+		case 1:
+			var ok bool
+			inner, ok = args[0].(types.InstantVectorOperator)
+			if !ok {
+				// Should be caught by the PromQL parser, but we check here for safety.
+				return nil, fmt.Errorf("expected an instant vector argument for %s, got %T", name, args[0])
+			}
+		default:
+			// Should be caught by the PromQL parser, but we check here for safety.
+			return nil, fmt.Errorf("expected 0 or 1 arguments for %s, got %v", name, len(args))
+		}
+
+		f := &operators.FunctionOverInstantVector{
+			Name:  name,
+			Inner: inner,
+			Pool:  pool,
+
+			MetadataFunc:   functions.DropSeriesName,
+			SeriesDataFunc: seriesDataFunc,
+		}
+
+		return operators.NewDeduplicateAndMergeSeries(f, pool), nil
+	}
+}
+
+// SortFunctionOperatorFactory creates an InstantVectorFunctionOperatorFactory for sort and sort_desc.
+//
+// Parameters:
+//   - name: The name of the function.
+//   - descending: Whether to sort series in descending order of value
+func SortFunctionOperatorFactory(name string, descending bool) InstantVectorFunctionOperatorFactory {
+	return func(args []types.Operator, pool *pooling.LimitingPool, timeRange types.QueryTimeRange) (types.InstantVectorOperator, error) {
+		if len(args) != 1 {
+			// Should be caught by the PromQL parser, but we check here for safety.
+			return nil, fmt.Errorf("expected exactly 1 argument for %s, got %v", name, len(args))
+		}
+
+		inner, ok := args[0].(types.InstantVectorOperator)
+		if !ok {
+			// Should be caught by the PromQL parser, but we check here for safety.
+			return nil, fmt.Errorf("expected an instant vector argument for %s, got %T", name, args[0])
+		}
+
+		if !timeRange.Start.Equal(timeRange.End) {
+			// Sorting only affects the order of series at a single time step: the results of range queries are
+			// always sorted by labels, so there's nothing to do.
+			return inner, nil
+		}
+
+		return operators.NewSort(inner, descending, pool), nil
+	}
+}
+
+func createTimestampFunctionOperator(args []types.Operator, pool *pooling.LimitingPool, _ types.QueryTimeRange) (types.InstantVectorOperator, error) {
 	if len(args) != 1 {
 		// Should be caught by the PromQL parser, but we check here for safety.
-		return nil, fmt.Errorf("expected exactly 1 argument for rate, got %v", len(args))
+		return nil, fmt.Errorf("expected exactly 1 argument for timestamp, got %v", len(args))
+	}
+
+	inner, ok := args[0].(types.InstantVectorOperator)
+	if !ok {
+		// Should be caught by the PromQL parser, but we check here for safety.
+		return nil, fmt.Errorf("expected an instant vector argument for timestamp, got %T", args[0])
+	}
+
+	seriesDataFunc := functions.Timestamp
+
+	if selector, ok := operators.Uninstrumented(inner).(*operators.InstantVectorSelector); ok {
+		// timestamp() over a vector selector returns the timestamp of each selected sample, rather than the
+		// timestamp of each time step, so the selector needs to return the sample timestamps.
+		selector.ReturnSampleTimestamps = true
+		seriesDataFunc = functions.Passthrough
+	}
+
+	f := &operators.FunctionOverInstantVector{
+		Name:  "timestamp",
+		Inner: inner,
+		Pool:  pool,
+
+		MetadataFunc:   functions.DropSeriesName,
+		SeriesDataFunc: seriesDataFunc,
+	}
+
+	return operators.NewDeduplicateAndMergeSeries(f, pool), nil
+}
+
+func createLabelJoinFunctionOperator(args []types.Operator, pool *pooling.LimitingPool, _ types.QueryTimeRange) (types.InstantVectorOperator, error) {
+	if len(args) < 3 {
+		// Should be caught by the PromQL parser, but we check here for safety.
+		return nil, fmt.Errorf("expected at least 3 arguments for label_join, got %v", len(args))
+	}
+
+	inner, ok := args[0].(types.InstantVectorOperator)
+	if !ok {
+		// Should be caught by the PromQL parser, but we check here for safety.
+		return nil, fmt.Errorf("expected an instant vector argument for label_join, got %T", args[0])
+	}
+
+	stringArgs, err := stringArgs("label_join", args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	f := &operators.FunctionOverInstantVector{
+		Name:  "label_join",
+		Inner: inner,
+		Pool:  pool,
+
+		MetadataFunc:   functions.LabelJoinFactory(stringArgs[0], stringArgs[1], stringArgs[2:]),
+		SeriesDataFunc: functions.Passthrough,
+	}
+
+	return operators.NewDeduplicateSeries(f, pool), nil
+}
+
+func createLabelReplaceFunctionOperator(args []types.Operator, pool *pooling.LimitingPool, _ types.QueryTimeRange) (types.InstantVectorOperator, error) {
+	if len(args) != 5 {
+		// Should be caught by the PromQL parser, but we check here for safety.
+		return nil, fmt.Errorf("expected exactly 5 arguments for label_replace, got %v", len(args))
 	}
 
-	inner, ok := args[0].(types.RangeVectorOperator)
+	inner, ok := args[0].(types.InstantVectorOperator)
 	if !ok {
 		// Should be caught by the PromQL parser, but we check here for safety.
-		return nil, fmt.Errorf("expected a range vector argument for rate, got %T", args[0])
+		return nil, fmt.Errorf("expected an instant vector argument for label_replace, got %T", args[0])
+	}
+
+	stringArgs, err := stringArgs("label_replace", args[1:])
+	if err != nil {
+		return nil, err
 	}
 
-	return &operators.FunctionOverRangeVector{
+	f := &operators.FunctionOverInstantVector{
+		Name:  "label_replace",
 		Inner: inner,
 		Pool:  pool,
+
+		MetadataFunc:   functions.LabelReplaceFactory(stringArgs[0], stringArgs[1], stringArgs[2], stringArgs[3]),
+		SeriesDataFunc: functions.Passthrough,
+	}
+
+	return operators.NewDeduplicateSeries(f, pool), nil
+}
+
+func stringArgs(name string, args []types.Operator) ([]types.StringOperator, error) {
+	stringArgs := make([]types.StringOperator, 0, len(args))
+
+	for _, arg := range args {
+		stringArg, ok := arg.(types.StringOperator)
+		if !ok {
+			// Should be caught by the PromQL parser, but we check here for safety.
+			return nil, fmt.Errorf("expected a string argument for %s, got %T", name, arg)
+		}
+
+		stringArgs = append(stringArgs, stringArg)
+	}
+
+	return stringArgs, nil
+}
+
+func createVectorFunctionOperator(args []types.Operator, _ *pooling.LimitingPool, _ types.QueryTimeRange) (types.InstantVectorOperator, error) {
+	if len(args) != 1 {
+		// Should be caught by the PromQL parser, but we check here for safety.
+		return nil, fmt.Errorf("expected exactly 1 argument for vector, got %v", len(args))
+	}
+
+	inner, ok := args[0].(types.ScalarOperator)
+	if !ok {
+		// Should be caught by the PromQL parser, but we check here for safety.
+		return nil, fmt.Errorf("expected a scalar argument for vector, got %T", args[0])
+	}
+
+	return &operators.ScalarToInstantVector{
+		Scalar: inner,
 	}, nil
 }
 
 // These functions return an instant-vector.
 var instantVectorFunctionOperatorFactories = map[string]InstantVectorFunctionOperatorFactory{
-	"abs":             TransformationFunctionOperatorFactory("abs", functions.Abs),
-	"acos":            TransformationFunctionOperatorFactory("acos", functions.Acos),
-	"acosh":           TransformationFunctionOperatorFactory("acosh", functions.Acosh),
-	"asin":            TransformationFunctionOperatorFactory("asin", functions.Asin),
-	"asinh":           TransformationFunctionOperatorFactory("asinh", functions.Asinh),
-	"atan":            TransformationFunctionOperatorFactory("atan", functions.Atan),
-	"atanh":           TransformationFunctionOperatorFactory("atanh", functions.Atanh),
-	"ceil":            TransformationFunctionOperatorFactory("ceil", functions.Ceil),
-	"cos":             TransformationFunctionOperatorFactory("cos", functions.Cos),
-	"cosh":            TransformationFunctionOperatorFactory("cosh", functions.Cosh),
-	"deg":             TransformationFunctionOperatorFactory("deg", functions.Deg),
-	"exp":             TransformationFunctionOperatorFactory("exp", functions.Exp),
-	"floor":           TransformationFunctionOperatorFactory("floor", functions.Floor),
-	"histogram_count": TransformationFunctionOperatorFactory("histogram_count", functions.HistogramCount),
-	"histogram_sum":   TransformationFunctionOperatorFactory("histogram_sum", functions.HistogramSum),
-	"ln":              TransformationFunctionOperatorFactory("ln", functions.Ln),
-	"log10":           TransformationFunctionOperatorFactory("log10", functions.Log10),
-	"log2":            TransformationFunctionOperatorFactory("log2", functions.Log2),
-	"rad":             TransformationFunctionOperatorFactory("rad", functions.Rad),
-	"rate":            createRateFunctionOperator,
-	"sgn":             TransformationFunctionOperatorFactory("sgn", functions.Sgn),
-	"sin":             TransformationFunctionOperatorFactory("sin", functions.Sin),
-	"sinh":            TransformationFunctionOperatorFactory("sinh", functions.Sinh),
-	"sqrt":            TransformationFunctionOperatorFactory("sqrt", functions.Sqrt),
-	"tan":             TransformationFunctionOperatorFactory("tan", functions.Tan),
-	"tanh":            TransformationFunctionOperatorFactory("tanh", functions.Tanh),
+	"abs":                TransformationFunctionOperatorFactory("abs", functions.Abs),
+	"acos":               TransformationFunctionOperatorFactory("acos", functions.Acos),
+	"acosh":              TransformationFunctionOperatorFactory("acosh", functions.Acosh),
+	"asin":               TransformationFunctionOperatorFactory("asin", functions.Asin),
+	"asinh":              TransformationFunctionOperatorFactory("asinh", functions.Asinh),
+	"atan":               TransformationFunctionOperatorFactory("atan", functions.Atan),
+	"atanh":              TransformationFunctionOperatorFactory("atanh", functions.Atanh),
+	"avg_over_time":      RangeVectorTransformationFunctionOperatorFactory("avg_over_time", functions.AvgOverTime),
+	"ceil":               TransformationFunctionOperatorFactory("ceil", functions.Ceil),
+	"changes":            RangeVectorTransformationFunctionOperatorFactory("changes", functions.Changes),
+	"clamp":              TransformationWithScalarArgsFunctionOperatorFactory("clamp", 2, 2, functions.Clamp),
+	"clamp_max":          TransformationWithScalarArgsFunctionOperatorFactory("clamp_max", 1, 1, functions.ClampMax),
+	"clamp_min":          TransformationWithScalarArgsFunctionOperatorFactory("clamp_min", 1, 1, functions.ClampMin),
+	"cos":                TransformationFunctionOperatorFactory("cos", functions.Cos),
+	"cosh":               TransformationFunctionOperatorFactory("cosh", functions.Cosh),
+	"count_over_time":    RangeVectorTransformationFunctionOperatorFactory("count_over_time", functions.CountOverTime),
+	"day_of_month":       DateFunctionOperatorFactory("day_of_month", functions.DayOfMonth),
+	"day_of_week":        DateFunctionOperatorFactory("day_of_week", functions.DayOfWeek),
+	"day_of_year":        DateFunctionOperatorFactory("day_of_year", functions.DayOfYear),
+	"days_in_month":      DateFunctionOperatorFactory("days_in_month", functions.DaysInMonth),
+	"deg":                TransformationFunctionOperatorFactory("deg", functions.Deg),
+	"delta":              RangeVectorTransformationFunctionOperatorFactory("delta", functions.Delta),
+	"deriv":              RangeVectorTransformationFunctionOperatorFactory("deriv", functions.Deriv),
+	"exp":                TransformationFunctionOperatorFactory("exp", functions.Exp),
+	"floor":              TransformationFunctionOperatorFactory("floor", functions.Floor),
+	"histogram_avg":      TransformationFunctionOperatorFactory("histogram_avg", functions.HistogramAvg),
+	"histogram_count":    TransformationFunctionOperatorFactory("histogram_count", functions.HistogramCount),
+	"histogram_fraction": HistogramFunctionOperatorFactory("histogram_fraction", 2, functions.ClassicHistogramFraction, functions.NativeHistogramFraction),
+	"histogram_quantile": HistogramFunctionOperatorFactory("histogram_quantile", 1, functions.ClassicHistogramQuantile, functions.NativeHistogramQuantile),
+	"histogram_stddev":   TransformationFunctionOperatorFactory("histogram_stddev", functions.HistogramStdDev),
+	"histogram_stdvar":   TransformationFunctionOperatorFactory("histogram_stdvar", functions.HistogramStdVar),
+	"histogram_sum":      TransformationFunctionOperatorFactory("histogram_sum", functions.HistogramSum),
+	"hour":               DateFunctionOperatorFactory("hour", functions.Hour),
+	"idelta":             RangeVectorTransformationFunctionOperatorFactory("idelta", functions.Idelta),
+	"increase":           RangeVectorTransformationFunctionOperatorFactory("increase", functions.Increase),
+	"irate":              RangeVectorTransformationFunctionOperatorFactory("irate", functions.Irate),
+	"label_join":         createLabelJoinFunctionOperator,
+	"label_replace":      createLabelReplaceFunctionOperator,
+	"last_over_time":     FunctionOverRangeVectorOperatorFactory("last_over_time", functions.PassthroughSeriesMetadata, functions.LastOverTime),
+	"ln":                 TransformationFunctionOperatorFactory("ln", functions.Ln),
+	"log10":              TransformationFunctionOperatorFactory("log10", functions.Log10),
+	"log2":               TransformationFunctionOperatorFactory("log2", functions.Log2),
+	"mad_over_time":      RangeVectorTransformationFunctionOperatorFactory("mad_over_time", functions.MadOverTime),
+	"max_over_time":      RangeVectorTransformationFunctionOperatorFactory("max_over_time", functions.MaxOverTime),
+	"min_over_time":      RangeVectorTransformationFunctionOperatorFactory("min_over_time", functions.MinOverTime),
+	"minute":             DateFunctionOperatorFactory("minute", functions.Minute),
+	"month":              DateFunctionOperatorFactory("month", functions.Month),
+	"predict_linear":     RangeVectorWithScalarFunctionOperatorFactory("predict_linear", 0, functions.PredictLinear),
+	"present_over_time":  RangeVectorTransformationFunctionOperatorFactory("present_over_time", functions.PresentOverTime),
+	"quantile_over_time": RangeVectorWithScalarFunctionOperatorFactory("quantile_over_time", 1, functions.QuantileOverTime),
+	"rad":                TransformationFunctionOperatorFactory("rad", functions.Rad),
+	"rate":               RangeVectorTransformationFunctionOperatorFactory("rate", functions.Rate),
+	"resets":             RangeVectorTransformationFunctionOperatorFactory("resets", functions.Resets),
+	"round":              TransformationWithScalarArgsFunctionOperatorFactory("round", 0, 1, functions.Round),
+	"sgn":                TransformationFunctionOperatorFactory("sgn", functions.Sgn),
+	"sin":                TransformationFunctionOperatorFactory("sin", functions.Sin),
+	"sinh":               TransformationFunctionOperatorFactory("sinh", functions.Sinh),
+	"sort":               SortFunctionOperatorFactory("sort", false),
+	"sort_desc":          SortFunctionOperatorFactory("sort_desc", true),
+	"sqrt":               TransformationFunctionOperatorFactory("sqrt", functions.Sqrt),
+	"stddev_over_time":   RangeVectorTransformationFunctionOperatorFactory("stddev_over_time", functions.StddevOverTime),
+	"stdvar_over_time":   RangeVectorTransformationFunctionOperatorFactory("stdvar_over_time", functions.StdvarOverTime),
+	"sum_over_time":      RangeVectorTransformationFunctionOperatorFactory("sum_over_time", functions.SumOverTime),
+	"tan":                TransformationFunctionOperatorFactory("tan", functions.Tan),
+	"tanh":               TransformationFunctionOperatorFactory("tanh", functions.Tanh),
+	"timestamp":          createTimestampFunctionOperator,
+	"vector":             createVectorFunctionOperator,
+	"year":               DateFunctionOperatorFactory("year", functions.Year),
+}
+
+type ScalarFunctionOperatorFactory func(args []types.Operator, start time.Time, end time.Time, interval time.Duration, pool *pooling.LimitingPool) (types.ScalarOperator, error)
+
+func createPiFunctionOperator(args []types.Operator, start time.Time, end time.Time, interval time.Duration, pool *pooling.LimitingPool) (types.ScalarOperator, error) {
+	if len(args) != 0 {
+		// Should be caught by the PromQL parser, but we check here for safety.
+		return nil, fmt.Errorf("expected no arguments for pi, got %v", len(args))
+	}
+
+	return operators.NewScalarConstant(math.Pi, start, end, interval, pool), nil
+}
+
+func createScalarFunctionOperator(args []types.Operator, start time.Time, end time.Time, interval time.Duration, pool *pooling.LimitingPool) (types.ScalarOperator, error) {
+	if len(args) != 1 {
+		// Should be caught by the PromQL parser, but we check here for safety.
+		return nil, fmt.Errorf("expected exactly 1 argument for scalar, got %v", len(args))
+	}
+
+	inner, ok := args[0].(types.InstantVectorOperator)
+	if !ok {
+		// Should be caught by the PromQL parser, but we check here for safety.
+		return nil, fmt.Errorf("expected an instant vector argument for scalar, got %T", args[0])
+	}
+
+	return operators.NewInstantVectorToScalar(inner, start, end, interval, pool), nil
+}
+
+func createTimeFunctionOperator(args []types.Operator, start time.Time, end time.Time, interval time.Duration, pool *pooling.LimitingPool) (types.ScalarOperator, error) {
+	if len(args) != 0 {
+		// Should be caught by the PromQL parser, but we check here for safety.
+		return nil, fmt.Errorf("expected no arguments for time, got %v", len(args))
+	}
+
+	return operators.NewTimeFunction(start, end, interval, pool), nil
+}
+
+// These functions return a scalar.
+var scalarFunctionOperatorFactories = map[string]ScalarFunctionOperatorFactory{
+	"pi":     createPiFunctionOperator,
+	"scalar": createScalarFunctionOperator,
+	"time":   createTimeFunctionOperator,
 }
 
 func RegisterInstantVectorFunctionOperatorFactory(functionName string, factory InstantVectorFunctionOperatorFactory) error {
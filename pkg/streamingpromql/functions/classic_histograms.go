@@ -0,0 +1,266 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/quantile.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package functions
+
+import (
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/prometheus/prometheus/util/almost"
+)
+
+// smallDeltaTolerance is the threshold for relative deltas between classic
+// histogram buckets that will be ignored by the histogram_quantile function
+// because they are most likely artifacts of floating point precision issues.
+// See the corresponding constant in Prometheus' engine for more details.
+const smallDeltaTolerance = 1e-12
+
+// Bucket is a single bucket of a classic histogram: the value of a series with an 'le' label.
+type Bucket struct {
+	UpperBound float64
+	Count      float64
+}
+
+// ClassicHistogramQuantile is the ClassicHistogramFunction for histogram_quantile.
+func ClassicHistogramQuantile(scalarArgs []float64, buckets []Bucket) float64 {
+	return bucketQuantile(scalarArgs[0], buckets)
+}
+
+// ClassicHistogramFraction is the ClassicHistogramFunction for histogram_fraction.
+//
+// Note that Prometheus' engine only supports histogram_fraction for native histograms.
+func ClassicHistogramFraction(scalarArgs []float64, buckets []Bucket) float64 {
+	return bucketFraction(scalarArgs[0], scalarArgs[1], buckets)
+}
+
+// bucketQuantile calculates the quantile 'q' based on the given buckets. The
+// buckets will be sorted by UpperBound by this function (i.e. no sorting
+// needed before calling this function). The quantile value is interpolated
+// assuming a linear distribution within a bucket. However, if the quantile
+// falls into the highest bucket, the upper bound of the 2nd highest bucket is
+// returned. A natural lower bound of 0 is assumed if the upper bound of the
+// lowest bucket is greater 0. In that case, interpolation in the lowest bucket
+// happens linearly between 0 and the upper bound of the lowest bucket.
+// However, if the lowest bucket has an upper bound less or equal 0, this upper
+// bound is returned if the quantile falls into the lowest bucket.
+//
+// There are a number of special cases:
+//
+// If 'buckets' has 0 observations, NaN is returned.
+//
+// If 'buckets' has fewer than 2 elements, NaN is returned.
+//
+// If the highest bucket is not +Inf, NaN is returned.
+//
+// If q==NaN, NaN is returned.
+//
+// If q<0, -Inf is returned.
+//
+// If q>1, +Inf is returned.
+func bucketQuantile(q float64, buckets []Bucket) float64 {
+	if math.IsNaN(q) {
+		return math.NaN()
+	}
+	if q < 0 {
+		return math.Inf(-1)
+	}
+	if q > 1 {
+		return math.Inf(+1)
+	}
+
+	buckets, ok := prepareBuckets(buckets)
+	if !ok || len(buckets) < 2 {
+		return math.NaN()
+	}
+
+	observations := buckets[len(buckets)-1].Count
+	if observations == 0 {
+		return math.NaN()
+	}
+	rank := q * observations
+	b := sort.Search(len(buckets)-1, func(i int) bool { return buckets[i].Count >= rank })
+
+	if b == len(buckets)-1 {
+		return buckets[len(buckets)-2].UpperBound
+	}
+	if b == 0 && buckets[0].UpperBound <= 0 {
+		return buckets[0].UpperBound
+	}
+	var (
+		bucketStart float64
+		bucketEnd   = buckets[b].UpperBound
+		count       = buckets[b].Count
+	)
+	if b > 0 {
+		bucketStart = buckets[b-1].UpperBound
+		count -= buckets[b-1].Count
+		rank -= buckets[b-1].Count
+	}
+	return bucketStart + (bucketEnd-bucketStart)*(rank/count)
+}
+
+// bucketFraction calculates the fraction of observations between the provided
+// lower and upper bounds, based on the given buckets. The buckets will be sorted
+// by UpperBound by this function.
+//
+// bucketFraction is the inverse of bucketQuantile: the same assumptions are made
+// about the distribution of observations within each bucket, so if
+// bucketQuantile(0.9, buckets) returns 123.4, then
+// bucketFraction(-Inf, 123.4, buckets) returns 0.9.
+//
+// If 'buckets' has 0 observations, lower or upper is NaN, or the highest bucket
+// is not +Inf, NaN is returned.
+//
+// If lower >= upper and the buckets have at least 1 observation, zero is returned.
+func bucketFraction(lower, upper float64, buckets []Bucket) float64 {
+	if math.IsNaN(lower) || math.IsNaN(upper) {
+		return math.NaN()
+	}
+
+	buckets, ok := prepareBuckets(buckets)
+	if !ok {
+		return math.NaN()
+	}
+
+	observations := buckets[len(buckets)-1].Count
+	if observations == 0 {
+		return math.NaN()
+	}
+	if lower >= upper {
+		return 0
+	}
+
+	return (bucketRank(upper, buckets) - bucketRank(lower, buckets)) / observations
+}
+
+// bucketRank returns the estimated number of observations less than or equal to v.
+//
+// buckets must have been prepared with prepareBuckets.
+func bucketRank(v float64, buckets []Bucket) float64 {
+	rank := 0.0 // The number of observations in all buckets below the current bucket.
+
+	for i, b := range buckets {
+		if v >= b.UpperBound {
+			rank = b.Count
+			continue
+		}
+
+		// v falls in this bucket.
+		lowerBound := math.Inf(-1)
+		if i > 0 {
+			lowerBound = buckets[i-1].UpperBound
+		} else if b.UpperBound > 0 {
+			// Assume a natural lower bound of 0, as bucketQuantile does.
+			lowerBound = 0
+		}
+
+		if v <= lowerBound {
+			return rank
+		}
+
+		if math.IsInf(b.UpperBound, +1) {
+			// bucketQuantile assumes observations in the +Inf bucket are at its lower bound, so do the same here.
+			return b.Count
+		}
+
+		if math.IsInf(lowerBound, -1) {
+			// bucketQuantile assumes observations in the lowest bucket are at its upper bound if the upper bound is
+			// less than or equal to 0, so do the same here.
+			return rank
+		}
+
+		return rank + (b.Count-rank)*(v-lowerBound)/(b.UpperBound-lowerBound)
+	}
+
+	return rank
+}
+
+// prepareBuckets sorts buckets, merges buckets with the same upper bound and
+// ensures bucket counts are monotonic.
+//
+// It returns false if the highest bucket is not +Inf.
+func prepareBuckets(buckets []Bucket) ([]Bucket, bool) {
+	slices.SortFunc(buckets, func(a, b Bucket) int {
+		// We don't expect the bucket boundary to be a NaN.
+		if a.UpperBound < b.UpperBound {
+			return -1
+		}
+		if a.UpperBound > b.UpperBound {
+			return +1
+		}
+		return 0
+	})
+	if !math.IsInf(buckets[len(buckets)-1].UpperBound, +1) {
+		return nil, false
+	}
+
+	buckets = coalesceBuckets(buckets)
+	ensureMonotonicAndIgnoreSmallDeltas(buckets, smallDeltaTolerance)
+
+	return buckets, true
+}
+
+// coalesceBuckets merges buckets with the same upper bound.
+//
+// The input buckets must be sorted.
+func coalesceBuckets(buckets []Bucket) []Bucket {
+	last := buckets[0]
+	i := 0
+	for _, b := range buckets[1:] {
+		if b.UpperBound == last.UpperBound {
+			last.Count += b.Count
+		} else {
+			buckets[i] = last
+			last = b
+			i++
+		}
+	}
+	buckets[i] = last
+	return buckets[:i+1]
+}
+
+// ensureMonotonicAndIgnoreSmallDeltas ensures bucket counts increase
+// monotonically with increasing UpperBound.
+//
+// This assumption may be violated if the data is inconsistent at the source, or
+// if floating point precision errors accumulate (for example, when query
+// sharding is used).
+//
+// bucketQuantile depends on that monotonicity to do a binary search for the
+// bucket with the φ-quantile count, so breaking the monotonicity
+// guarantee causes bucketQuantile() to return undefined (nonsense) results.
+//
+// As a somewhat hacky solution, we first silently ignore any numerically
+// insignificant (relative delta below the requested tolerance and likely to
+// be from floating point precision errors) differences between successive
+// buckets regardless of the direction. Then we calculate the "envelope" of
+// the histogram buckets, essentially removing any decreases in the count
+// between successive buckets.
+func ensureMonotonicAndIgnoreSmallDeltas(buckets []Bucket, tolerance float64) {
+	prev := buckets[0].Count
+	for i := 1; i < len(buckets); i++ {
+		curr := buckets[i].Count // Assumed always positive.
+		if curr == prev {
+			// No correction needed if the counts are identical between buckets.
+			continue
+		}
+		if almost.Equal(prev, curr, tolerance) {
+			// Silently correct numerically insignificant differences from floating
+			// point precision errors, regardless of direction.
+			// Do not update the 'prev' value as we are ignoring the difference.
+			buckets[i].Count = prev
+			continue
+		}
+		if curr < prev {
+			// Force monotonicity by removing any decreases regardless of magnitude.
+			// Do not update the 'prev' value as we are ignoring the decrease.
+			buckets[i].Count = prev
+			continue
+		}
+		prev = curr
+	}
+}
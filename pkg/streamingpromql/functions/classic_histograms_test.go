@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package functions
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// bucketQuantile is tested through the test scripts in pkg/streamingpromql/testdata, but Prometheus' engine does not
+// support histogram_fraction for classic histograms, so we test bucketFraction here.
+func TestBucketFraction(t *testing.T) {
+	buckets := func() []Bucket {
+		// Deliberately unsorted, to check bucketFraction sorts the buckets.
+		return []Bucket{
+			{UpperBound: 1, Count: 6},
+			{UpperBound: 0.5, Count: 2},
+			{UpperBound: math.Inf(+1), Count: 8},
+		}
+	}
+
+	testCases := map[string]struct {
+		lower, upper float64
+		buckets      []Bucket
+		expected     float64
+	}{
+		"all observations": {
+			lower:    math.Inf(-1),
+			upper:    math.Inf(+1),
+			buckets:  buckets(),
+			expected: 1,
+		},
+		"bounds on bucket boundaries": {
+			lower:    0.5,
+			upper:    1,
+			buckets:  buckets(),
+			expected: 0.5,
+		},
+		"bounds within buckets": {
+			lower:    0.25,
+			upper:    0.75,
+			buckets:  buckets(),
+			expected: 0.375, // (4 - 1) / 8
+		},
+		"lower bound below zero, with lowest bucket with positive upper bound": {
+			lower:    -1,
+			upper:    0.25,
+			buckets:  buckets(),
+			expected: 0.125,
+		},
+		"upper bound in +Inf bucket": {
+			lower:    0.5,
+			upper:    100,
+			buckets:  buckets(),
+			expected: 0.75,
+		},
+		"lower bound in +Inf bucket": {
+			lower:    100,
+			upper:    math.Inf(+1),
+			buckets:  buckets(),
+			expected: 0,
+		},
+		"lowest bucket with negative upper bound": {
+			lower: -2,
+			upper: 0,
+			buckets: []Bucket{
+				{UpperBound: -1, Count: 2},
+				{UpperBound: 1, Count: 6},
+				{UpperBound: math.Inf(+1), Count: 8},
+			},
+			expected: 0.5, // Observations in the lowest bucket are assumed to be at its upper bound, -1.
+		},
+		"lower bound equal to upper bound": {
+			lower:    0.5,
+			upper:    0.5,
+			buckets:  buckets(),
+			expected: 0,
+		},
+		"lower bound greater than upper bound": {
+			lower:    1,
+			upper:    0.5,
+			buckets:  buckets(),
+			expected: 0,
+		},
+		"non-monotonic buckets": {
+			lower: 0.5,
+			upper: 1,
+			buckets: []Bucket{
+				{UpperBound: 0.5, Count: 4},
+				{UpperBound: 1, Count: 2},
+				{UpperBound: math.Inf(+1), Count: 8},
+			},
+			expected: 0,
+		},
+		"no observations": {
+			lower:    0,
+			upper:    1,
+			buckets:  []Bucket{{UpperBound: 1, Count: 0}, {UpperBound: math.Inf(+1), Count: 0}},
+			expected: math.NaN(),
+		},
+		"no +Inf bucket": {
+			lower:    0,
+			upper:    1,
+			buckets:  []Bucket{{UpperBound: 1, Count: 2}, {UpperBound: 2, Count: 4}},
+			expected: math.NaN(),
+		},
+		"lower bound is NaN": {
+			lower:    math.NaN(),
+			upper:    1,
+			buckets:  buckets(),
+			expected: math.NaN(),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			actual := bucketFraction(testCase.lower, testCase.upper, testCase.buckets)
+
+			if math.IsNaN(testCase.expected) {
+				require.True(t, math.IsNaN(actual), "expected NaN, got %v", actual)
+			} else {
+				require.Equal(t, testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestBucketFractionIsInverseOfBucketQuantile(t *testing.T) {
+	buckets := []Bucket{
+		{UpperBound: 0.1, Count: 1},
+		{UpperBound: 0.5, Count: 3},
+		{UpperBound: 1, Count: 4},
+		{UpperBound: math.Inf(+1), Count: 5},
+	}
+
+	for _, q := range []float64{0.1, 0.25, 0.5, 0.75} {
+		v := bucketQuantile(q, buckets)
+		require.InDelta(t, q, bucketFraction(math.Inf(-1), v, buckets), 1e-12, "quantile %v", q)
+	}
+}
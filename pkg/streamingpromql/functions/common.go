@@ -3,6 +3,9 @@
 package functions
 
 import (
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/promql"
+
 	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
 	"github.com/grafana/mimir/pkg/streamingpromql/types"
 )
@@ -17,11 +20,19 @@ func DropSeriesName(seriesMetadata []types.SeriesMetadata, _ *pooling.LimitingPo
 	return seriesMetadata, nil
 }
 
-type InstantVectorFunction func(seriesData types.InstantVectorSeriesData, pool *pooling.LimitingPool) (types.InstantVectorSeriesData, error)
+func PassthroughSeriesMetadata(seriesMetadata []types.SeriesMetadata, _ *pooling.LimitingPool) ([]types.SeriesMetadata, error) {
+	return seriesMetadata, nil
+}
+
+// InstantVectorFunction computes the result of a function over a single series of an instant vector.
+//
+// scalarArgsData contains the values of each scalar argument to the function, in the order they appear in the
+// function call, with one sample for each time step.
+type InstantVectorFunction func(seriesData types.InstantVectorSeriesData, scalarArgsData []types.ScalarData, pool *pooling.LimitingPool) (types.InstantVectorSeriesData, error)
 
 // floatTransformationFunc is not needed elsewhere, so it is not exported yet
 func floatTransformationFunc(transform func(f float64) float64) InstantVectorFunction {
-	return func(seriesData types.InstantVectorSeriesData, _ *pooling.LimitingPool) (types.InstantVectorSeriesData, error) {
+	return func(seriesData types.InstantVectorSeriesData, _ []types.ScalarData, _ *pooling.LimitingPool) (types.InstantVectorSeriesData, error) {
 		for i := range seriesData.Floats {
 			seriesData.Floats[i].F = transform(seriesData.Floats[i].F)
 		}
@@ -31,15 +42,93 @@ func floatTransformationFunc(transform func(f float64) float64) InstantVectorFun
 
 func FloatTransformationDropHistogramsFunc(transform func(f float64) float64) InstantVectorFunction {
 	ft := floatTransformationFunc(transform)
-	return func(seriesData types.InstantVectorSeriesData, pool *pooling.LimitingPool) (types.InstantVectorSeriesData, error) {
+	return func(seriesData types.InstantVectorSeriesData, scalarArgsData []types.ScalarData, pool *pooling.LimitingPool) (types.InstantVectorSeriesData, error) {
 		// Functions that do not explicitly mention native histograms in their documentation will ignore histogram samples.
 		// https://prometheus.io/docs/prometheus/latest/querying/functions
 		pool.PutHPointSlice(seriesData.Histograms)
 		seriesData.Histograms = nil
-		return ft(seriesData, pool)
+		return ft(seriesData, scalarArgsData, pool)
 	}
 }
 
-func Passthrough(seriesData types.InstantVectorSeriesData, _ *pooling.LimitingPool) (types.InstantVectorSeriesData, error) {
+// floatTransformationWithScalarArgsDropHistogramsFunc is like FloatTransformationDropHistogramsFunc, but transform also
+// receives the value of each scalar argument at the time step of each point.
+// Points for which transform returns false are removed.
+func floatTransformationWithScalarArgsDropHistogramsFunc(transform func(f float64, scalarArgs []float64) (float64, bool)) InstantVectorFunction {
+	return func(seriesData types.InstantVectorSeriesData, scalarArgsData []types.ScalarData, pool *pooling.LimitingPool) (types.InstantVectorSeriesData, error) {
+		// Functions that do not explicitly mention native histograms in their documentation will ignore histogram samples.
+		// https://prometheus.io/docs/prometheus/latest/querying/functions
+		pool.PutHPointSlice(seriesData.Histograms)
+		seriesData.Histograms = nil
+
+		scalarArgs := make([]float64, len(scalarArgsData))
+		stepIdx := 0 // Every scalar has a sample at every time step, so we can use the same index for all of them.
+		outputIdx := 0
+
+		for _, p := range seriesData.Floats {
+			if len(scalarArgsData) > 0 {
+				for scalarArgsData[0].Samples[stepIdx].T < p.T {
+					stepIdx++
+				}
+
+				for i, d := range scalarArgsData {
+					scalarArgs[i] = d.Samples[stepIdx].F
+				}
+			}
+
+			f, ok := transform(p.F, scalarArgs)
+			if !ok {
+				continue
+			}
+
+			seriesData.Floats[outputIdx] = promql.FPoint{T: p.T, F: f}
+			outputIdx++
+		}
+
+		seriesData.Floats = seriesData.Floats[:outputIdx]
+
+		return seriesData, nil
+	}
+}
+
+func Passthrough(seriesData types.InstantVectorSeriesData, _ []types.ScalarData, _ *pooling.LimitingPool) (types.InstantVectorSeriesData, error) {
 	return seriesData, nil
 }
+
+// RangeVectorStepFunction computes the result of a function over a range vector for a single time step.
+//
+// floatBuffer and histogramBuffer contain the points for the range selected at this step, but may also contain
+// points after step.RangeEnd, so implementations must only consider points with timestamp less than or equal to step.RangeEnd.
+// floatBuffer and histogramBuffer must not be modified, and histograms in histogramBuffer must not be returned
+// or modified: they may be reused for subsequent steps.
+//
+// scalarArgs contains the value at this step of each scalar argument to the function, in the order they appear in the
+// function call.
+//
+// It returns the float result if hasFloat is true, or the histogram result if h is not nil. If neither is set, no
+// point is produced for this step.
+type RangeVectorStepFunction func(
+	step types.RangeVectorStepData,
+	rangeSeconds float64,
+	floatBuffer *types.FPointRingBuffer,
+	histogramBuffer *types.HPointRingBuffer,
+	scalarArgs []float64,
+	pool *pooling.LimitingPool,
+) (f float64, hasFloat bool, h *histogram.FloatHistogram, err error)
+
+// ClassicHistogramFunction computes the result of a function over a classic histogram at a single time step.
+//
+// buckets contains one entry for each bucket series with a point at this time step, in no particular order.
+// Implementations may modify buckets.
+//
+// scalarArgs contains the value at this step of each scalar argument to the function, in the order they appear in the
+// function call.
+type ClassicHistogramFunction func(scalarArgs []float64, buckets []Bucket) float64
+
+// NativeHistogramFunction computes the result of a function over a native histogram at a single time step.
+//
+// h must not be modified.
+//
+// scalarArgs contains the value at this step of each scalar argument to the function, in the order they appear in the
+// function call.
+type NativeHistogramFunction func(scalarArgs []float64, h *histogram.FloatHistogram) float64
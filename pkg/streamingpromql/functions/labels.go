@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/functions.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package functions
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// LabelJoinFactory returns the SeriesMetadataFunction for label_join.
+//
+// The string arguments are only evaluated, and validated, when the returned function is called, consistent with
+// Prometheus' engine, which returns invalid arguments as an evaluation error.
+func LabelJoinFactory(dstLabelOp types.StringOperator, separatorOp types.StringOperator, srcLabelOps []types.StringOperator) SeriesMetadataFunction {
+	return func(seriesMetadata []types.SeriesMetadata, _ *pooling.LimitingPool) ([]types.SeriesMetadata, error) {
+		dst := dstLabelOp.GetValue()
+		separator := separatorOp.GetValue()
+		srcLabels := make([]string, len(srcLabelOps))
+
+		for i, op := range srcLabelOps {
+			src := op.GetValue()
+			if !model.LabelName(src).IsValid() {
+				return nil, fmt.Errorf("invalid source label name in label_join(): %s", src)
+			}
+			srcLabels[i] = src
+		}
+
+		if !model.LabelName(dst).IsValid() {
+			return nil, fmt.Errorf("invalid destination label name in label_join(): %s", dst)
+		}
+
+		srcValues := make([]string, len(srcLabels))
+		lb := labels.NewBuilder(labels.EmptyLabels())
+
+		for i := range seriesMetadata {
+			for j, src := range srcLabels {
+				srcValues[j] = seriesMetadata[i].Labels.Get(src)
+			}
+
+			lb.Reset(seriesMetadata[i].Labels)
+			lb.Set(dst, strings.Join(srcValues, separator))
+			seriesMetadata[i].Labels = lb.Labels()
+		}
+
+		return seriesMetadata, nil
+	}
+}
+
+// LabelReplaceFactory returns the SeriesMetadataFunction for label_replace.
+//
+// The string arguments are only evaluated, and validated, when the returned function is called, consistent with
+// Prometheus' engine, which returns invalid arguments as an evaluation error.
+func LabelReplaceFactory(dstLabelOp, replacementOp, srcLabelOp, regexOp types.StringOperator) SeriesMetadataFunction {
+	return func(seriesMetadata []types.SeriesMetadata, _ *pooling.LimitingPool) ([]types.SeriesMetadata, error) {
+		dst := dstLabelOp.GetValue()
+		replacement := replacementOp.GetValue()
+		src := srcLabelOp.GetValue()
+		regexStr := regexOp.GetValue()
+
+		regex, err := regexp.Compile("^(?:" + regexStr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression in label_replace(): %s", regexStr)
+		}
+
+		if !model.LabelNameRE.MatchString(dst) {
+			return nil, fmt.Errorf("invalid destination label name in label_replace(): %s", dst)
+		}
+
+		lb := labels.NewBuilder(labels.EmptyLabels())
+
+		for i := range seriesMetadata {
+			srcValue := seriesMetadata[i].Labels.Get(src)
+			indexes := regex.FindStringSubmatchIndex(srcValue)
+
+			if indexes == nil {
+				// Only replace when the regular expression matches.
+				continue
+			}
+
+			res := regex.ExpandString([]byte{}, replacement, srcValue, indexes)
+			lb.Reset(seriesMetadata[i].Labels)
+			lb.Set(dst, string(res))
+			seriesMetadata[i].Labels = lb.Labels()
+		}
+
+		return seriesMetadata, nil
+	}
+}
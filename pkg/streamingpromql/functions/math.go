@@ -1,4 +1,7 @@
 // SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/functions.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
 
 package functions
 
@@ -48,3 +51,36 @@ var Sgn = FloatTransformationDropHistogramsFunc(func(f float64) float64 {
 	// Otherwise, if the value is 0, we should return 0.
 	return f
 })
+
+var Clamp = floatTransformationWithScalarArgsDropHistogramsFunc(func(f float64, scalarArgs []float64) (float64, bool) {
+	minVal, maxVal := scalarArgs[0], scalarArgs[1]
+
+	if maxVal < minVal {
+		// Prometheus' engine returns no points if the minimum is greater than the maximum.
+		return 0, false
+	}
+
+	return math.Max(minVal, math.Min(maxVal, f)), true
+})
+
+var ClampMin = floatTransformationWithScalarArgsDropHistogramsFunc(func(f float64, scalarArgs []float64) (float64, bool) {
+	return math.Max(scalarArgs[0], f), true
+})
+
+var ClampMax = floatTransformationWithScalarArgsDropHistogramsFunc(func(f float64, scalarArgs []float64) (float64, bool) {
+	return math.Min(scalarArgs[0], f), true
+})
+
+// Round rounds to the nearest multiple of its optional scalar argument, or to the nearest integer if the argument is
+// not provided. Ties are resolved by rounding up.
+var Round = floatTransformationWithScalarArgsDropHistogramsFunc(func(f float64, scalarArgs []float64) (float64, bool) {
+	toNearest := 1.0
+	if len(scalarArgs) > 0 {
+		toNearest = scalarArgs[0]
+	}
+
+	// Invert as it seems to cause fewer floating point accuracy issues.
+	toNearestInverse := 1.0 / toNearest
+
+	return math.Floor(f*toNearestInverse+0.5) / toNearestInverse, true
+})
@@ -1,48 +1,271 @@
 // SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/functions.go
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/quantile.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
 
 package functions
 
 import (
+	"math"
+
+	"github.com/prometheus/prometheus/model/histogram"
 	"github.com/prometheus/prometheus/promql"
 
+	"github.com/grafana/mimir/pkg/streamingpromql/floats"
 	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
 	"github.com/grafana/mimir/pkg/streamingpromql/types"
 )
 
-func HistogramCount(seriesData types.InstantVectorSeriesData, pool *pooling.LimitingPool) (types.InstantVectorSeriesData, error) {
-	floats, err := pool.GetFPointSlice(len(seriesData.Histograms))
-	if err != nil {
-		return types.InstantVectorSeriesData{}, err
+var HistogramCount = histogramToFloatFunc(func(h *histogram.FloatHistogram) float64 {
+	return h.Count
+})
+
+var HistogramSum = histogramToFloatFunc(func(h *histogram.FloatHistogram) float64 {
+	return h.Sum
+})
+
+var HistogramAvg = histogramToFloatFunc(func(h *histogram.FloatHistogram) float64 {
+	return h.Sum / h.Count
+})
+
+var HistogramStdDev = histogramToFloatFunc(func(h *histogram.FloatHistogram) float64 {
+	return math.Sqrt(histogramVariance(h))
+})
+
+var HistogramStdVar = histogramToFloatFunc(histogramVariance)
+
+// histogramToFloatFunc returns an InstantVectorFunction that computes a float from each histogram in a series.
+// Float samples are ignored.
+func histogramToFloatFunc(f func(h *histogram.FloatHistogram) float64) InstantVectorFunction {
+	return func(seriesData types.InstantVectorSeriesData, _ []types.ScalarData, pool *pooling.LimitingPool) (types.InstantVectorSeriesData, error) {
+		fPoints, err := pool.GetFPointSlice(len(seriesData.Histograms))
+		if err != nil {
+			return types.InstantVectorSeriesData{}, err
+		}
+
+		data := types.InstantVectorSeriesData{
+			Floats: fPoints,
+		}
+		for _, p := range seriesData.Histograms {
+			data.Floats = append(data.Floats, promql.FPoint{
+				T: p.T,
+				F: f(p.H),
+			})
+		}
+		pool.PutInstantVectorSeriesData(seriesData)
+		return data, nil
+	}
+}
+
+// histogramVariance estimates the variance of the observations in h, assuming that each observation lies at the
+// geometric mean of its bucket's boundaries (or zero for the zero bucket).
+func histogramVariance(h *histogram.FloatHistogram) float64 {
+	mean := h.Sum / h.Count
+	var variance, cVariance float64
+	it := h.AllBucketIterator()
+	for it.Next() {
+		bucket := it.At()
+		if bucket.Count == 0 {
+			continue
+		}
+		var val float64
+		if bucket.Lower <= 0 && 0 <= bucket.Upper {
+			val = 0
+		} else {
+			val = math.Sqrt(bucket.Upper * bucket.Lower)
+			if bucket.Upper < 0 {
+				val = -val
+			}
+		}
+		delta := val - mean
+		variance, cVariance = floats.KahanSumInc(bucket.Count*delta*delta, variance, cVariance)
 	}
+	variance += cVariance
+	variance /= h.Count
+	return variance
+}
+
+// NativeHistogramQuantile is the NativeHistogramFunction for histogram_quantile.
+func NativeHistogramQuantile(scalarArgs []float64, h *histogram.FloatHistogram) float64 {
+	return histogramQuantile(scalarArgs[0], h)
+}
+
+// NativeHistogramFraction is the NativeHistogramFunction for histogram_fraction.
+func NativeHistogramFraction(scalarArgs []float64, h *histogram.FloatHistogram) float64 {
+	return histogramFraction(scalarArgs[0], scalarArgs[1], h)
+}
 
-	data := types.InstantVectorSeriesData{
-		Floats: floats,
+// histogramQuantile calculates the quantile 'q' based on the given histogram.
+//
+// The quantile value is interpolated assuming a linear distribution within a
+// bucket.
+//
+// A natural lower bound of 0 is assumed if the histogram has only positive
+// buckets. Likewise, a natural upper bound of 0 is assumed if the histogram has
+// only negative buckets.
+//
+// If the histogram has 0 observations or q is NaN, NaN is returned.
+// If q<0, -Inf is returned, and if q>1, +Inf is returned.
+func histogramQuantile(q float64, h *histogram.FloatHistogram) float64 {
+	if q < 0 {
+		return math.Inf(-1)
 	}
-	for _, histogram := range seriesData.Histograms {
-		data.Floats = append(data.Floats, promql.FPoint{
-			T: histogram.T,
-			F: histogram.H.Count,
-		})
+	if q > 1 {
+		return math.Inf(+1)
 	}
-	pool.PutInstantVectorSeriesData(seriesData)
-	return data, nil
+
+	if h.Count == 0 || math.IsNaN(q) {
+		return math.NaN()
+	}
+
+	var (
+		bucket histogram.Bucket[float64]
+		count  float64
+		it     histogram.BucketIterator[float64]
+		rank   float64
+	)
+
+	// if there are NaN observations in the histogram (h.Sum is NaN), use the forward iterator
+	// if the q < 0.5, use the forward iterator
+	// if the q >= 0.5, use the reverse iterator
+	if math.IsNaN(h.Sum) || q < 0.5 {
+		it = h.AllBucketIterator()
+		rank = q * h.Count
+	} else {
+		it = h.AllReverseBucketIterator()
+		rank = (1 - q) * h.Count
+	}
+
+	for it.Next() {
+		bucket = it.At()
+		if bucket.Count == 0 {
+			continue
+		}
+		count += bucket.Count
+		if count >= rank {
+			break
+		}
+	}
+	if !h.UsesCustomBuckets() && bucket.Lower < 0 && bucket.Upper > 0 {
+		switch {
+		case len(h.NegativeBuckets) == 0 && len(h.PositiveBuckets) > 0:
+			// The result is in the zero bucket and the histogram has only
+			// positive buckets. So we consider 0 to be the lower bound.
+			bucket.Lower = 0
+		case len(h.PositiveBuckets) == 0 && len(h.NegativeBuckets) > 0:
+			// The result is in the zero bucket and the histogram has only
+			// negative buckets. So we consider 0 to be the upper bound.
+			bucket.Upper = 0
+		}
+	} else if h.UsesCustomBuckets() {
+		if bucket.Lower == math.Inf(-1) {
+			// first bucket, with lower bound -Inf
+			if bucket.Upper <= 0 {
+				return bucket.Upper
+			}
+			bucket.Lower = 0
+		} else if bucket.Upper == math.Inf(1) {
+			// last bucket, with upper bound +Inf
+			return bucket.Lower
+		}
+	}
+	// Due to numerical inaccuracies, we could end up with a higher count
+	// than h.Count. Thus, make sure count is never higher than h.Count.
+	if count > h.Count {
+		count = h.Count
+	}
+	// We could have hit the highest bucket without even reaching the rank
+	// (this should only happen if the histogram contains observations of
+	// the value NaN), in which case we simply return the upper limit of the
+	// highest explicit bucket.
+	if count < rank {
+		return bucket.Upper
+	}
+
+	// NaN observations increase h.Count but not the total number of
+	// observations in the buckets. Therefore, we have to use the forward
+	// iterator to find percentiles. We recognize histograms containing NaN
+	// observations by checking if their h.Sum is NaN.
+	if math.IsNaN(h.Sum) || q < 0.5 {
+		rank -= count - bucket.Count
+	} else {
+		rank = count - rank
+	}
+
+	return bucket.Lower + (bucket.Upper-bucket.Lower)*(rank/bucket.Count)
 }
 
-func HistogramSum(seriesData types.InstantVectorSeriesData, pool *pooling.LimitingPool) (types.InstantVectorSeriesData, error) {
-	floats, err := pool.GetFPointSlice(len(seriesData.Histograms))
-	if err != nil {
-		return types.InstantVectorSeriesData{}, err
+// histogramFraction calculates the fraction of observations between the
+// provided lower and upper bounds, based on the provided histogram.
+//
+// histogramFraction is in a certain way the inverse of histogramQuantile. If
+// histogramQuantile(0.9, h) returns 123.4, then histogramFraction(-Inf, 123.4, h)
+// returns 0.9.
+//
+// The same assumptions about the zero bucket boundaries apply as for histogramQuantile.
+//
+// If the histogram has 0 observations, or lower or upper is NaN, NaN is returned.
+// If lower >= upper and the histogram has at least 1 observation, zero is returned.
+func histogramFraction(lower, upper float64, h *histogram.FloatHistogram) float64 {
+	if h.Count == 0 || math.IsNaN(lower) || math.IsNaN(upper) {
+		return math.NaN()
+	}
+	if lower >= upper {
+		return 0
 	}
 
-	data := types.InstantVectorSeriesData{
-		Floats: floats,
+	var (
+		rank, lowerRank, upperRank float64
+		lowerSet, upperSet         bool
+		it                         = h.AllBucketIterator()
+	)
+	for it.Next() {
+		b := it.At()
+		if b.Lower < 0 && b.Upper > 0 {
+			switch {
+			case len(h.NegativeBuckets) == 0 && len(h.PositiveBuckets) > 0:
+				// This is the zero bucket and the histogram has only
+				// positive buckets. So we consider 0 to be the lower
+				// bound.
+				b.Lower = 0
+			case len(h.PositiveBuckets) == 0 && len(h.NegativeBuckets) > 0:
+				// This is in the zero bucket and the histogram has only
+				// negative buckets. So we consider 0 to be the upper
+				// bound.
+				b.Upper = 0
+			}
+		}
+		if !lowerSet && b.Lower >= lower {
+			lowerRank = rank
+			lowerSet = true
+		}
+		if !upperSet && b.Lower >= upper {
+			upperRank = rank
+			upperSet = true
+		}
+		if lowerSet && upperSet {
+			break
+		}
+		if !lowerSet && b.Lower < lower && b.Upper > lower {
+			lowerRank = rank + b.Count*(lower-b.Lower)/(b.Upper-b.Lower)
+			lowerSet = true
+		}
+		if !upperSet && b.Lower < upper && b.Upper > upper {
+			upperRank = rank + b.Count*(upper-b.Lower)/(b.Upper-b.Lower)
+			upperSet = true
+		}
+		if lowerSet && upperSet {
+			break
+		}
+		rank += b.Count
+	}
+	if !lowerSet || lowerRank > h.Count {
+		lowerRank = h.Count
 	}
-	for _, histogram := range seriesData.Histograms {
-		data.Floats = append(data.Floats, promql.FPoint{
-			T: histogram.T,
-			F: histogram.H.Sum,
-		})
+	if !upperSet || upperRank > h.Count {
+		upperRank = h.Count
 	}
-	pool.PutInstantVectorSeriesData(seriesData)
-	return data, nil
+
+	return (upperRank - lowerRank) / h.Count
 }
@@ -0,0 +1,534 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/functions.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package functions
+
+import (
+	"math"
+
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/floats"
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+func CountOverTime(step types.RangeVectorStepData, _ float64, floatBuffer *types.FPointRingBuffer, histogramBuffer *types.HPointRingBuffer, _ []float64, _ *pooling.LimitingPool) (float64, bool, *histogram.FloatHistogram, error) {
+	fHead, fTail := floatBuffer.UnsafePoints(step.RangeEnd)
+	hHead, hTail := histogramBuffer.UnsafePoints(step.RangeEnd)
+	count := len(fHead) + len(fTail) + len(hHead) + len(hTail)
+
+	if count == 0 {
+		return 0, false, nil, nil
+	}
+
+	return float64(count), true, nil, nil
+}
+
+func PresentOverTime(step types.RangeVectorStepData, _ float64, floatBuffer *types.FPointRingBuffer, histogramBuffer *types.HPointRingBuffer, _ []float64, _ *pooling.LimitingPool) (float64, bool, *histogram.FloatHistogram, error) {
+	fHead, _ := floatBuffer.UnsafePoints(step.RangeEnd)
+	hHead, _ := histogramBuffer.UnsafePoints(step.RangeEnd)
+
+	if len(fHead) == 0 && len(hHead) == 0 {
+		return 0, false, nil, nil
+	}
+
+	return 1, true, nil, nil
+}
+
+func LastOverTime(step types.RangeVectorStepData, _ float64, floatBuffer *types.FPointRingBuffer, histogramBuffer *types.HPointRingBuffer, _ []float64, _ *pooling.LimitingPool) (float64, bool, *histogram.FloatHistogram, error) {
+	lastFloat, floatAvailable := floatBuffer.LastAtOrBefore(step.RangeEnd)
+	lastHistogram, histogramAvailable := histogramBuffer.LastAtOrBefore(step.RangeEnd)
+
+	if floatAvailable && (!histogramAvailable || lastFloat.T > lastHistogram.T) {
+		return lastFloat.F, true, nil, nil
+	}
+
+	if histogramAvailable {
+		// The histogram in the buffer may be reused for later points, so we must return a copy.
+		return 0, false, lastHistogram.H.Copy(), nil
+	}
+
+	return 0, false, nil, nil
+}
+
+func MaxOverTime(step types.RangeVectorStepData, _ float64, floatBuffer *types.FPointRingBuffer, _ *types.HPointRingBuffer, _ []float64, _ *pooling.LimitingPool) (float64, bool, *histogram.FloatHistogram, error) {
+	head, tail := floatBuffer.UnsafePoints(step.RangeEnd)
+
+	if len(head) == 0 && len(tail) == 0 {
+		// Histograms are ignored by max_over_time.
+		return 0, false, nil, nil
+	}
+
+	maxSoFar := head[0].F
+
+	accumulate := func(points []promql.FPoint) {
+		for _, p := range points {
+			if p.F > maxSoFar || math.IsNaN(maxSoFar) {
+				maxSoFar = p.F
+			}
+		}
+	}
+
+	accumulate(head)
+	accumulate(tail)
+
+	return maxSoFar, true, nil, nil
+}
+
+func MinOverTime(step types.RangeVectorStepData, _ float64, floatBuffer *types.FPointRingBuffer, _ *types.HPointRingBuffer, _ []float64, _ *pooling.LimitingPool) (float64, bool, *histogram.FloatHistogram, error) {
+	head, tail := floatBuffer.UnsafePoints(step.RangeEnd)
+
+	if len(head) == 0 && len(tail) == 0 {
+		// Histograms are ignored by min_over_time.
+		return 0, false, nil, nil
+	}
+
+	minSoFar := head[0].F
+
+	accumulate := func(points []promql.FPoint) {
+		for _, p := range points {
+			if p.F < minSoFar || math.IsNaN(minSoFar) {
+				minSoFar = p.F
+			}
+		}
+	}
+
+	accumulate(head)
+	accumulate(tail)
+
+	return minSoFar, true, nil, nil
+}
+
+func SumOverTime(step types.RangeVectorStepData, _ float64, floatBuffer *types.FPointRingBuffer, histogramBuffer *types.HPointRingBuffer, _ []float64, _ *pooling.LimitingPool) (float64, bool, *histogram.FloatHistogram, error) {
+	fHead, fTail := floatBuffer.UnsafePoints(step.RangeEnd)
+	hHead, hTail := histogramBuffer.UnsafePoints(step.RangeEnd)
+
+	haveFloats := len(fHead) > 0 || len(fTail) > 0
+	haveHistograms := len(hHead) > 0 || len(hTail) > 0
+
+	if haveFloats && haveHistograms {
+		// Prometheus' engine drops the output point if there is a mix of floats and histograms.
+		return 0, false, nil, nil
+	}
+
+	if haveFloats {
+		return sumFloats(fHead, fTail), true, nil, nil
+	}
+
+	if haveHistograms {
+		h, err := sumHistograms(hHead, hTail)
+		return 0, false, h, ignoreIncompatibleHistogramsError(err)
+	}
+
+	return 0, false, nil, nil
+}
+
+func sumFloats(head, tail []promql.FPoint) float64 {
+	sum, c := 0.0, 0.0
+
+	for _, p := range head {
+		sum, c = floats.KahanSumInc(p.F, sum, c)
+	}
+
+	for _, p := range tail {
+		sum, c = floats.KahanSumInc(p.F, sum, c)
+	}
+
+	if math.IsInf(sum, 0) {
+		return sum
+	}
+
+	return sum + c
+}
+
+func sumHistograms(head, tail []promql.HPoint) (*histogram.FloatHistogram, error) {
+	sum := head[0].H.Copy() // We must make a copy of the histogram, as the ring buffer may reuse the FloatHistogram object it contains.
+
+	for _, p := range head[1:] {
+		if _, err := sum.Add(p.H); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range tail {
+		if _, err := sum.Add(p.H); err != nil {
+			return nil, err
+		}
+	}
+
+	return sum, nil
+}
+
+func AvgOverTime(step types.RangeVectorStepData, _ float64, floatBuffer *types.FPointRingBuffer, histogramBuffer *types.HPointRingBuffer, _ []float64, _ *pooling.LimitingPool) (float64, bool, *histogram.FloatHistogram, error) {
+	fHead, fTail := floatBuffer.UnsafePoints(step.RangeEnd)
+	hHead, hTail := histogramBuffer.UnsafePoints(step.RangeEnd)
+
+	haveFloats := len(fHead) > 0 || len(fTail) > 0
+	haveHistograms := len(hHead) > 0 || len(hTail) > 0
+
+	if haveFloats && haveHistograms {
+		// Prometheus' engine drops the output point if there is a mix of floats and histograms.
+		return 0, false, nil, nil
+	}
+
+	if haveFloats {
+		return avgFloats(fHead, fTail), true, nil, nil
+	}
+
+	if haveHistograms {
+		h, err := avgHistograms(hHead, hTail)
+		return 0, false, h, ignoreIncompatibleHistogramsError(err)
+	}
+
+	return 0, false, nil, nil
+}
+
+func avgFloats(head, tail []promql.FPoint) float64 {
+	mean, c, count := 0.0, 0.0, 0.0
+
+	accumulate := func(points []promql.FPoint) {
+		for _, p := range points {
+			count++
+
+			if math.IsInf(mean, 0) {
+				if math.IsInf(p.F, 0) && (mean > 0) == (p.F > 0) {
+					// The `mean` and `p.F` values are `Inf` of the same sign.  They
+					// can't be subtracted, but the value of `mean` is correct
+					// already.
+					continue
+				}
+
+				if !math.IsInf(p.F, 0) && !math.IsNaN(p.F) {
+					// At this stage, the mean is an infinite. If the added
+					// value is neither an Inf or a Nan, we can keep that mean
+					// value.
+					// This is required because our calculation below removes
+					// the mean value, which would look like Inf += x - Inf and
+					// end up as a NaN.
+					continue
+				}
+			}
+
+			mean, c = floats.KahanSumInc(p.F/count-mean/count, mean, c)
+		}
+	}
+
+	accumulate(head)
+	accumulate(tail)
+
+	if math.IsInf(mean, 0) {
+		return mean
+	}
+
+	return mean + c
+}
+
+func avgHistograms(head, tail []promql.HPoint) (*histogram.FloatHistogram, error) {
+	mean := head[0].H.Copy() // We must make a copy of the histogram, as the ring buffer may reuse the FloatHistogram object it contains.
+	count := 1.0
+
+	accumulate := func(points []promql.HPoint) error {
+		for _, p := range points {
+			count++
+			left := p.H.Copy().Div(count)
+			right := mean.Copy().Div(count)
+
+			toAdd, err := left.Sub(right)
+			if err != nil {
+				return err
+			}
+
+			if _, err := mean.Add(toAdd); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := accumulate(head[1:]); err != nil {
+		return nil, err
+	}
+
+	if err := accumulate(tail); err != nil {
+		return nil, err
+	}
+
+	return mean, nil
+}
+
+func StddevOverTime(step types.RangeVectorStepData, _ float64, floatBuffer *types.FPointRingBuffer, _ *types.HPointRingBuffer, _ []float64, _ *pooling.LimitingPool) (float64, bool, *histogram.FloatHistogram, error) {
+	variance, hasFloat := varianceOverTime(step, floatBuffer)
+	if !hasFloat {
+		return 0, false, nil, nil
+	}
+
+	return math.Sqrt(variance), true, nil, nil
+}
+
+func StdvarOverTime(step types.RangeVectorStepData, _ float64, floatBuffer *types.FPointRingBuffer, _ *types.HPointRingBuffer, _ []float64, _ *pooling.LimitingPool) (float64, bool, *histogram.FloatHistogram, error) {
+	variance, hasFloat := varianceOverTime(step, floatBuffer)
+	if !hasFloat {
+		return 0, false, nil, nil
+	}
+
+	return variance, true, nil, nil
+}
+
+// varianceOverTime returns the population variance of the float points in the range selected at step, or false if
+// there are no float points in the range.
+// Histograms are ignored by stddev_over_time and stdvar_over_time.
+func varianceOverTime(step types.RangeVectorStepData, floatBuffer *types.FPointRingBuffer) (float64, bool) {
+	head, tail := floatBuffer.UnsafePoints(step.RangeEnd)
+
+	if len(head) == 0 && len(tail) == 0 {
+		return 0, false
+	}
+
+	var count float64
+	var mean, cMean float64
+	var aux, cAux float64
+
+	accumulate := func(points []promql.FPoint) {
+		for _, p := range points {
+			count++
+			delta := p.F - (mean + cMean)
+			mean, cMean = floats.KahanSumInc(delta/count, mean, cMean)
+			aux, cAux = floats.KahanSumInc(delta*(p.F-(mean+cMean)), aux, cAux)
+		}
+	}
+
+	accumulate(head)
+	accumulate(tail)
+
+	return (aux + cAux) / count, true
+}
+
+// QuantileOverTime expects the quantile to compute as its only scalar argument.
+func QuantileOverTime(step types.RangeVectorStepData, _ float64, floatBuffer *types.FPointRingBuffer, _ *types.HPointRingBuffer, scalarArgs []float64, pool *pooling.LimitingPool) (float64, bool, *histogram.FloatHistogram, error) {
+	head, tail := floatBuffer.UnsafePoints(step.RangeEnd)
+
+	if len(head) == 0 && len(tail) == 0 {
+		// Histograms are ignored by quantile_over_time.
+		return 0, false, nil, nil
+	}
+
+	values, err := copyFloatValues(head, tail, pool)
+	if err != nil {
+		return 0, false, nil, err
+	}
+
+	defer pool.PutFloatSlice(values)
+
+	return floats.Quantile(scalarArgs[0], values), true, nil, nil
+}
+
+func MadOverTime(step types.RangeVectorStepData, _ float64, floatBuffer *types.FPointRingBuffer, _ *types.HPointRingBuffer, _ []float64, pool *pooling.LimitingPool) (float64, bool, *histogram.FloatHistogram, error) {
+	head, tail := floatBuffer.UnsafePoints(step.RangeEnd)
+
+	if len(head) == 0 && len(tail) == 0 {
+		// Histograms are ignored by mad_over_time.
+		return 0, false, nil, nil
+	}
+
+	values, err := copyFloatValues(head, tail, pool)
+	if err != nil {
+		return 0, false, nil, err
+	}
+
+	defer pool.PutFloatSlice(values)
+
+	median := floats.Quantile(0.5, values)
+
+	for i, v := range values {
+		values[i] = math.Abs(v - median)
+	}
+
+	return floats.Quantile(0.5, values), true, nil, nil
+}
+
+// copyFloatValues returns a slice from pool containing the values of the points in head and tail.
+func copyFloatValues(head, tail []promql.FPoint, pool *pooling.LimitingPool) ([]float64, error) {
+	values, err := pool.GetFloatSlice(len(head) + len(tail))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range head {
+		values = append(values, p.F)
+	}
+
+	for _, p := range tail {
+		values = append(values, p.F)
+	}
+
+	return values, nil
+}
+
+func Changes(step types.RangeVectorStepData, _ float64, floatBuffer *types.FPointRingBuffer, _ *types.HPointRingBuffer, _ []float64, _ *pooling.LimitingPool) (float64, bool, *histogram.FloatHistogram, error) {
+	head, tail := floatBuffer.UnsafePoints(step.RangeEnd)
+
+	if len(head) == 0 && len(tail) == 0 {
+		// Histograms are ignored by changes.
+		return 0, false, nil, nil
+	}
+
+	changes := 0
+	prev := head[0].F
+
+	accumulate := func(points []promql.FPoint) {
+		for _, p := range points {
+			if p.F != prev && !(math.IsNaN(p.F) && math.IsNaN(prev)) {
+				changes++
+			}
+
+			prev = p.F
+		}
+	}
+
+	accumulate(head[1:])
+	accumulate(tail)
+
+	return float64(changes), true, nil, nil
+}
+
+func Resets(step types.RangeVectorStepData, _ float64, floatBuffer *types.FPointRingBuffer, histogramBuffer *types.HPointRingBuffer, _ []float64, _ *pooling.LimitingPool) (float64, bool, *histogram.FloatHistogram, error) {
+	fHead, fTail := floatBuffer.UnsafePoints(step.RangeEnd)
+	hHead, hTail := histogramBuffer.UnsafePoints(step.RangeEnd)
+
+	if len(fHead) == 0 && len(hHead) == 0 {
+		return 0, false, nil, nil
+	}
+
+	resets := 0
+
+	if len(fHead) > 0 {
+		prev := fHead[0].F
+
+		accumulate := func(points []promql.FPoint) {
+			for _, p := range points {
+				if p.F < prev {
+					resets++
+				}
+
+				prev = p.F
+			}
+		}
+
+		accumulate(fHead[1:])
+		accumulate(fTail)
+	}
+
+	if len(hHead) > 0 {
+		prev := hHead[0].H
+
+		accumulate := func(points []promql.HPoint) {
+			for _, p := range points {
+				if p.H.DetectReset(prev) {
+					resets++
+				}
+
+				prev = p.H
+			}
+		}
+
+		accumulate(hHead[1:])
+		accumulate(hTail)
+	}
+
+	return float64(resets), true, nil, nil
+}
+
+func Deriv(step types.RangeVectorStepData, _ float64, floatBuffer *types.FPointRingBuffer, _ *types.HPointRingBuffer, _ []float64, _ *pooling.LimitingPool) (float64, bool, *histogram.FloatHistogram, error) {
+	head, tail := floatBuffer.UnsafePoints(step.RangeEnd)
+
+	// No sense in trying to compute a derivative without at least two points.
+	// Drop this Vector element.
+	if len(head)+len(tail) < 2 {
+		return 0, false, nil, nil
+	}
+
+	// We pass in an arbitrary timestamp that is near the values in use
+	// to avoid floating point accuracy issues, see
+	// https://github.com/prometheus/prometheus/issues/2674
+	slope, _ := linearRegression(head, tail, head[0].T)
+
+	return slope, true, nil, nil
+}
+
+// PredictLinear expects the number of seconds into the future to predict as its only scalar argument.
+func PredictLinear(step types.RangeVectorStepData, _ float64, floatBuffer *types.FPointRingBuffer, _ *types.HPointRingBuffer, scalarArgs []float64, _ *pooling.LimitingPool) (float64, bool, *histogram.FloatHistogram, error) {
+	head, tail := floatBuffer.UnsafePoints(step.RangeEnd)
+
+	// No sense in trying to predict anything without at least two points.
+	// Drop this Vector element.
+	if len(head)+len(tail) < 2 {
+		return 0, false, nil, nil
+	}
+
+	slope, intercept := linearRegression(head, tail, step.StepT)
+
+	return slope*scalarArgs[0] + intercept, true, nil, nil
+}
+
+// linearRegression performs a least-square linear regression analysis on the
+// points in head and tail. It returns the slope, and the intercept value at the
+// provided time.
+func linearRegression(head, tail []promql.FPoint, interceptTime int64) (slope, intercept float64) {
+	var (
+		n          float64
+		sumX, cX   float64
+		sumY, cY   float64
+		sumXY, cXY float64
+		sumX2, cX2 float64
+		initY      float64
+		constY     bool
+	)
+
+	initY = head[0].F
+	constY = true
+
+	accumulate := func(points []promql.FPoint) {
+		for _, p := range points {
+			// Set constY to false if any new y values are encountered.
+			if constY && p.F != initY {
+				constY = false
+			}
+
+			n += 1.0
+			x := float64(p.T-interceptTime) / 1e3
+			sumX, cX = floats.KahanSumInc(x, sumX, cX)
+			sumY, cY = floats.KahanSumInc(p.F, sumY, cY)
+			sumXY, cXY = floats.KahanSumInc(x*p.F, sumXY, cXY)
+			sumX2, cX2 = floats.KahanSumInc(x*x, sumX2, cX2)
+		}
+	}
+
+	accumulate(head)
+	accumulate(tail)
+
+	if constY {
+		if math.IsInf(initY, 0) {
+			return math.NaN(), math.NaN()
+		}
+
+		return 0, initY
+	}
+
+	sumX += cX
+	sumY += cY
+	sumXY += cXY
+	sumX2 += cX2
+
+	covXY := sumXY - sumX*sumY/n
+	varX := sumX2 - sumX*sumX/n
+
+	slope = covXY / varX
+	intercept = sumY/n - slope*sumX/n
+
+	return slope, intercept
+}
@@ -0,0 +1,293 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/functions.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package functions
+
+import (
+	"errors"
+
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+var Rate = extrapolatedRate(true, true)
+var Increase = extrapolatedRate(true, false)
+var Delta = extrapolatedRate(false, false)
+
+var Irate = instantValue(true)
+var Idelta = instantValue(false)
+
+// extrapolatedRate returns a RangeVectorStepFunction for rate, increase and delta.
+// It calculates the rate (allowing for counter resets if isCounter is true),
+// extrapolates if the first/last sample is close to the boundary, and returns
+// the result as either per-second (if isRate is true) or overall.
+func extrapolatedRate(isCounter, isRate bool) RangeVectorStepFunction {
+	return func(step types.RangeVectorStepData, rangeSeconds float64, floatBuffer *types.FPointRingBuffer, histogramBuffer *types.HPointRingBuffer, _ []float64, _ *pooling.LimitingPool) (float64, bool, *histogram.FloatHistogram, error) {
+		fHead, fTail := floatBuffer.UnsafePoints(step.RangeEnd)
+		fCount := len(fHead) + len(fTail)
+
+		hHead, hTail := histogramBuffer.UnsafePoints(step.RangeEnd)
+		hCount := len(hHead) + len(hTail)
+
+		if fCount > 0 && hCount > 0 {
+			// We need either at least two Histograms and no Floats, or at least two
+			// Floats and no Histograms to calculate a rate. Otherwise, drop this
+			// Vector element.
+			return 0, false, nil, nil
+		}
+
+		if fCount >= 2 {
+			val := floatRate(isCounter, isRate, step, rangeSeconds, fHead, fTail, fCount)
+			return val, true, nil, nil
+		}
+
+		if hCount >= 2 {
+			val, err := histogramRate(isCounter, isRate, step, rangeSeconds, hHead, hTail, hCount)
+			return 0, false, val, err
+		}
+
+		return 0, false, nil, nil
+	}
+}
+
+func floatRate(isCounter, isRate bool, step types.RangeVectorStepData, rangeSeconds float64, fHead []promql.FPoint, fTail []promql.FPoint, count int) float64 {
+	firstPoint := fHead[0]
+	lastPoint := lastFPoint(fHead, fTail)
+	delta := lastPoint.F - firstPoint.F
+
+	if isCounter {
+		previousValue := firstPoint.F
+
+		accumulate := func(points []promql.FPoint) {
+			for _, p := range points {
+				if p.F < previousValue {
+					// Counter reset.
+					delta += previousValue
+				}
+
+				previousValue = p.F
+			}
+		}
+
+		accumulate(fHead)
+		accumulate(fTail)
+	}
+
+	return calculateFloatRate(isCounter, isRate, step.RangeStart, step.RangeEnd, rangeSeconds, firstPoint, lastPoint, delta, count)
+}
+
+func histogramRate(isCounter, isRate bool, step types.RangeVectorStepData, rangeSeconds float64, hHead []promql.HPoint, hTail []promql.HPoint, count int) (*histogram.FloatHistogram, error) {
+	firstPoint := hHead[0]
+	lastPoint := lastHPoint(hHead, hTail)
+
+	minSchema := min(firstPoint.H.Schema, lastPoint.H.Schema)
+
+	if isCounter {
+		// Find the smallest schema used by any point, as the result will need to use this schema.
+		for _, p := range hHead {
+			minSchema = min(minSchema, p.H.Schema)
+		}
+
+		for _, p := range hTail {
+			minSchema = min(minSchema, p.H.Schema)
+		}
+	}
+
+	delta := lastPoint.H.CopyToSchema(minSchema)
+	if _, err := delta.Sub(firstPoint.H); err != nil {
+		return nil, ignoreIncompatibleHistogramsError(err)
+	}
+
+	if isCounter {
+		previousValue := firstPoint.H
+
+		accumulate := func(points []promql.HPoint) error {
+			for _, p := range points {
+				if p.H.DetectReset(previousValue) {
+					// Counter reset.
+					if _, err := delta.Add(previousValue); err != nil {
+						return err
+					}
+				}
+
+				previousValue = p.H
+			}
+
+			return nil
+		}
+
+		if err := accumulate(hHead[1:]); err != nil {
+			return nil, ignoreIncompatibleHistogramsError(err)
+		}
+
+		if err := accumulate(hTail); err != nil {
+			return nil, ignoreIncompatibleHistogramsError(err)
+		}
+	}
+
+	delta.CounterResetHint = histogram.GaugeType
+	delta.Compact(0)
+
+	return calculateHistogramRate(isRate, step.RangeStart, step.RangeEnd, rangeSeconds, firstPoint, lastPoint, delta, count), nil
+}
+
+// ignoreIncompatibleHistogramsError returns nil if err indicates that histograms could not be combined because they
+// are incompatible, or err otherwise.
+//
+// Prometheus' engine drops the output point in this case rather than failing the query, so we do the same.
+func ignoreIncompatibleHistogramsError(err error) error {
+	if errors.Is(err, histogram.ErrHistogramsIncompatibleSchema) || errors.Is(err, histogram.ErrHistogramsIncompatibleBounds) {
+		return nil
+	}
+
+	return err
+}
+
+// This is based on extrapolatedRate from promql/functions.go.
+// https://github.com/prometheus/prometheus/pull/13725 has a good explanation of the intended behaviour here.
+func calculateHistogramRate(isRate bool, rangeStart, rangeEnd int64, rangeSeconds float64, firstPoint, lastPoint promql.HPoint, delta *histogram.FloatHistogram, count int) *histogram.FloatHistogram {
+	durationToStart := float64(firstPoint.T-rangeStart) / 1000
+	durationToEnd := float64(rangeEnd-lastPoint.T) / 1000
+
+	sampledInterval := float64(lastPoint.T-firstPoint.T) / 1000
+	averageDurationBetweenSamples := sampledInterval / float64(count-1)
+
+	extrapolationThreshold := averageDurationBetweenSamples * 1.1
+	extrapolateToInterval := sampledInterval
+
+	if durationToStart >= extrapolationThreshold {
+		durationToStart = averageDurationBetweenSamples / 2
+	}
+
+	extrapolateToInterval += durationToStart
+
+	if durationToEnd >= extrapolationThreshold {
+		durationToEnd = averageDurationBetweenSamples / 2
+	}
+
+	extrapolateToInterval += durationToEnd
+
+	factor := extrapolateToInterval / sampledInterval
+	if isRate {
+		factor /= rangeSeconds
+	}
+
+	return delta.Mul(factor)
+}
+
+// This is based on extrapolatedRate from promql/functions.go.
+// https://github.com/prometheus/prometheus/pull/13725 has a good explanation of the intended behaviour here.
+func calculateFloatRate(isCounter, isRate bool, rangeStart, rangeEnd int64, rangeSeconds float64, firstPoint, lastPoint promql.FPoint, delta float64, count int) float64 {
+	durationToStart := float64(firstPoint.T-rangeStart) / 1000
+	durationToEnd := float64(rangeEnd-lastPoint.T) / 1000
+
+	sampledInterval := float64(lastPoint.T-firstPoint.T) / 1000
+	averageDurationBetweenSamples := sampledInterval / float64(count-1)
+
+	extrapolationThreshold := averageDurationBetweenSamples * 1.1
+	extrapolateToInterval := sampledInterval
+
+	if durationToStart >= extrapolationThreshold {
+		durationToStart = averageDurationBetweenSamples / 2
+	}
+
+	if isCounter && delta > 0 && firstPoint.F >= 0 {
+		// Counters cannot be negative. If we have any slope at all
+		// (i.e. delta went up), we can extrapolate the zero point
+		// of the counter. If the duration to the zero point is shorter
+		// than the durationToStart, we take the zero point as the start
+		// of the series, thereby avoiding extrapolation to negative
+		// counter values.
+		durationToZero := sampledInterval * (firstPoint.F / delta)
+		if durationToZero < durationToStart {
+			durationToStart = durationToZero
+		}
+	}
+
+	extrapolateToInterval += durationToStart
+
+	if durationToEnd >= extrapolationThreshold {
+		durationToEnd = averageDurationBetweenSamples / 2
+	}
+
+	extrapolateToInterval += durationToEnd
+
+	factor := extrapolateToInterval / sampledInterval
+	if isRate {
+		factor /= rangeSeconds
+	}
+
+	return delta * factor
+}
+
+// instantValue returns a RangeVectorStepFunction for irate and idelta.
+// It calculates the difference between the last two float points in the range (allowing for counter resets if
+// isRate is true), and returns the result as either per-second (if isRate is true) or overall.
+func instantValue(isRate bool) RangeVectorStepFunction {
+	return func(step types.RangeVectorStepData, _ float64, floatBuffer *types.FPointRingBuffer, _ *types.HPointRingBuffer, _ []float64, _ *pooling.LimitingPool) (float64, bool, *histogram.FloatHistogram, error) {
+		head, tail := floatBuffer.UnsafePoints(step.RangeEnd)
+
+		// No sense in trying to compute a rate without at least two points. Drop
+		// this Vector element.
+		if len(head)+len(tail) < 2 {
+			return 0, false, nil, nil
+		}
+
+		var previousSample, lastSample promql.FPoint
+
+		switch len(tail) {
+		case 0:
+			previousSample, lastSample = head[len(head)-2], head[len(head)-1]
+		case 1:
+			previousSample, lastSample = head[len(head)-1], tail[0]
+		default:
+			previousSample, lastSample = tail[len(tail)-2], tail[len(tail)-1]
+		}
+
+		var resultValue float64
+		if isRate && lastSample.F < previousSample.F {
+			// Counter reset.
+			resultValue = lastSample.F
+		} else {
+			resultValue = lastSample.F - previousSample.F
+		}
+
+		sampledInterval := lastSample.T - previousSample.T
+		if sampledInterval == 0 {
+			// Avoid dividing by 0.
+			return 0, false, nil, nil
+		}
+
+		if isRate {
+			// Convert to per-second.
+			resultValue /= float64(sampledInterval) / 1000
+		}
+
+		return resultValue, true, nil, nil
+	}
+}
+
+// lastFPoint returns the last point in head and tail, as returned by FPointRingBuffer.UnsafePoints.
+// head must not be empty.
+func lastFPoint(head []promql.FPoint, tail []promql.FPoint) promql.FPoint {
+	if len(tail) > 0 {
+		return tail[len(tail)-1]
+	}
+
+	return head[len(head)-1]
+}
+
+// lastHPoint returns the last point in head and tail, as returned by HPointRingBuffer.UnsafePoints.
+// head must not be empty.
+func lastHPoint(head []promql.HPoint, tail []promql.HPoint) promql.HPoint {
+	if len(tail) > 0 {
+		return tail[len(tail)-1]
+	}
+
+	return head[len(head)-1]
+}
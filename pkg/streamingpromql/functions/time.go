@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/functions.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package functions
+
+import (
+	"time"
+
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+var DaysInMonth = dateFunc(func(t time.Time) float64 {
+	return float64(32 - time.Date(t.Year(), t.Month(), 32, 0, 0, 0, 0, time.UTC).Day())
+})
+
+var DayOfMonth = dateFunc(func(t time.Time) float64 {
+	return float64(t.Day())
+})
+
+var DayOfWeek = dateFunc(func(t time.Time) float64 {
+	return float64(t.Weekday())
+})
+
+var DayOfYear = dateFunc(func(t time.Time) float64 {
+	return float64(t.YearDay())
+})
+
+var Hour = dateFunc(func(t time.Time) float64 {
+	return float64(t.Hour())
+})
+
+var Minute = dateFunc(func(t time.Time) float64 {
+	return float64(t.Minute())
+})
+
+var Month = dateFunc(func(t time.Time) float64 {
+	return float64(t.Month())
+})
+
+var Year = dateFunc(func(t time.Time) float64 {
+	return float64(t.Year())
+})
+
+// dateFunc returns an InstantVectorFunction that interprets each float value as a Unix timestamp in seconds,
+// and computes f for that time in UTC.
+func dateFunc(f func(t time.Time) float64) InstantVectorFunction {
+	return FloatTransformationDropHistogramsFunc(func(v float64) float64 {
+		return f(time.Unix(int64(v), 0).UTC())
+	})
+}
+
+// Timestamp implements the timestamp() function for all arguments other than a vector selector: the value of each
+// point is the timestamp of its time step, in seconds.
+//
+// timestamp() over a vector selector returns the timestamp of each sample instead, and this is handled by the selector.
+func Timestamp(seriesData types.InstantVectorSeriesData, _ []types.ScalarData, pool *pooling.LimitingPool) (types.InstantVectorSeriesData, error) {
+	if len(seriesData.Histograms) == 0 {
+		for i, p := range seriesData.Floats {
+			seriesData.Floats[i].F = float64(p.T) / 1000
+		}
+
+		return seriesData, nil
+	}
+
+	// Unlike most other functions, timestamp() returns a value for histograms as well as floats, so we need to
+	// merge both into a single slice of floats, ordered by timestamp.
+	output, err := pool.GetFPointSlice(len(seriesData.Floats) + len(seriesData.Histograms))
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	floatIdx, histogramIdx := 0, 0
+
+	for floatIdx < len(seriesData.Floats) || histogramIdx < len(seriesData.Histograms) {
+		var t int64
+
+		if histogramIdx == len(seriesData.Histograms) || (floatIdx < len(seriesData.Floats) && seriesData.Floats[floatIdx].T < seriesData.Histograms[histogramIdx].T) {
+			t = seriesData.Floats[floatIdx].T
+			floatIdx++
+		} else {
+			t = seriesData.Histograms[histogramIdx].T
+			histogramIdx++
+		}
+
+		output = append(output, promql.FPoint{T: t, F: float64(t) / 1000})
+	}
+
+	pool.PutInstantVectorSeriesData(seriesData)
+
+	return types.InstantVectorSeriesData{Floats: output}, nil
+}
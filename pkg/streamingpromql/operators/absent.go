@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/functions.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package operators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// Absent implements the absent() function.
+//
+// It returns a single series with value 1 at each step where no series in Inner has a point, or no series if every
+// step has at least one point.
+//
+// Determining whether there is an output series requires reading every series from Inner, so all series are read
+// in SeriesMetadata, and only the presence of points at each step is retained.
+type Absent struct {
+	Inner    types.InstantVectorOperator
+	Labels   labels.Labels // The labels of the output series.
+	Start    int64         // Milliseconds since Unix epoch
+	Interval int64         // In milliseconds
+	Steps    int
+	Pool     *pooling.LimitingPool
+
+	presence []bool // presence[i] is true if any series in Inner has a point at step i.
+	consumed bool
+}
+
+var _ types.InstantVectorOperator = &Absent{}
+
+func NewAbsent(
+	inner types.InstantVectorOperator,
+	lbls labels.Labels,
+	start time.Time,
+	end time.Time,
+	interval time.Duration,
+	pool *pooling.LimitingPool,
+) *Absent {
+	s, e, i := timestamp.FromTime(start), timestamp.FromTime(end), interval.Milliseconds()
+
+	return &Absent{
+		Inner:    inner,
+		Labels:   lbls,
+		Start:    s,
+		Interval: i,
+		Steps:    stepCount(s, e, i),
+		Pool:     pool,
+	}
+}
+
+func (a *Absent) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	innerMetadata, err := a.Inner.SeriesMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seriesCount := len(innerMetadata)
+	pooling.PutSeriesMetadataSlice(innerMetadata)
+
+	a.presence, err = a.Pool.GetBoolSlice(a.Steps)
+	if err != nil {
+		return nil, err
+	}
+
+	a.presence = a.presence[:a.Steps]
+
+	for i := 0; i < seriesCount; i++ {
+		d, err := a.Inner.NextSeries(ctx)
+		if err != nil {
+			if errors.Is(err, types.EOS) {
+				return nil, fmt.Errorf("expected %v series, but only received %v", seriesCount, i)
+			}
+
+			return nil, err
+		}
+
+		for _, p := range d.Floats {
+			a.presence[(p.T-a.Start)/a.Interval] = true
+		}
+
+		for _, p := range d.Histograms {
+			a.presence[(p.T-a.Start)/a.Interval] = true
+		}
+
+		a.Pool.PutInstantVectorSeriesData(d)
+	}
+
+	for _, present := range a.presence {
+		if !present {
+			metadata := pooling.GetSeriesMetadataSlice(1)
+			metadata = append(metadata, types.SeriesMetadata{Labels: a.Labels})
+
+			return metadata, nil
+		}
+	}
+
+	// Every step has at least one point, so there is no output series.
+	a.consumed = true
+
+	return nil, nil
+}
+
+func (a *Absent) NextSeries(_ context.Context) (types.InstantVectorSeriesData, error) {
+	if a.consumed {
+		return types.InstantVectorSeriesData{}, types.EOS
+	}
+
+	a.consumed = true
+
+	absentCount := 0
+	for _, present := range a.presence {
+		if !present {
+			absentCount++
+		}
+	}
+
+	points, err := a.Pool.GetFPointSlice(absentCount)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	for stepIdx, present := range a.presence {
+		if !present {
+			points = append(points, promql.FPoint{T: a.Start + int64(stepIdx)*a.Interval, F: 1})
+		}
+	}
+
+	return types.InstantVectorSeriesData{Floats: points}, nil
+}
+
+func (a *Absent) Close() {
+	a.Inner.Close()
+
+	a.Pool.PutBoolSlice(a.presence)
+	a.presence = nil
+}
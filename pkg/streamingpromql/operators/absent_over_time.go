@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/engine.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package operators
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// AbsentOverTime implements the absent_over_time() function.
+//
+// It returns a single series with value 1 at each step where no series in Inner has any points in the range selected
+// at that step, or no series if every step has at least one point.
+//
+// Determining whether there is an output series requires reading every series from Inner, so all series are read
+// in SeriesMetadata, and only the presence of points at each step is retained.
+type AbsentOverTime struct {
+	Inner    types.RangeVectorOperator
+	Labels   labels.Labels // The labels of the output series.
+	Start    int64         // Milliseconds since Unix epoch
+	Interval int64         // In milliseconds
+	Steps    int
+	Pool     *pooling.LimitingPool
+
+	presence []bool // presence[i] is true if any series in Inner has a point at step i.
+	consumed bool
+}
+
+var _ types.InstantVectorOperator = &AbsentOverTime{}
+
+func NewAbsentOverTime(
+	inner types.RangeVectorOperator,
+	lbls labels.Labels,
+	start time.Time,
+	end time.Time,
+	interval time.Duration,
+	pool *pooling.LimitingPool,
+) *AbsentOverTime {
+	s, e, i := timestamp.FromTime(start), timestamp.FromTime(end), interval.Milliseconds()
+
+	return &AbsentOverTime{
+		Inner:    inner,
+		Labels:   lbls,
+		Start:    s,
+		Interval: i,
+		Steps:    stepCount(s, e, i),
+		Pool:     pool,
+	}
+}
+
+func (a *AbsentOverTime) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	innerMetadata, err := a.Inner.SeriesMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seriesCount := len(innerMetadata)
+	pooling.PutSeriesMetadataSlice(innerMetadata)
+
+	a.presence, err = a.Pool.GetBoolSlice(a.Steps)
+	if err != nil {
+		return nil, err
+	}
+
+	a.presence = a.presence[:a.Steps]
+
+	if err := a.readAllSeries(ctx, seriesCount); err != nil {
+		return nil, err
+	}
+
+	for _, present := range a.presence {
+		if !present {
+			metadata := pooling.GetSeriesMetadataSlice(1)
+			metadata = append(metadata, types.SeriesMetadata{Labels: a.Labels})
+
+			return metadata, nil
+		}
+	}
+
+	// Every step has at least one point, so there is no output series.
+	a.consumed = true
+
+	return nil, nil
+}
+
+func (a *AbsentOverTime) readAllSeries(ctx context.Context, seriesCount int) error {
+	floats := types.NewFPointRingBuffer(a.Pool)
+	defer floats.Close()
+
+	histograms := types.NewHPointRingBuffer(a.Pool)
+	defer histograms.Close()
+
+	for i := 0; i < seriesCount; i++ {
+		if err := a.Inner.NextSeries(ctx); err != nil {
+			return err
+		}
+
+		floats.Reset()
+		histograms.Reset()
+
+		for stepIdx := 0; ; stepIdx++ {
+			step, err := a.Inner.NextStepSamples(floats, histograms)
+
+			// nolint:errorlint // errors.Is introduces a performance overhead, and NextStepSamples is guaranteed to return exactly EOS, never a wrapped error.
+			if err == types.EOS {
+				break
+			} else if err != nil {
+				return err
+			}
+
+			if a.presence[stepIdx] {
+				continue
+			}
+
+			fHead, _ := floats.UnsafePoints(step.RangeEnd)
+			hHead, _ := histograms.UnsafePoints(step.RangeEnd)
+			a.presence[stepIdx] = len(fHead) > 0 || len(hHead) > 0
+		}
+	}
+
+	return nil
+}
+
+func (a *AbsentOverTime) NextSeries(_ context.Context) (types.InstantVectorSeriesData, error) {
+	if a.consumed {
+		return types.InstantVectorSeriesData{}, types.EOS
+	}
+
+	a.consumed = true
+
+	absentCount := 0
+	for _, present := range a.presence {
+		if !present {
+			absentCount++
+		}
+	}
+
+	points, err := a.Pool.GetFPointSlice(absentCount)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	for stepIdx, present := range a.presence {
+		if !present {
+			points = append(points, promql.FPoint{T: a.Start + int64(stepIdx)*a.Interval, F: 1})
+		}
+	}
+
+	return types.InstantVectorSeriesData{Floats: points}, nil
+}
+
+func (a *AbsentOverTime) Close() {
+	a.Inner.Close()
+
+	a.Pool.PutBoolSlice(a.presence)
+	a.presence = nil
+}
@@ -13,12 +13,13 @@ import (
 	"sort"
 	"time"
 
-	"github.com/prometheus/prometheus/model/histogram"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/timestamp"
-	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/util/zeropool"
 
+	"github.com/grafana/mimir/pkg/streamingpromql/aggregations"
+	"github.com/grafana/mimir/pkg/streamingpromql/compat"
 	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
 	"github.com/grafana/mimir/pkg/streamingpromql/types"
 )
@@ -31,8 +32,11 @@ type Aggregation struct {
 	Steps    int
 	Grouping []string // If this is a 'without' aggregation, NewAggregation will ensure that this slice contains __name__.
 	Without  bool
+	Op       parser.ItemType
 	Pool     *pooling.LimitingPool
 
+	aggregationGroupFactory aggregations.AggregationGroupFactory
+
 	remainingInnerSeriesToGroup []*group // One entry per series produced by Inner, value is the group for that series
 	remainingGroups             []*group // One entry per group, in the order we want to return them
 }
@@ -44,18 +48,47 @@ func NewAggregation(
 	interval time.Duration,
 	grouping []string,
 	without bool,
+	op parser.ItemType,
 	pool *pooling.LimitingPool,
-) *Aggregation {
-	s, e, i := timestamp.FromTime(start), timestamp.FromTime(end), interval.Milliseconds()
+) (*Aggregation, error) {
+	opGroupFactory := aggregations.AggregationGroupFactories[op]
+	if opGroupFactory == nil {
+		return nil, compat.NewNotSupportedError(fmt.Sprintf("'%s' aggregation", op))
+	}
 
-	if without {
-		labelsToDrop := make([]string, 0, len(grouping)+1)
-		labelsToDrop = append(labelsToDrop, labels.MetricName)
-		labelsToDrop = append(labelsToDrop, grouping...)
-		grouping = labelsToDrop
+	return newAggregation(inner, start, end, interval, grouping, without, op, opGroupFactory, pool), nil
+}
+
+// NewQuantileAggregation creates an Aggregation that computes the φ-quantile q of each group.
+func NewQuantileAggregation(
+	inner types.InstantVectorOperator,
+	q float64,
+	start time.Time,
+	end time.Time,
+	interval time.Duration,
+	grouping []string,
+	without bool,
+	pool *pooling.LimitingPool,
+) *Aggregation {
+	factory := func() aggregations.AggregationGroup {
+		return aggregations.NewQuantileAggregationGroup(q)
 	}
 
-	slices.Sort(grouping)
+	return newAggregation(inner, start, end, interval, grouping, without, parser.QUANTILE, factory, pool)
+}
+
+func newAggregation(
+	inner types.InstantVectorOperator,
+	start time.Time,
+	end time.Time,
+	interval time.Duration,
+	grouping []string,
+	without bool,
+	op parser.ItemType,
+	groupFactory aggregations.AggregationGroupFactory,
+	pool *pooling.LimitingPool,
+) *Aggregation {
+	s, e, i := timestamp.FromTime(start), timestamp.FromTime(end), interval.Milliseconds()
 
 	return &Aggregation{
 		Inner:    inner,
@@ -63,12 +96,30 @@ func NewAggregation(
 		End:      e,
 		Interval: i,
 		Steps:    stepCount(s, e, i),
-		Grouping: grouping,
+		Grouping: sortedGroupingLabels(grouping, without),
 		Without:  without,
+		Op:       op,
 		Pool:     pool,
+
+		aggregationGroupFactory: groupFactory,
 	}
 }
 
+// sortedGroupingLabels returns the sorted list of labels to use for grouping.
+// If without is true, the returned slice also contains __name__, as 'without' aggregations always drop the metric name.
+func sortedGroupingLabels(grouping []string, without bool) []string {
+	if without {
+		labelsToDrop := make([]string, 0, len(grouping)+1)
+		labelsToDrop = append(labelsToDrop, labels.MetricName)
+		labelsToDrop = append(labelsToDrop, grouping...)
+		grouping = labelsToDrop
+	}
+
+	slices.Sort(grouping)
+
+	return grouping
+}
+
 type groupWithLabels struct {
 	labels labels.Labels
 	group  *group
@@ -82,11 +133,8 @@ type group struct {
 	// Used to sort groups in the order that they'll be completed in.
 	lastSeriesIndex int
 
-	// Sum, presence, and histograms for each step.
-	floatSums           []float64
-	floatPresent        []bool
-	histogramSums       []*histogram.FloatHistogram
-	histogramPointCount int
+	// The accumulated state of this group, specific to the aggregation operation.
+	aggregation aggregations.AggregationGroup
 }
 
 var _ types.InstantVectorOperator = &Aggregation{}
@@ -124,6 +172,7 @@ func (a *Aggregation) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadat
 			g.labels = groupLabelsFunc(series.Labels)
 			g.group = groupPool.Get()
 			g.group.remainingSeriesCount = 0
+			g.group.aggregation = a.aggregationGroupFactory()
 
 			groups[string(groupLabelsString)] = g
 		}
@@ -171,13 +220,18 @@ type seriesToGroupLabelsBytesFunc func(labels.Labels) []byte
 type seriesToGroupLabelsFunc func(labels.Labels) labels.Labels
 
 func (a *Aggregation) seriesToGroupFuncs() (seriesToGroupLabelsBytesFunc, seriesToGroupLabelsFunc) {
+	return seriesToGroupFuncs(a.Grouping, a.Without)
+}
+
+// seriesToGroupFuncs returns grouping functions for the given grouping labels, which must have been prepared with sortedGroupingLabels.
+func seriesToGroupFuncs(grouping []string, without bool) (seriesToGroupLabelsBytesFunc, seriesToGroupLabelsFunc) {
 	switch {
-	case a.Without:
-		return a.groupingWithoutLabelsSeriesToGroupFuncs()
-	case len(a.Grouping) == 0:
+	case without:
+		return groupingWithoutLabelsSeriesToGroupFuncs(grouping)
+	case len(grouping) == 0:
 		return groupToSingleSeriesLabelsBytesFunc, groupToSingleSeriesLabelsFunc
 	default:
-		return a.groupingByLabelsSeriesToGroupFuncs()
+		return groupingByLabelsSeriesToGroupFuncs(grouping)
 	}
 }
 
@@ -185,17 +239,17 @@ var groupToSingleSeriesLabelsBytesFunc = func(_ labels.Labels) []byte { return n
 var groupToSingleSeriesLabelsFunc = func(_ labels.Labels) labels.Labels { return labels.EmptyLabels() }
 
 // groupingWithoutLabelsSeriesToGroupFuncs returns grouping functions for aggregations that use 'without'.
-func (a *Aggregation) groupingWithoutLabelsSeriesToGroupFuncs() (seriesToGroupLabelsBytesFunc, seriesToGroupLabelsFunc) {
+func groupingWithoutLabelsSeriesToGroupFuncs(grouping []string) (seriesToGroupLabelsBytesFunc, seriesToGroupLabelsFunc) {
 	// Why 1024 bytes? It's what labels.Labels.String() uses as a buffer size, so we use that as a sensible starting point too.
 	b := make([]byte, 0, 1024)
 	bytesFunc := func(l labels.Labels) []byte {
-		return l.BytesWithoutLabels(b, a.Grouping...) // NewAggregation will add __name__ to Grouping for 'without' aggregations, so no need to add it here.
+		return l.BytesWithoutLabels(b, grouping...) // sortedGroupingLabels will add __name__ to grouping for 'without' aggregations, so no need to add it here.
 	}
 
 	lb := labels.NewBuilder(labels.EmptyLabels())
 	labelsFunc := func(m labels.Labels) labels.Labels {
 		lb.Reset(m)
-		lb.Del(a.Grouping...) // NewAggregation will add __name__ to Grouping for 'without' aggregations, so no need to add it here.
+		lb.Del(grouping...) // sortedGroupingLabels will add __name__ to grouping for 'without' aggregations, so no need to add it here.
 		l := lb.Labels()
 		return l
 	}
@@ -204,17 +258,17 @@ func (a *Aggregation) groupingWithoutLabelsSeriesToGroupFuncs() (seriesToGroupLa
 }
 
 // groupingByLabelsSeriesToGroupFuncs returns grouping functions for aggregations that use 'by'.
-func (a *Aggregation) groupingByLabelsSeriesToGroupFuncs() (seriesToGroupLabelsBytesFunc, seriesToGroupLabelsFunc) {
+func groupingByLabelsSeriesToGroupFuncs(grouping []string) (seriesToGroupLabelsBytesFunc, seriesToGroupLabelsFunc) {
 	// Why 1024 bytes? It's what labels.Labels.String() uses as a buffer size, so we use that as a sensible starting point too.
 	b := make([]byte, 0, 1024)
 	bytesFunc := func(l labels.Labels) []byte {
-		return l.BytesWithLabels(b, a.Grouping...)
+		return l.BytesWithLabels(b, grouping...)
 	}
 
 	lb := labels.NewBuilder(labels.EmptyLabels())
 	labelsFunc := func(m labels.Labels) labels.Labels {
 		lb.Reset(m)
-		lb.Keep(a.Grouping...)
+		lb.Keep(grouping...)
 		l := lb.Labels()
 		return l
 	}
@@ -238,11 +292,12 @@ func (a *Aggregation) NextSeries(ctx context.Context) (types.InstantVectorSeries
 	}
 
 	// Construct the group and return it
-	seriesData, err := a.constructSeriesData(thisGroup, a.Start, a.Interval)
+	seriesData, err := thisGroup.aggregation.ComputeOutputSeries(a.Start, a.Interval, a.Pool)
 	if err != nil {
 		return types.InstantVectorSeriesData{}, err
 	}
 
+	thisGroup.aggregation = nil
 	groupPool.Put(thisGroup)
 	return seriesData, nil
 }
@@ -260,147 +315,13 @@ func (a *Aggregation) accumulateUntilGroupComplete(ctx context.Context, g *group
 
 		thisSeriesGroup := a.remainingInnerSeriesToGroup[0]
 		a.remainingInnerSeriesToGroup = a.remainingInnerSeriesToGroup[1:]
-		err = a.accumulateSeriesIntoGroup(s, thisSeriesGroup, a.Steps, a.Start, a.Interval)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func (a *Aggregation) constructSeriesData(thisGroup *group, start int64, interval int64) (types.InstantVectorSeriesData, error) {
-	floatPointCount := thisGroup.reconcileAndCountFloatPoints()
-	var floatPoints []promql.FPoint
-	var err error
-	if floatPointCount > 0 {
-		floatPoints, err = a.Pool.GetFPointSlice(floatPointCount)
-		if err != nil {
-			return types.InstantVectorSeriesData{}, err
-		}
-
-		for i, havePoint := range thisGroup.floatPresent {
-			if havePoint {
-				t := start + int64(i)*interval
-				floatPoints = append(floatPoints, promql.FPoint{T: t, F: thisGroup.floatSums[i]})
-			}
-		}
-	}
-
-	var histogramPoints []promql.HPoint
-	if thisGroup.histogramPointCount > 0 {
-		histogramPoints, err = a.Pool.GetHPointSlice(thisGroup.histogramPointCount)
-		if err != nil {
-			return types.InstantVectorSeriesData{}, err
-		}
-
-		for i, h := range thisGroup.histogramSums {
-			if h != nil {
-				t := start + int64(i)*interval
-				histogramPoints = append(histogramPoints, promql.HPoint{T: t, H: thisGroup.histogramSums[i]})
-			}
-		}
-	}
-
-	a.Pool.PutFloatSlice(thisGroup.floatSums)
-	a.Pool.PutBoolSlice(thisGroup.floatPresent)
-	a.Pool.PutHistogramPointerSlice(thisGroup.histogramSums)
-	thisGroup.floatSums = nil
-	thisGroup.floatPresent = nil
-	thisGroup.histogramSums = nil
-	thisGroup.histogramPointCount = 0
-
-	return types.InstantVectorSeriesData{Floats: floatPoints, Histograms: histogramPoints}, nil
-}
-
-// reconcileAndCountFloatPoints will return the number of points with a float present.
-// It also takes the opportunity whilst looping through the floats to check if there
-// is a conflicting Histogram present. If both are present, an empty vector should
-// be returned. So this method removes the float+histogram where they conflict.
-func (g *group) reconcileAndCountFloatPoints() int {
-	// It would be possible to calculate the number of points when constructing
-	// the series groups. However, it requires checking each point at each input
-	// series which is more costly than looping again here and just checking each
-	// point of the already grouped series.
-	// See: https://github.com/grafana/mimir/pull/8442
-	// We also take two different approaches here: One with extra checks if we
-	// have both Floats and Histograms present, and one without these checks
-	// so we don't have to do it at every point.
-	floatPointCount := 0
-	if len(g.floatPresent) > 0 && len(g.histogramSums) > 0 {
-		for idx, present := range g.floatPresent {
-			if present {
-				if g.histogramSums[idx] != nil {
-					// If a mix of histogram samples and float samples, the corresponding vector element is removed from the output vector entirely.
-					g.floatPresent[idx] = false
-					g.histogramSums[idx] = nil
-					g.histogramPointCount--
-				} else {
-					floatPointCount++
-				}
-			}
-		}
-	} else {
-		for _, p := range g.floatPresent {
-			if p {
-				floatPointCount++
-			}
-		}
-	}
-	return floatPointCount
-}
-
-func (a *Aggregation) accumulateSeriesIntoGroup(s types.InstantVectorSeriesData, seriesGroup *group, steps int, start int64, interval int64) error {
-	var err error
-	if len(s.Floats) > 0 && seriesGroup.floatSums == nil {
-		// First series with float values for this group, populate it.
-		seriesGroup.floatSums, err = a.Pool.GetFloatSlice(steps)
-		if err != nil {
+		if err := thisSeriesGroup.aggregation.AccumulateSeries(s, a.Steps, a.Start, a.Interval, a.Pool); err != nil {
 			return err
 		}
 
-		seriesGroup.floatPresent, err = a.Pool.GetBoolSlice(steps)
-		if err != nil {
-			return err
-		}
-		seriesGroup.floatSums = seriesGroup.floatSums[:steps]
-		seriesGroup.floatPresent = seriesGroup.floatPresent[:steps]
+		a.Pool.PutInstantVectorSeriesData(s)
+		thisSeriesGroup.remainingSeriesCount--
 	}
-
-	if len(s.Histograms) > 0 && seriesGroup.histogramSums == nil {
-		// First series with histogram values for this group, populate it.
-		seriesGroup.histogramSums, err = a.Pool.GetHistogramPointerSlice(steps)
-		if err != nil {
-			return err
-		}
-		seriesGroup.histogramSums = seriesGroup.histogramSums[:steps]
-	}
-
-	for _, p := range s.Floats {
-		idx := (p.T - start) / interval
-		seriesGroup.floatSums[idx] += p.F
-		seriesGroup.floatPresent[idx] = true
-	}
-
-	for _, p := range s.Histograms {
-		idx := (p.T - start) / interval
-		if seriesGroup.histogramSums[idx] == nil {
-			// We copy here because we modify the histogram through Add later on.
-			// It is necessary to preserve the original Histogram in case of any range-queries using lookback.
-			seriesGroup.histogramSums[idx] = p.H.Copy()
-			// We already have to do the check if the histogram exists at this idx,
-			// so we can count the histogram points present at this point instead
-			// of needing to loop again later like we do for floats.
-			seriesGroup.histogramPointCount++
-		} else {
-			seriesGroup.histogramSums[idx], err = seriesGroup.histogramSums[idx].Add(p.H)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	a.Pool.PutInstantVectorSeriesData(s)
-	seriesGroup.remainingSeriesCount--
 	return nil
 }
 
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/mimir/pkg/streamingpromql/types"
@@ -75,10 +76,8 @@ func TestAggregation_ReturnsGroupsFinishedFirstEarliest(t *testing.T) {
 
 	for name, testCase := range testCases {
 		t.Run(name, func(t *testing.T) {
-			aggregator := &Aggregation{
-				Inner:    &testOperator{series: testCase.inputSeries},
-				Grouping: testCase.grouping,
-			}
+			aggregator, err := NewAggregation(&testOperator{series: testCase.inputSeries}, time.Time{}, time.Time{}, time.Minute, testCase.grouping, false, parser.SUM, nil)
+			require.NoError(t, err)
 
 			outputSeries, err := aggregator.SeriesMetadata(context.Background())
 			require.NoError(t, err)
@@ -231,7 +230,8 @@ func TestAggregation_GroupLabelling(t *testing.T) {
 
 	for name, testCase := range testCases {
 		t.Run(name, func(t *testing.T) {
-			aggregator := NewAggregation(nil, time.Time{}, time.Time{}, time.Minute, testCase.grouping, testCase.without, nil)
+			aggregator, err := NewAggregation(nil, time.Time{}, time.Time{}, time.Minute, testCase.grouping, testCase.without, parser.SUM, nil)
+			require.NoError(t, err)
 			bytesFunc, labelsFunc := aggregator.seriesToGroupFuncs()
 
 			actualLabels := labelsFunc(testCase.inputSeries)
@@ -0,0 +1,304 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/engine.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package operators
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// AndUnlessBinaryOperation represents the set operators "<expr> and <expr>" and "<expr> unless <expr>".
+//
+// Both operators return points from the left side, filtered by whether any series on the right side in the same
+// match group has a point at the same step: 'and' keeps a point only if there is such a point on the right side,
+// and 'unless' keeps a point only if there is no such point on the right side.
+//
+// The right side is never buffered as series: each right series is reduced to a single presence flag per step for its
+// match group as soon as it is read.
+type AndUnlessBinaryOperation struct {
+	Left     types.InstantVectorOperator
+	Right    types.InstantVectorOperator
+	IsUnless bool  // If true, this operator represents 'unless', otherwise it represents 'and'.
+	Start    int64 // Milliseconds since Unix epoch
+	Interval int64 // In milliseconds
+	Steps    int
+	Pool     *pooling.LimitingPool
+
+	VectorMatching parser.VectorMatching
+
+	remainingSeries       []andUnlessOutputSeries
+	leftBuffer            *binaryOperationSeriesBuffer
+	rightSeriesGroups     []*andUnlessMatchGroup // One entry per series produced by Right, value is the match group for that series (or nil if the series is not needed)
+	nextRightSeriesToRead int
+}
+
+var _ types.InstantVectorOperator = &AndUnlessBinaryOperation{}
+
+type andUnlessOutputSeries struct {
+	leftSeriesIndex int
+	group           *andUnlessMatchGroup // nil if there are no right series in this series' match group
+}
+
+type andUnlessMatchGroup struct {
+	lastRightSeriesIndex int
+
+	// The number of output series in this match group that we haven't yet returned.
+	remainingLeftSeriesCount int
+
+	// presence[i] is true if any right series in this match group has a point at step i.
+	presence []bool
+}
+
+func NewAndUnlessBinaryOperation(
+	left types.InstantVectorOperator,
+	right types.InstantVectorOperator,
+	vectorMatching parser.VectorMatching,
+	isUnless bool,
+	start time.Time,
+	end time.Time,
+	interval time.Duration,
+	pool *pooling.LimitingPool,
+) *AndUnlessBinaryOperation {
+	s, e, i := timestamp.FromTime(start), timestamp.FromTime(end), interval.Milliseconds()
+
+	return &AndUnlessBinaryOperation{
+		Left:           left,
+		Right:          right,
+		IsUnless:       isUnless,
+		VectorMatching: vectorMatching,
+		Start:          s,
+		Interval:       i,
+		Steps:          stepCount(s, e, i),
+		Pool:           pool,
+	}
+}
+
+func (a *AndUnlessBinaryOperation) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	leftMetadata, err := a.Left.SeriesMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(leftMetadata) == 0 {
+		// No series on left-hand side, we'll never have any output series.
+		pooling.PutSeriesMetadataSlice(leftMetadata)
+		return nil, nil
+	}
+
+	rightMetadata, err := a.Right.SeriesMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer pooling.PutSeriesMetadataSlice(rightMetadata)
+
+	if len(rightMetadata) == 0 && !a.IsUnless {
+		// No series on right-hand side, so 'and' will never have any output series.
+		pooling.PutSeriesMetadataSlice(leftMetadata)
+		return nil, nil
+	}
+
+	labelsFunc := matchGroupLabelsFunc(a.VectorMatching)
+	groups := map[string]*andUnlessMatchGroup{}
+	rightSeriesGroupsByIndex := make([]*andUnlessMatchGroup, len(rightMetadata))
+
+	for idx, s := range rightMetadata {
+		groupLabels := labelsFunc(s.Labels).String()
+		group, exists := groups[groupLabels]
+
+		if !exists {
+			group = &andUnlessMatchGroup{}
+			groups[groupLabels] = group
+		}
+
+		group.lastRightSeriesIndex = idx
+		rightSeriesGroupsByIndex[idx] = group
+	}
+
+	leftSeriesUsed, err := a.Pool.GetBoolSlice(len(leftMetadata))
+	if err != nil {
+		return nil, err
+	}
+
+	leftSeriesUsed = leftSeriesUsed[:len(leftMetadata)]
+	a.remainingSeries = make([]andUnlessOutputSeries, 0, len(leftMetadata))
+
+	for idx, s := range leftMetadata {
+		group := groups[labelsFunc(s.Labels).String()]
+
+		if group == nil && !a.IsUnless {
+			// No matching series on the right side, so this series will never have any points for 'and'.
+			continue
+		}
+
+		if group != nil {
+			group.remainingLeftSeriesCount++
+		}
+
+		leftSeriesUsed[idx] = true
+		a.remainingSeries = append(a.remainingSeries, andUnlessOutputSeries{leftSeriesIndex: idx, group: group})
+	}
+
+	// Only retain the match groups for right series that are needed by at least one left series.
+	for idx, group := range rightSeriesGroupsByIndex {
+		if group.remainingLeftSeriesCount == 0 {
+			rightSeriesGroupsByIndex[idx] = nil
+		}
+	}
+
+	a.rightSeriesGroups = rightSeriesGroupsByIndex
+	a.sortSeries(len(leftMetadata), len(rightMetadata))
+	a.leftBuffer = newBinaryOperationSeriesBuffer(a.Left, leftSeriesUsed, a.Pool)
+
+	outputMetadata := pooling.GetSeriesMetadataSlice(len(a.remainingSeries))
+	for _, s := range a.remainingSeries {
+		outputMetadata = append(outputMetadata, leftMetadata[s.leftSeriesIndex])
+	}
+
+	pooling.PutSeriesMetadataSlice(leftMetadata)
+
+	return outputMetadata, nil
+}
+
+// sortSeries sorts the output series in place to try to minimise the amount of data we'll need to buffer in memory.
+//
+// Like BinaryOperation.sortSeries, we read the side with the most series in order. If the right side has more series,
+// we return left series in order, and buffer the presence of each match group on the right side until it is no longer needed.
+// If the left side has more series, we return left series in the order their match groups are completed on the right side,
+// and buffer left series until they are needed.
+func (a *AndUnlessBinaryOperation) sortSeries(leftSeriesCount int, rightSeriesCount int) {
+	if leftSeriesCount >= rightSeriesCount {
+		// Left series are already in order.
+		return
+	}
+
+	slices.SortStableFunc(a.remainingSeries, func(x, y andUnlessOutputSeries) int {
+		return x.lastRightSeriesIndex() - y.lastRightSeriesIndex()
+	})
+}
+
+func (s andUnlessOutputSeries) lastRightSeriesIndex() int {
+	if s.group == nil {
+		return -1
+	}
+
+	return s.group.lastRightSeriesIndex
+}
+
+func (a *AndUnlessBinaryOperation) NextSeries(ctx context.Context) (types.InstantVectorSeriesData, error) {
+	if len(a.remainingSeries) == 0 {
+		return types.InstantVectorSeriesData{}, types.EOS
+	}
+
+	thisSeries := a.remainingSeries[0]
+	a.remainingSeries = a.remainingSeries[1:]
+
+	if thisSeries.group != nil {
+		if err := a.readRightSideUntilGroupComplete(ctx, thisSeries.group); err != nil {
+			return types.InstantVectorSeriesData{}, err
+		}
+	}
+
+	data, err := a.leftBuffer.getSingleSeries(ctx, thisSeries.leftSeriesIndex)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	if thisSeries.group == nil {
+		// 'unless' with no matching series on the right side: return the left series unchanged.
+		return data, nil
+	}
+
+	// Filter the points in place, reusing the slices from the left side.
+	floats := data.Floats[:0]
+	for _, p := range data.Floats {
+		if a.shouldKeepPoint(thisSeries.group, p.T) {
+			floats = append(floats, p)
+		}
+	}
+
+	histograms := data.Histograms[:0]
+	for _, p := range data.Histograms {
+		if a.shouldKeepPoint(thisSeries.group, p.T) {
+			histograms = append(histograms, p)
+		}
+	}
+
+	data.Floats = floats
+	data.Histograms = histograms
+
+	thisSeries.group.remainingLeftSeriesCount--
+	if thisSeries.group.remainingLeftSeriesCount == 0 {
+		a.Pool.PutBoolSlice(thisSeries.group.presence)
+		thisSeries.group.presence = nil
+	}
+
+	return data, nil
+}
+
+func (a *AndUnlessBinaryOperation) shouldKeepPoint(group *andUnlessMatchGroup, t int64) bool {
+	matched := group.presence != nil && group.presence[(t-a.Start)/a.Interval]
+
+	return matched != a.IsUnless
+}
+
+// readRightSideUntilGroupComplete reads series from the right side until all series in group have been read.
+func (a *AndUnlessBinaryOperation) readRightSideUntilGroupComplete(ctx context.Context, group *andUnlessMatchGroup) error {
+	for a.nextRightSeriesToRead <= group.lastRightSeriesIndex {
+		data, err := a.Right.NextSeries(ctx)
+		if err != nil {
+			return err
+		}
+
+		if g := a.rightSeriesGroups[a.nextRightSeriesToRead]; g != nil {
+			if err := a.updatePresence(g, data); err != nil {
+				return err
+			}
+		}
+
+		a.Pool.PutInstantVectorSeriesData(data)
+		a.nextRightSeriesToRead++
+	}
+
+	return nil
+}
+
+func (a *AndUnlessBinaryOperation) updatePresence(group *andUnlessMatchGroup, data types.InstantVectorSeriesData) error {
+	if group.presence == nil {
+		var err error
+		if group.presence, err = a.Pool.GetBoolSlice(a.Steps); err != nil {
+			return err
+		}
+
+		group.presence = group.presence[:a.Steps]
+	}
+
+	for _, p := range data.Floats {
+		group.presence[(p.T-a.Start)/a.Interval] = true
+	}
+
+	for _, p := range data.Histograms {
+		group.presence[(p.T-a.Start)/a.Interval] = true
+	}
+
+	return nil
+}
+
+func (a *AndUnlessBinaryOperation) Close() {
+	a.Left.Close()
+	a.Right.Close()
+
+	if a.leftBuffer != nil {
+		a.leftBuffer.close()
+	}
+}
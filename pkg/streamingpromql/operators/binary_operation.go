@@ -23,12 +23,16 @@ import (
 	"github.com/grafana/mimir/pkg/streamingpromql/types"
 )
 
-// BinaryOperation represents a binary operation between instant vectors such as "<expr> + <expr>" or "<expr> - <expr>".
+// BinaryOperation represents a one-to-one binary operation between instant vectors such as "<expr> + <expr>" or "<expr> - <expr>".
+//
+// One-to-many and many-to-one operations are handled by GroupedBinaryOperation, and the set operators 'and', 'or' and
+// 'unless' are handled by AndUnlessBinaryOperation and OrBinaryOperation.
 type BinaryOperation struct {
-	Left  types.InstantVectorOperator
-	Right types.InstantVectorOperator
-	Op    parser.ItemType
-	Pool  *pooling.LimitingPool
+	Left       types.InstantVectorOperator
+	Right      types.InstantVectorOperator
+	Op         parser.ItemType
+	ReturnBool bool
+	Pool       *pooling.LimitingPool
 
 	VectorMatching parser.VectorMatching
 
@@ -42,7 +46,8 @@ type BinaryOperation struct {
 	remainingSeries []*binaryOperationOutputSeries
 	leftBuffer      *binaryOperationSeriesBuffer
 	rightBuffer     *binaryOperationSeriesBuffer
-	opFunc          binaryOperationFunc
+	arithmeticFunc  binaryOperationFunc
+	comparisonFunc  binaryComparisonFunc
 }
 
 var _ types.InstantVectorOperator = &BinaryOperation{}
@@ -66,9 +71,11 @@ func (s binaryOperationOutputSeries) latestRightSeries() int {
 	return s.rightSeriesIndices[len(s.rightSeriesIndices)-1]
 }
 
-func NewBinaryOperation(left types.InstantVectorOperator, right types.InstantVectorOperator, vectorMatching parser.VectorMatching, op parser.ItemType, pool *pooling.LimitingPool) (*BinaryOperation, error) {
-	opFunc := arithmeticOperationFuncs[op]
-	if opFunc == nil {
+func NewBinaryOperation(left types.InstantVectorOperator, right types.InstantVectorOperator, vectorMatching parser.VectorMatching, op parser.ItemType, returnBool bool, pool *pooling.LimitingPool) (*BinaryOperation, error) {
+	arithmeticFunc := arithmeticOperationFuncs[op]
+	comparisonFunc := comparisonOperationFuncs[op]
+
+	if arithmeticFunc == nil && comparisonFunc == nil {
 		return nil, compat.NewNotSupportedError(fmt.Sprintf("binary expression with '%s'", op))
 	}
 
@@ -77,9 +84,11 @@ func NewBinaryOperation(left types.InstantVectorOperator, right types.InstantVec
 		Right:          right,
 		VectorMatching: vectorMatching,
 		Op:             op,
+		ReturnBool:     returnBool,
 		Pool:           pool,
 
-		opFunc: opFunc,
+		arithmeticFunc: arithmeticFunc,
+		comparisonFunc: comparisonFunc,
 	}, nil
 }
 
@@ -133,7 +142,6 @@ func (b *BinaryOperation) loadSeriesMetadata(ctx context.Context) (bool, error)
 	}
 
 	if len(b.leftMetadata) == 0 {
-		// FIXME: this is incorrect for 'or'
 		// No series on left-hand side, we'll never have any output series.
 		return false, nil
 	}
@@ -144,7 +152,6 @@ func (b *BinaryOperation) loadSeriesMetadata(ctx context.Context) (bool, error)
 	}
 
 	if len(b.rightMetadata) == 0 {
-		// FIXME: this is incorrect for 'or' and 'unless'
 		// No series on right-hand side, we'll never have any output series.
 		return false, nil
 	}
@@ -162,12 +169,12 @@ func (b *BinaryOperation) loadSeriesMetadata(ctx context.Context) (bool, error)
 // - a list indicating which series from the right side are needed to compute the output
 func (b *BinaryOperation) computeOutputSeries() ([]types.SeriesMetadata, []*binaryOperationOutputSeries, []bool, []bool, error) {
 	labelsFunc := b.labelsFunc()
+	outputLabelsFunc := b.outputLabelsFunc()
 	outputSeriesMap := map[string]*binaryOperationOutputSeries{}
 
 	// Use the smaller side to populate the map of possible output series first.
 	// This should ensure we don't unnecessarily populate the output series map with series that will never match in most cases.
 	// (It's possible that all the series on the larger side all belong to the same group, but this is expected to be rare.)
-	// FIXME: this doesn't work as-is for 'unless'.
 	smallerSide := b.leftMetadata
 	largerSide := b.rightMetadata
 	smallerSideIsLeftSide := len(b.leftMetadata) < len(b.rightMetadata)
@@ -204,14 +211,11 @@ func (b *BinaryOperation) computeOutputSeries() ([]types.SeriesMetadata, []*bina
 				series.leftSeriesIndices = append(series.leftSeriesIndices, idx)
 			}
 		}
-
-		// FIXME: if this is an 'or' operation, then we need to create the right side even if the left doesn't exist (or vice-versa)
 	}
 
 	// Remove series that cannot produce samples.
 	for seriesLabels, outputSeries := range outputSeriesMap {
 		if len(outputSeries.leftSeriesIndices) == 0 || len(outputSeries.rightSeriesIndices) == 0 {
-			// FIXME: this is incorrect for 'or' and 'unless'
 			// No matching series on at least one side for this output series, so output series will have no samples. Remove it.
 			delete(outputSeriesMap, seriesLabels)
 		}
@@ -235,7 +239,7 @@ func (b *BinaryOperation) computeOutputSeries() ([]types.SeriesMetadata, []*bina
 
 	for _, outputSeries := range outputSeriesMap {
 		firstSeriesLabels := b.leftMetadata[outputSeries.leftSeriesIndices[0]].Labels
-		allMetadata = append(allMetadata, types.SeriesMetadata{Labels: labelsFunc(firstSeriesLabels)})
+		allMetadata = append(allMetadata, types.SeriesMetadata{Labels: outputLabelsFunc(firstSeriesLabels)})
 		allSeries = append(allSeries, outputSeries)
 
 		for _, leftSeriesIndex := range outputSeries.leftSeriesIndices {
@@ -263,10 +267,6 @@ func (b *BinaryOperation) sortSeries(metadata []types.SeriesMetadata, series []*
 	// If we do this, then in the worst case, we'll have to buffer the whole of the lower cardinality side.
 	// (Compare this with sorting so that we read the lowest cardinality side in order: in the worst case, we'll have
 	// to buffer the whole of the higher cardinality side.)
-	//
-	// FIXME: this is reasonable for one-to-one matching, but likely not for one-to-many / many-to-one.
-	// For one-to-many / many-to-one, it would likely be best to buffer the side used for multiple output series (the "one" side),
-	// as we'll need to retain these series for multiple output series anyway.
 
 	var sortInterface sort.Interface
 
@@ -331,19 +331,54 @@ func (g favourRightSideSorter) Less(i, j int) bool {
 
 // labelsFunc returns a function that computes the labels of the output group this series belongs to.
 func (b *BinaryOperation) labelsFunc() func(labels.Labels) labels.Labels {
+	return matchGroupLabelsFunc(b.VectorMatching)
+}
+
+// outputLabelsFunc returns a function that computes the labels of the output series for an output group, given the labels of
+// a series from the left side in that group.
+//
+// This is the same as the labels of the output group, except for comparison operations without the bool modifier:
+// these act as a filter, and so retain the metric name from the left side, consistent with Prometheus' engine.
+//
+// If a group contains multiple left series with different metric names at different time steps, the output series
+// uses the metric name of the first series.
+func (b *BinaryOperation) outputLabelsFunc() func(labels.Labels) labels.Labels {
+	lb := labels.NewBuilder(labels.EmptyLabels())
+	dropMetricName := b.arithmeticFunc != nil || b.ReturnBool
+
+	return func(l labels.Labels) labels.Labels {
+		lb.Reset(l)
+
+		if b.VectorMatching.On {
+			lb.Keep(b.VectorMatching.MatchingLabels...)
+		} else {
+			lb.Del(b.VectorMatching.MatchingLabels...)
+		}
+
+		if dropMetricName {
+			lb.Del(labels.MetricName)
+		}
+
+		return lb.Labels()
+	}
+}
+
+// matchGroupLabelsFunc returns a function that computes the labels of the match group a series belongs to
+// for the given vector matching.
+func matchGroupLabelsFunc(vectorMatching parser.VectorMatching) func(labels.Labels) labels.Labels {
 	lb := labels.NewBuilder(labels.EmptyLabels())
 
-	if b.VectorMatching.On {
+	if vectorMatching.On {
 		return func(l labels.Labels) labels.Labels {
 			lb.Reset(l)
-			lb.Keep(b.VectorMatching.MatchingLabels...)
+			lb.Keep(vectorMatching.MatchingLabels...)
 			return lb.Labels()
 		}
 	}
 
 	return func(l labels.Labels) labels.Labels {
 		lb.Reset(l)
-		lb.Del(b.VectorMatching.MatchingLabels...)
+		lb.Del(vectorMatching.MatchingLabels...)
 		lb.Del(labels.MetricName)
 		return lb.Labels()
 	}
@@ -391,8 +426,6 @@ func (b *BinaryOperation) NextSeries(ctx context.Context) (types.InstantVectorSe
 // mergeOneSide will take in both series for left_side and return a single series with the points [1, 2, 3].
 //
 // mergeOneSide is optimised for the case where there is only one source series, or the source series do not overlap, as in the example above.
-//
-// FIXME: for many-to-one / one-to-many matching, we could avoid re-merging each time for the side used multiple times
 func (b *BinaryOperation) mergeOneSide(data []types.InstantVectorSeriesData, sourceSeriesIndices []int, sourceSeriesMetadata []types.SeriesMetadata, side string) (types.InstantVectorSeriesData, error) {
 	if len(data) == 1 {
 		// Fast path: if there's only one series on this side, there's no merging required.
@@ -413,7 +446,6 @@ func (b *BinaryOperation) mergeOneSide(data []types.InstantVectorSeriesData, sou
 	// We're going to create a new slice, so return this one to the pool.
 	// We'll return the other slices in the for loop below.
 	// We must defer here, rather than at the end, as the merge loop below reslices Floats.
-	// FIXME: this isn't correct for many-to-one / one-to-many matching - we'll need the series again (unless we store the result of the merge)
 	defer b.Pool.PutFPointSlice(data[0].Floats)
 
 	for i := 0; i < len(data)-1; i++ {
@@ -423,7 +455,6 @@ func (b *BinaryOperation) mergeOneSide(data []types.InstantVectorSeriesData, sou
 
 		// We're going to create a new slice, so return this one to the pool.
 		// We must defer here, rather than at the end, as the merge loop below reslices Floats.
-		// FIXME: this isn't correct for many-to-one / one-to-many matching - we'll need the series again (unless we store the result of the merge)
 		defer b.Pool.PutFPointSlice(second.Floats)
 
 		// Check if first overlaps with second.
@@ -500,10 +531,8 @@ func (b *BinaryOperation) mergeOneSide(data []types.InstantVectorSeriesData, sou
 func (b *BinaryOperation) computeResult(left types.InstantVectorSeriesData, right types.InstantVectorSeriesData) types.InstantVectorSeriesData {
 	var output []promql.FPoint
 
-	// For one-to-one matching for arithmetic operators, reuse one of the input slices to avoid allocating another slice.
+	// For one-to-one matching, reuse one of the input slices to avoid allocating another slice.
 	// We'll never produce more points than the smaller input side, so use that as our output slice.
-	//
-	// FIXME: this is not safe to do for one-to-many, many-to-one or many-to-many matching, as we may need the input series for later output series.
 	if len(left.Floats) < len(right.Floats) {
 		output = left.Floats[:0]
 		defer b.Pool.PutFPointSlice(right.Floats)
@@ -526,10 +555,12 @@ func (b *BinaryOperation) computeResult(left types.InstantVectorSeriesData, righ
 
 		if leftPoint.T == right.Floats[nextRightIndex].T {
 			// We have matching points on both sides, compute the result.
-			output = append(output, promql.FPoint{
-				F: b.opFunc(leftPoint.F, right.Floats[nextRightIndex].F),
-				T: leftPoint.T,
-			})
+			if f, keep := computeBinaryOperationPointResult(leftPoint.F, right.Floats[nextRightIndex].F, b.arithmeticFunc, b.comparisonFunc, b.ReturnBool); keep {
+				output = append(output, promql.FPoint{
+					F: f,
+					T: leftPoint.T,
+				})
+			}
 		}
 	}
 
@@ -669,3 +700,47 @@ var arithmeticOperationFuncs = map[parser.ItemType]binaryOperationFunc{
 	parser.POW:   math.Pow,
 	parser.ATAN2: math.Atan2,
 }
+
+type binaryComparisonFunc func(left, right float64) bool
+
+// computeBinaryOperationPointResult computes the result of a binary operation between points with values left and right.
+//
+// Exactly one of arithmeticFunc and comparisonFunc must be non-nil.
+//
+// It returns false if the point should not be included in the output, which is the case for comparison operations
+// without the bool modifier where the comparison is false.
+func computeBinaryOperationPointResult(left, right float64, arithmeticFunc binaryOperationFunc, comparisonFunc binaryComparisonFunc, returnBool bool) (float64, bool) {
+	if arithmeticFunc != nil {
+		return arithmeticFunc(left, right), true
+	}
+
+	keep := comparisonFunc(left, right)
+
+	if returnBool {
+		return boolToFloat(keep), true
+	}
+
+	// Comparison operations without the bool modifier act as a filter, and return the value from the left side.
+	return left, keep
+}
+
+var comparisonOperationFuncs = map[parser.ItemType]binaryComparisonFunc{
+	parser.EQLC: func(left, right float64) bool {
+		return left == right
+	},
+	parser.NEQ: func(left, right float64) bool {
+		return left != right
+	},
+	parser.GTR: func(left, right float64) bool {
+		return left > right
+	},
+	parser.LSS: func(left, right float64) bool {
+		return left < right
+	},
+	parser.GTE: func(left, right float64) bool {
+		return left >= right
+	},
+	parser.LTE: func(left, right float64) bool {
+		return left <= right
+	},
+}
@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/engine.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package operators
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// CountValues implements the count_values aggregation.
+//
+// The output series depend on the values of the input series, so CountValues reads all input series
+// in SeriesMetadata.
+type CountValues struct {
+	Inner     types.InstantVectorOperator
+	Start     int64 // Milliseconds since Unix epoch
+	Interval  int64 // In milliseconds
+	Steps     int
+	LabelName string
+	Grouping  []string // If this is a 'without' aggregation, NewCountValues will ensure that this slice contains __name__. If this is a 'by' aggregation, NewCountValues will ensure that this slice contains LabelName.
+	Without   bool
+	Pool      *pooling.LimitingPool
+
+	labelsBuilder   *labels.Builder
+	valueBytes      []byte
+	remainingGroups []*countValuesGroup // One entry per group, in the order we want to return them
+}
+
+type countValuesGroup struct {
+	labels labels.Labels
+	counts []int // One entry per step
+}
+
+var _ types.InstantVectorOperator = &CountValues{}
+
+func NewCountValues(
+	inner types.InstantVectorOperator,
+	labelName string,
+	start time.Time,
+	end time.Time,
+	interval time.Duration,
+	grouping []string,
+	without bool,
+	pool *pooling.LimitingPool,
+) (*CountValues, error) {
+	if !model.LabelName(labelName).IsValid() {
+		return nil, fmt.Errorf("invalid label name %q", labelName)
+	}
+
+	if !without {
+		grouping = append(slices.Clone(grouping), labelName)
+	}
+
+	s, e, i := timestamp.FromTime(start), timestamp.FromTime(end), interval.Milliseconds()
+
+	return &CountValues{
+		Inner:         inner,
+		Start:         s,
+		Interval:      i,
+		Steps:         stepCount(s, e, i),
+		LabelName:     labelName,
+		Grouping:      sortedGroupingLabels(grouping, without),
+		Without:       without,
+		Pool:          pool,
+		labelsBuilder: labels.NewBuilder(labels.EmptyLabels()),
+	}, nil
+}
+
+func (c *CountValues) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	innerSeries, err := c.Inner.SeriesMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer pooling.PutSeriesMetadataSlice(innerSeries)
+
+	groups := map[string]*countValuesGroup{}
+	groupLabelsBytesFunc, groupLabelsFunc := seriesToGroupFuncs(c.Grouping, c.Without)
+
+	for _, series := range innerSeries {
+		data, err := c.Inner.NextSeries(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range data.Floats {
+			c.valueBytes = strconv.AppendFloat(c.valueBytes[:0], p.F, 'f', -1, 64)
+			if err := c.incrementCount(series.Labels, p.T, groups, groupLabelsBytesFunc, groupLabelsFunc); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, p := range data.Histograms {
+			c.valueBytes = append(c.valueBytes[:0], p.H.String()...)
+			if err := c.incrementCount(series.Labels, p.T, groups, groupLabelsBytesFunc, groupLabelsFunc); err != nil {
+				return nil, err
+			}
+		}
+
+		c.Pool.PutInstantVectorSeriesData(data)
+	}
+
+	outputSeries := pooling.GetSeriesMetadataSlice(len(c.remainingGroups))
+
+	for _, g := range c.remainingGroups {
+		outputSeries = append(outputSeries, types.SeriesMetadata{Labels: g.labels})
+	}
+
+	return outputSeries, nil
+}
+
+func (c *CountValues) incrementCount(seriesLabels labels.Labels, t int64, groups map[string]*countValuesGroup, groupLabelsBytesFunc seriesToGroupLabelsBytesFunc, groupLabelsFunc seriesToGroupLabelsFunc) error {
+	c.labelsBuilder.Reset(seriesLabels)
+	c.labelsBuilder.Set(c.LabelName, string(c.valueBytes))
+	lbls := c.labelsBuilder.Labels()
+
+	groupLabelsString := groupLabelsBytesFunc(lbls)
+	g, groupExists := groups[string(groupLabelsString)] // Important: don't extract the string(...) call here - passing it directly allows us to avoid allocating it.
+
+	if !groupExists {
+		counts, err := c.Pool.GetIntSlice(c.Steps)
+		if err != nil {
+			return err
+		}
+
+		g = &countValuesGroup{
+			labels: groupLabelsFunc(lbls),
+			counts: counts[:c.Steps],
+		}
+
+		groups[string(groupLabelsString)] = g
+		c.remainingGroups = append(c.remainingGroups, g)
+	}
+
+	idx := (t - c.Start) / c.Interval
+	g.counts[idx]++
+
+	return nil
+}
+
+func (c *CountValues) NextSeries(_ context.Context) (types.InstantVectorSeriesData, error) {
+	if len(c.remainingGroups) == 0 {
+		return types.InstantVectorSeriesData{}, types.EOS
+	}
+
+	g := c.remainingGroups[0]
+	c.remainingGroups = c.remainingGroups[1:]
+
+	pointCount := 0
+	for _, count := range g.counts {
+		if count > 0 {
+			pointCount++
+		}
+	}
+
+	points, err := c.Pool.GetFPointSlice(pointCount)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	for idx, count := range g.counts {
+		if count > 0 {
+			t := c.Start + int64(idx)*c.Interval
+			points = append(points, promql.FPoint{T: t, F: float64(count)})
+		}
+	}
+
+	c.Pool.PutIntSlice(g.counts)
+
+	return types.InstantVectorSeriesData{Floats: points}, nil
+}
+
+func (c *CountValues) Close() {
+	c.Inner.Close()
+}
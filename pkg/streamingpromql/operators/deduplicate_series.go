@@ -0,0 +1,298 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/engine.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package operators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+var errDuplicateLabelSet = errors.New("vector cannot contain metrics with the same labelset")
+
+// DeduplicateSeries combines series from Inner with the same labels into a single output series.
+//
+// It is used after functions and operators that can change the labels of series, such as label_replace or the
+// functions dropping the metric name, to ensure the query result does not contain more than one series with the same
+// labels. Consistent with Prometheus' engine, it returns an error if more than one of the series with the same labels
+// has any points, or, if the points of the series are merged, if more than one of them has a point at the same timestamp.
+//
+// Only the series from each set of series with the same labels that has points, or their merged points, is retained,
+// so at most one series is held in memory for each set of series with the same labels.
+type DeduplicateSeries struct {
+	Inner types.InstantVectorOperator
+	Pool  *pooling.LimitingPool
+
+	// If true, the points of the series with the same labels are merged, like Prometheus' engine does for the functions
+	// and operators evaluated at each step. Otherwise, only one of them can have points, like for the functions over
+	// range vectors.
+	mergePoints bool
+
+	// If true, Inner does not produce any series with the same labels, and series from Inner are returned unchanged.
+	passthrough bool
+
+	remainingInnerSeriesToGroup []*deduplicationGroup // One entry per series produced by Inner, value is the group for that series
+	remainingGroups             []*deduplicationGroup // One entry per group, in the order we want to return them
+}
+
+var _ types.InstantVectorOperator = &DeduplicateSeries{}
+
+type deduplicationGroup struct {
+	remainingSeriesCount uint
+	lastSeriesIndex      int
+	data                 types.InstantVectorSeriesData
+	hasData              bool
+}
+
+// NewDeduplicateSeries returns a DeduplicateSeries failing if more than one series with the same labels has points.
+//
+// inner is instrumented, so that its statistics are still reported once wrapped.
+func NewDeduplicateSeries(inner types.InstantVectorOperator, pool *pooling.LimitingPool) *DeduplicateSeries {
+	return &DeduplicateSeries{
+		Inner: InstrumentInstantVectorOperator(inner, pool),
+		Pool:  pool,
+	}
+}
+
+// NewDeduplicateAndMergeSeries returns a DeduplicateSeries merging the points of the series with the same labels,
+// which fails only if more than one of them has a point at the same timestamp.
+func NewDeduplicateAndMergeSeries(inner types.InstantVectorOperator, pool *pooling.LimitingPool) *DeduplicateSeries {
+	return &DeduplicateSeries{
+		Inner:       InstrumentInstantVectorOperator(inner, pool),
+		Pool:        pool,
+		mergePoints: true,
+	}
+}
+
+func (d *DeduplicateSeries) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	innerMetadata, err := d.Inner.SeriesMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := map[string]*deduplicationGroup{}
+	d.remainingInnerSeriesToGroup = make([]*deduplicationGroup, 0, len(innerMetadata))
+	outputMetadata := pooling.GetSeriesMetadataSlice(len(innerMetadata))
+	buf := make([]byte, 0, 1024)
+
+	for seriesIdx, series := range innerMetadata {
+		buf = series.Labels.Bytes(buf)
+		g, exists := groups[string(buf)]
+
+		if !exists {
+			g = &deduplicationGroup{}
+			groups[string(buf)] = g
+			outputMetadata = append(outputMetadata, series)
+			d.remainingGroups = append(d.remainingGroups, g)
+		}
+
+		g.remainingSeriesCount++
+		g.lastSeriesIndex = seriesIdx
+		d.remainingInnerSeriesToGroup = append(d.remainingInnerSeriesToGroup, g)
+	}
+
+	if len(outputMetadata) == len(innerMetadata) {
+		// No series have the same labels, so there's nothing to do.
+		d.passthrough = true
+		d.remainingInnerSeriesToGroup = nil
+		d.remainingGroups = nil
+		pooling.PutSeriesMetadataSlice(outputMetadata)
+
+		return innerMetadata, nil
+	}
+
+	pooling.PutSeriesMetadataSlice(innerMetadata)
+
+	// Return groups in the order they'll be completed, so that we only need to hold one series from each
+	// set of series with the same labels in memory at a time.
+	sort.Sort(deduplicationGroupSorter{outputMetadata, d.remainingGroups})
+
+	return outputMetadata, nil
+}
+
+func (d *DeduplicateSeries) NextSeries(ctx context.Context) (types.InstantVectorSeriesData, error) {
+	if d.passthrough {
+		return d.Inner.NextSeries(ctx)
+	}
+
+	if len(d.remainingGroups) == 0 {
+		return types.InstantVectorSeriesData{}, types.EOS
+	}
+
+	thisGroup := d.remainingGroups[0]
+	d.remainingGroups = d.remainingGroups[1:]
+
+	for thisGroup.remainingSeriesCount > 0 {
+		s, err := d.Inner.NextSeries(ctx)
+		if err != nil {
+			if errors.Is(err, types.EOS) {
+				return types.InstantVectorSeriesData{}, fmt.Errorf("exhausted series before all groups were completed: %w", err)
+			}
+
+			return types.InstantVectorSeriesData{}, err
+		}
+
+		g := d.remainingInnerSeriesToGroup[0]
+		d.remainingInnerSeriesToGroup = d.remainingInnerSeriesToGroup[1:]
+		g.remainingSeriesCount--
+
+		if len(s.Floats) == 0 && len(s.Histograms) == 0 {
+			d.Pool.PutInstantVectorSeriesData(s)
+			continue
+		}
+
+		if !g.hasData {
+			g.data = s
+			g.hasData = true
+			continue
+		}
+
+		if !d.mergePoints {
+			d.Pool.PutInstantVectorSeriesData(s)
+			d.Pool.PutInstantVectorSeriesData(g.data)
+			g.data = types.InstantVectorSeriesData{}
+			return types.InstantVectorSeriesData{}, errDuplicateLabelSet
+		}
+
+		merged, err := d.merge(g.data, s)
+		g.data = merged
+		if err != nil {
+			return types.InstantVectorSeriesData{}, err
+		}
+	}
+
+	data := thisGroup.data
+	thisGroup.data = types.InstantVectorSeriesData{}
+
+	return data, nil
+}
+
+func (d *DeduplicateSeries) Close() {
+	d.Inner.Close()
+
+	for _, g := range d.remainingGroups {
+		d.Pool.PutInstantVectorSeriesData(g.data)
+		g.data = types.InstantVectorSeriesData{}
+	}
+
+	d.remainingGroups = nil
+	d.remainingInnerSeriesToGroup = nil
+}
+
+// merge merges the points of a and b, returning errDuplicateLabelSet if both have a point at the same timestamp.
+// The slices of a and b are either part of the returned series or returned to the pool, even if an error is returned.
+func (d *DeduplicateSeries) merge(a, b types.InstantVectorSeriesData) (types.InstantVectorSeriesData, error) {
+	if hasCommonTimestamp(a.Floats, b.Histograms) || hasCommonTimestamp(b.Floats, a.Histograms) {
+		d.Pool.PutInstantVectorSeriesData(a)
+		d.Pool.PutInstantVectorSeriesData(b)
+		return types.InstantVectorSeriesData{}, errDuplicateLabelSet
+	}
+
+	floats, err := mergePoints(a.Floats, b.Floats, d.Pool.GetFPointSlice, d.Pool.PutFPointSlice, func(p promql.FPoint) int64 { return p.T })
+	if err != nil {
+		d.Pool.PutInstantVectorSeriesData(a)
+		d.Pool.PutInstantVectorSeriesData(b)
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	histograms, err := mergePoints(a.Histograms, b.Histograms, d.Pool.GetHPointSlice, func(s []promql.HPoint) {
+		// The merged slice may hold the same histograms as s, so clear s to ensure whoever gets it next from the pool
+		// doesn't reuse (and overwrite) them.
+		clear(s)
+		d.Pool.PutHPointSlice(s)
+	}, func(p promql.HPoint) int64 { return p.T })
+	if err != nil {
+		d.Pool.PutFPointSlice(floats)
+		d.Pool.PutHPointSlice(a.Histograms)
+		d.Pool.PutHPointSlice(b.Histograms)
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	return types.InstantVectorSeriesData{Floats: floats, Histograms: histograms}, nil
+}
+
+// mergePoints merges the points of a and b, both sorted by timestamp, into a new slice from the pool, or returns
+// either of them if the other is empty. If no error is returned, the slices of a and b that aren't returned are
+// returned to the pool, otherwise they are left untouched.
+func mergePoints[P any](a, b []P, get func(int) ([]P, error), put func([]P), timestamp func(P) int64) ([]P, error) {
+	if len(a) == 0 {
+		put(a)
+		return b, nil
+	}
+	if len(b) == 0 {
+		put(b)
+		return a, nil
+	}
+
+	merged, err := get(len(a) + len(b))
+	if err != nil {
+		return nil, err
+	}
+
+	aIdx, bIdx := 0, 0
+	for aIdx < len(a) && bIdx < len(b) {
+		aT, bT := timestamp(a[aIdx]), timestamp(b[bIdx])
+		switch {
+		case aT == bT:
+			put(merged)
+			return nil, errDuplicateLabelSet
+		case aT < bT:
+			merged = append(merged, a[aIdx])
+			aIdx++
+		default:
+			merged = append(merged, b[bIdx])
+			bIdx++
+		}
+	}
+	merged = append(merged, a[aIdx:]...)
+	merged = append(merged, b[bIdx:]...)
+
+	put(a)
+	put(b)
+	return merged, nil
+}
+
+// hasCommonTimestamp returns true if any of floats has the same timestamp as any of histograms.
+func hasCommonTimestamp(floats []promql.FPoint, histograms []promql.HPoint) bool {
+	fIdx, hIdx := 0, 0
+	for fIdx < len(floats) && hIdx < len(histograms) {
+		switch {
+		case floats[fIdx].T == histograms[hIdx].T:
+			return true
+		case floats[fIdx].T < histograms[hIdx].T:
+			fIdx++
+		default:
+			hIdx++
+		}
+	}
+	return false
+}
+
+// deduplicationGroupSorter sorts groups, and the corresponding output series metadata, by the index of the last
+// series from Inner in each group.
+type deduplicationGroupSorter struct {
+	metadata []types.SeriesMetadata
+	groups   []*deduplicationGroup
+}
+
+func (s deduplicationGroupSorter) Len() int {
+	return len(s.metadata)
+}
+
+func (s deduplicationGroupSorter) Less(i, j int) bool {
+	return s.groups[i].lastSeriesIndex < s.groups[j].lastSeriesIndex
+}
+
+func (s deduplicationGroupSorter) Swap(i, j int) {
+	s.metadata[i], s.metadata[j] = s.metadata[j], s.metadata[i]
+	s.groups[i], s.groups[j] = s.groups[j], s.groups[i]
+}
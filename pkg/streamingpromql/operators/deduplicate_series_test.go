@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package operators
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// Most of the functionality of the deduplication operator is tested through the test scripts in
+// pkg/streamingpromql/testdata.
+//
+// The output sorting behaviour is impossible to test through these scripts, so we instead test it here.
+func TestDeduplicateSeries(t *testing.T) {
+	testCases := map[string]struct {
+		inputSeries []labels.Labels
+		inputData   []types.InstantVectorSeriesData
+		mergePoints bool
+
+		expectedOutputSeries []labels.Labels
+		expectedOutputData   []types.InstantVectorSeriesData
+		expectedError        error
+	}{
+		"no series": {
+			inputSeries:          []labels.Labels{},
+			inputData:            []types.InstantVectorSeriesData{},
+			expectedOutputSeries: []labels.Labels{},
+			expectedOutputData:   []types.InstantVectorSeriesData{},
+		},
+		"no duplicate series": {
+			inputSeries: []labels.Labels{
+				labels.FromStrings("pod", "1"),
+				labels.FromStrings("pod", "2"),
+			},
+			inputData: []types.InstantVectorSeriesData{
+				{Floats: []promql.FPoint{{T: 0, F: 1}}},
+				{Floats: []promql.FPoint{{T: 0, F: 2}}},
+			},
+			expectedOutputSeries: []labels.Labels{
+				labels.FromStrings("pod", "1"),
+				labels.FromStrings("pod", "2"),
+			},
+			expectedOutputData: []types.InstantVectorSeriesData{
+				{Floats: []promql.FPoint{{T: 0, F: 1}}},
+				{Floats: []promql.FPoint{{T: 0, F: 2}}},
+			},
+		},
+		"duplicate series, only one with points": {
+			inputSeries: []labels.Labels{
+				labels.FromStrings("pod", "1"),
+				labels.FromStrings("pod", "2"),
+				labels.FromStrings("pod", "1"),
+				labels.FromStrings("pod", "3"),
+			},
+			inputData: []types.InstantVectorSeriesData{
+				{},
+				{Floats: []promql.FPoint{{T: 0, F: 2}}},
+				{Floats: []promql.FPoint{{T: 0, F: 1}}},
+				{Floats: []promql.FPoint{{T: 0, F: 3}}},
+			},
+			expectedOutputSeries: []labels.Labels{
+				// Should sort so that the series that is completed first is returned first.
+				labels.FromStrings("pod", "2"),
+				labels.FromStrings("pod", "1"),
+				labels.FromStrings("pod", "3"),
+			},
+			expectedOutputData: []types.InstantVectorSeriesData{
+				{Floats: []promql.FPoint{{T: 0, F: 2}}},
+				{Floats: []promql.FPoint{{T: 0, F: 1}}},
+				{Floats: []promql.FPoint{{T: 0, F: 3}}},
+			},
+		},
+		"duplicate series, neither with points": {
+			inputSeries: []labels.Labels{
+				labels.FromStrings("pod", "1"),
+				labels.FromStrings("pod", "1"),
+			},
+			inputData: []types.InstantVectorSeriesData{
+				{},
+				{},
+			},
+			expectedOutputSeries: []labels.Labels{
+				labels.FromStrings("pod", "1"),
+			},
+			expectedOutputData: []types.InstantVectorSeriesData{
+				{},
+			},
+		},
+		"duplicate series, both with points": {
+			inputSeries: []labels.Labels{
+				labels.FromStrings("pod", "1"),
+				labels.FromStrings("pod", "1"),
+			},
+			inputData: []types.InstantVectorSeriesData{
+				{Floats: []promql.FPoint{{T: 0, F: 1}}},
+				{Floats: []promql.FPoint{{T: 60_000, F: 2}}},
+			},
+			expectedOutputSeries: []labels.Labels{
+				labels.FromStrings("pod", "1"),
+			},
+			expectedError: errDuplicateLabelSet,
+		},
+		"duplicate series, both with points at different timestamps, merging points": {
+			inputSeries: []labels.Labels{
+				labels.FromStrings("pod", "1"),
+				labels.FromStrings("pod", "2"),
+				labels.FromStrings("pod", "1"),
+			},
+			inputData: []types.InstantVectorSeriesData{
+				{Floats: []promql.FPoint{{T: 0, F: 1}, {T: 120_000, F: 3}}},
+				{Floats: []promql.FPoint{{T: 0, F: 10}}},
+				{Floats: []promql.FPoint{{T: 60_000, F: 2}}, Histograms: []promql.HPoint{{T: 180_000, H: &histogram.FloatHistogram{Count: 4}}}},
+			},
+			mergePoints: true,
+			expectedOutputSeries: []labels.Labels{
+				labels.FromStrings("pod", "2"),
+				labels.FromStrings("pod", "1"),
+			},
+			expectedOutputData: []types.InstantVectorSeriesData{
+				{Floats: []promql.FPoint{{T: 0, F: 10}}},
+				{
+					Floats:     []promql.FPoint{{T: 0, F: 1}, {T: 60_000, F: 2}, {T: 120_000, F: 3}},
+					Histograms: []promql.HPoint{{T: 180_000, H: &histogram.FloatHistogram{Count: 4}}},
+				},
+			},
+		},
+		"duplicate series, both with floats at the same timestamp, merging points": {
+			inputSeries: []labels.Labels{
+				labels.FromStrings("pod", "1"),
+				labels.FromStrings("pod", "1"),
+			},
+			inputData: []types.InstantVectorSeriesData{
+				{Floats: []promql.FPoint{{T: 0, F: 1}, {T: 60_000, F: 2}}},
+				{Floats: []promql.FPoint{{T: 60_000, F: 3}}},
+			},
+			mergePoints: true,
+			expectedOutputSeries: []labels.Labels{
+				labels.FromStrings("pod", "1"),
+			},
+			expectedError: errDuplicateLabelSet,
+		},
+		"duplicate series, with a float and a histogram at the same timestamp, merging points": {
+			inputSeries: []labels.Labels{
+				labels.FromStrings("pod", "1"),
+				labels.FromStrings("pod", "1"),
+			},
+			inputData: []types.InstantVectorSeriesData{
+				{Floats: []promql.FPoint{{T: 0, F: 1}}},
+				{Histograms: []promql.HPoint{{T: 0, H: &histogram.FloatHistogram{Count: 4}}}},
+			},
+			mergePoints: true,
+			expectedOutputSeries: []labels.Labels{
+				labels.FromStrings("pod", "1"),
+			},
+			expectedError: errDuplicateLabelSet,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			inner := &testOperator{series: testCase.inputSeries, data: testCase.inputData}
+			deduplicator := NewDeduplicateSeries(inner, pooling.NewLimitingPool(0, nil))
+			if testCase.mergePoints {
+				deduplicator = NewDeduplicateAndMergeSeries(inner, pooling.NewLimitingPool(0, nil))
+			}
+
+			outputSeries, err := deduplicator.SeriesMetadata(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, labelsToSeriesMetadata(testCase.expectedOutputSeries), outputSeries)
+
+			if testCase.expectedError != nil {
+				_, err := deduplicator.NextSeries(context.Background())
+				require.Equal(t, testCase.expectedError, err)
+				return
+			}
+
+			for _, expectedData := range testCase.expectedOutputData {
+				d, err := deduplicator.NextSeries(context.Background())
+				require.NoError(t, err)
+				require.Equal(t, expectedData, d)
+			}
+
+			_, err = deduplicator.NextSeries(context.Background())
+			require.Equal(t, types.EOS, err)
+		})
+	}
+}
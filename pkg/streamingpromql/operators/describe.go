@@ -0,0 +1,256 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package operators
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// Describe returns a description of op and the operators it consumes, for use in a query plan.
+//
+// timeRange is the time range op is evaluated over.
+//
+// If op is instrumented, the returned description includes the statistics recorded so far.
+func Describe(op types.Operator, timeRange types.QueryTimeRange) *types.PlanNode {
+	if i := instrumentationOf(op); i != nil {
+		node := Describe(Uninstrumented(op), timeRange)
+		node.Stats = i.Stats()
+
+		return node
+	}
+
+	// Prefetching doesn't change the results of an operator, so describe the prefetched operator instead.
+	switch o := op.(type) {
+	case *PrefetchingInstantVectorOperator:
+		return Describe(o.Inner, timeRange)
+	case *PrefetchingScalarOperator:
+		return Describe(o.Inner, timeRange)
+	}
+
+	node := &types.PlanNode{
+		Operator:  OperatorName(op),
+		TimeRange: types.NewPlanTimeRange(timeRange),
+	}
+
+	var children []types.Operator
+	childTimeRange := timeRange
+
+	switch o := op.(type) {
+	case *Absent:
+		children = []types.Operator{o.Inner}
+	case *AbsentOverTime:
+		children = []types.Operator{o.Inner}
+	case *Aggregation:
+		node.Details = o.Op.String()
+		node.Grouping = o.Grouping
+		node.Without = o.Without
+		children = []types.Operator{o.Inner}
+	case *AndUnlessBinaryOperation:
+		var binaryOp parser.ItemType = parser.LAND
+		if o.IsUnless {
+			binaryOp = parser.LUNLESS
+		}
+
+		node.Details = describeBinaryOperation(binaryOp, false, o.VectorMatching)
+		children = []types.Operator{o.Left, o.Right}
+	case *BinaryOperation:
+		node.Details = describeBinaryOperation(o.Op, o.ReturnBool, o.VectorMatching)
+		children = []types.Operator{o.Left, o.Right}
+	case *CountValues:
+		node.Details = fmt.Sprintf("count_values(%q)", o.LabelName)
+		node.Grouping = o.Grouping
+		node.Without = o.Without
+		children = []types.Operator{o.Inner}
+	case *DeduplicateSeries:
+		children = []types.Operator{o.Inner}
+	case *FunctionOverInstantVector:
+		node.Details = o.Name
+		children = append([]types.Operator{o.Inner}, scalarOperators(o.ScalarArgs)...)
+	case *FunctionOverRangeVector:
+		node.Details = o.Name
+		children = append([]types.Operator{o.Inner}, scalarOperators(o.ScalarArgs)...)
+	case *GroupedBinaryOperation:
+		node.Details = describeBinaryOperation(o.Op, o.ReturnBool, o.VectorMatching)
+		children = []types.Operator{o.Left, o.Right}
+	case *HistogramFunction:
+		node.Details = o.Name
+		children = append(scalarOperators(o.ScalarArgs), o.Inner)
+	case *InstantVectorSelector:
+		node.Matchers = describeMatchers(o.Selector.Matchers)
+		node.Details = describeSelector(o.Selector)
+
+		if o.ReturnSampleTimestamps {
+			node.Details = strings.TrimSpace(node.Details + " returning sample timestamps")
+		}
+	case *InstantVectorDuplicationConsumer:
+		node.Details = fmt.Sprintf("consumer %d of %d", o.Index+1, o.Buffer.ConsumerCount())
+
+		// Only describe the shared operator once, so its statistics are only reported once.
+		if o.Index == 0 {
+			children = []types.Operator{o.Buffer.Inner}
+		}
+	case *InstantVectorToScalar:
+		children = []types.Operator{o.Inner}
+	case *LimitK:
+		node.Details = fmt.Sprintf("limitk(%v)", o.K)
+		node.Grouping = o.Grouping
+		node.Without = o.Without
+		children = []types.Operator{o.Inner}
+	case *LimitRatio:
+		node.Details = fmt.Sprintf("limit_ratio(%v)", o.Ratio)
+		children = []types.Operator{o.Inner}
+	case *OrBinaryOperation:
+		node.Details = describeBinaryOperation(parser.LOR, false, o.VectorMatching)
+		children = []types.Operator{o.Left, o.Right}
+	case *RangeVectorSelector:
+		node.Matchers = describeMatchers(o.Selector.Matchers)
+		node.Details = describeSelector(o.Selector)
+	case *ScalarConstant:
+		node.Details = strconv.FormatFloat(o.Value, 'g', -1, 64)
+	case *ScalarScalarBinaryOperation:
+		node.Details = o.Op.String()
+		children = []types.Operator{o.Left, o.Right}
+	case *ScalarToInstantVector:
+		children = []types.Operator{o.Scalar}
+	case *Sort:
+		node.Details = "sort"
+		if o.Descending {
+			node.Details = "sort_desc"
+		}
+
+		children = []types.Operator{o.Inner}
+	case *StepInvariantInstantVectorOperator:
+		children = []types.Operator{o.Inner}
+		childTimeRange = o.InnerTimeRange()
+	case *StepInvariantScalarOperator:
+		children = []types.Operator{o.Inner}
+		childTimeRange = o.InnerTimeRange()
+	case *StringLiteral:
+		node.Details = strconv.Quote(o.Value)
+	case *Subquery:
+		node.Details = describeRangeModifiers(o.SubqueryRange, o.SubqueryOffset, o.SubqueryTimestamp)
+		children = []types.Operator{o.Inner}
+		childTimeRange = o.SubqueryTimeRange
+	case *TopKBottomK:
+		node.Details = fmt.Sprintf("bottomk(%v)", o.K)
+		if o.IsTopK {
+			node.Details = fmt.Sprintf("topk(%v)", o.K)
+		}
+
+		node.Grouping = o.Grouping
+		node.Without = o.Without
+		children = []types.Operator{o.Inner}
+	case *VectorScalarBinaryOperation:
+		node.Details = o.Op.String()
+		if o.ReturnBool {
+			node.Details += " bool"
+		}
+
+		if o.ScalarIsLeftSide {
+			children = []types.Operator{o.Scalar, o.Vector}
+		} else {
+			children = []types.Operator{o.Vector, o.Scalar}
+		}
+	}
+
+	for _, child := range children {
+		node.Children = append(node.Children, Describe(child, childTimeRange))
+	}
+
+	return node
+}
+
+// OperatorName returns the name of the type of op, eg. "Aggregation".
+func OperatorName(op types.Operator) string {
+	t := reflect.TypeOf(op)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return t.Name()
+}
+
+func scalarOperators(scalars []types.ScalarOperator) []types.Operator {
+	ops := make([]types.Operator, 0, len(scalars))
+	for _, s := range scalars {
+		ops = append(ops, s)
+	}
+
+	return ops
+}
+
+func describeBinaryOperation(op parser.ItemType, returnBool bool, matching parser.VectorMatching) string {
+	b := &strings.Builder{}
+	b.WriteString(op.String())
+
+	if returnBool {
+		b.WriteString(" bool")
+	}
+
+	if matching.On {
+		b.WriteString(" on (")
+		b.WriteString(strings.Join(matching.MatchingLabels, ", "))
+		b.WriteString(")")
+	} else if len(matching.MatchingLabels) > 0 {
+		b.WriteString(" ignoring (")
+		b.WriteString(strings.Join(matching.MatchingLabels, ", "))
+		b.WriteString(")")
+	}
+
+	switch matching.Card {
+	case parser.CardManyToOne:
+		b.WriteString(" group_left (")
+		b.WriteString(strings.Join(matching.Include, ", "))
+		b.WriteString(")")
+	case parser.CardOneToMany:
+		b.WriteString(" group_right (")
+		b.WriteString(strings.Join(matching.Include, ", "))
+		b.WriteString(")")
+	}
+
+	return b.String()
+}
+
+func describeMatchers(matchers []*labels.Matcher) []string {
+	descriptions := make([]string, 0, len(matchers))
+	for _, m := range matchers {
+		descriptions = append(descriptions, m.String())
+	}
+
+	return descriptions
+}
+
+func describeSelector(s *Selector) string {
+	return describeRangeModifiers(s.Range, s.Offset, s.Timestamp)
+}
+
+func describeRangeModifiers(rng time.Duration, offset int64, ts *int64) string {
+	var parts []string
+
+	if rng != 0 {
+		parts = append(parts, "["+model.Duration(rng).String()+"]")
+	}
+
+	if offset > 0 {
+		parts = append(parts, "offset "+model.Duration(time.Duration(offset)*time.Millisecond).String())
+	} else if offset < 0 {
+		parts = append(parts, "offset -"+model.Duration(time.Duration(-offset)*time.Millisecond).String())
+	}
+
+	if ts != nil {
+		parts = append(parts, "@ "+timestamp.Time(*ts).UTC().Format(time.RFC3339Nano))
+	}
+
+	return strings.Join(parts, " ")
+}
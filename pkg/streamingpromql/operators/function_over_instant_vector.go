@@ -16,14 +16,22 @@ import (
 
 // FunctionOverInstantVector performs a function over each series in an instant vector.
 type FunctionOverInstantVector struct {
+	Name string // The name of the function, used to describe the operator.
+
 	// At the moment no instant-vector promql function takes more than one instant-vector
 	// as an argument. We can assume this will always be the Inner operator and therefore
 	// what we use for the SeriesMetadata.
 	Inner types.InstantVectorOperator
-	Pool  *pooling.LimitingPool
+
+	// ScalarArgs contains any scalar arguments to the function, in the order they appear in the function call.
+	ScalarArgs []types.ScalarOperator
+
+	Pool *pooling.LimitingPool
 
 	MetadataFunc   functions.SeriesMetadataFunction
 	SeriesDataFunc functions.InstantVectorFunction
+
+	scalarArgsData []types.ScalarData
 }
 
 var _ types.InstantVectorOperator = &FunctionOverInstantVector{}
@@ -34,6 +42,19 @@ func (m *FunctionOverInstantVector) SeriesMetadata(ctx context.Context) ([]types
 		return nil, err
 	}
 
+	if len(metadata) > 0 && len(m.ScalarArgs) > 0 {
+		m.scalarArgsData = make([]types.ScalarData, 0, len(m.ScalarArgs))
+
+		for _, a := range m.ScalarArgs {
+			d, err := a.GetValues(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			m.scalarArgsData = append(m.scalarArgsData, d)
+		}
+	}
+
 	return m.MetadataFunc(metadata, m.Pool)
 }
 
@@ -43,9 +64,19 @@ func (m *FunctionOverInstantVector) NextSeries(ctx context.Context) (types.Insta
 		return types.InstantVectorSeriesData{}, err
 	}
 
-	return m.SeriesDataFunc(series, m.Pool)
+	return m.SeriesDataFunc(series, m.scalarArgsData, m.Pool)
 }
 
 func (m *FunctionOverInstantVector) Close() {
 	m.Inner.Close()
+
+	for _, a := range m.ScalarArgs {
+		a.Close()
+	}
+
+	for _, d := range m.scalarArgsData {
+		m.Pool.PutFPointSlice(d.Samples)
+	}
+
+	m.scalarArgsData = nil
 }
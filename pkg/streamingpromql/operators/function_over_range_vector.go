@@ -9,22 +9,33 @@ package operators
 import (
 	"context"
 
-	"github.com/prometheus/prometheus/model/histogram"
 	"github.com/prometheus/prometheus/promql"
 
+	"github.com/grafana/mimir/pkg/streamingpromql/functions"
 	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
 	"github.com/grafana/mimir/pkg/streamingpromql/types"
 )
 
-// FunctionOverRangeVector performs a rate calculation over a range vector.
+// FunctionOverRangeVector performs a function over each series in a range vector, producing an instant vector.
 type FunctionOverRangeVector struct {
+	Name  string // The name of the function, used to describe the operator.
 	Inner types.RangeVectorOperator
-	Pool  *pooling.LimitingPool
+
+	// ScalarArgs contains any scalar arguments to the function, in the order they appear in the function call.
+	ScalarArgs []types.ScalarOperator
+
+	Pool *pooling.LimitingPool
+
+	MetadataFunc functions.SeriesMetadataFunction
+	StepFunc     functions.RangeVectorStepFunction
 
 	numSteps        int
 	rangeSeconds    float64
 	floatBuffer     *types.FPointRingBuffer
 	histogramBuffer *types.HPointRingBuffer
+
+	scalarArgsData   []types.ScalarData
+	scalarArgsValues []float64 // Values of ScalarArgs at the current step, reused for each step to avoid allocating a new slice each time.
 }
 
 var _ types.InstantVectorOperator = &FunctionOverRangeVector{}
@@ -35,14 +46,30 @@ func (m *FunctionOverRangeVector) SeriesMetadata(ctx context.Context) ([]types.S
 		return nil, err
 	}
 
-	for i := range metadata {
-		metadata[i].Labels = metadata[i].Labels.DropMetricName()
+	if len(metadata) == 0 {
+		// No series, so there's no need to evaluate any scalar arguments.
+		return metadata, nil
+	}
+
+	if len(m.ScalarArgs) > 0 {
+		m.scalarArgsData = make([]types.ScalarData, 0, len(m.ScalarArgs))
+
+		for _, a := range m.ScalarArgs {
+			d, err := a.GetValues(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			m.scalarArgsData = append(m.scalarArgsData, d)
+		}
+
+		m.scalarArgsValues = make([]float64, len(m.ScalarArgs))
 	}
 
 	m.numSteps = m.Inner.StepCount()
 	m.rangeSeconds = m.Inner.Range().Seconds()
 
-	return metadata, nil
+	return m.MetadataFunc(metadata, m.Pool)
 }
 
 func (m *FunctionOverRangeVector) NextSeries(ctx context.Context) (types.InstantVectorSeriesData, error) {
@@ -63,7 +90,7 @@ func (m *FunctionOverRangeVector) NextSeries(ctx context.Context) (types.Instant
 
 	data := types.InstantVectorSeriesData{}
 
-	for {
+	for stepIdx := 0; ; stepIdx++ {
 		step, err := m.Inner.NextStepSamples(m.floatBuffer, m.histogramBuffer)
 
 		// nolint:errorlint // errors.Is introduces a performance overhead, and NextStepSamples is guaranteed to return exactly EOS, never a wrapped error.
@@ -73,197 +100,57 @@ func (m *FunctionOverRangeVector) NextSeries(ctx context.Context) (types.Instant
 			return types.InstantVectorSeriesData{}, err
 		}
 
-		err = m.computeNextStep(&data, step)
+		for i, d := range m.scalarArgsData {
+			m.scalarArgsValues[i] = d.Samples[stepIdx].F
+		}
+
+		f, hasFloat, h, err := m.StepFunc(step, m.rangeSeconds, m.floatBuffer, m.histogramBuffer, m.scalarArgsValues, m.Pool)
 		if err != nil {
 			return types.InstantVectorSeriesData{}, err
 		}
-	}
-}
 
-func (m *FunctionOverRangeVector) computeNextStep(data *types.InstantVectorSeriesData, step types.RangeVectorStepData) error {
-	var err error
-	// Floats
-	fHead, fTail := m.floatBuffer.UnsafePoints(step.RangeEnd)
-	fCount := len(fHead) + len(fTail)
-
-	// Histograms
-	hHead, hTail := m.histogramBuffer.UnsafePoints(step.RangeEnd)
-	hCount := len(hHead) + len(hTail)
-
-	if fCount > 0 && hCount > 0 {
-		// We need either at least two Histograms and no Floats, or at least two
-		// Floats and no Histograms to calculate a rate. Otherwise, drop this
-		// Vector element.
-		return nil
-	}
-
-	if fCount >= 2 {
-		firstPoint := m.floatBuffer.First()
-		lastPoint, _ := m.floatBuffer.LastAtOrBefore(step.RangeEnd) // We already know there is a point at or before this time, no need to check.
-		delta := lastPoint.F - firstPoint.F
-		previousValue := firstPoint.F
-
-		accumulate := func(points []promql.FPoint) {
-			for _, p := range points {
-				if p.F < previousValue {
-					// Counter reset.
-					delta += previousValue
+		if hasFloat {
+			if data.Floats == nil {
+				// Only get fPoint slice once we are sure we have float points.
+				// This potentially over-allocates as some points in the steps may be histograms,
+				// but this is expected to be rare.
+				data.Floats, err = m.Pool.GetFPointSlice(m.numSteps)
+				if err != nil {
+					return types.InstantVectorSeriesData{}, err
 				}
-
-				previousValue = p.F
-			}
-		}
-
-		accumulate(fHead)
-		accumulate(fTail)
-
-		val := m.calculateFloatRate(step.RangeStart, step.RangeEnd, firstPoint, lastPoint, delta, fCount)
-		if data.Floats == nil {
-			// Only get fPoint slice once we are sure we have float points.
-			// This potentially over-allocates as some points in the steps may be histograms,
-			// but this is expected to be rare.
-			data.Floats, err = m.Pool.GetFPointSlice(m.numSteps)
-			if err != nil {
-				return err
 			}
-		}
-		data.Floats = append(data.Floats, promql.FPoint{T: step.StepT, F: val})
-	}
 
-	if hCount >= 2 {
-		firstPoint := m.histogramBuffer.First()
-		lastPoint, _ := m.histogramBuffer.LastAtOrBefore(step.RangeEnd) // We already know there is a point at or before this time, no need to check.
-
-		currentSchema := firstPoint.H.Schema
-		if lastPoint.H.Schema < currentSchema {
-			currentSchema = lastPoint.H.Schema
+			data.Floats = append(data.Floats, promql.FPoint{T: step.StepT, F: f})
 		}
 
-		delta := lastPoint.H.CopyToSchema(currentSchema)
-		_, err = delta.Sub(firstPoint.H)
-		if err != nil {
-			return err
-		}
-		previousValue := firstPoint.H
-
-		accumulate := func(points []promql.HPoint) error {
-			for _, p := range points {
-				if p.H.DetectReset(previousValue) {
-					// Counter reset.
-					_, err = delta.Add(previousValue)
-					if err != nil {
-						return err
-					}
-				}
-				if p.H.Schema < currentSchema {
-					delta = delta.CopyToSchema(p.H.Schema)
+		if h != nil {
+			if data.Histograms == nil {
+				// Only get hPoint slice once we are sure we have histogram points.
+				// This potentially over-allocates as some points in the steps may be floats,
+				// but this is expected to be rare.
+				data.Histograms, err = m.Pool.GetHPointSlice(m.numSteps)
+				if err != nil {
+					return types.InstantVectorSeriesData{}, err
 				}
-
-				previousValue = p.H
 			}
-			return nil
-		}
 
-		err = accumulate(hHead)
-		if err != nil {
-			return err
-		}
-		err = accumulate(hTail)
-		if err != nil {
-			return err
-		}
-
-		val := m.calculateHistogramRate(step.RangeStart, step.RangeEnd, firstPoint, lastPoint, delta, hCount)
-		if data.Histograms == nil {
-			// Only get hPoint slice once we are sure we have histogram points.
-			// This potentially over-allocates as some points in the steps may be floats,
-			// but this is expected to be rare.
-			data.Histograms, err = m.Pool.GetHPointSlice(m.numSteps)
-			if err != nil {
-				return err
-			}
+			data.Histograms = append(data.Histograms, promql.HPoint{T: step.StepT, H: h})
 		}
-
-		data.Histograms = append(data.Histograms, promql.HPoint{T: step.StepT, H: val})
-	}
-	return nil
-}
-
-// This is based on extrapolatedRate from promql/functions.go.
-// https://github.com/prometheus/prometheus/pull/13725 has a good explanation of the intended behaviour here.
-func (m *FunctionOverRangeVector) calculateHistogramRate(rangeStart, rangeEnd int64, firstPoint, lastPoint promql.HPoint, delta *histogram.FloatHistogram, count int) *histogram.FloatHistogram {
-	durationToStart := float64(firstPoint.T-rangeStart) / 1000
-	durationToEnd := float64(rangeEnd-lastPoint.T) / 1000
-
-	sampledInterval := float64(lastPoint.T-firstPoint.T) / 1000
-	averageDurationBetweenSamples := sampledInterval / float64(count-1)
-
-	extrapolationThreshold := averageDurationBetweenSamples * 1.1
-	extrapolateToInterval := sampledInterval
-
-	if durationToStart >= extrapolationThreshold {
-		durationToStart = averageDurationBetweenSamples / 2
-	}
-
-	extrapolateToInterval += durationToStart
-
-	if durationToEnd >= extrapolationThreshold {
-		durationToEnd = averageDurationBetweenSamples / 2
 	}
-
-	extrapolateToInterval += durationToEnd
-
-	factor := extrapolateToInterval / sampledInterval
-	factor /= m.rangeSeconds
-	delta.CounterResetHint = histogram.GaugeType
-	delta.Compact(0)
-	return delta.Mul(factor)
 }
 
-// This is based on extrapolatedRate from promql/functions.go.
-// https://github.com/prometheus/prometheus/pull/13725 has a good explanation of the intended behaviour here.
-func (m *FunctionOverRangeVector) calculateFloatRate(rangeStart, rangeEnd int64, firstPoint, lastPoint promql.FPoint, delta float64, count int) float64 {
-	durationToStart := float64(firstPoint.T-rangeStart) / 1000
-	durationToEnd := float64(rangeEnd-lastPoint.T) / 1000
-
-	sampledInterval := float64(lastPoint.T-firstPoint.T) / 1000
-	averageDurationBetweenSamples := sampledInterval / float64(count-1)
-
-	extrapolationThreshold := averageDurationBetweenSamples * 1.1
-	extrapolateToInterval := sampledInterval
-
-	if durationToStart >= extrapolationThreshold {
-		durationToStart = averageDurationBetweenSamples / 2
-	}
+func (m *FunctionOverRangeVector) Close() {
+	m.Inner.Close()
 
-	if delta > 0 && firstPoint.F >= 0 {
-		// Counters cannot be negative. If we have any slope at all
-		// (i.e. delta went up), we can extrapolate the zero point
-		// of the counter. If the duration to the zero point is shorter
-		// than the durationToStart, we take the zero point as the start
-		// of the series, thereby avoiding extrapolation to negative
-		// counter values.
-		durationToZero := sampledInterval * (firstPoint.F / delta)
-		if durationToZero < durationToStart {
-			durationToStart = durationToZero
-		}
+	for _, a := range m.ScalarArgs {
+		a.Close()
 	}
 
-	extrapolateToInterval += durationToStart
-
-	if durationToEnd >= extrapolationThreshold {
-		durationToEnd = averageDurationBetweenSamples / 2
+	for _, d := range m.scalarArgsData {
+		m.Pool.PutFPointSlice(d.Samples)
 	}
 
-	extrapolateToInterval += durationToEnd
-
-	factor := extrapolateToInterval / sampledInterval
-	factor /= m.rangeSeconds
-	return delta * factor
-}
-
-func (m *FunctionOverRangeVector) Close() {
-	m.Inner.Close()
+	m.scalarArgsData = nil
 
 	if m.floatBuffer != nil {
 		m.floatBuffer.Close()
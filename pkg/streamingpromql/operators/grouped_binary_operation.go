@@ -0,0 +1,651 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/engine.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package operators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/compat"
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// GroupedBinaryOperation represents a many-to-one or one-to-many binary operation between instant vectors
+// such as "<expr> + on(...) group_left(...) <expr>" or "<expr> - on(...) group_right(...) <expr>".
+//
+// The side with more series per match group (the left side for group_left, the right side for group_right) is
+// referred to as the "many" side, and the other side is referred to as the "one" side.
+type GroupedBinaryOperation struct {
+	Left       types.InstantVectorOperator
+	Right      types.InstantVectorOperator
+	Op         parser.ItemType
+	ReturnBool bool
+	Start      int64 // Milliseconds since Unix epoch
+	Interval   int64 // In milliseconds
+	Steps      int
+	Pool       *pooling.LimitingPool
+
+	VectorMatching parser.VectorMatching
+
+	// We need to retain these so that NextSeries() can return an error message with the series labels when
+	// multiple points match on the "one" side.
+	oneSideMetadata  []types.SeriesMetadata
+	manySideMetadata []types.SeriesMetadata
+
+	remainingSeries []*groupedBinaryOperationOutputSeries
+	oneSideBuffer   *binaryOperationSeriesBuffer
+	manySideBuffer  *binaryOperationSeriesBuffer
+	arithmeticFunc  binaryOperationFunc
+	comparisonFunc  binaryComparisonFunc
+}
+
+var _ types.InstantVectorOperator = &GroupedBinaryOperation{}
+
+type groupedBinaryOperationOutputSeries struct {
+	labels labels.Labels
+
+	// In almost all cases, each output series is produced from a single pair of "many" side and "one" side series.
+	// However, it is possible for multiple pairs to produce the same output series (eg. if a label copied from the
+	// "one" side overwrites a label that differs between "many" side series).
+	sources []groupedBinaryOperationOutputSeriesSource
+
+	latestManySideSeriesIndex int
+	latestOneSideSeriesIndex  int
+}
+
+type groupedBinaryOperationOutputSeriesSource struct {
+	manySide *groupedBinaryOperationManySide
+	oneSide  *groupedBinaryOperationOneSide
+}
+
+// groupedBinaryOperationManySide represents a single series from the "many" side.
+type groupedBinaryOperationManySide struct {
+	seriesIndex int
+
+	// The number of output series that use this series that we haven't yet returned.
+	outputSeriesCount int
+
+	data   types.InstantVectorSeriesData
+	loaded bool
+}
+
+// groupedBinaryOperationOneSide represents all series from the "one" side in a match group that have the same
+// values for the labels copied to the output series with group_left or group_right.
+type groupedBinaryOperationOneSide struct {
+	matchGroup       *groupedBinaryOperationMatchGroup
+	additionalLabels labels.Labels
+	seriesIndices    []int
+
+	// The number of output series that use this series that we haven't yet returned.
+	outputSeriesCount int
+
+	mergedData types.InstantVectorSeriesData
+}
+
+// groupedBinaryOperationMatchGroup represents all series from the "one" side in a match group.
+type groupedBinaryOperationMatchGroup struct {
+	oneSides          []*groupedBinaryOperationOneSide
+	seriesIndices     []int // Sorted in ascending order
+	seriesOneSides    []*groupedBinaryOperationOneSide
+	hasManySideSeries bool
+	loaded            bool
+}
+
+func NewGroupedBinaryOperation(
+	left types.InstantVectorOperator,
+	right types.InstantVectorOperator,
+	vectorMatching parser.VectorMatching,
+	op parser.ItemType,
+	returnBool bool,
+	start time.Time,
+	end time.Time,
+	interval time.Duration,
+	pool *pooling.LimitingPool,
+) (*GroupedBinaryOperation, error) {
+	if vectorMatching.Card != parser.CardManyToOne && vectorMatching.Card != parser.CardOneToMany {
+		// Should be caught by the query planner, but we check here for safety.
+		return nil, fmt.Errorf("unexpected %v matching for grouped binary operation", vectorMatching.Card)
+	}
+
+	arithmeticFunc := arithmeticOperationFuncs[op]
+	comparisonFunc := comparisonOperationFuncs[op]
+
+	if arithmeticFunc == nil && comparisonFunc == nil {
+		return nil, compat.NewNotSupportedError(fmt.Sprintf("binary expression with '%s'", op))
+	}
+
+	s, e, i := timestamp.FromTime(start), timestamp.FromTime(end), interval.Milliseconds()
+
+	return &GroupedBinaryOperation{
+		Left:           left,
+		Right:          right,
+		VectorMatching: vectorMatching,
+		Op:             op,
+		ReturnBool:     returnBool,
+		Start:          s,
+		Interval:       i,
+		Steps:          stepCount(s, e, i),
+		Pool:           pool,
+
+		arithmeticFunc: arithmeticFunc,
+		comparisonFunc: comparisonFunc,
+	}, nil
+}
+
+func (g *GroupedBinaryOperation) manySideIsLeftSide() bool {
+	return g.VectorMatching.Card == parser.CardManyToOne
+}
+
+func (g *GroupedBinaryOperation) oneSideName() string {
+	if g.manySideIsLeftSide() {
+		return "right"
+	}
+
+	return "left"
+}
+
+// SeriesMetadata returns the series expected to be produced by this operator.
+//
+// As with BinaryOperation, it is possible that this method returns a series which will not have any points, as the
+// list of possible output series is generated based solely on the series labels, not their data.
+func (g *GroupedBinaryOperation) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	if canProduceAnySeries, err := g.loadSeriesMetadata(ctx); err != nil {
+		return nil, err
+	} else if !canProduceAnySeries {
+		return nil, nil
+	}
+
+	allSeries, oneSideSeriesUsed, manySideSeriesUsed, err := g.computeOutputSeries()
+	if err != nil {
+		return nil, err
+	}
+
+	g.sortSeries(allSeries)
+	g.remainingSeries = allSeries
+
+	allMetadata := pooling.GetSeriesMetadataSlice(len(allSeries))
+	for _, s := range allSeries {
+		allMetadata = append(allMetadata, types.SeriesMetadata{Labels: s.labels})
+	}
+
+	if g.manySideIsLeftSide() {
+		g.manySideBuffer = newBinaryOperationSeriesBuffer(g.Left, manySideSeriesUsed, g.Pool)
+		g.oneSideBuffer = newBinaryOperationSeriesBuffer(g.Right, oneSideSeriesUsed, g.Pool)
+	} else {
+		g.oneSideBuffer = newBinaryOperationSeriesBuffer(g.Left, oneSideSeriesUsed, g.Pool)
+		g.manySideBuffer = newBinaryOperationSeriesBuffer(g.Right, manySideSeriesUsed, g.Pool)
+	}
+
+	return allMetadata, nil
+}
+
+// loadSeriesMetadata loads series metadata from both sides of this operation.
+// It returns false if one side returned no series and that means there is no way for this operation to return any series.
+func (g *GroupedBinaryOperation) loadSeriesMetadata(ctx context.Context) (bool, error) {
+	// We retain the series labels for later so we can use them to generate error messages.
+	// We'll return them to the pool in Close().
+
+	leftMetadata, err := g.Left.SeriesMetadata(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	g.assignMetadata(leftMetadata, true)
+
+	if len(leftMetadata) == 0 {
+		// No series on left-hand side, we'll never have any output series.
+		return false, nil
+	}
+
+	rightMetadata, err := g.Right.SeriesMetadata(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	g.assignMetadata(rightMetadata, false)
+
+	if len(rightMetadata) == 0 {
+		// No series on right-hand side, we'll never have any output series.
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (g *GroupedBinaryOperation) assignMetadata(metadata []types.SeriesMetadata, isLeftSide bool) {
+	if isLeftSide == g.manySideIsLeftSide() {
+		g.manySideMetadata = metadata
+	} else {
+		g.oneSideMetadata = metadata
+	}
+}
+
+// computeOutputSeries determines the possible output series from this operator.
+// It assumes oneSideMetadata and manySideMetadata have already been populated.
+//
+// It returns:
+// - a list of all possible series this operator could return
+// - a list indicating which series from the "one" side are needed to compute the output
+// - a list indicating which series from the "many" side are needed to compute the output
+func (g *GroupedBinaryOperation) computeOutputSeries() ([]*groupedBinaryOperationOutputSeries, []bool, []bool, error) {
+	labelsFunc := matchGroupLabelsFunc(g.VectorMatching)
+	matchGroups := map[string]*groupedBinaryOperationMatchGroup{}
+
+	for idx, s := range g.oneSideMetadata {
+		groupLabels := labelsFunc(s.Labels).String()
+		group, exists := matchGroups[groupLabels]
+
+		if !exists {
+			group = &groupedBinaryOperationMatchGroup{}
+			matchGroups[groupLabels] = group
+		}
+
+		// Series with different values for the labels copied to the output series produce different output series,
+		// so they need to be tracked separately.
+		additionalLabels := s.Labels.MatchLabels(true, g.VectorMatching.Include...)
+		oneSide := group.oneSideFor(additionalLabels)
+		oneSide.seriesIndices = append(oneSide.seriesIndices, idx)
+
+		group.seriesIndices = append(group.seriesIndices, idx)
+		group.seriesOneSides = append(group.seriesOneSides, oneSide)
+	}
+
+	oneSideSeriesUsed, err := g.Pool.GetBoolSlice(len(g.oneSideMetadata))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	manySideSeriesUsed, err := g.Pool.GetBoolSlice(len(g.manySideMetadata))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	oneSideSeriesUsed = oneSideSeriesUsed[:len(g.oneSideMetadata)]
+	manySideSeriesUsed = manySideSeriesUsed[:len(g.manySideMetadata)]
+
+	outputSeriesMap := map[string]*groupedBinaryOperationOutputSeries{}
+	allSeries := []*groupedBinaryOperationOutputSeries{}
+	lb := labels.NewBuilder(labels.EmptyLabels())
+
+	for idx, s := range g.manySideMetadata {
+		group, exists := matchGroups[labelsFunc(s.Labels).String()]
+		if !exists {
+			// No matching series on the "one" side, so this series can't produce any output series.
+			continue
+		}
+
+		manySideSeriesUsed[idx] = true
+		group.hasManySideSeries = true
+		manySide := &groupedBinaryOperationManySide{seriesIndex: idx}
+
+		for _, oneSide := range group.oneSides {
+			outputLabels := g.outputLabels(lb, s.Labels, oneSide.additionalLabels)
+			key := outputLabels.String()
+			series, exists := outputSeriesMap[key]
+
+			if !exists {
+				series = &groupedBinaryOperationOutputSeries{labels: outputLabels, latestOneSideSeriesIndex: -1}
+				outputSeriesMap[key] = series
+				allSeries = append(allSeries, series)
+			}
+
+			series.sources = append(series.sources, groupedBinaryOperationOutputSeriesSource{manySide: manySide, oneSide: oneSide})
+
+			series.latestManySideSeriesIndex = max(series.latestManySideSeriesIndex, idx)
+
+			// We read all series in a match group at once, so the latest "one" side series needed is the last series in the match group.
+			series.latestOneSideSeriesIndex = max(series.latestOneSideSeriesIndex, group.seriesIndices[len(group.seriesIndices)-1])
+
+			manySide.outputSeriesCount++
+			oneSide.outputSeriesCount++
+		}
+	}
+
+	for _, group := range matchGroups {
+		if !group.hasManySideSeries {
+			continue
+		}
+
+		for _, idx := range group.seriesIndices {
+			oneSideSeriesUsed[idx] = true
+		}
+	}
+
+	return allSeries, oneSideSeriesUsed, manySideSeriesUsed, nil
+}
+
+func (m *groupedBinaryOperationMatchGroup) oneSideFor(additionalLabels labels.Labels) *groupedBinaryOperationOneSide {
+	// We expect there to be very few distinct values for the additional labels in a match group, so a linear search is fine.
+	for _, oneSide := range m.oneSides {
+		if labels.Equal(oneSide.additionalLabels, additionalLabels) {
+			return oneSide
+		}
+	}
+
+	oneSide := &groupedBinaryOperationOneSide{matchGroup: m, additionalLabels: additionalLabels}
+	m.oneSides = append(m.oneSides, oneSide)
+
+	return oneSide
+}
+
+// outputLabels returns the labels of the output series produced from a "many" side series with labels manySideLabels
+// and a "one" side series with additionalLabels as the values of the labels listed in group_left or group_right.
+func (g *GroupedBinaryOperation) outputLabels(lb *labels.Builder, manySideLabels labels.Labels, additionalLabels labels.Labels) labels.Labels {
+	lb.Reset(manySideLabels)
+
+	// Comparison operations without the bool modifier act as a filter, and so retain the metric name.
+	if g.arithmeticFunc != nil || g.ReturnBool {
+		lb.Del(labels.MetricName)
+	}
+
+	for _, l := range g.VectorMatching.Include {
+		// Labels listed in group_left or group_right that are not present on the "one" side are removed from the output series.
+		if v := additionalLabels.Get(l); v != "" {
+			lb.Set(l, v)
+		} else {
+			lb.Del(l)
+		}
+	}
+
+	return lb.Labels()
+}
+
+// sortSeries sorts series in place to try to minimise the number of input series we'll need to buffer in memory.
+//
+// This follows the same approach as BinaryOperation.sortSeries: we read the side with the most series in order,
+// which means that in the worst case, we'll have to buffer the whole of the side with fewer series.
+// In the common case where the "one" side has fewer series, this also means we buffer the side whose series
+// are used by multiple output series, and which we'd need to retain anyway.
+func (g *GroupedBinaryOperation) sortSeries(series []*groupedBinaryOperationOutputSeries) {
+	favourManySide := len(g.oneSideMetadata) < len(g.manySideMetadata)
+
+	slices.SortStableFunc(series, func(a, b *groupedBinaryOperationOutputSeries) int {
+		if favourManySide {
+			if a.latestManySideSeriesIndex != b.latestManySideSeriesIndex {
+				return a.latestManySideSeriesIndex - b.latestManySideSeriesIndex
+			}
+
+			return a.latestOneSideSeriesIndex - b.latestOneSideSeriesIndex
+		}
+
+		if a.latestOneSideSeriesIndex != b.latestOneSideSeriesIndex {
+			return a.latestOneSideSeriesIndex - b.latestOneSideSeriesIndex
+		}
+
+		return a.latestManySideSeriesIndex - b.latestManySideSeriesIndex
+	})
+}
+
+func (g *GroupedBinaryOperation) NextSeries(ctx context.Context) (types.InstantVectorSeriesData, error) {
+	if len(g.remainingSeries) == 0 {
+		return types.InstantVectorSeriesData{}, types.EOS
+	}
+
+	thisSeries := g.remainingSeries[0]
+	g.remainingSeries = g.remainingSeries[1:]
+
+	if len(thisSeries.sources) == 1 {
+		// Fast path: only one source, no need to check for conflicts between sources.
+		floats, err := g.computeResult(ctx, thisSeries.sources[0])
+		if err != nil {
+			return types.InstantVectorSeriesData{}, err
+		}
+
+		return types.InstantVectorSeriesData{Floats: floats}, nil
+	}
+
+	return g.computeAndMergeResults(ctx, thisSeries.sources)
+}
+
+// computeAndMergeResults computes the result for each of sources, then merges them into a single series.
+//
+// It returns an error if more than one source produces a point at the same timestamp, consistent with Prometheus' engine.
+func (g *GroupedBinaryOperation) computeAndMergeResults(ctx context.Context, sources []groupedBinaryOperationOutputSeriesSource) (types.InstantVectorSeriesData, error) {
+	results := make([][]promql.FPoint, 0, len(sources))
+	defer func() {
+		for _, r := range results {
+			g.Pool.PutFPointSlice(r)
+		}
+	}()
+
+	mergedSize := 0
+
+	for _, source := range sources {
+		floats, err := g.computeResult(ctx, source)
+		if err != nil {
+			return types.InstantVectorSeriesData{}, err
+		}
+
+		results = append(results, floats)
+		mergedSize += len(floats)
+	}
+
+	output, err := g.Pool.GetFPointSlice(mergedSize)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	for _, r := range results {
+		output = append(output, r...)
+	}
+
+	slices.SortFunc(output, func(a, b promql.FPoint) int {
+		return int(a.T - b.T)
+	})
+
+	for i := 1; i < len(output); i++ {
+		if output[i].T == output[i-1].T {
+			g.Pool.PutFPointSlice(output)
+			return types.InstantVectorSeriesData{}, errors.New("multiple matches for labels: grouping labels must ensure unique matches")
+		}
+	}
+
+	return types.InstantVectorSeriesData{Floats: output}, nil
+}
+
+// computeResult computes the result of this operation for a single pair of "many" side and "one" side series.
+func (g *GroupedBinaryOperation) computeResult(ctx context.Context, source groupedBinaryOperationOutputSeriesSource) ([]promql.FPoint, error) {
+	manySideData, err := g.getManySideData(ctx, source.manySide)
+	if err != nil {
+		return nil, err
+	}
+
+	oneSideData, err := g.getOneSideData(ctx, source.oneSide)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := g.Pool.GetFPointSlice(min(len(manySideData.Floats), len(oneSideData.Floats)))
+	if err != nil {
+		return nil, err
+	}
+
+	nextOneSideIndex := 0
+
+	for _, manySidePoint := range manySideData.Floats {
+		for nextOneSideIndex < len(oneSideData.Floats) && oneSideData.Floats[nextOneSideIndex].T < manySidePoint.T {
+			nextOneSideIndex++
+		}
+
+		if nextOneSideIndex == len(oneSideData.Floats) {
+			// No more points on the "one" side. We are done.
+			break
+		}
+
+		oneSidePoint := oneSideData.Floats[nextOneSideIndex]
+
+		if manySidePoint.T == oneSidePoint.T {
+			// We have matching points on both sides, compute the result.
+			left, right := manySidePoint.F, oneSidePoint.F
+			if !g.manySideIsLeftSide() {
+				left, right = right, left
+			}
+
+			if f, keep := computeBinaryOperationPointResult(left, right, g.arithmeticFunc, g.comparisonFunc, g.ReturnBool); keep {
+				output = append(output, promql.FPoint{
+					F: f,
+					T: manySidePoint.T,
+				})
+			}
+		}
+	}
+
+	source.manySide.outputSeriesCount--
+	if source.manySide.outputSeriesCount == 0 {
+		g.Pool.PutInstantVectorSeriesData(source.manySide.data)
+		source.manySide.data = types.InstantVectorSeriesData{}
+	}
+
+	source.oneSide.outputSeriesCount--
+	if source.oneSide.outputSeriesCount == 0 {
+		g.Pool.PutInstantVectorSeriesData(source.oneSide.mergedData)
+		source.oneSide.mergedData = types.InstantVectorSeriesData{}
+	}
+
+	return output, nil
+}
+
+func (g *GroupedBinaryOperation) getManySideData(ctx context.Context, manySide *groupedBinaryOperationManySide) (types.InstantVectorSeriesData, error) {
+	if manySide.loaded {
+		return manySide.data, nil
+	}
+
+	data, err := g.manySideBuffer.getSingleSeries(ctx, manySide.seriesIndex)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	manySide.data = data
+	manySide.loaded = true
+
+	return data, nil
+}
+
+func (g *GroupedBinaryOperation) getOneSideData(ctx context.Context, oneSide *groupedBinaryOperationOneSide) (types.InstantVectorSeriesData, error) {
+	if !oneSide.matchGroup.loaded {
+		if err := g.loadMatchGroup(ctx, oneSide.matchGroup); err != nil {
+			return types.InstantVectorSeriesData{}, err
+		}
+	}
+
+	return oneSide.mergedData, nil
+}
+
+// loadMatchGroup reads all "one" side series in the match group and merges the series for each of its one sides.
+//
+// Prometheus' engine requires that there is at most one "one" side series in each match group at each step, regardless
+// of the values of the labels copied to the output series, so we check this for the whole match group here.
+func (g *GroupedBinaryOperation) loadMatchGroup(ctx context.Context, group *groupedBinaryOperationMatchGroup) error {
+	// seriesAtStep[i] is 1 + the index of the series with a point at step i, or 0 if no series has a point at step i.
+	seriesAtStep, err := g.Pool.GetIntSlice(g.Steps)
+	if err != nil {
+		return err
+	}
+
+	defer g.Pool.PutIntSlice(seriesAtStep)
+	seriesAtStep = seriesAtStep[:g.Steps]
+
+	dataForOneSide := make(map[*groupedBinaryOperationOneSide][]types.InstantVectorSeriesData, len(group.oneSides))
+
+	for i, seriesIndex := range group.seriesIndices {
+		d, err := g.oneSideBuffer.getSingleSeries(ctx, seriesIndex)
+		if err != nil {
+			return err
+		}
+
+		oneSide := group.seriesOneSides[i]
+		dataForOneSide[oneSide] = append(dataForOneSide[oneSide], d)
+
+		for _, p := range d.Floats {
+			step := (p.T - g.Start) / g.Interval
+
+			if seriesAtStep[step] != 0 {
+				return g.duplicateOneSideSeriesError(seriesAtStep[step]-1, seriesIndex, p.T)
+			}
+
+			seriesAtStep[step] = seriesIndex + 1
+		}
+	}
+
+	for _, oneSide := range group.oneSides {
+		oneSide.mergedData, err = g.mergeOneSideData(dataForOneSide[oneSide])
+		if err != nil {
+			return err
+		}
+	}
+
+	group.loaded = true
+
+	return nil
+}
+
+func (g *GroupedBinaryOperation) duplicateOneSideSeriesError(firstSeriesIndex int, secondSeriesIndex int, t int64) error {
+	firstConflictingSeriesLabels := g.oneSideMetadata[firstSeriesIndex].Labels
+	secondConflictingSeriesLabels := g.oneSideMetadata[secondSeriesIndex].Labels
+	groupLabels := matchGroupLabelsFunc(g.VectorMatching)(firstConflictingSeriesLabels)
+
+	return fmt.Errorf("found duplicate series for the match group %s on the %s side of the operation at timestamp %s: %s and %s", groupLabels, g.oneSideName(), timestamp.Time(t).Format(time.RFC3339Nano), firstConflictingSeriesLabels, secondConflictingSeriesLabels)
+}
+
+// mergeOneSideData merges the series in data into a single series.
+// It assumes that no two series in data have points at the same timestamp.
+func (g *GroupedBinaryOperation) mergeOneSideData(data []types.InstantVectorSeriesData) (types.InstantVectorSeriesData, error) {
+	if len(data) == 1 {
+		// Fast path: if there's only one series, there's no merging required.
+		return data[0], nil
+	}
+
+	mergedSize := 0
+	for _, d := range data {
+		mergedSize += len(d.Floats)
+	}
+
+	output, err := g.Pool.GetFPointSlice(mergedSize)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	for _, d := range data {
+		output = append(output, d.Floats...)
+		g.Pool.PutInstantVectorSeriesData(d)
+	}
+
+	slices.SortFunc(output, func(a, b promql.FPoint) int {
+		return int(a.T - b.T)
+	})
+
+	return types.InstantVectorSeriesData{Floats: output}, nil
+}
+
+func (g *GroupedBinaryOperation) Close() {
+	g.Left.Close()
+	g.Right.Close()
+
+	if g.oneSideMetadata != nil {
+		pooling.PutSeriesMetadataSlice(g.oneSideMetadata)
+	}
+
+	if g.manySideMetadata != nil {
+		pooling.PutSeriesMetadataSlice(g.manySideMetadata)
+	}
+
+	if g.oneSideBuffer != nil {
+		g.oneSideBuffer.close()
+	}
+
+	if g.manySideBuffer != nil {
+		g.manySideBuffer.close()
+	}
+}
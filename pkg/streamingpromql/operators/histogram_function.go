@@ -0,0 +1,346 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/functions.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package operators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/functions"
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// HistogramFunction performs a function over each classic and native histogram in an instant vector, such as
+// histogram_quantile or histogram_fraction.
+//
+// Classic histogram bucket series (ie. series with an 'le' label) are grouped by all labels except 'le', and each
+// group produces a single output series without the metric name. Native histograms produce an output series with the
+// same labels as the input series, less the metric name. If a classic histogram and a native histogram with the same
+// labels both have a point at the same time step, no point is produced for that step, consistent with Prometheus' engine.
+//
+// Groups are returned in the order in which their last series is produced by Inner, so only the buckets of groups
+// with series that have been read from Inner but that are not yet complete are held in memory. In the common case
+// where the bucket series of each classic histogram are adjacent in Inner's output, this means only one group's
+// buckets are held in memory at a time.
+type HistogramFunction struct {
+	Name  string // The name of the function, used to describe the operator.
+	Inner types.InstantVectorOperator
+
+	// ScalarArgs contains the scalar arguments to the function, in the order they appear in the function call.
+	// There must be at least one scalar argument.
+	ScalarArgs []types.ScalarOperator
+
+	Pool *pooling.LimitingPool
+
+	ClassicHistogramFunc functions.ClassicHistogramFunction
+	NativeHistogramFunc  functions.NativeHistogramFunction
+
+	scalarArgsData   []types.ScalarData
+	scalarArgsValues []float64 // Values of ScalarArgs at the current step, reused for each step to avoid allocating a new slice each time.
+
+	remainingInnerSeriesToGroups []histogramSeriesGroups // One entry per series produced by Inner, value is the groups for that series
+	remainingGroups              []*histogramGroup       // One entry per group, in the order we want to return them
+
+	buckets []functions.Bucket // Buckets of the classic histogram at the current step, reused for each step.
+}
+
+var _ types.InstantVectorOperator = &HistogramFunction{}
+
+// histogramSeriesGroups describes the groups a single series from Inner contributes to.
+type histogramSeriesGroups struct {
+	// The group that float points from this series contribute to, or nil if this series is not a classic histogram
+	// bucket series.
+	classic    *histogramGroup
+	upperBound float64
+
+	// The group that native histogram points from this series contribute to.
+	native *histogramGroup
+}
+
+type histogramGroup struct {
+	labels labels.Labels
+
+	// The number of input series that belong to this group that we haven't yet seen.
+	remainingSeriesCount uint
+
+	// The index of the last series that contributes to this group.
+	// Used to sort groups in the order that they'll be completed in.
+	lastSeriesIndex int
+
+	classicBuckets   []classicHistogramBucketSeries
+	nativeHistograms []promql.HPoint
+}
+
+type classicHistogramBucketSeries struct {
+	upperBound float64
+	points     []promql.FPoint
+}
+
+func (h *HistogramFunction) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	innerSeries, err := h.Inner.SeriesMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer pooling.PutSeriesMetadataSlice(innerSeries)
+
+	if len(innerSeries) == 0 {
+		// No input series == no output series, so there's no need to evaluate any scalar arguments.
+		return nil, nil
+	}
+
+	h.scalarArgsData = make([]types.ScalarData, 0, len(h.ScalarArgs))
+
+	for _, a := range h.ScalarArgs {
+		d, err := a.GetValues(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		h.scalarArgsData = append(h.scalarArgsData, d)
+	}
+
+	h.scalarArgsValues = make([]float64, len(h.ScalarArgs))
+
+	// Determine the groups we'll return.
+	//
+	// Each group is identified by the labels of the series that contribute to it: for classic histograms, all labels
+	// except 'le', and for native histograms, all labels. A native histogram series without an 'le' label therefore
+	// shares a group with the classic histogram with the same labels, which allows us to detect when both are present.
+	//
+	// We don't know which series contain classic histogram buckets and which contain native histograms until we read
+	// them, so every series contributes to a native histogram group, and every series with an 'le' label also
+	// contributes to a classic histogram group. Groups that end up with no points produce an empty series.
+	groups := map[string]*histogramGroup{}
+	orderedGroups := make([]*histogramGroup, 0, len(innerSeries))
+	h.remainingInnerSeriesToGroups = make([]histogramSeriesGroups, 0, len(innerSeries))
+
+	// Why 1024 bytes? It's what labels.Labels.String() uses as a buffer size, so we use that as a sensible starting point too.
+	b := make([]byte, 0, 1024)
+	lb := labels.NewBuilder(labels.EmptyLabels())
+
+	getGroup := func(key []byte, seriesIdx int, groupLabels func() labels.Labels) *histogramGroup {
+		g, exists := groups[string(key)] // Important: don't extract the string(...) call here - passing it directly allows us to avoid allocating it.
+
+		if !exists {
+			g = &histogramGroup{labels: groupLabels()}
+			groups[string(key)] = g
+			orderedGroups = append(orderedGroups, g)
+		}
+
+		g.remainingSeriesCount++
+		g.lastSeriesIndex = seriesIdx
+		return g
+	}
+
+	for seriesIdx, series := range innerSeries {
+		seriesGroups := histogramSeriesGroups{}
+
+		if le := series.Labels.Get(labels.BucketLabel); le != "" {
+			upperBound, err := strconv.ParseFloat(le, 64)
+
+			// If the 'le' label is not a valid float, Prometheus' engine ignores float points from this series.
+			if err == nil {
+				seriesGroups.upperBound = upperBound
+				seriesGroups.classic = getGroup(series.Labels.BytesWithoutLabels(b, labels.BucketLabel), seriesIdx, func() labels.Labels {
+					lb.Reset(series.Labels)
+					lb.Del(labels.MetricName, labels.BucketLabel)
+					return lb.Labels()
+				})
+			}
+		}
+
+		seriesGroups.native = getGroup(series.Labels.Bytes(b), seriesIdx, func() labels.Labels {
+			return series.Labels.DropMetricName()
+		})
+
+		h.remainingInnerSeriesToGroups = append(h.remainingInnerSeriesToGroups, seriesGroups)
+	}
+
+	// Sort the groups into the order they'll be completed in. Use a stable sort so that the output is deterministic
+	// if one series is the last series for both a classic and a native histogram group.
+	slices.SortStableFunc(orderedGroups, func(a, b *histogramGroup) int {
+		return a.lastSeriesIndex - b.lastSeriesIndex
+	})
+
+	seriesMetadata := pooling.GetSeriesMetadataSlice(len(orderedGroups))
+	for _, g := range orderedGroups {
+		seriesMetadata = append(seriesMetadata, types.SeriesMetadata{Labels: g.labels})
+	}
+
+	h.remainingGroups = orderedGroups
+
+	return seriesMetadata, nil
+}
+
+func (h *HistogramFunction) NextSeries(ctx context.Context) (types.InstantVectorSeriesData, error) {
+	if len(h.remainingGroups) == 0 {
+		// No more groups left.
+		return types.InstantVectorSeriesData{}, types.EOS
+	}
+
+	// Determine next group to return
+	thisGroup := h.remainingGroups[0]
+	h.remainingGroups = h.remainingGroups[1:]
+
+	// Iterate through inner series until the desired group is complete
+	if err := h.accumulateUntilGroupComplete(ctx, thisGroup); err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	defer h.releaseGroup(thisGroup)
+
+	return h.computeOutputSeriesForGroup(thisGroup)
+}
+
+func (h *HistogramFunction) accumulateUntilGroupComplete(ctx context.Context, g *histogramGroup) error {
+	for g.remainingSeriesCount > 0 {
+		s, err := h.Inner.NextSeries(ctx)
+		if err != nil {
+			if errors.Is(err, types.EOS) {
+				return fmt.Errorf("exhausted series before all groups were completed: %w", err)
+			}
+
+			return err
+		}
+
+		thisSeriesGroups := h.remainingInnerSeriesToGroups[0]
+		h.remainingInnerSeriesToGroups = h.remainingInnerSeriesToGroups[1:]
+
+		if thisSeriesGroups.classic != nil && len(s.Floats) > 0 {
+			thisSeriesGroups.classic.classicBuckets = append(thisSeriesGroups.classic.classicBuckets, classicHistogramBucketSeries{
+				upperBound: thisSeriesGroups.upperBound,
+				points:     s.Floats,
+			})
+		} else {
+			h.Pool.PutFPointSlice(s.Floats)
+		}
+
+		if thisSeriesGroups.classic != nil {
+			thisSeriesGroups.classic.remainingSeriesCount--
+		}
+
+		// Each native histogram group has at most one series with native histograms, as series have unique labels.
+		if len(s.Histograms) > 0 {
+			thisSeriesGroups.native.nativeHistograms = s.Histograms
+		} else {
+			h.Pool.PutHPointSlice(s.Histograms)
+		}
+
+		thisSeriesGroups.native.remainingSeriesCount--
+	}
+
+	return nil
+}
+
+func (h *HistogramFunction) computeOutputSeriesForGroup(g *histogramGroup) (types.InstantVectorSeriesData, error) {
+	data := types.InstantVectorSeriesData{}
+
+	if len(g.classicBuckets) == 0 && len(g.nativeHistograms) == 0 {
+		return data, nil
+	}
+
+	steps := h.scalarArgsData[0].Samples
+
+	// bucketIndices[i] is the index of the next point in g.classicBuckets[i].
+	bucketIndices, err := h.Pool.GetIntSlice(len(g.classicBuckets))
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	defer h.Pool.PutIntSlice(bucketIndices)
+	bucketIndices = bucketIndices[:len(g.classicBuckets)]
+	nextNativeHistogramIndex := 0
+
+	for stepIdx, step := range steps {
+		t := step.T
+
+		h.buckets = h.buckets[:0]
+		for i, bucketSeries := range g.classicBuckets {
+			if bucketIndices[i] < len(bucketSeries.points) && bucketSeries.points[bucketIndices[i]].T == t {
+				h.buckets = append(h.buckets, functions.Bucket{UpperBound: bucketSeries.upperBound, Count: bucketSeries.points[bucketIndices[i]].F})
+				bucketIndices[i]++
+			}
+		}
+
+		var nativeHistogram *promql.HPoint
+		if nextNativeHistogramIndex < len(g.nativeHistograms) && g.nativeHistograms[nextNativeHistogramIndex].T == t {
+			nativeHistogram = &g.nativeHistograms[nextNativeHistogramIndex]
+			nextNativeHistogramIndex++
+		}
+
+		if len(h.buckets) == 0 && nativeHistogram == nil {
+			continue
+		}
+
+		if len(h.buckets) > 0 && nativeHistogram != nil {
+			// We have both classic buckets and a native histogram with the same labels at this step.
+			// Prometheus' engine does not produce a result in this case, so neither do we.
+			continue
+		}
+
+		for i, d := range h.scalarArgsData {
+			h.scalarArgsValues[i] = d.Samples[stepIdx].F
+		}
+
+		var f float64
+		if nativeHistogram != nil {
+			f = h.NativeHistogramFunc(h.scalarArgsValues, nativeHistogram.H)
+		} else {
+			f = h.ClassicHistogramFunc(h.scalarArgsValues, h.buckets)
+		}
+
+		if data.Floats == nil {
+			data.Floats, err = h.Pool.GetFPointSlice(len(steps))
+			if err != nil {
+				return types.InstantVectorSeriesData{}, err
+			}
+		}
+
+		data.Floats = append(data.Floats, promql.FPoint{T: t, F: f})
+	}
+
+	return data, nil
+}
+
+func (h *HistogramFunction) releaseGroup(g *histogramGroup) {
+	for _, b := range g.classicBuckets {
+		h.Pool.PutFPointSlice(b.points)
+	}
+
+	g.classicBuckets = nil
+
+	h.Pool.PutHPointSlice(g.nativeHistograms)
+	g.nativeHistograms = nil
+}
+
+func (h *HistogramFunction) Close() {
+	h.Inner.Close()
+
+	for _, a := range h.ScalarArgs {
+		a.Close()
+	}
+
+	for _, d := range h.scalarArgsData {
+		h.Pool.PutFPointSlice(d.Samples)
+	}
+
+	h.scalarArgsData = nil
+
+	// Return any data held by groups that were not completed, eg. because the query was aborted.
+	for _, g := range h.remainingGroups {
+		h.releaseGroup(g)
+	}
+
+	h.remainingGroups = nil
+}
@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package operators
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/functions"
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// Most of the functionality of histogram_quantile and histogram_fraction is tested through the test scripts in
+// pkg/streamingpromql/testdata.
+// The cases here cover behaviour that can't be tested there, either because Prometheus' engine emits annotations
+// we don't yet support, or because Prometheus' engine does not support the behaviour.
+
+func TestHistogramFunction(t *testing.T) {
+	start := timestamp.Time(0)
+	end := start.Add(2 * time.Minute)
+	interval := time.Minute
+
+	nativeHistogram := &histogram.FloatHistogram{
+		Count:           4,
+		Sum:             5,
+		PositiveSpans:   []histogram.Span{{Offset: 0, Length: 3}},
+		PositiveBuckets: []float64{1, 2, 1},
+	}
+
+	testCases := map[string]struct {
+		inner                *testOperator
+		scalarArgs           []float64
+		classicHistogramFunc functions.ClassicHistogramFunction
+		nativeHistogramFunc  functions.NativeHistogramFunction
+
+		expectedSeries []labels.Labels
+		expectedData   []types.InstantVectorSeriesData
+	}{
+		"classic and native histograms with the same labels": {
+			inner: &testOperator{
+				series: []labels.Labels{
+					labels.FromStrings(labels.MetricName, "metric", "le", "1"),
+					labels.FromStrings(labels.MetricName, "metric", "le", "+Inf"),
+					labels.FromStrings(labels.MetricName, "metric"),
+				},
+				data: []types.InstantVectorSeriesData{
+					{Floats: []promql.FPoint{{T: 0, F: 1}, {T: 60000, F: 2}}},
+					{Floats: []promql.FPoint{{T: 0, F: 2}, {T: 60000, F: 4}}},
+					{Histograms: []promql.HPoint{{T: 60000, H: nativeHistogram}, {T: 120000, H: nativeHistogram}}},
+				},
+			},
+			scalarArgs:           []float64{0.5},
+			classicHistogramFunc: functions.ClassicHistogramQuantile,
+			nativeHistogramFunc:  functions.NativeHistogramQuantile,
+
+			expectedSeries: []labels.Labels{
+				labels.FromStrings("le", "1"),
+				labels.FromStrings("le", "+Inf"),
+				labels.EmptyLabels(),
+			},
+			expectedData: []types.InstantVectorSeriesData{
+				{},
+				{},
+				// No point is produced at T=60000, as there are both classic buckets and a native histogram at that step.
+				{Floats: []promql.FPoint{{T: 0, F: 1}, {T: 120000, F: 1.5}}},
+			},
+		},
+		"histogram_fraction over classic histograms": {
+			inner: &testOperator{
+				series: []labels.Labels{
+					labels.FromStrings(labels.MetricName, "metric_bucket", "env", "prod", "le", "0.5"),
+					labels.FromStrings(labels.MetricName, "metric_bucket", "env", "prod", "le", "1"),
+					labels.FromStrings(labels.MetricName, "metric_bucket", "env", "prod", "le", "+Inf"),
+				},
+				data: []types.InstantVectorSeriesData{
+					{Floats: []promql.FPoint{{T: 0, F: 1}, {T: 60000, F: 2}, {T: 120000, F: 0}}},
+					{Floats: []promql.FPoint{{T: 0, F: 3}, {T: 60000, F: 4}, {T: 120000, F: 0}}},
+					{Floats: []promql.FPoint{{T: 0, F: 4}, {T: 60000, F: 8}, {T: 120000, F: 0}}},
+				},
+			},
+			scalarArgs:           []float64{0.25, 1},
+			classicHistogramFunc: functions.ClassicHistogramFraction,
+			nativeHistogramFunc:  functions.NativeHistogramFraction,
+
+			expectedSeries: []labels.Labels{
+				labels.FromStrings("env", "prod", "le", "0.5"),
+				labels.FromStrings("env", "prod", "le", "1"),
+				labels.FromStrings("env", "prod"),
+				labels.FromStrings("env", "prod", "le", "+Inf"),
+			},
+			expectedData: []types.InstantVectorSeriesData{
+				{},
+				{},
+				// At T=0: (3 - 0.5) / 4, at T=60000: (4 - 1) / 8, and at T=120000 there are no observations.
+				{Floats: []promql.FPoint{{T: 0, F: 0.625}, {T: 60000, F: 0.375}, {T: 120000, F: math.NaN()}}},
+				{},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			pool := pooling.NewLimitingPool(0, nil)
+
+			scalarArgs := make([]types.ScalarOperator, 0, len(testCase.scalarArgs))
+			for _, v := range testCase.scalarArgs {
+				scalarArgs = append(scalarArgs, NewScalarConstant(v, start, end, interval, pool))
+			}
+
+			operator := &HistogramFunction{
+				Inner:      testCase.inner,
+				ScalarArgs: scalarArgs,
+				Pool:       pool,
+
+				ClassicHistogramFunc: testCase.classicHistogramFunc,
+				NativeHistogramFunc:  testCase.nativeHistogramFunc,
+			}
+
+			ctx := context.Background()
+			metadata, err := operator.SeriesMetadata(ctx)
+			require.NoError(t, err)
+			require.Equal(t, labelsToSeriesMetadata(testCase.expectedSeries), metadata)
+
+			for i, expected := range testCase.expectedData {
+				d, err := operator.NextSeries(ctx)
+				require.NoError(t, err)
+				requireInstantVectorSeriesDataEqual(t, expected, d, "series %d", i)
+			}
+
+			_, err = operator.NextSeries(ctx)
+			require.True(t, errors.Is(err, types.EOS))
+		})
+	}
+}
+
+// requireInstantVectorSeriesDataEqual is like require.Equal, but considers NaN values to be equal to each other.
+func requireInstantVectorSeriesDataEqual(t *testing.T, expected, actual types.InstantVectorSeriesData, msgAndArgs ...interface{}) {
+	require.Len(t, actual.Floats, len(expected.Floats), msgAndArgs...)
+
+	for i, p := range expected.Floats {
+		require.Equal(t, p.T, actual.Floats[i].T, msgAndArgs...)
+
+		if math.IsNaN(p.F) {
+			require.True(t, math.IsNaN(actual.Floats[i].F), msgAndArgs...)
+		} else {
+			require.Equal(t, p.F, actual.Floats[i].F, msgAndArgs...)
+		}
+	}
+
+	require.Empty(t, actual.Histograms, msgAndArgs...)
+}
@@ -0,0 +1,242 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package operators
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// InstantVectorDuplicationBuffer evaluates Inner once and returns its results to multiple consumers, so that
+// an expression that appears multiple times in a query is only evaluated once.
+//
+// Series read from Inner are buffered until every consumer has read them. The memory consumed by buffered series,
+// and by the copies of series returned to each consumer, is tracked by Pool.
+//
+// Consumers may be evaluated from different goroutines simultaneously, for example if they are prefetched.
+type InstantVectorDuplicationBuffer struct {
+	Inner types.InstantVectorOperator
+	Pool  *pooling.LimitingPool
+
+	consumers []*InstantVectorDuplicationConsumer
+
+	seriesMetadata            []types.SeriesMetadata
+	haveLoadedSeriesMetadata  bool
+	consumersAwaitingMetadata int
+	seriesCount               int
+	nextInnerSeriesIndex      int
+	bufferedSeries            map[int]types.InstantVectorSeriesData
+	openConsumers             int
+
+	// Protects all fields above, and ensures Inner is only evaluated by one goroutine at a time.
+	mtx sync.Mutex
+}
+
+func NewInstantVectorDuplicationBuffer(inner types.InstantVectorOperator, pool *pooling.LimitingPool) *InstantVectorDuplicationBuffer {
+	return &InstantVectorDuplicationBuffer{
+		Inner:          inner,
+		Pool:           pool,
+		bufferedSeries: map[int]types.InstantVectorSeriesData{},
+	}
+}
+
+// AddConsumer returns a new operator that returns the results of Inner.
+//
+// All consumers must be added before any consumer is evaluated.
+func (b *InstantVectorDuplicationBuffer) AddConsumer() *InstantVectorDuplicationConsumer {
+	c := &InstantVectorDuplicationConsumer{
+		Buffer: b,
+		Index:  len(b.consumers),
+	}
+
+	b.consumers = append(b.consumers, c)
+	b.consumersAwaitingMetadata++
+	b.openConsumers++
+
+	return c
+}
+
+// ConsumerCount returns the number of consumers of this buffer.
+func (b *InstantVectorDuplicationBuffer) ConsumerCount() int {
+	return len(b.consumers)
+}
+
+func (b *InstantVectorDuplicationBuffer) getSeriesMetadata(ctx context.Context, consumer *InstantVectorDuplicationConsumer) ([]types.SeriesMetadata, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if !b.haveLoadedSeriesMetadata {
+		var err error
+		b.seriesMetadata, err = b.Inner.SeriesMetadata(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		b.haveLoadedSeriesMetadata = true
+		b.seriesCount = len(b.seriesMetadata)
+	}
+
+	consumer.haveReadSeriesMetadata = true
+	b.consumersAwaitingMetadata--
+
+	if b.consumersAwaitingMetadata == 0 {
+		// This is the last consumer to request series metadata, so it can have the original slice.
+		metadata := b.seriesMetadata
+		b.seriesMetadata = nil
+		return metadata, nil
+	}
+
+	// Consumers may modify the slice they receive, so give each other consumer its own copy.
+	metadata := pooling.GetSeriesMetadataSlice(len(b.seriesMetadata))
+	metadata = append(metadata, b.seriesMetadata...)
+
+	return metadata, nil
+}
+
+func (b *InstantVectorDuplicationBuffer) nextSeries(ctx context.Context, consumer *InstantVectorDuplicationConsumer) (types.InstantVectorSeriesData, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	seriesIndex := consumer.nextSeriesIndex
+
+	if seriesIndex >= b.seriesCount {
+		return types.InstantVectorSeriesData{}, types.EOS
+	}
+
+	if seriesIndex == b.nextInnerSeriesIndex {
+		// Each consumer reads series in order, so this consumer is the first to read this series.
+		d, err := b.Inner.NextSeries(ctx)
+		if err != nil {
+			return types.InstantVectorSeriesData{}, err
+		}
+
+		b.nextInnerSeriesIndex++
+		consumer.nextSeriesIndex++
+
+		if !b.isSeriesNeededByOtherConsumers(seriesIndex, consumer) {
+			return d, nil
+		}
+
+		// Keep the original data for the last consumer to read this series, and return a copy to this consumer.
+		b.bufferedSeries[seriesIndex] = d
+		return b.copySeriesData(d)
+	}
+
+	d := b.bufferedSeries[seriesIndex]
+	consumer.nextSeriesIndex++
+
+	if b.isSeriesNeededByOtherConsumers(seriesIndex, consumer) {
+		return b.copySeriesData(d)
+	}
+
+	// This is the last consumer to read this series, so it can have the original data.
+	delete(b.bufferedSeries, seriesIndex)
+	return d, nil
+}
+
+func (b *InstantVectorDuplicationBuffer) isSeriesNeededByOtherConsumers(seriesIndex int, consumer *InstantVectorDuplicationConsumer) bool {
+	for _, c := range b.consumers {
+		if c == consumer || c.closed {
+			continue
+		}
+
+		if c.nextSeriesIndex <= seriesIndex {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *InstantVectorDuplicationBuffer) copySeriesData(d types.InstantVectorSeriesData) (types.InstantVectorSeriesData, error) {
+	c := types.InstantVectorSeriesData{}
+
+	if len(d.Floats) > 0 {
+		var err error
+		if c.Floats, err = b.Pool.GetFPointSlice(len(d.Floats)); err != nil {
+			return types.InstantVectorSeriesData{}, err
+		}
+
+		c.Floats = append(c.Floats, d.Floats...)
+	}
+
+	if len(d.Histograms) > 0 {
+		var err error
+		if c.Histograms, err = b.Pool.GetHPointSlice(len(d.Histograms)); err != nil {
+			b.Pool.PutFPointSlice(c.Floats)
+			return types.InstantVectorSeriesData{}, err
+		}
+
+		// Copy each histogram rather than sharing it: histograms in slices returned to the pool may be reused
+		// by other operators, and each consumer may return its slice to the pool at any time.
+		for _, p := range d.Histograms {
+			c.Histograms = append(c.Histograms, promql.HPoint{T: p.T, H: p.H.Copy()})
+		}
+	}
+
+	return c, nil
+}
+
+func (b *InstantVectorDuplicationBuffer) closeConsumer(consumer *InstantVectorDuplicationConsumer) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if consumer.closed {
+		return
+	}
+
+	consumer.closed = true
+	b.openConsumers--
+
+	if !consumer.haveReadSeriesMetadata {
+		b.consumersAwaitingMetadata--
+	}
+
+	// Release any buffered series that are no longer needed by any open consumer.
+	for seriesIndex, d := range b.bufferedSeries {
+		if !b.isSeriesNeededByOtherConsumers(seriesIndex, consumer) {
+			b.Pool.PutInstantVectorSeriesData(d)
+			delete(b.bufferedSeries, seriesIndex)
+		}
+	}
+
+	if b.openConsumers > 0 {
+		return
+	}
+
+	b.Inner.Close()
+
+	if b.seriesMetadata != nil {
+		pooling.PutSeriesMetadataSlice(b.seriesMetadata)
+		b.seriesMetadata = nil
+	}
+}
+
+// InstantVectorDuplicationConsumer returns the results of an InstantVectorDuplicationBuffer's inner operator.
+type InstantVectorDuplicationConsumer struct {
+	Buffer *InstantVectorDuplicationBuffer
+	Index  int // The index of this consumer among the buffer's consumers.
+
+	haveReadSeriesMetadata bool
+	nextSeriesIndex        int
+	closed                 bool
+}
+
+var _ types.InstantVectorOperator = &InstantVectorDuplicationConsumer{}
+
+func (c *InstantVectorDuplicationConsumer) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	return c.Buffer.getSeriesMetadata(ctx, c)
+}
+
+func (c *InstantVectorDuplicationConsumer) NextSeries(ctx context.Context) (types.InstantVectorSeriesData, error) {
+	return c.Buffer.nextSeries(ctx, c)
+}
+
+func (c *InstantVectorDuplicationConsumer) Close() {
+	c.Buffer.closeConsumer(c)
+}
@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package operators
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// Most of the functionality of the duplication buffer is tested through the test scripts in
+// pkg/streamingpromql/testdata.
+//
+// The scripts can't control the order in which consumers read series or check that buffered series are released,
+// so we test these here.
+func TestInstantVectorDuplicationBuffer(t *testing.T) {
+	series := []labels.Labels{
+		labels.FromStrings("series", "1"),
+		labels.FromStrings("series", "2"),
+		labels.FromStrings("series", "3"),
+	}
+
+	type step struct {
+		consumer int
+		close    bool // If false, read the next series.
+	}
+
+	testCases := map[string]struct {
+		consumers int
+		steps     []step
+	}{
+		"single consumer": {
+			consumers: 1,
+			steps:     []step{{consumer: 0}, {consumer: 0}, {consumer: 0}},
+		},
+		"consumers read series in lockstep": {
+			consumers: 2,
+			steps:     []step{{consumer: 0}, {consumer: 1}, {consumer: 0}, {consumer: 1}, {consumer: 0}, {consumer: 1}},
+		},
+		"first consumer reads all series before second consumer": {
+			consumers: 2,
+			steps:     []step{{consumer: 0}, {consumer: 0}, {consumer: 0}, {consumer: 1}, {consumer: 1}, {consumer: 1}},
+		},
+		"second consumer reads all series before first consumer": {
+			consumers: 3,
+			steps:     []step{{consumer: 1}, {consumer: 1}, {consumer: 1}, {consumer: 2}, {consumer: 0}, {consumer: 2}, {consumer: 0}, {consumer: 0}, {consumer: 2}},
+		},
+		"consumer closed before reading all series": {
+			consumers: 2,
+			steps:     []step{{consumer: 0}, {consumer: 0}, {consumer: 0}, {consumer: 1}, {consumer: 1, close: true}},
+		},
+		"consumer closed before reading any series": {
+			consumers: 2,
+			steps:     []step{{consumer: 0}, {consumer: 1, close: true}, {consumer: 0}, {consumer: 0}},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			pool := pooling.NewLimitingPool(0, nil)
+			inner := &closeTrackingOperator{series: series}
+
+			for i := range series {
+				floats, err := pool.GetFPointSlice(1)
+				require.NoError(t, err)
+				histograms, err := pool.GetHPointSlice(1)
+				require.NoError(t, err)
+
+				inner.data = append(inner.data, types.InstantVectorSeriesData{
+					Floats:     append(floats, promql.FPoint{T: 0, F: float64(i)}),
+					Histograms: append(histograms, promql.HPoint{T: 1, H: &histogram.FloatHistogram{Count: float64(i)}}),
+				})
+			}
+
+			expectedData := make([]types.InstantVectorSeriesData, 0, len(series))
+			for i := range series {
+				expectedData = append(expectedData, types.InstantVectorSeriesData{
+					Floats:     []promql.FPoint{{T: 0, F: float64(i)}},
+					Histograms: []promql.HPoint{{T: 1, H: &histogram.FloatHistogram{Count: float64(i)}}},
+				})
+			}
+
+			buffer := NewInstantVectorDuplicationBuffer(inner, pool)
+			consumers := make([]*InstantVectorDuplicationConsumer, 0, testCase.consumers)
+			nextSeriesIndex := make([]int, testCase.consumers)
+			closed := make([]bool, testCase.consumers)
+
+			for i := 0; i < testCase.consumers; i++ {
+				consumers = append(consumers, buffer.AddConsumer())
+			}
+
+			for _, c := range consumers {
+				metadata, err := c.SeriesMetadata(ctx)
+				require.NoError(t, err)
+				require.Equal(t, labelsToSeriesMetadata(series), metadata)
+				pooling.PutSeriesMetadataSlice(metadata)
+			}
+
+			var returned []types.InstantVectorSeriesData
+
+			for _, s := range testCase.steps {
+				c := consumers[s.consumer]
+
+				if s.close {
+					c.Close()
+					closed[s.consumer] = true
+					continue
+				}
+
+				d, err := c.NextSeries(ctx)
+				require.NoError(t, err)
+				require.Equal(t, expectedData[nextSeriesIndex[s.consumer]], d)
+				nextSeriesIndex[s.consumer]++
+
+				for _, other := range returned {
+					require.NotSame(t, &other.Floats[0], &d.Floats[0], "each consumer should receive its own slices")
+					require.NotSame(t, other.Histograms[0].H, d.Histograms[0].H, "each consumer should receive its own histograms")
+				}
+
+				// Hold on to the data until the end of the test so we can check it isn't shared.
+				returned = append(returned, d)
+			}
+
+			for i, c := range consumers {
+				if nextSeriesIndex[i] == len(series) {
+					_, err := c.NextSeries(ctx)
+					require.Equal(t, types.EOS, err)
+				}
+			}
+
+			for i, c := range consumers {
+				if closed[i] {
+					continue
+				}
+
+				require.False(t, inner.closed, "inner operator should not be closed until all consumers are closed")
+				c.Close()
+			}
+
+			require.True(t, inner.closed)
+
+			for _, d := range returned {
+				pool.PutInstantVectorSeriesData(d)
+			}
+
+			// Return any series never read from the inner operator: any series read from the inner operator but not
+			// returned to a consumer should have been released by the buffer.
+			for _, d := range inner.data {
+				pool.PutInstantVectorSeriesData(d)
+			}
+
+			require.Zero(t, pool.CurrentEstimatedMemoryConsumptionBytes)
+		})
+	}
+}
+
+type closeTrackingOperator struct {
+	series []labels.Labels
+	data   []types.InstantVectorSeriesData
+	closed bool
+}
+
+func (o *closeTrackingOperator) SeriesMetadata(_ context.Context) ([]types.SeriesMetadata, error) {
+	return labelsToSeriesMetadata(o.series), nil
+}
+
+func (o *closeTrackingOperator) NextSeries(_ context.Context) (types.InstantVectorSeriesData, error) {
+	if len(o.data) == 0 {
+		return types.InstantVectorSeriesData{}, types.EOS
+	}
+
+	d := o.data[0]
+	o.data = o.data[1:]
+
+	return d, nil
+}
+
+func (o *closeTrackingOperator) Close() {
+	o.closed = true
+}
@@ -23,6 +23,10 @@ type InstantVectorSelector struct {
 	Selector *Selector
 	Pool     *pooling.LimitingPool
 
+	// If true, the value of each point is the timestamp of the selected sample, in seconds, rather than its value.
+	// This is used to implement timestamp() over a vector selector.
+	ReturnSampleTimestamps bool
+
 	numSteps int
 
 	chunkIterator    chunkenc.Iterator
@@ -63,6 +67,8 @@ func (v *InstantVectorSelector) NextSeries(ctx context.Context) (types.InstantVe
 			ts = *v.Selector.Timestamp
 		}
 
+		ts -= v.Selector.Offset
+
 		valueType := v.memoizedIterator.Seek(ts)
 
 		switch valueType {
@@ -89,6 +95,17 @@ func (v *InstantVectorSelector) NextSeries(ctx context.Context) (types.InstantVe
 			continue
 		}
 
+		if v.ReturnSampleTimestamps {
+			if len(data.Floats) == 0 {
+				var err error
+				if data.Floats, err = v.Pool.GetFPointSlice(v.numSteps); err != nil {
+					return types.InstantVectorSeriesData{}, err
+				}
+			}
+			data.Floats = append(data.Floats, promql.FPoint{T: stepT, F: float64(t) / 1000})
+			continue
+		}
+
 		// if (f, h) have been set by PeekPrev, we do not know if f is 0 because that's the actual value, or because
 		// the previous value had a histogram.
 		// PeekPrev will set the histogram to nil, or the value to 0 if the other type exists.
@@ -119,6 +136,8 @@ func (v *InstantVectorSelector) NextSeries(ctx context.Context) (types.InstantVe
 		return types.InstantVectorSeriesData{}, v.memoizedIterator.Err()
 	}
 
+	v.Selector.Stats.IncrementSamples(types.SampleCount(data.Floats, data.Histograms))
+
 	return data, nil
 }
 
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/functions.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package operators
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// InstantVectorToScalar implements the scalar() function: at each time step, its value is the value of the single
+// float sample in the inner instant vector, or NaN if the inner instant vector does not have exactly one sample.
+//
+// Native histograms are never converted to a scalar value, but still count towards the number of samples at each time step.
+type InstantVectorToScalar struct {
+	Inner    types.InstantVectorOperator
+	Start    int64 // Milliseconds since Unix epoch
+	End      int64 // Milliseconds since Unix epoch
+	Interval int64 // In milliseconds
+	Pool     *pooling.LimitingPool
+}
+
+var _ types.ScalarOperator = &InstantVectorToScalar{}
+
+func NewInstantVectorToScalar(inner types.InstantVectorOperator, start time.Time, end time.Time, interval time.Duration, pool *pooling.LimitingPool) *InstantVectorToScalar {
+	return &InstantVectorToScalar{
+		Inner:    inner,
+		Start:    timestamp.FromTime(start),
+		End:      timestamp.FromTime(end),
+		Interval: interval.Milliseconds(),
+		Pool:     pool,
+	}
+}
+
+func (i *InstantVectorToScalar) GetValues(ctx context.Context) (types.ScalarData, error) {
+	metadata, err := i.Inner.SeriesMetadata(ctx)
+	if err != nil {
+		return types.ScalarData{}, err
+	}
+
+	seriesCount := len(metadata)
+	pooling.PutSeriesMetadataSlice(metadata)
+
+	steps := stepCount(i.Start, i.End, i.Interval)
+
+	samples, err := i.Pool.GetFPointSlice(steps)
+	if err != nil {
+		return types.ScalarData{}, err
+	}
+
+	// The number of samples seen at each time step.
+	sampleCounts, err := i.Pool.GetIntSlice(steps)
+	if err != nil {
+		return types.ScalarData{}, err
+	}
+
+	defer i.Pool.PutIntSlice(sampleCounts)
+	sampleCounts = sampleCounts[:steps]
+
+	for t := i.Start; t <= i.End; t += i.Interval {
+		samples = append(samples, promql.FPoint{T: t, F: math.NaN()})
+	}
+
+	for seriesIdx := 0; seriesIdx < seriesCount; seriesIdx++ {
+		d, err := i.Inner.NextSeries(ctx)
+		if err != nil {
+			if errors.Is(err, types.EOS) {
+				return types.ScalarData{}, errors.New("exhausted series before all series were read")
+			}
+
+			return types.ScalarData{}, err
+		}
+
+		for _, p := range d.Floats {
+			idx := (p.T - i.Start) / i.Interval
+			sampleCounts[idx]++
+			samples[idx].F = p.F
+		}
+
+		for _, p := range d.Histograms {
+			idx := (p.T - i.Start) / i.Interval
+			sampleCounts[idx]++
+			samples[idx].F = math.NaN()
+		}
+
+		i.Pool.PutInstantVectorSeriesData(d)
+	}
+
+	for idx, count := range sampleCounts {
+		if count != 1 {
+			samples[idx].F = math.NaN()
+		}
+	}
+
+	return types.ScalarData{Samples: samples}, nil
+}
+
+func (i *InstantVectorToScalar) Close() {
+	i.Inner.Close()
+}
@@ -0,0 +1,230 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package operators
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// instrumentation records statistics about the evaluation of an operator.
+//
+// Wall time and peak memory consumption include time spent and memory consumed in the operators consumed by the
+// instrumented operator, as the instrumented operator calls them. If other operators are evaluated concurrently
+// (eg. because they are being prefetched), peak memory consumption may also include memory they consume.
+type instrumentation struct {
+	pool      *pooling.LimitingPool
+	stats     types.OperatorStats
+	evaluated bool
+}
+
+type instrumentationFrame struct {
+	start           time.Time
+	peakMeasurement pooling.PeakMeasurement
+}
+
+func (i *instrumentation) begin() instrumentationFrame {
+	i.evaluated = true
+
+	return instrumentationFrame{
+		start:           time.Now(),
+		peakMeasurement: i.pool.BeginPeakMeasurement(),
+	}
+}
+
+func (i *instrumentation) end(f instrumentationFrame) {
+	i.stats.WallTime += time.Since(f.start)
+
+	peak := i.pool.EndPeakMeasurement(f.peakMeasurement)
+	i.stats.PeakEstimatedMemoryConsumptionBytes = max(i.stats.PeakEstimatedMemoryConsumptionBytes, peak)
+}
+
+// Stats returns the statistics recorded for the instrumented operator so far, or nil if it has not been evaluated.
+func (i *instrumentation) Stats() *types.OperatorStats {
+	if !i.evaluated {
+		return nil
+	}
+
+	stats := i.stats
+	return &stats
+}
+
+// InstrumentedInstantVectorOperator records statistics about the evaluation of Inner.
+type InstrumentedInstantVectorOperator struct {
+	Inner types.InstantVectorOperator
+	instrumentation
+}
+
+var _ types.InstantVectorOperator = &InstrumentedInstantVectorOperator{}
+
+// InstrumentInstantVectorOperator returns an operator that records statistics about the evaluation of inner.
+//
+// If inner is already instrumented, it is returned unchanged.
+func InstrumentInstantVectorOperator(inner types.InstantVectorOperator, pool *pooling.LimitingPool) types.InstantVectorOperator {
+	if _, ok := inner.(*InstrumentedInstantVectorOperator); ok {
+		return inner
+	}
+
+	return &InstrumentedInstantVectorOperator{
+		Inner:           inner,
+		instrumentation: instrumentation{pool: pool},
+	}
+}
+
+func (o *InstrumentedInstantVectorOperator) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	f := o.begin()
+	defer o.end(f)
+
+	series, err := o.Inner.SeriesMetadata(ctx)
+	o.stats.OutputSeries += len(series)
+
+	return series, err
+}
+
+func (o *InstrumentedInstantVectorOperator) NextSeries(ctx context.Context) (types.InstantVectorSeriesData, error) {
+	f := o.begin()
+	defer o.end(f)
+
+	d, err := o.Inner.NextSeries(ctx)
+	o.stats.OutputSamples += len(d.Floats) + len(d.Histograms)
+
+	return d, err
+}
+
+func (o *InstrumentedInstantVectorOperator) Close() {
+	o.Inner.Close()
+}
+
+// InstrumentedRangeVectorOperator records statistics about the evaluation of Inner.
+//
+// Calls to NextStepSamples are not timed, as they are made once per time step and the overhead of timing them
+// would be significant. Time spent in NextStepSamples is instead included in the wall time of the operator that
+// consumes Inner.
+type InstrumentedRangeVectorOperator struct {
+	Inner types.RangeVectorOperator
+	instrumentation
+}
+
+var _ types.RangeVectorOperator = &InstrumentedRangeVectorOperator{}
+
+// InstrumentRangeVectorOperator returns an operator that records statistics about the evaluation of inner.
+//
+// If inner is already instrumented, it is returned unchanged.
+func InstrumentRangeVectorOperator(inner types.RangeVectorOperator, pool *pooling.LimitingPool) types.RangeVectorOperator {
+	if _, ok := inner.(*InstrumentedRangeVectorOperator); ok {
+		return inner
+	}
+
+	return &InstrumentedRangeVectorOperator{
+		Inner:           inner,
+		instrumentation: instrumentation{pool: pool},
+	}
+}
+
+func (o *InstrumentedRangeVectorOperator) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	f := o.begin()
+	defer o.end(f)
+
+	series, err := o.Inner.SeriesMetadata(ctx)
+	o.stats.OutputSeries += len(series)
+
+	return series, err
+}
+
+func (o *InstrumentedRangeVectorOperator) StepCount() int {
+	return o.Inner.StepCount()
+}
+
+func (o *InstrumentedRangeVectorOperator) Range() time.Duration {
+	return o.Inner.Range()
+}
+
+func (o *InstrumentedRangeVectorOperator) NextSeries(ctx context.Context) error {
+	f := o.begin()
+	defer o.end(f)
+
+	return o.Inner.NextSeries(ctx)
+}
+
+func (o *InstrumentedRangeVectorOperator) NextStepSamples(floats *types.FPointRingBuffer, histograms *types.HPointRingBuffer) (types.RangeVectorStepData, error) {
+	step, err := o.Inner.NextStepSamples(floats, histograms)
+	if err != nil {
+		return step, err
+	}
+
+	floatsHead, floatsTail := floats.UnsafePoints(step.RangeEnd)
+	histogramsHead, histogramsTail := histograms.UnsafePoints(step.RangeEnd)
+	o.stats.OutputSamples += len(floatsHead) + len(floatsTail) + len(histogramsHead) + len(histogramsTail)
+
+	return step, nil
+}
+
+func (o *InstrumentedRangeVectorOperator) Close() {
+	o.Inner.Close()
+}
+
+// InstrumentedScalarOperator records statistics about the evaluation of Inner.
+type InstrumentedScalarOperator struct {
+	Inner types.ScalarOperator
+	instrumentation
+}
+
+var _ types.ScalarOperator = &InstrumentedScalarOperator{}
+
+// InstrumentScalarOperator returns an operator that records statistics about the evaluation of inner.
+//
+// If inner is already instrumented, it is returned unchanged.
+func InstrumentScalarOperator(inner types.ScalarOperator, pool *pooling.LimitingPool) types.ScalarOperator {
+	if _, ok := inner.(*InstrumentedScalarOperator); ok {
+		return inner
+	}
+
+	return &InstrumentedScalarOperator{
+		Inner:           inner,
+		instrumentation: instrumentation{pool: pool},
+	}
+}
+
+func (o *InstrumentedScalarOperator) GetValues(ctx context.Context) (types.ScalarData, error) {
+	f := o.begin()
+	defer o.end(f)
+
+	d, err := o.Inner.GetValues(ctx)
+	o.stats.OutputSamples += len(d.Samples)
+
+	return d, err
+}
+
+func (o *InstrumentedScalarOperator) Close() {
+	o.Inner.Close()
+}
+
+// Uninstrumented returns the operator instrumented by op, or op itself if it is not instrumented.
+func Uninstrumented(op types.Operator) types.Operator {
+	switch o := op.(type) {
+	case *InstrumentedInstantVectorOperator:
+		return o.Inner
+	case *InstrumentedRangeVectorOperator:
+		return o.Inner
+	case *InstrumentedScalarOperator:
+		return o.Inner
+	default:
+		return op
+	}
+}
+
+func instrumentationOf(op types.Operator) *instrumentation {
+	switch o := op.(type) {
+	case *InstrumentedInstantVectorOperator:
+		return &o.instrumentation
+	case *InstrumentedRangeVectorOperator:
+		return &o.instrumentation
+	case *InstrumentedScalarOperator:
+		return &o.instrumentation
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,261 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/engine.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package operators
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// LimitK implements the limitk aggregation.
+//
+// For each group and step, the first K points seen are returned, and all others are dropped.
+type LimitK struct {
+	Inner    types.InstantVectorOperator
+	Start    int64 // Milliseconds since Unix epoch
+	Interval int64 // In milliseconds
+	Steps    int
+	K        int
+	Grouping []string // If this is a 'without' aggregation, NewLimitK will ensure that this slice contains __name__.
+	Without  bool
+	Pool     *pooling.LimitingPool
+
+	remainingInnerSeriesToGroup []*limitKGroup // One entry per series produced by Inner, value is the group for that series
+}
+
+type limitKGroup struct {
+	// The number of input series that belong to this group that we haven't yet seen.
+	remainingSeriesCount uint
+
+	// The number of points returned so far for each step.
+	pointCounts []int
+}
+
+var _ types.InstantVectorOperator = &LimitK{}
+
+func NewLimitK(
+	inner types.InstantVectorOperator,
+	k float64,
+	start time.Time,
+	end time.Time,
+	interval time.Duration,
+	grouping []string,
+	without bool,
+	pool *pooling.LimitingPool,
+) (*LimitK, error) {
+	if !convertibleToInt64(k) {
+		return nil, fmt.Errorf("Scalar value %v overflows int64", k) //nolint:revive
+	}
+
+	s, e, i := timestamp.FromTime(start), timestamp.FromTime(end), interval.Milliseconds()
+
+	return &LimitK{
+		Inner:    inner,
+		Start:    s,
+		Interval: i,
+		Steps:    stepCount(s, e, i),
+		K:        int(k),
+		Grouping: sortedGroupingLabels(grouping, without),
+		Without:  without,
+		Pool:     pool,
+	}, nil
+}
+
+func (l *LimitK) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	innerSeries, err := l.Inner.SeriesMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(innerSeries) == 0 || l.K < 1 {
+		// No input series or k < 1 == no output series.
+		pooling.PutSeriesMetadataSlice(innerSeries)
+		return nil, nil
+	}
+
+	groups := map[string]*limitKGroup{}
+	groupLabelsBytesFunc, _ := seriesToGroupFuncs(l.Grouping, l.Without)
+	l.remainingInnerSeriesToGroup = make([]*limitKGroup, 0, len(innerSeries))
+
+	for _, series := range innerSeries {
+		groupLabelsString := groupLabelsBytesFunc(series.Labels)
+		g, groupExists := groups[string(groupLabelsString)] // Important: don't extract the string(...) call here - passing it directly allows us to avoid allocating it.
+
+		if !groupExists {
+			g = &limitKGroup{}
+			groups[string(groupLabelsString)] = g
+		}
+
+		g.remainingSeriesCount++
+		l.remainingInnerSeriesToGroup = append(l.remainingInnerSeriesToGroup, g)
+	}
+
+	// Each output series is an input series, in the same order.
+	return innerSeries, nil
+}
+
+func (l *LimitK) NextSeries(ctx context.Context) (types.InstantVectorSeriesData, error) {
+	if len(l.remainingInnerSeriesToGroup) == 0 {
+		return types.InstantVectorSeriesData{}, types.EOS
+	}
+
+	data, err := l.Inner.NextSeries(ctx)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	g := l.remainingInnerSeriesToGroup[0]
+	l.remainingInnerSeriesToGroup = l.remainingInnerSeriesToGroup[1:]
+
+	if g.pointCounts == nil {
+		if g.pointCounts, err = l.Pool.GetIntSlice(l.Steps); err != nil {
+			return types.InstantVectorSeriesData{}, err
+		}
+
+		g.pointCounts = g.pointCounts[:l.Steps]
+	}
+
+	// Remove points from steps where we've already returned K points, reusing the slices we received from Inner.
+	floats := data.Floats[:0]
+	for _, p := range data.Floats {
+		if l.shouldKeepPoint(g, p.T) {
+			floats = append(floats, p)
+		}
+	}
+
+	histograms := data.Histograms[:0]
+	for _, p := range data.Histograms {
+		if l.shouldKeepPoint(g, p.T) {
+			histograms = append(histograms, p)
+		}
+	}
+
+	data.Floats = floats
+	data.Histograms = histograms
+
+	g.remainingSeriesCount--
+	if g.remainingSeriesCount == 0 {
+		l.Pool.PutIntSlice(g.pointCounts)
+		g.pointCounts = nil
+	}
+
+	return data, nil
+}
+
+func (l *LimitK) shouldKeepPoint(g *limitKGroup, t int64) bool {
+	idx := (t - l.Start) / l.Interval
+
+	if g.pointCounts[idx] >= l.K {
+		return false
+	}
+
+	g.pointCounts[idx]++
+	return true
+}
+
+func (l *LimitK) Close() {
+	l.Inner.Close()
+}
+
+// LimitRatio implements the limit_ratio aggregation.
+//
+// Whether a series is returned is determined solely from the hash of its labels, so each input series is either
+// returned in its entirety or dropped entirely.
+type LimitRatio struct {
+	Inner types.InstantVectorOperator
+	Ratio float64
+	Pool  *pooling.LimitingPool
+
+	remainingInnerSeriesToReturn []bool // One entry per series produced by Inner, value is true if the series should be returned
+}
+
+var _ types.InstantVectorOperator = &LimitRatio{}
+
+func NewLimitRatio(inner types.InstantVectorOperator, ratio float64, pool *pooling.LimitingPool) (*LimitRatio, error) {
+	if math.IsNaN(ratio) {
+		return nil, fmt.Errorf("Ratio value %v is NaN", ratio) //nolint:revive
+	}
+
+	// Ratios outside [-1, 1] are clamped, consistent with Prometheus' engine.
+	ratio = max(min(ratio, 1), -1)
+
+	return &LimitRatio{
+		Inner: inner,
+		Ratio: ratio,
+		Pool:  pool,
+	}, nil
+}
+
+func (l *LimitRatio) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	innerSeries, err := l.Inner.SeriesMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(innerSeries) == 0 || l.Ratio == 0 {
+		// No input series or ratio of 0 == no output series.
+		pooling.PutSeriesMetadataSlice(innerSeries)
+		return nil, nil
+	}
+
+	l.remainingInnerSeriesToReturn = make([]bool, 0, len(innerSeries))
+	outputSeries := innerSeries[:0]
+
+	for _, series := range innerSeries {
+		shouldReturn := l.shouldReturnSeries(series.Labels)
+		l.remainingInnerSeriesToReturn = append(l.remainingInnerSeriesToReturn, shouldReturn)
+
+		if shouldReturn {
+			outputSeries = append(outputSeries, series)
+		}
+	}
+
+	return outputSeries, nil
+}
+
+// shouldReturnSeries uses the hash of the series' labels as a deterministic value in [0, 1] to decide whether
+// the series should be returned, in the same way as Prometheus' engine.
+func (l *LimitRatio) shouldReturnSeries(lbls labels.Labels) bool {
+	offset := float64(lbls.Hash()) / float64(math.MaxUint64)
+
+	if l.Ratio >= 0 {
+		return offset < l.Ratio
+	}
+
+	return offset >= 1+l.Ratio
+}
+
+func (l *LimitRatio) NextSeries(ctx context.Context) (types.InstantVectorSeriesData, error) {
+	for len(l.remainingInnerSeriesToReturn) > 0 {
+		data, err := l.Inner.NextSeries(ctx)
+		if err != nil {
+			return types.InstantVectorSeriesData{}, err
+		}
+
+		shouldReturn := l.remainingInnerSeriesToReturn[0]
+		l.remainingInnerSeriesToReturn = l.remainingInnerSeriesToReturn[1:]
+
+		if shouldReturn {
+			return data, nil
+		}
+
+		l.Pool.PutInstantVectorSeriesData(data)
+	}
+
+	return types.InstantVectorSeriesData{}, types.EOS
+}
+
+func (l *LimitRatio) Close() {
+	l.Inner.Close()
+}
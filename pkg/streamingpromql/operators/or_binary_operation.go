@@ -0,0 +1,216 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/engine.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package operators
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// OrBinaryOperation represents the set operator "<expr> or <expr>".
+//
+// It returns all points from the left side, and all points from the right side at steps where no series on the
+// left side in the same match group has a point.
+//
+// All left series are returned first, followed by all right series. Neither side is buffered as series: each left
+// series is reduced to a single presence flag per step for its match group as soon as it is read, and this is retained
+// only for match groups that have series on the right side.
+type OrBinaryOperation struct {
+	Left     types.InstantVectorOperator
+	Right    types.InstantVectorOperator
+	Start    int64 // Milliseconds since Unix epoch
+	Interval int64 // In milliseconds
+	Steps    int
+	Pool     *pooling.LimitingPool
+
+	VectorMatching parser.VectorMatching
+
+	remainingLeftSeriesGroups  []*orMatchGroup // One entry per series produced by Left, value is the match group for that series (or nil if there are no right series in the match group)
+	remainingRightSeriesGroups []*orMatchGroup // One entry per series produced by Right, value is the match group for that series (or nil if there are no left series in the match group)
+}
+
+var _ types.InstantVectorOperator = &OrBinaryOperation{}
+
+type orMatchGroup struct {
+	// The number of right series in this match group that we haven't yet returned.
+	remainingRightSeriesCount int
+
+	// leftPresence[i] is true if any left series in this match group has a point at step i.
+	leftPresence []bool
+}
+
+func NewOrBinaryOperation(
+	left types.InstantVectorOperator,
+	right types.InstantVectorOperator,
+	vectorMatching parser.VectorMatching,
+	start time.Time,
+	end time.Time,
+	interval time.Duration,
+	pool *pooling.LimitingPool,
+) *OrBinaryOperation {
+	s, e, i := timestamp.FromTime(start), timestamp.FromTime(end), interval.Milliseconds()
+
+	return &OrBinaryOperation{
+		Left:           left,
+		Right:          right,
+		VectorMatching: vectorMatching,
+		Start:          s,
+		Interval:       i,
+		Steps:          stepCount(s, e, i),
+		Pool:           pool,
+	}
+}
+
+func (o *OrBinaryOperation) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	leftMetadata, err := o.Left.SeriesMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rightMetadata, err := o.Right.SeriesMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer pooling.PutSeriesMetadataSlice(rightMetadata)
+
+	labelsFunc := matchGroupLabelsFunc(o.VectorMatching)
+	leftGroups := make(map[string]*orMatchGroup, len(leftMetadata))
+	o.remainingLeftSeriesGroups = make([]*orMatchGroup, len(leftMetadata))
+
+	for idx, s := range leftMetadata {
+		groupLabels := labelsFunc(s.Labels).String()
+		group, exists := leftGroups[groupLabels]
+
+		if !exists {
+			group = &orMatchGroup{}
+			leftGroups[groupLabels] = group
+		}
+
+		o.remainingLeftSeriesGroups[idx] = group
+	}
+
+	o.remainingRightSeriesGroups = make([]*orMatchGroup, len(rightMetadata))
+
+	for idx, s := range rightMetadata {
+		if group, exists := leftGroups[labelsFunc(s.Labels).String()]; exists {
+			group.remainingRightSeriesCount++
+			o.remainingRightSeriesGroups[idx] = group
+		}
+	}
+
+	// Only retain the match groups for left series that are needed by at least one right series.
+	for idx, group := range o.remainingLeftSeriesGroups {
+		if group.remainingRightSeriesCount == 0 {
+			o.remainingLeftSeriesGroups[idx] = nil
+		}
+	}
+
+	outputMetadata := pooling.GetSeriesMetadataSlice(len(leftMetadata) + len(rightMetadata))
+	outputMetadata = append(outputMetadata, leftMetadata...)
+	outputMetadata = append(outputMetadata, rightMetadata...)
+	pooling.PutSeriesMetadataSlice(leftMetadata)
+
+	return outputMetadata, nil
+}
+
+func (o *OrBinaryOperation) NextSeries(ctx context.Context) (types.InstantVectorSeriesData, error) {
+	if len(o.remainingLeftSeriesGroups) > 0 {
+		return o.nextLeftSeries(ctx)
+	}
+
+	if len(o.remainingRightSeriesGroups) > 0 {
+		return o.nextRightSeries(ctx)
+	}
+
+	return types.InstantVectorSeriesData{}, types.EOS
+}
+
+func (o *OrBinaryOperation) nextLeftSeries(ctx context.Context) (types.InstantVectorSeriesData, error) {
+	data, err := o.Left.NextSeries(ctx)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	group := o.remainingLeftSeriesGroups[0]
+	o.remainingLeftSeriesGroups = o.remainingLeftSeriesGroups[1:]
+
+	if group == nil {
+		// No right series in this match group, so there's no need to track which steps this series has points at.
+		return data, nil
+	}
+
+	if group.leftPresence == nil {
+		if group.leftPresence, err = o.Pool.GetBoolSlice(o.Steps); err != nil {
+			return types.InstantVectorSeriesData{}, err
+		}
+
+		group.leftPresence = group.leftPresence[:o.Steps]
+	}
+
+	for _, p := range data.Floats {
+		group.leftPresence[(p.T-o.Start)/o.Interval] = true
+	}
+
+	for _, p := range data.Histograms {
+		group.leftPresence[(p.T-o.Start)/o.Interval] = true
+	}
+
+	// Left series are always returned unchanged.
+	return data, nil
+}
+
+func (o *OrBinaryOperation) nextRightSeries(ctx context.Context) (types.InstantVectorSeriesData, error) {
+	data, err := o.Right.NextSeries(ctx)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	group := o.remainingRightSeriesGroups[0]
+	o.remainingRightSeriesGroups = o.remainingRightSeriesGroups[1:]
+
+	if group == nil {
+		// No left series in this match group, so return the right series unchanged.
+		return data, nil
+	}
+
+	// Filter the points in place, reusing the slices from the right side.
+	floats := data.Floats[:0]
+	for _, p := range data.Floats {
+		if !group.leftPresence[(p.T-o.Start)/o.Interval] {
+			floats = append(floats, p)
+		}
+	}
+
+	histograms := data.Histograms[:0]
+	for _, p := range data.Histograms {
+		if !group.leftPresence[(p.T-o.Start)/o.Interval] {
+			histograms = append(histograms, p)
+		}
+	}
+
+	data.Floats = floats
+	data.Histograms = histograms
+
+	group.remainingRightSeriesCount--
+	if group.remainingRightSeriesCount == 0 {
+		o.Pool.PutBoolSlice(group.leftPresence)
+		group.leftPresence = nil
+	}
+
+	return data, nil
+}
+
+func (o *OrBinaryOperation) Close() {
+	o.Left.Close()
+	o.Right.Close()
+}
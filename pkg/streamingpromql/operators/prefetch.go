@@ -0,0 +1,301 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package operators
+
+import (
+	"context"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// maxPrefetchedSeries is the maximum number of series prefetched from each instant vector operator, in addition to
+// its series metadata.
+const maxPrefetchedSeries = 10
+
+// PrefetchLimiter limits the number of operators that can be prefetched concurrently for a single query.
+type PrefetchLimiter struct {
+	slots chan struct{}
+}
+
+func NewPrefetchLimiter(maxConcurrentPrefetches int) *PrefetchLimiter {
+	return &PrefetchLimiter{
+		slots: make(chan struct{}, maxConcurrentPrefetches),
+	}
+}
+
+// tryAcquire returns true if another prefetch can begin, or false if the limit has been reached.
+//
+// If tryAcquire returns true, release must be called once the prefetch is complete.
+func (l *PrefetchLimiter) tryAcquire() bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *PrefetchLimiter) release() {
+	<-l.slots
+}
+
+// PrefetchGroup prefetches the results of sibling operators, such as both sides of a binary operation, so that their
+// results are fetched concurrently rather than one after another.
+//
+// When any operator in the group is first evaluated, the group begins evaluating every other operator in the group
+// in the background. Operators are only evaluated in the background if the query's PrefetchLimiter allows it,
+// otherwise they are evaluated when first called, as if they were not in the group.
+//
+// Each operator is only ever evaluated by one goroutine at a time.
+type PrefetchGroup struct {
+	Limiter *PrefetchLimiter
+
+	members []prefetchGroupMember
+	started bool
+}
+
+type prefetchGroupMember interface {
+	startPrefetching(ctx context.Context)
+}
+
+func NewPrefetchGroup(limiter *PrefetchLimiter) *PrefetchGroup {
+	return &PrefetchGroup{
+		Limiter: limiter,
+	}
+}
+
+// AddInstantVectorOperator returns an operator that returns the results of inner, prefetching its series metadata and
+// first series when another operator in the group is first evaluated.
+//
+// All operators must be added before any operator in the group is evaluated.
+func (g *PrefetchGroup) AddInstantVectorOperator(inner types.InstantVectorOperator, pool *pooling.LimitingPool) *PrefetchingInstantVectorOperator {
+	o := &PrefetchingInstantVectorOperator{
+		Inner: inner,
+		Group: g,
+		Pool:  pool,
+	}
+
+	g.members = append(g.members, o)
+
+	return o
+}
+
+// AddScalarOperator returns an operator that returns the results of inner, prefetching them when another operator in
+// the group is first evaluated.
+//
+// All operators must be added before any operator in the group is evaluated.
+func (g *PrefetchGroup) AddScalarOperator(inner types.ScalarOperator, pool *pooling.LimitingPool) *PrefetchingScalarOperator {
+	o := &PrefetchingScalarOperator{
+		Inner: inner,
+		Group: g,
+		Pool:  pool,
+	}
+
+	g.members = append(g.members, o)
+
+	return o
+}
+
+// start begins prefetching every member of the group other than caller, if the group has not already started.
+func (g *PrefetchGroup) start(ctx context.Context, caller prefetchGroupMember) {
+	if g.started {
+		return
+	}
+
+	g.started = true
+
+	for _, m := range g.members {
+		if m != caller {
+			m.startPrefetching(ctx)
+		}
+	}
+}
+
+// prefetch tracks the state of a single operator being prefetched.
+type prefetch struct {
+	done   chan struct{} // Closed once prefetching is complete. nil if prefetching has not started.
+	cancel context.CancelFunc
+	closed bool
+}
+
+// start runs f in the background if prefetching is allowed by limiter.
+func (p *prefetch) start(ctx context.Context, limiter *PrefetchLimiter, f func(ctx context.Context)) {
+	if p.closed || p.done != nil || !limiter.tryAcquire() {
+		return
+	}
+
+	// We deliberately don't cancel this context once prefetching is complete: operators may retain the context
+	// passed to them (eg. selectors streaming chunks), so we only cancel it once the operator is closed.
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+		defer limiter.release()
+
+		f(ctx)
+	}()
+}
+
+// wait waits for any prefetching to complete, and returns true if the operator was prefetched.
+func (p *prefetch) wait() bool {
+	if p.done == nil {
+		return false
+	}
+
+	<-p.done
+	return true
+}
+
+// close stops any prefetching in progress, waits for it to complete and returns true if the operator was prefetched
+// and this is the first call to close.
+func (p *prefetch) close() bool {
+	if p.closed {
+		return false
+	}
+
+	p.closed = true
+
+	if p.done == nil {
+		return false
+	}
+
+	p.cancel()
+	<-p.done
+
+	return true
+}
+
+// PrefetchingInstantVectorOperator returns the results of Inner, which may be prefetched in the background.
+// It is created by PrefetchGroup.
+type PrefetchingInstantVectorOperator struct {
+	Inner types.InstantVectorOperator
+	Group *PrefetchGroup
+	Pool  *pooling.LimitingPool
+
+	prefetch prefetch
+
+	seriesMetadata       []types.SeriesMetadata
+	seriesMetadataErr    error
+	prefetchedSeries     []types.InstantVectorSeriesData
+	nextPrefetchedSeries int
+	prefetchedSeriesErr  error
+}
+
+var _ types.InstantVectorOperator = &PrefetchingInstantVectorOperator{}
+
+func (o *PrefetchingInstantVectorOperator) startPrefetching(ctx context.Context) {
+	o.prefetch.start(ctx, o.Group.Limiter, func(ctx context.Context) {
+		o.seriesMetadata, o.seriesMetadataErr = o.Inner.SeriesMetadata(ctx)
+		if o.seriesMetadataErr != nil {
+			return
+		}
+
+		seriesCount := min(len(o.seriesMetadata), maxPrefetchedSeries)
+		o.prefetchedSeries = make([]types.InstantVectorSeriesData, 0, seriesCount)
+
+		for len(o.prefetchedSeries) < seriesCount {
+			d, err := o.Inner.NextSeries(ctx)
+			if err != nil {
+				o.prefetchedSeriesErr = err
+				return
+			}
+
+			o.prefetchedSeries = append(o.prefetchedSeries, d)
+		}
+	})
+}
+
+func (o *PrefetchingInstantVectorOperator) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	o.Group.start(ctx, o)
+
+	if !o.prefetch.wait() {
+		return o.Inner.SeriesMetadata(ctx)
+	}
+
+	metadata := o.seriesMetadata
+	o.seriesMetadata = nil
+
+	return metadata, o.seriesMetadataErr
+}
+
+func (o *PrefetchingInstantVectorOperator) NextSeries(ctx context.Context) (types.InstantVectorSeriesData, error) {
+	if !o.prefetch.wait() {
+		return o.Inner.NextSeries(ctx)
+	}
+
+	if o.nextPrefetchedSeries < len(o.prefetchedSeries) {
+		d := o.prefetchedSeries[o.nextPrefetchedSeries]
+		o.prefetchedSeries[o.nextPrefetchedSeries] = types.InstantVectorSeriesData{}
+		o.nextPrefetchedSeries++
+
+		return d, nil
+	}
+
+	if o.prefetchedSeriesErr != nil {
+		return types.InstantVectorSeriesData{}, o.prefetchedSeriesErr
+	}
+
+	return o.Inner.NextSeries(ctx)
+}
+
+func (o *PrefetchingInstantVectorOperator) Close() {
+	if o.prefetch.close() {
+		for _, d := range o.prefetchedSeries[o.nextPrefetchedSeries:] {
+			o.Pool.PutInstantVectorSeriesData(d)
+		}
+
+		o.prefetchedSeries = nil
+
+		if o.seriesMetadata != nil {
+			pooling.PutSeriesMetadataSlice(o.seriesMetadata)
+			o.seriesMetadata = nil
+		}
+	}
+
+	o.Inner.Close()
+}
+
+// PrefetchingScalarOperator returns the results of Inner, which may be prefetched in the background.
+// It is created by PrefetchGroup.
+type PrefetchingScalarOperator struct {
+	Inner types.ScalarOperator
+	Group *PrefetchGroup
+	Pool  *pooling.LimitingPool
+
+	prefetch prefetch
+
+	values    types.ScalarData
+	valuesErr error
+}
+
+var _ types.ScalarOperator = &PrefetchingScalarOperator{}
+
+func (o *PrefetchingScalarOperator) startPrefetching(ctx context.Context) {
+	o.prefetch.start(ctx, o.Group.Limiter, func(ctx context.Context) {
+		o.values, o.valuesErr = o.Inner.GetValues(ctx)
+	})
+}
+
+func (o *PrefetchingScalarOperator) GetValues(ctx context.Context) (types.ScalarData, error) {
+	o.Group.start(ctx, o)
+
+	if !o.prefetch.wait() {
+		return o.Inner.GetValues(ctx)
+	}
+
+	values := o.values
+	o.values = types.ScalarData{}
+
+	return values, o.valuesErr
+}
+
+func (o *PrefetchingScalarOperator) Close() {
+	if o.prefetch.close() {
+		o.Pool.PutFPointSlice(o.values.Samples)
+		o.values = types.ScalarData{}
+	}
+
+	o.Inner.Close()
+}
@@ -0,0 +1,254 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package operators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// Most of the functionality of prefetching is tested through the test scripts in pkg/streamingpromql/testdata
+// and TestPrefetching in pkg/streamingpromql.
+//
+// The scripts can't control when operators are closed or inject errors, so we test these here.
+func TestPrefetchingInstantVectorOperator(t *testing.T) {
+	ctx := context.Background()
+
+	createOperator := func(t *testing.T, seriesCount int, pool *pooling.LimitingPool) *closeTrackingOperator {
+		o := &closeTrackingOperator{}
+
+		for i := 0; i < seriesCount; i++ {
+			floats, err := pool.GetFPointSlice(1)
+			require.NoError(t, err)
+
+			o.series = append(o.series, labels.FromStrings("series", fmt.Sprintf("%d", i)))
+			o.data = append(o.data, types.InstantVectorSeriesData{Floats: append(floats, promql.FPoint{T: 0, F: float64(i)})})
+		}
+
+		return o
+	}
+
+	t.Run("series are returned in order, including series not prefetched", func(t *testing.T) {
+		pool := pooling.NewLimitingPool(0, nil)
+		first := createOperator(t, 1, pool)
+		second := createOperator(t, maxPrefetchedSeries+2, pool)
+
+		group := NewPrefetchGroup(NewPrefetchLimiter(1))
+		firstPrefetching := group.AddInstantVectorOperator(first, pool)
+		secondPrefetching := group.AddInstantVectorOperator(second, pool)
+
+		_, err := firstPrefetching.SeriesMetadata(ctx)
+		require.NoError(t, err)
+		require.Nil(t, firstPrefetching.prefetch.done, "first operator evaluated should not be prefetched")
+		require.NotNil(t, secondPrefetching.prefetch.done, "second operator should be prefetched")
+
+		metadata, err := secondPrefetching.SeriesMetadata(ctx)
+		require.NoError(t, err)
+		require.Equal(t, labelsToSeriesMetadata(second.series), metadata)
+		require.Len(t, secondPrefetching.prefetchedSeries, maxPrefetchedSeries)
+
+		for i := 0; i < maxPrefetchedSeries+2; i++ {
+			d, err := secondPrefetching.NextSeries(ctx)
+			require.NoError(t, err)
+			require.Equal(t, []promql.FPoint{{T: 0, F: float64(i)}}, d.Floats)
+			pool.PutInstantVectorSeriesData(d)
+		}
+
+		_, err = secondPrefetching.NextSeries(ctx)
+		require.Equal(t, types.EOS, err)
+
+		firstPrefetching.Close()
+		secondPrefetching.Close()
+		require.True(t, first.closed)
+		require.True(t, second.closed)
+
+		for _, d := range first.data {
+			pool.PutInstantVectorSeriesData(d)
+		}
+
+		require.Zero(t, pool.CurrentEstimatedMemoryConsumptionBytes)
+	})
+
+	t.Run("closed before prefetched series are read", func(t *testing.T) {
+		pool := pooling.NewLimitingPool(0, nil)
+		first := createOperator(t, 1, pool)
+		second := createOperator(t, 3, pool)
+
+		group := NewPrefetchGroup(NewPrefetchLimiter(1))
+		firstPrefetching := group.AddInstantVectorOperator(first, pool)
+		secondPrefetching := group.AddInstantVectorOperator(second, pool)
+
+		_, err := firstPrefetching.SeriesMetadata(ctx)
+		require.NoError(t, err)
+
+		// Close the second operator without reading anything: the prefetched series should be returned to the pool.
+		secondPrefetching.Close()
+		require.True(t, second.closed)
+		require.Empty(t, second.data, "all series should have been prefetched")
+
+		firstPrefetching.Close()
+
+		for _, d := range first.data {
+			pool.PutInstantVectorSeriesData(d)
+		}
+
+		require.Zero(t, pool.CurrentEstimatedMemoryConsumptionBytes)
+	})
+
+	t.Run("closed before group started", func(t *testing.T) {
+		pool := pooling.NewLimitingPool(0, nil)
+		first := createOperator(t, 1, pool)
+		second := createOperator(t, 1, pool)
+
+		group := NewPrefetchGroup(NewPrefetchLimiter(1))
+		firstPrefetching := group.AddInstantVectorOperator(first, pool)
+		secondPrefetching := group.AddInstantVectorOperator(second, pool)
+
+		secondPrefetching.Close()
+
+		_, err := firstPrefetching.SeriesMetadata(ctx)
+		require.NoError(t, err)
+		require.Nil(t, secondPrefetching.prefetch.done, "closed operator should not be prefetched")
+
+		firstPrefetching.Close()
+	})
+
+	t.Run("limit reached", func(t *testing.T) {
+		pool := pooling.NewLimitingPool(0, nil)
+		limiter := NewPrefetchLimiter(1)
+		group := NewPrefetchGroup(limiter)
+
+		operators := []*PrefetchingInstantVectorOperator{
+			group.AddInstantVectorOperator(createOperator(t, 1, pool), pool),
+			group.AddInstantVectorOperator(createOperator(t, 1, pool), pool),
+			group.AddInstantVectorOperator(createOperator(t, 1, pool), pool),
+		}
+
+		// Occupy the only slot so nothing can be prefetched.
+		require.True(t, limiter.tryAcquire())
+
+		for _, o := range operators {
+			_, err := o.SeriesMetadata(ctx)
+			require.NoError(t, err)
+			require.Nil(t, o.prefetch.done, "operator should not be prefetched if limit is reached")
+
+			d, err := o.NextSeries(ctx)
+			require.NoError(t, err)
+			require.Equal(t, []promql.FPoint{{T: 0, F: 0}}, d.Floats)
+
+			o.Close()
+		}
+
+		limiter.release()
+	})
+
+	t.Run("error from SeriesMetadata while prefetching", func(t *testing.T) {
+		pool := pooling.NewLimitingPool(0, nil)
+		group := NewPrefetchGroup(NewPrefetchLimiter(1))
+		first := group.AddInstantVectorOperator(createOperator(t, 1, pool), pool)
+		second := group.AddInstantVectorOperator(&failingOperator{seriesMetadataErr: errors.New("metadata failed")}, pool)
+
+		_, err := first.SeriesMetadata(ctx)
+		require.NoError(t, err)
+
+		_, err = second.SeriesMetadata(ctx)
+		require.EqualError(t, err, "metadata failed")
+
+		first.Close()
+		second.Close()
+	})
+
+	t.Run("error from NextSeries while prefetching", func(t *testing.T) {
+		pool := pooling.NewLimitingPool(0, nil)
+		group := NewPrefetchGroup(NewPrefetchLimiter(1))
+		first := group.AddInstantVectorOperator(createOperator(t, 1, pool), pool)
+		second := group.AddInstantVectorOperator(&failingOperator{series: []labels.Labels{labels.FromStrings("series", "1")}, nextSeriesErr: errors.New("next series failed")}, pool)
+
+		_, err := first.SeriesMetadata(ctx)
+		require.NoError(t, err)
+
+		metadata, err := second.SeriesMetadata(ctx)
+		require.NoError(t, err)
+		require.Len(t, metadata, 1)
+
+		_, err = second.NextSeries(ctx)
+		require.EqualError(t, err, "next series failed")
+
+		first.Close()
+		second.Close()
+	})
+}
+
+func TestPrefetchingScalarOperator(t *testing.T) {
+	ctx := context.Background()
+	start, end := timestamp.Time(0), timestamp.Time(0).Add(time.Minute)
+
+	t.Run("prefetched values are returned", func(t *testing.T) {
+		pool := pooling.NewLimitingPool(0, nil)
+		group := NewPrefetchGroup(NewPrefetchLimiter(1))
+		first := group.AddScalarOperator(NewScalarConstant(1, start, end, time.Minute, pool), pool)
+		second := group.AddScalarOperator(NewScalarConstant(2, start, end, time.Minute, pool), pool)
+
+		d, err := first.GetValues(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []promql.FPoint{{T: 0, F: 1}, {T: 60000, F: 1}}, d.Samples)
+		pool.PutFPointSlice(d.Samples)
+		require.NotNil(t, second.prefetch.done, "second operator should be prefetched")
+
+		d, err = second.GetValues(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []promql.FPoint{{T: 0, F: 2}, {T: 60000, F: 2}}, d.Samples)
+		pool.PutFPointSlice(d.Samples)
+
+		first.Close()
+		second.Close()
+		require.Zero(t, pool.CurrentEstimatedMemoryConsumptionBytes)
+	})
+
+	t.Run("closed before prefetched values are read", func(t *testing.T) {
+		pool := pooling.NewLimitingPool(0, nil)
+		group := NewPrefetchGroup(NewPrefetchLimiter(1))
+		first := group.AddScalarOperator(NewScalarConstant(1, start, end, time.Minute, pool), pool)
+		second := group.AddScalarOperator(NewScalarConstant(2, start, end, time.Minute, pool), pool)
+
+		d, err := first.GetValues(ctx)
+		require.NoError(t, err)
+		pool.PutFPointSlice(d.Samples)
+
+		// Close the second operator without reading anything: the prefetched values should be returned to the pool.
+		first.Close()
+		second.Close()
+		require.Zero(t, pool.CurrentEstimatedMemoryConsumptionBytes)
+	})
+}
+
+type failingOperator struct {
+	series            []labels.Labels
+	seriesMetadataErr error
+	nextSeriesErr     error
+}
+
+func (o *failingOperator) SeriesMetadata(_ context.Context) ([]types.SeriesMetadata, error) {
+	if o.seriesMetadataErr != nil {
+		return nil, o.seriesMetadataErr
+	}
+
+	return labelsToSeriesMetadata(o.series), nil
+}
+
+func (o *failingOperator) NextSeries(_ context.Context) (types.InstantVectorSeriesData, error) {
+	return types.InstantVectorSeriesData{}, o.nextSeriesErr
+}
+
+func (o *failingOperator) Close() {}
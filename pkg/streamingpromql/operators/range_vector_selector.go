@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/prometheus/prometheus/model/histogram"
 	"github.com/prometheus/prometheus/model/value"
 	"github.com/prometheus/prometheus/promql"
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
@@ -68,6 +69,8 @@ func (m *RangeVectorSelector) NextStepSamples(floats *types.FPointRingBuffer, hi
 		rangeEnd = *m.Selector.Timestamp
 	}
 
+	rangeEnd -= m.Selector.Offset
+
 	rangeStart := rangeEnd - m.rangeMilliseconds
 	floats.DiscardPointsBefore(rangeStart)
 	histograms.DiscardPointsBefore(rangeStart)
@@ -76,6 +79,8 @@ func (m *RangeVectorSelector) NextStepSamples(floats *types.FPointRingBuffer, hi
 		return types.RangeVectorStepData{}, err
 	}
 
+	m.recordSamples(floats, histograms, rangeEnd)
+
 	m.nextT += m.Selector.Interval
 
 	return types.RangeVectorStepData{
@@ -118,6 +123,12 @@ func (m *RangeVectorSelector) fillBuffer(floats *types.FPointRingBuffer, histogr
 				continue
 			}
 			hPoint, _ := histograms.NextPoint()
+			if hPoint.H == nil {
+				// Don't pass nil to AtFloatHistogram: if we do, the returned histogram shares its spans with the iterator,
+				// and therefore with other histograms it returns. We reuse this histogram when the point is discarded
+				// from the buffer, which would then overwrite the spans of those other histograms.
+				hPoint.H = &histogram.FloatHistogram{}
+			}
 			hPoint.T, hPoint.H = m.chunkIterator.AtFloatHistogram(hPoint.H)
 			if value.IsStaleNaN(hPoint.H.Sum) {
 				// Range vectors ignore stale markers
@@ -136,6 +147,23 @@ func (m *RangeVectorSelector) fillBuffer(floats *types.FPointRingBuffer, histogr
 	}
 }
 
+// recordSamples records the number of samples in the range ending at rangeEnd in the query's statistics.
+//
+// Like Prometheus' engine, samples are counted once for each step they are selected at, so samples selected at
+// multiple steps are counted multiple times.
+func (m *RangeVectorSelector) recordSamples(floats *types.FPointRingBuffer, histograms *types.HPointRingBuffer, rangeEnd int64) {
+	if m.Selector.Stats == nil {
+		return
+	}
+
+	floatsHead, floatsTail := floats.UnsafePoints(rangeEnd)
+	histogramsHead, histogramsTail := histograms.UnsafePoints(rangeEnd)
+
+	m.Selector.Stats.IncrementSamples(
+		types.SampleCount(floatsHead, histogramsHead) + types.SampleCount(floatsTail, histogramsTail),
+	)
+}
+
 func (m *RangeVectorSelector) Close() {
 	m.Selector.Close()
 }
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/functions.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package operators
+
+import (
+	"context"
+
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// ScalarToInstantVector implements the vector() function: it returns a single series with no labels,
+// with the value of the scalar at each time step.
+type ScalarToInstantVector struct {
+	Scalar types.ScalarOperator
+
+	consumed bool
+}
+
+var _ types.InstantVectorOperator = &ScalarToInstantVector{}
+
+func (s *ScalarToInstantVector) SeriesMetadata(_ context.Context) ([]types.SeriesMetadata, error) {
+	metadata := pooling.GetSeriesMetadataSlice(1)
+	metadata = append(metadata, types.SeriesMetadata{Labels: labels.EmptyLabels()})
+
+	return metadata, nil
+}
+
+func (s *ScalarToInstantVector) NextSeries(ctx context.Context) (types.InstantVectorSeriesData, error) {
+	if s.consumed {
+		return types.InstantVectorSeriesData{}, types.EOS
+	}
+
+	s.consumed = true
+
+	d, err := s.Scalar.GetValues(ctx)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	return types.InstantVectorSeriesData{Floats: d.Samples}, nil
+}
+
+func (s *ScalarToInstantVector) Close() {
+	s.Scalar.Close()
+}
@@ -21,6 +21,7 @@ type Selector struct {
 	Start     int64  // Milliseconds since Unix epoch
 	End       int64  // Milliseconds since Unix epoch
 	Timestamp *int64 // Milliseconds since Unix epoch, only set if selector uses @ modifier (eg. metric{...} @ 123)
+	Offset    int64  // In milliseconds
 	Interval  int64  // In milliseconds
 	Matchers  []*labels.Matcher
 
@@ -30,6 +31,9 @@ type Selector struct {
 	// Set for range vector selectors, otherwise 0.
 	Range time.Duration
 
+	// Stats records the number of samples selected. May be nil.
+	Stats *types.QueryStats
+
 	querier storage.Querier
 	series  *seriesList
 
@@ -53,6 +57,11 @@ func (s *Selector) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata,
 		endTimestamp = *s.Timestamp
 	}
 
+	// Apply the offset after the @ modifier, so that queries like "metric @ 100 offset 50s" select data from 50s
+	// before timestamp 100, matching Prometheus' engine.
+	startTimestamp -= s.Offset
+	endTimestamp -= s.Offset
+
 	rangeMilliseconds := s.Range.Milliseconds()
 	start := startTimestamp - s.LookbackDelta.Milliseconds() - rangeMilliseconds
 
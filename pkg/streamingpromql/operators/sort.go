@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/functions.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package operators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// Sort implements the sort() and sort_desc() functions for expressions evaluated at a single time step.
+//
+// Sorting series by value is only meaningful when there is a single time step: Prometheus' engine always returns
+// the results of range queries sorted by labels, so sort() and sort_desc() have no effect on range queries, and
+// Sort should not be used for them.
+//
+// Consistent with Prometheus' engine, NaN values are always sorted last. Histograms are sorted as if their value was 0.
+type Sort struct {
+	Inner      types.InstantVectorOperator
+	Descending bool
+	Pool       *pooling.LimitingPool
+
+	allData []types.InstantVectorSeriesData // Data for all series, in the order they should be returned.
+}
+
+var _ types.InstantVectorOperator = &Sort{}
+
+func NewSort(inner types.InstantVectorOperator, descending bool, pool *pooling.LimitingPool) *Sort {
+	return &Sort{
+		Inner:      inner,
+		Descending: descending,
+		Pool:       pool,
+	}
+}
+
+type sortableSeries struct {
+	metadata types.SeriesMetadata
+	data     types.InstantVectorSeriesData
+}
+
+func (s sortableSeries) hasPoint() bool {
+	return len(s.data.Floats) > 0 || len(s.data.Histograms) > 0
+}
+
+func (s sortableSeries) value() float64 {
+	if len(s.data.Floats) > 0 {
+		return s.data.Floats[0].F
+	}
+
+	// Histograms don't have a single value to sort by, so treat them as 0.
+	return 0
+}
+
+func (s *Sort) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	metadata, err := s.Inner.SeriesMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// We need to read every series to determine the order in which to return them.
+	allSeries := make([]sortableSeries, 0, len(metadata))
+
+	for i := range metadata {
+		d, err := s.Inner.NextSeries(ctx)
+		if err != nil {
+			for _, series := range allSeries {
+				s.Pool.PutInstantVectorSeriesData(series.data)
+			}
+
+			if errors.Is(err, types.EOS) {
+				return nil, fmt.Errorf("expected %v series, but only received %v", len(metadata), i)
+			}
+
+			return nil, err
+		}
+
+		allSeries = append(allSeries, sortableSeries{metadata: metadata[i], data: d})
+	}
+
+	slices.SortStableFunc(allSeries, s.compare)
+
+	s.allData = make([]types.InstantVectorSeriesData, 0, len(allSeries))
+
+	for i, series := range allSeries {
+		metadata[i] = series.metadata
+		s.allData = append(s.allData, series.data)
+	}
+
+	return metadata, nil
+}
+
+func (s *Sort) compare(a, b sortableSeries) int {
+	// Series without a point at this step won't be returned, so their position doesn't matter: put them last.
+	if !a.hasPoint() || !b.hasPoint() {
+		if a.hasPoint() {
+			return -1
+		}
+
+		if b.hasPoint() {
+			return 1
+		}
+
+		return 0
+	}
+
+	aValue, bValue := a.value(), b.value()
+
+	if math.IsNaN(aValue) || math.IsNaN(bValue) {
+		if !math.IsNaN(aValue) {
+			return -1
+		}
+
+		if !math.IsNaN(bValue) {
+			return 1
+		}
+
+		return 0
+	}
+
+	if s.Descending {
+		aValue, bValue = bValue, aValue
+	}
+
+	if aValue < bValue {
+		return -1
+	}
+
+	if aValue > bValue {
+		return 1
+	}
+
+	return 0
+}
+
+func (s *Sort) NextSeries(_ context.Context) (types.InstantVectorSeriesData, error) {
+	if len(s.allData) == 0 {
+		return types.InstantVectorSeriesData{}, types.EOS
+	}
+
+	d := s.allData[0]
+	s.allData = s.allData[1:]
+
+	return d, nil
+}
+
+func (s *Sort) Close() {
+	s.Inner.Close()
+
+	for _, d := range s.allData {
+		s.Pool.PutInstantVectorSeriesData(d)
+	}
+
+	s.allData = nil
+}
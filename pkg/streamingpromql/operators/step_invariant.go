@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/engine.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package operators
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// StepInvariantInstantVectorOperator evaluates an instant vector expression that produces the same result at every
+// time step (eg. an expression using the @ modifier) once, and then returns that result at every time step.
+//
+// Inner must be evaluated at a single time step: the first time step of the query.
+type StepInvariantInstantVectorOperator struct {
+	Inner    types.InstantVectorOperator
+	Start    int64 // Milliseconds since Unix epoch
+	End      int64 // Milliseconds since Unix epoch
+	Interval int64 // In milliseconds
+	Pool     *pooling.LimitingPool
+
+	numSteps int
+}
+
+var _ types.InstantVectorOperator = &StepInvariantInstantVectorOperator{}
+
+func NewStepInvariantInstantVectorOperator(inner types.InstantVectorOperator, start time.Time, end time.Time, interval time.Duration, pool *pooling.LimitingPool) *StepInvariantInstantVectorOperator {
+	s := &StepInvariantInstantVectorOperator{
+		Inner:    inner,
+		Start:    timestamp.FromTime(start),
+		End:      timestamp.FromTime(end),
+		Interval: interval.Milliseconds(),
+		Pool:     pool,
+	}
+
+	s.numSteps = stepCount(s.Start, s.End, s.Interval)
+
+	return s
+}
+
+// InnerTimeRange returns the time range Inner is evaluated over.
+func (s *StepInvariantInstantVectorOperator) InnerTimeRange() types.QueryTimeRange {
+	return types.NewInstantQueryTimeRange(timestamp.Time(s.Start))
+}
+
+func (s *StepInvariantInstantVectorOperator) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	return s.Inner.SeriesMetadata(ctx)
+}
+
+func (s *StepInvariantInstantVectorOperator) NextSeries(ctx context.Context) (types.InstantVectorSeriesData, error) {
+	innerData, err := s.Inner.NextSeries(ctx)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	defer s.Pool.PutInstantVectorSeriesData(innerData)
+
+	if len(innerData.Floats)+len(innerData.Histograms) > 1 {
+		return types.InstantVectorSeriesData{}, fmt.Errorf("expected at most one sample from step invariant expression, got %d", len(innerData.Floats)+len(innerData.Histograms))
+	}
+
+	data := types.InstantVectorSeriesData{}
+
+	if len(innerData.Floats) == 1 {
+		if data.Floats, err = s.Pool.GetFPointSlice(s.numSteps); err != nil {
+			return types.InstantVectorSeriesData{}, err
+		}
+
+		f := innerData.Floats[0].F
+
+		for t := s.Start; t <= s.End; t += s.Interval {
+			data.Floats = append(data.Floats, promql.FPoint{T: t, F: f})
+		}
+	}
+
+	if len(innerData.Histograms) == 1 {
+		if data.Histograms, err = s.Pool.GetHPointSlice(s.numSteps); err != nil {
+			return types.InstantVectorSeriesData{}, err
+		}
+
+		// Like the instant vector selector when lookback occurs, we use the same histogram for every point.
+		h := innerData.Histograms[0].H
+
+		for t := s.Start; t <= s.End; t += s.Interval {
+			data.Histograms = append(data.Histograms, promql.HPoint{T: t, H: h})
+		}
+	}
+
+	return data, nil
+}
+
+func (s *StepInvariantInstantVectorOperator) Close() {
+	s.Inner.Close()
+}
+
+// StepInvariantScalarOperator evaluates a scalar expression that produces the same result at every time step
+// (eg. an expression using the @ modifier) once, and then returns that result at every time step.
+//
+// Inner must be evaluated at a single time step: the first time step of the query.
+type StepInvariantScalarOperator struct {
+	Inner    types.ScalarOperator
+	Start    int64 // Milliseconds since Unix epoch
+	End      int64 // Milliseconds since Unix epoch
+	Interval int64 // In milliseconds
+	Pool     *pooling.LimitingPool
+}
+
+var _ types.ScalarOperator = &StepInvariantScalarOperator{}
+
+func NewStepInvariantScalarOperator(inner types.ScalarOperator, start time.Time, end time.Time, interval time.Duration, pool *pooling.LimitingPool) *StepInvariantScalarOperator {
+	return &StepInvariantScalarOperator{
+		Inner:    inner,
+		Start:    timestamp.FromTime(start),
+		End:      timestamp.FromTime(end),
+		Interval: interval.Milliseconds(),
+		Pool:     pool,
+	}
+}
+
+// InnerTimeRange returns the time range Inner is evaluated over.
+func (s *StepInvariantScalarOperator) InnerTimeRange() types.QueryTimeRange {
+	return types.NewInstantQueryTimeRange(timestamp.Time(s.Start))
+}
+
+func (s *StepInvariantScalarOperator) GetValues(ctx context.Context) (types.ScalarData, error) {
+	innerData, err := s.Inner.GetValues(ctx)
+	if err != nil {
+		return types.ScalarData{}, err
+	}
+
+	defer s.Pool.PutFPointSlice(innerData.Samples)
+
+	if len(innerData.Samples) != 1 {
+		return types.ScalarData{}, fmt.Errorf("expected exactly one sample from step invariant expression, got %d", len(innerData.Samples))
+	}
+
+	samples, err := s.Pool.GetFPointSlice(stepCount(s.Start, s.End, s.Interval))
+	if err != nil {
+		return types.ScalarData{}, err
+	}
+
+	f := innerData.Samples[0].F
+
+	for t := s.Start; t <= s.End; t += s.Interval {
+		samples = append(samples, promql.FPoint{T: t, F: f})
+	}
+
+	return types.ScalarData{Samples: samples}, nil
+}
+
+func (s *StepInvariantScalarOperator) Close() {
+	s.Inner.Close()
+}
@@ -0,0 +1,26 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package operators
+
+import (
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// StringLiteral is a string literal, such as the label name arguments to label_replace.
+type StringLiteral struct {
+	Value string
+}
+
+var _ types.StringOperator = &StringLiteral{}
+
+func NewStringLiteral(value string) *StringLiteral {
+	return &StringLiteral{Value: value}
+}
+
+func (s *StringLiteral) GetValue() string {
+	return s.Value
+}
+
+func (s *StringLiteral) Close() {
+	// Nothing to do.
+}
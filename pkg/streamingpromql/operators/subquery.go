@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/engine.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package operators
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/prometheus/model/timestamp"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// Subquery evaluates an instant vector expression at each step of a subquery, and exposes the result as a range vector.
+//
+// Inner must be evaluated over the time range returned by SubqueryTimeRange. Each series from Inner is held in memory
+// only until the next series is requested.
+type Subquery struct {
+	Inner                types.InstantVectorOperator
+	ParentQueryTimeRange types.QueryTimeRange
+	SubqueryTimeRange    types.QueryTimeRange // The time range Inner is evaluated over.
+
+	SubqueryTimestamp *int64 // Milliseconds since Unix epoch, only set if subquery uses @ modifier (eg. (metric{...})[5m:1m] @ 123)
+	SubqueryOffset    int64  // In milliseconds
+	SubqueryRange     time.Duration
+
+	Pool *pooling.LimitingPool
+
+	parentStart    int64 // Milliseconds since Unix epoch
+	parentEnd      int64 // Milliseconds since Unix epoch
+	parentInterval int64 // In milliseconds
+	numSteps       int
+
+	rangeMilliseconds int64
+	nextStepT         int64
+
+	data               types.InstantVectorSeriesData // The current series from Inner.
+	nextFloatIndex     int                           // Index into data.Floats of the next point not yet added to the ring buffer.
+	nextHistogramIndex int                           // Index into data.Histograms of the next point not yet added to the ring buffer.
+}
+
+var _ types.RangeVectorOperator = &Subquery{}
+
+func NewSubquery(
+	inner types.InstantVectorOperator,
+	parentQueryTimeRange types.QueryTimeRange,
+	subqueryTimeRange types.QueryTimeRange,
+	subqueryTimestamp *int64,
+	subqueryOffset time.Duration,
+	subqueryRange time.Duration,
+	pool *pooling.LimitingPool,
+) *Subquery {
+	s, e, i := timestamp.FromTime(parentQueryTimeRange.Start), timestamp.FromTime(parentQueryTimeRange.End), parentQueryTimeRange.Interval.Milliseconds()
+
+	return &Subquery{
+		Inner:                inner,
+		ParentQueryTimeRange: parentQueryTimeRange,
+		SubqueryTimeRange:    subqueryTimeRange,
+		SubqueryTimestamp:    subqueryTimestamp,
+		SubqueryOffset:       subqueryOffset.Milliseconds(),
+		SubqueryRange:        subqueryRange,
+		Pool:                 pool,
+
+		parentStart:       s,
+		parentEnd:         e,
+		parentInterval:    i,
+		numSteps:          stepCount(s, e, i),
+		rangeMilliseconds: subqueryRange.Milliseconds(),
+	}
+}
+
+// SubqueryTimeRange returns the time range over which the expression inside a subquery must be evaluated, given the
+// time range of the parent expression.
+//
+// Subqueries are evaluated at steps aligned to the Unix epoch, not to the parent expression's steps, consistent with
+// Prometheus' engine.
+func SubqueryTimeRange(parentQueryTimeRange types.QueryTimeRange, subqueryTimestamp *int64, subqueryOffset time.Duration, subqueryRange time.Duration, step time.Duration) types.QueryTimeRange {
+	start, end := timestamp.FromTime(parentQueryTimeRange.Start), timestamp.FromTime(parentQueryTimeRange.End)
+
+	if subqueryTimestamp != nil {
+		start, end = *subqueryTimestamp, *subqueryTimestamp
+	}
+
+	end -= subqueryOffset.Milliseconds()
+
+	// Start with the first timestamp after (start - offset - range) that is aligned with the step.
+	interval := step.Milliseconds()
+	earliestT := start - subqueryOffset.Milliseconds() - subqueryRange.Milliseconds()
+	start = interval * (earliestT / interval)
+	if start < earliestT {
+		start += interval
+	}
+
+	return types.NewRangeQueryTimeRange(timestamp.Time(start), timestamp.Time(end), step)
+}
+
+func (s *Subquery) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	return s.Inner.SeriesMetadata(ctx)
+}
+
+func (s *Subquery) StepCount() int {
+	return s.numSteps
+}
+
+func (s *Subquery) Range() time.Duration {
+	return s.SubqueryRange
+}
+
+func (s *Subquery) NextSeries(ctx context.Context) error {
+	s.Pool.PutInstantVectorSeriesData(s.data)
+	s.data = types.InstantVectorSeriesData{}
+
+	var err error
+	s.data, err = s.Inner.NextSeries(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.nextFloatIndex = 0
+	s.nextHistogramIndex = 0
+	s.nextStepT = s.parentStart
+
+	return nil
+}
+
+func (s *Subquery) NextStepSamples(floats *types.FPointRingBuffer, histograms *types.HPointRingBuffer) (types.RangeVectorStepData, error) {
+	if s.nextStepT > s.parentEnd {
+		return types.RangeVectorStepData{}, types.EOS
+	}
+
+	stepT := s.nextStepT
+	rangeEnd := stepT
+
+	if s.SubqueryTimestamp != nil {
+		rangeEnd = *s.SubqueryTimestamp
+	}
+
+	rangeEnd -= s.SubqueryOffset
+
+	rangeStart := rangeEnd - s.rangeMilliseconds
+	floats.DiscardPointsBefore(rangeStart)
+	histograms.DiscardPointsBefore(rangeStart)
+
+	if err := s.fillBuffer(floats, histograms, rangeStart, rangeEnd); err != nil {
+		return types.RangeVectorStepData{}, err
+	}
+
+	s.nextStepT += s.parentInterval
+
+	return types.RangeVectorStepData{
+		StepT:      stepT,
+		RangeStart: rangeStart,
+		RangeEnd:   rangeEnd,
+	}, nil
+}
+
+func (s *Subquery) fillBuffer(floats *types.FPointRingBuffer, histograms *types.HPointRingBuffer, rangeStart, rangeEnd int64) error {
+	for s.nextFloatIndex < len(s.data.Floats) && s.data.Floats[s.nextFloatIndex].T <= rangeEnd {
+		p := s.data.Floats[s.nextFloatIndex]
+		s.nextFloatIndex++
+
+		if p.T < rangeStart {
+			continue
+		}
+
+		if err := floats.Append(p); err != nil {
+			return err
+		}
+	}
+
+	for s.nextHistogramIndex < len(s.data.Histograms) && s.data.Histograms[s.nextHistogramIndex].T <= rangeEnd {
+		p := s.data.Histograms[s.nextHistogramIndex]
+		s.nextHistogramIndex++
+
+		if p.T < rangeStart {
+			continue
+		}
+
+		hPoint, err := histograms.NextPoint()
+		if err != nil {
+			return err
+		}
+
+		// Copy the histogram rather than sharing it: the same histogram may be used for multiple points from Inner (eg. if
+		// lookback has occurred), and histograms in the buffer may be reused when the buffer is returned to the pool.
+		hPoint.T = p.T
+		if hPoint.H == nil {
+			hPoint.H = p.H.Copy()
+		} else {
+			p.H.CopyTo(hPoint.H)
+		}
+	}
+
+	return nil
+}
+
+func (s *Subquery) Close() {
+	s.Inner.Close()
+
+	s.Pool.PutInstantVectorSeriesData(s.data)
+	s.data = types.InstantVectorSeriesData{}
+}
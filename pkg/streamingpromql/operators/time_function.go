@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/functions.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package operators
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// TimeFunction implements the time() function: its value at each time step is the timestamp of that step, in seconds.
+type TimeFunction struct {
+	Start    int64 // Milliseconds since Unix epoch
+	End      int64 // Milliseconds since Unix epoch
+	Interval int64 // In milliseconds
+	Pool     *pooling.LimitingPool
+}
+
+var _ types.ScalarOperator = &TimeFunction{}
+
+func NewTimeFunction(start time.Time, end time.Time, interval time.Duration, pool *pooling.LimitingPool) *TimeFunction {
+	return &TimeFunction{
+		Start:    timestamp.FromTime(start),
+		End:      timestamp.FromTime(end),
+		Interval: interval.Milliseconds(),
+		Pool:     pool,
+	}
+}
+
+func (f *TimeFunction) GetValues(_ context.Context) (types.ScalarData, error) {
+	samples, err := f.Pool.GetFPointSlice(stepCount(f.Start, f.End, f.Interval))
+	if err != nil {
+		return types.ScalarData{}, err
+	}
+
+	for t := f.Start; t <= f.End; t += f.Interval {
+		samples = append(samples, promql.FPoint{T: t, F: float64(t) / 1000})
+	}
+
+	return types.ScalarData{Samples: samples}, nil
+}
+
+func (f *TimeFunction) Close() {
+	// Nothing to do.
+}
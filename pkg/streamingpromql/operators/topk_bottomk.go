@@ -0,0 +1,512 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/engine.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package operators
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// TopKBottomK implements the topk and bottomk aggregations.
+//
+// Each output series is one of the input series, so the output series are known once the input series are known.
+// However, whether a point from an input series is included in the output can only be determined once all series
+// in its group have been seen. So TopKBottomK keeps a bounded heap of at most k points for each step of each
+// incomplete group, and returns the series of a group once the group is complete.
+//
+// Instant queries are handled differently: Prometheus' engine returns the series in each group sorted by value,
+// so TopKBottomK reads all input series in SeriesMetadata and returns only the selected series, in the expected order.
+type TopKBottomK struct {
+	Inner    types.InstantVectorOperator
+	Start    int64 // Milliseconds since Unix epoch
+	End      int64 // Milliseconds since Unix epoch
+	Interval int64 // In milliseconds
+	Steps    int
+	K        int
+	IsTopK   bool
+	Grouping []string // If this is a 'without' aggregation, NewTopKBottomK will ensure that this slice contains __name__.
+	Without  bool
+	Pool     *pooling.LimitingPool
+
+	heap topKBottomKHeap
+
+	// Used for range queries.
+	remainingInnerSeriesToGroup []*topKBottomKGroup // One entry per series produced by Inner, value is the group for that series
+	remainingOutputSeries       []topKBottomKOutputSeries
+	nextInnerSeriesIndex        int
+
+	// Used for instant queries.
+	instantQueryValues []float64 // One entry per output series
+}
+
+type topKBottomKOutputSeries struct {
+	innerSeriesIndex int
+	group            *topKBottomKGroup
+}
+
+var _ types.InstantVectorOperator = &TopKBottomK{}
+
+// convertibleToInt64 returns true if v can be converted to an int64 without overflowing.
+func convertibleToInt64(v float64) bool {
+	return v <= maxInt64 && v >= minInt64
+}
+
+const (
+	// The largest and smallest float64 values that can be converted to an int64.
+	maxInt64 = 9223372036854774784
+	minInt64 = -9223372036854775808
+)
+
+func NewTopKBottomK(
+	inner types.InstantVectorOperator,
+	k float64,
+	isTopK bool,
+	start time.Time,
+	end time.Time,
+	interval time.Duration,
+	grouping []string,
+	without bool,
+	pool *pooling.LimitingPool,
+) (*TopKBottomK, error) {
+	if !convertibleToInt64(k) {
+		return nil, fmt.Errorf("Scalar value %v overflows int64", k) //nolint:revive
+	}
+
+	s, e, i := timestamp.FromTime(start), timestamp.FromTime(end), interval.Milliseconds()
+
+	return &TopKBottomK{
+		Inner:    inner,
+		Start:    s,
+		End:      e,
+		Interval: i,
+		Steps:    stepCount(s, e, i),
+		K:        int(k),
+		IsTopK:   isTopK,
+		Grouping: sortedGroupingLabels(grouping, without),
+		Without:  without,
+		Pool:     pool,
+
+		heap: topKBottomKHeap{isTopK: isTopK},
+	}, nil
+}
+
+type topKBottomKGroup struct {
+	// The number of input series that belong to this group that we haven't yet seen.
+	remainingSeriesCount uint
+
+	// The number of output series that belong to this group that we haven't yet returned.
+	remainingOutputSeriesCount uint
+
+	// The index of the first and last series that contributes to this group.
+	// Used to sort groups in the order that they'll be completed in (range queries) or in the order
+	// Prometheus' engine returns them in (instant queries).
+	firstSeriesIndex int
+	lastSeriesIndex  int
+
+	// The maximum number of points to retain for each step: the lower of K and the number of series in this group.
+	k int
+
+	// Heaps for each step.
+	// The heap for step i is stored in values[i*k:i*k+heapSizes[i]] and seriesIndices[i*k:i*k+heapSizes[i]].
+	values        []float64
+	seriesIndices []int
+	heapSizes     []int
+
+	// Positions in values and seriesIndices, sorted by series index then by step.
+	// Populated once all series in this group have been seen.
+	sortedPositions []int
+}
+
+func (t *TopKBottomK) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	innerSeries, err := t.Inner.SeriesMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer pooling.PutSeriesMetadataSlice(innerSeries)
+
+	if len(innerSeries) == 0 || t.K < 1 {
+		// No input series or k < 1 == no output series.
+		return nil, nil
+	}
+
+	groups := map[string]*topKBottomKGroup{}
+	groupLabelsBytesFunc, _ := seriesToGroupFuncs(t.Grouping, t.Without)
+	t.remainingInnerSeriesToGroup = make([]*topKBottomKGroup, 0, len(innerSeries))
+	orderedGroups := make([]*topKBottomKGroup, 0)
+
+	for seriesIdx, series := range innerSeries {
+		groupLabelsString := groupLabelsBytesFunc(series.Labels)
+		g, groupExists := groups[string(groupLabelsString)] // Important: don't extract the string(...) call here - passing it directly allows us to avoid allocating it.
+
+		if !groupExists {
+			g = &topKBottomKGroup{firstSeriesIndex: seriesIdx}
+			groups[string(groupLabelsString)] = g
+			orderedGroups = append(orderedGroups, g)
+		}
+
+		g.remainingSeriesCount++
+		g.remainingOutputSeriesCount++
+		g.lastSeriesIndex = seriesIdx
+		t.remainingInnerSeriesToGroup = append(t.remainingInnerSeriesToGroup, g)
+	}
+
+	for _, g := range orderedGroups {
+		g.k = min(t.K, int(g.remainingSeriesCount))
+	}
+
+	if t.Steps == 1 {
+		return t.instantQuerySeriesMetadata(ctx, innerSeries, orderedGroups)
+	}
+
+	// Sort the list of series we'll return so that series from the group that will be completed first are returned first.
+	// Within a group, series are returned in the same order as Inner returns them.
+	groupOrder := make(map[*topKBottomKGroup]int, len(orderedGroups))
+	slices.SortStableFunc(orderedGroups, func(a, b *topKBottomKGroup) int {
+		return a.lastSeriesIndex - b.lastSeriesIndex
+	})
+	for i, g := range orderedGroups {
+		groupOrder[g] = i
+	}
+
+	t.remainingOutputSeries = make([]topKBottomKOutputSeries, 0, len(innerSeries))
+	for seriesIdx, g := range t.remainingInnerSeriesToGroup {
+		t.remainingOutputSeries = append(t.remainingOutputSeries, topKBottomKOutputSeries{innerSeriesIndex: seriesIdx, group: g})
+	}
+
+	slices.SortStableFunc(t.remainingOutputSeries, func(a, b topKBottomKOutputSeries) int {
+		return groupOrder[a.group] - groupOrder[b.group]
+	})
+
+	seriesMetadata := pooling.GetSeriesMetadataSlice(len(innerSeries))
+	for _, s := range t.remainingOutputSeries {
+		seriesMetadata = append(seriesMetadata, innerSeries[s.innerSeriesIndex])
+	}
+
+	return seriesMetadata, nil
+}
+
+func (t *TopKBottomK) instantQuerySeriesMetadata(ctx context.Context, innerSeries []types.SeriesMetadata, orderedGroups []*topKBottomKGroup) ([]types.SeriesMetadata, error) {
+	for _, g := range orderedGroups {
+		if err := t.accumulateUntilGroupComplete(ctx, g); err != nil {
+			return nil, err
+		}
+	}
+
+	outputSeriesCount := 0
+	for _, g := range orderedGroups {
+		outputSeriesCount += g.heapSizes[0]
+	}
+
+	seriesMetadata := pooling.GetSeriesMetadataSlice(outputSeriesCount)
+
+	var err error
+	t.instantQueryValues, err = t.Pool.GetFloatSlice(outputSeriesCount)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, g := range orderedGroups {
+		if g.heapSizes[0] == 0 {
+			t.returnGroupToPool(g)
+			continue
+		}
+
+		// The heap keeps the lowest (topk) or highest (bottomk) value on top, so reverse it.
+		t.heapForStep(g, 0)
+		sort.Sort(sort.Reverse(&t.heap))
+
+		for i := range t.heap.values {
+			seriesMetadata = append(seriesMetadata, innerSeries[t.heap.seriesIndices[i]])
+			t.instantQueryValues = append(t.instantQueryValues, t.heap.values[i])
+		}
+
+		t.returnGroupToPool(g)
+	}
+
+	return seriesMetadata, nil
+}
+
+func (t *TopKBottomK) NextSeries(ctx context.Context) (types.InstantVectorSeriesData, error) {
+	if t.Steps == 1 {
+		return t.nextInstantQuerySeries()
+	}
+
+	if len(t.remainingOutputSeries) == 0 {
+		return types.InstantVectorSeriesData{}, types.EOS
+	}
+
+	next := t.remainingOutputSeries[0]
+	t.remainingOutputSeries = t.remainingOutputSeries[1:]
+	g := next.group
+
+	if err := t.accumulateUntilGroupComplete(ctx, g); err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	if g.sortedPositions == nil {
+		if err := t.sortGroupPositions(g); err != nil {
+			return types.InstantVectorSeriesData{}, err
+		}
+	}
+
+	data, err := t.takePointsForSeries(g, next.innerSeriesIndex)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	g.remainingOutputSeriesCount--
+	if g.remainingOutputSeriesCount == 0 {
+		t.returnGroupToPool(g)
+	}
+
+	return data, nil
+}
+
+func (t *TopKBottomK) nextInstantQuerySeries() (types.InstantVectorSeriesData, error) {
+	if len(t.instantQueryValues) == 0 {
+		t.Pool.PutFloatSlice(t.instantQueryValues)
+		t.instantQueryValues = nil
+		return types.InstantVectorSeriesData{}, types.EOS
+	}
+
+	points, err := t.Pool.GetFPointSlice(1)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	points = append(points, promql.FPoint{T: t.Start, F: t.instantQueryValues[0]})
+	t.instantQueryValues = t.instantQueryValues[1:]
+
+	return types.InstantVectorSeriesData{Floats: points}, nil
+}
+
+func (t *TopKBottomK) accumulateUntilGroupComplete(ctx context.Context, g *topKBottomKGroup) error {
+	for g.remainingSeriesCount > 0 {
+		s, err := t.Inner.NextSeries(ctx)
+		if err != nil {
+			if errors.Is(err, types.EOS) {
+				return fmt.Errorf("exhausted series before all groups were completed: %w", err)
+			}
+
+			return err
+		}
+
+		thisSeriesGroup := t.remainingInnerSeriesToGroup[0]
+		t.remainingInnerSeriesToGroup = t.remainingInnerSeriesToGroup[1:]
+
+		if err := t.accumulateSeriesIntoGroup(s, t.nextInnerSeriesIndex, thisSeriesGroup); err != nil {
+			return err
+		}
+
+		t.Pool.PutInstantVectorSeriesData(s)
+		thisSeriesGroup.remainingSeriesCount--
+		t.nextInnerSeriesIndex++
+	}
+
+	return nil
+}
+
+func (t *TopKBottomK) accumulateSeriesIntoGroup(s types.InstantVectorSeriesData, seriesIndex int, g *topKBottomKGroup) error {
+	// Native histograms are ignored by topk and bottomk.
+	if len(s.Floats) == 0 {
+		return nil
+	}
+
+	if g.values == nil {
+		var err error
+		if g.values, err = t.Pool.GetFloatSlice(t.Steps * g.k); err != nil {
+			return err
+		}
+
+		if g.seriesIndices, err = t.Pool.GetIntSlice(t.Steps * g.k); err != nil {
+			return err
+		}
+
+		if g.heapSizes, err = t.Pool.GetIntSlice(t.Steps); err != nil {
+			return err
+		}
+
+		g.values = g.values[:t.Steps*g.k]
+		g.seriesIndices = g.seriesIndices[:t.Steps*g.k]
+		g.heapSizes = g.heapSizes[:t.Steps]
+	}
+
+	for _, p := range s.Floats {
+		idx := int((p.T - t.Start) / t.Interval)
+		t.heapForStep(g, idx)
+
+		if t.heap.Len() < g.k {
+			t.heap.values = append(t.heap.values, p.F)
+			t.heap.seriesIndices = append(t.heap.seriesIndices, seriesIndex)
+			heap.Fix(&t.heap, t.heap.Len()-1) // Maintain the heap invariant.
+			g.heapSizes[idx]++
+			continue
+		}
+
+		if t.shouldReplace(t.heap.values[0], p.F) {
+			t.heap.values[0] = p.F
+			t.heap.seriesIndices[0] = seriesIndex
+
+			if g.k > 1 {
+				heap.Fix(&t.heap, 0) // Maintain the heap invariant.
+			}
+		}
+	}
+
+	return nil
+}
+
+// shouldReplace returns true if the new value f should replace the value currently on top of the heap.
+func (t *TopKBottomK) shouldReplace(top, f float64) bool {
+	if math.IsNaN(top) && !math.IsNaN(f) {
+		return true
+	}
+
+	if t.IsTopK {
+		return top < f
+	}
+
+	return top > f
+}
+
+// heapForStep points t.heap at the heap for the given step of g.
+func (t *TopKBottomK) heapForStep(g *topKBottomKGroup, step int) {
+	start := step * g.k
+	end := start + g.heapSizes[step]
+	limit := start + g.k
+
+	t.heap.values = g.values[start:end:limit]
+	t.heap.seriesIndices = g.seriesIndices[start:end:limit]
+}
+
+// sortGroupPositions populates g.sortedPositions with the positions of all points retained for g,
+// sorted by series index and then by step.
+func (t *TopKBottomK) sortGroupPositions(g *topKBottomKGroup) error {
+	pointCount := 0
+	for _, size := range g.heapSizes {
+		pointCount += size
+	}
+
+	var err error
+	if g.sortedPositions, err = t.Pool.GetIntSlice(pointCount); err != nil {
+		return err
+	}
+
+	for step, size := range g.heapSizes {
+		for i := 0; i < size; i++ {
+			g.sortedPositions = append(g.sortedPositions, step*g.k+i)
+		}
+	}
+
+	slices.SortFunc(g.sortedPositions, func(a, b int) int {
+		if g.seriesIndices[a] != g.seriesIndices[b] {
+			return g.seriesIndices[a] - g.seriesIndices[b]
+		}
+
+		return a - b // Positions are ordered by step already.
+	})
+
+	return nil
+}
+
+// takePointsForSeries returns the points retained for the given series, and removes them from g.sortedPositions.
+//
+// Series must be requested in ascending order of seriesIndex.
+func (t *TopKBottomK) takePointsForSeries(g *topKBottomKGroup, seriesIndex int) (types.InstantVectorSeriesData, error) {
+	pointCount := 0
+	for pointCount < len(g.sortedPositions) && g.seriesIndices[g.sortedPositions[pointCount]] == seriesIndex {
+		pointCount++
+	}
+
+	if pointCount == 0 {
+		return types.InstantVectorSeriesData{}, nil
+	}
+
+	points, err := t.Pool.GetFPointSlice(pointCount)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	for _, pos := range g.sortedPositions[:pointCount] {
+		step := pos / g.k
+		points = append(points, promql.FPoint{T: t.Start + int64(step)*t.Interval, F: g.values[pos]})
+	}
+
+	g.sortedPositions = g.sortedPositions[pointCount:]
+
+	return types.InstantVectorSeriesData{Floats: points}, nil
+}
+
+func (t *TopKBottomK) returnGroupToPool(g *topKBottomKGroup) {
+	t.Pool.PutFloatSlice(g.values)
+	t.Pool.PutIntSlice(g.seriesIndices)
+	t.Pool.PutIntSlice(g.heapSizes)
+	t.Pool.PutIntSlice(g.sortedPositions)
+
+	g.values = nil
+	g.seriesIndices = nil
+	g.heapSizes = nil
+	g.sortedPositions = nil
+}
+
+func (t *TopKBottomK) Close() {
+	t.Inner.Close()
+}
+
+// topKBottomKHeap is a heap of values and the index of the series each value came from.
+//
+// For topk, the smallest value is at the top of the heap, and for bottomk, the largest value is at the top of the heap.
+// In both cases, NaN is considered to be smaller (topk) or larger (bottomk) than all other values.
+//
+// Elements are added by appending to values and seriesIndices and then calling heap.Fix, rather than
+// calling heap.Push, to avoid allocating.
+type topKBottomKHeap struct {
+	values        []float64
+	seriesIndices []int
+	isTopK        bool
+}
+
+func (h *topKBottomKHeap) Len() int {
+	return len(h.values)
+}
+
+func (h *topKBottomKHeap) Less(i, j int) bool {
+	if math.IsNaN(h.values[i]) {
+		return true
+	}
+
+	if h.isTopK {
+		return h.values[i] < h.values[j]
+	}
+
+	return h.values[i] > h.values[j]
+}
+
+func (h *topKBottomKHeap) Swap(i, j int) {
+	h.values[i], h.values[j] = h.values[j], h.values[i]
+	h.seriesIndices[i], h.seriesIndices[j] = h.seriesIndices[j], h.seriesIndices[i]
+}
+
+func (h *topKBottomKHeap) Push(_ any) {
+	panic("Push() should not be called on topKBottomKHeap, append to values and seriesIndices and call heap.Fix() instead")
+}
+
+func (h *topKBottomKHeap) Pop() any {
+	panic("Pop() should not be called on topKBottomKHeap")
+}
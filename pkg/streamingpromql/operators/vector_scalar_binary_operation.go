@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/promql/engine.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors
+
+package operators
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/compat"
+	"github.com/grafana/mimir/pkg/streamingpromql/functions"
+	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+// VectorScalarBinaryOperation represents a binary operation between an instant vector and a scalar such as "<expr> + 2" or "3 * <expr>".
+type VectorScalarBinaryOperation struct {
+	Scalar           types.ScalarOperator
+	Vector           types.InstantVectorOperator
+	ScalarIsLeftSide bool
+	Op               parser.ItemType
+	ReturnBool       bool
+	Start            int64 // Milliseconds since Unix epoch
+	Interval         int64 // In milliseconds
+	Pool             *pooling.LimitingPool
+
+	arithmeticFunc binaryOperationFunc
+	comparisonFunc binaryComparisonFunc
+	scalarData     types.ScalarData
+}
+
+var _ types.InstantVectorOperator = &VectorScalarBinaryOperation{}
+
+func NewVectorScalarBinaryOperation(
+	scalar types.ScalarOperator,
+	vector types.InstantVectorOperator,
+	scalarIsLeftSide bool,
+	op parser.ItemType,
+	returnBool bool,
+	start time.Time,
+	interval time.Duration,
+	pool *pooling.LimitingPool,
+) (*VectorScalarBinaryOperation, error) {
+	arithmeticFunc := arithmeticOperationFuncs[op]
+	comparisonFunc := comparisonOperationFuncs[op]
+
+	if arithmeticFunc == nil && comparisonFunc == nil {
+		return nil, compat.NewNotSupportedError(fmt.Sprintf("binary expression with '%s'", op))
+	}
+
+	return &VectorScalarBinaryOperation{
+		Scalar:           scalar,
+		Vector:           vector,
+		ScalarIsLeftSide: scalarIsLeftSide,
+		Op:               op,
+		ReturnBool:       returnBool,
+		Start:            timestamp.FromTime(start),
+		Interval:         interval.Milliseconds(),
+		Pool:             pool,
+
+		arithmeticFunc: arithmeticFunc,
+		comparisonFunc: comparisonFunc,
+	}, nil
+}
+
+func (v *VectorScalarBinaryOperation) SeriesMetadata(ctx context.Context) ([]types.SeriesMetadata, error) {
+	// Get the scalar values once, now, rather than having to do this later in NextSeries.
+	var err error
+	v.scalarData, err = v.Scalar.GetValues(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := v.Vector.SeriesMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Comparison operations without the bool modifier are filters, and so retain the metric name.
+	if v.arithmeticFunc != nil || v.ReturnBool {
+		return functions.DropSeriesName(metadata, v.Pool)
+	}
+
+	return metadata, nil
+}
+
+func (v *VectorScalarBinaryOperation) NextSeries(ctx context.Context) (types.InstantVectorSeriesData, error) {
+	series, err := v.Vector.NextSeries(ctx)
+	if err != nil {
+		return types.InstantVectorSeriesData{}, err
+	}
+
+	// We compute the result in place, reusing the slices from the vector side.
+	floats := series.Floats[:0]
+
+	for _, p := range series.Floats {
+		scalarValue := v.scalarValueAt(p.T)
+
+		if v.arithmeticFunc != nil {
+			if v.ScalarIsLeftSide {
+				p.F = v.arithmeticFunc(scalarValue, p.F)
+			} else {
+				p.F = v.arithmeticFunc(p.F, scalarValue)
+			}
+
+			floats = append(floats, p)
+			continue
+		}
+
+		var keep bool
+		if v.ScalarIsLeftSide {
+			keep = v.comparisonFunc(scalarValue, p.F)
+		} else {
+			keep = v.comparisonFunc(p.F, scalarValue)
+		}
+
+		if v.ReturnBool {
+			p.F = boolToFloat(keep)
+			keep = true
+		}
+
+		// Comparisons always return the value from the vector side, regardless of which side the scalar is on.
+		if keep {
+			floats = append(floats, p)
+		}
+	}
+
+	histograms := series.Histograms[:0]
+
+	for _, p := range series.Histograms {
+		h := v.computeHistogramResult(p.H, v.scalarValueAt(p.T))
+
+		if h != nil {
+			p.H = h
+			histograms = append(histograms, p)
+		}
+	}
+
+	series.Floats = floats
+	series.Histograms = histograms
+
+	return series, nil
+}
+
+func (v *VectorScalarBinaryOperation) scalarValueAt(t int64) float64 {
+	return v.scalarData.Samples[(t-v.Start)/v.Interval].F
+}
+
+// computeHistogramResult returns the result of applying this operation to h and the scalar value s,
+// or nil if the operation is not defined for native histograms.
+//
+// Only multiplication of a native histogram by a scalar and division of a native histogram by a scalar are supported.
+func (v *VectorScalarBinaryOperation) computeHistogramResult(h *histogram.FloatHistogram, s float64) *histogram.FloatHistogram {
+	// The histogram may be used by other points (eg. if lookback has occurred), so we must copy it before modifying it.
+	switch {
+	case v.Op == parser.MUL:
+		return h.Copy().Mul(s)
+	case v.Op == parser.DIV && !v.ScalarIsLeftSide:
+		return h.Copy().Div(s)
+	default:
+		return nil
+	}
+}
+
+func (v *VectorScalarBinaryOperation) Close() {
+	v.Scalar.Close()
+	v.Vector.Close()
+
+	if v.scalarData.Samples != nil {
+		v.Pool.PutFPointSlice(v.scalarData.Samples)
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
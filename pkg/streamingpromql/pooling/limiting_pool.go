@@ -3,6 +3,7 @@
 package pooling
 
 import (
+	"sync"
 	"unsafe"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -27,6 +28,7 @@ const (
 	VectorSampleSize     = uint64(unsafe.Sizeof(promql.Sample{})) // This assumes each sample is a float sample, not a histogram.
 	Float64Size          = uint64(unsafe.Sizeof(float64(0)))
 	BoolSize             = uint64(unsafe.Sizeof(false))
+	IntSize              = uint64(unsafe.Sizeof(int(0)))
 	HistogramPointerSize = uint64(unsafe.Sizeof((*histogram.FloatHistogram)(nil)))
 )
 
@@ -51,6 +53,10 @@ var (
 		return make([]bool, 0, size)
 	})
 
+	intSlicePool = pool.NewBucketedPool(1, maxExpectedPointsPerSeries, pointsPerSeriesBucketFactor, func(size int) []int {
+		return make([]int, 0, size)
+	})
+
 	histogramSlicePool = pool.NewBucketedPool(1, maxExpectedPointsPerSeries, pointsPerSeriesBucketFactor, func(size int) []*histogram.FloatHistogram {
 		return make([]*histogram.FloatHistogram, 0, size)
 	})
@@ -58,9 +64,10 @@ var (
 
 // LimitingPool manages sample slices for a single query evaluation, and applies any max in-memory bytes limit.
 //
-// It also tracks the peak number of in-memory bytes for use in query statistics.
+// It also tracks the peak number of in-memory bytes and samples for use in query statistics.
 //
-// It is not safe to use this type from multiple goroutines simultaneously.
+// It is safe to use this type from multiple goroutines simultaneously, so that independent parts of a query can be
+// evaluated concurrently. However, the exported fields must only be read once no other goroutine is using the pool.
 //
 // LimitingPool only estimates the in-memory size of the slices it returns. For example, it ignores the overhead of slice headers,
 // assumes all native histograms are the same size, and assumes all elements of a promql.Vector are float samples.
@@ -69,8 +76,19 @@ type LimitingPool struct {
 	CurrentEstimatedMemoryConsumptionBytes uint64
 	PeakEstimatedMemoryConsumptionBytes    uint64
 
+	// The number of samples that could be held in the sample slices currently in use, and the peak of this number.
+	// These are based on the capacity of the slices, so they may be greater than the number of samples actually held.
+	CurrentInMemorySamples uint64
+	PeakInMemorySamples    uint64
+
 	rejectionCount        prometheus.Counter
 	haveRecordedRejection bool
+
+	// The peak reached since the most recent call to BeginPeakMeasurement, used to measure the peak memory consumption
+	// of individual operators.
+	measuredPeakEstimatedMemoryConsumptionBytes uint64
+
+	mtx sync.Mutex
 }
 
 func NewLimitingPool(maxEstimatedMemoryConsumptionBytes uint64, rejectionCount prometheus.Counter) *LimitingPool {
@@ -81,7 +99,10 @@ func NewLimitingPool(maxEstimatedMemoryConsumptionBytes uint64, rejectionCount p
 	}
 }
 
-func getWithElementSize[E any, S ~[]E](p *LimitingPool, pool *pool.BucketedPool[S, E], size int, elementSize uint64) (S, error) {
+// getWithElementSize returns a slice from pool with capacity greater than or equal to size.
+//
+// samplesPerElement is the number of samples each element of the slice can hold, or 0 if the slice does not hold samples.
+func getWithElementSize[E any, S ~[]E](p *LimitingPool, pool *pool.BucketedPool[S, E], size int, elementSize uint64, samplesPerElement uint64) (S, error) {
 	// We don't bother checking the limit before we get the slice for a couple of reasons:
 	// - we prefer to enforce the limit based on the capacity of the returned slices, not the requested size, to more accurately capture the true memory utilisation
 	// - we expect that the vast majority of the time, the limit won't be hit, so the extra caution just slows things down
@@ -94,6 +115,9 @@ func getWithElementSize[E any, S ~[]E](p *LimitingPool, pool *pool.BucketedPool[
 	// - there's no guarantee the slice will have size 'size' when it's returned to us in putWithElementSize, so using 'size' would make the accounting below impossible
 	estimatedBytes := uint64(cap(s)) * elementSize
 
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
 	if p.MaxEstimatedMemoryConsumptionBytes > 0 && p.CurrentEstimatedMemoryConsumptionBytes+estimatedBytes > p.MaxEstimatedMemoryConsumptionBytes {
 		pool.Put(s)
 
@@ -107,31 +131,84 @@ func getWithElementSize[E any, S ~[]E](p *LimitingPool, pool *pool.BucketedPool[
 
 	p.CurrentEstimatedMemoryConsumptionBytes += estimatedBytes
 	p.PeakEstimatedMemoryConsumptionBytes = max(p.PeakEstimatedMemoryConsumptionBytes, p.CurrentEstimatedMemoryConsumptionBytes)
+	p.measuredPeakEstimatedMemoryConsumptionBytes = max(p.measuredPeakEstimatedMemoryConsumptionBytes, p.CurrentEstimatedMemoryConsumptionBytes)
+	p.CurrentInMemorySamples += uint64(cap(s)) * samplesPerElement
+	p.PeakInMemorySamples = max(p.PeakInMemorySamples, p.CurrentInMemorySamples)
 
 	return s, nil
 }
 
-func putWithElementSize[E any, S ~[]E](p *LimitingPool, pool *pool.BucketedPool[S, E], elementSize uint64, s S) {
+func putWithElementSize[E any, S ~[]E](p *LimitingPool, pool *pool.BucketedPool[S, E], elementSize uint64, samplesPerElement uint64, s S) {
 	if s == nil {
 		return
 	}
 
+	p.mtx.Lock()
 	p.CurrentEstimatedMemoryConsumptionBytes -= uint64(cap(s)) * elementSize
+	p.CurrentInMemorySamples -= uint64(cap(s)) * samplesPerElement
+	p.mtx.Unlock()
+
 	pool.Put(s)
 }
 
+// PeakMeasurement is an in-progress measurement of peak memory consumption started by BeginPeakMeasurement.
+type PeakMeasurement struct {
+	baseMemoryConsumption uint64
+	previousPeak          uint64
+}
+
+// BeginPeakMeasurement starts measuring the peak memory consumption reached until the corresponding call to
+// EndPeakMeasurement.
+//
+// Measurements can be nested. If measurements are made from multiple goroutines simultaneously, the peak
+// returned by EndPeakMeasurement is approximate, as it may include memory consumed by other goroutines.
+//
+// Measurements do not affect PeakEstimatedMemoryConsumptionBytes.
+func (p *LimitingPool) BeginPeakMeasurement() PeakMeasurement {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	m := PeakMeasurement{
+		baseMemoryConsumption: p.CurrentEstimatedMemoryConsumptionBytes,
+		previousPeak:          p.measuredPeakEstimatedMemoryConsumptionBytes,
+	}
+
+	p.measuredPeakEstimatedMemoryConsumptionBytes = p.CurrentEstimatedMemoryConsumptionBytes
+
+	return m
+}
+
+// EndPeakMeasurement ends the measurement m, and returns the peak memory consumption reached since m began,
+// relative to the memory consumption when m began.
+func (p *LimitingPool) EndPeakMeasurement(m PeakMeasurement) uint64 {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	peak := p.measuredPeakEstimatedMemoryConsumptionBytes
+
+	// Restore the peak for any enclosing measurement.
+	p.measuredPeakEstimatedMemoryConsumptionBytes = max(m.previousPeak, peak)
+
+	if peak < m.baseMemoryConsumption {
+		// Another goroutine began a measurement after memory was released since m began.
+		return 0
+	}
+
+	return peak - m.baseMemoryConsumption
+}
+
 // GetFPointSlice returns a slice of promql.FPoint of length 0 and capacity greater than or equal to size.
 //
 // If the capacity of the returned slice would cause the max memory consumption limit to be exceeded, then an error is returned.
 //
 // Note that the capacity of the returned slice may be significantly larger than size, depending on the configuration of the underlying bucketed pool.
 func (p *LimitingPool) GetFPointSlice(size int) ([]promql.FPoint, error) {
-	return getWithElementSize(p, fPointSlicePool, size, FPointSize)
+	return getWithElementSize(p, fPointSlicePool, size, FPointSize, 1)
 }
 
 // PutFPointSlice returns a slice of promql.FPoint to the pool and updates the current number of in-memory samples.
 func (p *LimitingPool) PutFPointSlice(s []promql.FPoint) {
-	putWithElementSize(p, fPointSlicePool, FPointSize, s)
+	putWithElementSize(p, fPointSlicePool, FPointSize, 1, s)
 }
 
 // GetHPointSlice returns a slice of promql.HPoint of length 0 and capacity greater than or equal to size.
@@ -140,12 +217,12 @@ func (p *LimitingPool) PutFPointSlice(s []promql.FPoint) {
 //
 // Note that the capacity of the returned slice may be significantly larger than size, depending on the configuration of the underlying bucketed pool.
 func (p *LimitingPool) GetHPointSlice(size int) ([]promql.HPoint, error) {
-	return getWithElementSize(p, hPointSlicePool, size, HPointSize)
+	return getWithElementSize(p, hPointSlicePool, size, HPointSize, 1)
 }
 
 // PutHPointSlice returns a slice of promql.HPoint to the pool and updates the current number of in-memory samples.
 func (p *LimitingPool) PutHPointSlice(s []promql.HPoint) {
-	putWithElementSize(p, hPointSlicePool, HPointSize, s)
+	putWithElementSize(p, hPointSlicePool, HPointSize, 1, s)
 }
 
 // GetVector returns a promql.Vector of length 0 and capacity greater than or equal to size.
@@ -154,12 +231,12 @@ func (p *LimitingPool) PutHPointSlice(s []promql.HPoint) {
 //
 // Note that the capacity of the returned vector may be significantly larger than size, depending on the configuration of the underlying bucketed pool.
 func (p *LimitingPool) GetVector(size int) (promql.Vector, error) {
-	return getWithElementSize(p, vectorPool, size, VectorSampleSize)
+	return getWithElementSize(p, vectorPool, size, VectorSampleSize, 1)
 }
 
 // PutVector returns a promql.Vector to the pool and updates the current number of in-memory samples.
 func (p *LimitingPool) PutVector(v promql.Vector) {
-	putWithElementSize(p, vectorPool, VectorSampleSize, v)
+	putWithElementSize(p, vectorPool, VectorSampleSize, 1, v)
 }
 
 // GetFloatSlice returns a slice of float64 of length 0 and capacity greater than or equal to size.
@@ -170,7 +247,7 @@ func (p *LimitingPool) PutVector(v promql.Vector) {
 //
 // Note that the capacity of the returned slice may be significantly larger than size, depending on the configuration of the underlying bucketed pool.
 func (p *LimitingPool) GetFloatSlice(size int) ([]float64, error) {
-	s, err := getWithElementSize(p, float64SlicePool, size, Float64Size)
+	s, err := getWithElementSize(p, float64SlicePool, size, Float64Size, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -184,7 +261,7 @@ func (p *LimitingPool) GetFloatSlice(size int) ([]float64, error) {
 
 // PutFloatSlice returns a slice of float64 to the pool and updates the current number of in-memory samples.
 func (p *LimitingPool) PutFloatSlice(s []float64) {
-	putWithElementSize(p, float64SlicePool, Float64Size, s)
+	putWithElementSize(p, float64SlicePool, Float64Size, 0, s)
 }
 
 // GetBoolSlice returns a slice of bool of length 0 and capacity greater than or equal to size.
@@ -195,7 +272,7 @@ func (p *LimitingPool) PutFloatSlice(s []float64) {
 //
 // Note that the capacity of the returned slice may be significantly larger than size, depending on the configuration of the underlying bucketed pool.
 func (p *LimitingPool) GetBoolSlice(size int) ([]bool, error) {
-	s, err := getWithElementSize(p, boolSlicePool, size, BoolSize)
+	s, err := getWithElementSize(p, boolSlicePool, size, BoolSize, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -209,7 +286,32 @@ func (p *LimitingPool) GetBoolSlice(size int) ([]bool, error) {
 
 // PutBoolSlice returns a slice of bool to the pool and updates the current number of in-memory samples.
 func (p *LimitingPool) PutBoolSlice(s []bool) {
-	putWithElementSize(p, boolSlicePool, BoolSize, s)
+	putWithElementSize(p, boolSlicePool, BoolSize, 0, s)
+}
+
+// GetIntSlice returns a slice of int of length 0 and capacity greater than or equal to size.
+//
+// If the capacity of the returned slice would cause the max memory consumption limit to be exceeded, then an error is returned.
+//
+// Every element of the returned slice up to the requested size will have value 0.
+//
+// Note that the capacity of the returned slice may be significantly larger than size, depending on the configuration of the underlying bucketed pool.
+func (p *LimitingPool) GetIntSlice(size int) ([]int, error) {
+	s, err := getWithElementSize(p, intSlicePool, size, IntSize, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// This is not necessary if we've just created a new slice, it'll already have all elements reset.
+	// But we do it unconditionally for simplicity.
+	clear(s[:size])
+
+	return s, nil
+}
+
+// PutIntSlice returns a slice of int to the pool and updates the current number of in-memory samples.
+func (p *LimitingPool) PutIntSlice(s []int) {
+	putWithElementSize(p, intSlicePool, IntSize, 0, s)
 }
 
 // GetHistogramPointerSlice returns a slice of FloatHistogram of length 0 and capacity greater than or equal to size.
@@ -220,7 +322,7 @@ func (p *LimitingPool) PutBoolSlice(s []bool) {
 //
 // Note that the capacity of the returned slice may be significantly larger than size, depending on the configuration of the underlying bucketed pool.
 func (p *LimitingPool) GetHistogramPointerSlice(size int) ([]*histogram.FloatHistogram, error) {
-	s, err := getWithElementSize(p, histogramSlicePool, size, HistogramPointerSize)
+	s, err := getWithElementSize(p, histogramSlicePool, size, HistogramPointerSize, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -234,7 +336,7 @@ func (p *LimitingPool) GetHistogramPointerSlice(size int) ([]*histogram.FloatHis
 
 // PutHistogramPointerSlice returns a slice of FloatHistogram to the pool and updates the current number of in-memory samples.
 func (p *LimitingPool) PutHistogramPointerSlice(s []*histogram.FloatHistogram) {
-	putWithElementSize(p, histogramSlicePool, HistogramPointerSize, s)
+	putWithElementSize(p, histogramSlicePool, HistogramPointerSize, 0, s)
 }
 
 // PutInstantVectorSeriesData is equivalent to calling PutFPointSlice(d.Floats) and PutHPointSlice(d.Histograms).
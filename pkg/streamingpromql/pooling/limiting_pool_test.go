@@ -5,6 +5,7 @@ package pooling
 import (
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -47,6 +48,12 @@ func TestLimitingPool_Unlimited(t *testing.T) {
 		testUnlimitedPool(t, pool.GetBoolSlice, pool.PutBoolSlice, pool, BoolSize, reg)
 	})
 
+	t.Run("[]int", func(t *testing.T) {
+		reg, metric := createRejectedMetric()
+		pool := NewLimitingPool(0, metric)
+		testUnlimitedPool(t, pool.GetIntSlice, pool.PutIntSlice, pool, IntSize, reg)
+	})
+
 	t.Run("[]*histogram.FloatHistogram", func(t *testing.T) {
 		reg, metric := createRejectedMetric()
 		pool := NewLimitingPool(0, metric)
@@ -127,6 +134,12 @@ func TestLimitingPool_Limited(t *testing.T) {
 		testLimitedPool(t, pool.GetBoolSlice, pool.PutBoolSlice, pool, BoolSize, reg)
 	})
 
+	t.Run("[]int", func(t *testing.T) {
+		reg, metric := createRejectedMetric()
+		pool := NewLimitingPool(11*IntSize, metric)
+		testLimitedPool(t, pool.GetIntSlice, pool.PutIntSlice, pool, IntSize, reg)
+	})
+
 	t.Run("[]*histogram.FloatHistogram", func(t *testing.T) {
 		reg, metric := createRejectedMetric()
 		pool := NewLimitingPool(11*HistogramPointerSize, metric)
@@ -237,6 +250,21 @@ func TestLimitingPool_ClearsReturnedSlices(t *testing.T) {
 		require.Equal(t, []bool{false, false}, boolSlice)
 	})
 
+	t.Run("[]int", func(t *testing.T) {
+		intSlice, err := pool.GetIntSlice(2)
+		require.NoError(t, err)
+		intSlice = intSlice[:2]
+		intSlice[0] = 123
+		intSlice[1] = 456
+
+		pool.PutIntSlice(intSlice)
+
+		intSlice, err = pool.GetIntSlice(2)
+		require.NoError(t, err)
+		intSlice = intSlice[:2]
+		require.Equal(t, []int{0, 0}, intSlice)
+	})
+
 	t.Run("[]*histogram.FloatHistogram", func(t *testing.T) {
 		HistogramPointerSlice, err := pool.GetHistogramPointerSlice(2)
 		require.NoError(t, err)
@@ -253,6 +281,92 @@ func TestLimitingPool_ClearsReturnedSlices(t *testing.T) {
 	})
 }
 
+func TestLimitingPool_PeakMeasurement(t *testing.T) {
+	pool := NewLimitingPool(0, nil)
+
+	s10, err := pool.GetFPointSlice(10) // Capacity 16.
+	require.NoError(t, err)
+
+	outer := pool.BeginPeakMeasurement()
+
+	s100, err := pool.GetFPointSlice(100) // Capacity 128.
+	require.NoError(t, err)
+	pool.PutFPointSlice(s100)
+
+	inner := pool.BeginPeakMeasurement()
+
+	s2, err := pool.GetFPointSlice(2) // Capacity 2.
+	require.NoError(t, err)
+
+	require.Equal(t, 2*FPointSize, pool.EndPeakMeasurement(inner), "inner measurement should only include memory consumed since it began")
+	require.Equal(t, 128*FPointSize, pool.EndPeakMeasurement(outer), "outer measurement should include memory consumed before and during inner measurement")
+
+	// Measurements should not affect the peak for the query as a whole.
+	require.Equal(t, 144*FPointSize, pool.PeakEstimatedMemoryConsumptionBytes)
+
+	pool.PutFPointSlice(s10)
+	pool.PutFPointSlice(s2)
+	require.Zero(t, pool.CurrentEstimatedMemoryConsumptionBytes)
+}
+
+func TestLimitingPool_InMemorySamples(t *testing.T) {
+	pool := NewLimitingPool(0, nil)
+
+	floats, err := pool.GetFPointSlice(10) // Capacity 16.
+	require.NoError(t, err)
+	histograms, err := pool.GetHPointSlice(2) // Capacity 2.
+	require.NoError(t, err)
+	require.Equal(t, uint64(18), pool.CurrentInMemorySamples)
+
+	// Slices that don't hold samples should not be counted.
+	bools, err := pool.GetBoolSlice(100)
+	require.NoError(t, err)
+	require.Equal(t, uint64(18), pool.CurrentInMemorySamples)
+
+	pool.PutFPointSlice(floats)
+	require.Equal(t, uint64(2), pool.CurrentInMemorySamples)
+
+	vector, err := pool.GetVector(4) // Capacity 4.
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), pool.CurrentInMemorySamples)
+	require.Equal(t, uint64(18), pool.PeakInMemorySamples)
+
+	pool.PutHPointSlice(histograms)
+	pool.PutBoolSlice(bools)
+	pool.PutVector(vector)
+	require.Zero(t, pool.CurrentInMemorySamples)
+	require.Equal(t, uint64(18), pool.PeakInMemorySamples)
+}
+
+func TestLimitingPool_ConcurrentUse(t *testing.T) {
+	pool := NewLimitingPool(0, nil)
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				m := pool.BeginPeakMeasurement()
+
+				s, err := pool.GetFPointSlice(8)
+				require.NoError(t, err)
+				pool.PutFPointSlice(s)
+
+				pool.EndPeakMeasurement(m)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	require.Zero(t, pool.CurrentEstimatedMemoryConsumptionBytes)
+	require.GreaterOrEqual(t, pool.PeakEstimatedMemoryConsumptionBytes, 8*FPointSize)
+	require.LessOrEqual(t, pool.PeakEstimatedMemoryConsumptionBytes, 80*FPointSize)
+}
+
 func createRejectedMetric() (*prometheus.Registry, prometheus.Counter) {
 	reg := prometheus.NewPedanticRegistry()
 	metric := promauto.With(reg).NewCounter(prometheus.CounterOpts{
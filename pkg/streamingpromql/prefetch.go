@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package streamingpromql
+
+import (
+	"errors"
+
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/mimir/pkg/streamingpromql/operators"
+	"github.com/grafana/mimir/pkg/streamingpromql/types"
+)
+
+var errFoundSelector = errors.New("found selector")
+
+// newPrefetchGroup returns a group for prefetching the sibling expressions exprs concurrently, or nil if prefetching
+// is disabled for this query or fewer than two of exprs would benefit from prefetching.
+func (q *Query) newPrefetchGroup(exprs ...parser.Expr) *operators.PrefetchGroup {
+	if q.prefetchLimiter == nil {
+		return nil
+	}
+
+	prefetchable := 0
+	for _, e := range exprs {
+		if isPrefetchable(e) {
+			prefetchable++
+		}
+	}
+
+	if prefetchable < 2 {
+		return nil
+	}
+
+	return operators.NewPrefetchGroup(q.prefetchLimiter)
+}
+
+// prefetchInstantVectorOperator returns an operator that prefetches the results of o as part of group, or o if
+// group is nil or expr would not benefit from prefetching.
+func (q *Query) prefetchInstantVectorOperator(group *operators.PrefetchGroup, expr parser.Expr, o types.InstantVectorOperator) types.InstantVectorOperator {
+	if group == nil || !isPrefetchable(expr) {
+		return o
+	}
+
+	return group.AddInstantVectorOperator(o, q.pool)
+}
+
+// prefetchScalarOperator returns an operator that prefetches the results of o as part of group, or o if
+// group is nil or expr would not benefit from prefetching.
+func (q *Query) prefetchScalarOperator(group *operators.PrefetchGroup, expr parser.Expr, o types.ScalarOperator) types.ScalarOperator {
+	if group == nil || !isPrefetchable(expr) {
+		return o
+	}
+
+	return group.AddScalarOperator(o, q.pool)
+}
+
+// isPrefetchable returns true if expr produces an instant vector or scalar and selects series.
+//
+// Prefetching only reduces query latency if the expression selects series: other expressions can be evaluated
+// without waiting for any data to be fetched.
+func isPrefetchable(expr parser.Expr) bool {
+	if expr.Type() != parser.ValueTypeVector && expr.Type() != parser.ValueTypeScalar {
+		return false
+	}
+
+	err := parser.Walk(selectorFinder{}, expr, nil)
+	return errors.Is(err, errFoundSelector)
+}
+
+type selectorFinder struct{}
+
+func (f selectorFinder) Visit(node parser.Node, _ []parser.Node) (parser.Visitor, error) {
+	switch node.(type) {
+	case *parser.VectorSelector, *parser.MatrixSelector:
+		return nil, errFoundSelector
+	default:
+		return f, nil
+	}
+}
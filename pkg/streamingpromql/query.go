@@ -21,7 +21,10 @@ import (
 	"github.com/prometheus/prometheus/util/stats"
 	"golang.org/x/exp/slices"
 
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/streamingpromql/aggregations"
 	"github.com/grafana/mimir/pkg/streamingpromql/compat"
+	"github.com/grafana/mimir/pkg/streamingpromql/functions"
 	"github.com/grafana/mimir/pkg/streamingpromql/operators"
 	"github.com/grafana/mimir/pkg/streamingpromql/pooling"
 	"github.com/grafana/mimir/pkg/streamingpromql/types"
@@ -36,11 +39,20 @@ type Query struct {
 	queryable storage.Queryable
 	opts      promql.QueryOpts
 	statement *parser.EvalStmt
+	timeRange types.QueryTimeRange
 	root      types.Operator
 	engine    *Engine
 	qs        string
 	cancel    context.CancelCauseFunc
 	pool      *pooling.LimitingPool
+	stats     *types.QueryStats
+
+	// Instant vector expressions that appear more than once in the query, and the buffers used to share their results.
+	commonSubexpressions            map[parser.Expr]struct{}
+	instantVectorDuplicationBuffers map[subexpressionKey]*operators.InstantVectorDuplicationBuffer
+
+	// Limits the number of subexpressions prefetched concurrently, or nil if prefetching is disabled.
+	prefetchLimiter *operators.PrefetchLimiter
 
 	result *promql.Result
 }
@@ -55,6 +67,11 @@ func newQuery(ctx context.Context, queryable storage.Queryable, opts promql.Quer
 		return nil, err
 	}
 
+	maxConcurrentPrefetches, err := engine.limitsProvider.GetMaxConcurrentSubtreePrefetchesPerQuery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	expr, err := parser.ParseExpr(qs)
 	if err != nil {
 		return nil, err
@@ -68,6 +85,11 @@ func newQuery(ctx context.Context, queryable storage.Queryable, opts promql.Quer
 		engine:    engine,
 		qs:        qs,
 		pool:      pooling.NewLimitingPool(maxInMemorySamples, engine.queriesRejectedDueToPeakMemoryConsumption),
+		stats:     &types.QueryStats{},
+
+		commonSubexpressions:            findCommonSubexpressions(expr),
+		instantVectorDuplicationBuffers: map[subexpressionKey]*operators.InstantVectorDuplicationBuffer{},
+
 		statement: &parser.EvalStmt{
 			Expr:          expr,
 			Start:         start,
@@ -77,12 +99,21 @@ func newQuery(ctx context.Context, queryable storage.Queryable, opts promql.Quer
 		},
 	}
 
+	if maxConcurrentPrefetches > 0 {
+		q.prefetchLimiter = operators.NewPrefetchLimiter(maxConcurrentPrefetches)
+	}
+
 	if !q.IsInstant() {
 		if expr.Type() != parser.ValueTypeVector && expr.Type() != parser.ValueTypeScalar {
 			return nil, fmt.Errorf("query expression produces a %s, but expression for range queries must produce an instant vector or scalar", parser.DocumentedType(expr.Type()))
 		}
 	}
-	q.root, err = q.convertToOperator(expr)
+	q.timeRange = types.NewRangeQueryTimeRange(start, end, interval)
+	if q.IsInstant() {
+		q.timeRange = types.NewInstantQueryTimeRange(start)
+	}
+
+	q.root, err = q.convertToOperator(expr, q.timeRange)
 	if err != nil {
 		return nil, err
 	}
@@ -90,26 +121,52 @@ func newQuery(ctx context.Context, queryable storage.Queryable, opts promql.Quer
 	return q, nil
 }
 
-func (q *Query) convertToOperator(expr parser.Expr) (types.Operator, error) {
+func (q *Query) convertToOperator(expr parser.Expr, timeRange types.QueryTimeRange) (types.Operator, error) {
 	switch expr.Type() {
 	case parser.ValueTypeMatrix:
-		return q.convertToRangeVectorOperator(expr)
+		return q.convertToRangeVectorOperator(expr, timeRange)
 	case parser.ValueTypeVector:
-		return q.convertToInstantVectorOperator(expr)
+		return q.convertToInstantVectorOperator(expr, timeRange)
+	case parser.ValueTypeScalar:
+		return q.convertToScalarOperator(expr, timeRange)
 	default:
 		return nil, compat.NewNotSupportedError(fmt.Sprintf("%s value as top-level expression", parser.DocumentedType(expr.Type())))
 	}
 }
 
-func (q *Query) convertToInstantVectorOperator(expr parser.Expr) (types.InstantVectorOperator, error) {
-	if expr.Type() != parser.ValueTypeVector {
-		return nil, fmt.Errorf("cannot create instant vector operator for expression that produces a %s", parser.DocumentedType(expr.Type()))
+func (q *Query) convertToInstantVectorOperator(expr parser.Expr, timeRange types.QueryTimeRange) (types.InstantVectorOperator, error) {
+	if _, isCommonSubexpression := q.commonSubexpressions[expr]; !isCommonSubexpression {
+		return q.convertToInstrumentedInstantVectorOperator(expr, timeRange)
 	}
 
-	interval := q.statement.Interval
+	key := newSubexpressionKey(expr, timeRange)
+	buffer, exists := q.instantVectorDuplicationBuffers[key]
 
-	if q.IsInstant() {
-		interval = time.Millisecond
+	if !exists {
+		inner, err := q.convertToInstrumentedInstantVectorOperator(expr, timeRange)
+		if err != nil {
+			return nil, err
+		}
+
+		buffer = operators.NewInstantVectorDuplicationBuffer(inner, q.pool)
+		q.instantVectorDuplicationBuffers[key] = buffer
+	}
+
+	return operators.InstrumentInstantVectorOperator(buffer.AddConsumer(), q.pool), nil
+}
+
+func (q *Query) convertToInstrumentedInstantVectorOperator(expr parser.Expr, timeRange types.QueryTimeRange) (types.InstantVectorOperator, error) {
+	o, err := q.convertToUninstrumentedInstantVectorOperator(expr, timeRange)
+	if err != nil {
+		return nil, err
+	}
+
+	return operators.InstrumentInstantVectorOperator(o, q.pool), nil
+}
+
+func (q *Query) convertToUninstrumentedInstantVectorOperator(expr parser.Expr, timeRange types.QueryTimeRange) (types.InstantVectorOperator, error) {
+	if expr.Type() != parser.ValueTypeVector {
+		return nil, fmt.Errorf("cannot create instant vector operator for expression that produces a %s", parser.DocumentedType(expr.Type()))
 	}
 
 	switch e := expr.(type) {
@@ -119,143 +176,442 @@ func (q *Query) convertToInstantVectorOperator(expr parser.Expr) (types.InstantV
 			lookbackDelta = q.engine.lookbackDelta
 		}
 
-		if e.OriginalOffset != 0 || e.Offset != 0 {
-			return nil, compat.NewNotSupportedError("instant vector selector with 'offset'")
-		}
-
 		return &operators.InstantVectorSelector{
 			Pool: q.pool,
 			Selector: &operators.Selector{
 				Queryable:     q.queryable,
-				Start:         timestamp.FromTime(q.statement.Start),
-				End:           timestamp.FromTime(q.statement.End),
+				Start:         timestamp.FromTime(timeRange.Start),
+				End:           timestamp.FromTime(timeRange.End),
 				Timestamp:     e.Timestamp,
-				Interval:      interval.Milliseconds(),
+				Offset:        e.OriginalOffset.Milliseconds(),
+				Interval:      timeRange.Interval.Milliseconds(),
 				LookbackDelta: lookbackDelta,
 				Matchers:      e.LabelMatchers,
+				Stats:         q.stats,
 			},
 		}, nil
 	case *parser.AggregateExpr:
-		if e.Op != parser.SUM {
-			return nil, compat.NewNotSupportedError(fmt.Sprintf("'%s' aggregation", e.Op))
+		return q.convertAggregateExprToOperator(e, timeRange)
+	case *parser.Call:
+		return q.convertFunctionCallToOperator(e, timeRange)
+	case *parser.BinaryExpr:
+		if e.LHS.Type() == parser.ValueTypeScalar || e.RHS.Type() == parser.ValueTypeScalar {
+			return q.convertVectorScalarBinaryExprToOperator(e, timeRange)
 		}
 
-		if e.Param != nil {
-			// Should be caught by the PromQL parser, but we check here for safety.
-			return nil, fmt.Errorf("unexpected parameter for %s aggregation: %s", e.Op, e.Param)
+		lhs, err := q.convertToInstantVectorOperator(e.LHS, timeRange)
+		if err != nil {
+			return nil, err
 		}
 
-		inner, err := q.convertToInstantVectorOperator(e.Expr)
+		rhs, err := q.convertToInstantVectorOperator(e.RHS, timeRange)
 		if err != nil {
 			return nil, err
 		}
 
-		return operators.NewAggregation(
-			inner,
-			q.statement.Start,
-			q.statement.End,
-			interval,
-			e.Grouping,
-			e.Without,
-			q.pool,
-		), nil
-	case *parser.Call:
-		return q.convertFunctionCallToOperator(e)
-	case *parser.BinaryExpr:
-		if e.LHS.Type() != parser.ValueTypeVector || e.RHS.Type() != parser.ValueTypeVector {
-			return nil, compat.NewNotSupportedError("binary expression with scalars")
+		prefetchGroup := q.newPrefetchGroup(e.LHS, e.RHS)
+		lhs = q.prefetchInstantVectorOperator(prefetchGroup, e.LHS, lhs)
+		rhs = q.prefetchInstantVectorOperator(prefetchGroup, e.RHS, rhs)
+
+		switch e.Op {
+		case parser.LAND, parser.LUNLESS:
+			return operators.NewAndUnlessBinaryOperation(lhs, rhs, *e.VectorMatching, e.Op == parser.LUNLESS, timeRange.Start, timeRange.End, timeRange.Interval, q.pool), nil
+		case parser.LOR:
+			return operators.NewOrBinaryOperation(lhs, rhs, *e.VectorMatching, timeRange.Start, timeRange.End, timeRange.Interval, q.pool), nil
 		}
 
-		if e.VectorMatching.Card != parser.CardOneToOne {
+		switch e.VectorMatching.Card {
+		case parser.CardOneToOne:
+			return operators.NewBinaryOperation(lhs, rhs, *e.VectorMatching, e.Op, e.ReturnBool, q.pool)
+		case parser.CardManyToOne, parser.CardOneToMany:
+			return operators.NewGroupedBinaryOperation(lhs, rhs, *e.VectorMatching, e.Op, e.ReturnBool, timeRange.Start, timeRange.End, timeRange.Interval, q.pool)
+		default:
 			return nil, compat.NewNotSupportedError(fmt.Sprintf("binary expression with %v matching", e.VectorMatching.Card))
 		}
-
-		lhs, err := q.convertToInstantVectorOperator(e.LHS)
-		if err != nil {
-			return nil, err
+	case *parser.StepInvariantExpr:
+		if timeRange.Start.Equal(timeRange.End) {
+			// There's only one time step, so there's nothing to gain from evaluating the expression once.
+			return q.convertToInstantVectorOperator(e.Expr, timeRange)
 		}
 
-		rhs, err := q.convertToInstantVectorOperator(e.RHS)
+		inner, err := q.convertToInstantVectorOperator(e.Expr, types.NewInstantQueryTimeRange(timeRange.Start))
 		if err != nil {
 			return nil, err
 		}
 
-		return operators.NewBinaryOperation(lhs, rhs, *e.VectorMatching, e.Op, q.pool)
-	case *parser.StepInvariantExpr:
-		// One day, we'll do something smarter here.
-		return q.convertToInstantVectorOperator(e.Expr)
+		return operators.NewStepInvariantInstantVectorOperator(inner, timeRange.Start, timeRange.End, timeRange.Interval, q.pool), nil
 	case *parser.ParenExpr:
-		return q.convertToInstantVectorOperator(e.Expr)
+		return q.convertToInstantVectorOperator(e.Expr, timeRange)
 	default:
 		return nil, compat.NewNotSupportedError(fmt.Sprintf("PromQL expression type %T", e))
 	}
 }
 
-func (q *Query) convertFunctionCallToOperator(e *parser.Call) (types.InstantVectorOperator, error) {
+func (q *Query) convertVectorScalarBinaryExprToOperator(e *parser.BinaryExpr, timeRange types.QueryTimeRange) (types.InstantVectorOperator, error) {
+	scalarIsLeftSide := e.LHS.Type() == parser.ValueTypeScalar
+	scalarExpr, vectorExpr := e.LHS, e.RHS
+
+	if !scalarIsLeftSide {
+		scalarExpr, vectorExpr = e.RHS, e.LHS
+	}
+
+	scalar, err := q.convertToScalarOperator(scalarExpr, timeRange)
+	if err != nil {
+		return nil, err
+	}
+
+	vector, err := q.convertToInstantVectorOperator(vectorExpr, timeRange)
+	if err != nil {
+		return nil, err
+	}
+
+	prefetchGroup := q.newPrefetchGroup(scalarExpr, vectorExpr)
+	scalar = q.prefetchScalarOperator(prefetchGroup, scalarExpr, scalar)
+	vector = q.prefetchInstantVectorOperator(prefetchGroup, vectorExpr, vector)
+
+	o, err := operators.NewVectorScalarBinaryOperation(scalar, vector, scalarIsLeftSide, e.Op, e.ReturnBool, timeRange.Start, timeRange.Interval, q.pool)
+	if err != nil {
+		return nil, err
+	}
+
+	// Comparison operations without the bool modifier are filters and retain the metric name, but other operations
+	// drop it, so series with different names but otherwise identical labels would produce series with the same labels.
+	if !e.Op.IsComparisonOperator() || e.ReturnBool {
+		return operators.NewDeduplicateAndMergeSeries(o, q.pool), nil
+	}
+
+	return o, nil
+}
+
+func (q *Query) convertAggregateExprToOperator(e *parser.AggregateExpr, timeRange types.QueryTimeRange) (types.InstantVectorOperator, error) {
+	switch e.Op {
+	case parser.TOPK, parser.BOTTOMK, parser.LIMITK, parser.LIMIT_RATIO, parser.QUANTILE, parser.COUNT_VALUES:
+		// Handled below.
+	default:
+		if _, ok := aggregations.AggregationGroupFactories[e.Op]; !ok {
+			return nil, compat.NewNotSupportedError(fmt.Sprintf("'%s' aggregation", e.Op))
+		}
+
+		if e.Param != nil {
+			// Should be caught by the PromQL parser, but we check here for safety.
+			return nil, fmt.Errorf("unexpected parameter for %s aggregation: %s", e.Op, e.Param)
+		}
+	}
+
+	inner, err := q.convertToInstantVectorOperator(e.Expr, timeRange)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, interval := timeRange.Start, timeRange.End, timeRange.Interval
+
+	switch e.Op {
+	case parser.COUNT_VALUES:
+		labelName, ok := unwrapParenAndStepInvariantExpr(e.Param).(*parser.StringLiteral)
+		if !ok {
+			return nil, compat.NewNotSupportedError(fmt.Sprintf("'%s' aggregation with non-literal parameter", e.Op))
+		}
+
+		return operators.NewCountValues(inner, labelName.Val, start, end, interval, e.Grouping, e.Without, q.pool)
+	case parser.TOPK, parser.BOTTOMK, parser.LIMITK, parser.LIMIT_RATIO, parser.QUANTILE:
+		param, ok := unwrapParenAndStepInvariantExpr(e.Param).(*parser.NumberLiteral)
+		if !ok {
+			return nil, compat.NewNotSupportedError(fmt.Sprintf("'%s' aggregation with non-literal parameter", e.Op))
+		}
+
+		switch e.Op {
+		case parser.TOPK, parser.BOTTOMK:
+			return operators.NewTopKBottomK(inner, param.Val, e.Op == parser.TOPK, start, end, interval, e.Grouping, e.Without, q.pool)
+		case parser.LIMITK:
+			return operators.NewLimitK(inner, param.Val, start, end, interval, e.Grouping, e.Without, q.pool)
+		case parser.LIMIT_RATIO:
+			return operators.NewLimitRatio(inner, param.Val, q.pool)
+		default:
+			return operators.NewQuantileAggregation(inner, param.Val, start, end, interval, e.Grouping, e.Without, q.pool), nil
+		}
+	default:
+		return operators.NewAggregation(inner, start, end, interval, e.Grouping, e.Without, e.Op, q.pool)
+	}
+}
+
+// unwrapParenExpr returns the expression wrapped by any parentheses.
+func unwrapParenExpr(expr parser.Expr) parser.Expr {
+	for {
+		e, ok := expr.(*parser.ParenExpr)
+		if !ok {
+			return expr
+		}
+
+		expr = e.Expr
+	}
+}
+
+// unwrapParenAndStepInvariantExpr returns the expression wrapped by any parentheses or step invariant expressions.
+func unwrapParenAndStepInvariantExpr(expr parser.Expr) parser.Expr {
+	for {
+		switch e := expr.(type) {
+		case *parser.ParenExpr:
+			expr = e.Expr
+		case *parser.StepInvariantExpr:
+			expr = e.Expr
+		default:
+			return expr
+		}
+	}
+}
+
+func (q *Query) convertFunctionCallToOperator(e *parser.Call, timeRange types.QueryTimeRange) (types.InstantVectorOperator, error) {
+	switch e.Func.Name {
+	case "absent":
+		// absent needs the expression passed to it to determine the labels of its output series,
+		// so it can't be created by a factory.
+		return q.convertAbsentToOperator(e, timeRange)
+	case "absent_over_time":
+		// absent_over_time needs the expression passed to it to determine the labels of its output series,
+		// so it can't be created by a factory.
+		return q.convertAbsentOverTimeToOperator(e, timeRange)
+	}
+
 	factory, ok := instantVectorFunctionOperatorFactories[e.Func.Name]
 	if !ok {
 		return nil, compat.NewNotSupportedError(fmt.Sprintf("'%s' function", e.Func.Name))
 	}
 
+	args, err := q.convertFunctionArgsToOperators(e, timeRange)
+	if err != nil {
+		return nil, err
+	}
+
+	return factory(args, q.pool, timeRange)
+}
+
+// convertFunctionArgsToOperators returns an operator for each argument of e.
+func (q *Query) convertFunctionArgsToOperators(e *parser.Call, timeRange types.QueryTimeRange) ([]types.Operator, error) {
 	args := make([]types.Operator, len(e.Args))
 	for i := range e.Args {
-		a, err := q.convertToOperator(e.Args[i])
+		if selector, ok := timestampFunctionVectorSelector(e); ok {
+			// See timestampFunctionVectorSelector for why we don't use convertToOperator here.
+			a, err := q.convertToInstrumentedInstantVectorOperator(selector, timeRange)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = a
+			continue
+		}
+
+		if e.Args[i].Type() == parser.ValueTypeString {
+			// Strings are only supported as function arguments, so we don't handle them in convertToOperator.
+			a, err := q.convertToStringOperator(e.Args[i])
+			if err != nil {
+				return nil, err
+			}
+			args[i] = a
+			continue
+		}
+
+		a, err := q.convertToOperator(e.Args[i], timeRange)
 		if err != nil {
 			return nil, err
 		}
 		args[i] = a
 	}
 
-	return factory(args, q.pool)
+	// Prefetch any arguments that select series, so that they are fetched concurrently.
+	if prefetchGroup := q.newPrefetchGroup(e.Args...); prefetchGroup != nil {
+		for i, a := range args {
+			switch e.Args[i].Type() {
+			case parser.ValueTypeVector:
+				args[i] = q.prefetchInstantVectorOperator(prefetchGroup, e.Args[i], a.(types.InstantVectorOperator))
+			case parser.ValueTypeScalar:
+				args[i] = q.prefetchScalarOperator(prefetchGroup, e.Args[i], a.(types.ScalarOperator))
+			}
+		}
+	}
+
+	return args, nil
 }
 
-func (q *Query) convertToRangeVectorOperator(expr parser.Expr) (types.RangeVectorOperator, error) {
-	if expr.Type() != parser.ValueTypeMatrix {
-		return nil, fmt.Errorf("cannot create range vector operator for expression that produces a %s", parser.DocumentedType(expr.Type()))
+func (q *Query) convertAbsentToOperator(e *parser.Call, timeRange types.QueryTimeRange) (types.InstantVectorOperator, error) {
+	if len(e.Args) != 1 {
+		// Should be caught by the PromQL parser, but we check here for safety.
+		return nil, fmt.Errorf("expected exactly 1 argument for absent, got %v", len(e.Args))
+	}
+
+	inner, err := q.convertToInstantVectorOperator(e.Args[0], timeRange)
+	if err != nil {
+		return nil, err
+	}
+
+	lbls := functions.CreateLabelsForAbsentFunction(unwrapParenExpr(e.Args[0]))
+
+	return operators.NewAbsent(inner, lbls, timeRange.Start, timeRange.End, timeRange.Interval, q.pool), nil
+}
+
+func (q *Query) convertAbsentOverTimeToOperator(e *parser.Call, timeRange types.QueryTimeRange) (types.InstantVectorOperator, error) {
+	if len(e.Args) != 1 {
+		// Should be caught by the PromQL parser, but we check here for safety.
+		return nil, fmt.Errorf("expected exactly 1 argument for absent_over_time, got %v", len(e.Args))
+	}
+
+	inner, err := q.convertToRangeVectorOperator(e.Args[0], timeRange)
+	if err != nil {
+		return nil, err
+	}
+
+	lbls := functions.CreateLabelsForAbsentFunction(unwrapParenExpr(e.Args[0]))
+
+	return operators.NewAbsentOverTime(inner, lbls, timeRange.Start, timeRange.End, timeRange.Interval, q.pool), nil
+}
+
+func (q *Query) convertToScalarOperator(expr parser.Expr, timeRange types.QueryTimeRange) (types.ScalarOperator, error) {
+	o, err := q.convertToUninstrumentedScalarOperator(expr, timeRange)
+	if err != nil {
+		return nil, err
+	}
+
+	return operators.InstrumentScalarOperator(o, q.pool), nil
+}
+
+func (q *Query) convertToUninstrumentedScalarOperator(expr parser.Expr, timeRange types.QueryTimeRange) (types.ScalarOperator, error) {
+	if expr.Type() != parser.ValueTypeScalar {
+		return nil, fmt.Errorf("cannot create scalar operator for expression that produces a %s", parser.DocumentedType(expr.Type()))
 	}
 
 	switch e := expr.(type) {
-	case *parser.MatrixSelector:
-		vectorSelector := e.VectorSelector.(*parser.VectorSelector)
+	case *parser.NumberLiteral:
+		return operators.NewScalarConstant(e.Val, timeRange.Start, timeRange.End, timeRange.Interval, q.pool), nil
+	case *parser.Call:
+		return q.convertFunctionCallToScalarOperator(e, timeRange)
+	case *parser.BinaryExpr:
+		// We only need to handle binary expressions between two scalars here: binary expressions between a scalar
+		// and an instant vector produce an instant vector.
+		lhs, err := q.convertToScalarOperator(e.LHS, timeRange)
+		if err != nil {
+			return nil, err
+		}
 
-		if vectorSelector.OriginalOffset != 0 || vectorSelector.Offset != 0 {
-			return nil, compat.NewNotSupportedError("range vector selector with 'offset'")
+		rhs, err := q.convertToScalarOperator(e.RHS, timeRange)
+		if err != nil {
+			return nil, err
 		}
 
-		interval := q.statement.Interval
+		prefetchGroup := q.newPrefetchGroup(e.LHS, e.RHS)
+		lhs = q.prefetchScalarOperator(prefetchGroup, e.LHS, lhs)
+		rhs = q.prefetchScalarOperator(prefetchGroup, e.RHS, rhs)
 
-		if q.IsInstant() {
-			interval = time.Millisecond
+		return operators.NewScalarScalarBinaryOperation(lhs, rhs, e.Op, q.pool)
+	case *parser.StepInvariantExpr:
+		if _, isNumberLiteral := e.Expr.(*parser.NumberLiteral); isNumberLiteral || timeRange.Start.Equal(timeRange.End) {
+			// Number literals are already cheap to evaluate at every time step, and if there's only one time step,
+			// there's nothing to gain from evaluating the expression once.
+			return q.convertToScalarOperator(e.Expr, timeRange)
+		}
+
+		inner, err := q.convertToScalarOperator(e.Expr, types.NewInstantQueryTimeRange(timeRange.Start))
+		if err != nil {
+			return nil, err
 		}
 
+		return operators.NewStepInvariantScalarOperator(inner, timeRange.Start, timeRange.End, timeRange.Interval, q.pool), nil
+	case *parser.ParenExpr:
+		return q.convertToScalarOperator(e.Expr, timeRange)
+	default:
+		return nil, compat.NewNotSupportedError(fmt.Sprintf("PromQL expression type %T for scalars", e))
+	}
+}
+
+func (q *Query) convertFunctionCallToScalarOperator(e *parser.Call, timeRange types.QueryTimeRange) (types.ScalarOperator, error) {
+	factory, ok := scalarFunctionOperatorFactories[e.Func.Name]
+	if !ok {
+		return nil, compat.NewNotSupportedError(fmt.Sprintf("'%s' function", e.Func.Name))
+	}
+
+	args, err := q.convertFunctionArgsToOperators(e, timeRange)
+	if err != nil {
+		return nil, err
+	}
+
+	return factory(args, timeRange.Start, timeRange.End, timeRange.Interval, q.pool)
+}
+
+func (q *Query) convertToRangeVectorOperator(expr parser.Expr, timeRange types.QueryTimeRange) (types.RangeVectorOperator, error) {
+	o, err := q.convertToUninstrumentedRangeVectorOperator(expr, timeRange)
+	if err != nil {
+		return nil, err
+	}
+
+	return operators.InstrumentRangeVectorOperator(o, q.pool), nil
+}
+
+func (q *Query) convertToUninstrumentedRangeVectorOperator(expr parser.Expr, timeRange types.QueryTimeRange) (types.RangeVectorOperator, error) {
+	if expr.Type() != parser.ValueTypeMatrix {
+		return nil, fmt.Errorf("cannot create range vector operator for expression that produces a %s", parser.DocumentedType(expr.Type()))
+	}
+
+	switch e := expr.(type) {
+	case *parser.MatrixSelector:
+		vectorSelector := e.VectorSelector.(*parser.VectorSelector)
+
 		return &operators.RangeVectorSelector{
 			Selector: &operators.Selector{
 				Queryable: q.queryable,
-				Start:     timestamp.FromTime(q.statement.Start),
-				End:       timestamp.FromTime(q.statement.End),
+				Start:     timestamp.FromTime(timeRange.Start),
+				End:       timestamp.FromTime(timeRange.End),
 				Timestamp: vectorSelector.Timestamp,
-				Interval:  interval.Milliseconds(),
+				Offset:    vectorSelector.OriginalOffset.Milliseconds(),
+				Interval:  timeRange.Interval.Milliseconds(),
 				Range:     e.Range,
 				Matchers:  vectorSelector.LabelMatchers,
+				Stats:     q.stats,
 			},
 		}, nil
+	case *parser.SubqueryExpr:
+		step := e.Step
+		if step == 0 {
+			step = time.Duration(q.engine.noStepSubqueryIntervalFn(e.Range.Milliseconds())) * time.Millisecond
+		}
+
+		subqueryTimeRange := operators.SubqueryTimeRange(timeRange, e.Timestamp, e.OriginalOffset, e.Range, step)
+
+		inner, err := q.convertToInstantVectorOperator(e.Expr, subqueryTimeRange)
+		if err != nil {
+			return nil, err
+		}
+
+		return operators.NewSubquery(inner, timeRange, subqueryTimeRange, e.Timestamp, e.OriginalOffset, e.Range, q.pool), nil
 	case *parser.StepInvariantExpr:
-		// One day, we'll do something smarter here.
-		return q.convertToRangeVectorOperator(e.Expr)
+		// Range vectors are not duplicated across time steps (each time step selects its own range), so there's
+		// nothing to gain from evaluating the expression once.
+		return q.convertToRangeVectorOperator(e.Expr, timeRange)
 	case *parser.ParenExpr:
-		return q.convertToRangeVectorOperator(e.Expr)
+		return q.convertToRangeVectorOperator(e.Expr, timeRange)
 	default:
 		return nil, compat.NewNotSupportedError(fmt.Sprintf("PromQL expression type %T", e))
 	}
 }
 
+func (q *Query) convertToStringOperator(expr parser.Expr) (types.StringOperator, error) {
+	if expr.Type() != parser.ValueTypeString {
+		return nil, fmt.Errorf("cannot create string operator for expression that produces a %s", parser.DocumentedType(expr.Type()))
+	}
+
+	switch e := expr.(type) {
+	case *parser.StringLiteral:
+		return operators.NewStringLiteral(e.Val), nil
+	case *parser.StepInvariantExpr:
+		return q.convertToStringOperator(e.Expr)
+	case *parser.ParenExpr:
+		return q.convertToStringOperator(e.Expr)
+	default:
+		return nil, compat.NewNotSupportedError(fmt.Sprintf("PromQL expression type %T for strings", e))
+	}
+}
+
 func (q *Query) IsInstant() bool {
 	return q.statement.Start == q.statement.End && q.statement.Interval == 0
 }
 
 func (q *Query) Exec(ctx context.Context) *promql.Result {
-	defer q.root.Close()
-
 	ctx, cancel := context.WithCancelCause(ctx)
 	q.cancel = cancel
 
@@ -273,6 +629,7 @@ func (q *Query) Exec(ctx context.Context) *promql.Result {
 	if q.engine.activeQueryTracker != nil {
 		queryID, err := q.engine.activeQueryTracker.Insert(ctx, q.qs)
 		if err != nil {
+			q.root.Close()
 			return &promql.Result{Err: err}
 		}
 
@@ -280,41 +637,61 @@ func (q *Query) Exec(ctx context.Context) *promql.Result {
 	}
 
 	defer func() {
+		// Close the operators before recording their statistics, so that any operators being prefetched in the
+		// background have finished.
+		q.root.Close()
+
 		logger := spanlogger.FromContext(ctx, q.engine.logger)
 		level.Info(logger).Log("msg", "query stats", "estimatedPeakMemoryConsumption", q.pool.PeakEstimatedMemoryConsumptionBytes)
 		q.engine.estimatedPeakMemoryConsumption.Observe(float64(q.pool.PeakEstimatedMemoryConsumptionBytes))
+		q.recordOperatorStats(ctx, logger)
 	}()
 
-	series, err := q.root.SeriesMetadata(ctx)
-	if err != nil {
-		return &promql.Result{Err: err}
-	}
-	defer pooling.PutSeriesMetadataSlice(series)
-
 	switch q.statement.Expr.Type() {
 	case parser.ValueTypeMatrix:
-		v, err := q.populateMatrixFromRangeVectorOperator(ctx, q.root.(types.RangeVectorOperator), series)
+		root := q.root.(types.RangeVectorOperator)
+		series, err := root.SeriesMetadata(ctx)
+		if err != nil {
+			return &promql.Result{Err: err}
+		}
+		defer pooling.PutSeriesMetadataSlice(series)
+
+		v, err := q.populateMatrixFromRangeVectorOperator(ctx, root, series)
 		if err != nil {
 			return &promql.Result{Err: err}
 		}
 
 		q.result = &promql.Result{Value: v}
 	case parser.ValueTypeVector:
+		root := q.root.(types.InstantVectorOperator)
+		series, err := root.SeriesMetadata(ctx)
+		if err != nil {
+			return &promql.Result{Err: err}
+		}
+		defer pooling.PutSeriesMetadataSlice(series)
+
 		if q.IsInstant() {
-			v, err := q.populateVectorFromInstantVectorOperator(ctx, q.root.(types.InstantVectorOperator), series)
+			v, err := q.populateVectorFromInstantVectorOperator(ctx, root, series)
 			if err != nil {
 				return &promql.Result{Err: err}
 			}
 
 			q.result = &promql.Result{Value: v}
 		} else {
-			v, err := q.populateMatrixFromInstantVectorOperator(ctx, q.root.(types.InstantVectorOperator), series)
+			v, err := q.populateMatrixFromInstantVectorOperator(ctx, root, series)
 			if err != nil {
 				return &promql.Result{Err: err}
 			}
 
 			q.result = &promql.Result{Value: v}
 		}
+	case parser.ValueTypeScalar:
+		v, err := q.populateScalarFromScalarOperator(ctx, q.root.(types.ScalarOperator))
+		if err != nil {
+			return &promql.Result{Err: err}
+		}
+
+		q.result = &promql.Result{Value: v}
 	default:
 		// This should be caught in newQuery above.
 		return &promql.Result{Err: compat.NewNotSupportedError(fmt.Sprintf("unsupported result type %s", parser.DocumentedType(q.statement.Expr.Type())))}
@@ -323,6 +700,31 @@ func (q *Query) Exec(ctx context.Context) *promql.Result {
 	return q.result
 }
 
+func (q *Query) populateScalarFromScalarOperator(ctx context.Context, o types.ScalarOperator) (parser.Value, error) {
+	d, err := o.GetValues(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.IsInstant() {
+		defer q.pool.PutFPointSlice(d.Samples)
+
+		return promql.Scalar{
+			T: timeMilliseconds(q.statement.Start),
+			V: d.Samples[0].F,
+		}, nil
+	}
+
+	// Range queries return scalars as a matrix with a single series with no labels, consistent with Prometheus' engine.
+	m := pooling.GetMatrix(1)
+	m = append(m, promql.Series{
+		Metric: labels.EmptyLabels(),
+		Floats: d.Samples,
+	})
+
+	return m, nil
+}
+
 func (q *Query) populateVectorFromInstantVectorOperator(ctx context.Context, o types.InstantVectorOperator, series []types.SeriesMetadata) (promql.Vector, error) {
 	ts := timeMilliseconds(q.statement.Start)
 	v, err := q.pool.GetVector(len(series))
@@ -468,18 +870,100 @@ func (q *Query) Close() {
 		pooling.PutMatrix(v)
 	case promql.Vector:
 		q.pool.PutVector(v)
+	case promql.Scalar:
+		// Nothing to do, we already returned the slice in populateScalarFromScalarOperator.
 	default:
 		panic(fmt.Sprintf("unknown result value type %T", q.result.Value))
 	}
 }
 
+// Plan returns a description of the operators that will be used to evaluate the query.
+// recordOperatorStats adds statistics about the evaluation of each operator in the query to the query's stats and
+// to the current span.
+func (q *Query) recordOperatorStats(ctx context.Context, logger *spanlogger.SpanLogger) {
+	operatorStats := collectOperatorStats(q.Plan(), "0", nil)
+
+	for _, o := range operatorStats {
+		logger.DebugLog(
+			"msg", "operator stats",
+			"position", o.Position,
+			"operator", o.Operator,
+			"wall_time", o.WallTime,
+			"input_series", o.InputSeries,
+			"output_series", o.OutputSeries,
+			"samples_processed", o.SamplesProcessed,
+			"peak_estimated_memory_consumption_bytes", o.PeakEstimatedMemoryConsumptionBytes,
+		)
+	}
+
+	querier_stats.FromContext(ctx).AddOperatorStats(operatorStats...)
+}
+
+// collectOperatorStats appends statistics for node and its descendants to operatorStats.
+//
+// The statistics recorded for each node include its descendants, so we subtract the statistics of its children
+// to determine the wall time spent in each operator itself.
+func collectOperatorStats(node *types.PlanNode, position string, operatorStats []querier_stats.OperatorStats) []querier_stats.OperatorStats {
+	if node.Stats == nil {
+		return operatorStats
+	}
+
+	o := querier_stats.OperatorStats{
+		Position:                            position,
+		Operator:                            node.Description(),
+		WallTime:                            node.Stats.WallTime,
+		OutputSeries:                        uint64(node.Stats.OutputSeries),
+		PeakEstimatedMemoryConsumptionBytes: node.Stats.PeakEstimatedMemoryConsumptionBytes,
+	}
+
+	instrumentedChildren := 0
+
+	for _, child := range node.Children {
+		if child.Stats == nil {
+			continue
+		}
+
+		instrumentedChildren++
+		o.WallTime -= child.Stats.WallTime
+		o.InputSeries += uint64(child.Stats.OutputSeries)
+		o.SamplesProcessed += uint64(child.Stats.OutputSamples)
+	}
+
+	if instrumentedChildren == 0 {
+		o.SamplesProcessed = uint64(node.Stats.OutputSamples)
+	}
+
+	// Wall time is measured independently for each operator, so clock granularity could make the difference negative.
+	o.WallTime = max(o.WallTime, 0)
+
+	operatorStats = append(operatorStats, o)
+
+	for i, child := range node.Children {
+		operatorStats = collectOperatorStats(child, fmt.Sprintf("%s.%d", position, i), operatorStats)
+	}
+
+	return operatorStats
+}
+
+func (q *Query) Plan() *types.PlanNode {
+	return operators.Describe(q.root, q.timeRange)
+}
+
 func (q *Query) Statement() parser.Statement {
 	return q.statement
 }
 
+// Stats returns statistics about the evaluation of the query.
+//
+// Timings and per-step sample counts are not supported. It must only be called once Exec has returned.
 func (q *Query) Stats() *stats.Statistics {
-	// Not yet supported.
-	return nil
+	return &stats.Statistics{
+		Timers: stats.NewQueryTimers(),
+		Samples: &stats.QuerySamples{
+			TotalSamples: q.stats.TotalSamples(),
+			PeakSamples:  int(q.pool.PeakInMemorySamples),
+		},
+	}
 }
 
 func (q *Query) Cancel() {
@@ -25,6 +25,15 @@ type InstantVectorSeriesData struct {
 	Histograms []promql.HPoint
 }
 
+// ScalarData contains the values of a scalar.
+type ScalarData struct {
+	// Samples contains the value of the scalar at each time step.
+	// Samples must be sorted in timestamp order, earliest timestamps first.
+	// Samples must not have duplicate timestamps.
+	// There must be exactly one sample for each time step.
+	Samples []promql.FPoint
+}
+
 // RangeVectorStepData contains the timestamps associated with a single time step produced by a
 // RangeVectorOperator.
 //
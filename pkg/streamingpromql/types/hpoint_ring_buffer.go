@@ -172,6 +172,9 @@ func (b *HPointRingBuffer) NextPoint() (*promql.HPoint, error) {
 		copy(newSlice, b.points[b.firstIndex:])
 		copy(newSlice[pointsAtEnd:], b.points[:b.firstIndex])
 
+		// newSlice now contains the same H pointers as the current slice, so clear the current slice before returning
+		// it to the pool to ensure whoever gets it next doesn't reuse (and overwrite) histograms we're still using.
+		clear(b.points)
 		b.pool.PutHPointSlice(b.points)
 		b.points = newSlice
 		b.firstIndex = 0
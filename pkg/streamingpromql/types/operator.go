@@ -10,21 +10,26 @@ import (
 
 // Operator represents all operators.
 type Operator interface {
+	// Close frees all resources associated with this operator.
+	// Calling SeriesMetadata, NextSeries or GetValues after calling Close may result in unpredictable behaviour, corruption or crashes.
+	// It must be safe to call Close at any time, including if SeriesMetadata, NextSeries or GetValues have returned an error.
+	Close()
+}
+
+// SeriesOperator represents all operators that return one or more series.
+type SeriesOperator interface {
+	Operator
+
 	// SeriesMetadata returns a list of all series that will be returned by this operator.
 	// The returned []SeriesMetadata can be modified by the caller or returned to a pool.
 	// SeriesMetadata may return series in any order, but the same order must be used by both SeriesMetadata and NextSeries.
 	// SeriesMetadata should be called no more than once.
 	SeriesMetadata(ctx context.Context) ([]SeriesMetadata, error)
-
-	// Close frees all resources associated with this operator.
-	// Calling SeriesMetadata or NextSeries after calling Close may result in unpredictable behaviour, corruption or crashes.
-	// It must be safe to call Close at any time, including if SeriesMetadata or NextSeries have returned an error.
-	Close()
 }
 
 // InstantVectorOperator represents all operators that produce instant vectors.
 type InstantVectorOperator interface {
-	Operator
+	SeriesOperator
 
 	// NextSeries returns the next series from this operator, or EOS if no more series are available.
 	// SeriesMetadata must be called exactly once before calling NextSeries.
@@ -35,7 +40,7 @@ type InstantVectorOperator interface {
 
 // RangeVectorOperator represents all operators that produce range vectors.
 type RangeVectorOperator interface {
-	Operator
+	SeriesOperator
 
 	// StepCount returns the number of time steps produced for each series by this operator.
 	// StepCount must only be called after calling SeriesMetadata.
@@ -63,4 +68,22 @@ type RangeVectorOperator interface {
 	NextStepSamples(floats *FPointRingBuffer, histograms *HPointRingBuffer) (RangeVectorStepData, error)
 }
 
+// ScalarOperator represents all operators that produce scalars.
+type ScalarOperator interface {
+	Operator
+
+	// GetValues returns the samples for this scalar, with one sample for each time step.
+	// GetValues should be called no more than once.
+	// The returned ScalarData can be modified by the caller or returned to a pool.
+	GetValues(ctx context.Context) (ScalarData, error)
+}
+
+// StringOperator represents all operators that produce strings.
+type StringOperator interface {
+	Operator
+
+	// GetValue returns the string produced by this operator.
+	GetValue() string
+}
+
 var EOS = errors.New("operator stream exhausted") //nolint:revive
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package types
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// PlanNode describes an operator in a query plan, and the operators it consumes.
+type PlanNode struct {
+	Operator  string         `json:"operator"`          // The type of operator, eg. "Aggregation".
+	Details   string         `json:"details,omitempty"` // Operator-specific details, eg. the name of the aggregation or function.
+	Matchers  []string       `json:"matchers,omitempty"`
+	Grouping  []string       `json:"grouping,omitempty"`
+	Without   bool           `json:"without,omitempty"`
+	TimeRange PlanTimeRange  `json:"timeRange"`       // The time range the operator is evaluated over.
+	Stats     *OperatorStats `json:"stats,omitempty"` // Statistics about the evaluation of the operator, if it has been evaluated.
+	Children  []*PlanNode    `json:"children,omitempty"`
+}
+
+// OperatorStats describes the evaluation of an operator.
+//
+// Wall time and peak memory consumption include time spent and memory consumed in the operators it consumes.
+type OperatorStats struct {
+	WallTime                            time.Duration `json:"wallTime"`
+	OutputSeries                        int           `json:"outputSeries"`
+	OutputSamples                       int           `json:"outputSamples"`
+	PeakEstimatedMemoryConsumptionBytes uint64        `json:"peakEstimatedMemoryConsumptionBytes"`
+}
+
+// PlanTimeRange describes the time steps at which an operator in a query plan is evaluated.
+type PlanTimeRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Step  string    `json:"step,omitempty"` // Not set for operators evaluated at a single step.
+}
+
+func NewPlanTimeRange(timeRange QueryTimeRange) PlanTimeRange {
+	r := PlanTimeRange{
+		Start: timeRange.Start.UTC(),
+		End:   timeRange.End.UTC(),
+	}
+
+	if !timeRange.Start.Equal(timeRange.End) {
+		r.Step = model.Duration(timeRange.Interval).String()
+	}
+
+	return r
+}
+
+func (r PlanTimeRange) String() string {
+	if r.Step == "" {
+		return "at " + r.Start.Format(time.RFC3339Nano)
+	}
+
+	return "from " + r.Start.Format(time.RFC3339Nano) + " to " + r.End.Format(time.RFC3339Nano) + " with step " + r.Step
+}
+
+// String returns the plan rooted at n as a text tree, with one operator per line.
+//
+// The time range of each operator is only included if it differs from the time range of the operator that consumes it.
+func (n *PlanNode) String() string {
+	b := &strings.Builder{}
+	n.write(b, "", "", nil)
+
+	return b.String()
+}
+
+func (n *PlanNode) write(b *strings.Builder, prefix string, childPrefix string, parentTimeRange *PlanTimeRange) {
+	b.WriteString(prefix)
+	b.WriteString(n.Operator)
+
+	if n.Details != "" {
+		b.WriteString(": ")
+		b.WriteString(n.Details)
+	}
+
+	if len(n.Matchers) > 0 {
+		b.WriteString(" {")
+		b.WriteString(strings.Join(n.Matchers, ", "))
+		b.WriteString("}")
+	}
+
+	n.writeGrouping(b)
+
+	if parentTimeRange == nil || !parentTimeRange.equal(n.TimeRange) {
+		b.WriteString(" [")
+		b.WriteString(n.TimeRange.String())
+		b.WriteString("]")
+	}
+
+	b.WriteString("\n")
+
+	for i, child := range n.Children {
+		if i == len(n.Children)-1 {
+			child.write(b, childPrefix+"└─ ", childPrefix+"   ", &n.TimeRange)
+		} else {
+			child.write(b, childPrefix+"├─ ", childPrefix+"│  ", &n.TimeRange)
+		}
+	}
+}
+
+// Description returns a short description of n, without its matchers or time range, eg. "Aggregation: sum by (env)".
+func (n *PlanNode) Description() string {
+	b := &strings.Builder{}
+	b.WriteString(n.Operator)
+
+	if n.Details != "" {
+		b.WriteString(": ")
+		b.WriteString(n.Details)
+	}
+
+	n.writeGrouping(b)
+
+	return b.String()
+}
+
+func (n *PlanNode) writeGrouping(b *strings.Builder) {
+	if len(n.Grouping) == 0 {
+		return
+	}
+
+	if n.Without {
+		b.WriteString(" without (")
+	} else {
+		b.WriteString(" by (")
+	}
+
+	b.WriteString(strings.Join(n.Grouping, ", "))
+	b.WriteString(")")
+}
+
+func (r PlanTimeRange) equal(other PlanTimeRange) bool {
+	return r.Start.Equal(other.Start) && r.End.Equal(other.End) && r.Step == other.Step
+}
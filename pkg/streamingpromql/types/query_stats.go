@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package types
+
+import (
+	"github.com/prometheus/prometheus/promql"
+	"go.uber.org/atomic"
+)
+
+// QueryStats tracks statistics about the evaluation of a single query.
+//
+// It is safe to use from multiple goroutines simultaneously, so that independent parts of a query can be
+// evaluated concurrently.
+type QueryStats struct {
+	totalSamples atomic.Int64
+}
+
+// IncrementSamples adds samples to the total number of samples processed by the query.
+func (s *QueryStats) IncrementSamples(samples int64) {
+	if s == nil {
+		return
+	}
+
+	s.totalSamples.Add(samples)
+}
+
+// TotalSamples returns the total number of samples processed by the query.
+func (s *QueryStats) TotalSamples() int64 {
+	if s == nil {
+		return 0
+	}
+
+	return s.totalSamples.Load()
+}
+
+// SampleCount returns the number of samples in floats and histograms.
+//
+// Like Prometheus' engine, each histogram is counted in proportion to its size, rather than as a single sample.
+func SampleCount(floats []promql.FPoint, histograms []promql.HPoint) int64 {
+	count := int64(len(floats))
+
+	for _, h := range histograms {
+		count += HistogramSampleCount(h)
+	}
+
+	return count
+}
+
+// HistogramSampleCount returns the number of samples h is equivalent to, using the same calculation as Prometheus'
+// engine.
+func HistogramSampleCount(h promql.HPoint) int64 {
+	return int64((h.H.Size() + 8) / 16)
+}
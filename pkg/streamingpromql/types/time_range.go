@@ -0,0 +1,31 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package types
+
+import "time"
+
+// QueryTimeRange describes the time steps at which an expression is evaluated.
+//
+// This is the time range of the query for most expressions, but expressions inside a subquery are evaluated over the
+// time range of the subquery.
+type QueryTimeRange struct {
+	Start    time.Time
+	End      time.Time
+	Interval time.Duration // The time between each step. Instant queries have a single step and an interval of 1ms.
+}
+
+func NewInstantQueryTimeRange(t time.Time) QueryTimeRange {
+	return QueryTimeRange{
+		Start:    t,
+		End:      t,
+		Interval: time.Millisecond,
+	}
+}
+
+func NewRangeQueryTimeRange(start time.Time, end time.Time, interval time.Duration) QueryTimeRange {
+	return QueryTimeRange{
+		Start:    start,
+		End:      end,
+		Interval: interval,
+	}
+}
@@ -59,6 +59,7 @@ const (
 	MaxQueryLength              ID = "max-query-length"
 	MaxTotalQueryLength         ID = "max-total-query-length"
 	MaxQueryExpressionSizeBytes ID = "max-query-expression-size-bytes"
+	MaxEstimatedQueryCost       ID = "max-estimated-query-cost"
 	RequestRateLimited          ID = "tenant-max-request-rate"
 	IngestionRateLimited        ID = "tenant-max-ingestion-rate"
 	TooManyHAClusters           ID = "tenant-too-many-ha-clusters"
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	GraphiteMappingActionMap  = "map"
+	GraphiteMappingActionDrop = "drop"
+)
+
+// GraphiteMapping is a rule mapping the Graphite metrics whose path matches a glob pattern
+// to a Prometheus metric name and labels.
+type GraphiteMapping struct {
+	Match  string            `yaml:"match"`
+	Name   string            `yaml:"name"`
+	Labels map[string]string `yaml:"labels"`
+	Action string            `yaml:"action"`
+}
+
+func (m *GraphiteMapping) validate() error {
+	if m == nil {
+		return fmt.Errorf("invalid graphite_mappings")
+	}
+	if m.Match == "" {
+		return fmt.Errorf("invalid graphite_mappings: the match pattern is required")
+	}
+	for _, component := range strings.Split(m.Match, ".") {
+		if component == "" {
+			return fmt.Errorf("invalid graphite_mappings: the match pattern %q has an empty path component", m.Match)
+		}
+	}
+
+	switch m.Action {
+	case "", GraphiteMappingActionMap:
+		if m.Name == "" {
+			return fmt.Errorf("invalid graphite_mappings: the name of the mapping matching %q is required", m.Match)
+		}
+	case GraphiteMappingActionDrop:
+	default:
+		return fmt.Errorf("invalid graphite_mappings: unsupported action %q (supported: %s, %s)", m.Action, GraphiteMappingActionMap, GraphiteMappingActionDrop)
+	}
+	return nil
+}
@@ -29,36 +29,38 @@ import (
 )
 
 const (
-	MaxSeriesPerMetricFlag                    = "ingester.max-global-series-per-metric"
-	MaxMetadataPerMetricFlag                  = "ingester.max-global-metadata-per-metric"
-	MaxSeriesPerUserFlag                      = "ingester.max-global-series-per-user"
-	MaxMetadataPerUserFlag                    = "ingester.max-global-metadata-per-user"
-	MaxChunksPerQueryFlag                     = "querier.max-fetched-chunks-per-query"
-	MaxChunkBytesPerQueryFlag                 = "querier.max-fetched-chunk-bytes-per-query"
-	MaxSeriesPerQueryFlag                     = "querier.max-fetched-series-per-query"
-	MaxEstimatedChunksPerQueryMultiplierFlag  = "querier.max-estimated-fetched-chunks-per-query-multiplier"
-	MaxEstimatedMemoryConsumptionPerQueryFlag = "querier.max-estimated-memory-consumption-per-query"
-	MaxLabelNamesPerSeriesFlag                = "validation.max-label-names-per-series"
-	MaxLabelNameLengthFlag                    = "validation.max-length-label-name"
-	MaxLabelValueLengthFlag                   = "validation.max-length-label-value"
-	MaxMetadataLengthFlag                     = "validation.max-metadata-length"
-	maxNativeHistogramBucketsFlag             = "validation.max-native-histogram-buckets"
-	ReduceNativeHistogramOverMaxBucketsFlag   = "validation.reduce-native-histogram-over-max-buckets"
-	CreationGracePeriodFlag                   = "validation.create-grace-period"
-	PastGracePeriodFlag                       = "validation.past-grace-period"
-	MaxPartialQueryLengthFlag                 = "querier.max-partial-query-length"
-	MaxTotalQueryLengthFlag                   = "query-frontend.max-total-query-length"
-	MaxQueryExpressionSizeBytesFlag           = "query-frontend.max-query-expression-size-bytes"
-	RequestRateFlag                           = "distributor.request-rate-limit"
-	RequestBurstSizeFlag                      = "distributor.request-burst-size"
-	IngestionRateFlag                         = "distributor.ingestion-rate-limit"
-	IngestionBurstSizeFlag                    = "distributor.ingestion-burst-size"
-	IngestionBurstFactorFlag                  = "distributor.ingestion-burst-factor"
-	HATrackerMaxClustersFlag                  = "distributor.ha-tracker.max-clusters"
-	resultsCacheTTLFlag                       = "query-frontend.results-cache-ttl"
-	resultsCacheTTLForOutOfOrderWindowFlag    = "query-frontend.results-cache-ttl-for-out-of-order-time-window"
-	alignQueriesWithStepFlag                  = "query-frontend.align-queries-with-step"
-	QueryIngestersWithinFlag                  = "querier.query-ingesters-within"
+	MaxSeriesPerMetricFlag                     = "ingester.max-global-series-per-metric"
+	MaxMetadataPerMetricFlag                   = "ingester.max-global-metadata-per-metric"
+	MaxSeriesPerUserFlag                       = "ingester.max-global-series-per-user"
+	MaxMetadataPerUserFlag                     = "ingester.max-global-metadata-per-user"
+	MaxChunksPerQueryFlag                      = "querier.max-fetched-chunks-per-query"
+	MaxChunkBytesPerQueryFlag                  = "querier.max-fetched-chunk-bytes-per-query"
+	MaxSeriesPerQueryFlag                      = "querier.max-fetched-series-per-query"
+	MaxEstimatedChunksPerQueryMultiplierFlag   = "querier.max-estimated-fetched-chunks-per-query-multiplier"
+	MaxEstimatedMemoryConsumptionPerQueryFlag  = "querier.max-estimated-memory-consumption-per-query"
+	MaxConcurrentSubtreePrefetchesPerQueryFlag = "querier.max-concurrent-subtree-prefetches-per-query"
+	MaxLabelNamesPerSeriesFlag                 = "validation.max-label-names-per-series"
+	MaxLabelNameLengthFlag                     = "validation.max-length-label-name"
+	MaxLabelValueLengthFlag                    = "validation.max-length-label-value"
+	MaxMetadataLengthFlag                      = "validation.max-metadata-length"
+	maxNativeHistogramBucketsFlag              = "validation.max-native-histogram-buckets"
+	ReduceNativeHistogramOverMaxBucketsFlag    = "validation.reduce-native-histogram-over-max-buckets"
+	CreationGracePeriodFlag                    = "validation.create-grace-period"
+	PastGracePeriodFlag                        = "validation.past-grace-period"
+	MaxPartialQueryLengthFlag                  = "querier.max-partial-query-length"
+	MaxTotalQueryLengthFlag                    = "query-frontend.max-total-query-length"
+	MaxQueryExpressionSizeBytesFlag            = "query-frontend.max-query-expression-size-bytes"
+	MaxEstimatedQueryCostFlag                  = "query-frontend.max-estimated-query-cost"
+	RequestRateFlag                            = "distributor.request-rate-limit"
+	RequestBurstSizeFlag                       = "distributor.request-burst-size"
+	IngestionRateFlag                          = "distributor.ingestion-rate-limit"
+	IngestionBurstSizeFlag                     = "distributor.ingestion-burst-size"
+	IngestionBurstFactorFlag                   = "distributor.ingestion-burst-factor"
+	HATrackerMaxClustersFlag                   = "distributor.ha-tracker.max-clusters"
+	resultsCacheTTLFlag                        = "query-frontend.results-cache-ttl"
+	resultsCacheTTLForOutOfOrderWindowFlag     = "query-frontend.results-cache-ttl-for-out-of-order-time-window"
+	alignQueriesWithStepFlag                   = "query-frontend.align-queries-with-step"
+	QueryIngestersWithinFlag                   = "querier.query-ingesters-within"
 
 	// MinCompactorPartialBlockDeletionDelay is the minimum partial blocks deletion delay that can be configured in Mimir.
 	MinCompactorPartialBlockDeletionDelay = 4 * time.Hour
@@ -143,22 +145,23 @@ type Limits struct {
 	SeparateMetricsGroupLabel string `yaml:"separate_metrics_group_label" json:"separate_metrics_group_label" category:"experimental"`
 
 	// Querier enforced limits.
-	MaxChunksPerQuery                     int            `yaml:"max_fetched_chunks_per_query" json:"max_fetched_chunks_per_query"`
-	MaxEstimatedChunksPerQueryMultiplier  float64        `yaml:"max_estimated_fetched_chunks_per_query_multiplier" json:"max_estimated_fetched_chunks_per_query_multiplier" category:"experimental"`
-	MaxFetchedSeriesPerQuery              int            `yaml:"max_fetched_series_per_query" json:"max_fetched_series_per_query"`
-	MaxFetchedChunkBytesPerQuery          int            `yaml:"max_fetched_chunk_bytes_per_query" json:"max_fetched_chunk_bytes_per_query"`
-	MaxEstimatedMemoryConsumptionPerQuery uint64         `yaml:"max_estimated_memory_consumption_per_query" json:"max_estimated_memory_consumption_per_query" category:"experimental"`
-	MaxQueryLookback                      model.Duration `yaml:"max_query_lookback" json:"max_query_lookback"`
-	MaxPartialQueryLength                 model.Duration `yaml:"max_partial_query_length" json:"max_partial_query_length"`
-	MaxQueryParallelism                   int            `yaml:"max_query_parallelism" json:"max_query_parallelism"`
-	MaxLabelsQueryLength                  model.Duration `yaml:"max_labels_query_length" json:"max_labels_query_length"`
-	MaxCacheFreshness                     model.Duration `yaml:"max_cache_freshness" json:"max_cache_freshness" category:"advanced"`
-	MaxQueriersPerTenant                  int            `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
-	QueryShardingTotalShards              int            `yaml:"query_sharding_total_shards" json:"query_sharding_total_shards"`
-	QueryShardingMaxShardedQueries        int            `yaml:"query_sharding_max_sharded_queries" json:"query_sharding_max_sharded_queries"`
-	QueryShardingMaxRegexpSizeBytes       int            `yaml:"query_sharding_max_regexp_size_bytes" json:"query_sharding_max_regexp_size_bytes"`
-	SplitInstantQueriesByInterval         model.Duration `yaml:"split_instant_queries_by_interval" json:"split_instant_queries_by_interval" category:"experimental"`
-	QueryIngestersWithin                  model.Duration `yaml:"query_ingesters_within" json:"query_ingesters_within" category:"advanced"`
+	MaxChunksPerQuery                      int            `yaml:"max_fetched_chunks_per_query" json:"max_fetched_chunks_per_query"`
+	MaxEstimatedChunksPerQueryMultiplier   float64        `yaml:"max_estimated_fetched_chunks_per_query_multiplier" json:"max_estimated_fetched_chunks_per_query_multiplier" category:"experimental"`
+	MaxFetchedSeriesPerQuery               int            `yaml:"max_fetched_series_per_query" json:"max_fetched_series_per_query"`
+	MaxFetchedChunkBytesPerQuery           int            `yaml:"max_fetched_chunk_bytes_per_query" json:"max_fetched_chunk_bytes_per_query"`
+	MaxEstimatedMemoryConsumptionPerQuery  uint64         `yaml:"max_estimated_memory_consumption_per_query" json:"max_estimated_memory_consumption_per_query" category:"experimental"`
+	MaxConcurrentSubtreePrefetchesPerQuery int            `yaml:"max_concurrent_subtree_prefetches_per_query" json:"max_concurrent_subtree_prefetches_per_query" category:"experimental"`
+	MaxQueryLookback                       model.Duration `yaml:"max_query_lookback" json:"max_query_lookback"`
+	MaxPartialQueryLength                  model.Duration `yaml:"max_partial_query_length" json:"max_partial_query_length"`
+	MaxQueryParallelism                    int            `yaml:"max_query_parallelism" json:"max_query_parallelism"`
+	MaxLabelsQueryLength                   model.Duration `yaml:"max_labels_query_length" json:"max_labels_query_length"`
+	MaxCacheFreshness                      model.Duration `yaml:"max_cache_freshness" json:"max_cache_freshness" category:"advanced"`
+	MaxQueriersPerTenant                   int            `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
+	QueryShardingTotalShards               int            `yaml:"query_sharding_total_shards" json:"query_sharding_total_shards"`
+	QueryShardingMaxShardedQueries         int            `yaml:"query_sharding_max_sharded_queries" json:"query_sharding_max_sharded_queries"`
+	QueryShardingMaxRegexpSizeBytes        int            `yaml:"query_sharding_max_regexp_size_bytes" json:"query_sharding_max_regexp_size_bytes"`
+	SplitInstantQueriesByInterval          model.Duration `yaml:"split_instant_queries_by_interval" json:"split_instant_queries_by_interval" category:"experimental"`
+	QueryIngestersWithin                   model.Duration `yaml:"query_ingesters_within" json:"query_ingesters_within" category:"advanced"`
 
 	// Query-frontend limits.
 	MaxTotalQueryLength                    model.Duration  `yaml:"max_total_query_length" json:"max_total_query_length"`
@@ -168,6 +171,7 @@ type Limits struct {
 	ResultsCacheTTLForLabelsQuery          model.Duration  `yaml:"results_cache_ttl_for_labels_query" json:"results_cache_ttl_for_labels_query"`
 	ResultsCacheForUnalignedQueryEnabled   bool            `yaml:"cache_unaligned_requests" json:"cache_unaligned_requests" category:"advanced"`
 	MaxQueryExpressionSizeBytes            int             `yaml:"max_query_expression_size_bytes" json:"max_query_expression_size_bytes"`
+	MaxEstimatedQueryCost                  int             `yaml:"max_estimated_query_cost" json:"max_estimated_query_cost" category:"experimental"`
 	BlockedQueries                         []*BlockedQuery `yaml:"blocked_queries,omitempty" json:"blocked_queries,omitempty" doc:"nocli|description=List of queries to block." category:"experimental"`
 	AlignQueriesWithStep                   bool            `yaml:"align_queries_with_step" json:"align_queries_with_step"`
 
@@ -227,7 +231,11 @@ type Limits struct {
 	AlertmanagerMaxAlertsSizeBytes             int `yaml:"alertmanager_max_alerts_size_bytes" json:"alertmanager_max_alerts_size_bytes"`
 
 	// OpenTelemetry
-	OTelMetricSuffixesEnabled bool `yaml:"otel_metric_suffixes_enabled" json:"otel_metric_suffixes_enabled" category:"advanced"`
+	OTelMetricSuffixesEnabled     bool                   `yaml:"otel_metric_suffixes_enabled" json:"otel_metric_suffixes_enabled" category:"advanced"`
+	PromoteOTelResourceAttributes flagext.StringSliceCSV `yaml:"promote_otel_resource_attributes" json:"promote_otel_resource_attributes" category:"experimental"`
+
+	// Graphite
+	GraphiteMappings []*GraphiteMapping `yaml:"graphite_mappings,omitempty" json:"graphite_mappings,omitempty" doc:"nocli|description=List of rules mapping the paths of the metrics ingested through the Graphite endpoint to Prometheus metric names and labels. The first rule whose glob pattern matches the path applies. The paths not matching any rule are converted to metric names by replacing the invalid characters with underscores." category:"experimental"`
 
 	// Ingest storage.
 	IngestStorageReadConsistency       string `yaml:"ingest_storage_read_consistency" json:"ingest_storage_read_consistency" category:"experimental"`
@@ -263,6 +271,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&l.MetricRelabelingEnabled, "distributor.metric-relabeling-enabled", true, "Enable metric relabeling for the tenant. This configuration option can be used to forcefully disable metric relabeling on a per-tenant basis.")
 	f.BoolVar(&l.ServiceOverloadStatusCodeOnRateLimitEnabled, "distributor.service-overload-status-code-on-rate-limit-enabled", false, "If enabled, rate limit errors will be reported to the client with HTTP status code 529 (Service is overloaded). If disabled, status code 429 (Too Many Requests) is used. Enabling -distributor.retry-after-header.enabled before utilizing this option is strongly recommended as it helps prevent premature request retries by the client.")
 	f.BoolVar(&l.OTelMetricSuffixesEnabled, "distributor.otel-metric-suffixes-enabled", false, "Whether to enable automatic suffixes to names of metrics ingested through OTLP.")
+	f.Var(&l.PromoteOTelResourceAttributes, "distributor.promote-otel-resource-attributes", "List of OTel resource attributes to promote to labels of every series ingested through OTLP from the resource, for example k8s.namespace.name,deployment.environment. The attribute names are translated to label names following the OTel to Prometheus naming conventions. Labels of the series take precedence over the promoted attributes. On the command line, this list is given as a comma-separated list.")
 
 	f.IntVar(&l.MaxGlobalSeriesPerUser, MaxSeriesPerUserFlag, 150000, "The maximum number of in-memory series per tenant, across the cluster before replication. 0 to disable.")
 	f.IntVar(&l.MaxGlobalSeriesPerMetric, MaxSeriesPerMetricFlag, 0, "The maximum number of in-memory series per metric name, across the cluster before replication. 0 to disable.")
@@ -282,6 +291,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.MaxFetchedSeriesPerQuery, MaxSeriesPerQueryFlag, 0, "The maximum number of unique series for which a query can fetch samples from ingesters and store-gateways. This limit is enforced in the querier, ruler and store-gateway. 0 to disable")
 	f.IntVar(&l.MaxFetchedChunkBytesPerQuery, MaxChunkBytesPerQueryFlag, 0, "The maximum size of all chunks in bytes that a query can fetch from ingesters and store-gateways. This limit is enforced in the querier and ruler. 0 to disable.")
 	f.Uint64Var(&l.MaxEstimatedMemoryConsumptionPerQuery, MaxEstimatedMemoryConsumptionPerQueryFlag, 0, "The maximum estimated memory a single query can consume at once, in bytes. This limit is only enforced when Mimir's query engine is in use. This limit is enforced in the querier. 0 to disable.")
+	f.IntVar(&l.MaxConcurrentSubtreePrefetchesPerQuery, MaxConcurrentSubtreePrefetchesPerQueryFlag, 0, "The maximum number of subexpressions of a single query, such as each side of a binary operation, that can be prefetched concurrently. Prefetching is only supported by Mimir's query engine, and memory consumed by prefetched subexpressions counts towards -"+MaxEstimatedMemoryConsumptionPerQueryFlag+". This limit is enforced in the querier. 0 to disable prefetching.")
 	f.Var(&l.MaxPartialQueryLength, MaxPartialQueryLengthFlag, "Limit the time range for partial queries at the querier level.")
 	f.Var(&l.MaxQueryLookback, "querier.max-query-lookback", "Limit how long back data (series and metadata) can be queried, up until <lookback> duration ago. This limit is enforced in the query-frontend, querier and ruler for instant, range and remote read queries. For metadata queries like series, label names, label values queries the limit is enforced in the querier and ruler. If the requested time range is outside the allowed range, the request will not fail but will be manipulated to only query data within the allowed time range. 0 to disable.")
 	f.IntVar(&l.MaxQueryParallelism, "querier.max-query-parallelism", 14, "Maximum number of split (by time) or partial (by shard) queries that will be scheduled in parallel by the query-frontend for a single input query. This limit is introduced to have a fairer query scheduling and avoid a single query over a large time range saturating all available queriers.")
@@ -343,6 +353,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.Var(&l.ResultsCacheTTLForLabelsQuery, "query-frontend.results-cache-ttl-for-labels-query", "Time to live duration for cached label names and label values query results. The value 0 disables the cache.")
 	f.BoolVar(&l.ResultsCacheForUnalignedQueryEnabled, "query-frontend.cache-unaligned-requests", false, "Cache requests that are not step-aligned.")
 	f.IntVar(&l.MaxQueryExpressionSizeBytes, MaxQueryExpressionSizeBytesFlag, 0, "Max size of the raw query, in bytes. This limit is enforced by the query-frontend for instant, range and remote read queries. 0 to not apply a limit to the size of the query.")
+	f.IntVar(&l.MaxEstimatedQueryCost, MaxEstimatedQueryCostFlag, 0, "Maximum estimated cost of a range or instant query, measured as the estimated number of samples processed by the query. The cost is estimated by the query-frontend from the query's time range, step and expression, and the number of series selected by previous executions of the query, which are only tracked when cardinality-based query sharding is enabled. If the number of series is unknown, each selector of the query is assumed to select a single series. Queries with a higher estimated cost are rejected. 0 to disable.")
 	f.BoolVar(&l.AlignQueriesWithStep, alignQueriesWithStepFlag, false, "Mutate incoming queries to align their start and end with their step to improve result caching.")
 
 	// Store-gateway.
@@ -437,6 +448,12 @@ func (l *Limits) validate() error {
 		}
 	}
 
+	for _, mapping := range l.GraphiteMappings {
+		if err := mapping.validate(); err != nil {
+			return err
+		}
+	}
+
 	if l.MaxEstimatedChunksPerQueryMultiplier < 1 && l.MaxEstimatedChunksPerQueryMultiplier != 0 {
 		return errInvalidMaxEstimatedChunksPerQueryMultiplier
 	}
@@ -639,6 +656,12 @@ func (o *Overrides) MaxEstimatedMemoryConsumptionPerQuery(userID string) uint64
 	return o.getOverridesForUser(userID).MaxEstimatedMemoryConsumptionPerQuery
 }
 
+// MaxConcurrentSubtreePrefetchesPerQuery returns the maximum number of subexpressions of a single query that can be
+// prefetched concurrently. This is only effective when using Mimir's query engine (not Prometheus' engine).
+func (o *Overrides) MaxConcurrentSubtreePrefetchesPerQuery(userID string) int {
+	return o.getOverridesForUser(userID).MaxConcurrentSubtreePrefetchesPerQuery
+}
+
 // MaxQueryLookback returns the max lookback period of queries.
 func (o *Overrides) MaxQueryLookback(userID string) time.Duration {
 	return time.Duration(o.getOverridesForUser(userID).MaxQueryLookback)
@@ -659,6 +682,11 @@ func (o *Overrides) MaxQueryExpressionSizeBytes(userID string) int {
 	return o.getOverridesForUser(userID).MaxQueryExpressionSizeBytes
 }
 
+// MaxEstimatedQueryCost returns the limit of the estimated cost of a query.
+func (o *Overrides) MaxEstimatedQueryCost(userID string) int {
+	return o.getOverridesForUser(userID).MaxEstimatedQueryCost
+}
+
 // BlockedQueries returns the blocked queries.
 func (o *Overrides) BlockedQueries(userID string) []*BlockedQuery {
 	return o.getOverridesForUser(userID).BlockedQueries
@@ -1040,6 +1068,16 @@ func (o *Overrides) OTelMetricSuffixesEnabled(tenantID string) bool {
 	return o.getOverridesForUser(tenantID).OTelMetricSuffixesEnabled
 }
 
+// PromoteOTelResourceAttributes returns the list of OTel resource attributes to promote to series labels.
+func (o *Overrides) PromoteOTelResourceAttributes(tenantID string) []string {
+	return o.getOverridesForUser(tenantID).PromoteOTelResourceAttributes
+}
+
+// GraphiteMappings returns the rules mapping the Graphite metric paths to Prometheus metrics for a given user.
+func (o *Overrides) GraphiteMappings(userID string) []*GraphiteMapping {
+	return o.getOverridesForUser(userID).GraphiteMappings
+}
+
 func (o *Overrides) AlignQueriesWithStep(userID string) bool {
 	return o.getOverridesForUser(userID).AlignQueriesWithStep
 }
@@ -1021,6 +1021,48 @@ metric_relabel_configs:
 			cfg:         `ingest_storage_read_consistency: xyz`,
 			expectedErr: errInvalidIngestStorageReadConsistency.Error(),
 		},
+		"should pass on valid graphite_mappings": {
+			cfg: `
+graphite_mappings:
+  - match: servers.*.cpu.*
+    name: server_cpu_$2
+    labels:
+      server: $1
+  - match: servers.*.debug.*
+    action: drop
+`,
+			expectedErr: "",
+		},
+		"should fail on graphite_mappings without match pattern": {
+			cfg: `
+graphite_mappings:
+  - name: server_cpu
+`,
+			expectedErr: "invalid graphite_mappings: the match pattern is required",
+		},
+		"should fail on graphite_mappings with an empty path component": {
+			cfg: `
+graphite_mappings:
+  - match: servers..cpu
+    name: server_cpu
+`,
+			expectedErr: `invalid graphite_mappings: the match pattern "servers..cpu" has an empty path component`,
+		},
+		"should fail on graphite_mappings without name": {
+			cfg: `
+graphite_mappings:
+  - match: servers.*.cpu
+`,
+			expectedErr: `invalid graphite_mappings: the name of the mapping matching "servers.*.cpu" is required`,
+		},
+		"should fail on graphite_mappings with unsupported action": {
+			cfg: `
+graphite_mappings:
+  - match: servers.*.cpu
+    action: keep
+`,
+			expectedErr: `invalid graphite_mappings: unsupported action "keep"`,
+		},
 	}
 
 	for testName, testData := range tests {